@@ -58,6 +58,45 @@ func (a *AccessSet) Merge(right *AccessSet) {
 	}
 }
 
+// Changed returns the group resources whose access differs between the access sets. all is set instead if a rule
+// for every group or resource changed, since then the access of any resource may differ.
+func (a *AccessSet) Changed(other *AccessSet) (changed map[schema.GroupResource]bool, all bool) {
+	changed = map[schema.GroupResource]bool{}
+	diff := func(k key) bool {
+		if a.set[k].equal(other.set[k]) {
+			return false
+		}
+		if k.gr.Group == All || k.gr.Resource == All {
+			return true
+		}
+		changed[k.gr] = true
+		return false
+	}
+	for k := range a.set {
+		if diff(k) {
+			return nil, true
+		}
+	}
+	for k := range other.set {
+		if _, ok := a.set[k]; !ok && diff(k) {
+			return nil, true
+		}
+	}
+	return changed, false
+}
+
+func (r resourceAccessSet) equal(other resourceAccessSet) bool {
+	if len(r) != len(other) {
+		return false
+	}
+	for access := range r {
+		if !other[access] {
+			return false
+		}
+	}
+	return true
+}
+
 func (a AccessSet) Grants(verb string, gr schema.GroupResource, namespace, name string) bool {
 	for _, v := range []string{All, verb} {
 		for _, g := range []string{All, gr.Group} {
@@ -105,6 +144,15 @@ func (a AccessSet) AccessListFor(verb string, gr schema.GroupResource) (result A
 	return
 }
 
+// Each calls f with every verb, group resource and access granted by the access set.
+func (a *AccessSet) Each(f func(verb string, gr schema.GroupResource, access Access)) {
+	for k, accessSet := range a.set {
+		for access := range accessSet {
+			f(k.verb, k.gr, access)
+		}
+	}
+}
+
 func (a *AccessSet) Add(verb string, gr schema.GroupResource, access Access) {
 	if a.set == nil {
 		a.set = map[key]resourceAccessSet{}
@@ -151,8 +199,8 @@ func (a AccessListByVerb) Granted(verb string) (result map[string]Resources) {
 		result[access.Namespace] = resources
 	}
 
-	if verb == "list" {
-		// look for objects referenced by get
+	if verb == "list" || verb == "watch" {
+		// look for objects referenced by get, so the objects that are listed are also watched
 		for _, access := range a["get"] {
 			resources := result[access.Namespace]
 			if access.ResourceName == All {
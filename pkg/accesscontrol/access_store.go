@@ -4,14 +4,26 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	v1 "github.com/rancher/wrangler/pkg/generated/controllers/rbac/v1"
+	"github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
 
+const (
+	userCacheSecondsEnv = "CATTLE_ACCESS_CACHE_SECONDS"
+
+	defaultUserCacheTTL = 10 * time.Second
+)
+
 type AccessSetLookup interface {
 	AccessFor(user user.Info) *AccessSet
 	PurgeUserData(id string)
@@ -21,6 +33,13 @@ type AccessStore struct {
 	users  *policyRuleIndex
 	groups *policyRuleIndex
 	cache  *cache.LRUExpireCache
+
+	// userCache holds the access sets of users by their name and groups for userCacheTTL, so most requests skip
+	// hashing the roles of the user. Its keys include the generation, which is bumped by every change to a role or
+	// binding so no access set outlives a change of RBAC.
+	userCache    *cache.LRUExpireCache
+	userCacheTTL time.Duration
+	generation   int64
 }
 
 type roleKey struct {
@@ -36,11 +55,76 @@ func NewAccessStore(ctx context.Context, cacheResults bool, rbac v1.Interface) *
 	}
 	if cacheResults {
 		as.cache = cache.NewLRUExpireCache(50)
+		as.userCacheTTL = userCacheTTLFromEnv()
+		if as.userCacheTTL > 0 {
+			as.userCache = cache.NewLRUExpireCache(1000)
+			// registered after the role revisions, so the revisions are current by the time the cache is invalidated
+			rbac.Role().OnChange(ctx, "access-cache-invalidator", func(_ string, obj *rbacv1.Role) (*rbacv1.Role, error) {
+				as.Invalidate()
+				return obj, nil
+			})
+			rbac.RoleBinding().OnChange(ctx, "access-cache-invalidator", func(_ string, obj *rbacv1.RoleBinding) (*rbacv1.RoleBinding, error) {
+				as.Invalidate()
+				return obj, nil
+			})
+			rbac.ClusterRole().OnChange(ctx, "access-cache-invalidator", func(_ string, obj *rbacv1.ClusterRole) (*rbacv1.ClusterRole, error) {
+				as.Invalidate()
+				return obj, nil
+			})
+			rbac.ClusterRoleBinding().OnChange(ctx, "access-cache-invalidator", func(_ string, obj *rbacv1.ClusterRoleBinding) (*rbacv1.ClusterRoleBinding, error) {
+				as.Invalidate()
+				return obj, nil
+			})
+		}
 	}
 	return as
 }
 
+// userCacheTTLFromEnv returns how long the access sets of users are cached, which may be overridden with the
+// CATTLE_ACCESS_CACHE_SECONDS environment variable. Zero disables the cache.
+func userCacheTTLFromEnv() time.Duration {
+	setting := os.Getenv(userCacheSecondsEnv)
+	if setting == "" {
+		return defaultUserCacheTTL
+	}
+	seconds, err := strconv.Atoi(setting)
+	if err != nil || seconds < 0 {
+		logrus.Debugf("could not parse %s environment variable, error: %v", userCacheSecondsEnv, err)
+		return defaultUserCacheTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Invalidate drops the cached access sets of users, so they are computed again on their next request. It is called
+// for every change to a role or binding, and may be called by embedders that change access some other way.
+func (l *AccessStore) Invalidate() {
+	atomic.AddInt64(&l.generation, 1)
+}
+
+func (l *AccessStore) userCacheKey(user user.Info) string {
+	groups := append([]string{}, user.GetGroups()...)
+	sort.Strings(groups)
+	return strconv.FormatInt(atomic.LoadInt64(&l.generation), 10) + "\x00" + user.GetName() + "\x00" + strings.Join(groups, "\x00")
+}
+
 func (l *AccessStore) AccessFor(user user.Info) *AccessSet {
+	var userKey string
+	if l.userCache != nil {
+		userKey = l.userCacheKey(user)
+		if val, ok := l.userCache.Get(userKey); ok {
+			as, _ := val.(*AccessSet)
+			return as
+		}
+	}
+
+	result := l.accessFor(user)
+	if l.userCache != nil {
+		l.userCache.Add(userKey, result, l.userCacheTTL)
+	}
+	return result
+}
+
+func (l *AccessStore) accessFor(user user.Info) *AccessSet {
 	var cacheKey string
 	if l.cache != nil {
 		cacheKey = l.CacheKey(user)
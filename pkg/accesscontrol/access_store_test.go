@@ -0,0 +1,207 @@
+package accesscontrol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/rancher/wrangler/pkg/generated/controllers/rbac/v1"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// fakeRBAC has no roles or bindings, and keeps the handlers registered for their changes. It counts the lookups of
+// bindings, which are only made when the access set of a user isn't cached.
+type fakeRBAC struct {
+	lookups int
+
+	roles               []v1.RoleHandler
+	roleBindings        []v1.RoleBindingHandler
+	clusterRoles        []v1.ClusterRoleHandler
+	clusterRoleBindings []v1.ClusterRoleBindingHandler
+}
+
+func (f *fakeRBAC) Role() v1.RoleController               { return fakeRoles{rbac: f} }
+func (f *fakeRBAC) RoleBinding() v1.RoleBindingController { return fakeRoleBindings{rbac: f} }
+func (f *fakeRBAC) ClusterRole() v1.ClusterRoleController { return fakeClusterRoles{rbac: f} }
+func (f *fakeRBAC) ClusterRoleBinding() v1.ClusterRoleBindingController {
+	return fakeClusterRoleBindings{rbac: f}
+}
+
+// the fake controllers and caches only implement what the access store uses
+type fakeRoles struct {
+	v1.RoleController
+	rbac *fakeRBAC
+}
+
+func (f fakeRoles) OnChange(_ context.Context, _ string, sync v1.RoleHandler) {
+	f.rbac.roles = append(f.rbac.roles, sync)
+}
+
+func (f fakeRoles) Cache() v1.RoleCache {
+	return fakeRoleCache{}
+}
+
+type fakeRoleCache struct {
+	v1.RoleCache
+}
+
+type fakeRoleBindings struct {
+	v1.RoleBindingController
+	rbac *fakeRBAC
+}
+
+func (f fakeRoleBindings) OnChange(_ context.Context, _ string, sync v1.RoleBindingHandler) {
+	f.rbac.roleBindings = append(f.rbac.roleBindings, sync)
+}
+
+func (f fakeRoleBindings) Cache() v1.RoleBindingCache {
+	return fakeRoleBindingCache{rbac: f.rbac}
+}
+
+type fakeRoleBindingCache struct {
+	v1.RoleBindingCache
+	rbac *fakeRBAC
+}
+
+func (f fakeRoleBindingCache) AddIndexer(string, v1.RoleBindingIndexer) {}
+
+func (f fakeRoleBindingCache) GetByIndex(string, string) ([]*rbacv1.RoleBinding, error) {
+	f.rbac.lookups++
+	return nil, nil
+}
+
+type fakeClusterRoles struct {
+	v1.ClusterRoleController
+	rbac *fakeRBAC
+}
+
+func (f fakeClusterRoles) OnChange(_ context.Context, _ string, sync v1.ClusterRoleHandler) {
+	f.rbac.clusterRoles = append(f.rbac.clusterRoles, sync)
+}
+
+func (f fakeClusterRoles) Cache() v1.ClusterRoleCache {
+	return fakeClusterRoleCache{}
+}
+
+type fakeClusterRoleCache struct {
+	v1.ClusterRoleCache
+}
+
+type fakeClusterRoleBindings struct {
+	v1.ClusterRoleBindingController
+	rbac *fakeRBAC
+}
+
+func (f fakeClusterRoleBindings) OnChange(_ context.Context, _ string, sync v1.ClusterRoleBindingHandler) {
+	f.rbac.clusterRoleBindings = append(f.rbac.clusterRoleBindings, sync)
+}
+
+func (f fakeClusterRoleBindings) Cache() v1.ClusterRoleBindingCache {
+	return fakeClusterRoleBindingCache{rbac: f.rbac}
+}
+
+type fakeClusterRoleBindingCache struct {
+	v1.ClusterRoleBindingCache
+	rbac *fakeRBAC
+}
+
+func (f fakeClusterRoleBindingCache) AddIndexer(string, v1.ClusterRoleBindingIndexer) {}
+
+func (f fakeClusterRoleBindingCache) GetByIndex(string, string) ([]*rbacv1.ClusterRoleBinding, error) {
+	f.rbac.lookups++
+	return nil, nil
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestAccessForCachesUsers(t *testing.T) {
+	rbac := &fakeRBAC{}
+	store := NewAccessStore(context.Background(), true, rbac)
+	jane := &user.DefaultInfo{Name: "jane", Groups: []string{"devs", "ops"}}
+
+	store.AccessFor(jane)
+	lookups := rbac.lookups
+	store.AccessFor(&user.DefaultInfo{Name: "jane", Groups: []string{"ops", "devs"}})
+	assert.Equal(t, lookups, rbac.lookups, "the order of the groups doesn't change the cache key")
+
+	store.AccessFor(&user.DefaultInfo{Name: "jane", Groups: []string{"devs"}})
+	assert.Greater(t, rbac.lookups, lookups, "users in other groups are cached apart")
+}
+
+func TestAccessForInvalidatesOnRBACChanges(t *testing.T) {
+	rbac := &fakeRBAC{}
+	store := NewAccessStore(context.Background(), true, rbac)
+	jane := &user.DefaultInfo{Name: "jane", Groups: []string{"devs"}}
+
+	// the handlers are called in the order they were registered, as by the controllers
+	changes := map[string]func(){
+		"role": func() {
+			for _, handler := range rbac.roles {
+				handler("default/view", &rbacv1.Role{})
+			}
+		},
+		"role binding": func() {
+			for _, handler := range rbac.roleBindings {
+				handler("default/view", &rbacv1.RoleBinding{})
+			}
+		},
+		"cluster role": func() {
+			for _, handler := range rbac.clusterRoles {
+				handler("view", &rbacv1.ClusterRole{})
+			}
+		},
+		"cluster role binding": func() {
+			for _, handler := range rbac.clusterRoleBindings {
+				handler("view", &rbacv1.ClusterRoleBinding{})
+			}
+		},
+		"deleted cluster role binding": func() {
+			for _, handler := range rbac.clusterRoleBindings {
+				handler("view", nil)
+			}
+		},
+	}
+	for name, change := range changes {
+		store.AccessFor(jane)
+		lookups := rbac.lookups
+		store.AccessFor(jane)
+		assert.Equal(t, lookups, rbac.lookups, "%s: the access set is cached", name)
+
+		change()
+		store.AccessFor(jane)
+		assert.Greater(t, rbac.lookups, lookups, "%s: the cached access set is dropped", name)
+	}
+}
+
+func TestAccessForExpiresUsers(t *testing.T) {
+	rbac := &fakeRBAC{}
+	store := NewAccessStore(context.Background(), true, rbac)
+	clock := &fakeClock{now: time.Now()}
+	store.userCache = cache.NewLRUExpireCacheWithClock(1000, clock)
+	jane := &user.DefaultInfo{Name: "jane"}
+
+	store.AccessFor(jane)
+	lookups := rbac.lookups
+	clock.now = clock.now.Add(store.userCacheTTL - time.Second)
+	store.AccessFor(jane)
+	assert.Equal(t, lookups, rbac.lookups, "the access set is cached for the ttl")
+
+	clock.now = clock.now.Add(2 * time.Second)
+	store.AccessFor(jane)
+	assert.Greater(t, rbac.lookups, lookups, "the access set expires after the ttl")
+}
+
+func TestAccessForWithoutUserCache(t *testing.T) {
+	t.Setenv(userCacheSecondsEnv, "0")
+	store := NewAccessStore(context.Background(), true, &fakeRBAC{})
+	assert.Nil(t, store.userCache, "a ttl of zero disables the cache")
+}
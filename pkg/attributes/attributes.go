@@ -184,3 +184,61 @@ func SetPreferredGroup(s *types.APISchema, ver string) {
 	}
 	s.Attributes["preferredGroup"] = ver
 }
+
+func SetSensitiveFields(s *types.APISchema, fields [][]string) {
+	setVal(s, "sensitiveFields", fields)
+}
+
+func SensitiveFields(s *types.APISchema) [][]string {
+	fields, _ := s.Attributes["sensitiveFields"].([][]string)
+	return fields
+}
+
+func SetSubresources(s *types.APISchema, subresources []string) {
+	setVal(s, "subresources", subresources)
+}
+
+func Subresources(s *types.APISchema) []string {
+	return convert.ToStringSlice(s.Attributes["subresources"])
+}
+
+// SetAPIService sets the aggregated APIService serving the resource of the schema, and whether it is available,
+// with why it is not.
+func SetAPIService(s *types.APISchema, name string, available bool, message string) {
+	setVal(s, "apiService", name)
+	setVal(s, "available", available)
+	if message != "" {
+		setVal(s, "availabilityMessage", message)
+	}
+}
+
+func APIService(s *types.APISchema) string {
+	return str(s, "apiService")
+}
+
+// Available returns false only for the schemas of aggregated APIServices that are unavailable.
+func Available(s *types.APISchema) bool {
+	available, ok := s.Attributes["available"].(bool)
+	return available || !ok
+}
+
+// SetTemplate sets the skeleton object returned by the template action of the schema, in place of the one generated
+// from its fields.
+func SetTemplate(s *types.APISchema, template map[string]interface{}) {
+	setVal(s, "template", template)
+}
+
+func Template(s *types.APISchema) map[string]interface{} {
+	template, _ := s.Attributes["template"].(map[string]interface{})
+	return template
+}
+
+// SetVersions sets every version served for the resource of the schema, newest first, of which the version of the
+// schema is the preferred one.
+func SetVersions(s *types.APISchema, versions []string) {
+	setVal(s, "versions", versions)
+}
+
+func Versions(s *types.APISchema) []string {
+	return convert.ToStringSlice(s.Attributes["versions"])
+}
@@ -1,11 +1,14 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/rancher/steve/pkg/auth"
 	"github.com/urfave/cli"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -14,6 +17,9 @@ type WebhookConfig struct {
 	WebhookKubeconfig     string
 	WebhookURL            string
 	CacheTTLSeconds       int
+	// TokenReviewAuthentication authenticates bearer tokens by asking the cluster served to review them, instead
+	// of a webhook.
+	TokenReviewAuthentication bool
 }
 
 func (w *WebhookConfig) MustWebhookMiddleware() auth.Middleware {
@@ -47,6 +53,23 @@ func (w *WebhookConfig) WebhookMiddleware() (auth.Middleware, error) {
 	return auth.NewWebhookMiddleware(time.Duration(w.CacheTTLSeconds)*time.Second, kubeConfig)
 }
 
+// TokenReviewMiddleware returns the middleware authenticating the bearer tokens of requests by token reviews made
+// with the config of the cluster served.
+func (w *WebhookConfig) TokenReviewMiddleware(cfg *rest.Config) (auth.Middleware, error) {
+	if !w.TokenReviewAuthentication {
+		return nil, nil
+	}
+	if w.WebhookAuthentication {
+		return nil, fmt.Errorf("webhook and token review authentication can not both be enabled")
+	}
+
+	k8s, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewTokenReviewMiddleware(time.Duration(w.CacheTTLSeconds)*time.Second, k8s.AuthenticationV1()), nil
+}
+
 func Flags(config *WebhookConfig) []cli.Flag {
 	return []cli.Flag{
 		cli.BoolFlag{
@@ -69,5 +92,11 @@ func Flags(config *WebhookConfig) []cli.Flag {
 			EnvVar:      "WEBHOOK_CACHE_TTL",
 			Destination: &config.CacheTTLSeconds,
 		},
+		cli.BoolFlag{
+			Name:        "token-review-auth",
+			EnvVar:      "TOKEN_REVIEW_AUTH",
+			Usage:       "Authenticate bearer tokens, such as service account tokens, with token reviews against the cluster served",
+			Destination: &config.TokenReviewAuthentication,
+		},
 	}
 }
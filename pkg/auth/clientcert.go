@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// ClientCertOptions configures the authentication of the client certificates of TLS connections.
+type ClientCertOptions struct {
+	// CAFile is the PEM encoded bundle of the CAs that sign client certificates.
+	CAFile string
+	// CRLFile is a PEM or DER encoded certificate revocation list of one of the CAs. Certificates it lists are
+	// rejected. It is read again whenever it changes.
+	CRLFile string
+}
+
+// ClientCertAuthenticator authenticates the users of client certificates signed by a CA bundle. Like kubernetes,
+// the common name of a certificate is the name of its user and its organizations are the groups of its user.
+type ClientCertAuthenticator struct {
+	roots   *x509.CertPool
+	cas     []*x509.Certificate
+	crlFile string
+
+	lock       sync.Mutex
+	crlModTime time.Time
+	revoked    map[string]bool
+}
+
+func NewClientCertAuthenticator(opts ClientCertOptions) (*ClientCertAuthenticator, error) {
+	data, err := os.ReadFile(opts.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+	c := &ClientCertAuthenticator{
+		roots:   x509.NewCertPool(),
+		crlFile: opts.CRLFile,
+	}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing client CA file: %w", err)
+		}
+		c.roots.AddCert(ca)
+		c.cas = append(c.cas, ca)
+	}
+	if len(c.cas) == 0 {
+		return nil, fmt.Errorf("no certificates in client CA file %s", opts.CAFile)
+	}
+	if _, err := c.revocations(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ConfigureTLS makes a TLS config ask for client certificates signed by the CA bundle. Clients may still connect
+// without one, and be authenticated some other way.
+func (c *ClientCertAuthenticator) ConfigureTLS(config *tls.Config) {
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	config.ClientCAs = c.roots
+}
+
+func (c *ClientCertAuthenticator) Authenticate(req *http.Request) (user.Info, bool, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, false, nil
+	}
+
+	leaf := req.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range req.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("verifying client certificate: %w", err)
+	}
+
+	revoked, err := c.revocations()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, cert := range chains[0] {
+		if revoked[revocationKey(cert.RawIssuer, cert.SerialNumber.String())] {
+			return nil, false, fmt.Errorf("client certificate %s of %s is revoked", cert.SerialNumber, cert.Subject.CommonName)
+		}
+	}
+
+	if leaf.Subject.CommonName == "" {
+		return nil, false, fmt.Errorf("client certificate has no common name")
+	}
+	info := &user.DefaultInfo{
+		Name:   leaf.Subject.CommonName,
+		Groups: append(append([]string{}, leaf.Subject.Organization...), user.AllAuthenticated),
+	}
+	return info, true, nil
+}
+
+// revocations returns the revoked certificates of the CRL file, reading it again if it changed.
+func (c *ClientCertAuthenticator) revocations() (map[string]bool, error) {
+	if c.crlFile == "" {
+		return nil, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stat, err := os.Stat(c.crlFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CRL file: %w", err)
+	}
+	if c.revoked != nil && stat.ModTime().Equal(c.crlModTime) {
+		return c.revoked, nil
+	}
+
+	data, err := os.ReadFile(c.crlFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CRL file: %w", err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing client CRL file: %w", err)
+	}
+	if err := c.checkCRL(crl); err != nil {
+		return nil, err
+	}
+
+	revoked := map[string]bool{}
+	for _, cert := range crl.RevokedCertificates {
+		revoked[revocationKey(crl.RawIssuer, cert.SerialNumber.String())] = true
+	}
+	c.revoked, c.crlModTime = revoked, stat.ModTime()
+	return revoked, nil
+}
+
+// checkCRL returns an error unless the CRL is signed by one of the CAs.
+func (c *ClientCertAuthenticator) checkCRL(crl *x509.RevocationList) error {
+	for _, ca := range c.cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("client CRL file %s is not signed by a client CA", c.crlFile)
+}
+
+func revocationKey(issuer []byte, serial string) string {
+	return string(issuer) + "/" + serial
+}
+
+// ClientCertMiddleware authenticates the requests of connections with a client certificate by the certificate, and
+// all other requests with the fallback. Without a fallback those requests are unauthenticated.
+func ClientCertMiddleware(certs Authenticator, fallback Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		byCert := ToMiddleware(certs)(next)
+		other := byCert
+		if fallback != nil {
+			other = fallback(next)
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+				byCert.ServeHTTP(rw, req)
+				return
+			}
+			other.ServeHTTP(rw, req)
+		})
+	}
+}
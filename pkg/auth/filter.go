@@ -3,6 +3,7 @@ package auth
 import (
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -199,9 +200,20 @@ func Impersonation(req *http.Request) (user.Info, bool, error) {
 
 	for k, v := range req.Header {
 		if strings.HasPrefix(k, transport.ImpersonateUserExtraHeaderPrefix) {
-			result.Extra[k[len(transport.ImpersonateUserExtraHeaderPrefix):]] = v
+			result.Extra[extraKey(k[len(transport.ImpersonateUserExtraHeaderPrefix):])] = v
 		}
 	}
 
 	return &result, true, nil
 }
+
+// extraKey returns the extra key of the suffix of an Impersonate-Extra- header the way the kubernetes apiserver
+// does: lowercased, as header names are case insensitive, then percent-decoded, so that keys such as
+// example.com/scopes can be sent as example.com%2fscopes.
+func extraKey(suffix string) string {
+	key := strings.ToLower(suffix)
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// GroupProvider supplies group memberships of users beyond the groups they authenticated with, such as the groups
+// synced from an external identity provider, which may change while the user stays logged in.
+type GroupProvider interface {
+	// GroupsFor returns the current groups of a user, which are added to the groups the user authenticated with.
+	GroupsFor(user user.Info) ([]string, error)
+}
+
+// GroupProviderMiddleware adds the groups of the provider to the authenticated user of each request, so the groups
+// are part of the user that access is computed for and that is impersonated in the requests made to kubernetes.
+// The provider is asked once per request. Unauthenticated requests are left as they are, and if the provider fails
+// the user keeps only the groups it authenticated with.
+func GroupProviderMiddleware(provider GroupProvider) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if info, ok := request.UserFrom(req.Context()); ok && !isUnauthenticated(info) {
+				if enriched, ok := withProvidedGroups(provider, info); ok {
+					req = req.WithContext(request.WithUser(req.Context(), enriched))
+				}
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+func isUnauthenticated(info user.Info) bool {
+	for _, group := range info.GetGroups() {
+		if group == user.AllUnauthenticated {
+			return true
+		}
+	}
+	return false
+}
+
+// withProvidedGroups returns the user with the groups of the provider added, and false if there are no groups to add.
+func withProvidedGroups(provider GroupProvider, u user.Info) (user.Info, bool) {
+	provided, err := provider.GroupsFor(u)
+	if err != nil {
+		logrus.Errorf("failed to get the groups of user %s: %v", u.GetName(), err)
+		return nil, false
+	}
+
+	groups := append([]string{}, u.GetGroups()...)
+	seen := map[string]bool{}
+	for _, group := range groups {
+		seen[group] = true
+	}
+	for _, group := range provided {
+		if !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == len(u.GetGroups()) {
+		return nil, false
+	}
+	return &user.DefaultInfo{
+		Name:   u.GetName(),
+		UID:    u.GetUID(),
+		Groups: groups,
+		Extra:  u.GetExtra(),
+	}, true
+}
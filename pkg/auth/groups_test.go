@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/rest"
+)
+
+type fakeGroupProvider struct {
+	groups map[string][]string
+	err    error
+	calls  int
+}
+
+func (f *fakeGroupProvider) GroupsFor(u user.Info) ([]string, error) {
+	f.calls++
+	return f.groups[u.GetName()], f.err
+}
+
+func TestGroupProviderMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     user.Info
+		provider *fakeGroupProvider
+		groups   []string
+	}{
+		{
+			name:     "provided groups are added",
+			user:     &user.DefaultInfo{Name: "jane", Groups: []string{"devs", user.AllAuthenticated}},
+			provider: &fakeGroupProvider{groups: map[string][]string{"jane": {"idp:admins", "devs"}}},
+			groups:   []string{"devs", user.AllAuthenticated, "idp:admins"},
+		},
+		{
+			name:     "no provided groups",
+			user:     &user.DefaultInfo{Name: "jane", Groups: []string{"devs"}},
+			provider: &fakeGroupProvider{},
+			groups:   []string{"devs"},
+		},
+		{
+			name:     "failing provider",
+			user:     &user.DefaultInfo{Name: "jane", Groups: []string{"devs"}},
+			provider: &fakeGroupProvider{groups: map[string][]string{"jane": {"idp:admins"}}, err: errors.New("unavailable")},
+			groups:   []string{"devs"},
+		},
+		{
+			name:     "unauthenticated users are left alone",
+			user:     &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}},
+			provider: &fakeGroupProvider{groups: map[string][]string{user.Anonymous: {"idp:admins"}}},
+			groups:   []string{user.AllUnauthenticated},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var got user.Info
+			handler := GroupProviderMiddleware(test.provider)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				got, _ = request.UserFrom(req.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(request.WithUser(req.Context(), test.user)))
+
+			require.NotNil(t, got)
+			assert.Equal(t, test.user.GetName(), got.GetName())
+			assert.Equal(t, test.groups, got.GetGroups())
+		})
+	}
+}
+
+func TestGroupProviderMiddlewareImpersonatesProvidedGroups(t *testing.T) {
+	var impersonated []string
+	kube := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		impersonated = req.Header.Values("Impersonate-Group")
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"default"}}`))
+	}))
+	defer kube.Close()
+	cf, err := client.NewFactory(&rest.Config{Host: kube.URL}, true)
+	require.NoError(t, err)
+
+	provider := &fakeGroupProvider{groups: map[string][]string{"jane": {"idp:admins"}}}
+	authenticate := ToMiddleware(AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		return &user.DefaultInfo{Name: "jane", Groups: []string{"devs"}}, true, nil
+	}))
+	handler := authenticate.Chain(GroupProviderMiddleware(provider))(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		k8s, err := cf.K8sInterface(&types.APIRequest{Request: req})
+		require.NoError(t, err)
+		_, err = k8s.CoreV1().Namespaces().Get(context.Background(), "default", metav1.GetOptions{})
+		require.NoError(t, err)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/namespaces/default", nil))
+
+	assert.Equal(t, []string{"devs", "idp:admins"}, impersonated, "the provided groups are impersonated in requests to kubernetes")
+	assert.Equal(t, 1, provider.calls, "the provider is asked once per request")
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ImpersonationMiddleware lets a user act as another user by setting the Impersonate-User, Impersonate-Group,
+// Impersonate-Uid and Impersonate-Extra-* headers, as with kubectl --as. The headers are checked by the impersonation
+// filter of the kubernetes apiserver, so the authenticated user must be allowed the impersonate verb on the users,
+// serviceaccounts, groups, uids and userextras being impersonated, exactly as with kubernetes. The rest of the request
+// sees only the impersonated user, so schemas, access checks and the requests made to kubernetes on its behalf are all
+// those of the impersonated user.
+func ImpersonationMiddleware(asl accesscontrol.AccessSetLookup) Middleware {
+	return func(next http.Handler) http.Handler {
+		impersonate := filters.WithImpersonation(next, &impersonationAuthorizer{asl: asl}, scheme.Codecs.WithoutConversion())
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get(authenticationv1.ImpersonateUserHeader) == "" && impersonatesWithoutUser(req.Header) {
+				// the kubernetes filter answers these with 500, though the request is at fault
+				http.Error(rw, "impersonating groups, uids or extras requires impersonating a user", http.StatusBadRequest)
+				return
+			}
+			impersonate.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// impersonatesWithoutUser returns true if the headers impersonate groups, a uid or extras.
+func impersonatesWithoutUser(header http.Header) bool {
+	if len(header[authenticationv1.ImpersonateGroupHeader]) > 0 || header.Get(authenticationv1.ImpersonateUIDHeader) != "" {
+		return true
+	}
+	for k := range header {
+		if strings.HasPrefix(k, authenticationv1.ImpersonateUserExtraHeaderPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// impersonationAuthorizer authorizes the impersonate verb from the access set of the impersonator, so that the
+// impersonation filter of kubernetes can be used without a SubjectAccessReview per header.
+type impersonationAuthorizer struct {
+	asl accesscontrol.AccessSetLookup
+}
+
+func (i *impersonationAuthorizer) Authorize(ctx context.Context, attrs authorizer.Attributes) (authorizer.Decision, string, error) {
+	resource := attrs.GetResource()
+	if attrs.GetSubresource() != "" {
+		resource += "/" + attrs.GetSubresource()
+	}
+	gr := schema.GroupResource{Group: attrs.GetAPIGroup(), Resource: resource}
+	if i.asl.AccessFor(attrs.GetUser()).Grants(attrs.GetVerb(), gr, attrs.GetNamespace(), attrs.GetName()) {
+		return authorizer.DecisionAllow, "", nil
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+}
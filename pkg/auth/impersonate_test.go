@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type fakeAccessSetLookup struct {
+	access *accesscontrol.AccessSet
+}
+
+func (f *fakeAccessSetLookup) AccessFor(user.Info) *accesscontrol.AccessSet {
+	return f.access
+}
+
+func (f *fakeAccessSetLookup) PurgeUserData(string) {}
+
+func TestImpersonationMiddleware(t *testing.T) {
+	access := &accesscontrol.AccessSet{}
+	access.Add("impersonate", schema.GroupResource{Resource: "users"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "jane"})
+	access.Add("impersonate", schema.GroupResource{Resource: "groups"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "devs"})
+	access.Add("impersonate", schema.GroupResource{Group: "authentication.k8s.io", Resource: "userextras/scopes"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "view"})
+	access.Add("impersonate", schema.GroupResource{Group: "authentication.k8s.io", Resource: "userextras/example.com/team"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "web"})
+	access.Add("impersonate", schema.GroupResource{Group: "authentication.k8s.io", Resource: "uids"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "1234"})
+	access.Add("impersonate", schema.GroupResource{Resource: "serviceaccounts"}, accesscontrol.Access{Namespace: "dev", ResourceName: "deployer"})
+	// granting the username of a service account as a user does not allow impersonating the service account
+	access.Add("impersonate", schema.GroupResource{Resource: "users"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "system:serviceaccount:prod:deployer"})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		user    string
+		uid     string
+		groups  []string
+		extra   map[string][]string
+	}{
+		{
+			name:    "user",
+			headers: map[string]string{"Impersonate-User": "jane"},
+			status:  http.StatusOK,
+			user:    "jane",
+			groups:  []string{user.AllAuthenticated},
+			extra:   map[string][]string{},
+		},
+		{
+			name:    "group",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Group": "devs"},
+			status:  http.StatusOK,
+			user:    "jane",
+			groups:  []string{"devs", user.AllAuthenticated},
+			extra:   map[string][]string{},
+		},
+		{
+			name:    "uid",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Uid": "1234"},
+			status:  http.StatusOK,
+			user:    "jane",
+			uid:     "1234",
+			groups:  []string{user.AllAuthenticated},
+			extra:   map[string][]string{},
+		},
+		{
+			name:    "service account",
+			headers: map[string]string{"Impersonate-User": "system:serviceaccount:dev:deployer"},
+			status:  http.StatusOK,
+			user:    "system:serviceaccount:dev:deployer",
+			groups:  []string{"system:serviceaccounts", "system:serviceaccounts:dev", user.AllAuthenticated},
+			extra:   map[string][]string{},
+		},
+		{
+			name:    "extras are lowercased",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Extra-Scopes": "view"},
+			status:  http.StatusOK,
+			user:    "jane",
+			groups:  []string{user.AllAuthenticated},
+			extra:   map[string][]string{"scopes": {"view"}},
+		},
+		{
+			name:    "extras are unescaped",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Extra-Example.com%2FTeam": "web"},
+			status:  http.StatusOK,
+			user:    "jane",
+			groups:  []string{user.AllAuthenticated},
+			extra:   map[string][]string{"example.com/team": {"web"}},
+		},
+		{
+			name:    "denied user",
+			headers: map[string]string{"Impersonate-User": "admin"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "denied group",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Group": "system:masters"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "denied extra value",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Extra-Scopes": "admin"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "denied extra key",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Extra-Example.com%2FOther": "web"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "denied uid",
+			headers: map[string]string{"Impersonate-User": "jane", "Impersonate-Uid": "5678"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "denied service account in another namespace",
+			headers: map[string]string{"Impersonate-User": "system:serviceaccount:other:deployer"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "service accounts are checked as serviceaccounts, not users",
+			headers: map[string]string{"Impersonate-User": "system:serviceaccount:prod:deployer"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "group without user",
+			headers: map[string]string{"Impersonate-Group": "devs"},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "extra without user",
+			headers: map[string]string{"Impersonate-Extra-Scopes": "view"},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "uid without user",
+			headers: map[string]string{"Impersonate-Uid": "1234"},
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var got user.Info
+			handler := ImpersonationMiddleware(&fakeAccessSetLookup{access: access})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				got, _ = request.UserFrom(req.Context())
+				for k := range req.Header {
+					assert.NotContains(t, k, "Impersonate-", "impersonation headers are removed")
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "impersonator"}))
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.status, rw.Code)
+			if test.status != http.StatusOK {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, test.user, got.GetName())
+			assert.Equal(t, test.uid, got.GetUID())
+			assert.Equal(t, test.groups, got.GetGroups())
+			assert.Equal(t, test.extra, got.GetExtra())
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// issuedTokenPrefix tells the tokens of a TokenIssuer apart from the tokens of other authenticators.
+const issuedTokenPrefix = "steve-"
+
+type issuedTokenKey struct{}
+
+// TokenIssuer issues signed bearer tokens for users, which it authenticates as those users until they expire. The
+// tokens carry the name and groups of their user, so nothing is stored, and can't be revoked before they expire.
+type TokenIssuer struct {
+	key []byte
+	now func() time.Time
+}
+
+type issuedClaims struct {
+	Name    string   `json:"name"`
+	UID     string   `json:"uid,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Expires int64    `json:"exp"`
+}
+
+// NewTokenIssuer returns an issuer signing tokens with the key in keyFile, which must be at least 32 bytes. Without
+// a key file the key is random, so the tokens are only valid until steve restarts.
+func NewTokenIssuer(keyFile string) (*TokenIssuer, error) {
+	var key []byte
+	if keyFile == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token signing key file: %w", err)
+		}
+		key = []byte(strings.TrimSpace(string(data)))
+		if len(key) < 32 {
+			return nil, fmt.Errorf("token signing key file %s must hold at least 32 bytes", keyFile)
+		}
+	}
+	return &TokenIssuer{
+		key: key,
+		now: time.Now,
+	}, nil
+}
+
+// Issue returns a token of the user valid for the ttl, and when it expires.
+func (t *TokenIssuer) Issue(u user.Info, ttl time.Duration) (string, time.Time, error) {
+	expires := t.now().Add(ttl).Truncate(time.Second)
+	payload, err := json.Marshal(issuedClaims{
+		Name:    u.GetName(),
+		UID:     u.GetUID(),
+		Groups:  u.GetGroups(),
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return issuedTokenPrefix + encoded + "." + t.sign(encoded), expires, nil
+}
+
+func (t *TokenIssuer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// AuthenticateToken authenticates the tokens of the issuer. Other tokens are left unauthenticated without an
+// error.
+func (t *TokenIssuer) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	if !strings.HasPrefix(token, issuedTokenPrefix) {
+		return nil, false, nil
+	}
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, issuedTokenPrefix), ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(encoded))) {
+		return nil, false, errors.New("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid token: %w", err)
+	}
+	var claims issuedClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false, fmt.Errorf("invalid token: %w", err)
+	}
+	if t.now().Unix() >= claims.Expires {
+		return nil, false, errors.New("token is expired")
+	}
+	return &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   claims.Name,
+			UID:    claims.UID,
+			Groups: claims.Groups,
+		},
+	}, true, nil
+}
+
+// IssuedTokenMiddleware authenticates the requests bearing a token of the issuer by the token, and all other
+// requests with the fallback. Without a fallback those requests are unauthenticated.
+func IssuedTokenMiddleware(issuer *TokenIssuer, fallback Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		byToken := ToMiddleware(&bearerAuth{auth: issuer})(next)
+		other := byToken
+		if fallback != nil {
+			other = fallback(next)
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if strings.HasPrefix(req.Header.Get("Authorization"), "Bearer "+issuedTokenPrefix) {
+				byToken.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), issuedTokenKey{}, true)))
+				return
+			}
+			other.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// FromIssuedToken returns whether the request of the context was authenticated by a token of a TokenIssuer.
+func FromIssuedToken(ctx context.Context) bool {
+	issued, _ := ctx.Value(issuedTokenKey{}).(bool)
+	return issued
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestTokenIssuer(t *testing.T) {
+	issuer, err := NewTokenIssuer("")
+	require.NoError(t, err)
+	now := time.Now()
+	issuer.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	token, expires, err := issuer.Issue(&user.DefaultInfo{Name: "jane", UID: "1234", Groups: []string{"devs", user.AllAuthenticated}}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour).Truncate(time.Second), expires)
+
+	resp, ok, err := issuer.AuthenticateToken(ctx, token)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "jane", resp.User.GetName())
+	assert.Equal(t, "1234", resp.User.GetUID())
+	assert.Equal(t, []string{"devs", user.AllAuthenticated}, resp.User.GetGroups())
+
+	_, ok, err = issuer.AuthenticateToken(ctx, token[:len(token)-2]+"xx")
+	assert.False(t, ok)
+	assert.Error(t, err, "tokens with another signature are rejected")
+
+	other, err := NewTokenIssuer("")
+	require.NoError(t, err)
+	_, ok, err = other.AuthenticateToken(ctx, token)
+	assert.False(t, ok)
+	assert.Error(t, err, "tokens of another key are rejected")
+
+	issuer.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, ok, err = issuer.AuthenticateToken(ctx, token)
+	assert.False(t, ok)
+	assert.Error(t, err, "expired tokens are rejected")
+
+	_, ok, err = issuer.AuthenticateToken(ctx, "some-other-token")
+	assert.False(t, ok)
+	assert.NoError(t, err, "other tokens are left to other authenticators")
+}
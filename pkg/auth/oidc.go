@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/plugin/pkg/authenticator/token/oidc"
+)
+
+const (
+	// oidcCacheSize is the number of verified tokens cached.
+	oidcCacheSize = 4096
+	// oidcNoPrefix disables the default prefix of usernames or groups.
+	oidcNoPrefix = "-"
+	// systemPrefix is the prefix of the users and groups reserved for kubernetes.
+	systemPrefix = "system:"
+)
+
+// OIDCOptions configures the authentication of OpenID Connect ID tokens.
+type OIDCOptions struct {
+	// IssuerURL is the https URL of the issuer, whose discovery document gives the keys signing the tokens.
+	IssuerURL string
+	// ClientID is the audience the tokens must be issued for.
+	ClientID string
+	// CAFile is the PEM encoded CA bundle of the issuer. The system roots are used if empty.
+	CAFile string
+	// UsernameClaim is the claim of the username. Defaults to sub.
+	UsernameClaim string
+	// UsernamePrefix is prepended to usernames, to keep them from clashing with other users. As with the kubernetes
+	// apiserver, it defaults to the issuer URL followed by # unless the username claim is email, and - disables it.
+	UsernamePrefix string
+	// GroupsClaim is the claim of the groups, a string or a list of strings. No groups are read if empty.
+	GroupsClaim string
+	// GroupsPrefix is prepended to groups. It defaults to the issuer URL followed by #, and - disables it.
+	GroupsPrefix string
+	// CacheTTL is how long the users of verified tokens are cached, at most until the tokens expire. Nothing is
+	// cached if zero.
+	CacheTTL time.Duration
+}
+
+// NewOIDCAuthenticator returns an authenticator of the bearer tokens of requests that accepts the ID tokens of an
+// OpenID Connect issuer, verified by the OIDC authenticator of the kubernetes apiserver. Users and groups that
+// start with system: once prefixed are refused, so the issuer can't assert the identities of kubernetes.
+func NewOIDCAuthenticator(opts OIDCOptions) (Authenticator, error) {
+	if opts.ClientID == "" {
+		return nil, fmt.Errorf("oidc client id is required")
+	}
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = "sub"
+	}
+	opts.UsernamePrefix = oidcPrefix(opts.UsernamePrefix, opts.IssuerURL, opts.UsernameClaim != "email")
+	opts.GroupsPrefix = oidcPrefix(opts.GroupsPrefix, opts.IssuerURL, true)
+
+	options := oidc.Options{
+		IssuerURL:            opts.IssuerURL,
+		ClientID:             opts.ClientID,
+		UsernameClaim:        opts.UsernameClaim,
+		UsernamePrefix:       opts.UsernamePrefix,
+		GroupsClaim:          opts.GroupsClaim,
+		GroupsPrefix:         opts.GroupsPrefix,
+		SupportedSigningAlgs: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+	}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading oidc CA file: %w", err)
+		}
+		options.CAContentProvider = caBundle(pem)
+	}
+	verifier, err := oidc.New(options)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &oidcAuth{
+		opts:     opts,
+		verifier: verifier,
+	}
+	if opts.CacheTTL > 0 {
+		token.cache = utilcache.NewLRUExpireCache(oidcCacheSize)
+	}
+	return &bearerAuth{auth: token}, nil
+}
+
+// NewOIDCMiddleware returns the middleware of NewOIDCAuthenticator.
+func NewOIDCMiddleware(opts OIDCOptions) (Middleware, error) {
+	auth, err := NewOIDCAuthenticator(opts)
+	if err != nil {
+		return nil, err
+	}
+	return ToMiddleware(auth), nil
+}
+
+// oidcPrefix returns the prefix of usernames or groups: the issuer URL followed by # if unset and byDefault, and
+// none if set to -.
+func oidcPrefix(prefix, issuerURL string, byDefault bool) string {
+	switch {
+	case prefix == oidcNoPrefix:
+		return ""
+	case prefix == "" && byDefault:
+		return issuerURL + "#"
+	}
+	return prefix
+}
+
+type caBundle []byte
+
+func (c caBundle) CurrentCABundleContent() []byte {
+	return c
+}
+
+type oidcAuth struct {
+	opts     OIDCOptions
+	verifier authenticator.Token
+
+	// cache holds the responses of verified tokens by the hash of the token, until the token expires or for
+	// CacheTTL, whichever comes first. It is nil if CacheTTL is zero.
+	cache *utilcache.LRUExpireCache
+	now   func() time.Time
+}
+
+func (o *oidcAuth) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	if strings.Count(token, ".") != 2 {
+		// not a JWT, so not issued by the issuer
+		return nil, false, nil
+	}
+	cacheKey := sha256.Sum256([]byte(token))
+	if o.cache != nil {
+		if resp, ok := o.cache.Get(cacheKey); ok {
+			return resp.(*authenticator.Response), true, nil
+		}
+	}
+
+	resp, ok, err := o.verifier.AuthenticateToken(ctx, token)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if err := refuseSystemNames(resp.User); err != nil {
+		return nil, false, err
+	}
+	resp = &authenticator.Response{User: &user.DefaultInfo{
+		Name:   resp.User.GetName(),
+		UID:    resp.User.GetUID(),
+		Groups: append(append([]string{}, resp.User.GetGroups()...), user.AllAuthenticated),
+		Extra:  resp.User.GetExtra(),
+	}}
+
+	if o.cache != nil {
+		ttl := o.opts.CacheTTL
+		// the token is verified, so its expiry can be trusted
+		if expiry, ok := tokenExpiry(token); ok {
+			if untilExpiry := expiry.Sub(o.clock()); untilExpiry < ttl {
+				ttl = untilExpiry
+			}
+		}
+		if ttl > 0 {
+			o.cache.Add(cacheKey, resp, ttl)
+		}
+	}
+	return resp, true, nil
+}
+
+func (o *oidcAuth) clock() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+// refuseSystemNames returns an error if the user or one of its groups is reserved for kubernetes, such as
+// system:masters.
+func refuseSystemNames(info user.Info) error {
+	if strings.HasPrefix(info.GetName(), systemPrefix) {
+		return fmt.Errorf("oidc: username %q is reserved", info.GetName())
+	}
+	for _, group := range info.GetGroups() {
+		if strings.HasPrefix(group, systemPrefix) {
+			return fmt.Errorf("oidc: group %q is reserved", group)
+		}
+	}
+	return nil
+}
+
+// tokenExpiry returns the exp claim of a JWT.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp *int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(*claims.Exp, 0), true
+}
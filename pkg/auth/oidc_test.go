@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// newIssuer serves the discovery document and the key of an OpenID Connect issuer, and returns a function signing
+// tokens with the key.
+func newIssuer(t *testing.T) (*httptest.Server, func(kid string, claims map[string]interface{}) string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var issuer string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(rw).Encode(map[string]interface{}{
+				"issuer":                                issuer,
+				"jwks_uri":                              issuer + "/keys",
+				"id_token_signing_alg_values_supported": []string{"RS256"},
+			})
+		case "/keys":
+			json.NewEncoder(rw).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "one",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+	issuer = server.URL
+
+	return server, func(kid string, claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+}
+
+// newOIDCAuth returns the authenticator of the issuer, once it has discovered the keys of the issuer.
+func newOIDCAuth(t *testing.T, server *httptest.Server, opts OIDCOptions, valid string) authenticator.Token {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	opts.IssuerURL, opts.ClientID, opts.CAFile = server.URL, "steve", caFile
+
+	a, err := NewOIDCAuthenticator(opts)
+	require.NoError(t, err)
+	token := a.(*bearerAuth).auth
+	require.Eventually(t, func() bool {
+		_, ok, _ := token.AuthenticateToken(context.Background(), valid)
+		return ok
+	}, 10*time.Second, 10*time.Millisecond, "the keys of the issuer are discovered")
+	return token
+}
+
+func TestOIDCAuthenticateToken(t *testing.T) {
+	server, sign := newIssuer(t)
+	claims := func(aud string, exp time.Time, groups ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            server.URL,
+			"aud":            aud,
+			"sub":            "1234",
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"groups":         groups,
+			"exp":            exp.Unix(),
+		}
+	}
+	valid := sign("one", claims("steve", time.Now().Add(time.Hour), "admins", "devs"))
+	ctx := context.Background()
+
+	oidc := newOIDCAuth(t, server, OIDCOptions{GroupsClaim: "groups"}, valid)
+	resp, ok, err := oidc.AuthenticateToken(ctx, valid)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, server.URL+"#1234", resp.User.GetName(), "usernames are prefixed with the issuer by default")
+	assert.Equal(t, []string{server.URL + "#admins", server.URL + "#devs", user.AllAuthenticated}, resp.User.GetGroups(),
+		"groups are prefixed with the issuer by default")
+
+	_, ok, err = oidc.AuthenticateToken(ctx, sign("one", claims("other", time.Now().Add(time.Hour))))
+	assert.False(t, ok)
+	assert.Error(t, err, "tokens for other clients are rejected")
+
+	_, ok, err = oidc.AuthenticateToken(ctx, sign("one", claims("steve", time.Now().Add(-time.Hour))))
+	assert.False(t, ok)
+	assert.Error(t, err, "expired tokens are rejected")
+
+	_, ok, err = oidc.AuthenticateToken(ctx, sign("two", claims("steve", time.Now().Add(time.Hour))))
+	assert.False(t, ok)
+	assert.Error(t, err, "tokens signed by unknown keys are rejected")
+
+	_, ok, err = oidc.AuthenticateToken(ctx, "not-a-jwt")
+	assert.False(t, ok)
+	assert.NoError(t, err, "other tokens are left unauthenticated")
+
+	email := newOIDCAuth(t, server, OIDCOptions{UsernameClaim: "email", GroupsClaim: "groups", GroupsPrefix: "oidc:"}, valid)
+	resp, ok, err = email.AuthenticateToken(ctx, valid)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "jane@example.com", resp.User.GetName(), "emails aren't prefixed by default")
+	assert.Equal(t, []string{"oidc:admins", "oidc:devs", user.AllAuthenticated}, resp.User.GetGroups())
+}
+
+func TestOIDCRefusesSystemNames(t *testing.T) {
+	server, sign := newIssuer(t)
+	token := func(sub string, groups ...string) string {
+		return sign("one", map[string]interface{}{
+			"iss":    server.URL,
+			"aud":    "steve",
+			"sub":    sub,
+			"groups": groups,
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		})
+	}
+	oidc := newOIDCAuth(t, server, OIDCOptions{UsernamePrefix: "-", GroupsClaim: "groups", GroupsPrefix: "-"}, token("jane", "devs"))
+
+	for name, token := range map[string]string{
+		"system group": token("jane", "devs", "system:masters"),
+		"system user":  token("system:admin"),
+	} {
+		_, ok, err := oidc.AuthenticateToken(context.Background(), token)
+		assert.False(t, ok, name)
+		assert.Error(t, err, name)
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestOIDCCacheExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	verified := 0
+	oidc := &oidcAuth{
+		opts: OIDCOptions{CacheTTL: time.Hour},
+		verifier: authenticator.TokenFunc(func(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+			verified++
+			return &authenticator.Response{User: &user.DefaultInfo{Name: "jane"}}, true, nil
+		}),
+		cache: utilcache.NewLRUExpireCacheWithClock(10, clock),
+		now:   clock.Now,
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"sub": "1234", "exp": clock.now.Add(30 * time.Second).Unix()})
+	token := "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+
+	for i := 0; i < 2; i++ {
+		_, ok, err := oidc.AuthenticateToken(context.Background(), token)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	assert.Equal(t, 1, verified, "verified tokens are cached")
+
+	clock.now = clock.now.Add(40 * time.Second)
+	_, ok := oidc.cache.Get(sha256.Sum256([]byte(token)))
+	assert.False(t, ok, "tokens are not cached past their expiry, though the cache ttl is longer")
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/token/cache"
+	"k8s.io/apiserver/pkg/authentication/user"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+)
+
+// NewTokenReviewAuthenticator returns an authenticator of the bearer tokens of requests, such as the tokens of
+// service accounts, that asks the kubernetes API of the client to review them. The reviews are cached for cacheTTL.
+func NewTokenReviewAuthenticator(cacheTTL time.Duration, client authenticationv1client.TokenReviewsGetter) Authenticator {
+	var token authenticator.Token = &tokenReview{client: client.TokenReviews()}
+	if cacheTTL > 0 {
+		token = cache.New(token, false, cacheTTL, cacheTTL)
+	}
+	return &bearerAuth{auth: token}
+}
+
+// NewTokenReviewMiddleware returns the middleware of NewTokenReviewAuthenticator.
+func NewTokenReviewMiddleware(cacheTTL time.Duration, client authenticationv1client.TokenReviewsGetter) Middleware {
+	return ToMiddleware(NewTokenReviewAuthenticator(cacheTTL, client))
+}
+
+// bearerAuth authenticates the bearer tokens of requests.
+type bearerAuth struct {
+	auth authenticator.Token
+}
+
+func (t *bearerAuth) Authenticate(req *http.Request) (user.Info, bool, error) {
+	token := req.Header.Get("Authorization")
+	if !strings.HasPrefix(token, "Bearer ") {
+		return nil, false, nil
+	}
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		return nil, false, nil
+	}
+
+	resp, ok, err := t.auth.AuthenticateToken(req.Context(), token)
+	if resp == nil {
+		return nil, ok, err
+	}
+	return resp.User, ok, err
+}
+
+type tokenReview struct {
+	client authenticationv1client.TokenReviewInterface
+}
+
+func (t *tokenReview) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	result, err := t.client.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	if !result.Status.Authenticated {
+		if result.Status.Error != "" {
+			return nil, false, errors.New(result.Status.Error)
+		}
+		return nil, false, nil
+	}
+
+	var extra map[string][]string
+	if result.Status.User.Extra != nil {
+		extra = map[string][]string{}
+		for k, v := range result.Status.User.Extra {
+			extra[k] = v
+		}
+	}
+	return &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   result.Status.User.Username,
+			UID:    result.Status.User.UID,
+			Groups: result.Status.User.Groups,
+			Extra:  extra,
+		},
+	}, true, nil
+}
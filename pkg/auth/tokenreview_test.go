@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTokenReviewMiddleware(t *testing.T) {
+	k8s := fake.NewSimpleClientset()
+	k8s.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "valid" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{
+				Username: "system:serviceaccount:default:reader",
+				Groups:   []string{"system:serviceaccounts", "system:authenticated"},
+			}
+		}
+		return true, review, nil
+	})
+
+	var name string
+	handler := NewTokenReviewMiddleware(0, k8s.AuthenticationV1())(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		info, ok := request.UserFrom(req.Context())
+		require.True(t, ok)
+		name = info.GetName()
+	}))
+
+	for token, want := range map[string]string{
+		"Bearer valid":   "system:serviceaccount:default:reader",
+		"Bearer invalid": "system:unauthenticated",
+		"":               "system:unauthenticated",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, want, name, token)
+	}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	breakerFailures = 5
+	breakerCooldown = 30 * time.Second
+)
+
+// circuitBreaker stops sending requests to an API group of the apiserver after repeated failures to reach it, such
+// as refused connections and timeouts, so steve fails fast while the control plane is unhealthy. While the
+// circuit is open requests fail with a 503 ServiceUnavailable status; after a cooldown a single request is let
+// through to probe for recovery, which closes the circuit if it succeeds.
+// Circuits are per API group, so an unavailable aggregated API does not affect the rest of the cluster.
+type circuitBreaker struct {
+	lock     sync.Mutex
+	failures int
+	cooldown time.Duration
+	circuits map[string]*circuit
+	now      func() time.Time
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		failures: breakerFailures,
+		cooldown: breakerCooldown,
+		circuits: map[string]*circuit{},
+		now:      time.Now,
+	}
+}
+
+// wrap returns a round tripper which sends requests to next through the breaker.
+func (b *circuitBreaker) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		group := apiGroup(req.URL.Path)
+		if !b.allow(group) {
+			return unavailable(req, group), nil
+		}
+		resp, err := next.RoundTrip(req)
+		if err != nil && req.Context().Err() != nil {
+			// cancelled by the caller, which says nothing about the apiserver
+			b.release(group)
+			return resp, err
+		}
+		b.record(group, failed(err))
+		return resp, err
+	})
+}
+
+func (b *circuitBreaker) allow(group string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.circuits[group]
+	if !ok || c.failures < b.failures {
+		return true
+	}
+	if b.now().Before(c.openUntil) || c.probing {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// release lets another request probe the circuit of the group, if it is open.
+func (b *circuitBreaker) release(group string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if c, ok := b.circuits[group]; ok {
+		c.probing = false
+	}
+}
+
+func (b *circuitBreaker) record(group string, failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.circuits[group]
+	if !failed {
+		if ok && c.failures >= b.failures {
+			logrus.Infof("apiserver %s recovered, closing circuit", group)
+		}
+		delete(b.circuits, group)
+		return
+	}
+
+	if !ok {
+		c = &circuit{}
+		b.circuits[group] = c
+	}
+	c.failures++
+	c.probing = false
+	if c.failures >= b.failures {
+		if c.failures == b.failures {
+			logrus.Warnf("apiserver %s failed %d times in a row, opening circuit", group, c.failures)
+		}
+		c.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// failed returns whether a request failed to reach the apiserver, by a transport error or a timeout. Error
+// responses are left out: the circuit is shared by every user, and the requests of a single user, such as those
+// rejected by a broken webhook of its namespace, may get 500s and 504s from an apiserver serving everyone else.
+func failed(err error) bool {
+	return err != nil
+}
+
+// apiGroup returns the API group of a request path: /api for the core group or /apis/<group>.
+func apiGroup(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(parts) >= 2 && parts[0] == "apis" {
+		return "/apis/" + parts[1]
+	}
+	return "/" + parts[0]
+}
+
+// unavailable returns the response of a request short-circuited by an open circuit, which clients decode into a
+// ServiceUnavailable status error. It sets no Retry-After, which would make the client retry on its own.
+func unavailable(req *http.Request, group string) *http.Response {
+	status := metav1.Status{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Status",
+			APIVersion: "v1",
+		},
+		Status:  metav1.StatusFailure,
+		Message: fmt.Sprintf("apiserver %s is unavailable after repeated failures", group),
+		Reason:  metav1.StatusReasonServiceUnavailable,
+		Code:    http.StatusServiceUnavailable,
+	}
+	body, _ := json.Marshal(status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)),
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
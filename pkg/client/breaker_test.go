@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker()
+	breaker.now = func() time.Time { return now }
+
+	var (
+		calls   int
+		healthy bool
+	)
+	rt := breaker.wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if !healthy {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	do := func(path string) (int, error) {
+		resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			return 0, err
+		}
+		return resp.StatusCode, nil
+	}
+
+	for i := 0; i < breakerFailures; i++ {
+		_, err := do("/api/v1/pods")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, breakerFailures, calls)
+
+	// open: short-circuited without calling the apiserver
+	code, err := do("/api/v1/namespaces")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, breakerFailures, calls)
+
+	// other groups are unaffected
+	_, err = do("/apis/apps/v1/deployments")
+	assert.Error(t, err)
+	assert.Equal(t, breakerFailures+1, calls)
+
+	// after the cooldown a probe is let through and closes the circuit
+	now = now.Add(breakerCooldown)
+	healthy = true
+	code, err = do("/api/v1/pods")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	code, err = do("/api/v1/pods")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, breakerFailures+3, calls)
+}
+
+func TestCircuitBreakerIgnoresErrorResponses(t *testing.T) {
+	breaker := newCircuitBreaker()
+	rt := breaker.wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// the objects of one user are rejected by a broken webhook, while those of other users are served
+		if req.Header.Get("Impersonate-User") == "jane" {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	do := func(user string) int {
+		req := httptest.NewRequest(http.MethodPost, "/apis/apps/v1/namespaces/jane/deployments", nil)
+		req.Header.Set("Impersonate-User", user)
+		resp, err := rt.RoundTrip(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	for i := 0; i < 2*breakerFailures; i++ {
+		assert.Equal(t, http.StatusInternalServerError, do("jane"))
+	}
+	assert.Equal(t, http.StatusOK, do("john"), "the errors of one user don't open the circuit of the others")
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	clientCacheSizeEnv     = "CATTLE_CLIENT_CACHE_SIZE"
+	defaultClientCacheSize = 1000
+	clientCacheTTL         = 30 * time.Minute
+)
+
+// clientCache holds the clients made for each user, so requests reuse their transports and connections rather than
+// setting up new ones. The least recently used clients are evicted once the cache is full.
+type clientCache struct {
+	lock  sync.Mutex
+	size  int
+	cache *cache.LRUExpireCache
+}
+
+func newClientCache() *clientCache {
+	size := defaultClientCacheSize
+	if sizeSetting := os.Getenv(clientCacheSizeEnv); sizeSetting != "" {
+		n, err := strconv.Atoi(sizeSetting)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", clientCacheSizeEnv, err)
+		} else {
+			size = n
+		}
+	}
+	c := &clientCache{
+		size: size,
+	}
+	if size > 0 {
+		c.cache = cache.NewLRUExpireCache(size)
+	}
+	return c
+}
+
+// get returns the cached client for the key, creating it if there is none.
+func (c *clientCache) get(key string, create func() (interface{}, error)) (interface{}, error) {
+	if c.cache == nil {
+		return create()
+	}
+	if client, ok := c.cache.Get(key); ok {
+		metrics.IncClientCacheRequests(true)
+		return client, nil
+	}
+	metrics.IncClientCacheRequests(false)
+
+	client, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.cache.Get(key); !ok && len(c.cache.Keys()) >= c.size {
+		metrics.IncClientCacheEvictions()
+	}
+	c.cache.Add(key, client, clientCacheTTL)
+	metrics.SetClientCacheSize(len(c.cache.Keys()))
+	return client, nil
+}
+
+// clientKey identifies the client of a kind, made from a config, for a user. A nil user is the admin.
+func clientKey(kind string, cfg *rest.Config, user user.Info) string {
+	key := fmt.Sprintf("%s/%p", kind, cfg)
+	if user == nil {
+		return key
+	}
+
+	buf := &strings.Builder{}
+	buf.WriteString(key)
+	buf.WriteString("/")
+	buf.WriteString(user.GetName())
+	buf.WriteString("/")
+	buf.WriteString(strings.Join(user.GetGroups(), ","))
+
+	extra := user.GetExtra()
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString("/")
+		buf.WriteString(k)
+		buf.WriteString("=")
+		buf.WriteString(strings.Join(extra[k], ","))
+	}
+	return buf.String()
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/rest"
+)
+
+func TestClientCache(t *testing.T) {
+	t.Setenv(clientCacheSizeEnv, "2")
+	c := newClientCache()
+	created := 0
+	get := func(key string) {
+		_, err := c.get(key, func() (interface{}, error) {
+			created++
+			return key, nil
+		})
+		require.NoError(t, err)
+	}
+
+	get("jane")
+	get("jane")
+	assert.Equal(t, 1, created, "clients are reused")
+
+	get("joe")
+	get("jane")
+	get("ann")
+	assert.Equal(t, 3, created)
+	get("jane")
+	assert.Equal(t, 3, created, "the recently used client is kept")
+	get("joe")
+	assert.Equal(t, 4, created, "the least recently used client is evicted")
+
+	_, err := c.get("bob", func() (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	assert.Error(t, err)
+	get("bob")
+	assert.Equal(t, 5, created, "failures aren't cached")
+}
+
+func TestClientCacheSize(t *testing.T) {
+	t.Setenv(clientCacheSizeEnv, "none")
+	assert.Equal(t, defaultClientCacheSize, newClientCache().size, "an invalid size falls back to the default")
+
+	t.Setenv(clientCacheSizeEnv, "0")
+	c := newClientCache()
+	created := 0
+	for i := 0; i < 2; i++ {
+		_, err := c.get("jane", func() (interface{}, error) {
+			created++
+			return "jane", nil
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, created, "a size of zero disables the cache")
+}
+
+func TestClientKey(t *testing.T) {
+	cfg, other := &rest.Config{}, &rest.Config{}
+	jane := &user.DefaultInfo{Name: "jane", Groups: []string{"devs"}, Extra: map[string][]string{"a": {"1"}, "b": {"2"}}}
+	key := clientKey("dynamic", cfg, jane)
+
+	assert.Equal(t, key, clientKey("dynamic", cfg, &user.DefaultInfo{Name: "jane", Groups: []string{"devs"},
+		Extra: map[string][]string{"b": {"2"}, "a": {"1"}}}), "the order of extra doesn't change the key")
+	for name, different := range map[string]string{
+		"kind":   clientKey("table", cfg, jane),
+		"config": clientKey("dynamic", other, jane),
+		"user":   clientKey("dynamic", cfg, &user.DefaultInfo{Name: "joe", Groups: jane.Groups, Extra: jane.Extra}),
+		"groups": clientKey("dynamic", cfg, &user.DefaultInfo{Name: "jane", Groups: []string{"ops"}, Extra: jane.Extra}),
+		"extra":  clientKey("dynamic", cfg, &user.DefaultInfo{Name: "jane", Groups: jane.Groups}),
+		"admin":  clientKey("dynamic", cfg, nil),
+	} {
+		assert.NotEqual(t, key, different, "clients of another %s are cached apart", name)
+	}
+}
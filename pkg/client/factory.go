@@ -1,12 +1,17 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/attributes"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -22,9 +27,24 @@ type Factory struct {
 	watchClientCfg      *rest.Config
 	metadata            metadata.Interface
 	dynamic             dynamic.Interface
+	clients             *clientCache
 	Config              *rest.Config
 }
 
+type includeObjectKey struct{}
+
+// WithIncludeObject returns a context which makes the table clients ask the apiserver to include the objects of
+// the rows with the given policy, such as metav1.IncludeMetadata, instead of the full objects.
+func WithIncludeObject(ctx context.Context, policy metav1.IncludeObjectPolicy) context.Context {
+	return context.WithValue(ctx, includeObjectKey{}, policy)
+}
+
+// AcceptsTable returns whether the client of a request accepts a kubernetes Table, so only needs the cells of the
+// rows and the metadata of the objects.
+func AcceptsTable(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "as=Table")
+}
+
 type addQuery struct {
 	values map[string]string
 	next   http.RoundTripper
@@ -35,6 +55,9 @@ func (a *addQuery) RoundTrip(req *http.Request) (*http.Response, error) {
 	for k, v := range a.values {
 		q.Set(k, v)
 	}
+	if policy, ok := req.Context().Value(includeObjectKey{}).(metav1.IncludeObjectPolicy); ok && q.Get("includeObject") != "" {
+		q.Set("includeObject", string(policy))
+	}
 	req.Header.Set("Accept", "application/json;as=Table;v=v1;g=meta.k8s.io,application/json;as=Table;v=v1beta1;g=meta.k8s.io")
 	req.URL.RawQuery = q.Encode()
 	return a.next.RoundTrip(req)
@@ -42,6 +65,17 @@ func (a *addQuery) RoundTrip(req *http.Request) (*http.Response, error) {
 
 func NewFactory(cfg *rest.Config, impersonate bool) (*Factory, error) {
 	clientCfg := rest.CopyConfig(cfg)
+	// propagate the trace context of the request into the kubernetes api calls made on its behalf
+	clientCfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt)
+	})
+	// fail fast while the apiserver is unhealthy
+	breaker := newCircuitBreaker()
+	clientCfg.Wrap(breaker.wrap)
+	// pass the warnings of the apiserver, such as deprecation notices, on to the request they were made for
+	clientCfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &warningRecorder{next: rt}
+	})
 	clientCfg.QPS = 10000
 	clientCfg.Burst = 100
 
@@ -82,6 +116,7 @@ func NewFactory(cfg *rest.Config, impersonate bool) (*Factory, error) {
 		tableWatchClientCfg: tableWatchClientCfg,
 		clientCfg:           clientCfg,
 		watchClientCfg:      watchClientCfg,
+		clients:             newClientCache(),
 		Config:              watchClientCfg,
 	}, nil
 }
@@ -99,91 +134,128 @@ func (p *Factory) IsImpersonating() bool {
 }
 
 func (p *Factory) K8sInterface(ctx *types.APIRequest) (kubernetes.Interface, error) {
-	cfg, err := setupConfig(ctx, p.clientCfg, p.impersonate)
+	user, err := impersonatedUser(ctx, p.impersonate)
 	if err != nil {
 		return nil, err
 	}
 
-	return kubernetes.NewForConfig(cfg)
+	client, err := p.clients.get(clientKey("kubernetes", p.clientCfg, user), func() (interface{}, error) {
+		return kubernetes.NewForConfig(withUser(p.clientCfg, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.(kubernetes.Interface), nil
 }
 
 func (p *Factory) AdminK8sInterface() (kubernetes.Interface, error) {
 	return kubernetes.NewForConfig(p.clientCfg)
 }
 
+// RESTConfig returns the config for requests made on behalf of the user of the request, for the requests that are
+// proxied to kubernetes rather than made through a client.
+func (p *Factory) RESTConfig(ctx *types.APIRequest) (*rest.Config, error) {
+	return setupConfig(ctx, p.clientCfg, p.impersonate)
+}
+
 func (p *Factory) DynamicClient(ctx *types.APIRequest) (dynamic.Interface, error) {
-	return newDynamicClient(ctx, p.clientCfg, p.impersonate)
+	return p.dynamicClient(ctx, p.clientCfg, p.impersonate)
 }
 
 func (p *Factory) Client(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
-	return newClient(ctx, p.clientCfg, s, namespace, p.impersonate)
+	return p.newClient(ctx, p.clientCfg, s, namespace, p.impersonate)
 }
 
 func (p *Factory) AdminClient(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
-	return newClient(ctx, p.clientCfg, s, namespace, false)
+	return p.newClient(ctx, p.clientCfg, s, namespace, false)
 }
 
 func (p *Factory) ClientForWatch(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
-	return newClient(ctx, p.watchClientCfg, s, namespace, p.impersonate)
+	return p.newClient(ctx, p.watchClientCfg, s, namespace, p.impersonate)
 }
 
 func (p *Factory) AdminClientForWatch(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
-	return newClient(ctx, p.watchClientCfg, s, namespace, false)
+	return p.newClient(ctx, p.watchClientCfg, s, namespace, false)
 }
 
 func (p *Factory) TableClient(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
 	if attributes.Table(s) {
-		return newClient(ctx, p.tableClientCfg, s, namespace, p.impersonate)
+		return p.newClient(ctx, p.tableClientCfg, s, namespace, p.impersonate)
 	}
 	return p.Client(ctx, s, namespace)
 }
 
 func (p *Factory) TableAdminClient(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
 	if attributes.Table(s) {
-		return newClient(ctx, p.tableClientCfg, s, namespace, false)
+		return p.newClient(ctx, p.tableClientCfg, s, namespace, false)
 	}
 	return p.AdminClient(ctx, s, namespace)
 }
 
 func (p *Factory) TableClientForWatch(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
 	if attributes.Table(s) {
-		return newClient(ctx, p.tableWatchClientCfg, s, namespace, p.impersonate)
+		return p.newClient(ctx, p.tableWatchClientCfg, s, namespace, p.impersonate)
 	}
 	return p.ClientForWatch(ctx, s, namespace)
 }
 
 func (p *Factory) TableAdminClientForWatch(ctx *types.APIRequest, s *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
 	if attributes.Table(s) {
-		return newClient(ctx, p.tableWatchClientCfg, s, namespace, false)
+		return p.newClient(ctx, p.tableWatchClientCfg, s, namespace, false)
 	}
 	return p.AdminClientForWatch(ctx, s, namespace)
 }
 
 func setupConfig(ctx *types.APIRequest, cfg *rest.Config, impersonate bool) (*rest.Config, error) {
-	if impersonate {
-		user, ok := request.UserFrom(ctx.Context())
-		if !ok {
-			return nil, fmt.Errorf("user not found for impersonation")
-		}
-		cfg = rest.CopyConfig(cfg)
-		cfg.Impersonate.UserName = user.GetName()
-		cfg.Impersonate.Groups = user.GetGroups()
-		cfg.Impersonate.Extra = user.GetExtra()
+	user, err := impersonatedUser(ctx, impersonate)
+	if err != nil {
+		return nil, err
+	}
+	return withUser(cfg, user), nil
+}
+
+// impersonatedUser returns the user of the request if requests are made on its behalf, or nil if they are made as
+// the admin.
+func impersonatedUser(ctx *types.APIRequest, impersonate bool) (user.Info, error) {
+	if !impersonate {
+		return nil, nil
+	}
+	user, ok := request.UserFrom(ctx.Context())
+	if !ok {
+		return nil, fmt.Errorf("user not found for impersonation")
 	}
-	return cfg, nil
+	return user, nil
 }
 
-func newDynamicClient(ctx *types.APIRequest, cfg *rest.Config, impersonate bool) (dynamic.Interface, error) {
-	cfg, err := setupConfig(ctx, cfg, impersonate)
+func withUser(cfg *rest.Config, user user.Info) *rest.Config {
+	if user == nil {
+		return cfg
+	}
+	cfg = rest.CopyConfig(cfg)
+	cfg.Impersonate.UserName = user.GetName()
+	cfg.Impersonate.Groups = user.GetGroups()
+	cfg.Impersonate.Extra = user.GetExtra()
+	return cfg
+}
+
+// dynamicClient returns the cached client for the config and the user of the request.
+func (p *Factory) dynamicClient(ctx *types.APIRequest, cfg *rest.Config, impersonate bool) (dynamic.Interface, error) {
+	user, err := impersonatedUser(ctx, impersonate)
 	if err != nil {
 		return nil, err
 	}
 
-	return dynamic.NewForConfig(cfg)
+	client, err := p.clients.get(clientKey("dynamic", cfg, user), func() (interface{}, error) {
+		return dynamic.NewForConfig(withUser(cfg, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.(dynamic.Interface), nil
 }
 
-func newClient(ctx *types.APIRequest, cfg *rest.Config, s *types.APISchema, namespace string, impersonate bool) (dynamic.ResourceInterface, error) {
-	client, err := newDynamicClient(ctx, cfg, impersonate)
+func (p *Factory) newClient(ctx *types.APIRequest, cfg *rest.Config, s *types.APISchema, namespace string, impersonate bool) (dynamic.ResourceInterface, error) {
+	client, err := p.dynamicClient(ctx, cfg, impersonate)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddQueryIncludeObject(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "default",
+			ctx:  context.Background(),
+			want: "Object",
+		},
+		{
+			name: "metadata",
+			ctx:  WithIncludeObject(context.Background(), metav1.IncludeMetadata),
+			want: "Metadata",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var got *http.Request
+			rt := &addQuery{
+				values: map[string]string{"includeObject": "Object"},
+				next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					got = req
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				}),
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil).WithContext(test.ctx)
+			_, err := rt.RoundTrip(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got.URL.Query().Get("includeObject"))
+		})
+	}
+}
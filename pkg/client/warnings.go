@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+type warningHandlerKey struct{}
+
+// WithWarningHandler returns a context whose kubernetes api calls pass the text of the Warning headers of their
+// responses, such as the deprecation notices of the apiserver, to the handler.
+func WithWarningHandler(ctx context.Context, handler func(text string)) context.Context {
+	return context.WithValue(ctx, warningHandlerKey{}, handler)
+}
+
+type warningRecorder struct {
+	next http.RoundTripper
+}
+
+func (w *warningRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := w.next.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	handler, ok := req.Context().Value(warningHandlerKey{}).(func(string))
+	if !ok {
+		return resp, err
+	}
+	warnings, _ := utilnet.ParseWarningHeaders(resp.Header.Values("Warning"))
+	for _, warning := range warnings {
+		handler(warning.Text)
+	}
+	return resp, err
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarningRecorder(t *testing.T) {
+	rt := &warningRecorder{next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Add("Warning", `299 - "policy/v1beta1 PodSecurityPolicy is deprecated in v1.21+"`)
+		header.Add("Warning", `299 - "spec.foo: unknown field"`)
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody}, nil
+	})}
+
+	var warnings []string
+	ctx := WithWarningHandler(context.Background(), func(text string) {
+		warnings = append(warnings, text)
+	})
+	_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/apis/policy/v1beta1/podsecuritypolicies", nil).WithContext(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"policy/v1beta1 PodSecurityPolicy is deprecated in v1.21+", "spec.foo: unknown field"}, warnings)
+
+	// requests without a handler are passed through
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,221 @@
+package clustercache
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	memoryBudgetEnv  = "CATTLE_CLUSTER_CACHE_MEMORY_MB"
+	maxObjectsEnv    = "CATTLE_CLUSTER_CACHE_MAX_OBJECTS_PER_TYPE"
+	enforceInterval  = 10 * time.Second
+	fallbackTimeout  = time.Minute
+	fallbackPageSize = 500
+	// objectOverhead is the estimated memory of a summarized object beyond its strings, such as the summary, the
+	// store and the indexes.
+	objectOverhead = 512
+
+	reasonMaxObjects   = "max_objects"
+	reasonMemoryBudget = "memory_budget"
+)
+
+// budget is the memory the cluster cache may use, which it keeps to by evicting types, whose objects are then
+// looked up from kubernetes instead. Zero values mean no limit.
+type budget struct {
+	// bytes is the estimated memory of the objects of every type.
+	bytes int64
+	// maxObjects is the number of objects of each type.
+	maxObjects int64
+}
+
+// budgetFromEnv returns the budget set by the CATTLE_CLUSTER_CACHE_MEMORY_MB and
+// CATTLE_CLUSTER_CACHE_MAX_OBJECTS_PER_TYPE environment variables.
+func budgetFromEnv() budget {
+	return budget{
+		bytes:      int64FromEnv(memoryBudgetEnv) * 1024 * 1024,
+		maxObjects: int64FromEnv(maxObjectsEnv),
+	}
+}
+
+func int64FromEnv(env string) int64 {
+	setting := os.Getenv(env)
+	if setting == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(setting)
+	if err != nil {
+		logrus.Debugf("could not parse %s environment variable, error: %v", env, err)
+		return 0
+	}
+	return int64(value)
+}
+
+// usage is the count and estimated memory of the cached objects of a type, and when the type was last looked up.
+type usage struct {
+	objects    int64
+	bytes      int64
+	lastAccess int64
+	evicted    int32
+}
+
+func (u *usage) access() {
+	atomic.StoreInt64(&u.lastAccess, time.Now().UnixNano())
+}
+
+func (u *usage) isEvicted() bool {
+	return atomic.LoadInt32(&u.evicted) == 1
+}
+
+// evicted is a type whose objects are no longer cached.
+type evicted struct {
+	gvr    schema2.GroupVersionResource
+	reason string
+}
+
+// evictions are the types evicted from the cache, which aren't watched again while they have a schema.
+type evictions struct {
+	sync.RWMutex
+	types map[schema2.GroupVersionKind]evicted
+}
+
+func (e *evictions) get(gvk schema2.GroupVersionKind) (evicted, bool) {
+	e.RLock()
+	defer e.RUnlock()
+	ev, ok := e.types[gvk]
+	return ev, ok
+}
+
+func (e *evictions) add(gvk schema2.GroupVersionKind, ev evicted) {
+	e.Lock()
+	defer e.Unlock()
+	e.types[gvk] = ev
+}
+
+// retain forgets the evictions of the types without a schema, so they are watched again if they come back.
+func (e *evictions) retain(gvks map[schema2.GroupVersionKind]bool) {
+	e.Lock()
+	defer e.Unlock()
+	for gvk := range e.types {
+		if !gvks[gvk] {
+			delete(e.types, gvk)
+		}
+	}
+}
+
+// addUsageHandler counts the objects of the informer of a watcher against the budget, evicting the type if it has
+// more objects than a type may have.
+func (h *clusterCache) addUsageHandler(w *watcher) {
+	add := func(obj interface{}) {
+		objects := atomic.AddInt64(&w.usage.objects, 1)
+		atomic.AddInt64(&w.usage.bytes, estimateSize(obj))
+		if h.budget.maxObjects > 0 && objects > h.budget.maxObjects {
+			h.evict(w, reasonMaxObjects)
+		}
+	}
+	remove := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		atomic.AddInt64(&w.usage.objects, -1)
+		atomic.AddInt64(&w.usage.bytes, -estimateSize(obj))
+	}
+	w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: add,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			atomic.AddInt64(&w.usage.bytes, estimateSize(newObj)-estimateSize(oldObj))
+		},
+		DeleteFunc: remove,
+	})
+}
+
+// evict stops the watch of a type and drops its objects. Lookups of the type go to kubernetes until its schema is
+// removed.
+func (h *clusterCache) evict(w *watcher, reason string) {
+	if !atomic.CompareAndSwapInt32(&w.usage.evicted, 0, 1) {
+		return
+	}
+	logrus.Warnf("Evicting %s with %d objects from the cluster cache: %s", w.gvk, atomic.LoadInt64(&w.usage.objects), reason)
+	h.evictions.add(w.gvk, evicted{gvr: w.gvr, reason: reason})
+	metrics.IncClusterCacheEvictions(w.gvk.String(), reason)
+	w.cancel()
+}
+
+// enforce keeps the cache to its budget until the context is done, evicting the least recently used types while the
+// estimated memory of every type is over the budget, and reports the size of the cache. Types started lazily that
+// have gone unused for the idle timeout are stopped first.
+func (h *clusterCache) enforce() {
+	t := time.NewTicker(enforceInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		h.Lock()
+		h.stopIdle()
+		var (
+			watchers []*watcher
+			total    int64
+		)
+		for gvk, w := range h.watchers {
+			if w.usage.isEvicted() {
+				delete(h.watchers, gvk)
+				metrics.DeleteClusterCacheSize(gvk.String())
+				continue
+			}
+			watchers = append(watchers, w)
+			bytes := atomic.LoadInt64(&w.usage.bytes)
+			total += bytes
+			metrics.SetClusterCacheSize(gvk.String(), atomic.LoadInt64(&w.usage.objects), bytes)
+		}
+		h.Unlock()
+
+		if h.budget.bytes <= 0 || total <= h.budget.bytes {
+			continue
+		}
+		sort.Slice(watchers, func(i, j int) bool {
+			return atomic.LoadInt64(&watchers[i].usage.lastAccess) < atomic.LoadInt64(&watchers[j].usage.lastAccess)
+		})
+		for _, w := range watchers {
+			if total <= h.budget.bytes {
+				break
+			}
+			total -= atomic.LoadInt64(&w.usage.bytes)
+			h.evict(w, reasonMemoryBudget)
+		}
+	}
+}
+
+// estimateSize returns the estimated memory of a cached object, from the strings of its metadata.
+func estimateSize(obj interface{}) int64 {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return objectOverhead
+	}
+	size := objectOverhead + len(m.GetName()) + len(m.GetNamespace()) + len(m.GetResourceVersion()) + len(m.GetUID())
+	for k, v := range m.GetLabels() {
+		size += len(k) + len(v)
+	}
+	for k, v := range m.GetAnnotations() {
+		size += len(k) + len(v)
+	}
+	for _, owner := range m.GetOwnerReferences() {
+		size += len(owner.APIVersion) + len(owner.Kind) + len(owner.Name) + len(owner.UID)
+	}
+	for _, finalizer := range m.GetFinalizers() {
+		size += len(finalizer)
+	}
+	return int64(size)
+}
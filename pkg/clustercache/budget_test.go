@@ -0,0 +1,70 @@
+package clustercache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestEvictedFallback(t *testing.T) {
+	gvk := schema2.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	gvr := schema2.GroupVersionResource{Version: "v1", Resource: "pods"}
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "web-1", "namespace": "default"},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema2.GroupVersionResource]string{gvr: "PodList"}, pod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	w := &watcher{ctx: watchCtx, cancel: watchCancel, gvk: gvk, gvr: gvr}
+	h := &clusterCache{
+		ctx:           ctx,
+		summaryClient: newSummaryClient(client),
+		watchers:      map[schema2.GroupVersionKind]*watcher{gvk: w},
+		evictions:     evictions{types: map[schema2.GroupVersionKind]evicted{}},
+	}
+
+	h.evict(w, reasonMaxObjects)
+	assert.Error(t, watchCtx.Err())
+
+	obj, ok, err := h.Get(gvk, "default", "web-1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "web-1", obj.(*summary.SummarizedObject).Name)
+
+	_, ok, err = h.Get(gvk, "default", "web-2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.Len(t, h.List(gvk), 1)
+
+	h.evictions.retain(map[schema2.GroupVersionKind]bool{})
+	_, ok = h.evictions.get(gvk)
+	assert.False(t, ok)
+}
+
+func TestEstimateSize(t *testing.T) {
+	small := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "a"},
+	}}
+	large := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "a",
+			"annotations": map[string]interface{}{"note": "0123456789"},
+		},
+	}}
+	assert.Equal(t, int64(objectOverhead+1), estimateSize(small))
+	assert.Equal(t, int64(objectOverhead+15), estimateSize(large))
+	assert.Equal(t, int64(objectOverhead), estimateSize("not an object"))
+}
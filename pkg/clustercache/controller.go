@@ -7,30 +7,37 @@ import (
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/wrangler/pkg/merr"
-	"github.com/rancher/wrangler/pkg/summary/client"
 	"github.com/rancher/wrangler/pkg/summary/informer"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
+// ownerIndex indexes objects by the UIDs of their owners.
+const ownerIndex = "ownerUID"
+
 type Handler func(gvr schema2.GroupVersionKind, key string, obj runtime.Object) error
 type ChangeHandler func(gvr schema2.GroupVersionKind, key string, obj, oldObj runtime.Object) error
 
 type ClusterCache interface {
 	Get(gvk schema2.GroupVersionKind, namespace, name string) (interface{}, bool, error)
 	List(gvk schema2.GroupVersionKind) []interface{}
+	Dependents(uid k8stypes.UID) []interface{}
 	OnAdd(ctx context.Context, handler Handler)
 	OnRemove(ctx context.Context, handler Handler)
 	OnChange(ctx context.Context, handler ChangeHandler)
 	OnSchemas(schemas *schema.Collection) error
+	// Use records that a type is in use, starting its watch if the cache starts types lazily.
+	Use(gvk schema2.GroupVersionKind)
 }
 
 type event struct {
@@ -46,15 +53,21 @@ type watcher struct {
 	informer cache.SharedIndexInformer
 	gvk      schema2.GroupVersionKind
 	gvr      schema2.GroupVersionResource
+	usage    usage
 }
 
 type clusterCache struct {
 	sync.RWMutex
 
 	ctx           context.Context
-	summaryClient client.Interface
+	summaryClient *summaryClient
 	watchers      map[schema2.GroupVersionKind]*watcher
 	workqueue     workqueue.DelayingInterface
+	budget        budget
+	evictions     evictions
+	lazy          lazyStart
+	// known are the resources of the types with a schema that can be watched.
+	known map[schema2.GroupVersionKind]schema2.GroupVersionResource
 
 	addHandlers    cancelCollection
 	removeHandlers cancelCollection
@@ -64,11 +77,16 @@ type clusterCache struct {
 func NewClusterCache(ctx context.Context, dynamicClient dynamic.Interface) ClusterCache {
 	c := &clusterCache{
 		ctx:           ctx,
-		summaryClient: client.NewForDynamicClient(dynamicClient),
+		summaryClient: newSummaryClient(dynamicClient),
 		watchers:      map[schema2.GroupVersionKind]*watcher{},
 		workqueue:     workqueue.NewNamedDelayingQueue("cluster-cache"),
+		budget:        budgetFromEnv(),
+		evictions:     evictions{types: map[schema2.GroupVersionKind]evicted{}},
+		lazy:          lazyStartFromEnv(),
+		known:         map[schema2.GroupVersionKind]schema2.GroupVersionResource{},
 	}
 	go c.start()
+	go c.enforce()
 	return c
 }
 
@@ -130,6 +148,7 @@ func (h *clusterCache) OnSchemas(schemas *schema.Collection) error {
 
 	var (
 		gvks   = map[schema2.GroupVersionKind]bool{}
+		known  = map[schema2.GroupVersionKind]schema2.GroupVersionResource{}
 		toWait []*watcher
 	)
 
@@ -142,41 +161,34 @@ func (h *clusterCache) OnSchemas(schemas *schema.Collection) error {
 		gvr := attributes.GVR(schema)
 		gvk := attributes.GVK(schema)
 		gvks[gvk] = true
+		known[gvk] = gvr
 
-		if h.watchers[gvk] != nil {
+		if h.lazy.enabled || h.watchers[gvk] != nil {
 			continue
 		}
-
-		summaryInformer := informer.NewFilteredSummaryInformer(h.summaryClient, gvr, metav1.NamespaceAll, 2*time.Hour,
-			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
-		ctx, cancel := context.WithCancel(h.ctx)
-		w := &watcher{
-			ctx:      ctx,
-			cancel:   cancel,
-			gvk:      gvk,
-			gvr:      gvr,
-			informer: summaryInformer.Informer(),
+		if _, ok := h.evictions.get(gvk); ok {
+			continue
 		}
-		h.watchers[gvk] = w
-		toWait = append(toWait, w)
-
-		logrus.Infof("Watching metadata for %s", w.gvk)
-		h.addResourceEventHandler(w.gvk, w.informer)
-		go w.informer.Run(w.ctx.Done())
+		toWait = append(toWait, h.startWatcher(gvk, gvr))
 	}
+	h.known = known
 
 	for gvk, w := range h.watchers {
 		if !gvks[gvk] {
 			logrus.Infof("Stopping metadata watch on %s", gvk)
 			w.cancel()
 			delete(h.watchers, gvk)
+			metrics.DeleteClusterCacheSize(gvk.String())
 		}
 	}
+	h.evictions.retain(gvks)
 
 	for _, w := range toWait {
-		ctx, cancel := context.WithTimeout(w.ctx, 15*time.Minute)
+		ctx, cancel := context.WithTimeout(w.ctx, syncTimeout)
 		if !cache.WaitForCacheSync(ctx.Done(), w.informer.HasSynced) {
-			logrus.Errorf("failed to sync cache for %v", w.gvk)
+			if !w.usage.isEvicted() {
+				logrus.Errorf("failed to sync cache for %v", w.gvk)
+			}
 			cancel()
 			w.cancel()
 			delete(h.watchers, w.gvk)
@@ -187,12 +199,35 @@ func (h *clusterCache) OnSchemas(schemas *schema.Collection) error {
 	return nil
 }
 
-func (h *clusterCache) Get(gvk schema2.GroupVersionKind, namespace, name string) (interface{}, bool, error) {
-	h.RLock()
-	defer h.RUnlock()
+// startWatcher starts the watch of a type. The caller must hold the lock.
+func (h *clusterCache) startWatcher(gvk schema2.GroupVersionKind, gvr schema2.GroupVersionResource) *watcher {
+	summaryInformer := informer.NewFilteredSummaryInformer(h.summaryClient, gvr, metav1.NamespaceAll, 2*time.Hour,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc, ownerIndex: ownerIndexFunc}, nil)
+	ctx, cancel := context.WithCancel(h.ctx)
+	w := &watcher{
+		ctx:      ctx,
+		cancel:   cancel,
+		gvk:      gvk,
+		gvr:      gvr,
+		informer: summaryInformer.Informer(),
+	}
+	w.usage.access()
+	h.watchers[gvk] = w
+
+	logrus.Infof("Watching metadata for %s", w.gvk)
+	h.addResourceEventHandler(w.gvk, w.informer)
+	h.addUsageHandler(w)
+	go w.informer.Run(w.ctx.Done())
+	return w
+}
 
-	w, ok := h.watchers[gvk]
-	if !ok {
+// Get returns the summary of an object, which is looked up from kubernetes if its type isn't cached.
+func (h *clusterCache) Get(gvk schema2.GroupVersionKind, namespace, name string) (interface{}, bool, error) {
+	w := h.watcher(gvk)
+	if w == nil || !w.informer.HasSynced() {
+		if gvr, ok := h.uncached(gvk); ok {
+			return h.getUncached(gvr, namespace, name)
+		}
 		return nil, false, nil
 	}
 
@@ -206,16 +241,63 @@ func (h *clusterCache) Get(gvk schema2.GroupVersionKind, namespace, name string)
 	return w.informer.GetStore().GetByKey(key)
 }
 
+// List returns the summaries of the objects of a type, which are listed from kubernetes if the type isn't cached.
 func (h *clusterCache) List(gvk schema2.GroupVersionKind) []interface{} {
+	w := h.watcher(gvk)
+	if w == nil || !w.informer.HasSynced() {
+		if gvr, ok := h.uncached(gvk); ok {
+			return h.listUncached(gvr)
+		}
+		return nil
+	}
+
+	return w.informer.GetStore().List()
+}
+
+// watcher returns the watcher of a type that hasn't been evicted, recording the access for the eviction of the
+// least recently used types.
+func (h *clusterCache) watcher(gvk schema2.GroupVersionKind) *watcher {
 	h.RLock()
 	defer h.RUnlock()
 
 	w, ok := h.watchers[gvk]
-	if !ok {
+	if !ok || w.usage.isEvicted() {
 		return nil
 	}
+	w.usage.access()
+	return w
+}
 
-	return w.informer.GetStore().List()
+// Dependents returns the objects of every watched type that the object with the UID owns. The objects of evicted
+// types are left out.
+func (h *clusterCache) Dependents(uid k8stypes.UID) []interface{} {
+	h.RLock()
+	defer h.RUnlock()
+
+	var result []interface{}
+	for _, w := range h.watchers {
+		if w.usage.isEvicted() {
+			continue
+		}
+		objs, err := w.informer.GetIndexer().ByIndex(ownerIndex, string(uid))
+		if err != nil {
+			continue
+		}
+		result = append(result, objs...)
+	}
+	return result
+}
+
+func ownerIndexFunc(obj interface{}) ([]string, error) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, nil
+	}
+	var result []string
+	for _, owner := range m.GetOwnerReferences() {
+		result = append(result, string(owner.UID))
+	}
+	return result, nil
 }
 
 func (h *clusterCache) start() {
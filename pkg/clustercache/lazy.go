@@ -0,0 +1,165 @@
+package clustercache
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	lazyStartEnv       = "CATTLE_CLUSTER_CACHE_LAZY_START"
+	idleTimeoutEnv     = "CATTLE_CLUSTER_CACHE_IDLE_SECONDS"
+	defaultIdleTimeout = 30 * time.Minute
+	syncTimeout        = 15 * time.Minute
+)
+
+// lazyStart is whether the cache watches a type only once it is used, rather than every type with a schema, and
+// how long a type may go unused before its watch is stopped. Types that aren't watched are looked up from
+// kubernetes, and their objects are left out of the dependents and of the change events of the cache.
+type lazyStart struct {
+	enabled bool
+	// idle is how long a type may go unused before its watch is stopped, with zero never stopping it.
+	idle time.Duration
+}
+
+// lazyStartFromEnv returns the lazy start set by the CATTLE_CLUSTER_CACHE_LAZY_START and
+// CATTLE_CLUSTER_CACHE_IDLE_SECONDS environment variables.
+func lazyStartFromEnv() lazyStart {
+	enabled, err := strconv.ParseBool(os.Getenv(lazyStartEnv))
+	if err != nil {
+		if os.Getenv(lazyStartEnv) != "" {
+			logrus.Debugf("could not parse %s environment variable, error: %v", lazyStartEnv, err)
+		}
+		return lazyStart{}
+	}
+	l := lazyStart{enabled: enabled, idle: defaultIdleTimeout}
+	if setting := os.Getenv(idleTimeoutEnv); setting != "" {
+		seconds, err := strconv.Atoi(setting)
+		if err != nil || seconds < 0 {
+			logrus.Debugf("could not parse %s environment variable, error: %v", idleTimeoutEnv, err)
+			return l
+		}
+		l.idle = time.Duration(seconds) * time.Second
+	}
+	return l
+}
+
+// Use starts the watch of a type that isn't watched yet when the cache starts types lazily, and records that the
+// type is in use so its watch isn't stopped as idle. The watch syncs in the background.
+func (h *clusterCache) Use(gvk schema2.GroupVersionKind) {
+	if !h.lazy.enabled || h.watcher(gvk) != nil {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	gvr, ok := h.known[gvk]
+	if !ok || h.watchers[gvk] != nil {
+		return
+	}
+	if _, ok := h.evictions.get(gvk); ok {
+		return
+	}
+	w := h.startWatcher(gvk, gvr)
+	go h.waitForSync(w)
+}
+
+// waitForSync waits for the informer of a watcher to sync, stopping the watch if it doesn't.
+func (h *clusterCache) waitForSync(w *watcher) {
+	ctx, cancel := context.WithTimeout(w.ctx, syncTimeout)
+	defer cancel()
+	if cache.WaitForCacheSync(ctx.Done(), w.informer.HasSynced) {
+		return
+	}
+	if !w.usage.isEvicted() {
+		logrus.Errorf("failed to sync cache for %v", w.gvk)
+	}
+	w.cancel()
+
+	h.Lock()
+	defer h.Unlock()
+	if h.watchers[w.gvk] == w {
+		delete(h.watchers, w.gvk)
+	}
+}
+
+// stopIdle stops the watch of the types that haven't been used for the idle timeout. Their objects are looked up
+// from kubernetes until they are used again. The caller must hold the lock.
+func (h *clusterCache) stopIdle() {
+	if !h.lazy.enabled || h.lazy.idle <= 0 {
+		return
+	}
+	idleSince := time.Now().Add(-h.lazy.idle).UnixNano()
+	for gvk, w := range h.watchers {
+		if atomic.LoadInt64(&w.usage.lastAccess) < idleSince {
+			logrus.Infof("Stopping idle metadata watch on %s", gvk)
+			w.cancel()
+			delete(h.watchers, gvk)
+			metrics.DeleteClusterCacheSize(gvk.String())
+		}
+	}
+}
+
+// uncached returns the resource of a type whose objects are looked up from kubernetes because they aren't cached,
+// as the type was evicted or its watch hasn't started or synced. A type that isn't watched yet is started when the
+// cache starts types lazily.
+func (h *clusterCache) uncached(gvk schema2.GroupVersionKind) (schema2.GroupVersionResource, bool) {
+	if ev, ok := h.evictions.get(gvk); ok {
+		return ev.gvr, true
+	}
+	if !h.lazy.enabled {
+		return schema2.GroupVersionResource{}, false
+	}
+	h.Use(gvk)
+
+	h.RLock()
+	defer h.RUnlock()
+	gvr, ok := h.known[gvk]
+	return gvr, ok
+}
+
+// getUncached gets the summary of an object of a type that isn't cached from kubernetes.
+func (h *clusterCache) getUncached(gvr schema2.GroupVersionResource, namespace, name string) (interface{}, bool, error) {
+	ctx, cancel := context.WithTimeout(h.ctx, fallbackTimeout)
+	defer cancel()
+
+	obj, err := h.summaryClient.resourceFor(gvr, namespace).get(ctx, name)
+	if err != nil || obj == nil {
+		return nil, false, err
+	}
+	return obj, true, nil
+}
+
+// listUncached lists the summaries of the objects of a type that isn't cached from kubernetes.
+func (h *clusterCache) listUncached(gvr schema2.GroupVersionResource) []interface{} {
+	ctx, cancel := context.WithTimeout(h.ctx, fallbackTimeout)
+	defer cancel()
+
+	var (
+		result []interface{}
+		opts   = metav1.ListOptions{Limit: fallbackPageSize}
+	)
+	for {
+		list, err := h.summaryClient.Resource(gvr).List(ctx, opts)
+		if err != nil {
+			logrus.Errorf("failed to list uncached type %s: %v", gvr, err)
+			return result
+		}
+		for i := range list.Items {
+			result = append(result, &list.Items[i])
+		}
+		if list.Continue == "" {
+			return result
+		}
+		opts.Continue = list.Continue
+	}
+}
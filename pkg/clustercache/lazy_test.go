@@ -0,0 +1,79 @@
+package clustercache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestLazyStartFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		lazyStart string
+		idle      string
+		want      lazyStart
+	}{
+		{
+			name: "unset",
+			want: lazyStart{},
+		},
+		{
+			name:      "default idle timeout",
+			lazyStart: "true",
+			want:      lazyStart{enabled: true, idle: defaultIdleTimeout},
+		},
+		{
+			name:      "idle timeout",
+			lazyStart: "true",
+			idle:      "60",
+			want:      lazyStart{enabled: true, idle: time.Minute},
+		},
+		{
+			name:      "never idle",
+			lazyStart: "true",
+			idle:      "0",
+			want:      lazyStart{enabled: true},
+		},
+		{
+			name:      "invalid",
+			lazyStart: "yes please",
+			want:      lazyStart{},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(lazyStartEnv, test.lazyStart)
+			t.Setenv(idleTimeoutEnv, test.idle)
+			assert.Equal(t, test.want, lazyStartFromEnv())
+		})
+	}
+}
+
+func TestStopIdle(t *testing.T) {
+	idleGVK := schema2.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	activeGVK := schema2.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	idleCtx, idleCancel := context.WithCancel(ctx)
+	idle := &watcher{ctx: idleCtx, cancel: idleCancel, gvk: idleGVK}
+	idle.usage.lastAccess = time.Now().Add(-time.Hour).UnixNano()
+	activeCtx, activeCancel := context.WithCancel(ctx)
+	active := &watcher{ctx: activeCtx, cancel: activeCancel, gvk: activeGVK}
+	active.usage.access()
+
+	h := &clusterCache{
+		ctx:      ctx,
+		watchers: map[schema2.GroupVersionKind]*watcher{idleGVK: idle, activeGVK: active},
+		lazy:     lazyStart{enabled: true, idle: 30 * time.Minute},
+	}
+	h.stopIdle()
+
+	assert.Error(t, idleCtx.Err())
+	assert.NoError(t, activeCtx.Err())
+	assert.Equal(t, map[schema2.GroupVersionKind]*watcher{activeGVK: active}, h.watchers)
+}
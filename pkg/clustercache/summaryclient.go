@@ -0,0 +1,128 @@
+package clustercache
+
+import (
+	"context"
+
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/rancher/wrangler/pkg/summary/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// summaryClient lists and watches summarized objects like the summary client of wrangler, but also keeps the
+// owner references and finalizers of the objects, so the cache can index them by owner.
+type summaryClient struct {
+	client    dynamic.Interface
+	namespace string
+	resource  schema2.GroupVersionResource
+}
+
+var _ client.Interface = &summaryClient{}
+
+func newSummaryClient(client dynamic.Interface) *summaryClient {
+	return &summaryClient{client: client}
+}
+
+func (c *summaryClient) Resource(resource schema2.GroupVersionResource) client.NamespaceableResourceInterface {
+	return &summaryClient{client: c.client, resource: resource}
+}
+
+func (c *summaryClient) Namespace(ns string) client.ResourceInterface {
+	ret := *c
+	ret.namespace = ns
+	return &ret
+}
+
+// resourceFor returns the client of a resource in a namespace, or in every namespace if it is empty.
+func (c *summaryClient) resourceFor(resource schema2.GroupVersionResource, namespace string) *summaryClient {
+	return &summaryClient{client: c.client, resource: resource, namespace: namespace}
+}
+
+// get returns the summary of an object, or nil if it doesn't exist.
+func (c *summaryClient) get(ctx context.Context, name string) (*summary.SummarizedObject, error) {
+	u, err := c.resourceClient().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return summarize(u), nil
+}
+
+func (c *summaryClient) resourceClient() dynamic.ResourceInterface {
+	if c.namespace == "" {
+		return c.client.Resource(c.resource)
+	}
+	return c.client.Resource(c.resource).Namespace(c.namespace)
+}
+
+func (c *summaryClient) List(ctx context.Context, opts metav1.ListOptions) (*summary.SummarizedObjectList, error) {
+	u, err := c.resourceClient().List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &summary.SummarizedObjectList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       u.GetKind(),
+			APIVersion: u.GetAPIVersion(),
+		},
+		ListMeta: metav1.ListMeta{
+			ResourceVersion:    u.GetResourceVersion(),
+			Continue:           u.GetContinue(),
+			RemainingItemCount: u.GetRemainingItemCount(),
+		},
+	}
+	for i := range u.Items {
+		list.Items = append(list.Items, *summarize(&u.Items[i]))
+	}
+	return list, nil
+}
+
+func (c *summaryClient) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	resp, err := c.resourceClient().Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	eventChan := make(chan watch.Event)
+	go func() {
+		defer close(eventChan)
+		for event := range resp.ResultChan() {
+			// don't encode status objects
+			if _, ok := event.Object.(*metav1.Status); !ok {
+				event.Object = summarize(event.Object)
+			}
+			eventChan <- event
+		}
+	}()
+
+	return &summaryWatcher{
+		Interface: resp,
+		eventChan: eventChan,
+	}, nil
+}
+
+// summarize returns the summarized object with the owner references and finalizers of the object.
+func summarize(obj runtime.Object) *summary.SummarizedObject {
+	summarized := summary.Summarized(obj)
+	if m, err := meta.Accessor(obj); err == nil {
+		summarized.OwnerReferences = m.GetOwnerReferences()
+		summarized.Finalizers = m.GetFinalizers()
+	}
+	return summarized
+}
+
+type summaryWatcher struct {
+	watch.Interface
+	eventChan chan watch.Event
+}
+
+func (w summaryWatcher) ResultChan() <-chan watch.Event {
+	return w.eventChan
+}
@@ -0,0 +1,49 @@
+package clustercache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOwnerIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  map[string]interface{}
+		want []string
+	}{
+		{
+			name: "owned",
+			obj: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata": map[string]interface{}{
+					"name":      "web-1",
+					"namespace": "default",
+					"ownerReferences": []interface{}{
+						map[string]interface{}{"apiVersion": "apps/v1", "kind": "ReplicaSet", "name": "web-abc", "uid": "1234"},
+					},
+				},
+			},
+			want: []string{"1234"},
+		},
+		{
+			name: "not owned",
+			obj: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]interface{}{"name": "web-1", "namespace": "default"},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			summarized := summarize(&unstructured.Unstructured{Object: test.obj})
+			got, err := ownerIndexFunc(summarized)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
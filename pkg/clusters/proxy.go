@@ -0,0 +1,49 @@
+package clusters
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rancher/steve/pkg/proxy"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const proxyPrefix = "/k8s/clusters/"
+
+// Handler proxies /k8s/clusters/<id>/ to the kubernetes API of a registered cluster. If impersonate is set the
+// requests are made as the user of the request, so the registered credentials must be allowed to impersonate,
+// otherwise they are made with the registered credentials. Requests for clusters that aren't registered, such as the
+// local cluster, are passed to next.
+func (r *Registry) Handler(impersonate bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, proxyPrefix), "/")
+		if id == "" || !strings.HasPrefix(req.URL.Path, proxyPrefix) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		cfg, err := r.RESTConfig(req.Context(), id)
+		if apierrors.IsNotFound(err) {
+			next.ServeHTTP(rw, req)
+			return
+		} else if err != nil {
+			logrus.Errorf("failed to get the config of cluster %s for proxy: %v", id, err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		prefix := proxyPrefix + id
+		if impersonate {
+			proxy.ImpersonatingHandler(prefix, cfg).ServeHTTP(rw, req)
+			return
+		}
+		handler, err := proxy.Handler(prefix, cfg)
+		if err != nil {
+			logrus.Errorf("failed to proxy cluster %s: %v", id, err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,489 @@
+// Package clusters registers downstream clusters, by kubeconfig or by server, token and CA certificate, and builds
+// the clients of the clusters registered. Registrations are kept as secrets of a namespace of the local cluster,
+// their clusters are checked for health periodically, and their kubernetes APIs are proxied on /k8s/clusters/<id>/.
+package clusters
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	healthSecondsEnv     = "CATTLE_CLUSTERS_HEALTH_SECONDS"
+	defaultHealthCheck   = time.Minute
+	healthCheckTimeout   = 10 * time.Second
+	clusterLabel         = "steve.cattle.io/cluster"
+	kubeconfigKey        = "kubeconfig"
+	serverKey            = "server"
+	tokenKey             = "token"
+	caKey                = "ca.crt"
+	registrationResource = "remoteclusters"
+)
+
+// Registration is how to reach a downstream cluster, either by a kubeconfig or by the URL of its server with a token
+// and the certificate of its CA.
+type Registration struct {
+	ID         string
+	Kubeconfig []byte
+	Server     string
+	Token      string
+	CACert     []byte
+}
+
+// Status is the health of a cluster, as of its last check.
+type Status struct {
+	Ready       bool
+	Version     string
+	Message     string
+	LastChecked time.Time
+}
+
+type clients struct {
+	resourceVersion string
+	config          *rest.Config
+	dynamic         dynamic.Interface
+}
+
+// Registry keeps the registrations of the downstream clusters in the secrets of a namespace, labelled as clusters.
+// The clients of the clusters are built from a cache of the secrets, so requests to the clusters don't fetch them.
+type Registry struct {
+	namespace string
+	secrets   typedcorev1.SecretInterface
+	informer  cache.SharedIndexInformer
+	cache     corelisters.SecretNamespaceLister
+	interval  time.Duration
+
+	lock     sync.RWMutex
+	clients  map[string]*clients
+	status   map[string]Status
+	handlers []func(id string)
+}
+
+// NewRegistry returns the registry of the clusters registered in a namespace. Their secrets are cached and their
+// health is checked once Start is called, every CATTLE_CLUSTERS_HEALTH_SECONDS, a minute by default.
+func NewRegistry(k8s kubernetes.Interface, namespace string) *Registry {
+	secrets := informers.NewSharedInformerFactoryWithOptions(k8s, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = clusterLabel + "=true"
+		})).Core().V1().Secrets()
+	return &Registry{
+		namespace: namespace,
+		secrets:   k8s.CoreV1().Secrets(namespace),
+		informer:  secrets.Informer(),
+		cache:     secrets.Lister().Secrets(namespace),
+		interval:  healthCheckFromEnv(),
+		clients:   map[string]*clients{},
+		status:    map[string]Status{},
+	}
+}
+
+func healthCheckFromEnv() time.Duration {
+	setting := os.Getenv(healthSecondsEnv)
+	if setting == "" {
+		return defaultHealthCheck
+	}
+	seconds, err := strconv.Atoi(setting)
+	if err != nil || seconds <= 0 {
+		logrus.Debugf("could not parse %s environment variable, error: %v", healthSecondsEnv, err)
+		return defaultHealthCheck
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Namespace is the namespace of the secrets of the registrations.
+func (r *Registry) Namespace() string {
+	return r.namespace
+}
+
+// Get returns the registration of a cluster.
+func (r *Registry) Get(ctx context.Context, id string) (*Registration, *corev1.Secret, error) {
+	secret, err := r.secrets.Get(ctx, id, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, notFound(id)
+		}
+		return nil, nil, err
+	}
+	if secret.Labels[clusterLabel] != "true" {
+		return nil, nil, notFound(id)
+	}
+	return fromSecret(secret), secret, nil
+}
+
+// List returns the registrations of every cluster, by ID.
+func (r *Registry) List(ctx context.Context) ([]*Registration, error) {
+	secrets, err := r.secrets.List(ctx, metav1.ListOptions{LabelSelector: clusterLabel + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Registration, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		result = append(result, fromSecret(&secrets.Items[i]))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// Create registers a cluster. The registration must be valid, but the cluster need not be reachable yet.
+func (r *Registry) Create(ctx context.Context, registration *Registration) (*Registration, error) {
+	if _, err := registration.RESTConfig(); err != nil {
+		return nil, err
+	}
+	secret, err := r.secrets.Create(ctx, toSecret(registration, nil), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	r.cacheSecret(secret)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.status[secret.Name] = Status{Message: "not checked yet"}
+	return fromSecret(secret), nil
+}
+
+// Update replaces the registration of a cluster.
+func (r *Registry) Update(ctx context.Context, registration *Registration) (*Registration, error) {
+	if _, err := registration.RESTConfig(); err != nil {
+		return nil, err
+	}
+	_, existing, err := r.Get(ctx, registration.ID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := r.secrets.Update(ctx, toSecret(registration, existing), metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	r.cacheSecret(secret)
+
+	r.lock.Lock()
+	delete(r.clients, registration.ID)
+	r.lock.Unlock()
+	r.notify(registration.ID)
+	return fromSecret(secret), nil
+}
+
+// Delete removes the registration of a cluster.
+func (r *Registry) Delete(ctx context.Context, id string) error {
+	_, existing, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.secrets.Delete(ctx, id, metav1.DeleteOptions{}); err != nil {
+		return err
+	}
+	if err := r.informer.GetStore().Delete(existing); err != nil {
+		logrus.Debugf("failed to remove the secret of cluster %s from the cache: %v", id, err)
+	}
+
+	r.lock.Lock()
+	delete(r.clients, id)
+	delete(r.status, id)
+	r.lock.Unlock()
+	r.notify(id)
+	return nil
+}
+
+// OnChange calls the handler with the ID of a cluster whenever its registration changes or is removed, so what was
+// made from its clients can be made again.
+func (r *Registry) OnChange(handler func(id string)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+func (r *Registry) notify(id string) {
+	r.lock.RLock()
+	handlers := r.handlers
+	r.lock.RUnlock()
+	for _, handler := range handlers {
+		handler(id)
+	}
+}
+
+// RESTConfig returns the config of the clients of a registered cluster.
+func (r *Registry) RESTConfig(ctx context.Context, id string) (*rest.Config, error) {
+	c, err := r.clientsOf(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return rest.CopyConfig(c.config), nil
+}
+
+// DynamicClient returns a dynamic client of a registered cluster, built again whenever its registration changes.
+func (r *Registry) DynamicClient(ctx context.Context, id string) (dynamic.Interface, error) {
+	c, err := r.clientsOf(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.dynamic, nil
+}
+
+// cacheSecret writes a secret the registry changed to the cache, so the clients of its cluster aren't built from the
+// previous version until the watch of the cache catches up.
+func (r *Registry) cacheSecret(secret *corev1.Secret) {
+	if err := r.informer.GetStore().Update(secret); err != nil {
+		logrus.Debugf("failed to cache the secret of cluster %s: %v", secret.Name, err)
+	}
+}
+
+// cached returns the registration of a cluster from the cache of its secret. The secret is fetched until the cache
+// is synced, and when it is too new to be cached yet.
+func (r *Registry) cached(ctx context.Context, id string) (*Registration, *corev1.Secret, error) {
+	if r.informer.HasSynced() {
+		if secret, err := r.cache.Get(id); err == nil {
+			return fromSecret(secret), secret, nil
+		}
+	}
+	return r.Get(ctx, id)
+}
+
+// clientsOf returns the clients of a cluster, built again only when the resource version of its secret changes.
+func (r *Registry) clientsOf(ctx context.Context, id string) (*clients, error) {
+	registration, secret, err := r.cached(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.RLock()
+	c, ok := r.clients[id]
+	r.lock.RUnlock()
+	if ok && c.resourceVersion == secret.ResourceVersion {
+		return c, nil
+	}
+	if ok {
+		// changed other than through the registry
+		defer r.notify(id)
+	}
+
+	config, err := registration.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	c = &clients{
+		resourceVersion: secret.ResourceVersion,
+		config:          config,
+		dynamic:         dynamicClient,
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.clients[id] = c
+	return c, nil
+}
+
+// Status returns the health of a cluster as of its last check. Clusters not yet checked are not ready.
+func (r *Registry) Status(id string) Status {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.status[id]
+}
+
+// Registered returns whether a cluster is registered, as of its creation through the registry or the last health
+// check, so it is quick enough to route every request by.
+func (r *Registry) Registered(id string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	_, ok := r.status[id]
+	return ok
+}
+
+// Start caches the secrets of the registrations and checks the health of every registered cluster until the context
+// is done.
+func (r *Registry) Start(ctx context.Context) {
+	go r.informer.Run(ctx.Done())
+	go func() {
+		if !cache.WaitForCacheSync(ctx.Done(), r.informer.HasSynced) {
+			return
+		}
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			r.checkAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Registry) checkAll(ctx context.Context) {
+	registrations, err := r.List(ctx)
+	if err != nil {
+		logrus.Debugf("failed to list the registered clusters: %v", err)
+		return
+	}
+
+	status := make(map[string]Status, len(registrations))
+	for _, registration := range registrations {
+		status[registration.ID] = r.check(ctx, registration.ID)
+	}
+
+	r.lock.Lock()
+	var removed []string
+	for id := range r.status {
+		if _, ok := status[id]; !ok {
+			removed = append(removed, id)
+			delete(r.clients, id)
+		}
+	}
+	r.status = status
+	r.lock.Unlock()
+
+	for _, id := range removed {
+		r.notify(id)
+	}
+}
+
+// check returns the health of a cluster, which is ready if it serves its version.
+func (r *Registry) check(ctx context.Context, id string) Status {
+	status := Status{LastChecked: time.Now()}
+	config, err := r.RESTConfig(ctx, id)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	config.Timeout = healthCheckTimeout
+	k8s, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	version, err := k8s.Discovery().ServerVersion()
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	status.Ready = true
+	status.Version = version.GitVersion
+	return status
+}
+
+// RESTConfig returns the config of the clients of the cluster of a registration.
+func (r *Registration) RESTConfig() (*rest.Config, error) {
+	if len(r.Kubeconfig) > 0 {
+		if r.Server != "" || r.Token != "" {
+			return nil, fmt.Errorf("cluster %s is registered by a kubeconfig, so it can't also have a server or token", r.ID)
+		}
+		kubeconfig, err := clientcmd.Load(r.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s has an invalid kubeconfig: %w", r.ID, err)
+		}
+		if err := checkKubeconfig(kubeconfig); err != nil {
+			return nil, fmt.Errorf("cluster %s has an unsupported kubeconfig: %w", r.ID, err)
+		}
+		config, err := clientcmd.NewDefaultClientConfig(*kubeconfig, nil).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s has an invalid kubeconfig: %w", r.ID, err)
+		}
+		return config, nil
+	}
+	if r.Server == "" || r.Token == "" {
+		return nil, fmt.Errorf("cluster %s must be registered by a kubeconfig or by a server and token", r.ID)
+	}
+	if len(r.CACert) > 0 && !x509.NewCertPool().AppendCertsFromPEM(r.CACert) {
+		return nil, fmt.Errorf("cluster %s has an invalid CA certificate, which must be PEM encoded", r.ID)
+	}
+	return &rest.Config{
+		Host:        r.Server,
+		BearerToken: r.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: r.CACert,
+		},
+	}, nil
+}
+
+// checkKubeconfig refuses kubeconfigs that would make steve run commands or read files of its own host, such as exec
+// plugins and token or certificate files. Their credentials and certificates must be embedded as data instead.
+func checkKubeconfig(kubeconfig *clientcmdapi.Config) error {
+	for name, user := range kubeconfig.AuthInfos {
+		switch {
+		case user.Exec != nil:
+			return fmt.Errorf("user %s runs an exec plugin", name)
+		case user.AuthProvider != nil:
+			return fmt.Errorf("user %s uses the auth provider %s", name, user.AuthProvider.Name)
+		case user.TokenFile != "":
+			return fmt.Errorf("user %s reads its token from a file", name)
+		case user.ClientCertificate != "" || user.ClientKey != "":
+			return fmt.Errorf("user %s reads its client certificate from a file", name)
+		}
+	}
+	for name, cluster := range kubeconfig.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster %s reads its CA certificate from a file", name)
+		}
+	}
+	return nil
+}
+
+func notFound(id string) error {
+	return apierrors.NewNotFound(corev1.Resource(registrationResource), id)
+}
+
+func fromSecret(secret *corev1.Secret) *Registration {
+	return &Registration{
+		ID:         secret.Name,
+		Kubeconfig: secret.Data[kubeconfigKey],
+		Server:     string(secret.Data[serverKey]),
+		Token:      string(secret.Data[tokenKey]),
+		CACert:     secret.Data[caKey],
+	}
+}
+
+// toSecret returns the secret of a registration, replacing the data of the existing secret if there is one.
+func toSecret(registration *Registration, existing *corev1.Secret) *corev1.Secret {
+	secret := &corev1.Secret{}
+	if existing != nil {
+		secret = existing.DeepCopy()
+	}
+	secret.Name = registration.ID
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[clusterLabel] = "true"
+	secret.Type = corev1.SecretTypeOpaque
+	secret.Data = map[string][]byte{}
+	if len(registration.Kubeconfig) > 0 {
+		secret.Data[kubeconfigKey] = registration.Kubeconfig
+	}
+	if registration.Server != "" {
+		secret.Data[serverKey] = []byte(registration.Server)
+	}
+	if registration.Token != "" {
+		secret.Data[tokenKey] = []byte(registration.Token)
+	}
+	if len(registration.CACert) > 0 {
+		secret.Data[caKey] = registration.CACert
+	}
+	return secret
+}
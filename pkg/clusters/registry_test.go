@@ -0,0 +1,187 @@
+package clusters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com:6443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: east
+  context:
+    cluster: east
+    user: admin
+current-context: east
+`
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	k8s := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "clusters"},
+	})
+	registry := NewRegistry(k8s, "clusters")
+
+	_, err := registry.Create(ctx, &Registration{ID: "west", Server: "https://west.example.com"})
+	assert.Error(t, err, "a server needs a token")
+	_, err = registry.Create(ctx, &Registration{ID: "west", Kubeconfig: []byte(kubeconfig), Token: "token"})
+	assert.Error(t, err, "a kubeconfig can't be mixed with a token")
+
+	_, err = registry.Create(ctx, &Registration{ID: "east", Kubeconfig: []byte(kubeconfig)})
+	require.NoError(t, err)
+	_, err = registry.Create(ctx, &Registration{ID: "west", Server: "https://west.example.com", Token: "token", CACert: []byte("ca")})
+	assert.Error(t, err, "the CA certificate must be PEM encoded")
+	_, err = registry.Create(ctx, &Registration{ID: "west", Server: "https://west.example.com", Token: "token"})
+	require.NoError(t, err)
+
+	registrations, err := registry.List(ctx)
+	require.NoError(t, err)
+	var ids []string
+	for _, registration := range registrations {
+		ids = append(ids, registration.ID)
+	}
+	assert.Equal(t, []string{"east", "west"}, ids)
+
+	cfg, err := registry.RESTConfig(ctx, "east")
+	require.NoError(t, err)
+	assert.Equal(t, "https://east.example.com:6443", cfg.Host)
+	assert.Equal(t, "secret", cfg.BearerToken)
+
+	cfg, err = registry.RESTConfig(ctx, "west")
+	require.NoError(t, err)
+	assert.Equal(t, "https://west.example.com", cfg.Host)
+	assert.Equal(t, "token", cfg.BearerToken)
+
+	_, err = registry.Update(ctx, &Registration{ID: "west", Server: "https://west.example.org", Token: "token"})
+	require.NoError(t, err)
+	cfg, err = registry.RESTConfig(ctx, "west")
+	require.NoError(t, err)
+	assert.Equal(t, "https://west.example.org", cfg.Host)
+
+	_, _, err = registry.Get(ctx, "unrelated")
+	assert.True(t, apierrors.IsNotFound(err), "secrets that don't register clusters aren't clusters")
+
+	require.NoError(t, registry.Delete(ctx, "west"))
+	_, err = registry.RESTConfig(ctx, "west")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRegistryCachesSecrets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	k8s := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "west", Namespace: "clusters", Labels: map[string]string{clusterLabel: "true"}, ResourceVersion: "1"},
+		Data:       map[string][]byte{serverKey: []byte("https://west.example.com"), tokenKey: []byte("token")},
+	})
+	gets := 0
+	k8s.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+		return false, nil, nil
+	})
+	registry := NewRegistry(k8s, "clusters")
+	registry.Start(ctx)
+	require.Eventually(t, registry.informer.HasSynced, 10*time.Second, 10*time.Millisecond)
+
+	first, err := registry.DynamicClient(ctx, "west")
+	require.NoError(t, err)
+	second, err := registry.DynamicClient(ctx, "west")
+	require.NoError(t, err)
+	assert.Same(t, first, second, "clients are built again only when the secret changes")
+	assert.Zero(t, gets, "the secret is read from the cache")
+
+	secret, err := k8s.CoreV1().Secrets("clusters").Get(ctx, "west", metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.ResourceVersion = "2"
+	secret.Data[serverKey] = []byte("https://west.example.org")
+	_, err = k8s.CoreV1().Secrets("clusters").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		cfg, err := registry.RESTConfig(ctx, "west")
+		return err == nil && cfg.Host == "https://west.example.org"
+	}, 10*time.Second, 10*time.Millisecond, "changes made other than through the registry are cached")
+}
+
+func TestHandlerPassesUnregisteredClusters(t *testing.T) {
+	registry := NewRegistry(fake.NewSimpleClientset(), "clusters")
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+	rw := httptest.NewRecorder()
+	registry.Handler(false, next).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/k8s/clusters/local/api", nil))
+	assert.Equal(t, http.StatusTeapot, rw.Code)
+}
+
+func TestRESTConfigRefusesHostCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+		ca   string
+	}{
+		{
+			name: "exec plugin",
+			user: "exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: /bin/sh",
+		},
+		{
+			name: "auth provider",
+			user: "auth-provider:\n      name: gcp",
+		},
+		{
+			name: "token file",
+			user: "tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token",
+		},
+		{
+			name: "client certificate file",
+			user: "client-certificate: /etc/kubernetes/admin.crt\n    client-key: /etc/kubernetes/admin.key",
+		},
+		{
+			name: "CA certificate file",
+			user: "token: secret",
+			ca:   "\n    certificate-authority: /etc/kubernetes/pki/ca.crt",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			config := `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com:6443` + test.ca + `
+users:
+- name: admin
+  user:
+    ` + test.user + `
+contexts:
+- name: east
+  context:
+    cluster: east
+    user: admin
+current-context: east
+`
+			_, err := (&Registration{ID: "east", Kubeconfig: []byte(config)}).RESTConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unsupported kubeconfig")
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package clusters
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/data/convert"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// RemoteCluster is a registered cluster. The kubeconfig and token are only written, and never returned.
+type RemoteCluster struct {
+	ID         string              `json:"id,omitempty"`
+	Kubeconfig string              `json:"kubeconfig,omitempty"`
+	Server     string              `json:"server,omitempty"`
+	Token      string              `json:"token,omitempty"`
+	CACert     string              `json:"caCert,omitempty"`
+	Status     RemoteClusterStatus `json:"status"`
+}
+
+type RemoteClusterStatus struct {
+	Ready       bool   `json:"ready"`
+	Version     string `json:"version,omitempty"`
+	Message     string `json:"message,omitempty"`
+	LastChecked string `json:"lastChecked,omitempty"`
+}
+
+// Register adds the remotecluster schema, which registers the clusters of the registry. Users may get, create,
+// update and delete the registrations whose secrets they may get, create, update and delete.
+func Register(schemas *types.APISchemas, registry *Registry) {
+	schemas.InternalSchemas.TypeName("remotecluster", RemoteCluster{})
+	schemas.MustImportAndCustomize(RemoteCluster{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet, http.MethodPost}
+		schema.ResourceMethods = []string{http.MethodGet, http.MethodPut, http.MethodDelete}
+		schema.Store = &Store{registry: registry}
+	})
+}
+
+// Store serves the registrations of a registry.
+type Store struct {
+	empty.Store
+	registry *Registry
+}
+
+func (s *Store) toAPIObject(registration *Registration) types.APIObject {
+	remote := RemoteCluster{
+		ID:     registration.ID,
+		Server: registration.Server,
+		CACert: string(registration.CACert),
+	}
+	if config, err := registration.RESTConfig(); err == nil {
+		remote.Server = config.Host
+	}
+	status := s.registry.Status(registration.ID)
+	remote.Status = RemoteClusterStatus{
+		Ready:   status.Ready,
+		Version: status.Version,
+		Message: status.Message,
+	}
+	if !status.LastChecked.IsZero() {
+		remote.Status.LastChecked = status.LastChecked.UTC().Format(time.RFC3339)
+	}
+	return types.APIObject{
+		Type:   "remotecluster",
+		ID:     registration.ID,
+		Object: remote,
+	}
+}
+
+// authorize returns an error unless the user may do the verb to the secret of a registration.
+func (s *Store) authorize(apiOp *types.APIRequest, verb, id string) error {
+	if canSecret(apiOp, s.registry.Namespace(), verb, id) {
+		return nil
+	}
+	return apierror.NewAPIError(validation.PermissionDenied, "can not "+verb+" remoteclusters "+id)
+}
+
+func canSecret(apiOp *types.APIRequest, namespace, verb, id string) bool {
+	schema := apiOp.Schemas.LookupSchema("secret")
+	if schema == nil {
+		return false
+	}
+	return accesscontrol.GetAccessListMap(schema).Grants(verb, namespace, id)
+}
+
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := s.authorize(apiOp, "get", id); err != nil {
+		return types.APIObject{}, err
+	}
+	registration, _, err := s.registry.Get(apiOp.Context(), id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return s.toAPIObject(registration), nil
+}
+
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	registrations, err := s.registry.List(apiOp.Context())
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	var result types.APIObjectList
+	for _, registration := range registrations {
+		if !canSecret(apiOp, s.registry.Namespace(), "list", registration.ID) &&
+			!canSecret(apiOp, s.registry.Namespace(), "get", registration.ID) {
+			continue
+		}
+		result.Objects = append(result.Objects, s.toAPIObject(registration))
+	}
+	return result, nil
+}
+
+func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	registration, err := toRegistration(data, "")
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := s.authorize(apiOp, "create", registration.ID); err != nil {
+		return types.APIObject{}, err
+	}
+	registration, err = s.registry.Create(apiOp.Context(), registration)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return s.toAPIObject(registration), nil
+}
+
+func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	registration, err := toRegistration(data, id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := s.authorize(apiOp, "update", id); err != nil {
+		return types.APIObject{}, err
+	}
+	registration, err = s.registry.Update(apiOp.Context(), registration)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return s.toAPIObject(registration), nil
+}
+
+func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := s.authorize(apiOp, "delete", id); err != nil {
+		return types.APIObject{}, err
+	}
+	registration, _, err := s.registry.Get(apiOp.Context(), id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := s.registry.Delete(apiOp.Context(), id); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.toAPIObject(registration), nil
+}
+
+// toRegistration returns the registration of the body of a request, of the cluster of the id if one is given.
+func toRegistration(data types.APIObject, id string) (*Registration, error) {
+	var remote RemoteCluster
+	if err := convert.ToObj(data.Data(), &remote); err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	if id != "" {
+		remote.ID = id
+	}
+	if remote.ID == "" {
+		return nil, apierror.NewAPIError(validation.MissingRequired, "id is required")
+	}
+	if errs := k8svalidation.IsDNS1123Subdomain(remote.ID); len(errs) > 0 {
+		return nil, apierror.NewAPIError(validation.InvalidFormat, "invalid id "+remote.ID+": "+errs[0])
+	}
+	registration := &Registration{
+		ID:         remote.ID,
+		Kubeconfig: []byte(remote.Kubeconfig),
+		Server:     remote.Server,
+		Token:      remote.Token,
+		CACert:     []byte(remote.CACert),
+	}
+	if _, err := registration.RESTConfig(); err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	return registration, nil
+}
@@ -2,6 +2,8 @@ package schema
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/rancher/steve/pkg/resources/common"
 	schema2 "github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/schema/converter"
+	"github.com/rancher/steve/pkg/stores/apiservice"
 	apiextcontrollerv1 "github.com/rancher/wrangler/pkg/generated/controllers/apiextensions.k8s.io/v1"
 	v1 "github.com/rancher/wrangler/pkg/generated/controllers/apiregistration.k8s.io/v1"
 	"github.com/sirupsen/logrus"
@@ -19,6 +22,7 @@ import (
 	authorizationv1 "k8s.io/api/authorization/v1"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/discovery"
 	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	apiv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
@@ -38,14 +42,25 @@ type SchemasHandler interface {
 type handler struct {
 	sync.Mutex
 
-	ctx     context.Context
-	toSync  int32
+	ctx    context.Context
+	toSync int32
+	// pending are the CRDs changed since the last refresh, by key, with nil for deleted ones
+	pending     map[string]*apiextv1.CustomResourceDefinition
+	pendingLock sync.Mutex
+	// converted are the schemas of the last refresh before filtering, by versioned ID
+	converted map[string]*types.APISchema
+	// sources are the IDs of the schemas served for each converted schema that was not filtered out
+	sources map[string]string
 	schemas *schema2.Collection
 	client  discovery.DiscoveryInterface
 	cols    *common.DynamicColumns
 	crd     apiextcontrollerv1.CustomResourceDefinitionClient
 	ssar    authorizationv1client.SelfSubjectAccessReviewInterface
 	handler SchemasHandler
+	// resourceFilter selects the resources served
+	resourceFilter schema2.ResourceFilter
+	// availability is the availability of the aggregated APIServices, if they are tracked
+	availability *apiservice.Availability
 }
 
 func Register(ctx context.Context,
@@ -53,18 +68,27 @@ func Register(ctx context.Context,
 	discovery discovery.DiscoveryInterface,
 	crd apiextcontrollerv1.CustomResourceDefinitionController,
 	apiService v1.APIServiceController,
+	availability *apiservice.Availability,
 	ssar authorizationv1client.SelfSubjectAccessReviewInterface,
 	schemasHandler SchemasHandler,
-	schemas *schema2.Collection) {
+	schemas *schema2.Collection,
+	filter schema2.ResourceFilter) {
 
 	h := &handler{
-		ctx:     ctx,
-		cols:    cols,
-		client:  discovery,
-		schemas: schemas,
-		handler: schemasHandler,
-		crd:     crd,
-		ssar:    ssar,
+		ctx:            ctx,
+		pending:        map[string]*apiextv1.CustomResourceDefinition{},
+		cols:           cols,
+		client:         discovery,
+		schemas:        schemas,
+		handler:        schemasHandler,
+		crd:            crd,
+		ssar:           ssar,
+		resourceFilter: filter,
+		availability:   availability,
+	}
+
+	if availability != nil {
+		availability.OnChange(h.queueRefresh)
 	}
 
 	apiService.OnChange(ctx, "schema", h.OnChangeAPIService)
@@ -72,27 +96,60 @@ func Register(ctx context.Context,
 }
 
 func (h *handler) OnChangeCRD(key string, crd *apiextv1.CustomResourceDefinition) (*apiextv1.CustomResourceDefinition, error) {
-	h.queueRefresh()
+	h.pendingLock.Lock()
+	h.pending[key] = crd
+	h.pendingLock.Unlock()
+	h.queue()
 	return crd, nil
 }
 
 func (h *handler) OnChangeAPIService(key string, api *apiv1.APIService) (*apiv1.APIService, error) {
+	if h.availability != nil {
+		h.availability.Update(key, api)
+	}
 	h.queueRefresh()
 	return api, nil
 }
 
 func (h *handler) queueRefresh() {
 	atomic.StoreInt32(&h.toSync, 1)
+	h.queue()
+}
 
+// queue refreshes the schemas after a short delay, which batches the changes made in the meantime: a full refresh
+// if one is needed, otherwise a refresh of just the changed CRDs.
+func (h *handler) queue() {
 	go func() {
 		time.Sleep(500 * time.Millisecond)
-		if err := h.refreshAll(h.ctx); err != nil {
+		if err := h.refresh(h.ctx); err != nil {
 			logrus.Errorf("failed to sync schemas: %v", err)
 			atomic.StoreInt32(&h.toSync, 1)
 		}
 	}()
 }
 
+func (h *handler) refresh(ctx context.Context) error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.pendingLock.Lock()
+	pending := h.pending
+	h.pending = map[string]*apiextv1.CustomResourceDefinition{}
+	h.pendingLock.Unlock()
+
+	if h.converted == nil {
+		// nothing to update incrementally before the first full refresh
+		atomic.StoreInt32(&h.toSync, 1)
+	}
+	if h.needToSync() {
+		return h.refreshAll(ctx)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return h.refreshCRDs(ctx, pending)
+}
+
 func isListOrGetable(schema *types.APISchema) bool {
 	for _, verb := range attributes.Verbs(schema) {
 		switch verb {
@@ -128,7 +185,8 @@ func (h *handler) getColumns(ctx context.Context, schemas map[string]*types.APIS
 	eg := errgroup.Group{}
 
 	for _, schema := range schemas {
-		if !isListOrGetable(schema) {
+		if !isListOrGetable(schema) || !attributes.Available(schema) {
+			// an unavailable APIService would not answer until the request times out
 			continue
 		}
 
@@ -146,46 +204,130 @@ func (h *handler) getColumns(ctx context.Context, schemas map[string]*types.APIS
 	return eg.Wait()
 }
 
+// refreshAll converts and filters every schema of the cluster.
 func (h *handler) refreshAll(ctx context.Context) error {
-	h.Lock()
-	defer h.Unlock()
-
-	if !h.needToSync() {
-		return nil
-	}
-
 	schemas, err := converter.ToSchemas(h.crd, h.client)
 	if err != nil {
 		return err
 	}
+	h.keepUnavailable(schemas)
 
+	sources := map[string]string{}
 	filteredSchemas := map[string]*types.APISchema{}
-	for _, schema := range schemas {
-		if isListWatchable(schema) {
-			if preferredTypeExists(schema, schemas) {
-				continue
-			}
-			if ok, err := h.allowed(ctx, schema); err != nil {
-				return err
-			} else if !ok {
-				continue
-			}
+	for id, schema := range schemas {
+		schema, ok, err := h.filter(ctx, schema, schemas)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		sources[id] = schema.ID
+		filteredSchemas[schema.ID] = schema
+	}
+
+	if err := h.getColumns(h.ctx, filteredSchemas); err != nil {
+		return err
+	}
+
+	h.converted = schemas
+	h.sources = sources
+	h.schemas.Reset(filteredSchemas)
+	if h.handler != nil {
+		return h.handler.OnSchemas(h.schemas)
+	}
+
+	return nil
+}
+
+// keepUnavailable adds the converted schemas of the previous refresh that are missing from the schemas because
+// their APIService is unavailable, as the discovery of a backend that is down fails, so that their types stay
+// listed as unavailable rather than disappear until the backend is back.
+func (h *handler) keepUnavailable(schemas map[string]*types.APISchema) {
+	if h.availability == nil {
+		return
+	}
+	for id, schema := range h.converted {
+		if _, ok := schemas[id]; ok {
+			continue
+		}
+		gvr := attributes.GVR(schema)
+		if gvr.Resource == "" {
+			continue
+		}
+		if status, ok := h.availability.Status(gvr.GroupVersion()); ok && !status.Available {
+			schemas[id] = schema
 		}
+	}
+}
+
+// refreshCRDs converts and filters only the schemas of the changed CRDs, leaving every other schema, its columns
+// and its templates as they are. The schemas of a CRD come from the CRD itself rather than the OpenAPI document of
+// the cluster.
+func (h *handler) refreshCRDs(ctx context.Context, crds map[string]*apiextv1.CustomResourceDefinition) error {
+	schemas := make(map[string]*types.APISchema, len(h.converted))
+	for id, schema := range h.converted {
+		schemas[id] = schema
+	}
+
+	changed := map[string]bool{}
+	for key, crd := range crds {
+		for _, id := range crdSchemaIDs(key, schemas) {
+			delete(schemas, id)
+			changed[id] = true
+		}
+		if crd == nil || crd.DeletionTimestamp != nil {
+			continue
+		}
+		crdSchemas := map[string]*types.APISchema{}
+		if err := converter.AddCustomResource(crd, h.client, crdSchemas); err != nil {
+			return err
+		}
+		for id, schema := range crdSchemas {
+			schemas[id] = schema
+			changed[id] = true
+		}
+	}
 
-		gvk := attributes.GVK(schema)
-		if gvk.Kind != "" {
-			gvr := attributes.GVR(schema)
-			schema.ID = converter.GVKToSchemaID(gvk)
-			schema.PluralName = converter.GVRToPluralName(gvr)
+	sources := make(map[string]string, len(h.sources))
+	for id, schemaID := range h.sources {
+		sources[id] = schemaID
+	}
+	removed := map[string]bool{}
+	for id := range changed {
+		if schemaID, ok := sources[id]; ok {
+			removed[schemaID] = true
+			delete(sources, id)
+		}
+	}
+
+	filteredSchemas := map[string]*types.APISchema{}
+	for id := range changed {
+		schema, ok := schemas[id]
+		if !ok {
+			continue
 		}
+		schema, ok, err := h.filter(ctx, schema, schemas)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		sources[id] = schema.ID
 		filteredSchemas[schema.ID] = schema
+		delete(removed, schema.ID)
 	}
 
 	if err := h.getColumns(h.ctx, filteredSchemas); err != nil {
 		return err
 	}
 
-	h.schemas.Reset(filteredSchemas)
+	var removedIDs []string
+	for id := range removed {
+		removedIDs = append(removedIDs, id)
+	}
+	h.converted = schemas
+	h.sources = sources
+	h.schemas.Update(filteredSchemas, removedIDs)
 	if h.handler != nil {
 		return h.handler.OnSchemas(h.schemas)
 	}
@@ -193,6 +335,78 @@ func (h *handler) refreshAll(ctx context.Context) error {
 	return nil
 }
 
+// crdSchemaIDs returns the IDs of the converted schemas of the CRD with the key, which is its name of the form
+// <plural>.<group>: the schema of each version, and the schemas of the nested objects of each version.
+func crdSchemaIDs(key string, schemas map[string]*types.APISchema) []string {
+	plural, group, _ := strings.Cut(key, ".")
+	var prefixes, result []string
+	for id, schema := range schemas {
+		if gvr := attributes.GVR(schema); gvr.Group == group && gvr.Resource == plural {
+			prefixes = append(prefixes, id+".")
+			result = append(result, id)
+		}
+	}
+	for id := range schemas {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(id, prefix) {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// filter returns the schema as served, or false if it is left out: a resource excluded by the resource filter, or
+// a listable type that has a preferred version or group, or that steve itself may not list. The converted schema is
+// not changed.
+func (h *handler) filter(ctx context.Context, schema *types.APISchema, schemas map[string]*types.APISchema) (*types.APISchema, bool, error) {
+	if gr := attributes.GR(schema); gr.Resource != "" && !h.resourceFilter.Allowed(gr) {
+		return nil, false, nil
+	}
+	if isListWatchable(schema) {
+		if preferredTypeExists(schema, schemas) {
+			return nil, false, nil
+		}
+		if ok, err := h.allowed(ctx, schema); err != nil || !ok {
+			return nil, false, err
+		}
+	}
+
+	schema = schema.DeepCopy()
+	if h.availability != nil {
+		if status, ok := h.availability.Status(attributes.GVR(schema).GroupVersion()); ok {
+			attributes.SetAPIService(schema, status.Name, status.Available, status.Message)
+		}
+	}
+	if isListWatchable(schema) {
+		attributes.SetVersions(schema, servedVersions(schema, schemas))
+	}
+	gvk := attributes.GVK(schema)
+	if gvk.Kind != "" {
+		gvr := attributes.GVR(schema)
+		schema.ID = converter.GVKToSchemaID(gvk)
+		schema.PluralName = converter.GVRToPluralName(gvr)
+	}
+	return schema, true, nil
+}
+
+// servedVersions returns the versions of the group of the schema that serve its resource, newest first. The apiserver
+// converts between them, so any of them may be requested of the served schema.
+func servedVersions(schema *types.APISchema, schemas map[string]*types.APISchema) []string {
+	gvr := attributes.GVR(schema)
+	var versions []string
+	for _, other := range schemas {
+		if otherGVR := attributes.GVR(other); otherGVR.Group == gvr.Group && otherGVR.Resource == gvr.Resource && isListWatchable(other) {
+			versions = append(versions, otherGVR.Version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return version.CompareKubeAwareVersionStrings(versions[i], versions[j]) > 0
+	})
+	return versions
+}
+
 func preferredTypeExists(schema *types.APISchema, schemas map[string]*types.APISchema) bool {
 	if replacement, ok := typeNameChanges[schema.ID]; ok && schemas[replacement] != nil {
 		return true
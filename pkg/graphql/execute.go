@@ -0,0 +1,338 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/summarycache"
+	"github.com/rancher/wrangler/pkg/data"
+	"github.com/rancher/wrangler/pkg/slice"
+)
+
+// maxLookups is how many lists and gets of objects the store may be asked for to resolve a query.
+const maxLookups = 500
+
+var errTooManyLookups = fmt.Errorf("query looks up more than %d objects and lists", maxLookups)
+
+// Error is an error of a field of a query, with the path of the field in the result.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute resolves the fields of a query against the schemas of the request, through the stores of the schemas
+// and with the access of the user, so the objects are the same the REST API returns. The fields that fail are
+// null in the data and reported as errors. Once a query has looked up 500 objects and lists, the fields that would
+// look up more fail.
+func Execute(apiOp *types.APIRequest, fields []Field) (map[string]interface{}, []Error) {
+	e := &executor{
+		apiOp:   apiOp,
+		schemas: queryableSchemas(apiOp.Schemas),
+	}
+	result := map[string]interface{}{}
+	for _, field := range fields {
+		path := []interface{}{field.key()}
+		if field.Name == "__typename" {
+			result[field.key()] = "Query"
+			continue
+		}
+		schema, ok := e.schemas[field.Name]
+		if !ok {
+			result[field.key()] = nil
+			e.errorf(path, "unknown field %s on Query", field.Name)
+			continue
+		}
+		objs, err := e.resolve(schema, field.Arguments)
+		if err != nil {
+			result[field.key()] = nil
+			e.errorf(path, "%v", err)
+			continue
+		}
+		result[field.key()] = e.selectObjects(objs, field.Selection, path)
+	}
+	return result, e.errors
+}
+
+// queryableSchemas returns the schemas the user may list by their GraphQL names.
+func queryableSchemas(apiSchemas *types.APISchemas) map[string]*types.APISchema {
+	result := map[string]*types.APISchema{}
+	for _, schema := range apiSchemas.Schemas {
+		if name := typeName(schema.ID); validName(name) && schema.Store != nil &&
+			slice.ContainsString(schema.CollectionMethods, http.MethodGet) {
+			result[name] = schema
+		}
+	}
+	return result
+}
+
+// typeName returns the GraphQL name of a schema ID, which may only contain letters, digits and underscores.
+func typeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 && isName(byte(r)) {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+type executor struct {
+	apiOp   *types.APIRequest
+	schemas map[string]*types.APISchema
+	errors  []Error
+	lookups int
+}
+
+// object is a formatted object with its schema, so its related objects can be resolved.
+type object struct {
+	schema *types.APISchema
+	data   map[string]interface{}
+}
+
+func (e *executor) errorf(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, Error{
+		Message: fmt.Sprintf(format, args...),
+		Path:    append([]interface{}{}, path...),
+	})
+}
+
+// resolve returns the objects of a root field: the object with the name argument, or the list of the objects
+// selected by the namespace, labelSelector, fieldSelector and limit arguments.
+func (e *executor) resolve(schema *types.APISchema, args map[string]interface{}) ([]object, error) {
+	query := url.Values{}
+	for _, param := range []string{"labelSelector", "fieldSelector"} {
+		if value, ok := args[param].(string); ok && value != "" {
+			query.Set(param, value)
+		}
+	}
+	if limit, ok := args["limit"].(int64); ok && limit > 0 {
+		query.Set("limit", strconv.FormatInt(limit, 10))
+	}
+	namespace, _ := args["namespace"].(string)
+	if name, _ := args["name"].(string); name != "" {
+		obj, err := e.byID(schema, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return []object{obj}, nil
+	}
+	return e.list(schema, namespace, query)
+}
+
+// request returns a GET request of the schema, as the REST API would parse it.
+func (e *executor) request(schema *types.APISchema, namespace, name string, query url.Values) *types.APIRequest {
+	apiOp := e.apiOp.Clone()
+	apiOp.Schema = schema
+	apiOp.Type = schema.ID
+	apiOp.Namespace = namespace
+	apiOp.Name = name
+	apiOp.Method = http.MethodGet
+	apiOp.Action = ""
+	apiOp.Link = ""
+	apiOp.Query = query
+	apiOp.Request = e.apiOp.Request.Clone(e.apiOp.Context())
+	apiOp.Request.Method = http.MethodGet
+	apiOp.Request.URL.RawQuery = query.Encode()
+	return apiOp
+}
+
+// lookup counts a lookup of the store, failing once the query made too many.
+func (e *executor) lookup() error {
+	if e.lookups >= maxLookups {
+		return errTooManyLookups
+	}
+	e.lookups++
+	return nil
+}
+
+func (e *executor) byID(schema *types.APISchema, namespace, name string) (object, error) {
+	if err := e.lookup(); err != nil {
+		return object{}, err
+	}
+	apiOp := e.request(schema, namespace, name, url.Values{})
+	obj, err := handlers.ByIDHandler(apiOp)
+	if err != nil {
+		return object{}, err
+	}
+	return format(apiOp, schema, obj), nil
+}
+
+func (e *executor) list(schema *types.APISchema, namespace string, query url.Values) ([]object, error) {
+	if err := e.lookup(); err != nil {
+		return nil, err
+	}
+	apiOp := e.request(schema, namespace, "", query)
+	list, err := handlers.ListHandler(apiOp)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]object, 0, len(list.Objects))
+	for _, obj := range list.Objects {
+		result = append(result, format(apiOp, schema, obj))
+	}
+	return result, nil
+}
+
+// format returns the data of an object as the REST API writes it, with the fields its formatter adds.
+func format(apiOp *types.APIRequest, schema *types.APISchema, obj types.APIObject) object {
+	resource := &types.RawResource{
+		ID:        obj.ID,
+		Type:      schema.ID,
+		Schema:    schema,
+		Links:     map[string]string{},
+		Actions:   map[string]string{},
+		APIObject: obj,
+	}
+	if schema.Formatter != nil {
+		schema.Formatter(apiOp, resource)
+	}
+	d := resource.APIObject.Data()
+	if d == nil {
+		d = data.Object{}
+	}
+	if _, ok := d["id"]; !ok {
+		d["id"] = resource.ID
+	}
+	if _, ok := d["type"]; !ok {
+		d["type"] = resource.Type
+	}
+	return object{schema: schema, data: d}
+}
+
+func (e *executor) selectObjects(objs []object, selection []Field, path []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(objs))
+	for i, obj := range objs {
+		result = append(result, e.selectObject(obj, selection, append(path, i)))
+	}
+	return result
+}
+
+// selectObject returns the selected fields of an object. Without a selection the whole object is returned.
+func (e *executor) selectObject(obj object, selection []Field, path []interface{}) interface{} {
+	if len(selection) == 0 {
+		return obj.data
+	}
+	result := map[string]interface{}{}
+	for _, field := range selection {
+		fieldPath := append(path, field.key())
+		switch field.Name {
+		case "__typename":
+			result[field.key()] = typeName(obj.schema.ID)
+		case "related":
+			related, err := e.related(obj, field.Arguments)
+			if err != nil {
+				result[field.key()] = nil
+				e.errorf(fieldPath, "%v", err)
+				continue
+			}
+			result[field.key()] = e.selectObjects(related, field.Selection, fieldPath)
+		default:
+			result[field.key()] = selectValue(obj.data[field.Name], field.Selection)
+		}
+	}
+	return result
+}
+
+// selectValue returns the selected fields of a value that is not an object of a schema.
+func selectValue(value interface{}, selection []Field) interface{} {
+	if len(selection) == 0 || value == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for _, field := range selection {
+			result[field.key()] = selectValue(v[field.Name], field.Selection)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, selectValue(item, selection))
+		}
+		return result
+	}
+	// a selection of a value of another Go type is resolved on its JSON form
+	var generic interface{}
+	if bytes, err := json.Marshal(value); err == nil && json.Unmarshal(bytes, &generic) == nil {
+		if _, ok := generic.(map[string]interface{}); ok {
+			return selectValue(generic, selection)
+		}
+		if _, ok := generic.([]interface{}); ok {
+			return selectValue(generic, selection)
+		}
+	}
+	return value
+}
+
+// related returns the objects of the type argument the object is related to, as listed in its relationships,
+// optionally only the relationships of the rel argument. Objects the user may not get are left out.
+func (e *executor) related(obj object, args map[string]interface{}) ([]object, error) {
+	relatedType, _ := args["type"].(string)
+	schema := e.apiOp.Schemas.LookupSchema(relatedType)
+	if schema == nil {
+		if schema = e.schemas[relatedType]; schema == nil {
+			return nil, fmt.Errorf("unknown type %q", relatedType)
+		}
+	}
+	relName, _ := args["rel"].(string)
+
+	var rels []summarycache.Relationship
+	if value, ok := data.GetValue(obj.data, "metadata", "relationships"); ok {
+		if bytes, err := json.Marshal(value); err == nil {
+			_ = json.Unmarshal(bytes, &rels)
+		}
+	}
+
+	var (
+		result []object
+		seen   = map[string]bool{}
+	)
+	add := func(objs ...object) {
+		for _, o := range objs {
+			id, _ := o.data["id"].(string)
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, o)
+			}
+		}
+	}
+	for _, rel := range rels {
+		if relName != "" && rel.Rel != relName {
+			continue
+		}
+		if e.lookups >= maxLookups {
+			// the lookups of the relationships fail silently otherwise, as those of forbidden objects do
+			return nil, errTooManyLookups
+		}
+		switch {
+		case rel.ToType == schema.ID && rel.ToID != "":
+			namespace, name := splitNamespace(rel.ToID)
+			if o, err := e.byID(schema, namespace, name); err == nil {
+				add(o)
+			}
+		case rel.ToType == schema.ID && rel.Selector != "":
+			if objs, err := e.list(schema, rel.ToNamespace, url.Values{"labelSelector": []string{rel.Selector}}); err == nil {
+				add(objs...)
+			}
+		case rel.FromType == schema.ID && rel.FromID != "":
+			namespace, name := splitNamespace(rel.FromID)
+			if o, err := e.byID(schema, namespace, name); err == nil {
+				add(o)
+			}
+		}
+	}
+	return result, nil
+}
+
+func splitNamespace(id string) (string, string) {
+	if namespace, name, ok := strings.Cut(id, "/"); ok {
+		return namespace, name
+	}
+	return "", id
+}
@@ -0,0 +1,133 @@
+package graphql
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type mapStore struct {
+	empty.Store
+	objects map[string]map[string]interface{}
+}
+
+func (m *mapStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	obj, ok := m.objects[apiOp.Namespace+"/"+id]
+	if !ok {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "not found")
+	}
+	return types.APIObject{Type: schema.ID, ID: apiOp.Namespace + "/" + id, Object: &unstructured.Unstructured{Object: obj}}, nil
+}
+
+func (m *mapStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	var list types.APIObjectList
+	for id, obj := range m.objects {
+		if selector := apiOp.Request.URL.Query().Get("labelSelector"); selector != "" && selector != "app=web" {
+			continue
+		}
+		list.Objects = append(list.Objects, types.APIObject{Type: schema.ID, ID: id, Object: &unstructured.Unstructured{Object: obj}})
+	}
+	return list, nil
+}
+
+func TestExecute(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	methods := []string{http.MethodGet}
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "apps.deployment", ResourceMethods: methods, CollectionMethods: methods},
+		Store: &mapStore{objects: map[string]map[string]interface{}{
+			"default/web": {
+				"metadata": map[string]interface{}{
+					"name": "web",
+					"relationships": []interface{}{
+						map[string]interface{}{"toType": "pod", "toNamespace": "default", "selector": "app=web", "rel": "creates"},
+					},
+				},
+			},
+		}},
+	}))
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", ResourceMethods: methods, CollectionMethods: methods},
+		Store: &mapStore{objects: map[string]map[string]interface{}{
+			"default/web-1": {
+				"metadata": map[string]interface{}{"name": "web-1"},
+				"spec":     map[string]interface{}{"nodeName": "node-1"},
+			},
+		}},
+	}))
+	apiOp := &types.APIRequest{
+		Schemas:       apiSchemas,
+		AccessControl: accesscontrol.NewAccessControl(),
+		Request:       httptest.NewRequest(http.MethodPost, "/v1/graphql", nil),
+	}
+
+	fields, err := Parse(`{
+		web: apps_deployment(namespace: "default", name: "web") {
+			metadata { name }
+			pods: related(type: "pod") { __typename, spec { nodeName } }
+		}
+		missing: pod(namespace: "default", name: "web-2") { id }
+		secret { id }
+	}`, nil)
+	require.NoError(t, err)
+
+	data, errs := Execute(apiOp, fields)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web"},
+			"pods": []interface{}{
+				map[string]interface{}{"__typename": "pod", "spec": map[string]interface{}{"nodeName": "node-1"}},
+			},
+		},
+	}, data["web"])
+	assert.Nil(t, data["missing"])
+	assert.Nil(t, data["secret"])
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, []interface{}{"missing"}, errs[0].Path)
+		assert.Equal(t, "unknown field secret on Query", errs[1].Message)
+	}
+}
+
+func TestExecuteLookupLimit(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	methods := []string{http.MethodGet}
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", ResourceMethods: methods, CollectionMethods: methods},
+		Store: &mapStore{objects: map[string]map[string]interface{}{
+			"default/web-1": {"metadata": map[string]interface{}{"name": "web-1"}},
+		}},
+	}))
+	apiOp := &types.APIRequest{
+		Schemas:       apiSchemas,
+		AccessControl: accesscontrol.NewAccessControl(),
+		Request:       httptest.NewRequest(http.MethodPost, "/v1/graphql", nil),
+	}
+
+	var query strings.Builder
+	query.WriteString("{")
+	for i := 0; i <= maxLookups; i++ {
+		fmt.Fprintf(&query, " p%d: pod(namespace: \"default\", name: \"web-1\")", i)
+	}
+	query.WriteString(" }")
+	fields, err := Parse(query.String(), nil)
+	require.NoError(t, err)
+
+	data, errs := Execute(apiOp, fields)
+	assert.NotNil(t, data["p0"])
+	assert.Nil(t, data[fmt.Sprintf("p%d", maxLookups)])
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, errTooManyLookups.Error(), errs[0].Message)
+	}
+}
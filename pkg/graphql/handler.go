@@ -0,0 +1,88 @@
+// Package graphql serves a subset of GraphQL over the steve schemas visible to a user: a single query operation of
+// fields, aliases, arguments and variables. Fragments, directives, mutations, subscriptions and introspection other
+// than __typename are not part of it and are rejected, so clients and tools that need them, such as those that
+// introspect the schema, do not work with it; the schema is served in the schema definition language instead. Each
+// queryable schema is a field of the query type, resolved through its store like a REST request, so clients can
+// fetch only the fields they need and follow the relationships of objects, such as deployment to pods to node, in a
+// single request.
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+// maxBodySize is the largest body of a POST.
+const maxBodySize = 1 << 20
+
+// request is the body of a GraphQL request.
+type request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// response is the body of a GraphQL response.
+type response struct {
+	Data   map[string]interface{} `json:"data,omitempty"`
+	Errors []Error                `json:"errors,omitempty"`
+}
+
+// Handler returns a handler which executes the query of each request, in the subset of GraphQL Parse accepts, read from a JSON body of a POST or from the
+// query and variables parameters of a GET. A GET without a query writes the schema in the schema definition
+// language. Bodies larger than 1MiB are refused.
+func Handler(requestFor func(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body request
+		switch req.Method {
+		case http.MethodGet:
+			body.Query = req.URL.Query().Get("query")
+			if variables := req.URL.Query().Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &body.Variables); err != nil {
+					writeResponse(rw, http.StatusBadRequest, response{Errors: []Error{{Message: "invalid variables: " + err.Error()}}})
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxBodySize)).Decode(&body); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeResponse(rw, http.StatusRequestEntityTooLarge, response{Errors: []Error{{Message: "request body is too large"}}})
+					return
+				}
+				writeResponse(rw, http.StatusBadRequest, response{Errors: []Error{{Message: "invalid request: " + err.Error()}}})
+				return
+			}
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		apiOp, ok := requestFor(rw, req)
+		if !ok {
+			return
+		}
+
+		if body.Query == "" && req.Method == http.MethodGet {
+			rw.Header().Set("Content-Type", "text/plain")
+			_, _ = rw.Write([]byte(SDL(apiOp.Schemas)))
+			return
+		}
+
+		fields, err := Parse(body.Query, body.Variables)
+		if err != nil {
+			writeResponse(rw, http.StatusBadRequest, response{Errors: []Error{{Message: err.Error()}}})
+			return
+		}
+		data, errs := Execute(apiOp, fields)
+		writeResponse(rw, http.StatusOK, response{Data: data, Errors: errs})
+	})
+}
+
+func writeResponse(rw http.ResponseWriter, code int, resp response) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(resp)
+}
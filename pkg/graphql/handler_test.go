@@ -0,0 +1,21 @@
+package graphql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerBodyLimit(t *testing.T) {
+	h := Handler(func(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool) {
+		return &types.APIRequest{Schemas: types.EmptyAPISchemas(), Request: req}, true
+	})
+	body := `{"query": "{ pod { id } }", "variables": {"padding": "` + strings.Repeat("x", maxBodySize) + `"}}`
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+}
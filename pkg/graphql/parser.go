@@ -0,0 +1,412 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxDepth is how deeply selection sets and values may be nested.
+	maxDepth = 16
+	// maxFields is how many fields a query may select in all.
+	maxFields = 1000
+)
+
+// Field is a field of a selection set, with its arguments and the selection of its value.
+type Field struct {
+	Alias     string
+	Name      string
+	Arguments map[string]interface{}
+	Selection []Field
+}
+
+// key returns the key of the field in the result.
+func (f Field) key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Parse parses a query document into the selection set of its operation, resolving variables from vars. It accepts
+// a subset of GraphQL, a single query: fragments, directives, mutations, subscriptions and the introspection fields
+// starting with __ other than __typename are rejected, and the types of variables are not checked. Queries nested deeper than 16 levels,
+// or selecting more than 1000 fields, are rejected.
+func Parse(query string, vars map[string]interface{}) ([]Field, error) {
+	p := &parser{input: query, vars: vars}
+	p.skip()
+	if p.peekName() {
+		switch op := p.name(); op {
+		case "query":
+		case "mutation", "subscription":
+			return nil, p.errorf("%s operations are not supported", op)
+		case "fragment":
+			return nil, p.errorf("fragments are not supported")
+		default:
+			return nil, p.errorf("unexpected %q", op)
+		}
+		if p.peekName() {
+			p.name()
+		}
+		if p.peek('(') {
+			if err := p.variableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fields, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, p.errorf("only a single operation is supported, without fragments")
+	}
+	return fields, nil
+}
+
+type parser struct {
+	input  string
+	pos    int
+	vars   map[string]interface{}
+	depth  int
+	fields int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace, commas and comments, which are insignificant in GraphQL.
+func (p *parser) skip() {
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '#':
+			for p.pos < len(p.input) && p.input[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) peek(c byte) bool {
+	return p.pos < len(p.input) && p.input[p.pos] == c
+}
+
+// enter descends into a nested selection set or value, which leave must be called after.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return p.errorf("query is nested deeper than %d levels", maxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) expect(c byte) error {
+	if !p.peek(c) {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	p.skip()
+	return nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isName(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9'
+}
+
+func (p *parser) peekName() bool {
+	return p.pos < len(p.input) && isNameStart(p.input[p.pos])
+}
+
+func (p *parser) name() string {
+	start := p.pos
+	for p.pos < len(p.input) && isName(p.input[p.pos]) {
+		p.pos++
+	}
+	name := p.input[start:p.pos]
+	p.skip()
+	return name
+}
+
+// variableDefinitions skips the definitions of the variables of an operation, applying their defaults.
+func (p *parser) variableDefinitions() error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+	for !p.peek(')') {
+		if err := p.expect('$'); err != nil {
+			return err
+		}
+		if !p.peekName() {
+			return p.errorf("expected a variable name")
+		}
+		name := p.name()
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+		for p.peek('[') || p.peek(']') || p.peek('!') || p.peekName() {
+			if p.peekName() {
+				p.name()
+			} else {
+				p.pos++
+				p.skip()
+			}
+		}
+		if p.peek('=') {
+			p.pos++
+			p.skip()
+			def, err := p.value()
+			if err != nil {
+				return err
+			}
+			if _, ok := p.vars[name]; !ok {
+				if p.vars == nil {
+					p.vars = map[string]interface{}{}
+				}
+				p.vars[name] = def
+			}
+		}
+		if p.pos >= len(p.input) {
+			return p.errorf("unterminated variable definitions")
+		}
+	}
+	return p.expect(')')
+}
+
+func (p *parser) selectionSet() ([]Field, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	var fields []Field
+	for !p.peek('}') {
+		if p.pos >= len(p.input) {
+			return nil, p.errorf("unterminated selection set")
+		}
+		if p.peek('.') {
+			return nil, p.errorf("fragments are not supported")
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, p.expect('}')
+}
+
+func (p *parser) field() (Field, error) {
+	if !p.peekName() {
+		return Field{}, p.errorf("expected a field name")
+	}
+	p.fields++
+	if p.fields > maxFields {
+		return Field{}, p.errorf("query selects more than %d fields", maxFields)
+	}
+	field := Field{Name: p.name()}
+	if p.peek(':') {
+		p.pos++
+		p.skip()
+		if !p.peekName() {
+			return Field{}, p.errorf("expected a field name")
+		}
+		field.Alias, field.Name = field.Name, p.name()
+	}
+	if strings.HasPrefix(field.Name, "__") && field.Name != "__typename" {
+		return Field{}, p.errorf("introspection is not supported, GET the endpoint without a query for the schema")
+	}
+	if p.peek('(') {
+		args, err := p.arguments()
+		if err != nil {
+			return Field{}, err
+		}
+		field.Arguments = args
+	}
+	if p.peek('@') {
+		return Field{}, p.errorf("directives are not supported")
+	}
+	if p.peek('{') {
+		selection, err := p.selectionSet()
+		if err != nil {
+			return Field{}, err
+		}
+		field.Selection = selection
+	}
+	return field, nil
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{}
+	for !p.peek(')') {
+		if !p.peekName() {
+			return nil, p.errorf("expected an argument name")
+		}
+		name := p.name()
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+	return args, p.expect(')')
+}
+
+func (p *parser) value() (interface{}, error) {
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("expected a value")
+	}
+	switch c := p.input[p.pos]; {
+	case c == '$':
+		p.pos++
+		if !p.peekName() {
+			return nil, p.errorf("expected a variable name")
+		}
+		return p.vars[p.name()], nil
+	case c == '"':
+		return p.string()
+	case c == '[':
+		p.pos++
+		p.skip()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		list := []interface{}{}
+		for !p.peek(']') {
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, p.expect(']')
+	case c == '{':
+		p.pos++
+		p.skip()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		obj := map[string]interface{}{}
+		for !p.peek('}') {
+			if !p.peekName() {
+				return nil, p.errorf("expected a field name")
+			}
+			name := p.name()
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = value
+		}
+		return obj, p.expect('}')
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	case isNameStart(c):
+		switch name := p.name(); name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			// enum values are passed as strings
+			return name, nil
+		}
+	}
+	return nil, p.errorf("unexpected %q", p.input[p.pos])
+}
+
+func (p *parser) number() (interface{}, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.input) && strings.IndexByte("0123456789.eE+-", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	text := p.input[start:p.pos]
+	p.skip()
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", text)
+	}
+	return f, nil
+}
+
+func (p *parser) string() (string, error) {
+	p.pos++
+	var buf strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			p.skip()
+			return buf.String(), nil
+		case '\\':
+			if p.pos+1 >= len(p.input) {
+				return "", p.errorf("unterminated string")
+			}
+			p.pos++
+			switch e := p.input[p.pos]; e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case 'u':
+				if p.pos+4 >= len(p.input) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.input[p.pos+1:p.pos+5], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape")
+				}
+				buf.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				buf.WriteByte(e)
+			}
+		case '\n':
+			return "", p.errorf("unterminated string")
+		default:
+			buf.WriteByte(c)
+		}
+		p.pos++
+	}
+	return "", p.errorf("unterminated string")
+}
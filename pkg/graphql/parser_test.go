@@ -0,0 +1,122 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		vars    map[string]interface{}
+		want    []Field
+		wantErr bool
+	}{
+		{
+			name:  "shorthand",
+			query: `{ pod { id } }`,
+			want:  []Field{{Name: "pod", Selection: []Field{{Name: "id"}}}},
+		},
+		{
+			name: "arguments, aliases and variables",
+			query: `query Pods($ns: String = "default", $limit: Int) {
+				# the pods of the namespace
+				pods: pod(namespace: $ns, limit: 10, labelSelector: "app=\"web\"") {
+					metadata { name }, spec { nodeName }
+				}
+			}`,
+			vars: map[string]interface{}{"limit": int64(5)},
+			want: []Field{{
+				Alias: "pods",
+				Name:  "pod",
+				Arguments: map[string]interface{}{
+					"namespace":     "default",
+					"limit":         int64(10),
+					"labelSelector": `app="web"`,
+				},
+				Selection: []Field{
+					{Name: "metadata", Selection: []Field{{Name: "name"}}},
+					{Name: "spec", Selection: []Field{{Name: "nodeName"}}},
+				},
+			}},
+		},
+		{
+			name:  "values",
+			query: `{ a(list: [1, 2.5, true, null, ENUM], obj: {key: "value"}) }`,
+			want: []Field{{Name: "a", Arguments: map[string]interface{}{
+				"list": []interface{}{int64(1), 2.5, true, nil, "ENUM"},
+				"obj":  map[string]interface{}{"key": "value"},
+			}}},
+		},
+		{
+			name:    "mutation",
+			query:   `mutation { pod { id } }`,
+			wantErr: true,
+		},
+		{
+			name:    "fragment",
+			query:   `{ pod { ...fields } }`,
+			wantErr: true,
+		},
+		{
+			name:    "fragment definition",
+			query:   `query { pod { id } } fragment fields on pod { id }`,
+			wantErr: true,
+		},
+		{
+			name:    "directive",
+			query:   `{ pod @include(if: true) { id } }`,
+			wantErr: true,
+		},
+		{
+			name:    "introspection",
+			query:   `{ __schema { types { name } } }`,
+			wantErr: true,
+		},
+		{
+			name:    "type introspection",
+			query:   `{ __type(name: "pod") { name } }`,
+			wantErr: true,
+		},
+		{
+			name:  "typename",
+			query: `{ pod { __typename id } }`,
+			want:  []Field{{Name: "pod", Selection: []Field{{Name: "__typename"}, {Name: "id"}}}},
+		},
+		{
+			name:    "unterminated",
+			query:   `{ pod { id }`,
+			wantErr: true,
+		},
+		{
+			name:    "nested too deep",
+			query:   strings.Repeat("{ a ", maxDepth+1) + strings.Repeat("}", maxDepth+1),
+			wantErr: true,
+		},
+		{
+			name:    "value nested too deep",
+			query:   "{ a(list: " + strings.Repeat("[", maxDepth+1) + strings.Repeat("]", maxDepth+1) + ") }",
+			wantErr: true,
+		},
+		{
+			name:    "too many fields",
+			query:   "{ pod { " + strings.Repeat("id ", maxFields) + "} }",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := Parse(test.query, test.vars)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, fields)
+		})
+	}
+}
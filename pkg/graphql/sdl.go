@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/definition"
+)
+
+// SDL returns the GraphQL schema of the schemas in the schema definition language. Every queryable schema is a
+// field of Query, and every schema is an object type named after its ID with the characters GraphQL does not
+// allow replaced by underscores. Maps and untyped values are of the JSON scalar.
+func SDL(apiSchemas *types.APISchemas) string {
+	queryable := queryableSchemas(apiSchemas)
+	names := make([]string, 0, len(queryable))
+	for name := range queryable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &strings.Builder{}
+	buf.WriteString("scalar JSON\n\ntype Query {\n")
+	for _, name := range names {
+		buf.WriteString("  " + name + "(namespace: String, name: String, labelSelector: String, fieldSelector: String, limit: Int): [" + name + "]\n")
+	}
+	buf.WriteString("}\n")
+
+	ids := make([]string, 0, len(apiSchemas.Schemas))
+	for id := range apiSchemas.Schemas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		name := typeName(id)
+		if !validName(name) {
+			continue
+		}
+		schema := apiSchemas.Schemas[id]
+		_, resource := queryable[name]
+		writeType(buf, apiSchemas, name, schema, resource)
+	}
+	return buf.String()
+}
+
+func writeType(buf *strings.Builder, apiSchemas *types.APISchemas, name string, schema *types.APISchema, resource bool) {
+	fields := make([]string, 0, len(schema.ResourceFields))
+	for field := range schema.ResourceFields {
+		if validName(field) && !(resource && field == "related") {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	buf.WriteString("\ntype " + name + " {\n")
+	if resource {
+		for _, field := range []string{"id", "type"} {
+			if _, ok := schema.ResourceFields[field]; !ok {
+				buf.WriteString("  " + field + ": String\n")
+			}
+		}
+	}
+	for _, field := range fields {
+		buf.WriteString("  " + field + ": " + fieldType(apiSchemas, schema.ResourceFields[field].Type) + "\n")
+	}
+	if resource {
+		buf.WriteString("  related(type: String!, rel: String): [JSON]\n")
+	}
+	if len(fields) == 0 && !resource {
+		// object types must have a field
+		buf.WriteString("  _: JSON\n")
+	}
+	buf.WriteString("}\n")
+}
+
+// fieldType returns the GraphQL type of a steve field type.
+func fieldType(apiSchemas *types.APISchemas, t string) string {
+	switch {
+	case definition.IsArrayType(t):
+		return "[" + fieldType(apiSchemas, definition.SubType(t)) + "]"
+	case definition.IsMapType(t):
+		return "JSON"
+	case definition.IsReferenceType(t):
+		return "String"
+	}
+	switch t {
+	case "string", "enum", "dnsLabel", "hostname", "password", "date":
+		return "String"
+	case "int":
+		return "Int"
+	case "float":
+		return "Float"
+	case "boolean":
+		return "Boolean"
+	}
+	if schema := apiSchemas.LookupSchema(t); schema != nil {
+		if name := typeName(schema.ID); validName(name) {
+			return name
+		}
+	}
+	return "JSON"
+}
+
+// validName returns whether a name is a GraphQL name not reserved for introspection.
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isName(name[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const resultLabel = "result"
+
+var (
+	ClientCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "client_cache",
+			Name:      "requests",
+			Help:      "Total count of client lookups, by whether the client was cached",
+		},
+		[]string{resultLabel})
+	ClientCacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "client_cache",
+			Name:      "evictions",
+			Help:      "Total count of clients evicted from the cache to make room for another",
+		})
+	ClientCacheSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "client_cache",
+			Name:      "size",
+			Help:      "Number of clients in the cache",
+		})
+)
+
+func IncClientCacheRequests(hit bool) {
+	if prometheusMetrics {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		ClientCacheRequests.With(prometheus.Labels{resultLabel: result}).Inc()
+	}
+}
+
+func IncClientCacheEvictions() {
+	if prometheusMetrics {
+		ClientCacheEvictions.Inc()
+	}
+}
+
+func SetClientCacheSize(size int) {
+	if prometheusMetrics {
+		ClientCacheSize.Set(float64(size))
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	gvkLabel    = "gvk"
+	reasonLabel = "reason"
+)
+
+var (
+	ClusterCacheObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "cluster_cache",
+			Name:      "objects",
+			Help:      "Number of objects in the cluster cache, by type",
+		},
+		[]string{gvkLabel})
+	ClusterCacheBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "cluster_cache",
+			Name:      "estimated_bytes",
+			Help:      "Estimated memory of the objects in the cluster cache, by type",
+		},
+		[]string{gvkLabel})
+	ClusterCacheEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "cluster_cache",
+			Name:      "evictions",
+			Help:      "Total count of types evicted from the cluster cache, by whether they exceeded the object cap or the memory budget",
+		},
+		[]string{gvkLabel, reasonLabel})
+)
+
+func SetClusterCacheSize(gvk string, objects, bytes int64) {
+	if prometheusMetrics {
+		ClusterCacheObjects.With(prometheus.Labels{gvkLabel: gvk}).Set(float64(objects))
+		ClusterCacheBytes.With(prometheus.Labels{gvkLabel: gvk}).Set(float64(bytes))
+	}
+}
+
+func DeleteClusterCacheSize(gvk string) {
+	if prometheusMetrics {
+		ClusterCacheObjects.Delete(prometheus.Labels{gvkLabel: gvk})
+		ClusterCacheBytes.Delete(prometheus.Labels{gvkLabel: gvk})
+	}
+}
+
+func IncClusterCacheEvictions(gvk, reason string) {
+	if prometheusMetrics {
+		ClusterCacheEvictions.With(prometheus.Labels{gvkLabel: gvk, reasonLabel: reason}).Inc()
+	}
+}
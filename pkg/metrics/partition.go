@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	PartitionListTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "partition_lister",
+			Name:      "partition_list_time",
+			Help:      "List times in ms for a single partition",
+		},
+		[]string{resourceLabel})
+	PartitionSemaphoreWaitTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "partition_lister",
+			Name:      "semaphore_wait_time",
+			Help:      "Time in ms spent waiting for a concurrency slot before listing a partition",
+		},
+		[]string{resourceLabel})
+	PartitionsPerRequest = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "partition_lister",
+			Name:      "partitions_per_request",
+			Help:      "Number of partitions a list request spans",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		},
+		[]string{resourceLabel})
+	PartitionTruncatedLists = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "partition_lister",
+			Name:      "truncated_lists",
+			Help:      "Total count of lists truncated at the limit and returned with a continue token",
+		},
+		[]string{resourceLabel})
+	PartitionContinueRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "partition_lister",
+			Name:      "continue_requests",
+			Help:      "Total count of list requests resumed from a continue token",
+		},
+		[]string{resourceLabel})
+)
+
+// Handler returns the handler which serves the registered metrics, or nil if metrics are disabled.
+func Handler() http.Handler {
+	if !prometheusMetrics {
+		return nil
+	}
+	return promhttp.Handler()
+}
+
+func (m MetricLogger) RecordPartitionListTime(val float64) {
+	if prometheusMetrics {
+		PartitionListTime.With(prometheus.Labels{resourceLabel: m.Resource}).Observe(val)
+	}
+}
+
+func (m MetricLogger) RecordPartitionSemaphoreWaitTime(val float64) {
+	if prometheusMetrics {
+		PartitionSemaphoreWaitTime.With(prometheus.Labels{resourceLabel: m.Resource}).Observe(val)
+	}
+}
+
+func (m MetricLogger) RecordPartitionsPerRequest(val int) {
+	if prometheusMetrics {
+		PartitionsPerRequest.With(prometheus.Labels{resourceLabel: m.Resource}).Observe(float64(val))
+	}
+}
+
+func (m MetricLogger) IncPartitionTruncatedLists() {
+	if prometheusMetrics {
+		PartitionTruncatedLists.With(prometheus.Labels{resourceLabel: m.Resource}).Inc()
+	}
+}
+
+func (m MetricLogger) IncPartitionContinueRequests() {
+	if prometheusMetrics {
+		PartitionContinueRequests.With(prometheus.Labels{resourceLabel: m.Resource}).Inc()
+	}
+}
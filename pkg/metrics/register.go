@@ -14,5 +14,18 @@ func init() {
 		prometheus.MustRegister(ProxyTotalResponses)
 		prometheus.MustRegister(K8sClientResponseTime)
 		prometheus.MustRegister(ProxyStoreResponseTime)
+		prometheus.MustRegister(PartitionListTime)
+		prometheus.MustRegister(PartitionSemaphoreWaitTime)
+		prometheus.MustRegister(PartitionsPerRequest)
+		prometheus.MustRegister(PartitionTruncatedLists)
+		prometheus.MustRegister(PartitionContinueRequests)
+		prometheus.MustRegister(ClientCacheRequests)
+		prometheus.MustRegister(ClientCacheEvictions)
+		prometheus.MustRegister(ClientCacheSize)
+		prometheus.MustRegister(ClusterCacheObjects)
+		prometheus.MustRegister(ClusterCacheBytes)
+		prometheus.MustRegister(ClusterCacheEvictions)
+		prometheus.MustRegister(SchemaRecomputeTime)
+		prometheus.MustRegister(SchemasRecomputed)
 	}
 }
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const modeLabel = "mode"
+
+var (
+	SchemaRecomputeTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "schema",
+			Name:      "recompute_time",
+			Help:      "Time in ms to compute the schemas of a user, by whether they were computed in full or from the delta of a previous access set",
+		},
+		[]string{modeLabel})
+	SchemasRecomputed = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "schema",
+			Name:      "schemas_recomputed",
+			Help:      "Number of schemas whose access was computed for a user, by whether they were computed in full or from the delta of a previous access set",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+		},
+		[]string{modeLabel})
+)
+
+func RecordSchemaRecompute(mode string, millis float64, schemas int) {
+	if prometheusMetrics {
+		SchemaRecomputeTime.With(prometheus.Labels{modeLabel: mode}).Observe(millis)
+		SchemasRecomputed.With(prometheus.Labels{modeLabel: mode}).Observe(float64(schemas))
+	}
+}
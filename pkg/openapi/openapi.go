@@ -0,0 +1,379 @@
+// Package openapi renders the steve schemas visible to a user as an OpenAPI v3 document, describing the /v1 API so
+// client generators and other tooling can consume it.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/definition"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	apiVersion     = "v1"
+	refPrefix      = "#/components/schemas/"
+	errorSchemaID  = "error"
+)
+
+// Handler returns a handler which writes the document of the schemas of each request.
+func Handler(schemasFor func(rw http.ResponseWriter, req *http.Request) (*types.APISchemas, bool)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiSchemas, ok := schemasFor(rw, req)
+		if !ok {
+			return
+		}
+		data, err := json.Marshal(Document(apiSchemas))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(data)
+	})
+}
+
+// Document returns the OpenAPI v3 document of the schemas. Every schema is a component; the schemas with methods
+// also get the paths of their collection and resources, limited to the methods the user is allowed. Actions are
+// POSTs with the action query parameter, as steve expects them.
+func Document(apiSchemas *types.APISchemas) map[string]interface{} {
+	ids := make([]string, 0, len(apiSchemas.Schemas))
+	for id := range apiSchemas.Schemas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	components := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for _, id := range ids {
+		schema := apiSchemas.Schemas[id]
+		components[id] = component(apiSchemas, schema)
+		addPaths(apiSchemas, schema, paths)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "steve",
+			"version": apiVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": components,
+		},
+	}
+}
+
+// component returns the OpenAPI schema of a steve schema, with its methods and kubernetes type as extensions.
+func component(apiSchemas *types.APISchemas, schema *types.APISchema) map[string]interface{} {
+	names := make([]string, 0, len(schema.ResourceFields))
+	for name := range schema.ResourceFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties := map[string]interface{}{}
+	var required []string
+	for _, name := range names {
+		field := schema.ResourceFields[name]
+		properties[name] = property(apiSchemas, field)
+		if field.Required {
+			required = append(required, name)
+		}
+	}
+
+	result := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		result["required"] = required
+	}
+	if schema.Description != "" {
+		result["description"] = schema.Description
+	}
+	if methods := allowed(schema.ResourceMethods); len(methods) > 0 {
+		result["x-steve-resource-methods"] = methods
+	}
+	if methods := allowed(schema.CollectionMethods); len(methods) > 0 {
+		result["x-steve-collection-methods"] = methods
+	}
+	if gvk := attributes.GVK(schema); gvk.Kind != "" {
+		result["x-kubernetes-group-version-kind"] = []interface{}{
+			map[string]interface{}{
+				"group":   gvk.Group,
+				"version": gvk.Version,
+				"kind":    gvk.Kind,
+			},
+		}
+	}
+	return result
+}
+
+// property returns the OpenAPI schema of a field, read only unless it may be created or updated.
+func property(apiSchemas *types.APISchemas, field schemas.Field) map[string]interface{} {
+	result := typeSchema(apiSchemas, field.Type)
+	if _, ok := result["$ref"]; ok && (field.Description != "" || field.Default != nil || !field.Create || !field.Update) {
+		// siblings of $ref are ignored in OpenAPI 3.0, so the reference is wrapped
+		result = map[string]interface{}{"allOf": []interface{}{result}}
+	}
+	if field.Description != "" {
+		result["description"] = field.Description
+	}
+	if field.Default != nil {
+		result["default"] = field.Default
+	}
+	if field.Nullable {
+		result["nullable"] = true
+	}
+	if len(field.Options) > 0 {
+		result["enum"] = field.Options
+	}
+	switch {
+	case !field.Create && !field.Update:
+		result["readOnly"] = true
+	case !field.Update:
+		result["x-steve-immutable"] = true
+	}
+	if field.WriteOnly {
+		result["writeOnly"] = true
+	}
+	return result
+}
+
+// typeSchema returns the OpenAPI schema of a steve field type such as string, array[int] or map[<schema ID>].
+func typeSchema(apiSchemas *types.APISchemas, fieldType string) map[string]interface{} {
+	switch {
+	case definition.IsArrayType(fieldType):
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(apiSchemas, definition.SubType(fieldType)),
+		}
+	case definition.IsMapType(fieldType):
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(apiSchemas, definition.SubType(fieldType)),
+		}
+	case definition.IsReferenceType(fieldType):
+		return map[string]interface{}{
+			"type":        "string",
+			"description": "ID of a " + definition.SubType(fieldType),
+		}
+	}
+
+	switch fieldType {
+	case "string", "enum", "dnsLabel", "hostname":
+		return map[string]interface{}{"type": "string"}
+	case "password":
+		return map[string]interface{}{"type": "string", "format": "password"}
+	case "date":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "int":
+		return map[string]interface{}{"type": "integer"}
+	case "float":
+		return map[string]interface{}{"type": "number"}
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}
+	}
+
+	if schema := apiSchemas.LookupSchema(fieldType); schema != nil {
+		return ref(schema.ID)
+	}
+	// json and unknown types may be anything
+	return map[string]interface{}{}
+}
+
+func ref(id string) map[string]interface{} {
+	return map[string]interface{}{"$ref": refPrefix + id}
+}
+
+// allowed returns the methods the user may call, dropping the ones blocked on the schema.
+func allowed(methods []string) []string {
+	var result []string
+	for _, method := range methods {
+		if !strings.HasPrefix(method, "blocked-") {
+			result = append(result, method)
+		}
+	}
+	return result
+}
+
+// addPaths adds the collection and resource paths of a schema with methods.
+func addPaths(apiSchemas *types.APISchemas, schema *types.APISchema, paths map[string]interface{}) {
+	id := schema.ID
+	hasError := apiSchemas.LookupSchema(errorSchemaID) != nil
+
+	collection := map[string]interface{}{}
+	for _, method := range allowed(schema.CollectionMethods) {
+		switch method {
+		case http.MethodGet:
+			collection["get"] = operation(id, "list", schema, nil, listResponse(id), hasError)
+		case http.MethodPost:
+			op := operation(id, "create", schema, ref(id), ref(id), hasError)
+			if len(schema.CollectionActions) > 0 {
+				op["parameters"] = append(op["parameters"].([]interface{}), actionParameter(schema.CollectionActions, false))
+			}
+			collection["post"] = op
+		case http.MethodDelete:
+			collection["delete"] = operation(id, "deleteCollection", schema, nil, nil, hasError)
+		}
+	}
+	if len(collection) > 0 {
+		paths["/v1/"+id] = collection
+	}
+
+	resource := map[string]interface{}{}
+	for _, method := range allowed(schema.ResourceMethods) {
+		switch method {
+		case http.MethodGet:
+			resource["get"] = operation(id, "get", schema, nil, ref(id), hasError)
+		case http.MethodPut:
+			resource["put"] = operation(id, "update", schema, ref(id), ref(id), hasError)
+		case http.MethodPatch:
+			resource["patch"] = operation(id, "patch", schema, map[string]interface{}{"type": "object"}, ref(id), hasError)
+		case http.MethodDelete:
+			resource["delete"] = operation(id, "delete", schema, nil, ref(id), hasError)
+		}
+	}
+	if len(schema.ResourceActions) > 0 {
+		op := operation(id, "action", schema, actionInput(apiSchemas, schema.ResourceActions), nil, hasError)
+		op["parameters"] = append(op["parameters"].([]interface{}), actionParameter(schema.ResourceActions, true))
+		resource["post"] = op
+	}
+	if len(resource) == 0 {
+		return
+	}
+	path := "/v1/" + id + "/{name}"
+	parameters := []interface{}{pathParameter("name")}
+	if attributes.Namespaced(schema) {
+		path = "/v1/" + id + "/{namespace}/{name}"
+		parameters = []interface{}{pathParameter("namespace"), pathParameter("name")}
+	}
+	resource["parameters"] = parameters
+	paths[path] = resource
+}
+
+// operation returns an operation on a schema, with request and response bodies if they are set.
+func operation(id, verb string, schema *types.APISchema, request, response map[string]interface{}, hasError bool) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": verb + ":" + id,
+		"tags":        []interface{}{id},
+		"parameters":  []interface{}{},
+	}
+	if schema.Description != "" && (verb == "list" || verb == "get") {
+		op["description"] = schema.Description
+	}
+	if request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  content(request),
+		}
+	}
+	code := "200"
+	if verb == "create" {
+		code = "201"
+	}
+	ok := map[string]interface{}{"description": "OK"}
+	if response != nil {
+		ok["content"] = content(response)
+	}
+	responses := map[string]interface{}{code: ok}
+	if hasError {
+		responses["default"] = map[string]interface{}{
+			"description": "Error",
+			"content":     content(ref(errorSchemaID)),
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func content(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schema,
+		},
+	}
+}
+
+func listResponse(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"type":     map[string]interface{}{"type": "string"},
+			"revision": map[string]interface{}{"type": "string"},
+			"continue": map[string]interface{}{"type": "string"},
+			"data": map[string]interface{}{
+				"type":  "array",
+				"items": ref(id),
+			},
+		},
+	}
+}
+
+func pathParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     name,
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+}
+
+func queryParameter(name, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+// actionParameter returns the action query parameter, whose values are the names of the actions.
+func actionParameter(actions map[string]schemas.Action, required bool) map[string]interface{} {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	p := queryParameter("action", "Action to run")
+	p["required"] = required
+	p["schema"] = map[string]interface{}{
+		"type": "string",
+		"enum": names,
+	}
+	return p
+}
+
+// actionInput returns the request body of actions: the input of the action, one of the inputs if the actions
+// differ, or any object if none declares an input.
+func actionInput(apiSchemas *types.APISchemas, actions map[string]schemas.Action) map[string]interface{} {
+	inputs := map[string]bool{}
+	for _, action := range actions {
+		if action.Input != "" && apiSchemas.LookupSchema(action.Input) != nil {
+			inputs[action.Input] = true
+		}
+	}
+	if len(inputs) == 0 {
+		return map[string]interface{}{"type": "object"}
+	}
+	ids := make([]string, 0, len(inputs))
+	for id := range inputs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if len(ids) == 1 {
+		return ref(ids[0])
+	}
+	var oneOf []interface{}
+	for _, id := range ids {
+		oneOf = append(oneOf, ref(id))
+	}
+	return map[string]interface{}{"oneOf": oneOf}
+}
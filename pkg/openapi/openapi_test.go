@@ -0,0 +1,113 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	widget := types.APISchema{Schema: &schemas.Schema{
+		ID:                "widget",
+		CollectionMethods: []string{http.MethodGet, "blocked-" + http.MethodPost},
+		ResourceMethods:   []string{http.MethodGet, http.MethodDelete},
+		ResourceFields: map[string]schemas.Field{
+			"size":  {Type: "int", Required: true, Create: true, Update: true},
+			"tags":  {Type: "array[string]", Create: true},
+			"owner": {Type: "owner"},
+		},
+		ResourceActions: map[string]schemas.Action{
+			"resize": {Input: "owner"},
+		},
+	}}
+	attributes.SetNamespaced(&widget, true)
+	require.NoError(t, apiSchemas.AddSchema(widget))
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{Schema: &schemas.Schema{
+		ID: "owner",
+		ResourceFields: map[string]schemas.Field{
+			"name": {Type: "string"},
+		},
+	}}))
+
+	doc := Document(apiSchemas)
+	components := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Contains(t, components, "owner")
+	component := components["widget"].(map[string]interface{})
+	assert.Equal(t, []string{"size"}, component["required"])
+	assert.Equal(t, []string{http.MethodGet}, component["x-steve-collection-methods"])
+	properties := component["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, properties["size"])
+	assert.Equal(t, map[string]interface{}{
+		"type":              "array",
+		"items":             map[string]interface{}{"type": "string"},
+		"x-steve-immutable": true,
+	}, properties["tags"])
+	assert.Equal(t, map[string]interface{}{
+		"allOf":    []interface{}{map[string]interface{}{"$ref": "#/components/schemas/owner"}},
+		"readOnly": true,
+	}, properties["owner"])
+
+	paths := doc["paths"].(map[string]interface{})
+	assert.Len(t, paths, 2)
+	collection := paths["/v1/widget"].(map[string]interface{})
+	assert.Contains(t, collection, "get")
+	assert.NotContains(t, collection, "post")
+	resource := paths["/v1/widget/{namespace}/{name}"].(map[string]interface{})
+	assert.Contains(t, resource, "get")
+	assert.Contains(t, resource, "delete")
+	action := resource["post"].(map[string]interface{})
+	assert.Equal(t, "action:widget", action["operationId"])
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultSize interface{}
+		wantCode    int
+		wantType    string
+	}{
+		{
+			name:        "document",
+			defaultSize: 1,
+			wantCode:    http.StatusOK,
+			wantType:    "application/json",
+		},
+		{
+			name:        "encoding error",
+			defaultSize: func() {},
+			wantCode:    http.StatusInternalServerError,
+			wantType:    "text/plain; charset=utf-8",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			apiSchemas := types.EmptyAPISchemas()
+			require.NoError(t, apiSchemas.AddSchema(types.APISchema{Schema: &schemas.Schema{
+				ID: "widget",
+				ResourceFields: map[string]schemas.Field{
+					"size": {Type: "int", Default: test.defaultSize},
+				},
+			}}))
+			handler := Handler(func(rw http.ResponseWriter, req *http.Request) (*types.APISchemas, bool) {
+				return apiSchemas, true
+			})
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/openapi", nil))
+			assert.Equal(t, test.wantCode, rw.Code)
+			assert.Equal(t, test.wantType, rw.Header().Get("Content-Type"))
+			if test.wantCode == http.StatusOK {
+				assert.True(t, json.Valid(rw.Body.Bytes()))
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package access serves the access of the caller, so clients can tell what they may do without making requests
+// that are denied.
+package access
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const selfID = "self"
+
+// AccessControl is the access of a user, as /v1/accesscontrol/self.
+type AccessControl struct {
+	ID     string   `json:"id,omitempty"`
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	Rules  []Rule   `json:"rules"`
+}
+
+// Rule grants verbs on the objects of a resource in a namespace, "*" for every namespace, with the given names,
+// "*" for every name.
+type Rule struct {
+	APIGroup      string   `json:"apiGroup"`
+	Resource      string   `json:"resource"`
+	Namespace     string   `json:"namespace"`
+	ResourceNames []string `json:"resourceNames"`
+	Verbs         []string `json:"verbs"`
+}
+
+// Register adds the accesscontrol schema, whose only object, self, is the access of the caller.
+func Register(schemas *types.APISchemas) {
+	schemas.InternalSchemas.TypeName("accesscontrol", AccessControl{})
+	schemas.MustImportAndCustomize(AccessControl{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		schema.Store = &Store{}
+	})
+}
+
+// Store serves the access set of the schemas of the request.
+type Store struct {
+	empty.Store
+}
+
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if id != selfID {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no such accesscontrol "+id)
+	}
+	return self(apiOp), nil
+}
+
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	return types.APIObjectList{
+		Objects: []types.APIObject{self(apiOp)},
+	}, nil
+}
+
+func self(apiOp *types.APIRequest) types.APIObject {
+	result := AccessControl{
+		ID: selfID,
+	}
+	if user, ok := request.UserFrom(apiOp.Context()); ok {
+		result.User = user.GetName()
+		result.Groups = user.GetGroups()
+	}
+	if access, ok := apiOp.Schemas.Attributes["accessSet"].(*accesscontrol.AccessSet); ok && access != nil {
+		result.Rules = rules(access)
+	}
+	if result.Rules == nil {
+		result.Rules = []Rule{}
+	}
+	return types.APIObject{
+		Type:   "accesscontrol",
+		ID:     selfID,
+		Object: result,
+	}
+}
+
+type objectKey struct {
+	gr        schema.GroupResource
+	namespace string
+	name      string
+}
+
+type ruleKey struct {
+	gr        schema.GroupResource
+	namespace string
+	verbs     string
+}
+
+// rules returns the sorted rules of an access set, with the names of a resource and namespace that have the same
+// verbs in one rule.
+func rules(access *accesscontrol.AccessSet) []Rule {
+	verbs := map[objectKey][]string{}
+	access.Each(func(verb string, gr schema.GroupResource, access accesscontrol.Access) {
+		k := objectKey{gr: gr, namespace: access.Namespace, name: access.ResourceName}
+		verbs[k] = append(verbs[k], verb)
+	})
+
+	byRule := map[ruleKey]*Rule{}
+	for k, v := range verbs {
+		sort.Strings(v)
+		rk := ruleKey{gr: k.gr, namespace: k.namespace, verbs: strings.Join(v, ",")}
+		rule, ok := byRule[rk]
+		if !ok {
+			rule = &Rule{
+				APIGroup:  k.gr.Group,
+				Resource:  k.gr.Resource,
+				Namespace: k.namespace,
+				Verbs:     v,
+			}
+			byRule[rk] = rule
+		}
+		rule.ResourceNames = append(rule.ResourceNames, k.name)
+	}
+
+	result := make([]Rule, 0, len(byRule))
+	for _, rule := range byRule {
+		sort.Strings(rule.ResourceNames)
+		result = append(result, *rule)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return strings.Join(a.Verbs, ",") < strings.Join(b.Verbs, ",")
+	})
+	return result
+}
@@ -0,0 +1,26 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRules(t *testing.T) {
+	access := &accesscontrol.AccessSet{}
+	pods := schema.GroupResource{Resource: "pods"}
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	access.Add("get", pods, accesscontrol.Access{Namespace: "*", ResourceName: "*"})
+	access.Add("list", pods, accesscontrol.Access{Namespace: "*", ResourceName: "*"})
+	access.Add("delete", pods, accesscontrol.Access{Namespace: "dev", ResourceName: "web"})
+	access.Add("delete", pods, accesscontrol.Access{Namespace: "dev", ResourceName: "db"})
+	access.Add("update", deployments, accesscontrol.Access{Namespace: "dev", ResourceName: "*"})
+
+	assert.Equal(t, []Rule{
+		{Resource: "pods", Namespace: "*", ResourceNames: []string{"*"}, Verbs: []string{"get", "list"}},
+		{Resource: "pods", Namespace: "dev", ResourceNames: []string{"db", "web"}, Verbs: []string{"delete"}},
+		{APIGroup: "apps", Resource: "deployments", Namespace: "dev", ResourceNames: []string{"*"}, Verbs: []string{"update"}},
+	}, rules(access))
+}
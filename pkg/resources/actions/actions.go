@@ -0,0 +1,168 @@
+// Package actions serves custom actions on the objects of schemas, such as ?action=redeploy on deployments, with
+// the permissions each action needs checked against the access of the user before it runs.
+//
+// For example, to suspend cronjobs:
+//
+//	registry.Add("batch.cronjob", "suspend", actions.Action{
+//		Permissions: []actions.Permission{{Verb: "patch"}},
+//		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+//			...
+//		},
+//	})
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Permission is a verb the user needs on a resource to perform an action.
+type Permission struct {
+	Verb string
+	// Resource is the resource the verb is needed on, the resource of the schema if empty. The verb is checked for
+	// the object the action is performed on in the resource of the schema and its subresources, such as
+	// pods/ephemeralcontainers, and for every object of other resources.
+	Resource schema2.GroupResource
+	// Namespace is the namespace the verb on another resource is needed in, the namespace of the object if empty,
+	// or "*" for every namespace.
+	Namespace string
+}
+
+// Handler performs an action on an object, which the user may get, returning the object of the response. The
+// input of the action, if any, is the body of the request.
+type Handler func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error)
+
+// Action is a custom action on the objects of a schema.
+type Action struct {
+	// Permissions are checked before the handler runs, and the action is denied unless the user has all of them.
+	Permissions []Permission
+	// Input and Output are the IDs of the schemas of the input and output of the action, if any.
+	Input  string
+	Output string
+	// Handler performs the action.
+	Handler Handler
+}
+
+// Registry is the actions of each schema. Actions added after the schemas are built apply once the schemas are
+// next refreshed.
+type Registry struct {
+	lock    sync.RWMutex
+	actions map[string]map[string]Action
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		actions: map[string]map[string]Action{},
+	}
+}
+
+// Add registers an action of the objects of the schema, replacing any action of the same name.
+func (r *Registry) Add(schemaID, name string, action Action) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.actions[schemaID] == nil {
+		r.actions[schemaID] = map[string]Action{}
+	}
+	r.actions[schemaID][name] = action
+}
+
+func (r *Registry) forSchema(schemaID string) map[string]Action {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	result := make(map[string]Action, len(r.actions[schemaID]))
+	for name, action := range r.actions[schemaID] {
+		result[name] = action
+	}
+	return result
+}
+
+// Template returns the template adding the actions of the registry to their schemas.
+func Template(registry *Registry, asl accesscontrol.AccessSetLookup) schema.Template {
+	return schema.Template{
+		Customize: func(apiSchema *types.APISchema) {
+			for name, action := range registry.forSchema(apiSchema.ID) {
+				addAction(apiSchema, asl, name, action)
+			}
+		},
+	}
+}
+
+func addAction(apiSchema *types.APISchema, asl accesscontrol.AccessSetLookup, name string, action Action) {
+	if apiSchema.ActionHandlers == nil {
+		apiSchema.ActionHandlers = map[string]http.Handler{}
+	}
+	apiSchema.ActionHandlers[name] = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiOp := types.GetAPIContext(req.Context())
+		obj, err := perform(apiOp, asl, action)
+		if err != nil {
+			apiOp.WriteError(writer.FromStatus(err))
+			return
+		}
+		apiOp.WriteResponse(http.StatusOK, obj)
+	})
+
+	if apiSchema.ResourceActions == nil {
+		apiSchema.ResourceActions = map[string]schemas.Action{}
+	}
+	apiSchema.ResourceActions[name] = schemas.Action{
+		Input:  action.Input,
+		Output: action.Output,
+	}
+}
+
+func perform(apiOp *types.APIRequest, asl accesscontrol.AccessSetLookup, action Action) (types.APIObject, error) {
+	schema := apiOp.Schema
+	if schema.Store == nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no store found")
+	}
+	if err := authorize(apiOp, asl, action.Permissions); err != nil {
+		return types.APIObject{}, err
+	}
+	obj, err := schema.Store.ByID(apiOp, schema, apiOp.Name)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return action.Handler(apiOp, obj)
+}
+
+// authorize checks that the user has every permission of an action on the object of the request.
+func authorize(apiOp *types.APIRequest, asl accesscontrol.AccessSetLookup, permissions []Permission) error {
+	user, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return apierror.NewAPIError(validation.Unauthorized, "user not found")
+	}
+	access := asl.AccessFor(user)
+	for _, permission := range permissions {
+		gr, namespace, name := attributes.GR(apiOp.Schema), apiOp.Namespace, apiOp.Name
+		if !permission.Resource.Empty() && permission.Resource != gr && !isSubresource(permission.Resource, gr) {
+			gr, name = permission.Resource, accesscontrol.All
+			if permission.Namespace != "" {
+				namespace = permission.Namespace
+			}
+		} else if !permission.Resource.Empty() {
+			gr = permission.Resource
+		}
+		if !access.Grants(permission.Verb, gr, namespace, name) {
+			return apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("can not %s %s %s/%s", permission.Verb, gr, namespace, name))
+		}
+	}
+	return nil
+}
+
+func isSubresource(resource, of schema2.GroupResource) bool {
+	return resource.Group == of.Group && strings.HasPrefix(resource.Resource, of.Resource+"/")
+}
@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	runAction = "run"
+
+	// instantiateAnnotation marks the jobs created by hand from a cronjob, as kubectl create job --from does.
+	instantiateAnnotation = "cronjob.kubernetes.io/instantiate"
+	// maxJobNameLength keeps the names of jobs short enough to be the value of the job-name label of their pods.
+	maxJobNameLength = 63
+)
+
+var jobs = schema.GroupResource{Group: "batch", Resource: "jobs"}
+
+// run creates a job from the job template of a cronjob like kubectl create job --from=cronjob/<name>, with a
+// generated name and the cronjob as its owner, and returns the job.
+func run(cg ClientGetter) Action {
+	return Action{
+		Permissions: []Permission{{Verb: "create", Resource: jobs}},
+		Output:      "batch.job",
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			job, err := runCronJob(apiOp, client, apiOp.Namespace, apiOp.Name)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			jobSchema := apiOp.Schemas.LookupSchema("batch.job")
+			if jobSchema == nil || jobSchema.Store == nil {
+				return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no job schema found")
+			}
+			return jobSchema.Store.ByID(apiOp, jobSchema, job.Name)
+		},
+	}
+}
+
+func runCronJob(apiOp *types.APIRequest, client kubernetes.Interface, namespace, name string) (*batchv1.Job, error) {
+	cronJob, err := client.BatchV1().CronJobs(namespace).Get(apiOp.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{instantiateAnnotation: "manual"}
+	for k, v := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+	suffix := "-manual-" + utilrand.String(5)
+	prefix := cronJob.Name
+	if len(prefix)+len(suffix) > maxJobNameLength {
+		prefix = prefix[:maxJobNameLength-len(suffix)]
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        prefix + suffix,
+			Namespace:   namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+	return client.BatchV1().Jobs(namespace).Create(apiOp.Context(), job, metav1.CreateOptions{})
+}
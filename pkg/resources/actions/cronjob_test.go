@@ -0,0 +1,45 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunCronJob(t *testing.T) {
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default", UID: k8stypes.UID("backup")},
+		Spec: batchv1.CronJobSpec{
+			Schedule: "@daily",
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"app": "backup"},
+					Annotations: map[string]string{"team": "ops"},
+				},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(cronJob)
+	apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodPost, "/v1/batch.cronjobs/default/backup?action=run", nil)}
+
+	job, err := runCronJob(apiOp, client, "default", "backup")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(job.Name, "backup-manual-"))
+	assert.Equal(t, map[string]string{"app": "backup"}, job.Labels)
+	assert.Equal(t, map[string]string{"team": "ops", instantiateAnnotation: "manual"}, job.Annotations)
+	require.Len(t, job.OwnerReferences, 1)
+	assert.Equal(t, "CronJob", job.OwnerReferences[0].Kind)
+	assert.Equal(t, cronJob.UID, job.OwnerReferences[0].UID)
+
+	_, err = client.BatchV1().Jobs("default").Get(apiOp.Context(), job.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"encoding/json"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+const debugAction = "debug"
+
+// DebugInput is the input of the debug action of pods.
+type DebugInput struct {
+	// Image is the image of the debug container.
+	Image string `json:"image"`
+	// Name is the name of the debug container, generated if empty.
+	Name string `json:"name,omitempty"`
+	// Command and Args override the entrypoint and arguments of the image.
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// TargetContainer is the container of the pod whose process namespace the debug container shares, so its
+	// processes can be inspected.
+	TargetContainer string `json:"targetContainer,omitempty"`
+	// Interactive keeps the stdin of the debug container open with a TTY, to attach to it with the exec link.
+	Interactive bool `json:"interactive,omitempty"`
+}
+
+// debug adds an ephemeral debug container to a pod like kubectl debug, through the ephemeralcontainers
+// subresource, and returns the pod.
+func debug(cg ClientGetter) Action {
+	return Action{
+		Permissions: []Permission{{Verb: "patch", Resource: schema.GroupResource{Resource: "pods/ephemeralcontainers"}}},
+		Input:       "debugInput",
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			var input DebugInput
+			if err := json.NewDecoder(apiOp.Request.Body).Decode(&input); err != nil {
+				return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+			}
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			if _, err := addDebugContainer(apiOp, client, apiOp.Namespace, apiOp.Name, input); err != nil {
+				return types.APIObject{}, err
+			}
+			return apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+		},
+	}
+}
+
+func addDebugContainer(apiOp *types.APIRequest, client kubernetes.Interface, namespace, name string, input DebugInput) (*corev1.Pod, error) {
+	if input.Image == "" {
+		return nil, apierror.NewAPIError(validation.MissingRequired, "image is required")
+	}
+	pod, err := client.CoreV1().Pods(namespace).Get(apiOp.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, container := range pod.Spec.Containers {
+		names[container.Name] = true
+	}
+	if input.TargetContainer != "" && !names[input.TargetContainer] {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, "pod "+name+" has no container "+input.TargetContainer)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		names[container.Name] = true
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		names[container.Name] = true
+	}
+	if input.Name == "" {
+		input.Name = "debugger-" + utilrand.String(5)
+	}
+	if names[input.Name] {
+		return nil, apierror.NewAPIError(validation.Conflict, "pod "+name+" already has a container "+input.Name)
+	}
+
+	container := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     input.Name,
+			Image:                    input.Image,
+			Command:                  input.Command,
+			Args:                     input.Args,
+			Stdin:                    input.Interactive,
+			TTY:                      input.Interactive,
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+		},
+		TargetContainerName: input.TargetContainer,
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"ephemeralContainers": []corev1.EphemeralContainer{container},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Pods(namespace).Patch(apiOp.Context(), name, k8stypes.StrategicMergePatchType, patch, metav1.PatchOptions{}, "ephemeralcontainers")
+}
@@ -0,0 +1,46 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAddDebugContainer(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "web"}}},
+	}
+	client := fake.NewSimpleClientset(pod)
+	apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodPost, "/v1/pods/default/web?action=debug", nil)}
+
+	_, err := addDebugContainer(apiOp, client, "default", "web", DebugInput{})
+	assert.Error(t, err, "the image is required")
+	_, err = addDebugContainer(apiOp, client, "default", "web", DebugInput{Image: "busybox", TargetContainer: "db"})
+	assert.Error(t, err, "the target container must be a container of the pod")
+	_, err = addDebugContainer(apiOp, client, "default", "web", DebugInput{Image: "busybox", Name: "app"})
+	assert.Error(t, err, "the name must not be taken")
+
+	updated, err := addDebugContainer(apiOp, client, "default", "web", DebugInput{
+		Image:           "busybox",
+		Command:         []string{"sh"},
+		TargetContainer: "app",
+		Interactive:     true,
+	})
+	require.NoError(t, err)
+	require.Len(t, updated.Spec.EphemeralContainers, 1)
+	container := updated.Spec.EphemeralContainers[0]
+	assert.Contains(t, container.Name, "debugger-")
+	assert.Equal(t, "busybox", container.Image)
+	assert.Equal(t, []string{"sh"}, container.Command)
+	assert.Equal(t, "app", container.TargetContainerName)
+	assert.True(t, container.Stdin)
+	assert.True(t, container.TTY)
+}
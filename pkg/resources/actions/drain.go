@@ -0,0 +1,190 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	drainAction    = "drain"
+	cordonAction   = "cordon"
+	uncordonAction = "uncordon"
+
+	defaultDrainTimeout = 10 * time.Minute
+
+	// mirrorPodAnnotation marks the mirror pods of static pods, which the kubelet recreates, so they are not evicted.
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// DrainInput is the input of the drain action. Like kubectl drain, pods without a controller and pods with
+// emptyDir volumes stop the drain unless they are forced.
+type DrainInput struct {
+	// Force evicts pods that no controller will recreate.
+	Force bool `json:"force,omitempty"`
+	// DeleteEmptyDirData evicts pods with emptyDir volumes, whose data is lost.
+	DeleteEmptyDirData bool `json:"deleteEmptyDirData,omitempty"`
+	// GracePeriodSeconds overrides the termination grace period of the evicted pods.
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+	// TimeoutSeconds is how long the drain may take before it fails, 10 minutes by default.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// cordon sets whether a node is unschedulable, returning the node as it is after the patch.
+func cordon(cg ClientGetter, unschedulable bool) Action {
+	return Action{
+		Permissions: []Permission{{Verb: "patch"}},
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			if err := setUnschedulable(apiOp.Context(), client, apiOp.Name, unschedulable); err != nil {
+				return types.APIObject{}, err
+			}
+			return apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+		},
+	}
+}
+
+func setUnschedulable(ctx context.Context, client kubernetes.Interface, node string, unschedulable bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"unschedulable": unschedulable,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Nodes().Patch(ctx, node, k8stypes.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// drain cordons a node and starts evicting its pods like kubectl drain, skipping the pods of daemonsets and mirror
+// pods. It returns the progress of the drain, which goes on in the background and may be watched as the nodeDrain
+// of the node. Evictions refused by a pod disruption budget are retried until the drain times out.
+func drain(cg ClientGetter, drains *drains) Action {
+	return Action{
+		Permissions: []Permission{
+			{Verb: "patch"},
+			{Verb: "list", Resource: schema.GroupResource{Resource: "pods"}, Namespace: "*"},
+			{Verb: "get", Resource: schema.GroupResource{Resource: "pods"}, Namespace: "*"},
+			{Verb: "create", Resource: schema.GroupResource{Resource: "pods/eviction"}, Namespace: "*"},
+		},
+		Input:  "drainInput",
+		Output: nodeDrainType,
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			var input DrainInput
+			if err := json.NewDecoder(apiOp.Request.Body).Decode(&input); err != nil && err != io.EOF {
+				return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+			}
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			status, err := drains.start(apiOp.Context(), client, apiOp.Name, input)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			return toAPIObject(status), nil
+		},
+	}
+}
+
+// podsToEvict returns the pods of a node a drain evicts, or an error naming the pods that stop the drain.
+func podsToEvict(ctx context.Context, client kubernetes.Interface, node string, input DrainInput) ([]corev1.Pod, error) {
+	pods, err := client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		evict   []corev1.Pod
+		blocked []string
+	)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			evict = append(evict, pod)
+			continue
+		}
+		if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+			continue
+		}
+		controller := metav1.GetControllerOf(&pod)
+		if controller != nil && controller.Kind == "DaemonSet" {
+			continue
+		}
+		if controller == nil && !input.Force {
+			blocked = append(blocked, fmt.Sprintf("%s/%s has no controller", pod.Namespace, pod.Name))
+			continue
+		}
+		if hasEmptyDir(pod) && !input.DeleteEmptyDirData {
+			blocked = append(blocked, fmt.Sprintf("%s/%s has emptyDir data", pod.Namespace, pod.Name))
+			continue
+		}
+		evict = append(evict, pod)
+	}
+	if len(blocked) > 0 {
+		return nil, apierror.NewAPIError(validation.Conflict, "can not drain node "+node+": "+strings.Join(blocked, ", "))
+	}
+	return evict, nil
+}
+
+// evict asks for a pod to be evicted. It returns false without an error if a pod disruption budget refuses the
+// eviction for now, and true for a pod that is already gone.
+func evict(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, gracePeriodSeconds *int64) (bool, error) {
+	err := client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &metav1.DeleteOptions{
+			GracePeriodSeconds: gracePeriodSeconds,
+		},
+	})
+	switch {
+	case err == nil, apierrors.IsNotFound(err):
+		return true, nil
+	case apierrors.IsTooManyRequests(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// gone returns whether an evicted pod was deleted. A pod of the same name with another UID is a replacement.
+func gone(ctx context.Context, client kubernetes.Interface, pod corev1.Pod) (bool, error) {
+	current, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return current.UID != pod.UID, nil
+}
+
+func hasEmptyDir(pod corev1.Pod) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package actions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDrain(t *testing.T) {
+	isController := true
+	pod := func(name string, owner string, emptyDir bool) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: k8stypes.UID("uid-" + name)},
+			Spec:       corev1.PodSpec{NodeName: "node1"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if owner != "" {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: owner, Name: "owner", Controller: &isController}}
+		}
+		if emptyDir {
+			p.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+		}
+		return p
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	ctx := context.Background()
+
+	client := fake.NewSimpleClientset(node, pod("web", "ReplicaSet", false), pod("agent", "DaemonSet", false), pod("bare", "", false), pod("cache", "ReplicaSet", true))
+	refused := false
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction)
+		if eviction.GetNamespace() == "default" && !refused {
+			// the disruption budget refuses the first eviction
+			refused = true
+			return true, nil, apierrors.NewTooManyRequests("disruption budget", 1)
+		}
+		name := eviction.GetObject().(metav1.Object).GetName()
+		return true, nil, client.Tracker().Delete(action.GetResource(), action.GetNamespace(), name)
+	})
+
+	drains := newDrains()
+	drains.interval = 10 * time.Millisecond
+
+	_, err := drains.start(ctx, client, "node1", DrainInput{})
+	assert.Error(t, err, "pods without a controller or with emptyDir data stop the drain unless forced")
+	updated, err := client.CoreV1().Nodes().Get(ctx, "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.False(t, updated.Spec.Unschedulable, "a drain that is stopped does not cordon the node")
+
+	changed := drains.watch(ctx)
+	status, err := drains.start(ctx, client, "node1", DrainInput{Force: true, DeleteEmptyDirData: true})
+	require.NoError(t, err)
+	assert.Equal(t, DrainStateDraining, status.State)
+	assert.Equal(t, []string{"default/bare", "default/cache", "default/web"}, status.Pending, "daemonset pods are not evicted")
+
+	updated, err = client.CoreV1().Nodes().Get(ctx, "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, updated.Spec.Unschedulable)
+
+	timeout := time.After(5 * time.Second)
+	for status.State == DrainStateDraining {
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatal("drain did not finish")
+		}
+		status, _ = drains.get("node1")
+	}
+	assert.Equal(t, DrainStateDrained, status.State)
+	assert.ElementsMatch(t, []string{"default/bare", "default/cache", "default/web"}, status.Drained)
+	assert.True(t, refused)
+
+	_, err = client.CoreV1().Pods("default").Get(ctx, "agent", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
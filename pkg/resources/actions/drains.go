@@ -0,0 +1,332 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	nodeDrainType = "nodeDrain"
+
+	DrainStateDraining = "draining"
+	DrainStateDrained  = "drained"
+	DrainStateFailed   = "failed"
+
+	drainRetryInterval = 5 * time.Second
+)
+
+// NodeDrain is the progress of the drain of a node, by the namespace/name of its pods: those not yet evicted, those
+// evicted that are still terminating, and those gone.
+type NodeDrain struct {
+	ID       string   `json:"id,omitempty"`
+	State    string   `json:"state"`
+	Message  string   `json:"message,omitempty"`
+	Pending  []string `json:"pending"`
+	Evicting []string `json:"evicting"`
+	Drained  []string `json:"drained"`
+
+	revision int64
+}
+
+// drains tracks the drains of nodes, the last of each node, and tells watches of their progress.
+type drains struct {
+	lock     sync.Mutex
+	status   map[string]NodeDrain
+	revision int64
+	watchers map[chan struct{}]bool
+	interval time.Duration
+}
+
+func newDrains() *drains {
+	return &drains{
+		status:   map[string]NodeDrain{},
+		watchers: map[chan struct{}]bool{},
+		interval: drainRetryInterval,
+	}
+}
+
+// start cordons a node and evicts its pods in the background. A node is drained by one drain at a time.
+func (d *drains) start(ctx context.Context, client kubernetes.Interface, node string, input DrainInput) (NodeDrain, error) {
+	if status, ok := d.get(node); ok && status.State == DrainStateDraining {
+		return NodeDrain{}, apierror.NewAPIError(validation.Conflict, "node "+node+" is already draining")
+	}
+	pods, err := podsToEvict(ctx, client, node, input)
+	if err != nil {
+		return NodeDrain{}, err
+	}
+	if err := setUnschedulable(ctx, client, node, true); err != nil {
+		return NodeDrain{}, err
+	}
+
+	status := NodeDrain{
+		ID:    node,
+		State: DrainStateDraining,
+	}
+	pending := map[string]corev1.Pod{}
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		pending[key] = pod
+		status.Pending = append(status.Pending, key)
+	}
+	status = d.update(status)
+
+	timeout := defaultDrainTimeout
+	if input.TimeoutSeconds > 0 {
+		timeout = time.Duration(input.TimeoutSeconds) * time.Second
+	}
+	go d.run(client, status, pending, input.GracePeriodSeconds, timeout)
+	return status, nil
+}
+
+// run evicts the pending pods of a drain and waits for them to be gone, retrying the evictions refused by pod
+// disruption budgets, until every pod is gone or the drain times out.
+func (d *drains) run(client kubernetes.Interface, status NodeDrain, pending map[string]corev1.Pod, gracePeriodSeconds *int64, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var evicting []corev1.Pod
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		status.Message = ""
+		for _, key := range status.Pending {
+			pod := pending[key]
+			evicted, err := evict(ctx, client, pod, gracePeriodSeconds)
+			if err != nil {
+				d.fail(status, "evicting "+key+": "+err.Error())
+				return
+			}
+			if evicted {
+				evicting = append(evicting, pod)
+				delete(pending, key)
+			} else {
+				status.Message = "waiting for the disruption budget of " + key
+			}
+		}
+
+		var stillEvicting []corev1.Pod
+		for _, pod := range evicting {
+			done, err := gone(ctx, client, pod)
+			if err != nil {
+				d.fail(status, "waiting for "+pod.Namespace+"/"+pod.Name+": "+err.Error())
+				return
+			}
+			if done {
+				status.Drained = append(status.Drained, pod.Namespace+"/"+pod.Name)
+			} else {
+				stillEvicting = append(stillEvicting, pod)
+			}
+		}
+		evicting = stillEvicting
+
+		status.Pending, status.Evicting = keys(pending), podKeys(evicting)
+		if len(pending) == 0 && len(evicting) == 0 {
+			status.State = DrainStateDrained
+			d.update(status)
+			return
+		}
+		d.update(status)
+
+		select {
+		case <-ctx.Done():
+			d.fail(status, "timed out")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *drains) fail(status NodeDrain, message string) {
+	logrus.Infof("drain of node %s failed: %s", status.ID, message)
+	status.State = DrainStateFailed
+	status.Message = message
+	d.update(status)
+}
+
+// update records the progress of a drain and wakes up the watches.
+func (d *drains) update(status NodeDrain) NodeDrain {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.revision++
+	status.revision = d.revision
+	status.Pending = append([]string{}, status.Pending...)
+	status.Evicting = append([]string{}, status.Evicting...)
+	status.Drained = append([]string{}, status.Drained...)
+	d.status[status.ID] = status
+	for watcher := range d.watchers {
+		select {
+		case watcher <- struct{}{}:
+		default:
+		}
+	}
+	return status
+}
+
+func (d *drains) get(node string) (NodeDrain, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	status, ok := d.status[node]
+	return status, ok
+}
+
+func (d *drains) list() []NodeDrain {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	result := make([]NodeDrain, 0, len(d.status))
+	for _, status := range d.status {
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// watch returns a channel that receives a value after drains progress, until the context is done.
+func (d *drains) watch(ctx context.Context) <-chan struct{} {
+	watcher := make(chan struct{}, 1)
+	d.lock.Lock()
+	d.watchers[watcher] = true
+	d.lock.Unlock()
+	go func() {
+		<-ctx.Done()
+		d.lock.Lock()
+		delete(d.watchers, watcher)
+		d.lock.Unlock()
+	}()
+	return watcher
+}
+
+func keys(pods map[string]corev1.Pod) []string {
+	result := make([]string, 0, len(pods))
+	for key := range pods {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func podKeys(pods []corev1.Pod) []string {
+	result := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		result = append(result, pod.Namespace+"/"+pod.Name)
+	}
+	return result
+}
+
+func toAPIObject(status NodeDrain) types.APIObject {
+	return types.APIObject{
+		Type:   nodeDrainType,
+		ID:     status.ID,
+		Object: status,
+	}
+}
+
+func registerNodeDrain(apiSchemas *types.APISchemas, drains *drains, asl accesscontrol.AccessSetLookup) {
+	apiSchemas.InternalSchemas.TypeName(nodeDrainType, NodeDrain{})
+	apiSchemas.MustImportAndCustomize(NodeDrain{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		schema.Attributes["access"] = accesscontrol.AccessListByVerb{
+			"watch": accesscontrol.AccessList{
+				{
+					Namespace:    "*",
+					ResourceName: "*",
+				},
+			},
+		}
+		schema.Store = &drainStore{
+			drains: drains,
+			asl:    asl,
+		}
+	})
+}
+
+// drainStore serves the progress of the drains of the nodes the user may get.
+type drainStore struct {
+	empty.Store
+	drains *drains
+	asl    accesscontrol.AccessSetLookup
+}
+
+func (s *drainStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	status, ok := s.drains.get(id)
+	if !ok || !s.canGet(apiOp)(id) {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no drain of node "+id)
+	}
+	return toAPIObject(status), nil
+}
+
+func (s *drainStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	canGet := s.canGet(apiOp)
+	result := types.APIObjectList{}
+	for _, status := range s.drains.list() {
+		if canGet(status.ID) {
+			result.Objects = append(result.Objects, toAPIObject(status))
+		}
+	}
+	return result, nil
+}
+
+func (s *drainStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	canGet := s.canGet(apiOp)
+	changed := s.drains.watch(apiOp.Context())
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+
+		seen := map[string]int64{}
+		for _, status := range s.drains.list() {
+			seen[status.ID] = status.revision
+		}
+		for {
+			select {
+			case <-apiOp.Context().Done():
+				return
+			case <-changed:
+			}
+			for _, status := range s.drains.list() {
+				if status.revision == seen[status.ID] || !canGet(status.ID) || (w.ID != "" && w.ID != status.ID) {
+					continue
+				}
+				seen[status.ID] = status.revision
+				select {
+				case result <- types.APIEvent{
+					Name:         types.ChangeAPIEvent,
+					ResourceType: nodeDrainType,
+					Object:       toAPIObject(status),
+				}:
+				case <-apiOp.Context().Done():
+					return
+				}
+			}
+		}
+	}()
+	return result, nil
+}
+
+func (s *drainStore) canGet(apiOp *types.APIRequest) func(node string) bool {
+	user, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return func(string) bool { return false }
+	}
+	access := s.asl.AccessFor(user)
+	return func(node string) bool {
+		return access.Grants("get", schema.GroupResource{Resource: "nodes"}, "", node)
+	}
+}
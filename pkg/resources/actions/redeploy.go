@@ -0,0 +1,90 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	redeployAction = "redeploy"
+
+	// restartedAtAnnotation is the annotation of the pod template kubectl rollout restart sets.
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// ClientGetter returns the kubernetes client of the user of a request.
+type ClientGetter interface {
+	K8sInterface(apiOp *types.APIRequest) (kubernetes.Interface, error)
+}
+
+// AddDefaults registers the built-in actions: redeploy on deployments, statefulsets and daemonsets, run on
+// cronjobs, debug on pods, and cordon, uncordon and drain on nodes, with the schemas of their input and of the
+// progress of drains.
+func AddDefaults(apiSchemas *types.APISchemas, registry *Registry, cg ClientGetter, asl accesscontrol.AccessSetLookup) {
+	for _, schemaID := range []string{"apps.deployment", "apps.statefulset", "apps.daemonset"} {
+		registry.Add(schemaID, redeployAction, redeploy(cg))
+	}
+	registry.Add("batch.cronjob", runAction, run(cg))
+	apiSchemas.MustImportAndCustomize(DebugInput{}, nil)
+	registry.Add("pod", debugAction, debug(cg))
+
+	drains := newDrains()
+	apiSchemas.MustImportAndCustomize(DrainInput{}, nil)
+	registerNodeDrain(apiSchemas, drains, asl)
+	registry.Add("node", cordonAction, cordon(cg, true))
+	registry.Add("node", uncordonAction, cordon(cg, false))
+	registry.Add("node", drainAction, drain(cg, drains))
+}
+
+// redeploy restarts the pods of a workload like kubectl rollout restart, by patching an annotation with the time of
+// the restart into its pod template.
+func redeploy(cg ClientGetter) Action {
+	return Action{
+		Permissions: []Permission{{Verb: "patch"}},
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			patch, err := json.Marshal(map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"annotations": map[string]string{
+								restartedAtAnnotation: time.Now().Format(time.RFC3339),
+							},
+						},
+					},
+				},
+			})
+			if err != nil {
+				return types.APIObject{}, err
+			}
+
+			opts := metav1.PatchOptions{}
+			apps := client.AppsV1()
+			switch kind := attributes.Kind(apiOp.Schema); kind {
+			case "Deployment":
+				_, err = apps.Deployments(apiOp.Namespace).Patch(apiOp.Context(), apiOp.Name, k8stypes.StrategicMergePatchType, patch, opts)
+			case "StatefulSet":
+				_, err = apps.StatefulSets(apiOp.Namespace).Patch(apiOp.Context(), apiOp.Name, k8stypes.StrategicMergePatchType, patch, opts)
+			case "DaemonSet":
+				_, err = apps.DaemonSets(apiOp.Namespace).Patch(apiOp.Context(), apiOp.Name, k8stypes.StrategicMergePatchType, patch, opts)
+			default:
+				err = fmt.Errorf("can not redeploy %s", kind)
+			}
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			return apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+		},
+	}
+}
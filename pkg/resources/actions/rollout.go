@@ -0,0 +1,350 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+)
+
+const (
+	pauseAction   = "pause"
+	resumeAction  = "resume"
+	restartAction = "restart"
+	undoAction    = "undo"
+	historyLink   = "history"
+
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+var (
+	replicaSets         = schema2.GroupResource{Group: "apps", Resource: "replicasets"}
+	controllerRevisions = schema2.GroupResource{Group: "apps", Resource: "controllerrevisions"}
+)
+
+// RolloutHistory is the output of the history link of a workload: its revisions, oldest first.
+type RolloutHistory struct {
+	Revisions []RolloutRevision `json:"revisions"`
+}
+
+// RolloutRevision is a revision of a workload, the replicaset of a deployment or the controllerrevision of a
+// daemonset or statefulset.
+type RolloutRevision struct {
+	Revision    int64       `json:"revision"`
+	Name        string      `json:"name"`
+	Created     metav1.Time `json:"created"`
+	ChangeCause string      `json:"changeCause,omitempty"`
+	Current     bool        `json:"current,omitempty"`
+}
+
+// RolloutUndoInput is the input of the undo action. The workload is rolled back to the revision, or to the one
+// before the current revision if it is zero.
+type RolloutUndoInput struct {
+	ToRevision int64 `json:"toRevision,omitempty"`
+}
+
+// revisionLister returns the revisions of workloads from the caches of replicasets and controllerrevisions.
+type revisionLister struct {
+	replicaSets appslisters.ReplicaSetLister
+	revisions   appslisters.ControllerRevisionLister
+}
+
+// revision is a revision of a workload with what it takes to go back to it.
+type revision struct {
+	RolloutRevision
+	template []byte
+	patch    []byte
+}
+
+// AddRollouts registers the rollout actions of workloads: pause and resume of deployments, and restart and undo of
+// deployments, daemonsets and statefulsets. It returns the templates adding the history link to workloads. The
+// revisions of workloads are listed from the caches of replicasets and controllerrevisions.
+func AddRollouts(apiSchemas *types.APISchemas, registry *Registry, cg ClientGetter, asl accesscontrol.AccessSetLookup,
+	replicaSetLister appslisters.ReplicaSetLister, revisionsLister appslisters.ControllerRevisionLister) []schema.Template {
+	lister := &revisionLister{
+		replicaSets: replicaSetLister,
+		revisions:   revisionsLister,
+	}
+	apiSchemas.MustImportAndCustomize(RolloutHistory{}, nil)
+	apiSchemas.MustImportAndCustomize(RolloutUndoInput{}, nil)
+
+	registry.Add("apps.deployment", pauseAction, setPaused(cg, true))
+	registry.Add("apps.deployment", resumeAction, setPaused(cg, false))
+
+	var templates []schema.Template
+	for _, schemaID := range []string{"apps.deployment", "apps.statefulset", "apps.daemonset"} {
+		registry.Add(schemaID, restartAction, redeploy(cg))
+		registry.Add(schemaID, undoAction, undo(cg, lister, schemaID))
+		templates = append(templates, schema.Template{
+			ID:        schemaID,
+			Formatter: addHistoryLink,
+			Customize: func(apiSchema *types.APISchema) {
+				addHistory(apiSchema, asl, lister)
+			},
+		})
+	}
+	return templates
+}
+
+// revisionsResource returns the resource of the revisions of the workloads of a schema.
+func revisionsResource(schemaID string) schema2.GroupResource {
+	if schemaID == "apps.deployment" {
+		return replicaSets
+	}
+	return controllerRevisions
+}
+
+func setPaused(cg ClientGetter, paused bool) Action {
+	return Action{
+		Permissions: []Permission{{Verb: "patch"}},
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			patch, err := json.Marshal(map[string]interface{}{
+				"spec": map[string]interface{}{
+					"paused": paused,
+				},
+			})
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			_, err = client.AppsV1().Deployments(apiOp.Namespace).Patch(apiOp.Context(), apiOp.Name, k8stypes.StrategicMergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			return apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+		},
+	}
+}
+
+// undo rolls a workload back to a revision like kubectl rollout undo, by putting the pod template of the revision
+// back into the workload.
+func undo(cg ClientGetter, lister *revisionLister, schemaID string) Action {
+	return Action{
+		Permissions: []Permission{
+			{Verb: "patch"},
+			{Verb: "list", Resource: revisionsResource(schemaID)},
+		},
+		Input: "rolloutUndoInput",
+		Handler: func(apiOp *types.APIRequest, obj types.APIObject) (types.APIObject, error) {
+			var input RolloutUndoInput
+			if err := json.NewDecoder(apiOp.Request.Body).Decode(&input); err != nil && err != io.EOF {
+				return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+			}
+			m, err := meta.Accessor(obj.Object)
+			if err != nil {
+				return types.APIObject{}, apierror.NewAPIError(validation.InvalidType, "object has no metadata")
+			}
+			revisions, err := lister.list(attributes.Kind(apiOp.Schema), m)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			target, err := undoTarget(revisions, input.ToRevision)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+
+			client, err := cg.K8sInterface(apiOp)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			ctx, opts := apiOp.Context(), metav1.PatchOptions{}
+			switch kind := attributes.Kind(apiOp.Schema); kind {
+			case "Deployment":
+				if obj.Data().Bool("spec", "paused") {
+					return types.APIObject{}, apierror.NewAPIError(validation.Conflict, "can not undo a paused deployment, resume it first")
+				}
+				patch, err := json.Marshal([]map[string]interface{}{{
+					"op":    "replace",
+					"path":  "/spec/template",
+					"value": json.RawMessage(target.template),
+				}})
+				if err != nil {
+					return types.APIObject{}, err
+				}
+				_, err = client.AppsV1().Deployments(apiOp.Namespace).Patch(ctx, apiOp.Name, k8stypes.JSONPatchType, patch, opts)
+				if err != nil {
+					return types.APIObject{}, err
+				}
+			case "StatefulSet":
+				if _, err := client.AppsV1().StatefulSets(apiOp.Namespace).Patch(ctx, apiOp.Name, k8stypes.StrategicMergePatchType, target.patch, opts); err != nil {
+					return types.APIObject{}, err
+				}
+			case "DaemonSet":
+				if _, err := client.AppsV1().DaemonSets(apiOp.Namespace).Patch(ctx, apiOp.Name, k8stypes.StrategicMergePatchType, target.patch, opts); err != nil {
+					return types.APIObject{}, err
+				}
+			default:
+				return types.APIObject{}, fmt.Errorf("can not undo %s", kind)
+			}
+			return apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+		},
+	}
+}
+
+// undoTarget returns the revision to go back to: the given revision, or the newest revision before the current
+// one.
+func undoTarget(revisions []revision, toRevision int64) (revision, error) {
+	if toRevision == 0 {
+		for i := len(revisions) - 1; i >= 0; i-- {
+			if revisions[i].Current {
+				if i == 0 {
+					break
+				}
+				return revisions[i-1], nil
+			}
+		}
+		return revision{}, apierror.NewAPIError(validation.NotFound, "no previous revision to undo to")
+	}
+	for _, r := range revisions {
+		if r.Revision == toRevision {
+			return r, nil
+		}
+	}
+	return revision{}, apierror.NewAPIError(validation.NotFound, "no revision "+strconv.FormatInt(toRevision, 10))
+}
+
+// list returns the revisions of a workload, oldest first, from the objects it is the controller of.
+func (l *revisionLister) list(kind string, workload metav1.Object) ([]revision, error) {
+	var result []revision
+	if kind == "Deployment" {
+		sets, err := l.replicaSets.ReplicaSets(workload.GetNamespace()).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		current := workload.GetAnnotations()[revisionAnnotation]
+		for _, rs := range sets {
+			if !controlledBy(rs, workload) {
+				continue
+			}
+			number, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+			if err != nil {
+				continue
+			}
+			template := rs.Spec.Template.DeepCopy()
+			delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+			data, err := json.Marshal(template)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, revision{
+				RolloutRevision: RolloutRevision{
+					Revision:    number,
+					Name:        rs.Name,
+					Created:     rs.CreationTimestamp,
+					ChangeCause: rs.Annotations[changeCauseAnnotation],
+					Current:     rs.Annotations[revisionAnnotation] == current,
+				},
+				template: data,
+			})
+		}
+	} else {
+		revisions, err := l.revisions.ControllerRevisions(workload.GetNamespace()).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range revisions {
+			if !controlledBy(cr, workload) {
+				continue
+			}
+			result = append(result, revision{
+				RolloutRevision: RolloutRevision{
+					Revision:    cr.Revision,
+					Name:        cr.Name,
+					Created:     cr.CreationTimestamp,
+					ChangeCause: cr.Annotations[changeCauseAnnotation],
+				},
+				patch: cr.Data.Raw,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Revision < result[j].Revision
+	})
+	if kind != "Deployment" && len(result) > 0 {
+		// the newest controllerrevision is the one the pods are rolled out to
+		result[len(result)-1].Current = true
+	}
+	return result, nil
+}
+
+func controlledBy(obj, owner metav1.Object) bool {
+	controller := metav1.GetControllerOf(obj)
+	return controller != nil && controller.UID == owner.GetUID()
+}
+
+// addHistory serves GET /v1/<type>/<id>?link=history with the revisions of a workload, to users who may list the
+// replicasets or controllerrevisions of its namespace.
+func addHistory(apiSchema *types.APISchema, asl accesscontrol.AccessSetLookup, lister *revisionLister) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if apiOp.Link != historyLink || apiOp.Method != http.MethodGet {
+			return next(apiOp)
+		}
+		obj, err := next(apiOp)
+		if err != nil {
+			return obj, err
+		}
+		user, ok := request.UserFrom(apiOp.Context())
+		if !ok {
+			return types.APIObject{}, apierror.NewAPIError(validation.Unauthorized, "user not found")
+		}
+		gr := revisionsResource(apiOp.Schema.ID)
+		if !asl.AccessFor(user).Grants("list", gr, apiOp.Namespace, accesscontrol.All) {
+			return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("can not list %s %s", gr, apiOp.Namespace))
+		}
+		m, err := meta.Accessor(obj.Object)
+		if err != nil {
+			return types.APIObject{}, apierror.NewAPIError(validation.InvalidType, "object has no metadata")
+		}
+		revisions, err := lister.list(attributes.Kind(apiOp.Schema), m)
+		if err != nil {
+			return types.APIObject{}, err
+		}
+
+		history := RolloutHistory{
+			Revisions: make([]RolloutRevision, 0, len(revisions)),
+		}
+		for _, r := range revisions {
+			history.Revisions = append(history.Revisions, r.RolloutRevision)
+		}
+		apiOp.Response.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(apiOp.Response).Encode(history); err != nil {
+			return types.APIObject{}, err
+		}
+		return types.APIObject{}, validation.ErrComplete
+	}
+}
+
+// addHistoryLink adds the history link to workloads.
+func addHistoryLink(request *types.APIRequest, resource *types.RawResource) {
+	if resource.Links == nil {
+		return
+	}
+	resource.Links[historyLink] = request.URLBuilder.Link(resource.Schema, resource.ID, historyLink)
+}
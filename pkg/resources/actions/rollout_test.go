@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRevisions(t *testing.T) {
+	isController := true
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:        "web",
+		Namespace:   "default",
+		UID:         types.UID("web"),
+		Annotations: map[string]string{revisionAnnotation: "3"},
+	}}
+	replicaSet := func(name, revision, owner, image string) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				Annotations:     map[string]string{revisionAnnotation: revision},
+				OwnerReferences: []metav1.OwnerReference{{UID: types.UID(owner), Controller: &isController}},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: name}},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: image}}},
+				},
+			},
+		}
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, rs := range []*appsv1.ReplicaSet{
+		replicaSet("web-3", "3", "web", "web:3"),
+		replicaSet("web-1", "1", "web", "web:1"),
+		replicaSet("web-2", "2", "web", "web:2"),
+		replicaSet("other-1", "1", "other", "other:1"),
+	} {
+		require.NoError(t, indexer.Add(rs))
+	}
+	lister := &revisionLister{replicaSets: appslisters.NewReplicaSetLister(indexer)}
+
+	revisions, err := lister.list("Deployment", deployment)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3, "only the replicasets of the deployment are its revisions")
+	assert.Equal(t, []string{"web-1", "web-2", "web-3"}, []string{revisions[0].Name, revisions[1].Name, revisions[2].Name})
+	assert.True(t, revisions[2].Current)
+
+	target, err := undoTarget(revisions, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), target.Revision, "undo goes back to the revision before the current one")
+
+	var template corev1.PodTemplateSpec
+	require.NoError(t, json.Unmarshal(target.template, &template))
+	assert.Equal(t, "web:2", template.Spec.Containers[0].Image)
+	assert.NotContains(t, template.Labels, appsv1.DefaultDeploymentUniqueLabelKey, "the label of the replicaset is dropped")
+
+	target, err = undoTarget(revisions, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", target.Name)
+
+	_, err = undoTarget(revisions, 7)
+	assert.Error(t, err)
+	_, err = undoTarget(revisions[2:], 0)
+	assert.Error(t, err, "there is nothing to undo to without a previous revision")
+}
@@ -0,0 +1,189 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	batchAction             = "batch"
+	defaultBatchConcurrency = 3
+)
+
+// BatchInput is the input of the batch collection action.
+type BatchInput struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is a single create, update or delete of a batch.
+// Objects are identified by the namespace and name in their metadata, or for a delete by an ID of the form
+// namespace/name.
+type BatchOperation struct {
+	// Action is one of create, update or delete.
+	Action string                 `json:"action"`
+	ID     string                 `json:"id,omitempty"`
+	Object map[string]interface{} `json:"object,omitempty"`
+}
+
+// BatchOutput is the output of the batch collection action, with a result for each operation in the same order.
+type BatchOutput struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of a single operation of a batch.
+type BatchResult struct {
+	Action  string                 `json:"action"`
+	ID      string                 `json:"id,omitempty"`
+	Status  int                    `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Object  map[string]interface{} `json:"object,omitempty"`
+}
+
+// RegisterBatch adds the input and output schemas of the batch collection action.
+func RegisterBatch(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(BatchInput{}, nil)
+	apiSchemas.MustImportAndCustomize(BatchOutput{}, nil)
+}
+
+// addBatch adds the batch collection action to a schema, which creates, updates and deletes several objects of the
+// schema, possibly in different namespaces, in a single request.
+func addBatch(schema *types.APISchema, concurrency int64) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if schema.ActionHandlers == nil {
+		schema.ActionHandlers = map[string]http.Handler{}
+	}
+	schema.ActionHandlers[batchAction] = &batch{concurrency: concurrency}
+
+	if schema.CollectionActions == nil {
+		schema.CollectionActions = map[string]schemas.Action{}
+	}
+	schema.CollectionActions[batchAction] = schemas.Action{
+		Input:  "batchInput",
+		Output: "batchOutput",
+	}
+}
+
+// batch performs the operations of a batch concurrently through the schema's store, so each one is subject to the
+// same access checks as the equivalent single request. An operation that fails does not stop the others.
+type batch struct {
+	concurrency int64
+}
+
+func (b *batch) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var (
+		apiOp = types.GetAPIContext(req.Context())
+		input BatchInput
+	)
+
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		apiOp.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	var (
+		results = make([]BatchResult, len(input.Operations))
+		sem     = semaphore.NewWeighted(b.concurrency)
+		eg      errgroup.Group
+	)
+	for i, op := range input.Operations {
+		index, op := i, op
+		if err := sem.Acquire(apiOp.Context(), 1); err != nil {
+			apiOp.WriteError(err)
+			return
+		}
+		eg.Go(func() error {
+			defer sem.Release(1)
+			results[index] = do(apiOp, op)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	apiOp.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "batchOutput",
+		Object: BatchOutput{Results: results},
+	})
+}
+
+// do performs a single operation of a batch.
+func do(apiOp *types.APIRequest, op BatchOperation) BatchResult {
+	namespace, name := op.target()
+	result := BatchResult{
+		Action: op.Action,
+		ID:     name,
+	}
+	if namespace != "" {
+		result.ID = namespace + "/" + name
+	}
+
+	req := apiOp.Clone()
+	req.Namespace = namespace
+	schema := apiOp.Schema
+
+	var (
+		obj    types.APIObject
+		err    error
+		status = http.StatusOK
+	)
+	switch {
+	case schema.Store == nil:
+		err = apierror.NewAPIError(validation.NotFound, "no store found")
+	case op.Action == "create":
+		status = http.StatusCreated
+		if err = req.AccessControl.CanCreate(req, schema); err == nil {
+			obj, err = schema.Store.Create(req, schema, types.APIObject{Object: &unstructured.Unstructured{Object: op.Object}})
+		}
+	case op.Action == "update" && name != "":
+		if err = req.AccessControl.CanUpdate(req, types.APIObject{}, schema); err == nil {
+			obj, err = schema.Store.Update(req, schema, types.APIObject{Object: &unstructured.Unstructured{Object: op.Object}}, name)
+		}
+	case op.Action == "delete" && name != "":
+		if err = req.AccessControl.CanDelete(req, types.APIObject{}, schema); err == nil {
+			obj, err = schema.Store.Delete(req, schema, name)
+		}
+	case name == "" && (op.Action == "update" || op.Action == "delete"):
+		err = apierror.NewAPIError(validation.MissingRequired, fmt.Sprintf("%s requires the name of the object", op.Action))
+	default:
+		err = apierror.NewAPIError(validation.InvalidAction, fmt.Sprintf("invalid batch action: %s", op.Action))
+	}
+
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		var apiErr *apierror.APIError
+		if errors.As(err, &apiErr) {
+			result.Status = apiErr.Code.Status
+		}
+		result.Message = err.Error()
+		return result
+	}
+	result.Status = status
+	if obj.Object != nil {
+		result.Object = obj.Data()
+	}
+	return result
+}
+
+// target returns the namespace and name of the object an operation applies to.
+func (op BatchOperation) target() (string, string) {
+	if op.ID != "" {
+		if namespace, name, ok := strings.Cut(op.ID, "/"); ok {
+			return namespace, name
+		}
+		return "", op.ID
+	}
+	obj := unstructured.Unstructured{Object: op.Object}
+	return obj.GetNamespace(), obj.GetName()
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchStore records the namespace of each object it writes, and has no object named missing.
+type batchStore struct {
+	empty.Store
+
+	lock       sync.Mutex
+	namespaces map[string]string
+}
+
+func (b *batchStore) write(apiOp *types.APIRequest, name string, obj types.APIObject) (types.APIObject, error) {
+	if name == "missing" {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "not found")
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.namespaces[name] = apiOp.Namespace
+	return obj, nil
+}
+
+func (b *batchStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	return b.write(apiOp, data.Data().String("metadata", "name"), data)
+}
+
+func (b *batchStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	return b.write(apiOp, id, data)
+}
+
+// batchResponse records the response written by a handler.
+type batchResponse struct {
+	code int
+	obj  types.APIObject
+}
+
+func (b *batchResponse) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	b.code, b.obj = code, obj
+}
+
+func (b *batchResponse) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {}
+
+func TestBatch(t *testing.T) {
+	store := &batchStore{namespaces: map[string]string{}}
+	schema := &types.APISchema{Schema: &schemas.Schema{
+		ID:                "apps.deployment",
+		CollectionMethods: []string{http.MethodPost},
+		ResourceMethods:   []string{http.MethodPut},
+	}}
+	schema.Store = store
+	addBatch(schema, 0)
+
+	web := map[string]interface{}{"metadata": map[string]interface{}{"name": "web", "namespace": "dev"}}
+	input, err := json.Marshal(BatchInput{Operations: []BatchOperation{
+		{Action: "create", Object: web},
+		{Action: "update", ID: "prod/api", Object: map[string]interface{}{}},
+		{Action: "update", ID: "prod/missing", Object: map[string]interface{}{}},
+		{Action: "update", Object: map[string]interface{}{}},
+		{Action: "delete", ID: "prod/db"},
+		{Action: "restart", ID: "prod/db"},
+	}})
+	require.NoError(t, err)
+
+	response := &batchResponse{}
+	apiOp := types.StoreAPIContext(&types.APIRequest{
+		Schema:         schema,
+		AccessControl:  accesscontrol.NewAccessControl(),
+		ResponseWriter: response,
+		Request:        httptest.NewRequest(http.MethodPost, "/v1/apps.deployments?action=batch", bytes.NewReader(input)),
+	})
+	schema.ActionHandlers[batchAction].ServeHTTP(httptest.NewRecorder(), apiOp.Request)
+
+	require.Equal(t, http.StatusOK, response.code)
+	results := response.obj.Object.(BatchOutput).Results
+	require.Len(t, results, 6, "there is a result for every operation, in order")
+	assert.Equal(t, BatchResult{Action: "create", ID: "dev/web", Status: http.StatusCreated, Object: web}, results[0])
+	assert.Equal(t, BatchResult{Action: "update", ID: "prod/api", Status: http.StatusOK}, results[1])
+	assert.Equal(t, http.StatusNotFound, results[2].Status, "a failed operation doesn't stop the others")
+	assert.Equal(t, http.StatusUnprocessableEntity, results[3].Status, "updates need the name of the object")
+	assert.Equal(t, http.StatusForbidden, results[4].Status, "operations are subject to the access of the schema")
+	assert.Equal(t, http.StatusUnprocessableEntity, results[5].Status)
+	assert.Equal(t, map[string]string{"web": "dev", "api": "prod"}, store.namespaces,
+		"operations are made in the namespace of their object")
+}
+
+func TestBatchOperationTarget(t *testing.T) {
+	obj := map[string]interface{}{"metadata": map[string]interface{}{"name": "web", "namespace": "dev"}}
+	tests := []struct {
+		name      string
+		op        BatchOperation
+		namespace string
+		target    string
+	}{
+		{name: "namespaced id", op: BatchOperation{ID: "prod/db", Object: obj}, namespace: "prod", target: "db"},
+		{name: "cluster id", op: BatchOperation{ID: "db"}, target: "db"},
+		{name: "object", op: BatchOperation{Object: obj}, namespace: "dev", target: "web"},
+		{name: "nothing", op: BatchOperation{}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			namespace, name := test.op.target()
+			assert.Equal(t, test.namespace, namespace)
+			assert.Equal(t, test.target, name)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/schema/converter"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const deletePreviewAction = "deletePreview"
+
+// DeletePreview is the output of the deletePreview action: the objects deleting a resource would remove, starting
+// with the resource itself, and the number of those the user may not see.
+type DeletePreview struct {
+	Objects []DeletePreviewObject `json:"objects"`
+	Hidden  int                   `json:"hidden,omitempty"`
+}
+
+// DeletePreviewObject is an object a delete would remove. An object with finalizers is only removed once they are
+// done.
+type DeletePreviewObject struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+// RegisterDeletePreview adds the output schema of the deletePreview action.
+func RegisterDeletePreview(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(DeletePreview{}, nil)
+}
+
+// addDeletePreview adds the deletePreview resource action to a schema, which returns the objects that deleting
+// the resource would remove, without deleting anything. The propagationPolicy query parameter is that of the
+// delete; with Orphan the dependents are kept.
+func addDeletePreview(schema *types.APISchema, source dependentSource) {
+	if schema.ActionHandlers == nil {
+		schema.ActionHandlers = map[string]http.Handler{}
+	}
+	schema.ActionHandlers[deletePreviewAction] = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiOp := types.GetAPIContext(req.Context())
+		preview, err := deletePreview(apiOp, source)
+		if err != nil {
+			apiOp.WriteError(err)
+			return
+		}
+		apiOp.WriteResponse(http.StatusOK, types.APIObject{
+			Type:   "deletePreview",
+			Object: preview,
+		})
+	})
+
+	if schema.ResourceActions == nil {
+		schema.ResourceActions = map[string]schemas.Action{}
+	}
+	schema.ResourceActions[deletePreviewAction] = schemas.Action{
+		Output: "deletePreview",
+	}
+}
+
+func deletePreview(apiOp *types.APIRequest, source dependentSource) (DeletePreview, error) {
+	schema := apiOp.Schema
+	if schema.Store == nil {
+		return DeletePreview{}, apierror.NewAPIError(validation.NotFound, "no store found")
+	}
+	obj, err := schema.Store.ByID(apiOp, schema, apiOp.Name)
+	if err != nil {
+		return DeletePreview{}, err
+	}
+	if err := apiOp.AccessControl.CanDelete(apiOp, obj, schema); err != nil {
+		return DeletePreview{}, err
+	}
+	m, err := meta.Accessor(obj.Object)
+	if err != nil {
+		return DeletePreview{}, apierror.NewAPIError(validation.InvalidType, "object has no metadata")
+	}
+
+	preview := DeletePreview{
+		Objects: []DeletePreviewObject{{
+			Type:       schema.ID,
+			ID:         obj.ID,
+			Finalizers: m.GetFinalizers(),
+		}},
+	}
+	if metav1.DeletionPropagation(apiOp.Request.URL.Query().Get("propagationPolicy")) == metav1.DeletePropagationOrphan {
+		return preview, nil
+	}
+
+	// the garbage collector removes a dependent once none of its owners remain, so a dependent is only reached
+	// when every owner is being removed
+	removed := map[k8stypes.UID]bool{m.GetUID(): true}
+	queue := []k8stypes.UID{m.GetUID()}
+	for len(queue) > 0 {
+		uid := queue[0]
+		queue = queue[1:]
+		for _, dependent := range source.Dependents(uid) {
+			if removed[dependent.UID] || !allOwnersRemoved(dependent.OwnerReferences, removed) {
+				continue
+			}
+			removed[dependent.UID] = true
+			queue = append(queue, dependent.UID)
+
+			schemaID := converter.GVKToSchemaID(dependent.GroupVersionKind())
+			id := dependent.Name
+			if dependent.Namespace != "" {
+				id = dependent.Namespace + "/" + dependent.Name
+			}
+			if !canGet(apiOp, schemaID, id) {
+				preview.Hidden++
+				continue
+			}
+			preview.Objects = append(preview.Objects, DeletePreviewObject{
+				Type:       schemaID,
+				ID:         id,
+				Finalizers: dependent.Finalizers,
+			})
+		}
+	}
+
+	return preview, nil
+}
+
+func allOwnersRemoved(owners []metav1.OwnerReference, removed map[k8stypes.UID]bool) bool {
+	for _, owner := range owners {
+		if !removed[owner.UID] {
+			return false
+		}
+	}
+	return true
+}
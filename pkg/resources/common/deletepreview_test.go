@@ -0,0 +1,115 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+type deploymentStore struct {
+	empty.Store
+}
+
+func (deploymentStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	return types.APIObject{
+		Type: schema.ID,
+		ID:   "default/web",
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "namespace": "default", "uid": "deployment"},
+		}},
+	}, nil
+}
+
+type dependentsByOwner map[k8stypes.UID][]*summary.SummarizedObject
+
+func (d dependentsByOwner) Dependents(uid k8stypes.UID) []*summary.SummarizedObject {
+	return d[uid]
+}
+
+func dependent(kind, name string, uid k8stypes.UID, finalizers []string, owners ...k8stypes.UID) *summary.SummarizedObject {
+	obj := &summary.SummarizedObject{}
+	obj.APIVersion, obj.Kind = "apps/v1", kind
+	obj.Name, obj.Namespace, obj.UID, obj.Finalizers = name, "default", uid, finalizers
+	for _, owner := range owners {
+		obj.OwnerReferences = append(obj.OwnerReferences, metav1.OwnerReference{UID: owner})
+	}
+	return obj
+}
+
+func TestDeletePreview(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	for _, kind := range []string{"deployment", "replicaset", "pod"} {
+		schema := types.APISchema{Schema: &schemas.Schema{ID: "apps." + kind, ResourceMethods: []string{http.MethodDelete}}}
+		attributes.SetGVK(&schema, schema2.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind})
+		names := "*"
+		if kind == "pod" {
+			names = "web-abc-1"
+		}
+		attributes.SetAccess(&schema, accesscontrol.AccessListByVerb{
+			"get": accesscontrol.AccessList{{Namespace: "*", ResourceName: names}},
+		})
+		require.NoError(t, apiSchemas.AddSchema(schema))
+	}
+	schema := apiSchemas.LookupSchema("apps.deployment")
+	schema.Store = &deploymentStore{}
+
+	source := dependentsByOwner{
+		"deployment": {dependent("replicaset", "web-abc", "replicaset", nil, "deployment")},
+		"replicaset": {
+			dependent("pod", "web-abc-1", "pod-1", []string{"example.io/cleanup"}, "replicaset"),
+			dependent("pod", "web-abc-2", "pod-2", nil, "replicaset"),
+			dependent("pod", "web-abc-3", "pod-3", nil, "replicaset", "other"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		want       []DeletePreviewObject
+		wantHidden int
+	}{
+		{
+			name: "cascade",
+			want: []DeletePreviewObject{
+				{Type: "apps.deployment", ID: "default/web"},
+				{Type: "apps.replicaset", ID: "default/web-abc"},
+				{Type: "apps.pod", ID: "default/web-abc-1", Finalizers: []string{"example.io/cleanup"}},
+			},
+			wantHidden: 1,
+		},
+		{
+			name:  "orphan",
+			query: "?propagationPolicy=Orphan",
+			want:  []DeletePreviewObject{{Type: "apps.deployment", ID: "default/web"}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			apiOp := &types.APIRequest{
+				Name:          "default/web",
+				Schema:        schema,
+				Schemas:       apiSchemas,
+				AccessControl: accesscontrol.NewAccessControl(),
+				Request:       httptest.NewRequest(http.MethodPost, "/v1/apps.deployments/default/web"+test.query, nil),
+			}
+			preview, err := deletePreview(apiOp, source)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, preview.Objects)
+			assert.Equal(t, test.wantHidden, preview.Hidden)
+		})
+	}
+}
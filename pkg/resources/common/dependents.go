@@ -0,0 +1,76 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/schema/converter"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/summary"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const dependentsLink = "dependents"
+
+// dependentSource looks up the objects owned by an object, as the summary cache does.
+type dependentSource interface {
+	Dependents(uid k8stypes.UID) []*summary.SummarizedObject
+}
+
+// addDependents serves GET /v1/<type>/<id>?link=dependents with the objects whose owner references point at the
+// object, such as the pods of a replicaset, so the impact of deleting it can be shown. Only the objects the user
+// may get are included.
+func addDependents(apiSchema *types.APISchema, source dependentSource) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if apiOp.Link != dependentsLink || apiOp.Method != http.MethodGet {
+			return next(apiOp)
+		}
+		obj, err := next(apiOp)
+		if err != nil {
+			return obj, err
+		}
+		m, err := meta.Accessor(obj.Object)
+		if err != nil {
+			return obj, nil
+		}
+
+		apiOp.Response.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(apiOp.Response).Encode(map[string]interface{}{
+			"data": dependents(apiOp, source, m.GetUID()),
+		}); err != nil {
+			return types.APIObject{}, err
+		}
+		return types.APIObject{}, validation.ErrComplete
+	}
+}
+
+// dependents returns the objects owned by the object with the UID, sorted by type and ID.
+func dependents(apiOp *types.APIRequest, source dependentSource, uid k8stypes.UID) []GraphNode {
+	result := []GraphNode{}
+	for _, summarized := range source.Dependents(uid) {
+		schemaID := converter.GVKToSchemaID(summarized.GroupVersionKind())
+		id := summarized.Name
+		if summarized.Namespace != "" {
+			id = summarized.Namespace + "/" + summarized.Name
+		}
+		if !canGet(apiOp, schemaID, id) {
+			continue
+		}
+		result = append(result, toNode(schemaID, id, summarized))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
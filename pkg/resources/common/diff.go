@@ -0,0 +1,272 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/redact"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	diffAction = "diff"
+
+	// liveRevision names the live object in a diff.
+	liveRevision     = "live"
+	manifestRevision = "manifest"
+)
+
+var (
+	// serverFields are set by the apiserver, so a manifest that leaves them out doesn't change them.
+	serverFields = [][]string{
+		{"metadata", "uid"},
+		{"metadata", "creationTimestamp"},
+		{"metadata", "deletionTimestamp"},
+		{"metadata", "generation"},
+		{"metadata", "selfLink"},
+		{"status"},
+	}
+	// ignoredFields change with every write, and are left out of diffs.
+	ignoredFields = [][]string{
+		{"metadata", "managedFields"},
+		{"metadata", "resourceVersion"},
+	}
+)
+
+// DiffInput is the input of the diff action. With a manifest, the changes updating the object with it would make
+// are returned, from the live object or from the revision From. Otherwise the changes between the revisions From and
+// To are returned, To being the live object if empty.
+type DiffInput struct {
+	Manifest map[string]interface{} `json:"manifest,omitempty"`
+	From     string                 `json:"from,omitempty"`
+	To       string                 `json:"to,omitempty"`
+}
+
+// Diff is the output of the diff action. From and To are the resource versions of the revisions compared, or live
+// and manifest. Revisions are the resource versions of the revisions kept of the object, oldest first.
+type Diff struct {
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Changes   []DiffChange `json:"changes"`
+	Revisions []string     `json:"revisions,omitempty"`
+}
+
+// DiffChange is a change of a field, at the JSON pointer Path. Op is add, remove or replace, as in JSON patches.
+type DiffChange struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// revisionSource looks up the kept revisions of an object, oldest first, as the History does.
+type revisionSource interface {
+	Revisions(uid k8stypes.UID) []Revision
+}
+
+// RegisterDiff adds the input and output schemas of the diff action.
+func RegisterDiff(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(DiffChange{}, nil)
+	apiSchemas.MustImportAndCustomize(Diff{}, nil)
+	apiSchemas.MustImportAndCustomize(DiffInput{}, nil)
+}
+
+// addDiff adds the diff resource action to a schema, which returns the changes between the live object and a
+// submitted manifest, or between two revisions of the object kept by the history, without changing anything. The
+// sensitive fields of the revisions are masked, as those of the live object are.
+func addDiff(schema *types.APISchema, source revisionSource) {
+	if schema.ActionHandlers == nil {
+		schema.ActionHandlers = map[string]http.Handler{}
+	}
+	schema.ActionHandlers[diffAction] = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiOp := types.GetAPIContext(req.Context())
+		result, err := diff(apiOp, source)
+		if err != nil {
+			apiOp.WriteError(writer.FromStatus(err))
+			return
+		}
+		apiOp.WriteResponse(http.StatusOK, types.APIObject{
+			Type:   "diff",
+			Object: result,
+		})
+	})
+
+	if schema.ResourceActions == nil {
+		schema.ResourceActions = map[string]schemas.Action{}
+	}
+	schema.ResourceActions[diffAction] = schemas.Action{
+		Input:  "diffInput",
+		Output: "diff",
+	}
+}
+
+func diff(apiOp *types.APIRequest, source revisionSource) (Diff, error) {
+	var input DiffInput
+	if err := json.NewDecoder(apiOp.Request.Body).Decode(&input); err != nil && err != io.EOF {
+		return Diff{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	if input.Manifest == nil && input.From == "" {
+		return Diff{}, apierror.NewAPIError(validation.MissingRequired, "either manifest or from is required")
+	}
+
+	schema := apiOp.Schema
+	if schema.Store == nil {
+		return Diff{}, apierror.NewAPIError(validation.NotFound, "no store found")
+	}
+	obj, err := schema.Store.ByID(apiOp, schema, apiOp.Name)
+	if err != nil {
+		return Diff{}, err
+	}
+	m, err := meta.Accessor(obj.Object)
+	if err != nil {
+		return Diff{}, apierror.NewAPIError(validation.InvalidType, "object has no metadata")
+	}
+
+	result := Diff{From: liveRevision, To: liveRevision}
+	revisions := source.Revisions(m.GetUID())
+	for _, revision := range revisions {
+		result.Revisions = append(result.Revisions, revision.Object.GetResourceVersion())
+	}
+	live := map[string]interface{}(obj.Data())
+	from, to := live, live
+	if input.From != "" {
+		if from, err = revisionOf(schema, revisions, input.From); err != nil {
+			return Diff{}, err
+		}
+		result.From = input.From
+	}
+	if input.Manifest != nil {
+		to = withServerFields(input.Manifest, live)
+		result.To = manifestRevision
+	} else if input.To != "" {
+		if to, err = revisionOf(schema, revisions, input.To); err != nil {
+			return Diff{}, err
+		}
+		result.To = input.To
+	}
+
+	if result.Changes, err = diffObjects(from, to); err != nil {
+		return Diff{}, err
+	}
+	return result, nil
+}
+
+// revisionOf returns the kept revision with the resource version, with the sensitive fields of the schema masked.
+func revisionOf(schema *types.APISchema, revisions []Revision, resourceVersion string) (map[string]interface{}, error) {
+	for _, revision := range revisions {
+		if revision.Object.GetResourceVersion() == resourceVersion {
+			return redact.Mask(revision.Object.Object, redact.Fields(schema)), nil
+		}
+	}
+	return nil, apierror.NewAPIError(validation.NotFound, "revision "+resourceVersion+" is not kept")
+}
+
+// withServerFields returns a copy of the manifest with the server fields of the live object it leaves out.
+func withServerFields(manifest, live map[string]interface{}) map[string]interface{} {
+	result := (&unstructured.Unstructured{Object: manifest}).DeepCopy().Object
+	for _, field := range serverFields {
+		if _, ok, _ := unstructured.NestedFieldNoCopy(result, field...); ok {
+			continue
+		}
+		if value, ok, _ := unstructured.NestedFieldNoCopy(live, field...); ok {
+			_ = unstructured.SetNestedField(result, value, field...)
+		}
+	}
+	return result
+}
+
+// diffObjects returns the changes from one object to another, sorted by path. Both are compared as JSON, so that
+// numbers compare equal whatever their type.
+func diffObjects(from, to map[string]interface{}) ([]DiffChange, error) {
+	var (
+		normalized [2]map[string]interface{}
+		err        error
+	)
+	for i, obj := range []map[string]interface{}{from, to} {
+		if normalized[i], err = normalize(obj); err != nil {
+			return nil, err
+		}
+		for _, field := range ignoredFields {
+			unstructured.RemoveNestedField(normalized[i], field...)
+		}
+	}
+	changes := []DiffChange{}
+	diffValues("", normalized[0], normalized[1], &changes)
+	return changes, nil
+}
+
+func normalize(obj map[string]interface{}) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	return result, json.Unmarshal(bytes, &result)
+}
+
+// diffValues adds the changes from one value to another. Maps are compared by key, and lists of the same length by
+// index; any other difference replaces the value.
+func diffValues(path string, from, to interface{}, changes *[]DiffChange) {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		toValue, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for key := range fromValue {
+			keys[key] = true
+		}
+		for key := range toValue {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			fieldPath := path + "/" + escapePointer(key)
+			fromField, inFrom := fromValue[key]
+			toField, inTo := toValue[key]
+			switch {
+			case !inFrom:
+				*changes = append(*changes, DiffChange{Op: "add", Path: fieldPath, To: toField})
+			case !inTo:
+				*changes = append(*changes, DiffChange{Op: "remove", Path: fieldPath, From: fromField})
+			default:
+				diffValues(fieldPath, fromField, toField, changes)
+			}
+		}
+		return
+	case []interface{}:
+		toValue, ok := to.([]interface{})
+		if !ok || len(fromValue) != len(toValue) {
+			break
+		}
+		for i := range fromValue {
+			diffValues(path+"/"+strconv.Itoa(i), fromValue[i], toValue[i], changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, DiffChange{Op: "replace", Path: path, From: from, To: to})
+	}
+}
+
+// escapePointer escapes a key for a JSON pointer.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
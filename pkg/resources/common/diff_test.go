@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffObjects(t *testing.T) {
+	live := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"uid":             "1234",
+			"resourceVersion": "42",
+			"labels":          map[string]interface{}{"app": "web", "app.kubernetes.io/tier": "frontend"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:1.21"},
+			}}},
+		},
+		"status": map[string]interface{}{"replicas": int64(2)},
+	}
+	manifest := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "web",
+			"labels": map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"paused":   false,
+			"template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:1.23"},
+			}}},
+		},
+	}
+
+	changes, err := diffObjects(live, withServerFields(manifest, live))
+	require.NoError(t, err)
+	assert.Equal(t, []DiffChange{
+		{Op: "remove", Path: "/metadata/labels/app.kubernetes.io~1tier", From: "frontend"},
+		{Op: "add", Path: "/spec/paused", To: false},
+		{Op: "replace", Path: "/spec/template/spec/containers/0/image", From: "nginx:1.21", To: "nginx:1.23"},
+	}, changes)
+	_, ok := manifest["status"]
+	assert.False(t, ok, "the manifest is not changed")
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const eventsLink = "events"
+
+// EventSource lists the cached events of a namespace, as the wrangler event cache does.
+type EventSource interface {
+	List(namespace string, selector labels.Selector) ([]*corev1.Event, error)
+}
+
+// addEvents serves GET /v1/<type>/<id>?link=events with the events whose involved object is the object, newest
+// first, paged by the pagesize and page query parameters as lists are. Only the events the user may get are
+// included.
+func addEvents(apiSchema *types.APISchema, source EventSource) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if apiOp.Link != eventsLink || apiOp.Method != http.MethodGet {
+			return next(apiOp)
+		}
+		obj, err := next(apiOp)
+		if err != nil {
+			return obj, err
+		}
+		m, err := meta.Accessor(obj.Object)
+		if err != nil {
+			return obj, nil
+		}
+
+		// the events of cluster scoped objects, such as nodes, are recorded in the default namespace
+		namespace := m.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		events, err := source.List(namespace, labels.Everything())
+		if err != nil {
+			return types.APIObject{}, err
+		}
+		related := relatedEvents(apiOp, events, attributes.Kind(apiOp.Schema), m)
+		page, pages := listprocessor.PaginateList(related, listprocessor.ParseQuery(apiOp).Pagination)
+
+		data := make([]interface{}, 0, len(page))
+		for _, event := range page {
+			data = append(data, event.Object)
+		}
+		response := map[string]interface{}{
+			"data":  data,
+			"count": len(related),
+		}
+		if pages > 0 {
+			response["pages"] = pages
+		}
+		apiOp.Response.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(apiOp.Response).Encode(response); err != nil {
+			return types.APIObject{}, err
+		}
+		return types.APIObject{}, validation.ErrComplete
+	}
+}
+
+// relatedEvents returns the events of an object the user may get, newest first. Events are matched by the UID of
+// their involved object, or by its kind and name when they have no UID.
+func relatedEvents(apiOp *types.APIRequest, events []*corev1.Event, kind string, m metav1.Object) []types.APIObject {
+	var related []*corev1.Event
+	for _, event := range events {
+		involved := event.InvolvedObject
+		if involved.UID != "" {
+			if involved.UID != m.GetUID() {
+				continue
+			}
+		} else if involved.Kind != kind || involved.Name != m.GetName() || involved.Namespace != m.GetNamespace() {
+			continue
+		}
+		if !canGet(apiOp, "event", event.Namespace+"/"+event.Name) {
+			continue
+		}
+		related = append(related, event)
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		return lastSeen(related[i]).After(lastSeen(related[j]).Time)
+	})
+
+	result := make([]types.APIObject, 0, len(related))
+	for _, event := range related {
+		result = append(result, types.APIObject{
+			Type:   "event",
+			ID:     event.Namespace + "/" + event.Name,
+			Object: event,
+		})
+	}
+	return result
+}
+
+// lastSeen returns when an event last happened, from the first of its timestamps that is set.
+func lastSeen(event *corev1.Event) metav1.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return metav1.Time{Time: event.Series.LastObservedTime.Time}
+	case !event.EventTime.IsZero():
+		return metav1.Time{Time: event.EventTime.Time}
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp
+	}
+	return event.CreationTimestamp
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRelatedEvents(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	schema := types.APISchema{Schema: &schemas.Schema{ID: "event"}}
+	attributes.SetAccess(&schema, accesscontrol.AccessListByVerb{
+		"list": accesscontrol.AccessList{{Namespace: "default", ResourceName: "*"}},
+	})
+	require.NoError(t, apiSchemas.AddSchema(schema))
+	apiOp := &types.APIRequest{Schemas: apiSchemas}
+
+	now := time.Now()
+	event := func(namespace, name string, involved corev1.ObjectReference, last time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: namespace},
+			InvolvedObject: involved,
+			LastTimestamp:  metav1.NewTime(last),
+		}
+	}
+	pod := &metav1.ObjectMeta{Name: "web-1", Namespace: "default", UID: "uid-1"}
+	events := []*corev1.Event{
+		event("default", "scheduled", corev1.ObjectReference{Kind: "Pod", Name: "web-1", UID: "uid-1"}, now.Add(-time.Hour)),
+		event("default", "pulled", corev1.ObjectReference{Kind: "Pod", Name: "web-1", UID: "uid-1"}, now),
+		event("default", "old-pod", corev1.ObjectReference{Kind: "Pod", Name: "web-1", UID: "uid-0"}, now),
+		event("default", "no-uid", corev1.ObjectReference{Kind: "Pod", Name: "web-1", Namespace: "default"}, now.Add(-time.Minute)),
+		event("other", "hidden", corev1.ObjectReference{Kind: "Pod", Name: "web-1", UID: "uid-1"}, now),
+	}
+
+	var ids []string
+	for _, obj := range relatedEvents(apiOp, events, "Pod", pod) {
+		ids = append(ids, obj.ID)
+	}
+	assert.Equal(t, []string{"default/pulled", "default/no-uid", "default/scheduled"}, ids)
+}
@@ -7,8 +7,16 @@ import (
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
 	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/schema/table"
+	"github.com/rancher/steve/pkg/stores/admission"
+	"github.com/rancher/steve/pkg/stores/apiservice"
+	"github.com/rancher/steve/pkg/stores/audit"
 	metricsStore "github.com/rancher/steve/pkg/stores/metrics"
+	"github.com/rancher/steve/pkg/stores/partition"
 	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/steve/pkg/stores/ratelimit"
+	"github.com/rancher/steve/pkg/stores/redact"
+	"github.com/rancher/steve/pkg/stores/sqlcache"
 	"github.com/rancher/steve/pkg/summarycache"
 	"github.com/rancher/wrangler/pkg/data"
 	"github.com/rancher/wrangler/pkg/slice"
@@ -19,12 +27,77 @@ import (
 	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// TemplateOptions are the stores wrapped around the proxy store of the default template and the links added to its
+// schemas. The zero value serves the proxy store alone.
+type TemplateOptions struct {
+	// Store configures the partitioned lists and watches of the proxy store.
+	Store partition.Options
+	// RateLimits limits the requests of each user to the store.
+	RateLimits ratelimit.Options
+	// AuditSink records the store operations as configured by Audit if set.
+	AuditSink audit.Sink
+	Audit     audit.Options
+	// AdmissionHooks are run on objects before they are created or updated if set.
+	AdmissionHooks *admission.Hooks
+	// SQLCache serves the lists of the schemas it mirrors if set.
+	SQLCache *sqlcache.Cache
+	// Redact masks the sensitive fields of objects, such as the data of secrets, for the users not granted the
+	// unredacted verb on them. It is off by default: no default role grants the verb, so admins would be masked too.
+	Redact bool
+	// Availability fails requests to aggregated APIs whose backend is down if set.
+	Availability *apiservice.Availability
+	// Events adds the events link to objects if set.
+	Events EventSource
+	// History adds the revisions link to the objects of the schemas it keeps.
+	History *History
+}
+
 func DefaultTemplate(clientGetter proxy.ClientGetter,
 	summaryCache *summarycache.SummaryCache,
-	asl accesscontrol.AccessSetLookup) schema.Template {
+	asl accesscontrol.AccessSetLookup,
+	opts TemplateOptions) schema.Template {
+	var store types.Store = proxy.NewProxyStore(clientGetter, summaryCache, asl, opts.Store)
+	if opts.SQLCache != nil {
+		store = sqlcache.NewSQLCacheStore(store, opts.SQLCache, opts.Store)
+	}
+	if opts.AdmissionHooks != nil {
+		store = admission.NewAdmissionStore(store, opts.AdmissionHooks)
+	}
+	if opts.Redact {
+		store = redact.NewRedactStore(store, asl)
+	}
+	store = metricsStore.NewMetricsStore(store)
+	if opts.Availability != nil {
+		store = apiservice.NewAPIServiceStore(store, opts.Availability)
+	}
+	if opts.RateLimits.Enabled() {
+		store = ratelimit.NewRateLimitStore(store, opts.RateLimits)
+	}
+	if opts.AuditSink != nil {
+		store = audit.NewAuditStore(store, opts.AuditSink, opts.Audit)
+	}
 	return schema.Template{
-		Store:     metricsStore.NewMetricsStore(proxy.NewProxyStore(clientGetter, summaryCache, asl)),
-		Formatter: formatter(summaryCache),
+		Store:     store,
+		Formatter: formatter(summaryCache, opts.Events != nil),
+		Customize: func(apiSchema *types.APISchema) {
+			addBatch(apiSchema, opts.Store.Concurrency)
+			if summaryCache != nil && attributes.GVK(apiSchema).Kind != "" {
+				addGraph(apiSchema, summaryCache)
+				addDependents(apiSchema, summaryCache)
+				if slice.ContainsString(attributes.Verbs(apiSchema), "delete") {
+					addDeletePreview(apiSchema, summaryCache)
+				}
+			}
+			if opts.Events != nil && attributes.GVK(apiSchema).Kind != "" {
+				addEvents(apiSchema, opts.Events)
+			}
+			if attributes.GVK(apiSchema).Kind != "" {
+				addDiff(apiSchema, opts.History)
+			}
+			if opts.History.Keeps(apiSchema.ID) {
+				addRevisions(apiSchema, opts.History)
+			}
+		},
 	}
 }
 
@@ -50,7 +123,7 @@ func selfLink(gvr schema2.GroupVersionResource, meta metav1.Object) (prefix stri
 	return buf.String()
 }
 
-func formatter(summarycache *summarycache.SummaryCache) types.Formatter {
+func formatter(summarycache *summarycache.SummaryCache, events bool) types.Formatter {
 	return func(request *types.APIRequest, resource *types.RawResource) {
 		if resource.Schema == nil {
 			return
@@ -82,6 +155,20 @@ func formatter(summarycache *summarycache.SummaryCache) types.Formatter {
 			resource.Links["remove"] = "blocked"
 		}
 
+		if summarycache != nil {
+			resource.Links[graphLink] = request.URLBuilder.Link(resource.Schema, resource.ID, graphLink)
+			resource.Links[dependentsLink] = request.URLBuilder.Link(resource.Schema, resource.ID, dependentsLink)
+		}
+		if events {
+			resource.Links[eventsLink] = request.URLBuilder.Link(resource.Schema, resource.ID, eventsLink)
+		}
+
+		for _, subresource := range attributes.Subresources(resource.Schema) {
+			if subresource == "status" || subresource == "scale" {
+				resource.Links[subresource] = request.URLBuilder.Link(resource.Schema, resource.ID, subresource)
+			}
+		}
+
 		if unstr, ok := resource.APIObject.Object.(*unstructured.Unstructured); ok {
 			s, rel := summarycache.SummaryAndRelationship(unstr)
 			data.PutValue(unstr.Object, map[string]interface{}{
@@ -93,6 +180,7 @@ func formatter(summarycache *summarycache.SummaryCache) types.Formatter {
 			data.PutValue(unstr.Object, rel, "metadata", "relationships")
 
 			summary.NormalizeConditions(unstr)
+			addColumnFields(resource.Schema, unstr)
 
 			includeFields(request, unstr)
 			excludeFields(request, unstr)
@@ -102,6 +190,21 @@ func formatter(summarycache *summarycache.SummaryCache) types.Formatter {
 	}
 }
 
+// addColumnFields sets the cells of the printer columns of a CRD on an object that did not come from a table
+// request, such as the response of a create or update, so it has the same fields as listed objects.
+func addColumnFields(schema *types.APISchema, unstr *unstructured.Unstructured) {
+	columns, ok := attributes.Columns(schema).([]table.Column)
+	if !ok {
+		return
+	}
+	if _, ok := data.GetValue(unstr.Object, "metadata", "fields"); ok {
+		return
+	}
+	if cells := table.Cells(columns, unstr.Object); cells != nil {
+		data.PutValue(unstr.Object, cells, "metadata", "fields")
+	}
+}
+
 func includeFields(request *types.APIRequest, unstr *unstructured.Unstructured) {
 	if fields, ok := request.Query["include"]; ok {
 		newObj := map[string]interface{}{}
@@ -0,0 +1,202 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/summarycache"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/summary"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	graphLink = "graph"
+	// maxGraphDepth bounds how many relationships away from the object the graph reaches.
+	maxGraphDepth = 3
+)
+
+// Graph is the topology around an object: the objects it is related to, such as its owners, its dependents and
+// the objects its spec refers to, and the relationships between them.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is an object of a graph with its summarized state, which is empty if the object is not cached.
+type GraphNode struct {
+	Type          string `json:"type"`
+	ID            string `json:"id"`
+	State         string `json:"state,omitempty"`
+	Message       string `json:"message,omitempty"`
+	Error         bool   `json:"error,omitempty"`
+	Transitioning bool   `json:"transitioning,omitempty"`
+}
+
+// GraphEdge is a relationship from an object to another, or to the objects of a selector.
+type GraphEdge struct {
+	FromType string `json:"fromType"`
+	FromID   string `json:"fromId"`
+	ToType   string `json:"toType"`
+	ToID     string `json:"toId,omitempty"`
+	Rel      string `json:"rel"`
+	Selector string `json:"selector,omitempty"`
+}
+
+// relationshipSource looks up the summaries and relationships of objects, as the summary cache does.
+type relationshipSource interface {
+	SummaryAndRelationship(obj runtime.Object) (*summary.SummarizedObject, []summarycache.Relationship)
+	Relationships(gvk schema2.GroupVersionKind, namespace, name string) (*summary.SummarizedObject, []summarycache.Relationship, bool)
+}
+
+// addGraph serves GET /v1/<type>/<id>?link=graph with the graph of the object, reaching up to the depth query
+// parameter of relationships away, 1 by default. Only the objects the user may get are included.
+func addGraph(apiSchema *types.APISchema, source relationshipSource) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if apiOp.Link != graphLink || apiOp.Method != http.MethodGet {
+			return next(apiOp)
+		}
+		obj, err := next(apiOp)
+		if err != nil {
+			return obj, err
+		}
+		unstr, ok := obj.Object.(*unstructured.Unstructured)
+		if !ok {
+			return obj, nil
+		}
+
+		depth, err := strconv.Atoi(apiOp.Request.URL.Query().Get("depth"))
+		if err != nil || depth < 1 {
+			depth = 1
+		} else if depth > maxGraphDepth {
+			depth = maxGraphDepth
+		}
+
+		apiOp.Response.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(apiOp.Response).Encode(buildGraph(apiOp, source, apiOp.Schema.ID, obj.ID, unstr, depth)); err != nil {
+			return types.APIObject{}, err
+		}
+		return types.APIObject{}, validation.ErrComplete
+	}
+}
+
+// buildGraph walks the relationships from the object breadth first, so each object is expanded once.
+func buildGraph(apiOp *types.APIRequest, source relationshipSource, schemaID, id string, obj runtime.Object, depth int) Graph {
+	graph := Graph{
+		Nodes: []GraphNode{},
+		Edges: []GraphEdge{},
+	}
+	seen := map[string]bool{}
+	seenEdges := map[GraphEdge]bool{}
+
+	summarized, rels := source.SummaryAndRelationship(obj)
+	root := toNode(schemaID, id, summarized)
+	graph.Nodes = append(graph.Nodes, root)
+	seen[nodeKey(schemaID, id)] = true
+
+	type expansion struct {
+		node GraphNode
+		rels []summarycache.Relationship
+	}
+	frontier := []expansion{{node: root, rels: rels}}
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		var next []expansion
+		for _, current := range frontier {
+			for _, rel := range current.rels {
+				edge, otherType, otherID := toEdge(current.node, rel)
+				if otherID != "" && !canGet(apiOp, otherType, otherID) {
+					continue
+				}
+				if !seenEdges[edge] {
+					seenEdges[edge] = true
+					graph.Edges = append(graph.Edges, edge)
+				}
+				if otherID == "" || seen[nodeKey(otherType, otherID)] {
+					continue
+				}
+				seen[nodeKey(otherType, otherID)] = true
+
+				node := GraphNode{Type: otherType, ID: otherID}
+				var otherRels []summarycache.Relationship
+				if otherSchema := apiOp.Schemas.LookupSchema(otherType); otherSchema != nil {
+					namespace, name := splitID(otherID)
+					if otherSummary, r, ok := source.Relationships(attributes.GVK(otherSchema), namespace, name); ok {
+						node = toNode(otherType, otherID, otherSummary)
+						otherRels = r
+					}
+				}
+				graph.Nodes = append(graph.Nodes, node)
+				next = append(next, expansion{node: node, rels: otherRels})
+			}
+		}
+		frontier = next
+	}
+
+	return graph
+}
+
+// toEdge returns the edge of a relationship of a node, with the type and ID of the object at its other end.
+func toEdge(node GraphNode, rel summarycache.Relationship) (GraphEdge, string, string) {
+	if rel.FromType != "" {
+		return GraphEdge{
+			FromType: rel.FromType,
+			FromID:   rel.FromID,
+			ToType:   node.Type,
+			ToID:     node.ID,
+			Rel:      rel.Rel,
+		}, rel.FromType, rel.FromID
+	}
+	return GraphEdge{
+		FromType: node.Type,
+		FromID:   node.ID,
+		ToType:   rel.ToType,
+		ToID:     rel.ToID,
+		Rel:      rel.Rel,
+		Selector: rel.Selector,
+	}, rel.ToType, rel.ToID
+}
+
+func toNode(schemaID, id string, summarized *summary.SummarizedObject) GraphNode {
+	return GraphNode{
+		Type:          schemaID,
+		ID:            id,
+		State:         summarized.State,
+		Message:       strings.Join(summarized.Message, "; "),
+		Error:         summarized.Error,
+		Transitioning: summarized.Transitioning,
+	}
+}
+
+// canGet returns whether the user may get or list the object of the type.
+func canGet(apiOp *types.APIRequest, schemaID, id string) bool {
+	schema := apiOp.Schemas.LookupSchema(schemaID)
+	if schema == nil {
+		return false
+	}
+	access := accesscontrol.GetAccessListMap(schema)
+	namespace, name := splitID(id)
+	return access.Grants("get", namespace, name) || access.Grants("list", namespace, name)
+}
+
+func splitID(id string) (string, string) {
+	if namespace, name, ok := strings.Cut(id, "/"); ok {
+		return namespace, name
+	}
+	return "", id
+}
+
+func nodeKey(schemaID, id string) string {
+	return schemaID + "#" + id
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/summarycache"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type testSource map[string][]summarycache.Relationship
+
+func (t testSource) SummaryAndRelationship(obj runtime.Object) (*summary.SummarizedObject, []summarycache.Relationship) {
+	return &summary.SummarizedObject{Summary: summary.Summary{State: "running"}}, t["pod:default/web-1"]
+}
+
+func (t testSource) Relationships(gvk schema2.GroupVersionKind, namespace, name string) (*summary.SummarizedObject, []summarycache.Relationship, bool) {
+	rels, ok := t[gvk.Kind+":"+namespace+"/"+name]
+	if !ok {
+		return nil, nil, false
+	}
+	return &summary.SummarizedObject{
+		PartialObjectMetadata: metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}},
+		Summary:               summary.Summary{State: "active"},
+	}, rels, true
+}
+
+func TestBuildGraph(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	for _, kind := range []string{"pod", "replicaset", "deployment", "secret"} {
+		schema := types.APISchema{Schema: &schemas.Schema{ID: kind}}
+		attributes.SetGVK(&schema, schema2.GroupVersionKind{Version: "v1", Kind: kind})
+		names := "*"
+		if kind == "secret" {
+			names = "other"
+		}
+		attributes.SetAccess(&schema, accesscontrol.AccessListByVerb{
+			"get": accesscontrol.AccessList{{Namespace: "*", ResourceName: names}},
+		})
+		require.NoError(t, apiSchemas.AddSchema(schema))
+	}
+
+	source := testSource{
+		"pod:default/web-1": {
+			{FromType: "replicaset", FromID: "default/web-abc", Rel: "owner"},
+			{ToType: "secret", ToID: "default/web-tls", Rel: "uses"},
+		},
+		"replicaset:default/web-abc": {
+			{FromType: "deployment", FromID: "default/web", Rel: "owner"},
+			{ToType: "pod", ToID: "default/web-1", Rel: "owner"},
+		},
+		"deployment:default/web": {
+			{ToType: "replicaset", ToID: "default/web-abc", Rel: "owner"},
+		},
+	}
+	apiOp := &types.APIRequest{Schemas: apiSchemas}
+	pod := &unstructured.Unstructured{}
+
+	tests := []struct {
+		name      string
+		depth     int
+		wantNodes []string
+		wantEdges int
+	}{
+		{
+			name:      "owner",
+			depth:     1,
+			wantNodes: []string{"pod:default/web-1", "replicaset:default/web-abc"},
+			wantEdges: 1,
+		},
+		{
+			name:      "owner of owner",
+			depth:     2,
+			wantNodes: []string{"pod:default/web-1", "replicaset:default/web-abc", "deployment:default/web"},
+			wantEdges: 2,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			graph := buildGraph(apiOp, source, "pod", "default/web-1", pod, test.depth)
+			var nodes []string
+			for _, node := range graph.Nodes {
+				nodes = append(nodes, node.Type+":"+node.ID)
+			}
+			assert.Equal(t, test.wantNodes, nodes)
+			assert.Len(t, graph.Edges, test.wantEdges)
+			assert.Equal(t, "running", graph.Nodes[0].State)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/steve/pkg/schema/converter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// HistoryOptions configure the revisions kept of the objects of the cluster cache.
+type HistoryOptions struct {
+	// Revisions is the number of revisions kept of each object of a schema, by schema ID, such as 10 for
+	// apps.deployment. No revisions are kept of the objects of other schemas.
+	Revisions map[string]int
+}
+
+// Enabled returns whether revisions are kept of any schema.
+func (o HistoryOptions) Enabled() bool {
+	for _, n := range o.Revisions {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHistoryRevisions parses comma separated revision counts of schemas, as schema=count, such as
+// apps.deployment=10,configmap=5.
+func ParseHistoryRevisions(value string) (map[string]int, error) {
+	result := map[string]int{}
+	for _, revisions := range strings.Split(value, ",") {
+		revisions = strings.TrimSpace(revisions)
+		if revisions == "" {
+			continue
+		}
+		schemaID, count, ok := strings.Cut(revisions, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid history revisions %q, expected schema=count", revisions)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid history revisions %q, expected a count of at least zero", revisions)
+		}
+		result[schemaID] = n
+	}
+	return result, nil
+}
+
+// Revision is a revision of an object, as received from the watch of the cluster cache.
+type Revision struct {
+	Object   *unstructured.Unstructured
+	Observed time.Time
+}
+
+// History keeps the last revisions of each object of the schemas of its options, in a ring buffer per object fed
+// by the watch events of the cluster cache. The latest revision is the object of the cache, so objects that don't
+// change cost little more. A nil History keeps nothing.
+type History struct {
+	sync.RWMutex
+	options HistoryOptions
+	now     func() time.Time
+	rings   map[k8stypes.UID]*ring
+}
+
+// NewHistory returns a History recording the changes of the objects of the cluster cache.
+func NewHistory(ctx context.Context, ccache clustercache.ClusterCache, options HistoryOptions) *History {
+	h := &History{
+		options: options,
+		now:     time.Now,
+		rings:   map[k8stypes.UID]*ring{},
+	}
+	ccache.OnAdd(ctx, func(gvk schema.GroupVersionKind, _ string, obj runtime.Object) error {
+		h.add(gvk, obj)
+		return nil
+	})
+	ccache.OnChange(ctx, func(gvk schema.GroupVersionKind, _ string, obj, _ runtime.Object) error {
+		h.add(gvk, obj)
+		return nil
+	})
+	ccache.OnRemove(ctx, func(_ schema.GroupVersionKind, _ string, obj runtime.Object) error {
+		h.remove(obj)
+		return nil
+	})
+	return h
+}
+
+// Keeps returns whether revisions are kept of the objects of the schema.
+func (h *History) Keeps(schemaID string) bool {
+	return h != nil && h.options.Revisions[schemaID] > 0
+}
+
+// Revisions returns the kept revisions of the object with the UID, oldest first.
+func (h *History) Revisions(uid k8stypes.UID) []Revision {
+	if h == nil {
+		return nil
+	}
+	h.RLock()
+	defer h.RUnlock()
+	if r := h.rings[uid]; r != nil {
+		return r.list()
+	}
+	return nil
+}
+
+func (h *History) add(gvk schema.GroupVersionKind, obj runtime.Object) {
+	size := h.options.Revisions[converter.GVKToSchemaID(gvk)]
+	u, ok := obj.(*unstructured.Unstructured)
+	if size <= 0 || !ok || u.GetUID() == "" {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	r := h.rings[u.GetUID()]
+	if r == nil {
+		r = &ring{revisions: make([]Revision, 0, size)}
+		h.rings[u.GetUID()] = r
+	}
+	r.add(Revision{Object: u, Observed: h.now()})
+}
+
+func (h *History) remove(obj runtime.Object) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	delete(h.rings, u.GetUID())
+}
+
+// ring holds the last revisions of an object, up to its capacity. Once full, next is the oldest revision, which the
+// next revision added replaces.
+type ring struct {
+	revisions []Revision
+	next      int
+}
+
+// add adds a revision, replacing the latest revision instead if it has the same resource version, as the resyncs
+// of the watch do.
+func (r *ring) add(revision Revision) {
+	if n := len(r.revisions); n > 0 {
+		latest := (r.next - 1 + n) % n
+		if r.revisions[latest].Object.GetResourceVersion() == revision.Object.GetResourceVersion() {
+			r.revisions[latest].Object = revision.Object
+			return
+		}
+	}
+	if len(r.revisions) < cap(r.revisions) {
+		r.revisions = append(r.revisions, revision)
+		r.next = len(r.revisions) % cap(r.revisions)
+		return
+	}
+	r.revisions[r.next] = revision
+	r.next = (r.next + 1) % len(r.revisions)
+}
+
+// list returns the revisions oldest first.
+func (r *ring) list() []Revision {
+	result := make([]Revision, 0, len(r.revisions))
+	if len(r.revisions) < cap(r.revisions) {
+		return append(result, r.revisions...)
+	}
+	result = append(result, r.revisions[r.next:]...)
+	return append(result, r.revisions[:r.next]...)
+}
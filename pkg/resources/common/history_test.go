@@ -0,0 +1,65 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestHistory(t *testing.T) {
+	observed := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := &History{
+		options: HistoryOptions{Revisions: map[string]int{"configmap": 2}},
+		now:     func() time.Time { return observed },
+		rings:   map[k8stypes.UID]*ring{},
+	}
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	revision := func(resourceVersion string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetUID("1234")
+		u.SetResourceVersion(resourceVersion)
+		return u
+	}
+	h.add(configMap, revision("1"))
+	h.add(configMap, revision("2"))
+	h.add(configMap, revision("2"))
+	h.add(configMap, revision("3"))
+	h.add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, revision("4"))
+
+	assert.True(t, h.Keeps("configmap"))
+	assert.False(t, h.Keeps("secret"))
+	var kept []string
+	for _, r := range h.Revisions("1234") {
+		kept = append(kept, r.Object.GetResourceVersion())
+		assert.Equal(t, observed, r.Observed)
+	}
+	assert.Equal(t, []string{"2", "3"}, kept)
+
+	h.remove(revision("3"))
+	assert.Empty(t, h.Revisions("1234"))
+
+	var none *History
+	assert.False(t, none.Keeps("configmap"))
+	assert.Empty(t, none.Revisions("1234"))
+}
+
+func TestRevisionOfMasksSensitiveFields(t *testing.T) {
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{"password": "aHVudGVyMg=="},
+	}}
+	secret.SetResourceVersion("1")
+	secretSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret"}}
+	attributes.SetGVK(secretSchema, schema.GroupVersionKind{Version: "v1", Kind: "Secret"})
+
+	obj, err := revisionOf(secretSchema, []Revision{{Object: secret}}, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"password": ""}, obj["data"])
+	assert.Equal(t, "aHVudGVyMg==", secret.Object["data"].(map[string]interface{})["password"], "the kept revision is not changed")
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/redact"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+const revisionsLink = "revisions"
+
+// ObjectRevision is a kept revision of an object, with when it was observed and the changes from the revision
+// before it.
+type ObjectRevision struct {
+	ResourceVersion string                 `json:"resourceVersion"`
+	Observed        time.Time              `json:"observed"`
+	Changes         []DiffChange           `json:"changes,omitempty"`
+	Object          map[string]interface{} `json:"object"`
+}
+
+// addRevisions serves GET /v1/<type>/<id>?link=revisions with the revisions the history keeps of the object,
+// newest first, so what changed can be seen without an audit log. The sensitive fields of the revisions are
+// masked.
+func addRevisions(apiSchema *types.APISchema, source revisionSource) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if apiOp.Link != revisionsLink || apiOp.Method != http.MethodGet {
+			return next(apiOp)
+		}
+		obj, err := next(apiOp)
+		if err != nil {
+			return obj, err
+		}
+		m, err := meta.Accessor(obj.Object)
+		if err != nil {
+			return obj, nil
+		}
+		revisions, err := objectRevisions(source.Revisions(m.GetUID()), redact.Fields(apiOp.Schema))
+		if err != nil {
+			return types.APIObject{}, err
+		}
+
+		apiOp.Response.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(apiOp.Response).Encode(map[string]interface{}{
+			"data": revisions,
+		}); err != nil {
+			return types.APIObject{}, err
+		}
+		return types.APIObject{}, validation.ErrComplete
+	}
+}
+
+// objectRevisions returns the revisions newest first, each with the changes from the one before it, and with the
+// sensitive fields masked.
+func objectRevisions(revisions []Revision, sensitiveFields [][]string) ([]ObjectRevision, error) {
+	result := make([]ObjectRevision, len(revisions))
+	var previous map[string]interface{}
+	for i, revision := range revisions {
+		obj := redact.Mask(revision.Object.Object, sensitiveFields)
+		r := ObjectRevision{
+			ResourceVersion: revision.Object.GetResourceVersion(),
+			Observed:        revision.Observed,
+			Object:          obj,
+		}
+		if previous != nil {
+			changes, err := diffObjects(previous, obj)
+			if err != nil {
+				return nil, err
+			}
+			r.Changes = changes
+		}
+		previous = obj
+		result[len(revisions)-1-i] = r
+	}
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectRevisions(t *testing.T) {
+	observed := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	revision := func(resourceVersion, value string) Revision {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"data": map[string]interface{}{"key": value, "password": value},
+		}}
+		u.SetResourceVersion(resourceVersion)
+		return Revision{Object: u, Observed: observed.Add(time.Minute * time.Duration(len(resourceVersion)))}
+	}
+	kept := []Revision{revision("2", "b"), revision("33", "c")}
+
+	revisions, err := objectRevisions(kept, [][]string{{"data", "password"}})
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "33", revisions[0].ResourceVersion, "the newest revision is first")
+	assert.Equal(t, observed.Add(2*time.Minute), revisions[0].Observed)
+	assert.Equal(t, []DiffChange{{Op: "replace", Path: "/data/key", From: "b", To: "c"}}, revisions[0].Changes)
+	assert.Equal(t, "", revisions[0].Object["data"].(map[string]interface{})["password"])
+	assert.Equal(t, "2", revisions[1].ResourceVersion)
+	assert.Empty(t, revisions[1].Changes)
+	assert.Equal(t, "c", kept[1].Object.Object["data"].(map[string]interface{})["password"], "the kept revision is not changed")
+}
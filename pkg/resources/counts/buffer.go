@@ -15,6 +15,8 @@ func buffer(c chan types.APIEvent) chan types.APIEvent {
 	return result
 }
 
+// debounce sends at most one event a second, holding the latest counts of every type that changed since the
+// previous one.
 func debounce(result, input chan types.APIEvent) {
 	t := time.NewTicker(time.Second)
 	defer t.Stop()
@@ -26,7 +28,7 @@ func debounce(result, input chan types.APIEvent) {
 		select {
 		case event, ok := <-input:
 			if ok {
-				lastEvent = &event
+				lastEvent = merge(lastEvent, event)
 			} else {
 				return
 			}
@@ -38,3 +40,29 @@ func debounce(result, input chan types.APIEvent) {
 		}
 	}
 }
+
+// merge returns the pending event with the counts of the next event added, replacing those of the same types.
+func merge(pending *types.APIEvent, next types.APIEvent) *types.APIEvent {
+	if pending == nil {
+		return &next
+	}
+	pendingCount, ok := pending.Object.Object.(Count)
+	if !ok {
+		return &next
+	}
+	nextCount, ok := next.Object.Object.(Count)
+	if !ok {
+		return &next
+	}
+
+	counts := make(map[string]ItemCount, len(pendingCount.Counts)+len(nextCount.Counts))
+	for id, itemCount := range pendingCount.Counts {
+		counts[id] = itemCount
+	}
+	for id, itemCount := range nextCount.Counts {
+		counts[id] = itemCount
+	}
+	nextCount.Counts = counts
+	next.Object = toAPIObject(nextCount)
+	return &next
+}
@@ -42,14 +42,19 @@ func Register(schemas *types.APISchemas, ccache clustercache.ClusterCache) {
 	})
 }
 
+// Count holds the counts of each type the user may list and watch, by namespace and by state. The events of a
+// watch carry only the counts of the types that changed since the previous event.
 type Count struct {
 	ID     string               `json:"id,omitempty"`
 	Counts map[string]ItemCount `json:"counts"`
 }
 
+// Summary counts objects. States counts the objects in error or in progress, ByState counts them by their
+// summarized state, such as the phase of a pod.
 type Summary struct {
 	Count         int            `json:"count,omitempty"`
 	States        map[string]int `json:"states,omitempty"`
+	ByState       map[string]int `json:"byState,omitempty"`
 	Error         int            `json:"errors,omitempty"`
 	Transitioning int            `json:"transitioning,omitempty"`
 }
@@ -62,6 +67,12 @@ func (s *Summary) DeepCopy() *Summary {
 			r.States[k] = s.States[k]
 		}
 	}
+	if r.ByState != nil {
+		r.ByState = map[string]int{}
+		for k := range s.ByState {
+			r.ByState[k] = s.ByState[k]
+		}
+	}
 	return &r
 }
 
@@ -163,6 +174,7 @@ func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.
 			if _, _, _, oldSummary, ok := getInfo(oldObj); ok {
 				if oldSummary.Transitioning == summary.Transitioning &&
 					oldSummary.Error == summary.Error &&
+					oldSummary.State == summary.State &&
 					simpleState(oldSummary) == simpleState(summary) {
 					return nil
 				}
@@ -178,17 +190,14 @@ func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.
 		}
 
 		counts[schema.ID] = itemCount
-		countsCopy := map[string]ItemCount{}
-		for k, v := range counts {
-			countsCopy[k] = *v.DeepCopy()
-		}
-
 		result <- types.APIEvent{
 			Name:         "resource.change",
 			ResourceType: "counts",
 			Object: toAPIObject(Count{
-				ID:     "count",
-				Counts: countsCopy,
+				ID: "count",
+				Counts: map[string]ItemCount{
+					schema.ID: *itemCount.DeepCopy(),
+				},
 			}),
 		}
 
@@ -255,7 +264,11 @@ func getInfo(obj interface{}) (name string, namespace string, revision int, summ
 func removeCounts(itemCount ItemCount, ns string, summary summary.Summary) ItemCount {
 	itemCount.Summary = removeSummary(itemCount.Summary, summary)
 	if ns != "" {
-		itemCount.Namespaces[ns] = removeSummary(itemCount.Namespaces[ns], summary)
+		if nsCount := removeSummary(itemCount.Namespaces[ns], summary); nsCount.Count > 0 {
+			itemCount.Namespaces[ns] = nsCount
+		} else {
+			delete(itemCount.Namespaces, ns)
+		}
 	}
 	return itemCount
 }
@@ -282,6 +295,11 @@ func removeSummary(counts Summary, summary summary.Summary) Summary {
 		}
 		counts.States[simpleState(summary)]--
 	}
+	if summary.State != "" && counts.ByState != nil {
+		if counts.ByState[summary.State]--; counts.ByState[summary.State] <= 0 {
+			delete(counts.ByState, summary.State)
+		}
+	}
 	return counts
 }
 
@@ -299,6 +317,12 @@ func addSummary(counts Summary, summary summary.Summary) Summary {
 		}
 		counts.States[simpleState(summary)]++
 	}
+	if summary.State != "" {
+		if counts.ByState == nil {
+			counts.ByState = map[string]int{}
+		}
+		counts.ByState[summary.State]++
+	}
 	return counts
 }
 
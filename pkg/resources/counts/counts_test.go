@@ -0,0 +1,56 @@
+package counts
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounts(t *testing.T) {
+	running := summary.Summary{State: "running"}
+	pending := summary.Summary{State: "pending", Transitioning: true}
+
+	itemCount := ItemCount{Namespaces: map[string]Summary{}}
+	itemCount = addCounts(itemCount, "default", running)
+	itemCount = addCounts(itemCount, "default", pending)
+	itemCount = addCounts(itemCount, "kube-system", running)
+
+	assert.Equal(t, Summary{
+		Count:         3,
+		States:        map[string]int{"in-progress": 1},
+		ByState:       map[string]int{"running": 2, "pending": 1},
+		Transitioning: 1,
+	}, itemCount.Summary)
+	assert.Equal(t, map[string]int{"running": 1, "pending": 1}, itemCount.Namespaces["default"].ByState)
+
+	itemCount = removeCounts(itemCount, "default", pending)
+	itemCount = removeCounts(itemCount, "kube-system", running)
+
+	assert.Equal(t, map[string]int{"running": 1}, itemCount.Summary.ByState)
+	assert.Equal(t, 1, itemCount.Summary.Count)
+	assert.NotContains(t, itemCount.Namespaces, "kube-system")
+}
+
+func TestMerge(t *testing.T) {
+	event := func(counts map[string]ItemCount) types.APIEvent {
+		return types.APIEvent{
+			Name:   "resource.change",
+			Object: toAPIObject(Count{ID: "count", Counts: counts}),
+		}
+	}
+
+	pending := merge(nil, event(map[string]ItemCount{
+		"pod":        {Summary: Summary{Count: 1}},
+		"deployment": {Summary: Summary{Count: 1}},
+	}))
+	pending = merge(pending, event(map[string]ItemCount{
+		"pod": {Summary: Summary{Count: 2}},
+	}))
+
+	assert.Equal(t, map[string]ItemCount{
+		"pod":        {Summary: Summary{Count: 2}},
+		"deployment": {Summary: Summary{Count: 1}},
+	}, pending.Object.Object.(Count).Counts)
+}
@@ -0,0 +1,295 @@
+// Package crds serves POST /v1/crdapply, which creates or updates the custom resource definitions of a YAML body
+// after checking that the apiserver will accept them and that their conversion webhook is reachable, and waits
+// until their types are served.
+package crds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	steveschema "github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/yaml"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	maxApplySize = 10 << 20
+	dryRunQP     = "dryRun"
+	fieldManager = "steve"
+	crdSchemaID  = "apiextensions.k8s.io.customresourcedefinition"
+
+	// establishTimeout is how long to wait for the types of applied definitions to be served before returning.
+	establishTimeout = 30 * time.Second
+	establishPoll    = 250 * time.Millisecond
+)
+
+var crdGVR = apiextv1.SchemeGroupVersion.WithResource("customresourcedefinitions")
+
+// CRDApply is the result of applying custom resource definitions, with a result for each definition of the YAML in
+// the same order.
+type CRDApply struct {
+	ID      string      `json:"id,omitempty"`
+	DryRun  bool        `json:"dryRun,omitempty"`
+	Results []CRDResult `json:"results"`
+}
+
+// CRDResult is the outcome of applying a single custom resource definition.
+type CRDResult struct {
+	Name    string `json:"name"`
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Established is whether the apiserver serves the resources of the definition.
+	Established bool `json:"established"`
+	// SchemaID is the ID of the schema of the resources of the definition, once steve serves them.
+	SchemaID string `json:"schemaId,omitempty"`
+}
+
+// Register adds the crdapply type: POST /v1/crdapply with a YAML body of custom resource definitions, and
+// ?dryRun=true to only check them. The definitions are applied as the user with a server-side apply patch, so the
+// user needs to be allowed to patch customresourcedefinitions, and to create those that don't exist yet. Their
+// conversion webhook services are looked up as the user too.
+func Register(apiSchemas *types.APISchemas, cg proxy.ClientGetter, schemaFactory steveschema.Factory) {
+	apiSchemas.MustImportAndCustomize(CRDResult{}, nil)
+	apiSchemas.InternalSchemas.TypeName("crdapply", CRDApply{})
+	apiSchemas.MustImportAndCustomize(CRDApply{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodPost}
+		schema.ResourceMethods = []string{}
+		a := &applier{
+			cg:            cg,
+			schemaFactory: schemaFactory,
+		}
+		schema.CreateHandler = a.create
+	})
+}
+
+type applier struct {
+	cg            proxy.ClientGetter
+	schemaFactory steveschema.Factory
+}
+
+// document is a definition of the body, as uploaded and decoded.
+type document struct {
+	obj *unstructured.Unstructured
+	crd *apiextv1.CustomResourceDefinition
+}
+
+// create checks every definition of the body, and applies them only if none has a problem, so that a broken
+// definition is rejected before anything is changed.
+func (a *applier) create(apiOp *types.APIRequest) (types.APIObject, error) {
+	docs, err := a.decode(apiOp)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := a.authorize(apiOp, docs); err != nil {
+		return types.APIObject{}, err
+	}
+
+	k8s, err := a.cg.K8sInterface(apiOp)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	var problems []string
+	for _, doc := range docs {
+		for _, problem := range validate(apiOp.Context(), k8s, doc.crd) {
+			problems = append(problems, doc.crd.Name+": "+problem)
+		}
+	}
+	if len(problems) > 0 {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, strings.Join(problems, "; "))
+	}
+
+	client, err := a.cg.DynamicClient(apiOp)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	dryRun := apiOp.Request.URL.Query().Get(dryRunQP) == "true"
+	result := CRDApply{
+		DryRun:  dryRun,
+		Results: make([]CRDResult, len(docs)),
+	}
+	for i, doc := range docs {
+		result.Results[i] = a.apply(apiOp, client.Resource(crdGVR), doc, dryRun)
+	}
+	if !dryRun {
+		a.wait(apiOp, client.Resource(crdGVR), docs, result.Results)
+	}
+	return types.APIObject{
+		Type:   "crdapply",
+		Object: result,
+	}, nil
+}
+
+// authorize checks that the user may apply every definition, before any is validated: that the user may patch it,
+// and create it if it doesn't exist yet.
+func (a *applier) authorize(apiOp *types.APIRequest, docs []document) error {
+	schema := apiOp.Schemas.LookupSchema(crdSchemaID)
+	if schema == nil {
+		return apierror.NewAPIError(validation.PermissionDenied, "can not apply customresourcedefinitions")
+	}
+	access, _ := attributes.Access(schema).(accesscontrol.AccessListByVerb)
+	for _, doc := range docs {
+		name := doc.crd.Name
+		if !access.Grants("patch", accesscontrol.All, name) {
+			return apierror.NewAPIError(validation.PermissionDenied, "can not patch customresourcedefinition "+name)
+		}
+		if access.Grants("create", accesscontrol.All, name) {
+			continue
+		}
+		admin, err := a.cg.AdminClient(apiOp, schema, "")
+		if err != nil {
+			return err
+		}
+		if _, err := admin.Get(apiOp.Context(), name, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			return apierror.NewAPIError(validation.PermissionDenied, "can not create customresourcedefinition "+name)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode returns the custom resource definitions of the YAML body, rejecting other objects.
+func (a *applier) decode(apiOp *types.APIRequest) ([]document, error) {
+	body, err := writer.ReadBody(apiOp, maxApplySize)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := yaml.ToObjects(bytes.NewReader(body))
+	if err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	if len(objs) == 0 {
+		return nil, apierror.NewAPIError(validation.MissingRequired, "no custom resource definitions found")
+	}
+	var result []document
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok || u.GroupVersionKind() != apiextv1.SchemeGroupVersion.WithKind("CustomResourceDefinition") {
+			return nil, apierror.NewAPIError(validation.InvalidBodyContent,
+				fmt.Sprintf("%s is not an %s CustomResourceDefinition", obj.GetObjectKind().GroupVersionKind(), apiextv1.SchemeGroupVersion))
+		}
+		crd := &apiextv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, crd); err != nil {
+			return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+		}
+		result = append(result, document{obj: u, crd: crd})
+	}
+	return result, nil
+}
+
+// apply applies a definition as uploaded, with a server-side apply patch.
+func (a *applier) apply(apiOp *types.APIRequest, client dynamic.ResourceInterface, doc document, dryRun bool) CRDResult {
+	result := CRDResult{Name: doc.crd.Name}
+	body, err := json.Marshal(doc.obj.Object)
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Message = err.Error()
+		return result
+	}
+
+	opts := metav1.PatchOptions{FieldManager: fieldManager}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	if _, err := client.Patch(apiOp.Context(), doc.crd.Name, apitypes.ApplyPatchType, body, opts); err != nil {
+		result.Status = http.StatusInternalServerError
+		if apiErr, ok := writer.FromStatus(err).(*apierror.APIError); ok {
+			result.Status = apiErr.Code.Status
+		}
+		result.Message = err.Error()
+		return result
+	}
+	result.Status = http.StatusOK
+	return result
+}
+
+// wait waits, for at most the establish timeout, until the applied definitions are established and steve serves
+// a schema of their resources, which the schema controller adds as soon as it sees the definition change. The
+// results of the definitions that aren't by then are returned as they are.
+func (a *applier) wait(apiOp *types.APIRequest, client dynamic.ResourceInterface, docs []document, results []CRDResult) {
+	deadline := time.NewTimer(establishTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(establishPoll)
+	defer ticker.Stop()
+
+	for {
+		pending := false
+		for i, doc := range docs {
+			result := &results[i]
+			if result.Status != http.StatusOK || result.SchemaID != "" {
+				continue
+			}
+			if !result.Established {
+				result.Established, result.Message = established(apiOp, client, doc.crd.Name)
+			}
+			if result.Established {
+				result.SchemaID = a.schemaID(doc.crd)
+			}
+			pending = pending || result.SchemaID == ""
+		}
+		if !pending {
+			return
+		}
+		select {
+		case <-apiOp.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// established returns whether a definition is established, or why its names were not accepted.
+func established(apiOp *types.APIRequest, client dynamic.ResourceInterface, name string) (bool, string) {
+	obj, err := client.Get(apiOp.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		return false, err.Error()
+	}
+	crd := &apiextv1.CustomResourceDefinition{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, crd); err != nil {
+		return false, err.Error()
+	}
+	var message string
+	for _, condition := range crd.Status.Conditions {
+		switch {
+		case condition.Type == apiextv1.Established && condition.Status == apiextv1.ConditionTrue:
+			return true, ""
+		case condition.Type == apiextv1.NamesAccepted && condition.Status == apiextv1.ConditionFalse:
+			message = condition.Message
+		}
+	}
+	return false, message
+}
+
+// schemaID returns the ID of the schema steve serves for a served version of a definition, if any.
+func (a *applier) schemaID(crd *apiextv1.CustomResourceDefinition) string {
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+		if id := a.schemaFactory.ByGVK(gvk); id != "" {
+			return id
+		}
+	}
+	return ""
+}
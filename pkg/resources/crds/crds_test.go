@@ -0,0 +1,60 @@
+package crds
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		access accesscontrol.AccessListByVerb
+		denied bool
+	}{
+		{
+			name:   "no access to customresourcedefinitions",
+			denied: true,
+		},
+		{
+			name:   "patch of another definition",
+			access: accesscontrol.AccessListByVerb{"patch": {{Namespace: "*", ResourceName: "gadgets.example.com"}}},
+			denied: true,
+		},
+		{
+			name: "patch and create",
+			access: accesscontrol.AccessListByVerb{
+				"patch":  {{Namespace: "*", ResourceName: "widgets.example.com"}},
+				"create": {{Namespace: "*", ResourceName: "*"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			apiSchemas := types.EmptyAPISchemas()
+			if test.access != nil {
+				schema := &types.APISchema{Schema: &schemas.Schema{ID: crdSchemaID, Attributes: map[string]interface{}{}}}
+				attributes.SetAccess(schema, test.access)
+				apiSchemas.MustAddSchema(*schema)
+			}
+
+			err := (&applier{}).authorize(&types.APIRequest{Schemas: apiSchemas}, []document{{crd: widgets(nil)}})
+			if !test.denied {
+				assert.NoError(t, err)
+				return
+			}
+			var apiErr *apierror.APIError
+			if assert.True(t, errors.As(err, &apiErr), err) {
+				assert.Equal(t, validation.PermissionDenied, apiErr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package crds
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// validate returns the problems of a custom resource definition that the apiserver would reject, or that would
+// leave its resources unusable: names that don't match, versions without a single storage version, schemas that
+// aren't structural, and a conversion webhook that can't be reached.
+func validate(ctx context.Context, k8s kubernetes.Interface, crd *apiextv1.CustomResourceDefinition) []string {
+	var errs []string
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	spec := crd.Spec
+	if spec.Group == "" {
+		add("spec.group", "Required value")
+	} else if !strings.Contains(spec.Group, ".") {
+		add("spec.group", "should be a domain with at least one dot")
+	}
+	if spec.Names.Plural == "" {
+		add("spec.names.plural", "Required value")
+	}
+	if spec.Names.Kind == "" {
+		add("spec.names.kind", "Required value")
+	}
+	if want := spec.Names.Plural + "." + spec.Group; crd.Name != want {
+		add("metadata.name", "must be spec.names.plural+\".\"+spec.group, %s", want)
+	}
+	if spec.Scope != apiextv1.NamespaceScoped && spec.Scope != apiextv1.ClusterScoped {
+		add("spec.scope", "must be %s or %s", apiextv1.NamespaceScoped, apiextv1.ClusterScoped)
+	}
+
+	if len(spec.Versions) == 0 {
+		add("spec.versions", "Required value")
+	}
+	var (
+		storage int
+		served  bool
+		names   = map[string]bool{}
+	)
+	for i, version := range spec.Versions {
+		field := fmt.Sprintf("spec.versions[%d]", i)
+		if names[version.Name] {
+			add(field+".name", "Duplicate value: %q", version.Name)
+		}
+		names[version.Name] = true
+		if version.Storage {
+			storage++
+		}
+		served = served || version.Served
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			add(field+".schema.openAPIV3Schema", "Required value")
+			continue
+		}
+		root := version.Schema.OpenAPIV3Schema
+		if root.Type != "object" {
+			add(field+".schema.openAPIV3Schema.type", "must be object")
+		}
+		if metadata, ok := root.Properties["metadata"]; ok {
+			validateMetadata(field+".schema.openAPIV3Schema.properties[metadata]", &metadata, add)
+		}
+		validateStructural(field+".schema.openAPIV3Schema", root, false, add)
+	}
+	if len(spec.Versions) > 0 && storage != 1 {
+		add("spec.versions", "must have exactly one version marked as storage version")
+	}
+	if len(spec.Versions) > 0 && !served {
+		add("spec.versions", "must serve at least one version")
+	}
+
+	if conversion := spec.Conversion; conversion != nil && conversion.Strategy == apiextv1.WebhookConverter {
+		if conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil {
+			add("spec.conversion.webhook.clientConfig", "Required value")
+		} else if len(conversion.Webhook.ConversionReviewVersions) == 0 {
+			add("spec.conversion.webhook.conversionReviewVersions", "Required value")
+		} else if err := checkWebhook(ctx, k8s, conversion.Webhook.ClientConfig); err != nil {
+			add("spec.conversion.webhook.clientConfig", "conversion webhook is unreachable: %v", err)
+		}
+	}
+	return errs
+}
+
+// validateMetadata checks that the schema of the metadata of the objects at most restricts their name and
+// generateName, which are the only fields of metadata that may be validated.
+func validateMetadata(field string, s *apiextv1.JSONSchemaProps, add func(field, format string, args ...interface{})) {
+	if s.Type != "" && s.Type != "object" {
+		add(field+".type", "must be object")
+	}
+	for _, name := range sortedKeys(s.Properties) {
+		if name != "name" && name != "generateName" {
+			add(field+".properties["+name+"]", "Forbidden: must not specify anything other than name and generateName")
+		}
+	}
+}
+
+// validateStructural checks that a schema is structural, as the apiserver requires of the schemas of v1 custom
+// resource definitions: every field has a type, arrays have items, objects either have properties or
+// additionalProperties, and the schemas of allOf, anyOf, oneOf and not only validate values.
+func validateStructural(field string, s *apiextv1.JSONSchemaProps, inJunctor bool, add func(field, format string, args ...interface{})) {
+	if inJunctor {
+		if s.Type != "" {
+			add(field+".type", "Forbidden: must be empty inside allOf, anyOf, oneOf and not")
+		}
+		if s.Default != nil {
+			add(field+".default", "Forbidden: must be empty inside allOf, anyOf, oneOf and not")
+		}
+	} else {
+		switch {
+		case s.XIntOrString && s.Type != "":
+			add(field+".type", "must be empty if x-kubernetes-int-or-string is true")
+		case !s.XIntOrString && s.Type == "" && (s.XPreserveUnknownFields == nil || !*s.XPreserveUnknownFields):
+			add(field+".type", "Required value: must not be empty for specified fields")
+		}
+	}
+
+	if s.Type == "array" && s.Items == nil {
+		add(field+".items", "Required value: must be specified for arrays")
+	}
+	if s.Items != nil && len(s.Items.JSONSchemas) > 0 {
+		add(field+".items", "Forbidden: items must be a schema object and not an array")
+	}
+	if len(s.Properties) > 0 && s.Type != "" && s.Type != "object" {
+		add(field+".properties", "Forbidden: must only be specified for objects")
+	}
+	if len(s.Properties) > 0 && s.AdditionalProperties != nil && (s.AdditionalProperties.Schema != nil || s.AdditionalProperties.Allows) {
+		add(field+".additionalProperties", "Forbidden: additionalProperties and properties are mutually exclusive")
+	}
+
+	for _, name := range sortedKeys(s.Properties) {
+		property := s.Properties[name]
+		validateStructural(field+".properties["+name+"]", &property, inJunctor, add)
+	}
+	if s.Items != nil && s.Items.Schema != nil {
+		validateStructural(field+".items", s.Items.Schema, inJunctor, add)
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		validateStructural(field+".additionalProperties", s.AdditionalProperties.Schema, inJunctor, add)
+	}
+	for i := range s.AllOf {
+		validateStructural(fmt.Sprintf("%s.allOf[%d]", field, i), &s.AllOf[i], true, add)
+	}
+	for i := range s.AnyOf {
+		validateStructural(fmt.Sprintf("%s.anyOf[%d]", field, i), &s.AnyOf[i], true, add)
+	}
+	for i := range s.OneOf {
+		validateStructural(fmt.Sprintf("%s.oneOf[%d]", field, i), &s.OneOf[i], true, add)
+	}
+	if s.Not != nil {
+		validateStructural(field+".not", s.Not, true, add)
+	}
+}
+
+// checkWebhook checks that a conversion webhook can be reached: that its service has ready endpoints. The service is
+// looked up with the client of the user, so users only learn of the services and endpoints they may get. URLs are only
+// checked to be https, as dialing them would let users probe the network of steve.
+func checkWebhook(ctx context.Context, k8s kubernetes.Interface, config *apiextv1.WebhookClientConfig) error {
+	if config.Service != nil {
+		return checkService(ctx, k8s, config.Service)
+	}
+	if config.URL == nil {
+		return fmt.Errorf("neither url nor service is set")
+	}
+	u, err := url.Parse(*config.URL)
+	if err != nil {
+		return fmt.Errorf("url is invalid")
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("url %s must use https", *config.URL)
+	}
+	return nil
+}
+
+func checkService(ctx context.Context, k8s kubernetes.Interface, ref *apiextv1.ServiceReference) error {
+	name := ref.Namespace + "/" + ref.Name
+	service, err := k8s.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("service %s not found", name)
+	} else if err != nil {
+		return err
+	}
+
+	port := int32(443)
+	if ref.Port != nil {
+		port = *ref.Port
+	}
+	found := false
+	for _, servicePort := range service.Spec.Ports {
+		found = found || servicePort.Port == port
+	}
+	if !found {
+		return fmt.Errorf("service %s has no port %d", name, port)
+	}
+	if service.Spec.Type == "ExternalName" {
+		return nil
+	}
+
+	endpoints, err := k8s.CoreV1().Endpoints(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if endpoints != nil {
+		for _, subset := range endpoints.Subsets {
+			if len(subset.Addresses) > 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("service %s has no ready endpoints", name)
+}
+
+func sortedKeys(m map[string]apiextv1.JSONSchemaProps) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package crds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func widgets(schema *apiextv1.JSONSchemaProps) *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextv1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget"},
+			Scope: apiextv1.NamespaceScoped,
+			Versions: []apiextv1.CustomResourceDefinitionVersion{{
+				Name:    "v1",
+				Served:  true,
+				Storage: true,
+				Schema:  &apiextv1.CustomResourceValidation{OpenAPIV3Schema: schema},
+			}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	k8s := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "widgets", Name: "converter"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 443}}},
+		},
+		&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "widgets", Name: "converter"}},
+	)
+	preserve := true
+
+	valid := widgets(&apiextv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextv1.JSONSchemaProps{
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiextv1.JSONSchemaProps{
+					"port":   {XIntOrString: true},
+					"tags":   {Type: "array", Items: &apiextv1.JSONSchemaPropsOrArray{Schema: &apiextv1.JSONSchemaProps{Type: "string"}}},
+					"config": {XPreserveUnknownFields: &preserve},
+				},
+				OneOf: []apiextv1.JSONSchemaProps{{Required: []string{"port"}}, {Required: []string{"tags"}}},
+			},
+		},
+	})
+	assert.Empty(t, validate(context.Background(), k8s, valid))
+
+	broken := widgets(&apiextv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextv1.JSONSchemaProps{
+			"metadata": {Type: "object", Properties: map[string]apiextv1.JSONSchemaProps{"labels": {Type: "object"}}},
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiextv1.JSONSchemaProps{
+					"name": {},
+					"tags": {Type: "array"},
+				},
+				AdditionalProperties: &apiextv1.JSONSchemaPropsOrBool{Allows: true},
+				AnyOf:                []apiextv1.JSONSchemaProps{{Type: "string"}},
+			},
+		},
+	})
+	broken.Name = "widgets"
+	broken.Spec.Conversion = &apiextv1.CustomResourceConversion{
+		Strategy: apiextv1.WebhookConverter,
+		Webhook: &apiextv1.WebhookConversion{
+			ConversionReviewVersions: []string{"v1"},
+			ClientConfig: &apiextv1.WebhookClientConfig{
+				Service: &apiextv1.ServiceReference{Namespace: "widgets", Name: "converter"},
+			},
+		},
+	}
+	assert.Equal(t, []string{
+		`metadata.name: must be spec.names.plural+"."+spec.group, widgets.example.com`,
+		"spec.versions[0].schema.openAPIV3Schema.properties[metadata].properties[labels]: Forbidden: must not specify anything other than name and generateName",
+		"spec.versions[0].schema.openAPIV3Schema.properties[spec].additionalProperties: Forbidden: additionalProperties and properties are mutually exclusive",
+		"spec.versions[0].schema.openAPIV3Schema.properties[spec].properties[name].type: Required value: must not be empty for specified fields",
+		"spec.versions[0].schema.openAPIV3Schema.properties[spec].properties[tags].items: Required value: must be specified for arrays",
+		"spec.versions[0].schema.openAPIV3Schema.properties[spec].anyOf[0].type: Forbidden: must be empty inside allOf, anyOf, oneOf and not",
+		"spec.conversion.webhook.clientConfig: conversion webhook is unreachable: service widgets/converter has no ready endpoints",
+	}, validate(context.Background(), k8s, broken))
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	k8s := fake.NewSimpleClientset()
+	url := func(u string) *apiextv1.WebhookClientConfig {
+		return &apiextv1.WebhookClientConfig{URL: &u}
+	}
+
+	assert.NoError(t, checkWebhook(context.Background(), k8s, url("https://127.0.0.1:1/convert")), "urls are not dialed")
+	assert.EqualError(t, checkWebhook(context.Background(), k8s, url("http://converter/convert")), "url http://converter/convert must use https")
+	assert.EqualError(t, checkWebhook(context.Background(), k8s, &apiextv1.WebhookClientConfig{}), "neither url nor service is set")
+}
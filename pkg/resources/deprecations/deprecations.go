@@ -0,0 +1,231 @@
+// Package deprecations serves /v1/apideprecations, a report of the deprecated API versions the cluster still serves
+// and of the objects last applied or managed with them, to plan kubernetes upgrades.
+package deprecations
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/steve/pkg/schema/converter"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/slice"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+	StatusDeprecated = "deprecated"
+	StatusRemoved    = "removed"
+)
+
+// APIDeprecation is a deprecated API version that the cluster serves, or that objects were last applied or are
+// still managed with.
+type APIDeprecation struct {
+	ID           string `json:"id,omitempty"`
+	APIVersion   string `json:"apiVersion"`
+	Kind         string `json:"kind"`
+	Replacement  string `json:"replacement,omitempty"`
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn"`
+	// Status is removed if the kubernetes version of the cluster no longer serves the API version.
+	Status  string             `json:"status"`
+	Served  bool               `json:"served"`
+	Objects []DeprecatedObject `json:"objects,omitempty"`
+}
+
+// DeprecatedObject is an object using a deprecated API version, with where the use was found: the last applied
+// configuration of kubectl, or the managed fields of a manager.
+type DeprecatedObject struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Source    string `json:"source"`
+}
+
+func Register(schemas *types.APISchemas, ccache clustercache.ClusterCache, discovery discovery.ServerVersionInterface) {
+	schemas.MustImportAndCustomize(DeprecatedObject{}, nil)
+	schemas.InternalSchemas.TypeName("apideprecation", APIDeprecation{})
+	schemas.MustImportAndCustomize(APIDeprecation{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		schema.Attributes["access"] = accesscontrol.AccessListByVerb{
+			"watch": accesscontrol.AccessList{
+				{
+					Namespace:    "*",
+					ResourceName: "*",
+				},
+			},
+		}
+		schema.Store = &Store{
+			ccache:    ccache,
+			discovery: discovery,
+		}
+	})
+}
+
+// Store reports the deprecations of the built-in table that apply to the schemas and the cached objects the user
+// may list.
+type Store struct {
+	empty.Store
+
+	ccache    clustercache.ClusterCache
+	discovery discovery.ServerVersionInterface
+}
+
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	for _, d := range s.report(apiOp) {
+		if d.ID == id {
+			return toAPIObject(d), nil
+		}
+	}
+	return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no deprecated API version "+id+" in use")
+}
+
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	var result types.APIObjectList
+	for _, d := range s.report(apiOp) {
+		result.Objects = append(result.Objects, toAPIObject(d))
+	}
+	return result, nil
+}
+
+func toAPIObject(d APIDeprecation) types.APIObject {
+	return types.APIObject{
+		Type:   "apideprecation",
+		ID:     d.ID,
+		Object: d,
+	}
+}
+
+// report returns the deprecations that are served or in use, sorted by ID.
+func (s *Store) report(apiOp *types.APIRequest) []APIDeprecation {
+	serverVersion := s.serverVersion()
+	var result []APIDeprecation
+	for _, d := range table {
+		gv := schema2.GroupVersion{Group: d.Group, Version: d.Version}
+		item := APIDeprecation{
+			ID:           converter.GVKToVersionedSchemaID(gv.WithKind(d.Kind)),
+			APIVersion:   gv.String(),
+			Kind:         d.Kind,
+			Replacement:  d.Replacement,
+			DeprecatedIn: d.DeprecatedIn,
+			RemovedIn:    d.RemovedIn,
+			Status:       StatusDeprecated,
+			Served:       served(apiOp, d),
+			Objects:      s.objects(apiOp, d),
+		}
+		if !item.Served && len(item.Objects) == 0 {
+			continue
+		}
+		if serverVersion != nil && serverVersion.AtLeast(version.MustParseGeneric(d.RemovedIn)) {
+			item.Status = StatusRemoved
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (s *Store) serverVersion() *version.Version {
+	if s.discovery == nil {
+		return nil
+	}
+	info, err := s.discovery.ServerVersion()
+	if err != nil {
+		logrus.Debugf("failed to get the kubernetes version: %v", err)
+		return nil
+	}
+	v, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		logrus.Debugf("failed to parse the kubernetes version %s: %v", info.GitVersion, err)
+		return nil
+	}
+	return v
+}
+
+// served returns whether a schema the user may see serves the deprecated version.
+func served(apiOp *types.APIRequest, d deprecation) bool {
+	for _, schema := range apiOp.Schemas.Schemas {
+		gvr := attributes.GVR(schema)
+		if gvr.Group != d.Group || gvr.Resource != d.Resource {
+			continue
+		}
+		if gvr.Version == d.Version || slice.ContainsString(attributes.Versions(schema), d.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// objects returns the cached objects the user may list that were last applied, or are managed, with the deprecated
+// version. They are served by the replacement of the version, or by the group of the version if it has none.
+func (s *Store) objects(apiOp *types.APIRequest, d deprecation) []DeprecatedObject {
+	group := d.Group
+	if d.Replacement != "" {
+		gv, err := schema2.ParseGroupVersion(d.Replacement)
+		if err != nil {
+			return nil
+		}
+		group = gv.Group
+	}
+
+	apiVersion := schema2.GroupVersion{Group: d.Group, Version: d.Version}.String()
+	var result []DeprecatedObject
+	for _, schema := range apiOp.Schemas.Schemas {
+		if gvr := attributes.GVR(schema); gvr.Group != group || gvr.Resource != d.Resource {
+			continue
+		}
+		access, _ := attributes.Access(schema).(accesscontrol.AccessListByVerb)
+		for _, obj := range s.ccache.List(attributes.GVK(schema)) {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok || !access.Grants("list", u.GetNamespace(), u.GetName()) {
+				continue
+			}
+			for _, source := range usages(u, apiVersion, d.Kind) {
+				result = append(result, DeprecatedObject{
+					Namespace: u.GetNamespace(),
+					Name:      u.GetName(),
+					Source:    source,
+				})
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Namespace+"/"+result[i].Name < result[j].Namespace+"/"+result[j].Name
+	})
+	return result
+}
+
+// usages returns where an object uses the API version: its last applied configuration, and the managers of its
+// fields that last wrote them with the version.
+func usages(obj *unstructured.Unstructured, apiVersion, kind string) []string {
+	var result []string
+	if applied := obj.GetAnnotations()[lastAppliedAnnotation]; applied != "" {
+		var meta struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}
+		if err := json.Unmarshal([]byte(applied), &meta); err == nil && meta.APIVersion == apiVersion && meta.Kind == kind {
+			result = append(result, lastAppliedAnnotation)
+		}
+	}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.APIVersion == apiVersion {
+			result = append(result, "manager "+entry.Manager)
+		}
+	}
+	return result
+}
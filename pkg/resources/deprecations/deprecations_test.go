@@ -0,0 +1,95 @@
+package deprecations
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+type fakeCache struct {
+	clustercache.ClusterCache
+	objects map[schema2.GroupVersionKind][]interface{}
+}
+
+func (f *fakeCache) List(gvk schema2.GroupVersionKind) []interface{} {
+	return f.objects[gvk]
+}
+
+func TestReport(t *testing.T) {
+	pdbs := &types.APISchema{Schema: &schemas.Schema{ID: "policy.poddisruptionbudget"}}
+	attributes.SetGVK(pdbs, schema2.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"})
+	attributes.SetResource(pdbs, "poddisruptionbudgets")
+	attributes.SetVersions(pdbs, []string{"v1", "v1beta1"})
+	attributes.SetAccess(pdbs, accesscontrol.AccessListByVerb{"list": {{Namespace: "default", ResourceName: "*"}}})
+
+	ingresses := &types.APISchema{Schema: &schemas.Schema{ID: "networking.k8s.io.ingress"}}
+	attributes.SetGVK(ingresses, schema2.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"})
+	attributes.SetResource(ingresses, "ingresses")
+	attributes.SetVersions(ingresses, []string{"v1"})
+	attributes.SetAccess(ingresses, accesscontrol.AccessListByVerb{"list": {{Namespace: "*", ResourceName: "*"}}})
+
+	apiSchemas := types.EmptyAPISchemas()
+	apiSchemas.MustAddSchema(*pdbs)
+	apiSchemas.MustAddSchema(*ingresses)
+
+	obj := func(namespace, name, applied string, managedFields ...metav1.ManagedFieldsEntry) interface{} {
+		u := &unstructured.Unstructured{}
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		if applied != "" {
+			u.SetAnnotations(map[string]string{lastAppliedAnnotation: applied})
+		}
+		u.SetManagedFields(managedFields)
+		return u
+	}
+	ccache := &fakeCache{objects: map[schema2.GroupVersionKind][]interface{}{
+		attributes.GVK(pdbs): {
+			obj("default", "web", `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget"}`),
+			obj("default", "api", `{"apiVersion":"policy/v1","kind":"PodDisruptionBudget"}`),
+			obj("other", "hidden", `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget"}`),
+		},
+		attributes.GVK(ingresses): {
+			obj("default", "web", "", metav1.ManagedFieldsEntry{Manager: "helm", APIVersion: "networking.k8s.io/v1beta1"}),
+		},
+	}}
+	discovery := &fakediscovery.FakeDiscovery{
+		Fake:               &clienttesting.Fake{},
+		FakedServerVersion: &version.Info{GitVersion: "v1.24.3+k3s1"},
+	}
+
+	s := &Store{ccache: ccache, discovery: discovery}
+	assert.Equal(t, []APIDeprecation{
+		{
+			ID:           "networking.k8s.io.v1beta1.ingress",
+			APIVersion:   "networking.k8s.io/v1beta1",
+			Kind:         "Ingress",
+			Replacement:  "networking.k8s.io/v1",
+			DeprecatedIn: "1.19",
+			RemovedIn:    "1.22",
+			Status:       StatusRemoved,
+			Objects:      []DeprecatedObject{{Namespace: "default", Name: "web", Source: "manager helm"}},
+		},
+		{
+			ID:           "policy.v1beta1.poddisruptionbudget",
+			APIVersion:   "policy/v1beta1",
+			Kind:         "PodDisruptionBudget",
+			Replacement:  "policy/v1",
+			DeprecatedIn: "1.21",
+			RemovedIn:    "1.25",
+			Status:       StatusDeprecated,
+			Served:       true,
+			Objects:      []DeprecatedObject{{Namespace: "default", Name: "web", Source: lastAppliedAnnotation}},
+		},
+	}, s.report(&types.APIRequest{Schemas: apiSchemas}))
+}
@@ -0,0 +1,441 @@
+// Package health serves /v1/health/cluster, a summary of the health of the cluster for dashboard landing pages: the
+// status of its components, the readiness of its nodes, the availability of its deployments and its failing pods.
+package health
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	refreshSecondsEnv     = "CATTLE_HEALTH_REFRESH_SECONDS"
+	defaultRefresh        = 10 * time.Second
+	componentFetchTimeout = 5 * time.Second
+
+	clusterID = "cluster"
+
+	StateHealthy  = "healthy"
+	StateDegraded = "degraded"
+)
+
+var (
+	nodeGVK       = schema2.GroupVersionKind{Version: "v1", Kind: "Node"}
+	podGVK        = schema2.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	deploymentGVK = schema2.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	// waitingFailures are the reasons a container waits that it won't recover from by itself
+	waitingFailures = map[string]bool{
+		"CrashLoopBackOff":           true,
+		"ImagePullBackOff":           true,
+		"ErrImagePull":               true,
+		"InvalidImageName":           true,
+		"CreateContainerConfigError": true,
+		"CreateContainerError":       true,
+		"RunContainerError":          true,
+	}
+)
+
+// Health is the health of the cluster. Each part is included only if the user may list the type it summarizes, and
+// holds only the objects the user may list.
+type Health struct {
+	ID          string            `json:"id,omitempty"`
+	State       string            `json:"state"`
+	Components  []Component       `json:"components,omitempty"`
+	Nodes       *NodeHealth       `json:"nodes,omitempty"`
+	Deployments *DeploymentHealth `json:"deployments,omitempty"`
+	FailingPods []Pod             `json:"failingPods,omitempty"`
+}
+
+// Component is the status of a control plane component, such as the scheduler or etcd.
+type Component struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+type NodeHealth struct {
+	Total    int    `json:"total"`
+	Ready    int    `json:"ready"`
+	NotReady []Node `json:"notReady,omitempty"`
+}
+
+type Node struct {
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+type DeploymentHealth struct {
+	Total       int          `json:"total"`
+	Available   int          `json:"available"`
+	Unavailable []Deployment `json:"unavailable,omitempty"`
+}
+
+type Deployment struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Desired   int64  `json:"desired"`
+	Available int64  `json:"available"`
+}
+
+type Pod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Message   string `json:"message,omitempty"`
+}
+
+func Register(schemas *types.APISchemas, ccache clustercache.ClusterCache, k8s kubernetes.Interface) {
+	schemas.MustImportAndCustomize(Health{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		schema.Attributes["access"] = accesscontrol.AccessListByVerb{
+			"watch": accesscontrol.AccessList{
+				{
+					Namespace:    "*",
+					ResourceName: "*",
+				},
+			},
+		}
+		schema.Store = NewStore(ccache, k8s)
+	})
+}
+
+// snapshot is the health of every object, from which the health each user may see is taken.
+type snapshot struct {
+	components  []Component
+	nodes       []nodeStatus
+	deployments []Deployment
+	failingPods []Pod
+}
+
+type nodeStatus struct {
+	Node
+	ready bool
+}
+
+// Store serves the health of the cluster, computed at most once in the refresh interval of the
+// CATTLE_HEALTH_REFRESH_SECONDS environment variable, 10 seconds by default. Watches receive the health again
+// whenever it changes.
+type Store struct {
+	empty.Store
+	sync.Mutex
+
+	ccache   clustercache.ClusterCache
+	k8s      kubernetes.Interface
+	refresh  time.Duration
+	now      func() time.Time
+	current  *snapshot
+	computed time.Time
+}
+
+func NewStore(ccache clustercache.ClusterCache, k8s kubernetes.Interface) *Store {
+	return &Store{
+		ccache:  ccache,
+		k8s:     k8s,
+		refresh: refreshFromEnv(),
+		now:     time.Now,
+	}
+}
+
+func refreshFromEnv() time.Duration {
+	setting := os.Getenv(refreshSecondsEnv)
+	if setting == "" {
+		return defaultRefresh
+	}
+	seconds, err := strconv.Atoi(setting)
+	if err != nil || seconds <= 0 {
+		logrus.Debugf("could not parse %s environment variable, error: %v", refreshSecondsEnv, err)
+		return defaultRefresh
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func toAPIObject(h Health) types.APIObject {
+	return types.APIObject{
+		Type:   "health",
+		ID:     h.ID,
+		Object: h,
+	}
+}
+
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if id != clusterID {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no such health "+id)
+	}
+	return toAPIObject(view(apiOp, s.get(apiOp.Context()))), nil
+}
+
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	return types.APIObjectList{
+		Objects: []types.APIObject{
+			toAPIObject(view(apiOp, s.get(apiOp.Context()))),
+		},
+	}, nil
+}
+
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+
+		last := view(apiOp, s.get(apiOp.Context()))
+		ticker := time.NewTicker(s.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-apiOp.Context().Done():
+				return
+			case <-ticker.C:
+			}
+			health := view(apiOp, s.get(apiOp.Context()))
+			if reflect.DeepEqual(health, last) {
+				continue
+			}
+			last = health
+			select {
+			case result <- types.APIEvent{
+				Name:         types.ChangeAPIEvent,
+				ResourceType: "health",
+				Object:       toAPIObject(health),
+			}:
+			case <-apiOp.Context().Done():
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// get returns the health of every object, computing it again if it is older than the refresh interval.
+func (s *Store) get(ctx context.Context) *snapshot {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.current == nil || s.now().Sub(s.computed) >= s.refresh {
+		s.current = &snapshot{
+			components: s.components(ctx),
+		}
+		s.current.add(s.ccache.List(nodeGVK), s.ccache.List(deploymentGVK), s.ccache.List(podGVK))
+		s.computed = s.now()
+	}
+	return s.current
+}
+
+// components returns the status of the control plane components, or none if the cluster no longer serves them.
+func (s *Store) components(ctx context.Context) []Component {
+	if s.k8s == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, componentFetchTimeout)
+	defer cancel()
+	list, err := s.k8s.CoreV1().ComponentStatuses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logrus.Debugf("failed to list the component statuses: %v", err)
+		return nil
+	}
+
+	result := make([]Component, 0, len(list.Items))
+	for _, item := range list.Items {
+		component := Component{Name: item.Name}
+		for _, cond := range item.Conditions {
+			if cond.Type == corev1.ComponentHealthy {
+				component.Healthy = cond.Status == corev1.ConditionTrue
+				component.Message = cond.Error
+				if component.Message == "" {
+					component.Message = cond.Message
+				}
+			}
+		}
+		result = append(result, component)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// add summarizes the nodes, deployments and pods of the cluster cache.
+func (s *snapshot) add(nodes, deployments, pods []interface{}) {
+	for _, obj := range nodes {
+		node, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		status := nodeStatus{Node: Node{Name: node.GetName()}}
+		conditions, _, _ := unstructured.NestedSlice(node.Object, "status", "conditions")
+		for _, c := range conditions {
+			cond, _ := c.(map[string]interface{})
+			if cond["type"] == string(corev1.NodeReady) {
+				status.ready = cond["status"] == string(corev1.ConditionTrue)
+				status.Message, _ = cond["message"].(string)
+			}
+		}
+		s.nodes = append(s.nodes, status)
+	}
+
+	for _, obj := range deployments {
+		deployment, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		desired, found, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas")
+		if !found {
+			desired = 1
+		}
+		available, _, _ := unstructured.NestedInt64(deployment.Object, "status", "availableReplicas")
+		s.deployments = append(s.deployments, Deployment{
+			Namespace: deployment.GetNamespace(),
+			Name:      deployment.GetName(),
+			Desired:   desired,
+			Available: available,
+		})
+	}
+
+	for _, obj := range pods {
+		pod, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if failing, ok := podFailure(pod); ok {
+			s.failingPods = append(s.failingPods, failing)
+		}
+	}
+
+	sort.Slice(s.nodes, func(i, j int) bool {
+		return s.nodes[i].Name < s.nodes[j].Name
+	})
+	sort.Slice(s.deployments, func(i, j int) bool {
+		return s.deployments[i].Namespace+"/"+s.deployments[i].Name < s.deployments[j].Namespace+"/"+s.deployments[j].Name
+	})
+	sort.Slice(s.failingPods, func(i, j int) bool {
+		return s.failingPods[i].Namespace+"/"+s.failingPods[i].Name < s.failingPods[j].Namespace+"/"+s.failingPods[j].Name
+	})
+}
+
+// podFailure returns why a pod is failing: it failed, one of its containers can't start or keeps crashing, or its
+// summary is in error.
+func podFailure(pod *unstructured.Unstructured) (Pod, bool) {
+	failing := Pod{
+		Namespace: pod.GetNamespace(),
+		Name:      pod.GetName(),
+	}
+	if phase, _, _ := unstructured.NestedString(pod.Object, "status", "phase"); phase == string(corev1.PodFailed) {
+		failing.State = "failed"
+		failing.Message, _, _ = unstructured.NestedString(pod.Object, "status", "message")
+		return failing, true
+	}
+	for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+		statuses, _, _ := unstructured.NestedSlice(pod.Object, "status", field)
+		for _, status := range statuses {
+			container, _ := status.(map[string]interface{})
+			reason, _, _ := unstructured.NestedString(container, "state", "waiting", "reason")
+			if waitingFailures[reason] {
+				failing.State = reason
+				failing.Message, _, _ = unstructured.NestedString(container, "state", "waiting", "message")
+				return failing, true
+			}
+		}
+	}
+	if sum := summary.Summarize(pod); sum.Error {
+		failing.State = sum.State
+		if len(sum.Message) > 0 {
+			failing.Message = sum.Message[0]
+		}
+		return failing, true
+	}
+	return Pod{}, false
+}
+
+// view returns the health the user may see. The cluster is healthy when its components are healthy, its nodes ready,
+// its deployments available and none of its pods failing.
+func view(apiOp *types.APIRequest, s *snapshot) Health {
+	health := Health{
+		ID:    clusterID,
+		State: StateHealthy,
+	}
+
+	if access, ok := accessOf(apiOp, "componentstatus"); ok {
+		for _, component := range s.components {
+			if !access.Grants("list", "", component.Name) {
+				continue
+			}
+			health.Components = append(health.Components, component)
+			if !component.Healthy {
+				health.State = StateDegraded
+			}
+		}
+	}
+
+	if access, ok := accessOf(apiOp, "node"); ok {
+		health.Nodes = &NodeHealth{}
+		for _, node := range s.nodes {
+			if !access.Grants("list", "", node.Name) {
+				continue
+			}
+			health.Nodes.Total++
+			if node.ready {
+				health.Nodes.Ready++
+			} else {
+				health.Nodes.NotReady = append(health.Nodes.NotReady, node.Node)
+				health.State = StateDegraded
+			}
+		}
+	}
+
+	if access, ok := accessOf(apiOp, "apps.deployment"); ok {
+		health.Deployments = &DeploymentHealth{}
+		for _, deployment := range s.deployments {
+			if !access.Grants("list", deployment.Namespace, deployment.Name) {
+				continue
+			}
+			health.Deployments.Total++
+			if deployment.Available >= deployment.Desired {
+				health.Deployments.Available++
+			} else {
+				health.Deployments.Unavailable = append(health.Deployments.Unavailable, deployment)
+				health.State = StateDegraded
+			}
+		}
+	}
+
+	if access, ok := accessOf(apiOp, "pod"); ok {
+		for _, pod := range s.failingPods {
+			if !access.Grants("list", pod.Namespace, pod.Name) {
+				continue
+			}
+			health.FailingPods = append(health.FailingPods, pod)
+			health.State = StateDegraded
+		}
+	}
+
+	return health
+}
+
+// accessOf returns the access of the user to a type, if the user may see the type at all.
+func accessOf(apiOp *types.APIRequest, schemaID string) (accesscontrol.AccessListByVerb, bool) {
+	schema := apiOp.Schemas.LookupSchema(schemaID)
+	if schema == nil {
+		return nil, false
+	}
+	return accesscontrol.GetAccessListMap(schema), true
+}
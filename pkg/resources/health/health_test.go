@@ -0,0 +1,102 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestView(t *testing.T) {
+	obj := func(namespace, name string, spec, status map[string]interface{}) interface{} {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec":       spec,
+			"status":     status,
+		}}
+	}
+	ready := func(status string) map[string]interface{} {
+		return map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": status, "message": "kubelet is " + status},
+		}}
+	}
+	s := &snapshot{components: []Component{{Name: "etcd-0", Healthy: true}}}
+	s.add(
+		[]interface{}{obj("", "node-b", nil, ready("False")), obj("", "node-a", nil, ready("True"))},
+		[]interface{}{
+			obj("default", "web", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"availableReplicas": int64(2)}),
+			obj("other", "api", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{"availableReplicas": int64(1)}),
+		},
+		[]interface{}{
+			obj("default", "web-1", nil, map[string]interface{}{"phase": "Running"}),
+			obj("other", "api-1", nil, map[string]interface{}{"phase": "Running", "containerStatuses": []interface{}{
+				map[string]interface{}{"name": "api", "state": map[string]interface{}{
+					"waiting": map[string]interface{}{"reason": "CrashLoopBackOff", "message": "back-off restarting failed container"},
+				}},
+			}}),
+			obj("other", "api-0", nil, map[string]interface{}{"phase": "Failed", "message": "evicted"}),
+		},
+	)
+
+	tests := []struct {
+		name   string
+		access map[string]accesscontrol.AccessList
+		want   Health
+	}{
+		{
+			name: "everything",
+			access: map[string]accesscontrol.AccessList{
+				"componentstatus": {{Namespace: "*", ResourceName: "*"}},
+				"node":            {{Namespace: "*", ResourceName: "*"}},
+				"apps.deployment": {{Namespace: "*", ResourceName: "*"}},
+				"pod":             {{Namespace: "*", ResourceName: "*"}},
+			},
+			want: Health{
+				ID:         clusterID,
+				State:      StateDegraded,
+				Components: []Component{{Name: "etcd-0", Healthy: true}},
+				Nodes: &NodeHealth{Total: 2, Ready: 1, NotReady: []Node{
+					{Name: "node-b", Message: "kubelet is False"},
+				}},
+				Deployments: &DeploymentHealth{Total: 2, Available: 1, Unavailable: []Deployment{
+					{Namespace: "other", Name: "api", Desired: 3, Available: 1},
+				}},
+				FailingPods: []Pod{
+					{Namespace: "other", Name: "api-0", State: "failed", Message: "evicted"},
+					{Namespace: "other", Name: "api-1", State: "CrashLoopBackOff", Message: "back-off restarting failed container"},
+				},
+			},
+		},
+		{
+			name: "one namespace",
+			access: map[string]accesscontrol.AccessList{
+				"apps.deployment": {{Namespace: "default", ResourceName: "*"}},
+				"pod":             {{Namespace: "default", ResourceName: "*"}},
+			},
+			want: Health{
+				ID:          clusterID,
+				State:       StateHealthy,
+				Deployments: &DeploymentHealth{Total: 1, Available: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			apiSchemas := types.EmptyAPISchemas()
+			for id, access := range test.access {
+				schema := types.APISchema{Schema: &schemas.Schema{ID: id}}
+				attributes.SetAccess(&schema, accesscontrol.AccessListByVerb{"list": access})
+				require.NoError(t, apiSchemas.AddSchema(schema))
+			}
+			assert.Equal(t, test.want, view(&types.APIRequest{Schemas: apiSchemas}, s))
+		})
+	}
+}
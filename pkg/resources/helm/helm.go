@@ -0,0 +1,329 @@
+// Package helm serves the releases of Helm 3, decoded from the secrets and configmaps Helm stores them in, as the
+// helmrelease schema. The releases are read through the secret and configmap schemas of the user, so users see
+// the releases whose storage they may see.
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/redact"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	releaseType = "helmrelease"
+
+	// ownerSelector selects the secrets and configmaps Helm stores releases in.
+	ownerSelector = "owner=helm"
+	// releaseSecretType is the type of the secrets of releases, which tells them apart from other secrets that
+	// happen to have the owner label.
+	releaseSecretType = "helm.sh/release.v1"
+)
+
+var (
+	// storage are the schemas of the objects Helm may store releases in.
+	storage = []string{"secret", "configmap"}
+	// releaseField is where a secret or configmap holds its release.
+	releaseField = []string{"data", "release"}
+
+	gzipMagic = []byte{0x1f, 0x8b, 0x08}
+)
+
+// Release is a revision of a Helm release.
+type Release struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Version is the revision of the release.
+	Version      int    `json:"version"`
+	Chart        string `json:"chart"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+	AppVersion   string `json:"appVersion,omitempty"`
+	Status       string `json:"status"`
+	Description  string `json:"description,omitempty"`
+	// FirstDeployed and LastDeployed are RFC 3339 timestamps.
+	FirstDeployed string `json:"firstDeployed,omitempty"`
+	LastDeployed  string `json:"lastDeployed,omitempty"`
+	// ValuesDigest is the sha256 digest of the values the release was installed or upgraded with, to tell whether two
+	// revisions have the same values without exposing them.
+	ValuesDigest string `json:"valuesDigest"`
+	// Storage is the schema of the object the release is stored in, secret or configmap.
+	Storage string `json:"storage"`
+}
+
+// release is the part of a release, as Helm stores it, that is served.
+type release struct {
+	Name string `json:"name"`
+	Info struct {
+		FirstDeployed string `json:"first_deployed"`
+		LastDeployed  string `json:"last_deployed"`
+		Description   string `json:"description"`
+		Status        string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Config    json.RawMessage `json:"config"`
+	Version   int             `json:"version"`
+	Namespace string          `json:"namespace"`
+}
+
+// Register adds the helmrelease schema.
+func Register(schemas *types.APISchemas) {
+	schemas.InternalSchemas.TypeName(releaseType, Release{})
+	schemas.MustImportAndCustomize(Release{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		attributes.SetNamespaced(schema, true)
+		schema.Attributes["access"] = accesscontrol.AccessListByVerb{
+			"watch": accesscontrol.AccessList{
+				{
+					Namespace:    "*",
+					ResourceName: "*",
+				},
+			},
+		}
+		schema.Store = &Store{}
+	})
+}
+
+// Store serves the releases stored in the secrets and configmaps the user may see.
+type Store struct {
+	empty.Store
+}
+
+// ByID returns the release stored in the secret or configmap of the name in the namespace of the request.
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	for _, storageID := range storage {
+		storageOp, storageSchema := delegate(apiOp, storageID, "")
+		if storageSchema == nil {
+			continue
+		}
+		obj, err := storageSchema.Store.ByID(storageOp, storageSchema, id)
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return types.APIObject{}, err
+		}
+		if result, ok := toAPIObject(storageID, obj); ok {
+			return result, nil
+		}
+	}
+	return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "release "+id+" not found")
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apierror.APIError
+	return errors.As(writer.FromStatus(err), &apiErr) && apiErr.Code.Status == http.StatusNotFound
+}
+
+// List returns the releases of the namespace of the request, or of every namespace. The labelSelector parameter
+// selects the secrets and configmaps by the labels Helm sets, such as name and status.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	var result types.APIObjectList
+	for _, storageID := range storage {
+		storageOp, storageSchema := delegate(apiOp, storageID, apiOp.Request.URL.Query().Get("labelSelector"))
+		if storageSchema == nil {
+			continue
+		}
+		objs, err := storageSchema.Store.List(storageOp, storageSchema)
+		if err != nil {
+			return types.APIObjectList{}, err
+		}
+		for _, obj := range objs.Objects {
+			if release, ok := toAPIObject(storageID, obj); ok {
+				result.Objects = append(result.Objects, release)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Watch sends changes of the releases stored in the secrets and configmaps the user may watch.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	var (
+		wg     sync.WaitGroup
+		result = make(chan types.APIEvent)
+	)
+	for _, storageID := range storage {
+		storageOp, storageSchema := delegate(apiOp, storageID, w.Selector)
+		if storageSchema == nil {
+			continue
+		}
+		events, err := storageSchema.Store.Watch(storageOp, storageSchema, types.WatchRequest{
+			ID:       w.ID,
+			Selector: storageOp.Request.URL.Query().Get("labelSelector"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func(storageID string) {
+			defer wg.Done()
+			for event := range events {
+				if event.Error == nil {
+					release, ok := toAPIObject(storageID, event.Object)
+					if !ok {
+						continue
+					}
+					event.ResourceType = releaseType
+					event.Object = release
+				}
+				select {
+				case result <- event:
+				case <-apiOp.Context().Done():
+					return
+				}
+			}
+		}(storageID)
+	}
+	go func() {
+		wg.Wait()
+		close(result)
+	}()
+	return result, nil
+}
+
+// delegate returns a copy of the request for the schema of a storage, if the user has it, listing the objects of
+// the selector that Helm owns. The parameters of the request are for the releases, so they are dropped, as is
+// the Accept header so that the data of the objects is returned rather than a table. The release data is left
+// unmasked by the redact store of secrets, since only the metadata and the digest of the values of the release are
+// served from it.
+func delegate(apiOp *types.APIRequest, schemaID, selector string) (*types.APIRequest, *types.APISchema) {
+	schema := apiOp.Schemas.LookupSchema(schemaID)
+	if schema == nil || schema.Store == nil {
+		return nil, nil
+	}
+	if selector == "" {
+		selector = ownerSelector
+	} else {
+		selector = ownerSelector + "," + selector
+	}
+
+	storageOp := apiOp.Clone()
+	storageOp.Type = schemaID
+	storageOp.Schema = schema
+	storageOp.Request = apiOp.Request.Clone(redact.WithExemptFields(apiOp.Context(), releaseField))
+	storageOp.Request.Header.Del("Accept")
+	q := storageOp.Request.URL.Query()
+	for key := range q {
+		q.Del(key)
+	}
+	q.Set("labelSelector", selector)
+	storageOp.Request.URL.RawQuery = q.Encode()
+	return storageOp, schema
+}
+
+// toAPIObject returns the release stored in a secret or configmap, unless it holds none.
+func toAPIObject(storageID string, obj types.APIObject) (types.APIObject, bool) {
+	data := obj.Data()
+	if storageID == "secret" && data.String("type") != releaseSecretType {
+		return types.APIObject{}, false
+	}
+	encoded := data.String(releaseField...)
+	if encoded == "" {
+		return types.APIObject{}, false
+	}
+	if storageID == "secret" {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			logrus.Debugf("could not decode helm release secret %s, error: %v", obj.ID, err)
+			return types.APIObject{}, false
+		}
+		encoded = string(decoded)
+	}
+	rel, err := decodeRelease(encoded)
+	if err != nil {
+		logrus.Debugf("could not decode helm release %s %s, error: %v", storageID, obj.ID, err)
+		return types.APIObject{}, false
+	}
+
+	namespace := rel.Namespace
+	if namespace == "" {
+		namespace = obj.Namespace()
+	}
+	release := Release{
+		ID:            obj.Namespace() + "/" + obj.Name(),
+		Name:          rel.Name,
+		Namespace:     namespace,
+		Version:       rel.Version,
+		Chart:         rel.Chart.Metadata.Name,
+		ChartVersion:  rel.Chart.Metadata.Version,
+		AppVersion:    rel.Chart.Metadata.AppVersion,
+		Status:        rel.Info.Status,
+		Description:   rel.Info.Description,
+		FirstDeployed: rel.Info.FirstDeployed,
+		LastDeployed:  rel.Info.LastDeployed,
+		ValuesDigest:  valuesDigest(rel.Config),
+		Storage:       storageID,
+	}
+	return types.APIObject{
+		Type:   releaseType,
+		ID:     release.ID,
+		Object: release,
+	}, true
+}
+
+// decodeRelease decodes a release the way Helm encodes it, as base64 encoded JSON, gzipped unless Helm was told
+// not to.
+func decodeRelease(encoded string) (*release, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+	var rel release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, err
+	}
+	if rel.Name == "" {
+		return nil, errors.New("release has no name")
+	}
+	return &rel, nil
+}
+
+// valuesDigest returns the digest of the values of a release, re-encoded so that the order of the keys doesn't
+// change it.
+func valuesDigest(config json.RawMessage) string {
+	var values map[string]interface{}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &values); err != nil {
+			logrus.Debugf("could not decode helm release values, error: %v", err)
+		}
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	canonical, _ := json.Marshal(values)
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,131 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/redact"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type fakeStore struct {
+	empty.Store
+	objects  []types.APIObject
+	selector string
+}
+
+func (f *fakeStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	f.selector = apiOp.Request.URL.Query().Get("labelSelector")
+	return types.APIObjectList{Objects: f.objects}, nil
+}
+
+func encode(t *testing.T, data string) string {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func storageObject(schemaID, name, secretType, release string) types.APIObject {
+	data := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": name, "namespace": "apps"},
+		"data":     map[string]interface{}{"release": release},
+	}
+	if secretType != "" {
+		data["type"] = secretType
+	}
+	return types.APIObject{Type: schemaID, ID: "apps/" + name, Object: data}
+}
+
+func TestList(t *testing.T) {
+	release := `{"name":"web","namespace":"apps","version":2,"info":{"status":"deployed","last_deployed":"2024-01-02T00:00:00Z"},` +
+		`"chart":{"metadata":{"name":"nginx","version":"1.2.3","appVersion":"1.25"}},"config":{"b":1,"a":2}}`
+	reordered := `{"name":"api","namespace":"apps","version":1,"info":{"status":"failed"},` +
+		`"chart":{"metadata":{"name":"api"}},"config":{"a":2,"b":1}}`
+	secrets := &fakeStore{objects: []types.APIObject{
+		storageObject("secret", "sh.helm.release.v1.web.v2", releaseSecretType,
+			base64.StdEncoding.EncodeToString([]byte(encode(t, release)))),
+		storageObject("secret", "other", "Opaque", "ignored"),
+	}}
+	configMaps := &fakeStore{objects: []types.APIObject{
+		storageObject("configmap", "api.v1", "", base64.StdEncoding.EncodeToString([]byte(reordered))),
+		storageObject("configmap", "broken", "", "not a release"),
+	}}
+
+	apiSchemas := types.EmptyAPISchemas()
+	apiSchemas.MustAddSchema(types.APISchema{Schema: &schemas.Schema{ID: "secret"}, Store: secrets})
+	apiSchemas.MustAddSchema(types.APISchema{Schema: &schemas.Schema{ID: "configmap"}, Store: configMaps})
+	req := httptest.NewRequest(http.MethodGet, "/v1/helmreleases?labelSelector=status%3Ddeployed&sort=name", nil)
+	req.Header.Set("Accept", "application/json;as=Table;v=v1;g=meta.k8s.io")
+	apiOp := &types.APIRequest{Request: req, Schemas: apiSchemas}
+
+	list, err := (&Store{}).List(apiOp, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "owner=helm,status=deployed", secrets.selector)
+	require.Len(t, list.Objects, 2)
+
+	web := list.Objects[0].Object.(Release)
+	assert.Equal(t, "apps/sh.helm.release.v1.web.v2", web.ID)
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, 2, web.Version)
+	assert.Equal(t, "nginx", web.Chart)
+	assert.Equal(t, "1.2.3", web.ChartVersion)
+	assert.Equal(t, "deployed", web.Status)
+	assert.Equal(t, "secret", web.Storage)
+
+	api := list.Objects[1].Object.(Release)
+	assert.Equal(t, "api", api.Name)
+	assert.Equal(t, "configmap", api.Storage)
+	assert.Equal(t, web.ValuesDigest, api.ValuesDigest, "the order of the values doesn't change the digest")
+}
+
+type noAccess struct{}
+
+func (noAccess) AccessFor(user.Info) *accesscontrol.AccessSet {
+	return &accesscontrol.AccessSet{}
+}
+
+func (noAccess) PurgeUserData(string) {}
+
+func TestListMaskedSecrets(t *testing.T) {
+	release := `{"name":"web","namespace":"apps","version":1,"info":{"status":"deployed"},"chart":{"metadata":{"name":"nginx"}}}`
+	secret := storageObject("secret", "sh.helm.release.v1.web.v1", releaseSecretType,
+		base64.StdEncoding.EncodeToString([]byte(encode(t, release))))
+	secret.Object = &unstructured.Unstructured{Object: secret.Object.(map[string]interface{})}
+	secretSchema := types.APISchema{Schema: &schemas.Schema{ID: "secret", Attributes: map[string]interface{}{}}}
+	attributes.SetGVK(&secretSchema, schema.GroupVersionKind{Version: "v1", Kind: "Secret"})
+	// the secrets are read through the redact store, as every schema of the default template is
+	secretSchema.Store = redact.NewRedactStore(&fakeStore{objects: []types.APIObject{secret}}, noAccess{})
+
+	apiSchemas := types.EmptyAPISchemas()
+	apiSchemas.MustAddSchema(secretSchema)
+	req := httptest.NewRequest(http.MethodGet, "/v1/helmreleases", nil)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "jane"}))
+	apiOp := &types.APIRequest{Request: req, Schemas: apiSchemas}
+
+	list, err := (&Store{}).List(apiOp, nil)
+	require.NoError(t, err)
+	require.Len(t, list.Objects, 1)
+	assert.Equal(t, "web", list.Objects[0].Object.(Release).Name)
+
+	// the secrets themselves are still masked
+	secrets, err := secretSchema.Store.List(&types.APIRequest{Request: req, Schemas: apiSchemas}, &secretSchema)
+	require.NoError(t, err)
+	assert.Equal(t, "", secrets.Objects[0].Data().String("data", "release"))
+}
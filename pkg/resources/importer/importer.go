@@ -0,0 +1,213 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	steveschema "github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/yaml"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	defaultNamespace   = "default"
+	importConcurrency  = 5
+	maxImportSize      = 10 << 20
+	defaultNamespaceQP = "defaultNamespace"
+)
+
+// Import is the result of an import, with a result for each object of the YAML in the same order. Imports aren't
+// kept, the ID is generated for each one.
+type Import struct {
+	ID      string         `json:"id,omitempty"`
+	Results []ImportResult `json:"results"`
+}
+
+// ImportResult is the outcome of applying a single object of an import.
+type ImportResult struct {
+	APIVersion string                 `json:"apiVersion,omitempty"`
+	Kind       string                 `json:"kind,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	ID         string                 `json:"id,omitempty"`
+	Status     int                    `json:"status"`
+	Message    string                 `json:"message,omitempty"`
+	Object     map[string]interface{} `json:"object,omitempty"`
+}
+
+// Register adds the import type, which applies the objects of a multi-document YAML body like kubectl apply -f:
+// POST /v1/import?defaultNamespace=ns. Each object is applied with a server-side apply patch through the store of its
+// schema, so it is subject to the same access checks as patching it directly.
+func Register(apiSchemas *types.APISchemas, schemaFactory steveschema.Factory) {
+	apiSchemas.MustImportAndCustomize(ImportResult{}, nil)
+	apiSchemas.MustImportAndCustomize(Import{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodPost}
+		schema.ResourceMethods = []string{}
+		i := &importer{
+			schemaFactory: schemaFactory,
+		}
+		schema.CreateHandler = i.create
+	})
+}
+
+type importer struct {
+	schemaFactory steveschema.Factory
+}
+
+// document is an object of an import and the position of its result.
+type document struct {
+	index int
+	obj   *unstructured.Unstructured
+}
+
+// create applies the objects of the YAML body. Cluster scoped objects are applied first, in order, so that namespaces
+// and custom resource definitions exist before the objects that need them; then the objects of each namespace are
+// applied in order, with namespaces applied concurrently.
+func (i *importer) create(apiOp *types.APIRequest) (types.APIObject, error) {
+	body, err := writer.ReadBody(apiOp, maxImportSize)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	objs, err := yaml.ToObjects(bytes.NewReader(body))
+	if err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+
+	defaultNS := apiOp.Request.URL.Query().Get(defaultNamespaceQP)
+	if defaultNS == "" {
+		defaultNS = defaultNamespace
+	}
+
+	var (
+		results    = make([]ImportResult, len(objs))
+		namespaces = map[string][]document{}
+	)
+	for index, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			results[index].Status = http.StatusUnprocessableEntity
+			results[index].Message = fmt.Sprintf("unsupported object %T", obj)
+			continue
+		}
+		namespace := u.GetNamespace()
+		if schema := i.schema(apiOp, u); schema != nil && attributes.Namespaced(schema) && namespace == "" {
+			namespace = defaultNS
+			u.SetNamespace(namespace)
+		}
+		namespaces[namespace] = append(namespaces[namespace], document{index: index, obj: u})
+	}
+
+	for _, doc := range namespaces[""] {
+		results[doc.index] = i.apply(apiOp, doc.obj)
+	}
+	delete(namespaces, "")
+
+	names := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+
+	var (
+		sem = semaphore.NewWeighted(importConcurrency)
+		eg  errgroup.Group
+	)
+	for _, namespace := range names {
+		docs := namespaces[namespace]
+		if err := sem.Acquire(apiOp.Context(), 1); err != nil {
+			return types.APIObject{}, err
+		}
+		eg.Go(func() error {
+			defer sem.Release(1)
+			for _, doc := range docs {
+				results[doc.index] = i.apply(apiOp, doc.obj)
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	id := "import-" + utilrand.String(8)
+	return types.APIObject{
+		Type:   "import",
+		ID:     id,
+		Object: Import{ID: id, Results: results},
+	}, nil
+}
+
+func (i *importer) schema(apiOp *types.APIRequest, obj *unstructured.Unstructured) *types.APISchema {
+	return apiOp.Schemas.LookupSchema(i.schemaFactory.ByGVK(obj.GroupVersionKind()))
+}
+
+// apply applies a single object with a server-side apply patch through the store of its schema.
+func (i *importer) apply(apiOp *types.APIRequest, obj *unstructured.Unstructured) ImportResult {
+	result := ImportResult{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		ID:         obj.GetName(),
+	}
+	if obj.GetNamespace() != "" {
+		result.ID = obj.GetNamespace() + "/" + obj.GetName()
+	}
+
+	resp, err := i.patch(apiOp, obj, &result)
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		var apiErr *apierror.APIError
+		if errors.As(err, &apiErr) {
+			result.Status = apiErr.Code.Status
+		}
+		result.Message = err.Error()
+		return result
+	}
+	result.Status = http.StatusOK
+	if resp.Object != nil {
+		result.Object = resp.Data()
+	}
+	return result
+}
+
+func (i *importer) patch(apiOp *types.APIRequest, obj *unstructured.Unstructured, result *ImportResult) (types.APIObject, error) {
+	schema := i.schema(apiOp, obj)
+	if schema == nil || schema.Store == nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("no schema found for %s %s", obj.GetAPIVersion(), obj.GetKind()))
+	}
+	result.Type = schema.ID
+	if obj.GetName() == "" {
+		return types.APIObject{}, apierror.NewAPIError(validation.MissingRequired, "metadata.name is required")
+	}
+
+	body, err := json.Marshal(obj.Object)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+
+	req := apiOp.Clone()
+	req.Schema = schema
+	req.Type = schema.ID
+	req.Namespace = obj.GetNamespace()
+	req.Name = obj.GetName()
+	req.Method = http.MethodPatch
+	req.Request = apiOp.Request.Clone(apiOp.Context())
+	req.Request.Method = http.MethodPatch
+	req.Request.Header.Set("Content-Type", string(apitypes.ApplyPatchType))
+	req.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := req.AccessControl.CanUpdate(req, types.APIObject{}, schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return schema.Store.Update(req, schema, types.APIObject{Object: obj.Object}, obj.GetName())
+}
@@ -0,0 +1,143 @@
+package importer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	steveschema "github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+type fakeFactory struct {
+	steveschema.Factory
+}
+
+func (f *fakeFactory) ByGVK(gvk schema.GroupVersionKind) string {
+	if gvk.Group != "" {
+		return ""
+	}
+	return strings.ToLower(gvk.Kind)
+}
+
+type fakeAccessControl struct {
+	types.AccessControl
+}
+
+func (f *fakeAccessControl) CanUpdate(apiOp *types.APIRequest, obj types.APIObject, schema *types.APISchema) error {
+	if schema.ID == "secret" {
+		return apierror.NewAPIError(validation.PermissionDenied, "can not update secrets")
+	}
+	return nil
+}
+
+type fakeStore struct {
+	empty.Store
+	lock    sync.Mutex
+	applied []string
+}
+
+func (f *fakeStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if apiOp.Request.Header.Get("Content-Type") != string(apitypes.ApplyPatchType) {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, "not an apply patch")
+	}
+	f.applied = append(f.applied, apiOp.Namespace+"/"+id)
+	return data, nil
+}
+
+func TestCreate(t *testing.T) {
+	store := &fakeStore{}
+	apiSchemas := types.EmptyAPISchemas()
+	for _, id := range []string{"namespace", "configmap", "secret"} {
+		s := types.APISchema{Schema: &schemas.Schema{ID: id}, Store: store}
+		attributes.SetNamespaced(&s, id != "namespace")
+		apiSchemas.MustAddSchema(s)
+	}
+
+	body := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+  namespace: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: gear
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    app: web
+`
+	apiOp := &types.APIRequest{
+		Request:       httptest.NewRequest(http.MethodPost, "/v1/import?defaultNamespace=apps", strings.NewReader(body)),
+		Response:      httptest.NewRecorder(),
+		Schemas:       apiSchemas,
+		AccessControl: &fakeAccessControl{},
+	}
+	i := &importer{schemaFactory: &fakeFactory{}}
+	obj, err := i.create(apiOp)
+	require.NoError(t, err)
+
+	result := obj.Object.(Import)
+	assert.True(t, strings.HasPrefix(result.ID, "import-"))
+	assert.Equal(t, result.ID, obj.ID)
+	var got []ImportResult
+	for _, r := range result.Results {
+		got = append(got, ImportResult{Type: r.Type, ID: r.ID, Status: r.Status})
+	}
+	assert.Equal(t, []ImportResult{
+		{Type: "namespace", ID: "web", Status: http.StatusOK},
+		{Type: "configmap", ID: "apps/settings", Status: http.StatusOK},
+		{Type: "configmap", ID: "web/other", Status: http.StatusOK},
+		{Type: "secret", ID: "apps/token", Status: http.StatusForbidden},
+		{ID: "gear", Status: http.StatusNotFound},
+		{Type: "configmap", ID: "apps/", Status: http.StatusUnprocessableEntity},
+	}, got)
+	assert.Equal(t, "/web", store.applied[0], "cluster scoped objects are applied first")
+	assert.ElementsMatch(t, []string{"/web", "apps/settings", "web/other"}, store.applied)
+}
+
+func TestCreateTooLarge(t *testing.T) {
+	apiOp := &types.APIRequest{
+		Request:  httptest.NewRequest(http.MethodPost, "/v1/import", bytes.NewReader(make([]byte, maxImportSize+1))),
+		Response: httptest.NewRecorder(),
+		Schemas:  types.EmptyAPISchemas(),
+	}
+	_, err := (&importer{schemaFactory: &fakeFactory{}}).create(apiOp)
+	var apiErr *apierror.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, writer.RequestTooLarge, apiErr.Code)
+}
@@ -0,0 +1,347 @@
+// Package kubeconfig serves POST /v1/kubeconfigs, which generates a kubeconfig for the user of the request so
+// dashboard users can download credentials for kubectl and other CLIs. A kubeconfig holds either a token issued by
+// steve, for the kubernetes API steve proxies, or a client certificate signed by the cluster, for the kubernetes API
+// itself, if allowed. Either way the credentials are those of the user and expire after their TTL.
+package kubeconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	TypeToken       = "token"
+	TypeCertificate = "certificate"
+
+	defaultTTL = 8 * time.Hour
+	defaultMax = 24 * time.Hour
+	// minCertificateTTL is the shortest expiration kubernetes accepts for a signed certificate.
+	minCertificateTTL = 10 * time.Minute
+	// certificateSkew is how much later than its TTL a signed certificate may expire, for the clock skew between
+	// steve and the signer.
+	certificateSkew = 5 * time.Minute
+	// systemPrefix is the prefix of the users and groups of kubernetes itself, such as system:masters.
+	systemPrefix = "system:"
+
+	signTimeout  = 30 * time.Second
+	signInterval = 500 * time.Millisecond
+
+	contextName = "default"
+)
+
+// Options configures the generation of kubeconfigs.
+type Options struct {
+	// SigningKeyFile holds the key that signs the issued tokens, at least 32 bytes. Without one the key is random,
+	// so the tokens are only valid until steve restarts.
+	SigningKeyFile string
+	// ServerCA is the PEM encoded CA of the TLS certificate of steve, added to the kubeconfigs of tokens. Without
+	// it clients verify steve against their system roots.
+	ServerCA []byte
+	// DefaultTTL and MaxTTL are the TTL of kubeconfigs that ask for none, 8 hours by default, and the longest TTL a
+	// kubeconfig may ask for, 24 hours by default.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	// Certificates allows kubeconfigs of client certificates signed by the cluster, which unlike tokens can't be
+	// revoked before they expire. Only tokens are issued by default.
+	Certificates bool
+}
+
+// Kubeconfig is both the input and the output of a POST to /v1/kubeconfigs: the type and TTL of the credentials in,
+// and the kubeconfig and its expiry out.
+type Kubeconfig struct {
+	ID         string      `json:"id,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	TTLSeconds int64       `json:"ttlSeconds,omitempty"`
+	ExpiresAt  metav1.Time `json:"expiresAt,omitempty"`
+	Config     string      `json:"config,omitempty"`
+}
+
+// Register adds the kubeconfig schema. Tokens are issued by the issuer; certificates, if allowed, are signed by the
+// kube-apiserver-client signer of the cluster through certificate signing requests that the admin client creates
+// and approves, so its credentials must be allowed to approve them.
+func Register(schemas *types.APISchemas, opts Options, issuer *auth.TokenIssuer, admin kubernetes.Interface, restConfig *rest.Config) {
+	if opts.DefaultTTL <= 0 {
+		opts.DefaultTTL = defaultTTL
+	}
+	if opts.MaxTTL <= 0 {
+		opts.MaxTTL = defaultMax
+	}
+	if opts.DefaultTTL > opts.MaxTTL {
+		opts.DefaultTTL = opts.MaxTTL
+	}
+	schemas.MustImportAndCustomize(Kubeconfig{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodPost}
+		schema.ResourceMethods = []string{}
+		schema.Store = &Store{
+			opts:       opts,
+			issuer:     issuer,
+			admin:      admin,
+			restConfig: restConfig,
+		}
+	})
+}
+
+// Store generates the kubeconfigs of users.
+type Store struct {
+	empty.Store
+
+	opts       Options
+	issuer     *auth.TokenIssuer
+	admin      kubernetes.Interface
+	restConfig *rest.Config
+}
+
+func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, params types.APIObject) (types.APIObject, error) {
+	var input Kubeconfig
+	data, err := json.Marshal(params.Object)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := json.Unmarshal(data, &input); err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+
+	u, ok := request.UserFrom(apiOp.Context())
+	if !ok || u.GetName() == "" || isUnauthenticated(u) {
+		return types.APIObject{}, apierror.NewAPIError(validation.Unauthorized, "kubeconfigs are only generated for authenticated users")
+	}
+	if auth.FromIssuedToken(apiOp.Context()) {
+		// the credentials of a kubeconfig would otherwise renew themselves forever
+		return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, "kubeconfigs can not be generated with the token of another kubeconfig")
+	}
+
+	ttl := s.opts.DefaultTTL
+	if input.TTLSeconds > 0 {
+		ttl = time.Duration(input.TTLSeconds) * time.Second
+	}
+	if ttl > s.opts.MaxTTL {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("ttlSeconds may be at most %d", int64(s.opts.MaxTTL/time.Second)))
+	}
+
+	result := Kubeconfig{
+		ID:   contextName,
+		Type: input.Type,
+	}
+	var config *clientcmdapi.Config
+	switch input.Type {
+	case "", TypeToken:
+		result.Type = TypeToken
+		config, result.ExpiresAt, err = s.tokenConfig(apiOp, u, ttl)
+	case TypeCertificate:
+		if !s.opts.Certificates {
+			return types.APIObject{}, apierror.NewAPIError(validation.ActionNotAvailable, "certificates are not issued")
+		}
+		if strings.HasPrefix(u.GetName(), systemPrefix) {
+			return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, "certificates are not issued for the system user "+u.GetName())
+		}
+		if ttl < minCertificateTTL {
+			ttl = minCertificateTTL
+		}
+		config, result.ExpiresAt, err = s.certificateConfig(apiOp.Context(), u, ttl)
+	default:
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, "type must be token or certificate")
+	}
+	if err != nil {
+		return types.APIObject{}, err
+	}
+
+	out, err := clientcmd.Write(*config)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	result.Config = string(out)
+	result.TTLSeconds = int64(time.Until(result.ExpiresAt.Time) / time.Second)
+	return types.APIObject{
+		Type:   "kubeconfig",
+		ID:     result.ID,
+		Object: result,
+	}, nil
+}
+
+func isUnauthenticated(u user.Info) bool {
+	for _, group := range u.GetGroups() {
+		if group == user.AllUnauthenticated {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenConfig returns a kubeconfig holding a token issued for the user, for the kubernetes API steve proxies at the
+// root URL of the request.
+func (s *Store) tokenConfig(apiOp *types.APIRequest, u user.Info, ttl time.Duration) (*clientcmdapi.Config, metav1.Time, error) {
+	if s.issuer == nil {
+		return nil, metav1.Time{}, apierror.NewAPIError(validation.ActionNotAvailable, "tokens are not issued")
+	}
+	token, expires, err := s.issuer.Issue(u, ttl)
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+	server := strings.TrimSuffix(apiOp.URLBuilder.RelativeToRoot(""), "/")
+	return newConfig(u.GetName(), &clientcmdapi.Cluster{
+		Server:                   server,
+		CertificateAuthorityData: s.opts.ServerCA,
+	}, &clientcmdapi.AuthInfo{
+		Token: token,
+	}), metav1.NewTime(expires), nil
+}
+
+// certificateConfig returns a kubeconfig holding a client certificate of the user signed by the cluster, for the
+// kubernetes API of the cluster. The system groups of the user, such as system:masters, are left out of the
+// certificate, so that it grants no more than the roles bound to the user and its other groups.
+func (s *Store) certificateConfig(ctx context.Context, u user.Info, ttl time.Duration) (*clientcmdapi.Config, metav1.Time, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+	var groups []string
+	for _, group := range u.GetGroups() {
+		// kubernetes adds the group of every authenticated user by itself
+		if !strings.HasPrefix(group, systemPrefix) {
+			groups = append(groups, group)
+		}
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: u.GetName(), Organization: groups},
+	}, key)
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+
+	certData, err := s.sign(ctx, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), u.GetName(), ttl)
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil, metav1.Time{}, fmt.Errorf("the signed certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+	if latest := time.Now().Add(ttl + certificateSkew); cert.NotAfter.After(latest) {
+		// signers of kubernetes before 1.22 ignore the expiration of the request
+		return nil, metav1.Time{}, fmt.Errorf("the cluster signed a certificate valid until %s, after its TTL of %s", cert.NotAfter.Format(time.RFC3339), ttl)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+
+	caData := s.restConfig.CAData
+	if len(caData) == 0 && s.restConfig.CAFile != "" {
+		if caData, err = os.ReadFile(s.restConfig.CAFile); err != nil {
+			return nil, metav1.Time{}, err
+		}
+	}
+	return newConfig(u.GetName(), &clientcmdapi.Cluster{
+		Server:                   s.restConfig.Host,
+		CertificateAuthorityData: caData,
+		InsecureSkipTLSVerify:    s.restConfig.Insecure,
+	}, &clientcmdapi.AuthInfo{
+		ClientCertificateData: certData,
+		ClientKeyData:         pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}), metav1.NewTime(cert.NotAfter), nil
+}
+
+// sign creates and approves a certificate signing request for the kube-apiserver-client signer, returning the
+// certificate once it is issued. The request is deleted afterwards.
+func (s *Store) sign(ctx context.Context, csr []byte, username string, ttl time.Duration) ([]byte, error) {
+	client := s.admin.CertificatesV1().CertificateSigningRequests()
+	expiration := int32(ttl / time.Second)
+	created, err := client.Create(ctx, &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "steve-kubeconfig-" + utilrand.String(8),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:           csr,
+			SignerName:        certificatesv1.KubeAPIServerClientSignerName,
+			ExpirationSeconds: &expiration,
+			Usages:            []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Delete(context.Background(), created.Name, metav1.DeleteOptions{})
+	}()
+
+	created.Status.Conditions = append(created.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:    certificatesv1.CertificateApproved,
+		Status:  corev1.ConditionTrue,
+		Reason:  "SteveKubeconfig",
+		Message: "kubeconfig generated by steve for " + username,
+	})
+	if _, err := client.UpdateApproval(ctx, created.Name, created, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+
+	var cert []byte
+	ctx, cancel := context.WithTimeout(ctx, signTimeout)
+	defer cancel()
+	err = wait.PollImmediateUntilWithContext(ctx, signInterval, func(ctx context.Context) (bool, error) {
+		current, err := client.Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, cond := range current.Status.Conditions {
+			if cond.Type == certificatesv1.CertificateFailed || cond.Type == certificatesv1.CertificateDenied {
+				return false, fmt.Errorf("signing the certificate of %s failed: %s", username, cond.Message)
+			}
+		}
+		cert = current.Status.Certificate
+		return len(cert) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func newConfig(username string, cluster *clientcmdapi.Cluster, authInfo *clientcmdapi.AuthInfo) *clientcmdapi.Config {
+	return &clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			contextName: cluster,
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			username: authInfo,
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			contextName: {
+				Cluster:  contextName,
+				AuthInfo: username,
+			},
+		},
+		CurrentContext: contextName,
+	}
+}
@@ -0,0 +1,93 @@
+package kubeconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestCreateToken(t *testing.T) {
+	issuer, err := auth.NewTokenIssuer("")
+	require.NoError(t, err)
+	s := &Store{
+		opts:   Options{DefaultTTL: time.Hour, MaxTTL: 2 * time.Hour, ServerCA: []byte("ca")},
+		issuer: issuer,
+	}
+
+	apiOp := func(u user.Info) *types.APIRequest {
+		req := httptest.NewRequest(http.MethodPost, "https://steve.example.com/v1/kubeconfigs", nil)
+		req = req.WithContext(request.WithUser(req.Context(), u))
+		builder, err := urlbuilder.NewPrefixed(req, types.EmptyAPISchemas(), "v1")
+		require.NoError(t, err)
+		return &types.APIRequest{Request: req, URLBuilder: builder}
+	}
+	jane := &user.DefaultInfo{Name: "jane", Groups: []string{"devs", user.AllAuthenticated}}
+
+	obj, err := s.Create(apiOp(jane), nil, types.APIObject{Object: map[string]interface{}{"type": "token"}})
+	require.NoError(t, err)
+	result := obj.Object.(Kubeconfig)
+	assert.Equal(t, TypeToken, result.Type)
+	assert.InDelta(t, int64(time.Hour/time.Second), result.TTLSeconds, 5)
+
+	config, err := clientcmd.Load([]byte(result.Config))
+	require.NoError(t, err)
+	cluster := config.Clusters[config.Contexts[config.CurrentContext].Cluster]
+	assert.Equal(t, "https://steve.example.com", cluster.Server)
+	assert.Equal(t, []byte("ca"), cluster.CertificateAuthorityData)
+	resp, ok, err := issuer.AuthenticateToken(apiOp(jane).Context(), config.AuthInfos["jane"].Token)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "jane", resp.User.GetName())
+
+	_, err = s.Create(apiOp(jane), nil, types.APIObject{Object: map[string]interface{}{"ttlSeconds": 3 * 3600}})
+	assert.Error(t, err, "kubeconfigs may not outlive the max TTL")
+
+	_, err = s.Create(apiOp(&user.DefaultInfo{Name: "system:unauthenticated", Groups: []string{user.AllUnauthenticated}}), nil, types.APIObject{Object: map[string]interface{}{}})
+	assert.Error(t, err, "unauthenticated users get no kubeconfig")
+}
+
+func TestCreateRefusals(t *testing.T) {
+	issuer, err := auth.NewTokenIssuer("")
+	require.NoError(t, err)
+	s := &Store{
+		opts:   Options{DefaultTTL: time.Hour, MaxTTL: 2 * time.Hour},
+		issuer: issuer,
+	}
+	apiOp := func(ctx context.Context, u user.Info) *types.APIRequest {
+		req := httptest.NewRequest(http.MethodPost, "https://steve.example.com/v1/kubeconfigs", nil)
+		return &types.APIRequest{Request: req.WithContext(request.WithUser(ctx, u))}
+	}
+	jane := &user.DefaultInfo{Name: "jane", Groups: []string{user.AllAuthenticated}}
+
+	// a token of a kubeconfig authenticates the request
+	var issued context.Context
+	token, _, err := issuer.Issue(jane, time.Hour)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/v1/kubeconfigs", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	auth.IssuedTokenMiddleware(issuer, nil)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		issued = req.Context()
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, issued)
+	_, err = s.Create(apiOp(issued, jane), nil, types.APIObject{Object: map[string]interface{}{}})
+	assert.Error(t, err, "kubeconfigs can't renew themselves")
+
+	_, err = s.Create(apiOp(context.Background(), jane), nil, types.APIObject{Object: map[string]interface{}{"type": "certificate"}})
+	assert.Error(t, err, "certificates are only issued if allowed")
+
+	s.opts.Certificates = true
+	admin := &user.DefaultInfo{Name: "system:admin", Groups: []string{"system:masters"}}
+	_, err = s.Create(apiOp(context.Background(), admin), nil, types.APIObject{Object: map[string]interface{}{"type": "certificate"}})
+	assert.Error(t, err, "certificates are not issued for system users")
+}
@@ -0,0 +1,193 @@
+package namespaces
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const quotaLink = "quota"
+
+// Quota is the quota of a namespace: the limits its resource quotas set and their usage, and the constraints and
+// defaults its limit ranges set on its pods, containers and claims.
+type Quota struct {
+	Namespace string          `json:"namespace"`
+	Resources []QuotaResource `json:"resources"`
+	Limits    []Limit         `json:"limits"`
+	// Hidden is the number of resource quotas and limit ranges of the namespace the user may not see.
+	Hidden int `json:"hidden,omitempty"`
+}
+
+// QuotaResource is the limit of a resource, such as requests.cpu, of the resource quota with the least of it left,
+// and the usage of the resource counted by that quota.
+type QuotaResource struct {
+	Resource string `json:"resource"`
+	Quota    string `json:"quota"`
+	Hard     string `json:"hard"`
+	Used     string `json:"used"`
+	// Percent is the share of the limit in use, rounded down.
+	Percent int64    `json:"percent"`
+	Scopes  []string `json:"scopes,omitempty"`
+}
+
+// Limit is a constraint of a limit range on a resource of the pods, containers or claims of a namespace.
+type Limit struct {
+	LimitRange           string `json:"limitRange"`
+	Type                 string `json:"type"`
+	Resource             string `json:"resource"`
+	Min                  string `json:"min,omitempty"`
+	Max                  string `json:"max,omitempty"`
+	Default              string `json:"default,omitempty"`
+	DefaultRequest       string `json:"defaultRequest,omitempty"`
+	MaxLimitRequestRatio string `json:"maxLimitRequestRatio,omitempty"`
+}
+
+// AddQuota serves GET /v1/namespaces/<name>?link=quota with the quota of the namespace, computed from the caches of
+// resource quotas and limit ranges. Only the resource quotas and limit ranges the user may get are included.
+func AddQuota(apiSchema *types.APISchema, quotas corelisters.ResourceQuotaLister, limitRanges corelisters.LimitRangeLister) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if apiOp.Link != quotaLink || apiOp.Method != http.MethodGet {
+			return next(apiOp)
+		}
+		// the namespace is looked up first so the user must be able to see it
+		obj, err := next(apiOp)
+		if err != nil {
+			return obj, err
+		}
+
+		quotaList, err := quotas.ResourceQuotas(obj.ID).List(labels.Everything())
+		if err != nil {
+			return types.APIObject{}, err
+		}
+		limitRangeList, err := limitRanges.LimitRanges(obj.ID).List(labels.Everything())
+		if err != nil {
+			return types.APIObject{}, err
+		}
+
+		apiOp.Response.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(apiOp.Response).Encode(buildQuota(apiOp, obj.ID, quotaList, limitRangeList)); err != nil {
+			return types.APIObject{}, err
+		}
+		return types.APIObject{}, validation.ErrComplete
+	}
+}
+
+// AddQuotaLink adds the quota link to namespaces.
+func AddQuotaLink(request *types.APIRequest, resource *types.RawResource) {
+	if resource.Links == nil {
+		return
+	}
+	resource.Links[quotaLink] = request.URLBuilder.Link(resource.Schema, resource.ID, quotaLink)
+}
+
+func buildQuota(apiOp *types.APIRequest, namespace string, quotaList []*corev1.ResourceQuota, limitRangeList []*corev1.LimitRange) Quota {
+	result := Quota{
+		Namespace: namespace,
+		Resources: []QuotaResource{},
+		Limits:    []Limit{},
+	}
+
+	sort.Slice(quotaList, func(i, j int) bool { return quotaList[i].Name < quotaList[j].Name })
+	remaining := map[corev1.ResourceName]resource.Quantity{}
+	resources := map[corev1.ResourceName]QuotaResource{}
+	for _, quota := range quotaList {
+		if !canGet(apiOp, "resourcequota", namespace, quota.Name) {
+			result.Hidden++
+			continue
+		}
+		for name, hard := range quota.Status.Hard {
+			used := quota.Status.Used[name]
+			left := hard.DeepCopy()
+			left.Sub(used)
+			if current, ok := remaining[name]; ok && current.Cmp(left) <= 0 {
+				continue
+			}
+			remaining[name] = left
+			resources[name] = toQuotaResource(quota, name, hard, used)
+		}
+	}
+	for _, r := range resources {
+		result.Resources = append(result.Resources, r)
+	}
+	sort.Slice(result.Resources, func(i, j int) bool { return result.Resources[i].Resource < result.Resources[j].Resource })
+
+	sort.Slice(limitRangeList, func(i, j int) bool { return limitRangeList[i].Name < limitRangeList[j].Name })
+	for _, limitRange := range limitRangeList {
+		if !canGet(apiOp, "limitrange", namespace, limitRange.Name) {
+			result.Hidden++
+			continue
+		}
+		for _, item := range limitRange.Spec.Limits {
+			result.Limits = append(result.Limits, toLimits(limitRange.Name, item)...)
+		}
+	}
+	return result
+}
+
+func toQuotaResource(quota *corev1.ResourceQuota, name corev1.ResourceName, hard, used resource.Quantity) QuotaResource {
+	r := QuotaResource{
+		Resource: string(name),
+		Quota:    quota.Name,
+		Hard:     hard.String(),
+		Used:     used.String(),
+	}
+	switch {
+	case hard.MilliValue() > 0:
+		r.Percent = used.MilliValue() * 100 / hard.MilliValue()
+	case used.MilliValue() > 0:
+		r.Percent = 100
+	}
+	for _, scope := range quota.Spec.Scopes {
+		r.Scopes = append(r.Scopes, string(scope))
+	}
+	return r
+}
+
+// toLimits returns the limits of a limit range item by resource, in the order of the resource names.
+func toLimits(limitRange string, item corev1.LimitRangeItem) []Limit {
+	limits := map[corev1.ResourceName]*Limit{}
+	set := func(list corev1.ResourceList, field func(*Limit) *string) {
+		for name, quantity := range list {
+			l, ok := limits[name]
+			if !ok {
+				l = &Limit{LimitRange: limitRange, Type: string(item.Type), Resource: string(name)}
+				limits[name] = l
+			}
+			*field(l) = quantity.String()
+		}
+	}
+	set(item.Min, func(l *Limit) *string { return &l.Min })
+	set(item.Max, func(l *Limit) *string { return &l.Max })
+	set(item.Default, func(l *Limit) *string { return &l.Default })
+	set(item.DefaultRequest, func(l *Limit) *string { return &l.DefaultRequest })
+	set(item.MaxLimitRequestRatio, func(l *Limit) *string { return &l.MaxLimitRequestRatio })
+
+	result := make([]Limit, 0, len(limits))
+	for _, l := range limits {
+		result = append(result, *l)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Resource < result[j].Resource })
+	return result
+}
+
+func canGet(apiOp *types.APIRequest, schemaID, namespace, name string) bool {
+	schema := apiOp.Schemas.LookupSchema(schemaID)
+	if schema == nil {
+		return false
+	}
+	access := accesscontrol.GetAccessListMap(schema)
+	return access.Grants("get", namespace, name) || access.Grants("list", namespace, name)
+}
@@ -0,0 +1,71 @@
+package namespaces
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildQuota(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	for id, names := range map[string]string{"resourcequota": "*", "limitrange": "defaults"} {
+		schema := types.APISchema{Schema: &schemas.Schema{ID: id}}
+		attributes.SetAccess(&schema, accesscontrol.AccessListByVerb{
+			"get": accesscontrol.AccessList{{Namespace: "team-a", ResourceName: names}},
+		})
+		require.NoError(t, apiSchemas.AddSchema(schema))
+	}
+	apiOp := &types.APIRequest{Schemas: apiSchemas}
+
+	quotas := []*corev1.ResourceQuota{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "team-a"},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{"requests.cpu": resource.MustParse("4"), "pods": resource.MustParse("10")},
+				Used: corev1.ResourceList{"requests.cpu": resource.MustParse("1500m"), "pods": resource.MustParse("3")},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "best-effort", Namespace: "team-a"},
+			Spec:       corev1.ResourceQuotaSpec{Scopes: []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{"pods": resource.MustParse("5")},
+				Used: corev1.ResourceList{"pods": resource.MustParse("4")},
+			},
+		},
+	}
+	limitRanges := []*corev1.LimitRange{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "team-a"},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Max:            corev1.ResourceList{"memory": resource.MustParse("1Gi")},
+				DefaultRequest: corev1.ResourceList{"cpu": resource.MustParse("100m"), "memory": resource.MustParse("128Mi")},
+			}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "hidden", Namespace: "team-a"},
+		},
+	}
+
+	assert.Equal(t, Quota{
+		Namespace: "team-a",
+		Resources: []QuotaResource{
+			{Resource: "pods", Quota: "best-effort", Hard: "5", Used: "4", Percent: 80, Scopes: []string{"BestEffort"}},
+			{Resource: "requests.cpu", Quota: "compute", Hard: "4", Used: "1500m", Percent: 37},
+		},
+		Limits: []Limit{
+			{LimitRange: "defaults", Type: "Container", Resource: "cpu", DefaultRequest: "100m"},
+			{LimitRange: "defaults", Type: "Container", Resource: "memory", Max: "1Gi", DefaultRequest: "128Mi"},
+		},
+		Hidden: 1,
+	}, buildQuota(apiOp, "team-a", quotas, limitRanges))
+}
@@ -0,0 +1,306 @@
+package pods
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	uploadAction   = "upload"
+	downloadAction = "download"
+
+	copyMaxBytesEnv     = "CATTLE_POD_COPY_MAX_BYTES"
+	defaultCopyMaxBytes = 1 << 30
+	progressInterval    = time.Second
+	// tarRecordSize is the size of a tar record. Tar reads an archive a record at a time, so an upload is padded
+	// to whole records for tar to find the end of the archive without waiting for a stdin that never closes.
+	tarRecordSize = 10240
+)
+
+// CopyProgress reports how much of an upload or download has been transferred.
+// Progress is reported as a stream of these, one JSON object per line, when an upload is made with ?progress=true,
+// and the size of a download is set in the X-Transferred-Bytes trailer. The progress of an upload is that of copying
+// it into the container, once the whole body has been received.
+type CopyProgress struct {
+	Bytes int64  `json:"bytes"`
+	Done  bool   `json:"done,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RegisterCopy adds the output schema of the upload action.
+func RegisterCopy(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(CopyProgress{}, nil)
+}
+
+// addCopy adds the upload and download actions to the pod schema, which copy files into and out of a container as
+// tar archives, like kubectl cp: ?action=upload&path=/dir extracts the tar of the body into /dir, and
+// ?action=download&path=/dir/file returns a tar of /dir/file. The container is chosen with ?container=.
+func addCopy(apiSchema *types.APISchema, s *streams) {
+	c := &cp{
+		streams:  s,
+		maxBytes: copyMaxBytes(),
+	}
+
+	if apiSchema.ActionHandlers == nil {
+		apiSchema.ActionHandlers = map[string]http.Handler{}
+	}
+	apiSchema.ActionHandlers[uploadAction] = http.HandlerFunc(c.upload)
+	apiSchema.ActionHandlers[downloadAction] = http.HandlerFunc(c.download)
+
+	if apiSchema.ResourceActions == nil {
+		apiSchema.ResourceActions = map[string]schemas.Action{}
+	}
+	apiSchema.ResourceActions[uploadAction] = schemas.Action{
+		Output: "copyProgress",
+	}
+	apiSchema.ResourceActions[downloadAction] = schemas.Action{}
+}
+
+func copyMaxBytes() int64 {
+	if maxBytes := os.Getenv(copyMaxBytesEnv); maxBytes != "" {
+		n, err := strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", copyMaxBytesEnv, err)
+		} else {
+			return n
+		}
+	}
+	return defaultCopyMaxBytes
+}
+
+type cp struct {
+	*streams
+	maxBytes int64
+}
+
+func (c *cp) upload(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+	dir := req.URL.Query().Get("path")
+	if dir == "" {
+		apiOp.WriteError(apierror.NewAPIError(validation.MissingRequired, "path is required"))
+		return
+	}
+	if err := c.authorize(apiOp, "pods/exec"); err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+
+	command := []string{"tar", "xmf", "-", "-C", dir}
+
+	if req.URL.Query().Get("progress") != "true" {
+		body := &limitReader{
+			reader: req.Body,
+			limit:  c.maxBytes,
+		}
+		stdin := io.MultiReader(body, &zeros{n: tarRecordSize})
+		if err := c.run(apiOp, req.URL.Query().Get("container"), command, stdin, io.Discard); err != nil {
+			apiOp.WriteError(err)
+			return
+		}
+		apiOp.WriteResponse(http.StatusOK, types.APIObject{
+			Type:   "copyProgress",
+			Object: CopyProgress{Bytes: body.uploaded(), Done: true},
+		})
+		return
+	}
+
+	// an HTTP/1 server stops reading the body once the response is started, so the upload is spooled to a
+	// temporary file before the progress of copying it into the container is streamed
+	spool, err := c.spool(apiOp)
+	if err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	body := &limitReader{
+		reader: spool,
+		limit:  c.maxBytes,
+	}
+	stdin := io.MultiReader(body, &zeros{n: tarRecordSize})
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+	progress := newProgressWriter(rw)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.run(apiOp, req.URL.Query().Get("container"), command, stdin, io.Discard)
+	}()
+
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case err := <-done:
+			result := CopyProgress{Bytes: body.uploaded(), Done: true}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			progress.write(result)
+			return
+		case <-t.C:
+			progress.write(CopyProgress{Bytes: body.uploaded()})
+		}
+	}
+}
+
+func (c *cp) download(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+	file := path.Clean(req.URL.Query().Get("path"))
+	if file == "." || file == "/" {
+		apiOp.WriteError(apierror.NewAPIError(validation.MissingRequired, "path of a file or directory is required"))
+		return
+	}
+	if err := c.authorize(apiOp, "pods/exec"); err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+
+	out := &limitWriter{
+		writer: rw,
+		limit:  c.maxBytes,
+		header: func() {
+			rw.Header().Set("Content-Type", "application/x-tar")
+			rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(file)+".tar"))
+			rw.Header().Set("Trailer", "X-Transferred-Bytes")
+			rw.WriteHeader(http.StatusOK)
+		},
+	}
+	err := c.run(apiOp, req.URL.Query().Get("container"), []string{"tar", "cf", "-", "-C", path.Dir(file), path.Base(file)}, nil, out)
+	if err != nil && !out.started {
+		apiOp.WriteError(err)
+		return
+	}
+	if !out.started {
+		out.header()
+	}
+	if err != nil {
+		// the archive is incomplete, which the client sees as a missing trailer and a truncated body
+		logrus.Errorf("failed to download %s from %s/%s: %v", file, apiOp.Namespace, apiOp.Name, err)
+		panic(http.ErrAbortHandler)
+	}
+	rw.Header().Set("X-Transferred-Bytes", strconv.FormatInt(out.written, 10))
+}
+
+// spool copies the body of the request to a temporary file, positioned at its start. A body over the limit is
+// refused with a RequestTooLarge error.
+func (c *cp) spool(apiOp *types.APIRequest) (*os.File, error) {
+	f, err := os.CreateTemp("", "steve-upload-")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, http.MaxBytesReader(apiOp.Response, apiOp.Request.Body, c.maxBytes))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, apierror.NewAPIError(writer.RequestTooLarge, fmt.Sprintf("upload is larger than the limit of %d bytes", c.maxBytes))
+		}
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	return f, nil
+}
+
+// limitReader reads until the limit, after which reads fail.
+type limitReader struct {
+	reader io.Reader
+	limit  int64
+	read   int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	if atomic.AddInt64(&l.read, int64(n)) > l.limit {
+		return 0, fmt.Errorf("upload is larger than the limit of %d bytes", l.limit)
+	}
+	return n, err
+}
+
+// uploaded returns the number of bytes read so far.
+func (l *limitReader) uploaded() int64 {
+	return atomic.LoadInt64(&l.read)
+}
+
+// limitWriter writes until the limit, after which writes fail. The header is written before the first write.
+type limitWriter struct {
+	writer  io.Writer
+	limit   int64
+	written int64
+	started bool
+	header  func()
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.written+int64(len(p)) > l.limit {
+		return 0, fmt.Errorf("download is larger than the limit of %d bytes", l.limit)
+	}
+	if !l.started {
+		l.started = true
+		l.header()
+	}
+	n, err := l.writer.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+type zeros struct {
+	n int
+}
+
+func (z *zeros) Read(p []byte) (int, error) {
+	if z.n == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > z.n {
+		p = p[:z.n]
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	z.n -= len(p)
+	return len(p), nil
+}
+
+// progressWriter writes progress as JSON lines, flushing each one to the client.
+type progressWriter struct {
+	rw      http.ResponseWriter
+	encoder *json.Encoder
+}
+
+func newProgressWriter(rw http.ResponseWriter) *progressWriter {
+	return &progressWriter{
+		rw:      rw,
+		encoder: json.NewEncoder(rw),
+	}
+}
+
+func (p *progressWriter) write(progress CopyProgress) {
+	if err := p.encoder.Encode(progress); err != nil {
+		return
+	}
+	if f, ok := p.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,178 @@
+package pods
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type objectWriter struct {
+	types.ResponseWriter
+	code int
+	obj  types.APIObject
+}
+
+func (o *objectWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	o.code, o.obj = code, obj
+}
+
+// execHandler serves the exec subresource with a command that reads stdin bytes of stdin, then writes stdout and
+// succeeds. The command and stdin it got are kept.
+func execHandler(stdin int, stdout []byte, command *[]string, got *bytes.Buffer) http.Handler {
+	upgrader := websocket.Upgrader{Subprotocols: []string{execProtocol}}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		*command = req.URL.Query()["command"]
+		conn, err := upgrader.Upgrade(rw, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for got.Len() < stdin {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if len(data) > 0 && data[0] == stdinChannel {
+				got.Write(data[1:])
+			}
+		}
+		if len(stdout) > 0 {
+			conn.WriteMessage(websocket.BinaryMessage, append([]byte{stdoutChannel}, stdout...))
+		}
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{errorChannel}, `{"status":"Success"}`...))
+	})
+}
+
+func TestUpload(t *testing.T) {
+	archive := bytes.Repeat([]byte("archive"), 20000)
+
+	for _, progress := range []bool{false, true} {
+		progress := progress
+		t.Run("progress="+strconv.FormatBool(progress), func(t *testing.T) {
+			var (
+				command []string
+				got     bytes.Buffer
+			)
+			c := &cp{
+				streams:  newStreams(t, execHandler(len(archive)+tarRecordSize, nil, &command, &got), "pods/exec"),
+				maxBytes: 1 << 20,
+			}
+			w := &objectWriter{}
+			var apiErr error
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				apiOp := newAPIRequest(rw, req, uploadAction, &apiErr)
+				apiOp.ResponseWriter = w
+				c.upload(rw, apiOp.Request)
+			}))
+			defer server.Close()
+
+			url := server.URL + "/?action=upload&path=/data"
+			if progress {
+				url += "&progress=true"
+			}
+			resp, err := http.Post(url, "application/x-tar", bytes.NewReader(archive))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.NoError(t, apiErr)
+			assert.Equal(t, []string{"tar", "xmf", "-", "-C", "/data"}, command)
+			assert.Equal(t, archive, got.Bytes()[:len(archive)])
+			assert.Equal(t, make([]byte, tarRecordSize), got.Bytes()[len(archive):], "the archive is padded to a whole record")
+
+			if !progress {
+				assert.Equal(t, http.StatusOK, w.code)
+				assert.Equal(t, CopyProgress{Bytes: int64(len(archive)), Done: true}, w.obj.Object)
+				return
+			}
+			assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+			var last CopyProgress
+			decoder := json.NewDecoder(resp.Body)
+			for decoder.More() {
+				require.NoError(t, decoder.Decode(&last))
+			}
+			assert.Equal(t, CopyProgress{Bytes: int64(len(archive)), Done: true}, last)
+		})
+	}
+}
+
+func TestUploadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		access bool
+		code   validation.ErrorCode
+	}{
+		{
+			name:   "no path",
+			query:  "action=upload",
+			access: true,
+			code:   validation.MissingRequired,
+		},
+		{
+			name:  "no access",
+			query: "action=upload&path=/data",
+			code:  validation.PermissionDenied,
+		},
+		{
+			name:   "too large",
+			query:  "action=upload&path=/data&progress=true",
+			access: true,
+			code:   writer.RequestTooLarge,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var subresources []string
+			if test.access {
+				subresources = append(subresources, "pods/exec")
+			}
+			c := &cp{
+				streams:  newStreams(t, http.NotFoundHandler(), subresources...),
+				maxBytes: 10,
+			}
+			var err error
+			rw := httptest.NewRecorder()
+			apiOp := newAPIRequest(rw, httptest.NewRequest(http.MethodPost, "/?"+test.query, strings.NewReader("larger than ten bytes")), uploadAction, &err)
+			c.upload(rw, apiOp.Request)
+
+			var apiErr *apierror.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, test.code, apiErr.Code)
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	var (
+		command []string
+		got     bytes.Buffer
+	)
+	c := &cp{
+		streams:  newStreams(t, execHandler(0, []byte("archive"), &command, &got), "pods/exec"),
+		maxBytes: 1 << 20,
+	}
+	var err error
+	rw := httptest.NewRecorder()
+	apiOp := newAPIRequest(rw, httptest.NewRequest(http.MethodGet, "/?action=download&path=/data/app.log", nil), downloadAction, &err)
+	c.download(rw, apiOp.Request)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tar", "cf", "-", "-C", "/data", "app.log"}, command)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/x-tar", rw.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="app.log.tar"`, rw.Header().Get("Content-Disposition"))
+	assert.Equal(t, "archive", rw.Body.String())
+	assert.Equal(t, "7", rw.Header().Get("X-Transferred-Bytes"))
+}
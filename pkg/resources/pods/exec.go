@@ -0,0 +1,155 @@
+package pods
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/client"
+	k8sproxy "github.com/rancher/steve/pkg/proxy"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	execAction        = "exec"
+	portForwardAction = "portforward"
+)
+
+// Register adds the streaming actions to the pod schema: exec, port forwarding and copying files.
+// Websockets are opened with a GET, which the apiserver library never routes to action handlers, so the websocket
+// actions are served by wrapping the ByID handler. Browsers may only open the websockets from the origin of steve
+// or one of origins, such as https://dashboard.example.com, so other sites can't open them with the cookies of the
+// user.
+func Register(apiSchema *types.APISchema, cf *client.Factory, asl accesscontrol.AccessSetLookup, origins []string) {
+	next := apiSchema.ByIDHandler
+	if next == nil {
+		next = handlers.ByIDHandler
+	}
+	s := &streams{
+		cf:      cf,
+		asl:     asl,
+		origins: origins,
+	}
+	addCopy(apiSchema, s)
+	apiSchema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		if !websocket.IsWebSocketUpgrade(apiOp.Request) {
+			return next(apiOp)
+		}
+		switch apiOp.Action {
+		case execAction:
+			return types.APIObject{}, s.exec(apiOp)
+		case portForwardAction:
+			return types.APIObject{}, s.portForward(apiOp)
+		}
+		return next(apiOp)
+	}
+}
+
+type streams struct {
+	cf      *client.Factory
+	asl     accesscontrol.AccessSetLookup
+	origins []string
+}
+
+// exec runs a command in a container of the pod and connects it to the websocket of the request.
+// The command, container, stdin and tty are taken from the query, e.g.
+// ?action=exec&container=app&command=sh&stdin=true&tty=true. The command is required, and stdin is only attached
+// if asked for.
+// The websocket is proxied to the exec subresource, so the client speaks the kubernetes channel protocols
+// (channel.k8s.io, base64.channel.k8s.io and v4.channel.k8s.io): the first byte of each message is the channel,
+// 0 for stdin, 1 for stdout, 2 for stderr, 3 for errors and 4 for resizing the terminal.
+func (s *streams) exec(apiOp *types.APIRequest) error {
+	query := apiOp.Request.URL.Query()
+	tty := query.Get("tty") == "true"
+	command := query["command"]
+	if len(command) == 0 {
+		return apierror.NewAPIError(validation.MissingRequired, "command is required")
+	}
+
+	return s.proxy(apiOp, "exec", &corev1.PodExecOptions{
+		Container: query.Get("container"),
+		Command:   command,
+		Stdin:     query.Get("stdin") == "true",
+		Stdout:    true,
+		Stderr:    !tty,
+		TTY:       tty,
+	})
+}
+
+// proxy forwards the request, as the user of the request, to a subresource of the pod with the given options.
+func (s *streams) proxy(apiOp *types.APIRequest, subresource string, options runtime.Object) error {
+	if err := s.checkOrigin(apiOp); err != nil {
+		return err
+	}
+	if err := s.authorize(apiOp, "pods/"+subresource); err != nil {
+		return err
+	}
+
+	cfg, err := s.cf.RESTConfig(apiOp)
+	if err != nil {
+		return err
+	}
+	handler, err := k8sproxy.Handler("/", cfg)
+	if err != nil {
+		return err
+	}
+
+	params, err := scheme.ParameterCodec.EncodeParameters(options, corev1.SchemeGroupVersion)
+	if err != nil {
+		return err
+	}
+
+	req := apiOp.Request.Clone(apiOp.Context())
+	req.URL.Path = fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/%s", apiOp.Namespace, apiOp.Name, subresource)
+	req.URL.RawPath = ""
+	req.URL.RawQuery = params.Encode()
+	req.Header.Del("Cookie")
+	for k := range req.Header {
+		if strings.HasPrefix(k, "Impersonate-") {
+			delete(req.Header, k)
+		}
+	}
+
+	handler.ServeHTTP(apiOp.Response, req)
+	return validation.ErrComplete
+}
+
+// authorize checks that the user may create the subresource of the pod, as kubectl would need to.
+func (s *streams) authorize(apiOp *types.APIRequest, resource string) error {
+	user, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return apierror.NewAPIError(validation.Unauthorized, "user not found")
+	}
+	if !s.asl.AccessFor(user).Grants("create", schema.GroupResource{Resource: resource}, apiOp.Namespace, apiOp.Name) {
+		return apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("can not create %s %s/%s", resource, apiOp.Namespace, apiOp.Name))
+	}
+	return nil
+}
+
+// checkOrigin refuses the websockets opened by browsers from origins other than steve's or the allowed ones. Other
+// clients don't send an Origin, and can't be made to by another site.
+func (s *streams) checkOrigin(apiOp *types.APIRequest) error {
+	origin := apiOp.Request.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, apiOp.Request.Host) {
+		return nil
+	}
+	for _, allowed := range s.origins {
+		if allowed != "" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("origin %s is not allowed", origin))
+}
@@ -0,0 +1,240 @@
+package pods
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/rest"
+)
+
+type fakeAccessSetLookup struct {
+	access *accesscontrol.AccessSet
+}
+
+func (f *fakeAccessSetLookup) AccessFor(user.Info) *accesscontrol.AccessSet {
+	return f.access
+}
+
+func (f *fakeAccessSetLookup) PurgeUserData(string) {}
+
+// newStreams returns the streams of the pod default/web for jane, with the kubernetes API served by handler.
+func newStreams(t *testing.T, handler http.Handler, subresources ...string) *streams {
+	kube := httptest.NewServer(handler)
+	t.Cleanup(kube.Close)
+	cf, err := client.NewFactory(&rest.Config{Host: kube.URL}, true)
+	require.NoError(t, err)
+
+	access := &accesscontrol.AccessSet{}
+	for _, subresource := range subresources {
+		access.Add("create", schema.GroupResource{Resource: subresource}, accesscontrol.Access{Namespace: "default", ResourceName: "web"})
+	}
+	return &streams{
+		cf:      cf,
+		asl:     &fakeAccessSetLookup{access: access},
+		origins: []string{"https://dashboard.example.com"},
+	}
+}
+
+// newAPIRequest returns the request of an action on the pod default/web made by jane. Errors are kept in err.
+func newAPIRequest(rw http.ResponseWriter, req *http.Request, action string, err *error) *types.APIRequest {
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "jane"}))
+	return types.StoreAPIContext(&types.APIRequest{
+		Action:    action,
+		Namespace: "default",
+		Name:      "web",
+		Request:   req,
+		Response:  rw,
+		ErrorHandler: func(_ *types.APIRequest, e error) {
+			*err = e
+			rw.WriteHeader(http.StatusInternalServerError)
+		},
+	})
+}
+
+func TestStreamProxy(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		origin string
+		path   string
+		want   url.Values
+	}{
+		{
+			name:  "exec",
+			query: "action=exec&container=app&command=ls&command=-l",
+			path:  "/api/v1/namespaces/default/pods/web/exec",
+			want: url.Values{
+				"container": {"app"},
+				"command":   {"ls", "-l"},
+				"stdout":    {"true"},
+				"stderr":    {"true"},
+			},
+		},
+		{
+			name:   "exec with stdin and a tty",
+			query:  "action=exec&command=sh&stdin=true&tty=true",
+			origin: "https://dashboard.example.com",
+			path:   "/api/v1/namespaces/default/pods/web/exec",
+			want: url.Values{
+				"command": {"sh"},
+				"stdin":   {"true"},
+				"stdout":  {"true"},
+				"tty":     {"true"},
+			},
+		},
+		{
+			name:  "port forward",
+			query: "action=portforward&ports=8080&ports=9090",
+			path:  "/api/v1/namespaces/default/pods/web/portforward",
+			want:  url.Values{"ports": {"8080", "9090"}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				gotPath, gotUser string
+				gotQuery         url.Values
+			)
+			// the apiserver accepts websockets from any origin
+			upgrader := websocket.Upgrader{Subprotocols: []string{execProtocol}, CheckOrigin: func(*http.Request) bool { return true }}
+			s := newStreams(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				gotPath, gotQuery, gotUser = req.URL.Path, req.URL.Query(), req.Header.Get("Impersonate-User")
+				conn, err := upgrader.Upgrade(rw, req, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				conn.WriteMessage(websocket.BinaryMessage, []byte{stdoutChannel, 'o', 'k'})
+			}), "pods/exec", "pods/portforward")
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				var err error
+				apiOp := newAPIRequest(rw, req, req.URL.Query().Get("action"), &err)
+				if apiOp.Action == execAction {
+					err = s.exec(apiOp)
+				} else {
+					err = s.portForward(apiOp)
+				}
+				assert.Equal(t, validation.ErrComplete, err)
+			}))
+			defer server.Close()
+
+			dialer := websocket.Dialer{Subprotocols: []string{execProtocol}}
+			header := http.Header{"Impersonate-User": {"admin"}}
+			if test.origin != "" {
+				header.Set("Origin", test.origin)
+			}
+			conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?"+test.query, header)
+			require.NoError(t, err)
+			defer conn.Close()
+			_, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			assert.Equal(t, []byte{stdoutChannel, 'o', 'k'}, data)
+
+			assert.Equal(t, test.path, gotPath)
+			assert.Equal(t, test.want, gotQuery)
+			assert.Equal(t, "jane", gotUser, "the subresource is requested as the user, whatever the impersonation headers")
+		})
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		origin string
+		code   validation.ErrorCode
+	}{
+		{
+			name:  "exec without access",
+			query: "action=exec&command=sh",
+			code:  validation.PermissionDenied,
+		},
+		{
+			name:  "exec without a command",
+			query: "action=exec",
+			code:  validation.MissingRequired,
+		},
+		{
+			name:   "exec from another origin",
+			query:  "action=exec&command=sh",
+			origin: "https://evil.example.com",
+			code:   validation.PermissionDenied,
+		},
+		{
+			name:   "port forward from another origin",
+			query:  "action=portforward&ports=8080",
+			origin: "https://evil.example.com",
+			code:   validation.PermissionDenied,
+		},
+		{
+			name:  "port forward without ports",
+			query: "action=portforward",
+			code:  validation.MissingRequired,
+		},
+		{
+			name:  "port forward to an invalid port",
+			query: "action=portforward&ports=http",
+			code:  validation.InvalidFormat,
+		},
+		{
+			name:  "port forward to port zero",
+			query: "action=portforward&ports=0",
+			code:  validation.InvalidFormat,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := newStreams(t, http.NotFoundHandler(), "pods/portforward")
+			var err error
+			req := httptest.NewRequest(http.MethodGet, "/?"+test.query, nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			apiOp := newAPIRequest(httptest.NewRecorder(), req, "", &err)
+			if strings.Contains(test.query, execAction) {
+				err = s.exec(apiOp)
+			} else {
+				err = s.portForward(apiOp)
+			}
+			var apiErr *apierror.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, test.code, apiErr.Code)
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	s := &streams{origins: []string{"https://dashboard.example.com/", ""}}
+	for origin, allowed := range map[string]bool{
+		"":                              true,
+		"https://steve.example.com":     true,
+		"https://dashboard.example.com": true,
+		"https://DASHBOARD.example.com": true,
+		"https://evil.example.com":      false,
+		"null":                          false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "https://steve.example.com/v1/pods/default/web?action=exec", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		err := s.checkOrigin(&types.APIRequest{Request: req})
+		assert.Equal(t, allowed, err == nil, origin)
+	}
+}
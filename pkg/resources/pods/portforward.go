@@ -0,0 +1,31 @@
+package pods
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// portForward tunnels the websocket of the request to ports of the pod, given in the query, e.g.
+// ?action=portforward&ports=8080&ports=9090. The websocket is proxied to the portforward subresource, so each port
+// gets a pair of channels, numbered in the order of the ports: a data channel and an error channel. The first
+// message of each channel is the port it belongs to, as a little-endian uint16.
+func (s *streams) portForward(apiOp *types.APIRequest) error {
+	var options corev1.PodPortForwardOptions
+	for _, value := range apiOp.Request.URL.Query()["ports"] {
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil || port == 0 {
+			return apierror.NewAPIError(validation.InvalidFormat, fmt.Sprintf("invalid port %q", value))
+		}
+		options.Ports = append(options.Ports, int32(port))
+	}
+	if len(options.Ports) == 0 {
+		return apierror.NewAPIError(validation.MissingRequired, "at least one port is required")
+	}
+
+	return s.proxy(apiOp, "portforward", &options)
+}
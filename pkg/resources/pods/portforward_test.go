@@ -0,0 +1,89 @@
+package pods
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortForwardPorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		grants []string
+		code   validation.ErrorCode
+	}{
+		{
+			name:   "without access to port forward",
+			query:  "action=portforward&ports=8080",
+			grants: []string{"pods/exec"},
+			code:   validation.PermissionDenied,
+		},
+		{
+			name:  "port out of range",
+			query: "action=portforward&ports=65536",
+			code:  validation.InvalidFormat,
+		},
+		{
+			name:  "negative port",
+			query: "action=portforward&ports=-1",
+			code:  validation.InvalidFormat,
+		},
+		{
+			name:  "one invalid port among valid ones",
+			query: "action=portforward&ports=8080&ports=http&ports=9090",
+			code:  validation.InvalidFormat,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			grants := test.grants
+			if grants == nil {
+				grants = []string{"pods/portforward"}
+			}
+			s := newStreams(t, http.NotFoundHandler(), grants...)
+			var err error
+			apiOp := newAPIRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?"+test.query, nil), portForwardAction, &err)
+
+			err = s.portForward(apiOp)
+			var apiErr *apierror.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, test.code, apiErr.Code)
+		})
+	}
+}
+
+func TestRegisterPortForward(t *testing.T) {
+	s := newStreams(t, http.NotFoundHandler(), "pods/portforward")
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	var served bool
+	schema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+		served = true
+		return types.APIObject{}, nil
+	}
+	Register(schema, s.cf, s.asl, nil)
+
+	var err error
+	req := httptest.NewRequest(http.MethodGet, "/?action=portforward", nil)
+	_, err = schema.ByIDHandler(newAPIRequest(httptest.NewRecorder(), req, portForwardAction, &err))
+	assert.NoError(t, err)
+	assert.True(t, served, "requests that aren't websockets are served by the ByID handler")
+
+	served = false
+	req = httptest.NewRequest(http.MethodGet, "/?action=portforward", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	_, err = schema.ByIDHandler(newAPIRequest(httptest.NewRecorder(), req, portForwardAction, &err))
+	var apiErr *apierror.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, validation.MissingRequired, apiErr.Code, "websockets are port forwarded")
+	assert.False(t, served)
+}
@@ -0,0 +1,193 @@
+package pods
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// execProtocol is the websocket protocol of the exec subresource, in which the first byte of each binary
+	// message is the channel: 0 for stdin, 1 for stdout, 2 for stderr and 3 for the final status.
+	execProtocol = "v4.channel.k8s.io"
+	// maxStderr is how much of the stderr of a command is kept to report why it failed.
+	maxStderr = 4096
+)
+
+const (
+	stdinChannel byte = iota
+	stdoutChannel
+	stderrChannel
+	errorChannel
+)
+
+// run runs a command in a container of the pod of the request, as the user of the request, copying stdin to the
+// command and its stdout to stdout. It returns once the command has finished, with an error if it failed.
+func (s *streams) run(apiOp *types.APIRequest, container string, command []string, stdin io.Reader, stdout io.Writer) error {
+	cfg, err := s.cf.RESTConfig(apiOp)
+	if err != nil {
+		return err
+	}
+	k8s, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	url := k8s.CoreV1().RESTClient().Get().
+		Resource("pods").
+		Namespace(apiOp.Namespace).
+		Name(apiOp.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec).
+		URL()
+	url.Scheme = strings.Replace(url.Scheme, "http", "ws", 1)
+
+	headers, err := authHeaders(cfg, url.String())
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := rest.TLSConfigFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 60 * time.Second,
+		TLSClientConfig:  tlsConfig,
+		Subprotocols:     []string{execProtocol},
+	}
+	conn, resp, err := dialer.DialContext(apiOp.Context(), url.String(), headers)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to exec in %s/%s: %s", apiOp.Namespace, apiOp.Name, resp.Status)
+		}
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-apiOp.Context().Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if stdin != nil {
+		go writeStdin(conn, stdin)
+	}
+
+	stderr := &bytes.Buffer{}
+	for {
+		_, data, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			return commandError(stderr)
+		} else if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			continue
+		}
+		switch data[0] {
+		case stdoutChannel:
+			if _, err := stdout.Write(data[1:]); err != nil {
+				return err
+			}
+		case stderrChannel:
+			if stderr.Len() < maxStderr {
+				stderr.Write(data[1:])
+			}
+		case errorChannel:
+			status := metav1.Status{}
+			if err := json.Unmarshal(data[1:], &status); err != nil {
+				return fmt.Errorf("invalid exec status %q: %w", data[1:], err)
+			}
+			if status.Status != metav1.StatusSuccess {
+				if stderr.Len() > 0 {
+					return fmt.Errorf("%s: %s", status.Message, strings.TrimSpace(stderr.String()))
+				}
+				return errors.New(status.Message)
+			}
+			return nil
+		}
+	}
+}
+
+// writeStdin copies stdin to the command until it ends or the connection fails.
+func writeStdin(conn *websocket.Conn, stdin io.Reader) {
+	buf := make([]byte, 32*1024)
+	buf[0] = stdinChannel
+	for {
+		n, err := stdin.Read(buf[1:])
+		if n > 0 {
+			if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n+1]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func commandError(stderr *bytes.Buffer) error {
+	if stderr.Len() > 0 {
+		return errors.New(strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// authHeaders returns the headers that authenticate a request made with the config, such as a bearer token or
+// impersonation. They are captured from the round trippers of the config, since a websocket dialer has none.
+func authHeaders(cfg *rest.Config, url string) (http.Header, error) {
+	var headers http.Header
+	rt, err := rest.HTTPWrappersForConfig(cfg, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		headers = req.Header.Clone()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return headers, nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -4,28 +4,45 @@ import (
 	"context"
 
 	"github.com/rancher/apiserver/pkg/store/apiroot"
-	"github.com/rancher/apiserver/pkg/subscribe"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
 	"github.com/rancher/steve/pkg/client"
 	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/steve/pkg/resources/access"
+	"github.com/rancher/steve/pkg/resources/actions"
 	"github.com/rancher/steve/pkg/resources/apigroups"
 	"github.com/rancher/steve/pkg/resources/cluster"
 	"github.com/rancher/steve/pkg/resources/common"
 	"github.com/rancher/steve/pkg/resources/counts"
+	"github.com/rancher/steve/pkg/resources/crds"
+	"github.com/rancher/steve/pkg/resources/deprecations"
 	"github.com/rancher/steve/pkg/resources/formatters"
+	"github.com/rancher/steve/pkg/resources/health"
+	"github.com/rancher/steve/pkg/resources/helm"
+	"github.com/rancher/steve/pkg/resources/importer"
+	"github.com/rancher/steve/pkg/resources/namespaces"
+	"github.com/rancher/steve/pkg/resources/pods"
+	"github.com/rancher/steve/pkg/resources/usage"
 	"github.com/rancher/steve/pkg/resources/userpreferences"
 	"github.com/rancher/steve/pkg/schema"
 	steveschema "github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/steve/pkg/subscribe"
 	"github.com/rancher/steve/pkg/summarycache"
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/informers"
 )
 
 func DefaultSchemas(ctx context.Context, baseSchema *types.APISchemas, ccache clustercache.ClusterCache,
 	cg proxy.ClientGetter, schemaFactory steveschema.Factory, serverVersion string) error {
 	counts.Register(baseSchema, ccache)
+	k8s, err := cg.AdminK8sInterface()
+	if err != nil {
+		return err
+	}
+	health.Register(baseSchema, ccache, k8s)
 	subscribe.Register(baseSchema, func(apiOp *types.APIRequest) *types.APISchemas {
 		user, ok := request.UserFrom(apiOp.Context())
 		if ok {
@@ -39,16 +56,38 @@ func DefaultSchemas(ctx context.Context, baseSchema *types.APISchemas, ccache cl
 	apiroot.Register(baseSchema, []string{"v1"}, "proxy:/apis")
 	cluster.Register(ctx, baseSchema, cg, schemaFactory)
 	userpreferences.Register(baseSchema)
+	access.Register(baseSchema)
+	common.RegisterBatch(baseSchema)
+	common.RegisterDeletePreview(baseSchema)
+	common.RegisterDiff(baseSchema)
+	pods.RegisterCopy(baseSchema)
+	helm.Register(baseSchema)
+	importer.Register(baseSchema, schemaFactory)
+	crds.Register(baseSchema, cg, schemaFactory)
+	deprecations.Register(baseSchema, ccache, k8s.Discovery())
 	return nil
 }
 
+// TemplateOptions are the options of the default template and the registries of the actions added to schemas.
+type TemplateOptions struct {
+	common.TemplateOptions
+	// Actions gets the built-in actions, which are added to the schemas with the actions of the embedder, if set.
+	Actions *actions.Registry
+	// Informers adds the rollout actions of workloads and the quota link of namespaces if set.
+	Informers informers.SharedInformerFactory
+	// StreamOrigins are the origins, besides that of steve, from which browsers may open the exec and port
+	// forwarding websockets of pods.
+	StreamOrigins []string
+}
+
 func DefaultSchemaTemplates(cf *client.Factory,
 	baseSchemas *types.APISchemas,
 	summaryCache *summarycache.SummaryCache,
 	lookup accesscontrol.AccessSetLookup,
-	discovery discovery.DiscoveryInterface) []schema.Template {
-	return []schema.Template{
-		common.DefaultTemplate(cf, summaryCache, lookup),
+	discovery discovery.DiscoveryInterface,
+	opts TemplateOptions) []schema.Template {
+	templates := []schema.Template{
+		common.DefaultTemplate(cf, summaryCache, lookup, opts.TemplateOptions),
 		apigroups.Template(discovery),
 		{
 			ID:        "configmap",
@@ -61,6 +100,15 @@ func DefaultSchemaTemplates(cf *client.Factory,
 		{
 			ID:        "pod",
 			Formatter: formatters.Pod,
+			Customize: func(apiSchema *types.APISchema) {
+				pods.Register(apiSchema, cf, lookup, opts.StreamOrigins)
+			},
+		},
+		{
+			ID: "apps.deployment",
+			Customize: func(apiSchema *types.APISchema) {
+				attributes.SetTemplate(apiSchema, deploymentTemplate())
+			},
 		},
 		{
 			ID: "management.cattle.io.cluster",
@@ -69,4 +117,26 @@ func DefaultSchemaTemplates(cf *client.Factory,
 			},
 		},
 	}
+	templates = append(templates, usage.Templates(cf.AdminDynamicClient())...)
+	if opts.Actions != nil {
+		actions.AddDefaults(baseSchemas, opts.Actions, cf, lookup)
+		templates = append(templates, actions.Template(opts.Actions, lookup))
+	}
+	if opts.Informers != nil {
+		quotas := opts.Informers.Core().V1().ResourceQuotas().Lister()
+		limitRanges := opts.Informers.Core().V1().LimitRanges().Lister()
+		if opts.Actions != nil {
+			templates = append(templates, actions.AddRollouts(baseSchemas, opts.Actions, cf, lookup,
+				opts.Informers.Apps().V1().ReplicaSets().Lister(),
+				opts.Informers.Apps().V1().ControllerRevisions().Lister())...)
+		}
+		templates = append(templates, schema.Template{
+			ID:        "namespace",
+			Formatter: namespaces.AddQuotaLink,
+			Customize: func(apiSchema *types.APISchema) {
+				namespaces.AddQuota(apiSchema, quotas, limitRanges)
+			},
+		})
+	}
+	return templates
 }
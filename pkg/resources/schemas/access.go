@@ -0,0 +1,77 @@
+package schemas
+
+import (
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+)
+
+const namespaceVerbsAttribute = "namespaceVerbs"
+
+// namespaceVerbsFormatter adds the namespaceVerbs attribute to the schemas of namespaced types, so clients can tell
+// what the user may do in each namespace and not only somewhere in the cluster.
+func namespaceVerbsFormatter(next types.Formatter) types.Formatter {
+	return func(apiOp *types.APIRequest, resource *types.RawResource) {
+		next(apiOp, resource)
+		schema, ok := resource.APIObject.Object.(*types.APISchema)
+		if !ok || schema.Attributes[namespaceVerbsAttribute] != nil {
+			return
+		}
+		// the schema of the response is a copy without the access of the user, which only the user's own schema has
+		setNamespaceVerbs(schema, namespaceVerbs(apiOp.Schemas.LookupSchema(schema.ID)))
+	}
+}
+
+// setNamespaceVerbs sets the namespaceVerbs attribute of the copy of a schema in a response.
+func setNamespaceVerbs(schema *types.APISchema, verbs map[string][]string) {
+	if verbs == nil {
+		return
+	}
+	if schema.Attributes == nil {
+		schema.Attributes = map[string]interface{}{}
+	}
+	schema.Attributes[namespaceVerbsAttribute] = verbs
+}
+
+// namespaceVerbs returns the sorted verbs the schema grants on every object of each namespace, with the verbs
+// granted in all namespaces under "*". The verbs of a namespace include those of all namespaces, so a client can
+// use the verbs of a namespace if it is listed and those of "*" otherwise. Verbs granted only on objects of given
+// names are left out. It returns nil for schemas that aren't namespaced.
+func namespaceVerbs(schema *types.APISchema) map[string][]string {
+	if schema == nil || !attributes.Namespaced(schema) {
+		return nil
+	}
+
+	granted := map[string]map[string]bool{
+		accesscontrol.All: {},
+	}
+	for verb, accessList := range accesscontrol.GetAccessListMap(schema) {
+		for _, access := range accessList {
+			if access.ResourceName != accesscontrol.All {
+				continue
+			}
+			if granted[access.Namespace] == nil {
+				granted[access.Namespace] = map[string]bool{}
+			}
+			granted[access.Namespace][verb] = true
+		}
+	}
+
+	result := map[string][]string{}
+	for namespace, verbs := range granted {
+		if namespace != accesscontrol.All {
+			for verb := range granted[accesscontrol.All] {
+				verbs[verb] = true
+			}
+		}
+		list := make([]string, 0, len(verbs))
+		for verb := range verbs {
+			list = append(list, verb)
+		}
+		sort.Strings(list)
+		result[namespace] = list
+	}
+	return result
+}
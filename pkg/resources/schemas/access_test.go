@@ -0,0 +1,33 @@
+package schemas
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceVerbs(t *testing.T) {
+	pods := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	attributes.SetNamespaced(pods, true)
+	attributes.SetAccess(pods, accesscontrol.AccessListByVerb{
+		"list":   {{Namespace: "*", ResourceName: "*"}},
+		"get":    {{Namespace: "*", ResourceName: "*"}},
+		"create": {{Namespace: "dev", ResourceName: "*"}},
+		"delete": {{Namespace: "dev", ResourceName: "*"}, {Namespace: "prod", ResourceName: "web"}},
+		"update": {{Namespace: "prod", ResourceName: "*"}},
+	})
+
+	assert.Equal(t, map[string][]string{
+		"*":    {"get", "list"},
+		"dev":  {"create", "delete", "get", "list"},
+		"prod": {"get", "list", "update"},
+	}, namespaceVerbs(pods))
+
+	nodes := &types.APISchema{Schema: &schemas.Schema{ID: "node"}}
+	attributes.SetAccess(nodes, accesscontrol.AccessListByVerb{"get": {{Namespace: "*", ResourceName: "*"}}})
+	assert.Nil(t, namespaceVerbs(nodes), "cluster scoped schemas have no namespaces")
+}
@@ -0,0 +1,139 @@
+package schemas
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/slice"
+)
+
+const (
+	templateAction = "template"
+	// maxTemplateDepth bounds how deep nested types are expanded when generating a template.
+	maxTemplateDepth = 10
+)
+
+// templateByIDHandler serves GET /v1/schemas/<id>?action=template with a skeleton object of the schema, which a
+// client may fill in and create, and every other lookup as usual.
+func templateByIDHandler(apiOp *types.APIRequest) (types.APIObject, error) {
+	if apiOp.Action != templateAction || apiOp.Method != http.MethodGet {
+		return handlers.ByIDHandler(apiOp)
+	}
+
+	schema := apiOp.Schemas.LookupSchema(apiOp.Name)
+	if schema == nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no such schema")
+	}
+	apiOp.Response.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(apiOp.Response).Encode(objectTemplate(apiOp.Schemas, schema)); err != nil {
+		return types.APIObject{}, err
+	}
+	return types.APIObject{}, validation.ErrComplete
+}
+
+// templateFormatter adds a template link to the schemas of types the user may create.
+func templateFormatter(next types.Formatter) types.Formatter {
+	return func(apiOp *types.APIRequest, resource *types.RawResource) {
+		next(apiOp, resource)
+		schema, ok := resource.APIObject.Object.(*types.APISchema)
+		if !ok || !slice.ContainsString(schema.CollectionMethods, http.MethodPost) {
+			return
+		}
+		if self, ok := resource.Links["self"]; ok {
+			resource.Links[templateAction] = self + "?action=" + templateAction
+		}
+	}
+}
+
+// objectTemplate returns the skeleton of an object of the schema: the template set with attributes.SetTemplate,
+// or one generated from the defaults of its fields and the required fields without a default.
+func objectTemplate(schemas *types.APISchemas, schema *types.APISchema) map[string]interface{} {
+	if template := attributes.Template(schema); template != nil {
+		return copyJSON(template)
+	}
+
+	obj := fieldValues(schemas, schema, map[string]bool{schema.ID: true}, 0)
+	delete(obj, "status")
+	if gvk := attributes.GVK(schema); gvk.Kind != "" {
+		obj["apiVersion"] = gvk.GroupVersion().String()
+		obj["kind"] = gvk.Kind
+		metadata := map[string]interface{}{
+			"name": "",
+		}
+		if attributes.Namespaced(schema) {
+			metadata["namespace"] = ""
+		}
+		obj["metadata"] = metadata
+	}
+	return obj
+}
+
+// fieldValues returns the template values of the fields of a schema, skipping the types already being expanded
+// so recursive types terminate. Only the top level of an object keeps reserved fields prefixed with an
+// underscore, as the proxy store expects.
+func fieldValues(schemas *types.APISchemas, schema *types.APISchema, expanding map[string]bool, depth int) map[string]interface{} {
+	result := map[string]interface{}{}
+	for name, field := range schema.ResourceFields {
+		if reserved := strings.TrimPrefix(name, "_"); depth > 0 && types.ReservedFields[reserved] {
+			name = reserved
+		}
+		if value, ok := fieldValue(schemas, field.Type, field.Default, field.Required, expanding, depth); ok {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// fieldValue returns the template value of a field: its default, the expanded object of a nested type that has
+// any defaults or is required, or the zero value of a required field.
+func fieldValue(schemas *types.APISchemas, fieldType string, def interface{}, required bool, expanding map[string]bool, depth int) (interface{}, bool) {
+	if def != nil {
+		return def, true
+	}
+	switch {
+	case strings.HasPrefix(fieldType, "array["):
+		return []interface{}{}, required
+	case strings.HasPrefix(fieldType, "map["):
+		return map[string]interface{}{}, required
+	}
+
+	switch fieldType {
+	case "string", "date", "password":
+		return "", required
+	case "int", "float":
+		return 0, required
+	case "boolean":
+		return false, required
+	}
+
+	sub := schemas.LookupSchema(fieldType)
+	if sub == nil || expanding[sub.ID] || depth >= maxTemplateDepth {
+		if required {
+			return map[string]interface{}{}, true
+		}
+		return nil, false
+	}
+	expanding[sub.ID] = true
+	defer delete(expanding, sub.ID)
+	values := fieldValues(schemas, sub, expanding, depth+1)
+	return values, required || len(values) > 0
+}
+
+// copyJSON returns a deep copy of a template, so clients can not change the one on the schema.
+func copyJSON(obj map[string]interface{}) map[string]interface{} {
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return obj
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		return obj
+	}
+	return result
+}
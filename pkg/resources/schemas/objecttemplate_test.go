@@ -0,0 +1,80 @@
+package schemas
+
+import (
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestObjectTemplate(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	widget := types.APISchema{Schema: &schemas.Schema{
+		ID: "example.io.widget",
+		ResourceFields: map[string]schemas.Field{
+			"metadata": {Type: "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+			"spec":     {Type: "io.example.WidgetSpec"},
+			"status":   {Type: "io.example.WidgetStatus"},
+		},
+	}}
+	attributes.SetGVK(&widget, k8sschema.GroupVersionKind{Group: "example.io", Version: "v1", Kind: "Widget"})
+	attributes.SetNamespaced(&widget, true)
+	require.NoError(t, apiSchemas.AddSchema(widget))
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{Schema: &schemas.Schema{
+		ID: "io.example.WidgetSpec",
+		ResourceFields: map[string]schemas.Field{
+			"replicas": {Type: "int", Default: 1},
+			"image":    {Type: "string", Required: true},
+			"ports":    {Type: "array[int]"},
+			"_type":    {Type: "string", Default: "basic"},
+			"parent":   {Type: "io.example.WidgetSpec"},
+		},
+	}}))
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{Schema: &schemas.Schema{
+		ID: "io.example.WidgetStatus",
+		ResourceFields: map[string]schemas.Field{
+			"ready": {Type: "boolean", Default: false},
+		},
+	}}))
+
+	tests := []struct {
+		name     string
+		template map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "generated",
+			want: map[string]interface{}{
+				"apiVersion": "example.io/v1",
+				"kind":       "Widget",
+				"metadata": map[string]interface{}{
+					"name":      "",
+					"namespace": "",
+				},
+				"spec": map[string]interface{}{
+					"replicas": 1,
+					"image":    "",
+					"type":     "basic",
+				},
+			},
+		},
+		{
+			name:     "custom",
+			template: map[string]interface{}{"kind": "Widget", "spec": map[string]interface{}{"replicas": 3}},
+			want:     map[string]interface{}{"kind": "Widget", "spec": map[string]interface{}{"replicas": float64(3)}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema := apiSchemas.LookupSchema("example.io.widget").DeepCopy()
+			if test.template != nil {
+				attributes.SetTemplate(schema, test.template)
+			}
+			assert.Equal(t, test.want, objectTemplate(apiSchemas, schema))
+		})
+	}
+}
@@ -30,6 +30,8 @@ func SetupWatcher(ctx context.Context, schemas *types.APISchemas, asl accesscont
 		sf:                 factory,
 		schemaChangeNotify: notifier,
 	}
+	schema.ByIDHandler = templateByIDHandler
+	schema.Formatter = namespaceVerbsFormatter(templateFormatter(schema.Formatter))
 
 	schemas.AddSchema(schema)
 }
@@ -106,10 +108,13 @@ func (s *Store) sendSchemas(result chan types.APIEvent, apiOp *types.APIRequest,
 			oldSchemaCopy := oldSchema.Schema.DeepCopy()
 			newSchemaCopy.Mapper = nil
 			oldSchemaCopy.Mapper = nil
-			if equality.Semantic.DeepEqual(newSchemaCopy, oldSchemaCopy) {
+			if equality.Semantic.DeepEqual(newSchemaCopy, oldSchemaCopy) &&
+				equality.Semantic.DeepEqual(namespaceVerbs(schemas.LookupSchema(apiObject.ID)), namespaceVerbs(oldSchema)) {
 				continue
 			}
 		}
+		// the schemas of the request are those of the start of the watch, so the current access is set here
+		setNamespaceVerbs(apiObject.Object.(*types.APISchema), namespaceVerbs(schemas.LookupSchema(apiObject.ID)))
 		result <- types.APIEvent{
 			Name:         eventName,
 			ResourceType: "schema",
@@ -0,0 +1,38 @@
+package resources
+
+// deploymentTemplate is the skeleton returned by the template action of deployments, with a single container whose
+// pods are selected by their app label.
+func deploymentTemplate() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "",
+			"namespace": "",
+		},
+		"spec": map[string]interface{}{
+			"replicas": 1,
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"app": "",
+				},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{
+						"app": "",
+					},
+				},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":            "",
+							"image":           "",
+							"imagePullPolicy": "IfNotPresent",
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,174 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// metricsServer is the source of the usage served by the metrics.k8s.io API of metrics-server.
+type metricsServer struct {
+	client dynamic.Interface
+}
+
+func (m *metricsServer) Usage(ctx context.Context, resourceName string) (map[string]Usage, error) {
+	gvr := schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: resourceName}
+	list, err := m.client.Resource(gvr).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Usage, len(list.Items))
+	for _, item := range list.Items {
+		key := item.GetName()
+		if ns := item.GetNamespace(); ns != "" {
+			key = ns + "/" + key
+		}
+		var u Usage
+		if resourceName == pods {
+			// the usage of a pod is that of its containers
+			containers, _, _ := unstructured.NestedSlice(item.Object, "containers")
+			for _, container := range containers {
+				if c, ok := container.(map[string]interface{}); ok {
+					u.add(c["usage"])
+				}
+			}
+		} else {
+			u.add(item.Object["usage"])
+		}
+		result[key] = u
+	}
+	return result, nil
+}
+
+func (u *Usage) add(usage interface{}) {
+	m, _ := usage.(map[string]interface{})
+	if cpu, ok := m["cpu"].(string); ok {
+		if q, err := resource.ParseQuantity(cpu); err == nil {
+			u.CPU.Add(q)
+		}
+	}
+	if memory, ok := m["memory"].(string); ok {
+		if q, err := resource.ParseQuantity(memory); err == nil {
+			u.Memory.Add(q)
+		}
+	}
+}
+
+// prometheusQueries are the queries of the CPU and memory of the pods and nodes, from the cAdvisor metrics of the
+// kubelets, and the labels of the series that name the pod or node.
+var prometheusQueries = map[string]struct {
+	cpu, memory string
+	labels      []string
+}{
+	pods: {
+		cpu:    `sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{container!="", pod!=""}[5m]))`,
+		memory: `sum by (namespace, pod) (container_memory_working_set_bytes{container!="", pod!=""})`,
+		labels: []string{"namespace", "pod"},
+	},
+	nodes: {
+		cpu:    `sum by (node) (rate(container_cpu_usage_seconds_total{id="/"}[5m]))`,
+		memory: `sum by (node) (container_memory_working_set_bytes{id="/"})`,
+		labels: []string{"node"},
+	},
+}
+
+// prometheus is the source of the usage queried from a Prometheus server.
+type prometheus struct {
+	url    string
+	client *http.Client
+}
+
+func newPrometheus(url string) *prometheus {
+	return &prometheus{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{},
+	}
+}
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func (p *prometheus) Usage(ctx context.Context, resourceName string) (map[string]Usage, error) {
+	queries := prometheusQueries[resourceName]
+	result := map[string]Usage{}
+
+	cpu, err := p.query(ctx, queries.cpu, queries.labels)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range cpu {
+		u := result[key]
+		// CPU is in cores, kept to the millicore
+		u.CPU = *resource.NewMilliQuantity(int64(value*1000), resource.DecimalSI)
+		result[key] = u
+	}
+
+	memory, err := p.query(ctx, queries.memory, queries.labels)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range memory {
+		u := result[key]
+		u.Memory = *resource.NewQuantity(int64(value), resource.BinarySI)
+		result[key] = u
+	}
+	return result, nil
+}
+
+// query returns the values of the series of an instant query, by the values of their labels joined with a slash.
+func (p *prometheus) query(ctx context.Context, query string, labels []string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/api/v1/query?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decoding the response of prometheus: %w", err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("querying prometheus: %s", response.Error)
+	}
+
+	result := make(map[string]float64, len(response.Data.Result))
+	for _, series := range response.Data.Result {
+		if len(series.Value) != 2 {
+			continue
+		}
+		s, _ := series.Value[1].(string)
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			continue
+		}
+		key := make([]string, 0, len(labels))
+		for _, label := range labels {
+			key = append(key, series.Metric[label])
+		}
+		result[strings.Join(key, "/")] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,180 @@
+// Package usage adds the CPU and memory in use by pods and nodes to their objects and columns, from metrics-server
+// or from Prometheus.
+package usage
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	prometheusURLEnv = "CATTLE_METRICS_PROMETHEUS_URL"
+	cacheSecondsEnv  = "CATTLE_METRICS_CACHE_SECONDS"
+	defaultCacheTTL  = 30 * time.Second
+	fetchTimeout     = 5 * time.Second
+
+	pods  = "pods"
+	nodes = "nodes"
+)
+
+// Usage is the CPU and memory in use by a pod or node.
+type Usage struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+// Source returns the usage of the pods, by namespace/name, or of the nodes, by name.
+type Source interface {
+	Usage(ctx context.Context, resourceName string) (map[string]Usage, error)
+}
+
+type entry struct {
+	usage   map[string]Usage
+	fetched time.Time
+}
+
+// Cache keeps the usage of a source for a while, so the objects of a list and the lists of a while share a fetch.
+// When the source fails, such as when metrics-server isn't installed, objects go without usage until the next fetch.
+type Cache struct {
+	sync.Mutex
+
+	source  Source
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*entry
+}
+
+// NewCache returns a cache of the usage of a source, fetched again once it is older than the ttl.
+func NewCache(source Source, ttl time.Duration) *Cache {
+	return &Cache{
+		source:  source,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]*entry{},
+	}
+}
+
+// Templates returns the templates adding usage to pods and nodes, from the Prometheus of the
+// CATTLE_METRICS_PROMETHEUS_URL environment variable if set, or else from metrics-server. The usage is fetched again
+// after the CATTLE_METRICS_CACHE_SECONDS environment variable, 30 seconds by default.
+func Templates(client dynamic.Interface) []schema.Template {
+	var source Source = &metricsServer{client: client}
+	if url := os.Getenv(prometheusURLEnv); url != "" {
+		source = newPrometheus(url)
+	}
+	c := NewCache(source, cacheTTLFromEnv())
+	return []schema.Template{
+		{
+			ID:        "pod",
+			Formatter: c.formatter(pods),
+			Customize: addColumns,
+		},
+		{
+			ID:        "node",
+			Formatter: c.formatter(nodes),
+			Customize: addColumns,
+		},
+	}
+}
+
+func cacheTTLFromEnv() time.Duration {
+	setting := os.Getenv(cacheSecondsEnv)
+	if setting == "" {
+		return defaultCacheTTL
+	}
+	seconds, err := strconv.Atoi(setting)
+	if err != nil || seconds <= 0 {
+		logrus.Debugf("could not parse %s environment variable, error: %v", cacheSecondsEnv, err)
+		return defaultCacheTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// get returns the usage of a pod or node, fetching the usage of every pod or node if it is out of date.
+func (c *Cache) get(ctx context.Context, resourceName, key string) (Usage, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[resourceName]
+	if !ok || c.now().Sub(e.fetched) > c.ttl {
+		ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		defer cancel()
+		usage, err := c.source.Usage(ctx, resourceName)
+		if err != nil {
+			logrus.Debugf("failed to get the usage of %s: %v", resourceName, err)
+		}
+		e = &entry{usage: usage, fetched: c.now()}
+		c.entries[resourceName] = e
+	}
+	u, ok := e.usage[key]
+	return u, ok
+}
+
+// formatter adds the usage of a pod or node to its metadata, as usage.cpu and usage.memory.
+func (c *Cache) formatter(resourceName string) types.Formatter {
+	return func(request *types.APIRequest, raw *types.RawResource) {
+		obj, ok := raw.APIObject.Object.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		key := obj.GetName()
+		if ns := obj.GetNamespace(); ns != "" {
+			key = ns + "/" + key
+		}
+		u, ok := c.get(request.Context(), resourceName, key)
+		if !ok {
+			return
+		}
+
+		// the object may be shared with a cache, so the usage is set on a copy of the maps it changes
+		metadata, _ := obj.Object["metadata"].(map[string]interface{})
+		withUsage := make(map[string]interface{}, len(metadata)+1)
+		for k, v := range metadata {
+			withUsage[k] = v
+		}
+		withUsage["usage"] = map[string]interface{}{
+			"cpu":    u.CPU.String(),
+			"memory": u.Memory.String(),
+		}
+		copied := make(map[string]interface{}, len(obj.Object))
+		for k, v := range obj.Object {
+			copied[k] = v
+		}
+		copied["metadata"] = withUsage
+		raw.APIObject.Object = &unstructured.Unstructured{Object: copied}
+	}
+}
+
+// addColumns adds the CPU and memory columns to the columns of a schema from its table.
+func addColumns(apiSchema *types.APISchema) {
+	cols, ok := attributes.Columns(apiSchema).([]common.ColumnDefinition)
+	if !ok {
+		return
+	}
+	withUsage := make([]common.ColumnDefinition, 0, len(cols)+2)
+	withUsage = append(withUsage, cols...)
+	withUsage = append(withUsage,
+		common.ColumnDefinition{
+			TableColumnDefinition: metav1.TableColumnDefinition{Name: "CPU", Type: "string", Description: "The CPU in use."},
+			Field:                 "$.metadata.usage.cpu",
+		},
+		common.ColumnDefinition{
+			TableColumnDefinition: metav1.TableColumnDefinition{Name: "Memory", Type: "string", Description: "The memory in use."},
+			Field:                 "$.metadata.usage.memory",
+		},
+	)
+	attributes.SetColumns(apiSchema, withUsage)
+}
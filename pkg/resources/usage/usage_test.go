@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type fakeSource struct {
+	calls int
+	usage map[string]Usage
+	err   error
+}
+
+func (f *fakeSource) Usage(ctx context.Context, resourceName string) (map[string]Usage, error) {
+	f.calls++
+	return f.usage, f.err
+}
+
+func TestCacheFormatter(t *testing.T) {
+	now := time.Now()
+	source := &fakeSource{err: errors.New("the server could not find the requested resource")}
+	c := NewCache(source, time.Minute)
+	c.now = func() time.Time { return now }
+	format := c.formatter(pods)
+
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web-1", "namespace": "default"},
+	}}
+	raw := &types.RawResource{APIObject: types.APIObject{Object: pod}}
+	request := &types.APIRequest{Request: httptest.NewRequest(http.MethodGet, "/v1/pods", nil)}
+
+	// without metrics the object is left alone
+	format(request, raw)
+	assert.Same(t, pod, raw.APIObject.Object)
+
+	source.err = nil
+	source.usage = map[string]Usage{"default/web-1": {CPU: resource.MustParse("250m"), Memory: resource.MustParse("64Mi")}}
+	format(request, raw)
+	assert.Same(t, pod, raw.APIObject.Object)
+	assert.Equal(t, 1, source.calls)
+
+	now = now.Add(2 * time.Minute)
+	format(request, raw)
+	assert.Equal(t, 2, source.calls)
+	formatted := raw.APIObject.Object.(*unstructured.Unstructured)
+	usage, _, _ := unstructured.NestedStringMap(formatted.Object, "metadata", "usage")
+	assert.Equal(t, map[string]string{"cpu": "250m", "memory": "64Mi"}, usage)
+	_, found, _ := unstructured.NestedMap(pod.Object, "metadata", "usage")
+	assert.False(t, found, "the cached object must not be changed")
+}
+
+func TestPrometheus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		value := `"0.25"`
+		if req.URL.Query().Get("query") == prometheusQueries[pods].memory {
+			value = `"67108864"`
+		}
+		_, _ = rw.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+			`{"metric":{"namespace":"default","pod":"web-1"},"value":[1700000000,` + value + `]}]}}`))
+	}))
+	defer server.Close()
+
+	usage, err := newPrometheus(server.URL+"/").Usage(context.Background(), pods)
+	require.NoError(t, err)
+	require.Contains(t, usage, "default/web-1")
+	u := usage["default/web-1"]
+	assert.Equal(t, "250m", u.CPU.String())
+	assert.Equal(t, "64Mi", u.Memory.String())
+}
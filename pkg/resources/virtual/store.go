@@ -0,0 +1,231 @@
+package virtual
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Store serves the objects of the source of a virtual schema that the user may see.
+type Store struct {
+	empty.Store
+	schema Schema
+	asl    accesscontrol.AccessSetLookup
+}
+
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	obj, ok, err := s.get(apiOp, s.namespace(apiOp), id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if !ok || !s.canSee(apiOp)(obj) {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, s.schema.ID+" "+id+" not found")
+	}
+	return s.toAPIObject(obj), nil
+}
+
+func (s *Store) get(apiOp *types.APIRequest, namespace, name string) (Object, bool, error) {
+	if getter, ok := s.schema.Source.(Getter); ok {
+		return getter.Get(apiOp, namespace, name)
+	}
+	objs, err := s.schema.Source.List(apiOp, namespace)
+	if err != nil {
+		return Object{}, false, err
+	}
+	for _, obj := range objs {
+		if obj.Namespace == namespace && obj.Name == name {
+			return obj, true, nil
+		}
+	}
+	return Object{}, false, nil
+}
+
+// List returns the objects the user may see, filtered, sorted and paged by the parameters of the request as the
+// lists of kubernetes resources are.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	objs, err := s.list(apiOp)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	result := make([]types.APIObject, 0, len(objs))
+	for _, obj := range objs {
+		result = append(result, s.toAPIObject(obj))
+	}
+
+	opts := listprocessor.ParseQuery(apiOp)
+	result = listprocessor.FilterList(result, opts.Filters)
+	result = listprocessor.SortList(result, opts.Sort)
+	writer.ListMetaFrom(apiOp.Context()).SetCount(len(result))
+	if opts.Pagination.PageSize > 0 {
+		var pages int
+		result, pages = listprocessor.PaginateList(result, opts.Pagination)
+		writer.ListMetaFrom(apiOp.Context()).SetPages(pages)
+	}
+	return types.APIObjectList{Objects: result}, nil
+}
+
+// list returns the objects of the namespace of the request the user may see.
+func (s *Store) list(apiOp *types.APIRequest) ([]Object, error) {
+	objs, err := s.schema.Source.List(apiOp, s.namespace(apiOp))
+	if err != nil {
+		return nil, err
+	}
+	canSee := s.canSee(apiOp)
+	result := make([]Object, 0, len(objs))
+	for _, obj := range objs {
+		if canSee(obj) {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+// Watch lists the objects again whenever the source notifies of a change, or every poll interval, and sends the
+// objects that were added, changed or removed since the previous list.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	var changed <-chan struct{}
+	if notifier, ok := s.schema.Source.(Notifier); ok {
+		changed = notifier.Changed(apiOp.Context())
+	} else {
+		ticker := time.NewTicker(s.schema.PollInterval)
+		ticks := make(chan struct{})
+		go func() {
+			defer ticker.Stop()
+			defer close(ticks)
+			for {
+				select {
+				case <-apiOp.Context().Done():
+					return
+				case <-ticker.C:
+				}
+				select {
+				case ticks <- struct{}{}:
+				case <-apiOp.Context().Done():
+					return
+				}
+			}
+		}()
+		changed = ticks
+	}
+
+	seen, err := s.watched(apiOp, w)
+	if err != nil {
+		return nil, err
+	}
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		send := func(name string, obj Object) bool {
+			select {
+			case result <- types.APIEvent{
+				Name:         name,
+				ResourceType: s.schema.ID,
+				Object:       s.toAPIObject(obj),
+			}:
+				return true
+			case <-apiOp.Context().Done():
+				return false
+			}
+		}
+		for range changed {
+			current, err := s.watched(apiOp, w)
+			if err != nil {
+				select {
+				case result <- types.APIEvent{Name: "resource.error", Error: err}:
+				case <-apiOp.Context().Done():
+				}
+				return
+			}
+			for key, obj := range current {
+				previous, ok := seen[key]
+				switch {
+				case !ok:
+					if !send(types.CreateAPIEvent, obj) {
+						return
+					}
+				case !same(previous, obj):
+					if !send(types.ChangeAPIEvent, obj) {
+						return
+					}
+				}
+			}
+			for key, obj := range seen {
+				if _, ok := current[key]; !ok && !send(types.RemoveAPIEvent, obj) {
+					return
+				}
+			}
+			seen = current
+		}
+	}()
+	return result, nil
+}
+
+// watched returns the objects of a watch the user may see, by their ID.
+func (s *Store) watched(apiOp *types.APIRequest, w types.WatchRequest) (map[string]Object, error) {
+	objs, err := s.list(apiOp)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]Object, len(objs))
+	for _, obj := range objs {
+		id := s.id(obj)
+		if w.ID != "" && w.ID != id && w.ID != obj.Name {
+			continue
+		}
+		result[id] = obj
+	}
+	return result, nil
+}
+
+func same(previous, current Object) bool {
+	if previous.Revision != "" || current.Revision != "" {
+		return previous.Revision == current.Revision
+	}
+	return reflect.DeepEqual(previous.Data, current.Data)
+}
+
+func (s *Store) namespace(apiOp *types.APIRequest) string {
+	if !s.schema.Namespaced {
+		return ""
+	}
+	return apiOp.Namespace
+}
+
+// canSee returns whether the user may see an object, by the access of the user to the resource of the schema.
+func (s *Store) canSee(apiOp *types.APIRequest) func(obj Object) bool {
+	if s.schema.Resource.Empty() {
+		return func(Object) bool { return true }
+	}
+	user, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return func(Object) bool { return false }
+	}
+	access := s.asl.AccessFor(user)
+	return func(obj Object) bool {
+		return access.Grants("get", s.schema.Resource, obj.Namespace, obj.Name) ||
+			access.Grants("list", s.schema.Resource, obj.Namespace, obj.Name)
+	}
+}
+
+func (s *Store) id(obj Object) string {
+	if obj.Namespace == "" {
+		return obj.Name
+	}
+	return obj.Namespace + "/" + obj.Name
+}
+
+func (s *Store) toAPIObject(obj Object) types.APIObject {
+	return types.APIObject{
+		Type:   s.schema.ID,
+		ID:     s.id(obj),
+		Object: obj.Data,
+	}
+}
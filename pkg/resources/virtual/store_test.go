@@ -0,0 +1,101 @@
+package virtual
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type image struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type fakeSource struct {
+	objects []Object
+	changed chan struct{}
+}
+
+func (f *fakeSource) List(apiOp *types.APIRequest, namespace string) ([]Object, error) {
+	var result []Object
+	for _, obj := range f.objects {
+		if namespace == "" || obj.Namespace == namespace {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeSource) Changed(ctx context.Context) <-chan struct{} {
+	return f.changed
+}
+
+type fakeLookup struct {
+	access *accesscontrol.AccessSet
+}
+
+func (f *fakeLookup) AccessFor(user.Info) *accesscontrol.AccessSet {
+	return f.access
+}
+
+func (f *fakeLookup) PurgeUserData(string) {}
+
+func newRequest(ctx context.Context, query string) *types.APIRequest {
+	req := httptest.NewRequest(http.MethodGet, "/v1/image?"+query, nil)
+	req = req.WithContext(request.WithUser(ctx, &user.DefaultInfo{Name: "alice"}))
+	return &types.APIRequest{Request: req}
+}
+
+func obj(namespace, name string, count int) Object {
+	return Object{Namespace: namespace, Name: name, Data: image{Name: name, Count: count}}
+}
+
+func TestStore(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	access := &accesscontrol.AccessSet{}
+	access.Add("list", pods, accesscontrol.Access{Namespace: "apps", ResourceName: accesscontrol.All})
+	source := &fakeSource{
+		objects: []Object{obj("apps", "nginx", 2), obj("apps", "busybox", 1), obj("apps", "redis", 3), obj("system", "etcd", 1)},
+		changed: make(chan struct{}),
+	}
+	store := &Store{
+		schema: Schema{ID: "image", Namespaced: true, Resource: pods, Source: source},
+		asl:    &fakeLookup{access: access},
+	}
+
+	list, err := store.List(newRequest(context.Background(), "sort=name&pagesize=2"), nil)
+	require.NoError(t, err)
+	require.Len(t, list.Objects, 2, "objects of namespaces the user may not list are not seen")
+	assert.Equal(t, "apps/busybox", list.Objects[0].ID)
+	assert.Equal(t, "apps/nginx", list.Objects[1].ID)
+
+	_, err = store.ByID(newRequest(context.Background(), ""), nil, "etcd")
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := store.Watch(newRequest(ctx, ""), nil, types.WatchRequest{})
+	require.NoError(t, err)
+
+	source.objects = []Object{obj("apps", "nginx", 4), obj("apps", "busybox", 1), obj("apps", "postgres", 1), obj("system", "etcd", 2)}
+	source.changed <- struct{}{}
+	received := map[string]string{}
+	for i := 0; i < 3; i++ {
+		event := <-events
+		received[event.Object.ID] = event.Name
+	}
+	assert.Equal(t, map[string]string{
+		"apps/nginx":    types.ChangeAPIEvent,
+		"apps/postgres": types.CreateAPIEvent,
+		"apps/redis":    types.RemoveAPIEvent,
+	}, received)
+}
@@ -0,0 +1,162 @@
+// Package virtual serves virtual schemas, whose objects are computed by Go code, such as aggregations of other
+// resources or the state of external systems, rather than stored in kubernetes. Virtual schemas are listed with
+// the other schemas, their objects are filtered by the access of the user to a kubernetes resource, lists are
+// filtered, sorted and paged by the usual query parameters, and watches send the objects that change.
+//
+// For example, to serve the images of the pods of a namespace:
+//
+//	registry.Add(virtual.Schema{
+//		ID:         "image",
+//		Object:     Image{},
+//		Namespaced: true,
+//		Resource:   schema.GroupResource{Resource: "pods"},
+//		Source:     imageSource,
+//	})
+package virtual
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	pollIntervalEnv     = "CATTLE_VIRTUAL_POLL_INTERVAL_SECONDS"
+	defaultPollInterval = 10 * time.Second
+)
+
+// Object is an object of a virtual schema.
+type Object struct {
+	// Namespace and Name identify the object, and are checked against the access of the user to the resource of the
+	// schema. The namespace is empty for objects of schemas that aren't namespaced.
+	Namespace string
+	Name      string
+	// Revision changes whenever the object does, so watches send only the objects that changed. Objects without a
+	// revision are compared by their data instead.
+	Revision string
+	// Data is the object served, a value of the type of the Object of the schema.
+	Data interface{}
+}
+
+// Source computes the objects of a virtual schema.
+type Source interface {
+	// List returns the objects of the namespace, or of every namespace if the namespace is empty. The request is
+	// that of the user, so sources may read other schemas as the user.
+	List(apiOp *types.APIRequest, namespace string) ([]Object, error)
+}
+
+// Getter is implemented by sources that can compute a single object without listing the objects of its
+// namespace. Objects of other sources are found by listing.
+type Getter interface {
+	// Get returns the object of the name in the namespace, and false if there is none.
+	Get(apiOp *types.APIRequest, namespace, name string) (Object, bool, error)
+}
+
+// Notifier is implemented by sources that know when their objects change. Watches of other sources list the
+// objects again every poll interval.
+type Notifier interface {
+	// Changed returns a channel that receives a value whenever objects of the source may have changed, and is
+	// closed when the context is done.
+	Changed(ctx context.Context) <-chan struct{}
+}
+
+// Schema is a virtual schema.
+type Schema struct {
+	// ID is the ID of the schema, and the type of its objects.
+	ID string
+	// Object is a value of the struct type of the objects, whose fields are the fields of the schema.
+	Object interface{}
+	// Namespaced is whether the objects are in namespaces, and may be listed by namespace.
+	Namespaced bool
+	// Resource is the kubernetes resource whose access decides the objects a user sees: the objects of the
+	// namespaces and names the user may get or list in it. Every user sees every object if it is empty.
+	Resource schema.GroupResource
+	// Source computes the objects.
+	Source Source
+	// PollInterval is how often watches list the objects of a source that isn't a Notifier, every 10 seconds by
+	// default.
+	PollInterval time.Duration
+}
+
+// Registry is the virtual schemas served. Only the schemas added before the registry is registered with the base
+// schemas are served.
+type Registry struct {
+	lock    sync.RWMutex
+	schemas map[string]Schema
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas: map[string]Schema{},
+	}
+}
+
+// Add adds a virtual schema, replacing any virtual schema of the same ID.
+func (r *Registry) Add(s Schema) error {
+	if s.ID == "" {
+		return fmt.Errorf("virtual schema has no ID")
+	}
+	if s.Object == nil || s.Source == nil {
+		return fmt.Errorf("virtual schema %s needs an object and a source", s.ID)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.schemas[s.ID] = s
+	return nil
+}
+
+// Register adds the schemas of the registry to the base schemas.
+func (r *Registry) Register(apiSchemas *types.APISchemas, asl accesscontrol.AccessSetLookup) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, s := range r.schemas {
+		register(apiSchemas, asl, s)
+	}
+}
+
+func register(apiSchemas *types.APISchemas, asl accesscontrol.AccessSetLookup, s Schema) {
+	if s.PollInterval <= 0 {
+		s.PollInterval = pollInterval()
+	}
+	apiSchemas.InternalSchemas.TypeName(s.ID, s.Object)
+	apiSchemas.MustImportAndCustomize(s.Object, func(apiSchema *types.APISchema) {
+		apiSchema.CollectionMethods = []string{http.MethodGet}
+		apiSchema.ResourceMethods = []string{http.MethodGet}
+		attributes.SetNamespaced(apiSchema, s.Namespaced)
+		apiSchema.Attributes["access"] = accesscontrol.AccessListByVerb{
+			"watch": accesscontrol.AccessList{
+				{
+					Namespace:    "*",
+					ResourceName: "*",
+				},
+			},
+		}
+		apiSchema.Store = &Store{
+			schema: s,
+			asl:    asl,
+		}
+	})
+}
+
+func pollInterval() time.Duration {
+	if setting := os.Getenv(pollIntervalEnv); setting != "" {
+		seconds, err := strconv.Atoi(setting)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", pollIntervalEnv, err)
+		} else if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultPollInterval
+}
@@ -0,0 +1,256 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/data"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type apiOpKey struct{}
+
+// Handler returns a handler which serves the Steve service. Each call runs with the request returned by
+// requestFor for the HTTP/2 request carrying it, so it has the schemas and access of the user.
+func Handler(requestFor func(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool)) http.Handler {
+	s := grpc.NewServer()
+	RegisterSteveServer(s, &server{})
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiOp, ok := requestFor(rw, req)
+		if !ok {
+			return
+		}
+		s.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), apiOpKey{}, apiOp)))
+	})
+}
+
+type server struct{}
+
+func (s *server) List(ctx context.Context, in *ListRequest) (*ListResponse, error) {
+	query := url.Values{}
+	if in.LabelSelector != "" {
+		query.Set("labelSelector", in.LabelSelector)
+	}
+	if in.FieldSelector != "" {
+		query.Set("fieldSelector", in.FieldSelector)
+	}
+	if in.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(in.Limit, 10))
+	}
+	if in.Continue != "" {
+		query.Set("continue", in.Continue)
+	}
+	apiOp, err := request(ctx, in.Type, in.Namespace, "", http.MethodGet, query)
+	if err != nil {
+		return nil, err
+	}
+	list, err := handlers.ListHandler(apiOp)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &ListResponse{
+		Revision: list.Revision,
+		Continue: list.Continue,
+	}
+	for _, obj := range list.Objects {
+		o, err := toObject(apiOp.Schema, obj)
+		if err != nil {
+			return nil, err
+		}
+		resp.Objects = append(resp.Objects, o)
+	}
+	return resp, nil
+}
+
+func (s *server) Get(ctx context.Context, in *GetRequest) (*Object, error) {
+	apiOp, err := request(ctx, in.Type, in.Namespace, in.Name, http.MethodGet, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	obj, err := handlers.ByIDHandler(apiOp)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toObject(apiOp.Schema, obj)
+}
+
+func (s *server) Watch(in *WatchRequest, stream Steve_WatchServer) error {
+	apiOp, err := request(stream.Context(), in.Type, in.Namespace, in.Name, http.MethodGet, url.Values{})
+	if err != nil {
+		return err
+	}
+	if err := apiOp.AccessControl.CanWatch(apiOp, apiOp.Schema); err != nil {
+		return toStatus(err)
+	}
+	if apiOp.Schema.Store == nil {
+		return status.Errorf(codes.NotFound, "no store found for %s", in.Type)
+	}
+
+	events, err := apiOp.Schema.Store.Watch(apiOp, apiOp.Schema, types.WatchRequest{
+		Revision: in.Revision,
+		ID:       in.Name,
+		Selector: in.LabelSelector,
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	for event := range events {
+		if in.Name != "" && event.Object.Object != nil && event.Object.Name() != in.Name {
+			continue
+		}
+		resp := &WatchEvent{
+			Name:     event.Name,
+			Revision: event.Revision,
+		}
+		if event.Error != nil {
+			resp.Error = event.Error.Error()
+		}
+		if event.Object.Object != nil {
+			if resp.Object, err = toObject(apiOp.Schema, event.Object); err != nil {
+				return err
+			}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return stream.Context().Err()
+}
+
+func (s *server) Apply(ctx context.Context, in *ApplyRequest) (*Object, error) {
+	obj := data.Object(in.Object.AsMap())
+	name := obj.String("metadata", "name")
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "metadata.name is required")
+	}
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	query := url.Values{}
+	if in.FieldManager != "" {
+		query.Set("fieldManager", in.FieldManager)
+	}
+	if in.Force {
+		query.Set("force", "true")
+	}
+	if in.DryRun {
+		query.Set("dryRun", "All")
+	}
+	apiOp, err := request(ctx, in.Type, obj.String("metadata", "namespace"), name, http.MethodPatch, query)
+	if err != nil {
+		return nil, err
+	}
+	// a server-side apply of the object, which creates it if it doesn't exist
+	apiOp.Request.Header.Set("Content-Type", "application/apply-patch+yaml")
+	apiOp.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	result, err := handlers.UpdateHandler(apiOp)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toObject(apiOp.Schema, result)
+}
+
+// request returns the request of a call for a type, as the HTTP API would parse it.
+func request(ctx context.Context, schemaID, namespace, name, method string, query url.Values) (*types.APIRequest, error) {
+	parent, ok := ctx.Value(apiOpKey{}).(*types.APIRequest)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "no user")
+	}
+	schema := parent.Schemas.LookupSchema(schemaID)
+	if schema == nil {
+		return nil, status.Errorf(codes.NotFound, "unknown type %s", schemaID)
+	}
+
+	apiOp := parent.Clone()
+	apiOp.Schema = schema
+	apiOp.Type = schema.ID
+	apiOp.Namespace = namespace
+	apiOp.Name = name
+	apiOp.Method = method
+	apiOp.Query = query
+	apiOp.Request = parent.Request.Clone(ctx)
+	apiOp.Request.Method = method
+	apiOp.Request.URL.RawQuery = query.Encode()
+	apiOp.Request.Header.Set("Content-Type", "application/json")
+	apiOp.Request.Header.Set("Accept", "application/json")
+	return apiOp, nil
+}
+
+// toObject returns the protobuf form of an object of a schema.
+func toObject(schema *types.APISchema, obj types.APIObject) (*Object, error) {
+	content, err := structpb.NewStruct(obj.Data())
+	if err != nil {
+		// values of types the protobuf struct doesn't support are converted through their JSON form
+		var generic map[string]interface{}
+		bytes, err := json.Marshal(obj.Object)
+		if err == nil {
+			err = json.Unmarshal(bytes, &generic)
+		}
+		if err == nil {
+			content, err = structpb.NewStruct(generic)
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return &Object{
+		Type:   schema.ID,
+		Id:     obj.ID,
+		Object: content,
+	}, nil
+}
+
+// toStatus returns the gRPC status of an error of a store or of the access control.
+func toStatus(err error) error {
+	code := http.StatusInternalServerError
+	var (
+		apiErr    *apierror.APIError
+		apiStatus apierrors.APIStatus
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		code = apiErr.Code.Status
+	case errors.As(err, &apiStatus):
+		code = int(apiStatus.Status().Code)
+	}
+
+	switch {
+	case code == http.StatusBadRequest || code == http.StatusUnprocessableEntity:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case code == http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case code == http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case code == http.StatusNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case code == http.StatusConflict && apierrors.IsAlreadyExists(err):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case code == http.StatusConflict:
+		return status.Error(codes.Aborted, err.Error())
+	case code == http.StatusMethodNotAllowed:
+		return status.Error(codes.Unimplemented, err.Error())
+	case code == http.StatusTooManyRequests:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case code == http.StatusServiceUnavailable:
+		return status.Error(codes.Unavailable, err.Error())
+	case code == http.StatusGatewayTimeout:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
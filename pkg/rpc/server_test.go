@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type podStore struct {
+	empty.Store
+}
+
+func (p *podStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if id != "web-1" {
+		return types.APIObject{}, apierrors.NewNotFound(schema2.GroupResource{Resource: "pods"}, id)
+	}
+	return types.APIObject{
+		Type: schema.ID,
+		ID:   apiOp.Namespace + "/" + id,
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": id, "namespace": apiOp.Namespace},
+			"spec":     map[string]interface{}{"priority": int64(1)},
+		}},
+	}, nil
+}
+
+func (p *podStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	obj, _ := p.ByID(apiOp, schema, "web-1")
+	return types.APIObjectList{
+		Revision: "10",
+		Continue: apiOp.Request.URL.Query().Get("limit"),
+		Objects:  []types.APIObject{obj},
+	}, nil
+}
+
+func TestServer(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	methods := []string{http.MethodGet}
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", ResourceMethods: methods, CollectionMethods: methods},
+		Store:  &podStore{},
+	}))
+	ctx := context.WithValue(context.Background(), apiOpKey{}, &types.APIRequest{
+		Schemas:       apiSchemas,
+		AccessControl: accesscontrol.NewAccessControl(),
+		Request:       httptest.NewRequest(http.MethodPost, "/steve.v1.Steve/List", nil),
+	})
+	s := &server{}
+
+	list, err := s.List(ctx, &ListRequest{Type: "pod", Namespace: "default", Limit: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "10", list.Revision)
+	assert.Equal(t, "5", list.Continue)
+	require.Len(t, list.Objects, 1)
+	assert.Equal(t, "default/web-1", list.Objects[0].Id)
+	assert.Equal(t, float64(1), list.Objects[0].Object.AsMap()["spec"].(map[string]interface{})["priority"])
+
+	_, err = s.Get(ctx, &GetRequest{Type: "pod", Namespace: "default", Name: "web-2"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.Get(ctx, &GetRequest{Type: "secret", Namespace: "default", Name: "web-1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.Apply(ctx, &ApplyRequest{Type: "pod"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.Get(context.Background(), &GetRequest{Type: "pod"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,107 @@
+// Package rpc serves the steve schemas over gRPC, as the Steve service of steve.proto, for agents and CLIs that
+// prefer protobuf and streaming watches over the HTTP JSON API. Requests go through the same authentication,
+// access control and stores as the HTTP API.
+package rpc
+
+//go:generate protoc --go_out=paths=source_relative:. steve.proto
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// SteveServer is the server API of the Steve service.
+type SteveServer interface {
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Get(context.Context, *GetRequest) (*Object, error)
+	Watch(*WatchRequest, Steve_WatchServer) error
+	Apply(context.Context, *ApplyRequest) (*Object, error)
+}
+
+// Steve_WatchServer is the stream of the events of a watch.
+type Steve_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type steveWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *steveWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RegisterSteveServer registers the Steve service on a gRPC server.
+func RegisterSteveServer(s grpc.ServiceRegistrar, srv SteveServer) {
+	s.RegisterService(&steveServiceDesc, srv)
+}
+
+var steveServiceDesc = grpc.ServiceDesc{
+	ServiceName: "steve.v1.Steve",
+	HandlerType: (*SteveServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(ListRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return unary(ctx, in, "List", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SteveServer).List(ctx, req.(*ListRequest))
+				})
+			},
+		},
+		{
+			MethodName: "Get",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(GetRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return unary(ctx, in, "Get", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SteveServer).Get(ctx, req.(*GetRequest))
+				})
+			},
+		},
+		{
+			MethodName: "Apply",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(ApplyRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return unary(ctx, in, "Apply", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SteveServer).Apply(ctx, req.(*ApplyRequest))
+				})
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Watch",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := new(WatchRequest)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(SteveServer).Watch(in, &steveWatchServer{stream})
+			},
+			ServerStreams: true,
+		},
+	},
+	Metadata: "steve.proto",
+}
+
+// unary calls the handler of a unary method through the interceptor of the server, if any.
+func unary(ctx context.Context, in interface{}, method string, interceptor grpc.UnaryServerInterceptor, handler grpc.UnaryHandler) (interface{}, error) {
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/steve.v1.Steve/" + method,
+	}
+	return interceptor(ctx, in, info, handler)
+}
@@ -109,6 +109,36 @@ func (c *Collection) OnChange(ctx context.Context, cb func()) {
 }
 
 func (c *Collection) Reset(schemas map[string]*types.APISchema) {
+	for _, s := range schemas {
+		c.applyTemplates(s)
+	}
+	c.set(schemas)
+}
+
+// Update adds or replaces the schemas in changed and drops the schemas in removed, keeping every other schema as
+// it is, so templates are only applied to the schemas that changed.
+func (c *Collection) Update(changed map[string]*types.APISchema, removed []string) {
+	for _, s := range changed {
+		c.applyTemplates(s)
+	}
+
+	c.lock.RLock()
+	schemas := make(map[string]*types.APISchema, len(c.schemas)+len(changed))
+	for id, s := range c.schemas {
+		schemas[id] = s
+	}
+	c.lock.RUnlock()
+	for _, id := range removed {
+		delete(schemas, id)
+	}
+	for id, s := range changed {
+		schemas[id] = s
+	}
+	c.set(schemas)
+}
+
+// set replaces the schemas, which already have their templates applied, and notifies the listeners.
+func (c *Collection) set(schemas map[string]*types.APISchema) {
 	byGVK := map[schema.GroupVersionKind]string{}
 	byGVR := map[schema.GroupVersionResource]string{}
 
@@ -121,8 +151,6 @@ func (c *Collection) Reset(schemas map[string]*types.APISchema) {
 		if gvk.Kind != "" {
 			byGVK[gvk] = s.ID
 		}
-
-		c.applyTemplates(s)
 	}
 
 	c.lock.Lock()
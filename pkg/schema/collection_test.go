@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionUpdate(t *testing.T) {
+	collection := NewCollection(context.TODO(), types.EmptyAPISchemas(), newMockAccessSetLookup())
+	customized := map[string]int{}
+	collection.AddTemplate(Template{
+		Customize: func(s *types.APISchema) {
+			customized[s.ID]++
+		},
+	})
+	notified := 0
+	collection.OnChange(context.TODO(), func() {
+		notified++
+	})
+
+	schema := func(id string) *types.APISchema {
+		return &types.APISchema{Schema: &schemas.Schema{ID: id}}
+	}
+	collection.Reset(map[string]*types.APISchema{
+		"a": schema("a"),
+		"b": schema("b"),
+		"c": schema("c"),
+	})
+	unchanged := collection.Schema("a")
+
+	collection.Update(map[string]*types.APISchema{
+		"b": schema("b"),
+		"d": schema("d"),
+	}, []string{"c"})
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 1, "d": 1}, customized)
+	assert.Same(t, unchanged, collection.Schema("a"))
+	assert.NotNil(t, collection.Schema("d"))
+	assert.Nil(t, collection.Schema("c"))
+	assert.Equal(t, 2, notified)
+}
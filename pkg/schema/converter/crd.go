@@ -1,6 +1,9 @@
 package converter
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/attributes"
 	"github.com/rancher/steve/pkg/schema/table"
@@ -9,6 +12,7 @@ import (
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 )
 
 var (
@@ -48,14 +52,70 @@ func AddCustomResources(crd apiextv1.CustomResourceDefinitionClient, schemas map
 	return nil
 }
 
+// AddCustomResource adds the schemas of a single CRD from the discovery of its served versions and the schemas in its
+// spec, without the OpenAPI document of the whole cluster, so a change to one CRD is cheap to convert.
+func AddCustomResource(crd *v1.CustomResourceDefinition, client discovery.DiscoveryInterface, schemas map[string]*types.APISchema) error {
+	if crd.Status.AcceptedNames.Plural == "" {
+		return nil
+	}
+
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return err
+	}
+	var apiGroups []*metav1.APIGroup
+	for i := range groups.Groups {
+		apiGroups = append(apiGroups, &groups.Groups[i])
+	}
+	versions := indexVersions(apiGroups)
+
+	group, kind, plural := crd.Spec.Group, crd.Status.AcceptedNames.Kind, crd.Status.AcceptedNames.Plural
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		gv := schema.GroupVersion{Group: group, Version: version.Name}
+		resources, err := client.ServerResourcesForGroupVersion(gv.String())
+		if err != nil {
+			return err
+		}
+		// the group may hold other resources, which are left as they are
+		crdResources := resources.DeepCopy()
+		crdResources.APIResources = nil
+		for _, resource := range resources.APIResources {
+			if resource.Name == plural || strings.HasPrefix(resource.Name, plural+"/") {
+				crdResources.APIResources = append(crdResources.APIResources, resource)
+			}
+		}
+		if err := refresh(gv, versions, crdResources, schemas); err != nil {
+			return err
+		}
+		forVersion(crd, group, kind, version, schemas)
+	}
+	return nil
+}
+
 func forVersion(crd *v1.CustomResourceDefinition, group, kind string, version v1.CustomResourceDefinitionVersion, schemasMap map[string]*types.APISchema) {
+	// the cells of the table of a CRD are its name followed by the printer columns
 	var versionColumns []table.Column
-	for _, col := range version.AdditionalPrinterColumns {
+	for i, col := range version.AdditionalPrinterColumns {
+		if i == 0 {
+			versionColumns = append(versionColumns, table.Column{
+				Name:     "Name",
+				Field:    "$.metadata.fields[0]",
+				Type:     "string",
+				Format:   "name",
+				JSONPath: ".metadata.name",
+			})
+		}
 		versionColumns = append(versionColumns, table.Column{
-			Name:   col.Name,
-			Field:  col.JSONPath,
-			Type:   col.Type,
-			Format: col.Format,
+			Name:        col.Name,
+			Field:       fmt.Sprintf("$.metadata.fields[%d]", i+1),
+			Type:        col.Type,
+			Format:      col.Format,
+			Description: col.Description,
+			Priority:    int(col.Priority),
+			JSONPath:    col.JSONPath,
 		})
 	}
 
@@ -64,6 +64,13 @@ func indexVersions(groups []*metav1.APIGroup) map[string]string {
 }
 
 func refresh(gv schema.GroupVersion, groupToPreferredVersion map[string]string, resources *metav1.APIResourceList, schemasMap map[string]*types.APISchema) error {
+	subresources := map[string][]string{}
+	for _, resource := range resources.APIResources {
+		if parent, subresource, ok := strings.Cut(resource.Name, "/"); ok {
+			subresources[parent] = append(subresources[parent], subresource)
+		}
+	}
+
 	for _, resource := range resources.APIResources {
 		if strings.Contains(resource.Name, "/") {
 			continue
@@ -88,6 +95,9 @@ func refresh(gv schema.GroupVersion, groupToPreferredVersion map[string]string,
 
 		schema.PluralName = gvrToPluralName(gvr)
 		attributes.SetAPIResource(schema, resource)
+		if len(subresources[resource.Name]) > 0 {
+			attributes.SetSubresources(schema, subresources[resource.Name])
+		}
 		if preferredVersion := groupToPreferredVersion[gv.Group]; preferredVersion != "" && preferredVersion != gv.Version {
 			attributes.SetPreferredVersion(schema, preferredVersion)
 		}
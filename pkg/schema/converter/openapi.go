@@ -82,6 +82,7 @@ func AddOpenAPI(client discovery.DiscoveryInterface, schemas map[string]*types.A
 func toField(schema proto.Schema) schemas.Field {
 	f := schemas.Field{
 		Description: schema.GetDescription(),
+		Default:     schema.GetDefault(),
 		Create:      true,
 		Update:      true,
 	}
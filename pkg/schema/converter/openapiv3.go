@@ -1,8 +1,11 @@
 package converter
 
 import (
+	"encoding/json"
+
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/sirupsen/logrus"
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 )
 
@@ -49,6 +52,11 @@ func toResourceField(name string, schema v1.JSONSchemaProps, schemasMap map[stri
 		Create:      true,
 		Update:      true,
 	}
+	if schema.Default != nil {
+		if err := json.Unmarshal(schema.Default.Raw, &f.Default); err != nil {
+			logrus.Debugf("invalid default of %s: %v", name, err)
+		}
+	}
 	var itemSchema *v1.JSONSchemaProps
 	if schema.Items != nil {
 		if schema.Items.Schema != nil {
@@ -9,6 +9,8 @@ import (
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/wrangler/pkg/slice"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
 
@@ -23,6 +25,7 @@ func newSchemas() (*types.APISchemas, error) {
 
 func (c *Collection) Schemas(user user.Info) (*types.APISchemas, error) {
 	access := c.as.AccessFor(user)
+	previous := c.previousSchemas(access, user)
 	c.removeOldRecords(access, user)
 	val, ok := c.cache.Get(access.ID)
 	if ok {
@@ -30,14 +33,40 @@ func (c *Collection) Schemas(user user.Info) (*types.APISchemas, error) {
 		return schemas, nil
 	}
 
-	schemas, err := c.schemasForSubject(access)
+	start := time.Now()
+	mode := "full"
+	schemas, computed, err := c.schemasForSubjectDelta(access, previous)
+	if err == nil && schemas != nil {
+		mode = "delta"
+	} else if err == nil {
+		schemas, computed, err = c.schemasForSubject(access)
+	}
 	if err != nil {
 		return nil, err
 	}
+	metrics.RecordSchemaRecompute(mode, float64(time.Since(start).Milliseconds()), computed)
 	c.addToCache(access, user, schemas)
 	return schemas, nil
 }
 
+// previousSchemas returns the cached schemas of the previous access set of the user, if its access changed since.
+func (c *Collection) previousSchemas(access *accesscontrol.AccessSet, user user.Info) *types.APISchemas {
+	current, ok := c.userCache.Get(user.GetName())
+	if !ok {
+		return nil
+	}
+	currentID, _ := current.(string)
+	if currentID == "" || currentID == access.ID {
+		return nil
+	}
+	val, ok := c.cache.Get(currentID)
+	if !ok {
+		return nil
+	}
+	schemas, _ := val.(*types.APISchemas)
+	return schemas
+}
+
 func (c *Collection) removeOldRecords(access *accesscontrol.AccessSet, user user.Info) {
 	current, ok := c.userCache.Get(user.GetName())
 	if ok {
@@ -62,104 +91,170 @@ func (c *Collection) purgeUserRecords(id string) {
 	c.as.PurgeUserData(id)
 }
 
-func (c *Collection) schemasForSubject(access *accesscontrol.AccessSet) (*types.APISchemas, error) {
+// schemasForSubject returns the schemas of an access set, and the number of schemas whose access was computed.
+func (c *Collection) schemasForSubject(access *accesscontrol.AccessSet) (*types.APISchemas, int, error) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
 	result, err := newSchemas()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if err := result.AddSchemas(c.baseSchema); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
+	computed := 0
 	for _, s := range c.schemas {
-		gr := attributes.GR(s)
-
-		if gr.Resource == "" {
-			if err := result.AddSchema(*s); err != nil {
-				return nil, err
-			}
-			continue
+		if attributes.GR(s).Resource != "" {
+			computed++
+		}
+		if err := addSchemaForSubject(result, access, s); err != nil {
+			return nil, 0, err
 		}
+	}
 
-		verbs := attributes.Verbs(s)
-		verbAccess := accesscontrol.AccessListByVerb{}
-
-		for _, verb := range verbs {
-			a := access.AccessListFor(verb, gr)
-			if !attributes.Namespaced(s) {
-				// trim out bad data where we are granted namespaced access to cluster scoped object
-				result := accesscontrol.AccessList{}
-				for _, access := range a {
-					if access.Namespace == accesscontrol.All {
-						result = append(result, access)
-					}
+	result.Attributes = map[string]interface{}{
+		"accessSet": access,
+	}
+	return result, computed, nil
+}
+
+// schemasForSubjectDelta returns the schemas of an access set from the schemas of the previous access set of the
+// same user, computing the access of only the schemas whose resources have different access, so a change of RBAC
+// doesn't recompute every schema of a user. It returns nil schemas if they must be computed in full instead.
+func (c *Collection) schemasForSubjectDelta(access *accesscontrol.AccessSet, previous *types.APISchemas) (*types.APISchemas, int, error) {
+	if previous == nil {
+		return nil, 0, nil
+	}
+	previousAccess, _ := previous.Attributes["accessSet"].(*accesscontrol.AccessSet)
+	if previousAccess == nil || previousAccess == access {
+		return nil, 0, nil
+	}
+	changed, all := previousAccess.Changed(access)
+	if all {
+		return nil, 0, nil
+	}
+	namespacesChanged := !slice.StringsEqual(previousAccess.Namespaces(), access.Namespaces())
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	result, err := newSchemas()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := result.AddSchemas(c.baseSchema); err != nil {
+		return nil, 0, err
+	}
+
+	computed := 0
+	for _, s := range c.schemas {
+		gr := attributes.GR(s)
+		if gr.Resource != "" && !changed[gr] && !(namespacesChanged && gr.Group == "" && gr.Resource == "namespaces") {
+			// the access of the resource is the same, so the schema is too, as is its absence
+			if previousSchema := previous.Schemas[s.ID]; previousSchema != nil {
+				if err := result.AddSchema(*previousSchema); err != nil {
+					return nil, 0, err
 				}
-				a = result
-			}
-			if len(a) > 0 {
-				verbAccess[verb] = a
 			}
+			continue
+		}
+		if gr.Resource != "" {
+			computed++
+		}
+		if err := addSchemaForSubject(result, access, s); err != nil {
+			return nil, 0, err
 		}
+	}
 
-		if len(verbAccess) == 0 {
-			if gr.Group == "" && gr.Resource == "namespaces" {
-				var accessList accesscontrol.AccessList
-				for _, ns := range access.Namespaces() {
-					accessList = append(accessList, accesscontrol.Access{
-						Namespace:    accesscontrol.All,
-						ResourceName: ns,
-					})
-				}
-				verbAccess["get"] = accessList
-				verbAccess["watch"] = accessList
-				if len(accessList) == 0 {
-					// always allow list
-					s.CollectionMethods = append(s.CollectionMethods, http.MethodGet)
+	result.Attributes = map[string]interface{}{
+		"accessSet": access,
+	}
+	return result, computed, nil
+}
+
+// addSchemaForSubject adds the schema to the result with the methods and access of the access set, unless the
+// access set grants nothing on it.
+func addSchemaForSubject(result *types.APISchemas, access *accesscontrol.AccessSet, s *types.APISchema) error {
+	gr := attributes.GR(s)
+
+	if gr.Resource == "" {
+		return result.AddSchema(*s)
+	}
+
+	verbs := attributes.Verbs(s)
+	verbAccess := accesscontrol.AccessListByVerb{}
+
+	for _, verb := range verbs {
+		a := access.AccessListFor(verb, gr)
+		if !attributes.Namespaced(s) {
+			// trim out bad data where we are granted namespaced access to cluster scoped object
+			result := accesscontrol.AccessList{}
+			for _, access := range a {
+				if access.Namespace == accesscontrol.All {
+					result = append(result, access)
 				}
 			}
+			a = result
 		}
-
-		allowed := func(method string) string {
-			if attributes.DisallowMethods(s)[method] {
-				return "blocked-" + method
-			}
-			return method
+		if len(a) > 0 {
+			verbAccess[verb] = a
 		}
+	}
 
-		s = s.DeepCopy()
-		attributes.SetAccess(s, verbAccess)
-		if verbAccess.AnyVerb("list", "get") {
-			s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodGet))
-			s.CollectionMethods = append(s.CollectionMethods, allowed(http.MethodGet))
-		}
-		if verbAccess.AnyVerb("delete") {
-			s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodDelete))
-		}
-		if verbAccess.AnyVerb("update") {
-			s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodPut))
-			s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodPatch))
-		}
-		if verbAccess.AnyVerb("create") {
-			s.CollectionMethods = append(s.CollectionMethods, allowed(http.MethodPost))
+	if len(verbAccess) == 0 {
+		if gr.Group == "" && gr.Resource == "namespaces" {
+			var accessList accesscontrol.AccessList
+			for _, ns := range access.Namespaces() {
+				accessList = append(accessList, accesscontrol.Access{
+					Namespace:    accesscontrol.All,
+					ResourceName: ns,
+				})
+			}
+			verbAccess["get"] = accessList
+			verbAccess["watch"] = accessList
+			if len(accessList) == 0 {
+				// always allow list
+				s.CollectionMethods = append(s.CollectionMethods, http.MethodGet)
+			}
 		}
+	}
 
-		if len(s.CollectionMethods) == 0 && len(s.ResourceMethods) == 0 {
-			continue
+	allowed := func(method string) string {
+		if attributes.DisallowMethods(s)[method] {
+			return "blocked-" + method
 		}
+		return method
+	}
 
-		if err := result.AddSchema(*s); err != nil {
-			return nil, err
-		}
+	s = s.DeepCopy()
+	attributes.SetAccess(s, verbAccess)
+	if verbAccess.AnyVerb("list", "get") {
+		s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodGet))
+		s.CollectionMethods = append(s.CollectionMethods, allowed(http.MethodGet))
+	}
+	if verbAccess.AnyVerb("delete") {
+		s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodDelete))
+	}
+	if verbAccess.AnyVerb("delete") && verbAccess.AnyVerb("deletecollection") {
+		s.CollectionMethods = append(s.CollectionMethods, allowed(http.MethodDelete))
+	}
+	if verbAccess.AnyVerb("update") {
+		s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodPut))
+		s.ResourceMethods = append(s.ResourceMethods, allowed(http.MethodPatch))
+	}
+	if verbAccess.AnyVerb("create") {
+		s.CollectionMethods = append(s.CollectionMethods, allowed(http.MethodPost))
 	}
 
-	result.Attributes = map[string]interface{}{
-		"accessSet": access,
+	if len(s.CollectionMethods) == 0 && len(s.ResourceMethods) == 0 {
+		return nil
 	}
-	return result, nil
+
+	return result.AddSchema(*s)
 }
 
 func (c *Collection) defaultStore() types.Store {
@@ -120,6 +120,43 @@ func TestSchemaCache(t *testing.T) {
 	}
 }
 
+func TestSchemasDelta(t *testing.T) {
+	mockLookup := newMockAccessSetLookup()
+	testUser := &user.DefaultInfo{Name: "testUser"}
+	testCRD := k8sSchema.GroupResource{Group: testGroup, Resource: "testCRD"}
+	otherCRD := k8sSchema.GroupResource{Group: testGroup, Resource: "otherCRD"}
+
+	collection := NewCollection(context.TODO(), types.EmptyAPISchemas(), mockLookup)
+	collection.schemas = map[string]*types.APISchema{
+		"testCRD":  makeSchema("testCRD"),
+		"otherCRD": makeSchema("otherCRD"),
+	}
+
+	mockLookup.AddAccessForUser(testUser, "get", testCRD, "*", "*")
+	mockLookup.AddAccessForUser(testUser, "get", otherCRD, "*", "*")
+	before, err := collection.Schemas(testUser)
+	assert.NoError(t, err)
+
+	mockLookup.Clear()
+	mockLookup.AddAccessForUser(testUser, "get", testCRD, "*", "*")
+	mockLookup.AddAccessForUser(testUser, "create", testCRD, "*", "*")
+	mockLookup.AddAccessForUser(testUser, "get", otherCRD, "*", "*")
+	after, computed, err := collection.schemasForSubjectDelta(mockLookup.AccessFor(testUser), before)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, computed, "only the schema whose access changed is recomputed")
+	assert.Contains(t, after.Schemas["testCRD"].CollectionMethods, "POST")
+	assert.Equal(t, before.Schemas["otherCRD"].CollectionMethods, after.Schemas["otherCRD"].CollectionMethods)
+	_, err = collection.Schemas(testUser)
+	assert.NoError(t, err)
+
+	mockLookup.Clear()
+	mockLookup.AddAccessForUser(testUser, "get", otherCRD, "*", "*")
+	after, err = collection.Schemas(testUser)
+	assert.NoError(t, err)
+	assert.Nil(t, after.Schemas["testCRD"], "the schema no longer granted is removed")
+	assert.NotNil(t, after.Schemas["otherCRD"])
+}
+
 func runSchemaTest(t *testing.T, config schemaTestConfig, lookup *mockAccessSetLookup, collection *Collection, testUser user.Info) {
 	for _, verb := range config.permissionVerbs {
 		lookup.AddAccessForUser(testUser, verb, k8sSchema.GroupResource{Group: testGroup, Resource: "testCRD"}, "*", "*")
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceFilter selects the resources that get schemas. Each entry is a resource with its group, as in
+// deployments.apps or pods for the core group, or every resource of a group, as in *.apps or * for the core group.
+type ResourceFilter struct {
+	// Include, if set, are the only resources served.
+	Include []string
+	// Exclude are resources that are not served, even if included.
+	Exclude []string
+}
+
+// Allowed returns whether the resource is served.
+func (f ResourceFilter) Allowed(gr schema.GroupResource) bool {
+	if matched, _ := matchesAny(f.Exclude, gr); matched {
+		return false
+	}
+	matched, any := matchesAny(f.Include, gr)
+	return matched || !any
+}
+
+// matchesAny returns whether any entry matches the resource, and whether there are any entries.
+func matchesAny(entries []string, gr schema.GroupResource) (matched bool, any bool) {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		any = true
+		resource, group, _ := strings.Cut(entry, ".")
+		if group == gr.Group && (resource == "*" || resource == gr.Resource) {
+			return true, true
+		}
+	}
+	return false, any
+}
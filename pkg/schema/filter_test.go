@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8sSchema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  ResourceFilter
+		gr      k8sSchema.GroupResource
+		allowed bool
+	}{
+		{
+			name:    "no filter",
+			filter:  ResourceFilter{Include: []string{""}, Exclude: []string{""}},
+			gr:      k8sSchema.GroupResource{Resource: "pods"},
+			allowed: true,
+		},
+		{
+			name:    "excluded core resource",
+			filter:  ResourceFilter{Exclude: []string{"events"}},
+			gr:      k8sSchema.GroupResource{Resource: "events"},
+			allowed: false,
+		},
+		{
+			name:    "excluded group",
+			filter:  ResourceFilter{Exclude: []string{"*.metrics.k8s.io"}},
+			gr:      k8sSchema.GroupResource{Group: "metrics.k8s.io", Resource: "pods"},
+			allowed: false,
+		},
+		{
+			name:    "included",
+			filter:  ResourceFilter{Include: []string{"deployments.apps", "pods"}},
+			gr:      k8sSchema.GroupResource{Group: "apps", Resource: "deployments"},
+			allowed: true,
+		},
+		{
+			name:    "not included",
+			filter:  ResourceFilter{Include: []string{"deployments.apps", "pods"}},
+			gr:      k8sSchema.GroupResource{Group: "apps", Resource: "statefulsets"},
+			allowed: false,
+		},
+		{
+			name:    "included and excluded",
+			filter:  ResourceFilter{Include: []string{"*.apps"}, Exclude: []string{"daemonsets.apps"}},
+			gr:      k8sSchema.GroupResource{Group: "apps", Resource: "daemonsets"},
+			allowed: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.allowed, test.filter.Allowed(test.gr))
+		})
+	}
+}
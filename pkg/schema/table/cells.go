@@ -0,0 +1,56 @@
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Cells computes the cells of a row of the table of an object, the way the apiserver does for the printer columns
+// of a CRD: dates are shown as their age and missing values are nil. It returns nil unless every column has a
+// JSONPath.
+func Cells(columns []Column, obj map[string]interface{}) []interface{} {
+	if len(columns) == 0 {
+		return nil
+	}
+	cells := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		if column.JSONPath == "" {
+			return nil
+		}
+		cells = append(cells, cell(column, obj))
+	}
+	return cells
+}
+
+func cell(column Column, obj map[string]interface{}) interface{} {
+	path := jsonpath.New(column.Name).AllowMissingKeys(true)
+	if err := path.Parse(fmt.Sprintf("{%s}", column.JSONPath)); err != nil {
+		return nil
+	}
+	results, err := path.FindResults(obj)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return nil
+	}
+	value := results[0][0].Interface()
+
+	switch v := value.(type) {
+	case string:
+		if column.Type == "date" {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return duration.HumanDuration(time.Since(t))
+			}
+		}
+		return v
+	case bool, int, int32, int64, float32, float64, nil:
+		return v
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return string(bytes)
+}
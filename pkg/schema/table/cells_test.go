@@ -0,0 +1,51 @@
+package table
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCells(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":              "widget",
+			"creationTimestamp": time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"ports":    []interface{}{int64(80), int64(443)},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		columns []Column
+		want    []interface{}
+	}{
+		{
+			name: "printer columns",
+			columns: []Column{
+				{Name: "Name", JSONPath: ".metadata.name"},
+				{Name: "Replicas", Type: "integer", JSONPath: ".spec.replicas"},
+				{Name: "Ports", Type: "string", JSONPath: ".spec.ports"},
+				{Name: "Ready", Type: "boolean", JSONPath: ".status.ready"},
+				{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+			},
+			want: []interface{}{"widget", int64(3), "[80,443]", nil, "120m"},
+		},
+		{
+			name: "column without a path",
+			columns: []Column{
+				{Name: "Name", JSONPath: ".metadata.name"},
+				{Name: "Computed", Field: "$.metadata.fields[1]"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Cells(test.columns, obj))
+		})
+	}
+}
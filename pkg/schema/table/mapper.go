@@ -15,6 +15,9 @@ type Column struct {
 	Format      string `json:"format,omitempty"`
 	Description string `json:"description,omitempty"`
 	Priority    int    `json:"priority,omitempty"`
+	// JSONPath is the path of the value of the column in the object, for columns from the additionalPrinterColumns
+	// of a CRD, whose values are computed when the apiserver did not.
+	JSONPath string `json:"jsonPath,omitempty"`
 }
 
 type Table struct {
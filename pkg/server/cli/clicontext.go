@@ -2,10 +2,17 @@ package cli
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	steveauth "github.com/rancher/steve/pkg/auth"
 	authcli "github.com/rancher/steve/pkg/auth/cli"
+	"github.com/rancher/steve/pkg/resources/common"
+	stevekubeconfig "github.com/rancher/steve/pkg/resources/kubeconfig"
+	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/server"
+	"github.com/rancher/steve/pkg/stores/audit"
+	storeratelimit "github.com/rancher/steve/pkg/stores/ratelimit"
 	"github.com/rancher/steve/pkg/ui"
 	"github.com/rancher/wrangler/pkg/kubeconfig"
 	"github.com/rancher/wrangler/pkg/ratelimit"
@@ -13,11 +20,37 @@ import (
 )
 
 type Config struct {
-	KubeConfig      string
-	Context         string
-	HTTPSListenPort int
-	HTTPListenPort  int
-	UIPath          string
+	KubeConfig          string
+	Context             string
+	HTTPSListenPort     int
+	HTTPListenPort      int
+	UIPath              string
+	ListConcurrency     int
+	ExcludeFields       string
+	ListTimeout         time.Duration
+	SkipEmptyPartitions bool
+	AllowImpersonation  bool
+	Metrics             bool
+	Redact              bool
+	StreamOrigins       string
+	RateLimitQPS        float64
+	RateLimitBurst      int
+	RateLimitOverrides  string
+	AuditLogPath        string
+	AuditWebhookURL     string
+	AuditBodies         bool
+	AuditRedactSchemas  string
+	IncludeResources    string
+	ExcludeResources    string
+	ClusterNamespace    string
+	OIDC                steveauth.OIDCOptions
+	ClientCAFile        string
+	ClientCRLFile       string
+	Kubeconfigs         bool
+	KubeconfigKeyFile   string
+	KubeconfigMaxTTL    time.Duration
+	KubeconfigCerts     bool
+	HistoryRevisions    string
 
 	WebhookConfig authcli.WebhookConfig
 }
@@ -47,10 +80,81 @@ func (c *Config) ToServer(ctx context.Context) (*server.Server, error) {
 			return nil, err
 		}
 	}
+	if c.WebhookConfig.TokenReviewAuthentication {
+		auth, err = c.WebhookConfig.TokenReviewMiddleware(restConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var oidc *steveauth.OIDCOptions
+	if c.OIDC.IssuerURL != "" {
+		oidc = &c.OIDC
+		oidc.CacheTTL = time.Duration(c.WebhookConfig.CacheTTLSeconds) * time.Second
+	}
+
+	var clientCert *steveauth.ClientCertOptions
+	if c.ClientCAFile != "" {
+		clientCert = &steveauth.ClientCertOptions{
+			CAFile:  c.ClientCAFile,
+			CRLFile: c.ClientCRLFile,
+		}
+	}
+
+	var kubeconfigs *stevekubeconfig.Options
+	if c.Kubeconfigs {
+		kubeconfigs = &stevekubeconfig.Options{
+			SigningKeyFile: c.KubeconfigKeyFile,
+			MaxTTL:         c.KubeconfigMaxTTL,
+			Certificates:   c.KubeconfigCerts,
+		}
+	}
+
+	overrides, err := storeratelimit.ParseOverrides(c.RateLimitOverrides)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := common.ParseHistoryRevisions(c.HistoryRevisions)
+	if err != nil {
+		return nil, err
+	}
 
 	return server.New(ctx, restConfig, &server.Options{
-		AuthMiddleware: auth,
-		Next:           ui.New(c.UIPath),
+		AuthMiddleware:      auth,
+		Next:                ui.New(c.UIPath),
+		ListConcurrency:     int64(c.ListConcurrency),
+		ExcludeFields:       strings.Split(c.ExcludeFields, ","),
+		ListTimeout:         c.ListTimeout,
+		SkipEmptyPartitions: c.SkipEmptyPartitions,
+		AllowImpersonation:  c.AllowImpersonation,
+		Metrics:             c.Metrics,
+		Redact:              c.Redact,
+		StreamOrigins:       strings.Split(c.StreamOrigins, ","),
+		ClusterNamespace:    c.ClusterNamespace,
+		OIDC:                oidc,
+		ClientCert:          clientCert,
+		Kubeconfig:          kubeconfigs,
+		RateLimits: storeratelimit.Options{
+			Limit: storeratelimit.Limit{
+				QPS:   c.RateLimitQPS,
+				Burst: c.RateLimitBurst,
+			},
+			Overrides: overrides,
+		},
+		ResourceFilter: schema.ResourceFilter{
+			Include: strings.Split(c.IncludeResources, ","),
+			Exclude: strings.Split(c.ExcludeResources, ","),
+		},
+		Audit: audit.Options{
+			Path:          c.AuditLogPath,
+			WebhookURL:    c.AuditWebhookURL,
+			IncludeBodies: c.AuditBodies,
+			RedactSchemas: strings.Split(c.AuditRedactSchemas, ","),
+		},
+		History: common.HistoryOptions{
+			Revisions: revisions,
+		},
 	})
 }
 
@@ -80,6 +184,170 @@ func Flags(config *Config) []cli.Flag {
 			Value:       9080,
 			Destination: &config.HTTPListenPort,
 		},
+		cli.IntFlag{
+			Name:        "list-concurrency",
+			Usage:       "Maximum number of namespaces or other partitions listed at once for a single request",
+			Value:       3,
+			Destination: &config.ListConcurrency,
+		},
+		cli.StringFlag{
+			Name:        "exclude-fields",
+			Usage:       "Comma separated field paths to strip from listed and watched objects unless a request sets excludeFields",
+			Value:       "metadata.managedFields,metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]",
+			Destination: &config.ExcludeFields,
+		},
+		cli.DurationFlag{
+			Name:        "list-timeout",
+			Usage:       "Default time limit for a list request, after which a partial list is returned with a continue token",
+			Destination: &config.ListTimeout,
+		},
+		cli.BoolFlag{
+			Name:        "skip-empty-partitions",
+			Usage:       "Probe partitions before a list and skip the ones without any objects",
+			Destination: &config.SkipEmptyPartitions,
+		},
+		cli.BoolFlag{
+			Name:        "allow-impersonation",
+			Usage:       "Allow users with the impersonate permission to act as other users with the Impersonate-User and Impersonate-Group headers",
+			Destination: &config.AllowImpersonation,
+		},
+		cli.BoolFlag{
+			Name:        "metrics",
+			Usage:       "Serve the prometheus metrics enabled by CATTLE_PROMETHEUS_METRICS=true on /metrics to users allowed to get the /metrics URL",
+			Destination: &config.Metrics,
+		},
+		cli.BoolFlag{
+			Name:        "redact",
+			Usage:       "Mask the data of secrets for users not granted the unredacted verb on them",
+			Destination: &config.Redact,
+		},
+		cli.StringFlag{
+			Name:        "stream-origins",
+			Usage:       "Comma separated origins, besides that of steve, from which browsers may exec in and port forward to pods",
+			Destination: &config.StreamOrigins,
+		},
+		cli.Float64Flag{
+			Name:        "rate-limit-qps",
+			Usage:       "Average number of requests a second each user may make, zero for no limit",
+			Destination: &config.RateLimitQPS,
+		},
+		cli.IntFlag{
+			Name:        "rate-limit-burst",
+			Usage:       "Number of requests each user may make at once above the rate limit",
+			Destination: &config.RateLimitBurst,
+		},
+		cli.StringFlag{
+			Name:        "rate-limit-overrides",
+			Usage:       "Comma separated limits of each user for expensive schemas, as schema=qps:burst, such as pod=5:10,event=2:5",
+			Destination: &config.RateLimitOverrides,
+		},
+		cli.StringFlag{
+			Name:        "audit-log-path",
+			Usage:       "File to append an audit event for every request to as JSON lines, or - for stdout",
+			Destination: &config.AuditLogPath,
+		},
+		cli.StringFlag{
+			Name:        "audit-webhook-url",
+			Usage:       "URL to POST batches of audit events to",
+			Destination: &config.AuditWebhookURL,
+		},
+		cli.BoolFlag{
+			Name:        "audit-bodies",
+			Usage:       "Include the objects sent by creates and updates in audit events, with sensitive fields masked",
+			Destination: &config.AuditBodies,
+		},
+		cli.StringFlag{
+			Name:        "audit-redact-schemas",
+			Usage:       "Comma separated schema IDs whose bodies are never included in audit events",
+			Value:       "secret",
+			Destination: &config.AuditRedactSchemas,
+		},
+		cli.StringFlag{
+			Name:        "include-resources",
+			Usage:       "Comma separated resources to serve, and no others, as resource.group, such as pods or deployments.apps, or *.group for a whole group",
+			Destination: &config.IncludeResources,
+		},
+		cli.StringFlag{
+			Name:        "exclude-resources",
+			Usage:       "Comma separated resources not to serve, as resource.group, such as events or *.metrics.k8s.io for a whole group",
+			Destination: &config.ExcludeResources,
+		},
+		cli.StringFlag{
+			Name:        "cluster-namespace",
+			Usage:       "Namespace of the secrets registering downstream clusters, which are proxied on /k8s/clusters/<id>/",
+			Destination: &config.ClusterNamespace,
+		},
+		cli.StringFlag{
+			Name:        "oidc-issuer-url",
+			Usage:       "URL of the OpenID Connect issuer whose ID tokens authenticate users, which must be https",
+			Destination: &config.OIDC.IssuerURL,
+		},
+		cli.StringFlag{
+			Name:        "oidc-client-id",
+			Usage:       "Client ID the OpenID Connect ID tokens must be issued for",
+			Destination: &config.OIDC.ClientID,
+		},
+		cli.StringFlag{
+			Name:        "oidc-ca-file",
+			Usage:       "PEM encoded CA bundle of the OpenID Connect issuer, instead of the system roots",
+			Destination: &config.OIDC.CAFile,
+		},
+		cli.StringFlag{
+			Name:        "oidc-username-claim",
+			Usage:       "Claim of the OpenID Connect ID tokens giving the username",
+			Value:       "sub",
+			Destination: &config.OIDC.UsernameClaim,
+		},
+		cli.StringFlag{
+			Name:        "oidc-username-prefix",
+			Usage:       "Prefix of the usernames of OpenID Connect users, the issuer URL followed by # by default unless the username claim is email, or - for none",
+			Destination: &config.OIDC.UsernamePrefix,
+		},
+		cli.StringFlag{
+			Name:        "oidc-groups-claim",
+			Usage:       "Claim of the OpenID Connect ID tokens giving the groups of the user",
+			Destination: &config.OIDC.GroupsClaim,
+		},
+		cli.StringFlag{
+			Name:        "oidc-groups-prefix",
+			Usage:       "Prefix of the groups of OpenID Connect users, the issuer URL followed by # by default, or - for none",
+			Destination: &config.OIDC.GroupsPrefix,
+		},
+		cli.StringFlag{
+			Name:        "client-ca-file",
+			Usage:       "PEM encoded CA bundle signing client certificates, whose common name is the user and organizations the groups",
+			Destination: &config.ClientCAFile,
+		},
+		cli.StringFlag{
+			Name:        "client-crl-file",
+			Usage:       "Revocation list of the client CA, whose certificates are rejected, read again whenever it changes",
+			Destination: &config.ClientCRLFile,
+		},
+		cli.BoolFlag{
+			Name:        "kubeconfigs",
+			Usage:       "Serve kubeconfigs of the requesting user, with a token issued by steve",
+			Destination: &config.Kubeconfigs,
+		},
+		cli.StringFlag{
+			Name:        "kubeconfig-key-file",
+			Usage:       "Key of at least 32 bytes signing the tokens of kubeconfigs, random if unset so tokens are invalid after a restart",
+			Destination: &config.KubeconfigKeyFile,
+		},
+		cli.DurationFlag{
+			Name:        "kubeconfig-max-ttl",
+			Usage:       "Longest TTL a kubeconfig may ask for (default 24h)",
+			Destination: &config.KubeconfigMaxTTL,
+		},
+		cli.BoolFlag{
+			Name:        "kubeconfig-certificates",
+			Usage:       "Also serve kubeconfigs of client certificates signed by the cluster, which can't be revoked before they expire",
+			Destination: &config.KubeconfigCerts,
+		},
+		cli.StringFlag{
+			Name:        "history-revisions",
+			Usage:       "Comma separated number of revisions to keep of each object of schemas, as schema=count, such as apps.deployment=10,configmap=5",
+			Destination: &config.HistoryRevisions,
+		},
 	}
 
 	return append(flags, authcli.Flags(&config.WebhookConfig)...)
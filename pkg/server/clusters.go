@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/rancher/steve/pkg/clusters"
+	"github.com/rancher/steve/pkg/server/handler"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	apiPrefix        = "/v1/"
+	clusterAPIPrefix = "/v1/clusters/"
+)
+
+// clusterServers serves the API of each registered cluster on /v1/clusters/<cluster>/, from a server of its own with
+// the schemas, access control and caches of the cluster. A server is started on the first request for its cluster, and
+// stopped when the registration of the cluster changes or is removed.
+type clusterServers struct {
+	ctx      context.Context
+	registry *clusters.Registry
+	opts     Options
+	auth     auth.Middleware
+
+	lock    sync.Mutex
+	servers map[string]*clusterServer
+}
+
+type clusterServer struct {
+	once    sync.Once
+	cancel  func()
+	handler http.Handler
+	err     error
+}
+
+// newClusterServers returns the servers of the clusters of a registry, made with the options of the server of the
+// local cluster. Their requests are authenticated by the middleware of the local server, and without one are made
+// as admin. They run the admission hooks of the local server, and are audited and cached as its own requests are,
+// each cluster with the tables of its SQL cache apart.
+func newClusterServers(ctx context.Context, server *Server, authMiddleware auth.Middleware) *clusterServers {
+	opts := Options{
+		ListConcurrency:     server.ListConcurrency,
+		ExcludeFields:       server.ExcludeFields,
+		ListTimeout:         server.ListTimeout,
+		SkipEmptyPartitions: server.SkipEmptyPartitions,
+		ListTransformers:    server.ListTransformers,
+		RateLimits:          server.RateLimits,
+		Audit:               server.Audit,
+		AdmissionHooks:      server.AdmissionHooks,
+		ResourceFilter:      server.ResourceFilter,
+		SQLCache:            server.SQLCache,
+		ServerVersion:       server.Version,
+	}
+	if authMiddleware != nil {
+		opts.AuthMiddleware = auth.ExistingContext
+	}
+
+	c := &clusterServers{
+		ctx:      ctx,
+		registry: server.Clusters,
+		opts:     opts,
+		auth:     authMiddleware,
+		servers:  map[string]*clusterServer{},
+	}
+	c.registry.OnChange(c.stop)
+	return c
+}
+
+// stop stops the server of a cluster, if it has one.
+func (c *clusterServers) stop(id string) {
+	c.lock.Lock()
+	s, ok := c.servers[id]
+	delete(c.servers, id)
+	c.lock.Unlock()
+
+	if ok {
+		// waits for the server to start if it is starting
+		s.once.Do(func() {})
+		if s.cancel != nil {
+			s.cancel()
+		}
+	}
+}
+
+// server returns the server of a cluster, starting it if it isn't yet.
+func (c *clusterServers) server(id string) (http.Handler, error) {
+	c.lock.Lock()
+	s, ok := c.servers[id]
+	if !ok {
+		s = &clusterServer{}
+		c.servers[id] = s
+	}
+	c.lock.Unlock()
+
+	s.once.Do(func() {
+		cfg, err := c.registry.RESTConfig(c.ctx, id)
+		if err != nil {
+			s.err = err
+			return
+		}
+		ctx, cancel := context.WithCancel(c.ctx)
+		opts := c.opts
+		opts.Audit.Cluster = id
+		opts.SQLCache.TablePrefix = id + "_"
+		server, err := New(ctx, cfg, &opts)
+		if err != nil {
+			cancel()
+			s.err = err
+			return
+		}
+		s.cancel = cancel
+		s.handler = server
+	})
+
+	if s.err != nil {
+		// try again on the next request
+		c.lock.Lock()
+		if c.servers[id] == s {
+			delete(c.servers, id)
+		}
+		c.lock.Unlock()
+	}
+	return s.handler, s.err
+}
+
+// handler routes /v1/clusters/<cluster>/ to the servers of the registered clusters, and every other request to next.
+func (c *clusterServers) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, clusterAPIPrefix) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		id, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, clusterAPIPrefix), "/")
+		if !c.registry.Registered(id) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		server, err := c.server(id)
+		if err != nil {
+			logrus.Errorf("failed to start the server of cluster %s: %v", id, err)
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		clusterReq := req.Clone(handler.WithCluster(req.Context(), id, req.URL))
+		clusterReq.URL.Path = strings.TrimSuffix(apiPrefix+rest, "/")
+		if req.URL.RawPath != "" {
+			_, rawRest, _ := strings.Cut(strings.TrimPrefix(req.URL.RawPath, clusterAPIPrefix), "/")
+			clusterReq.URL.RawPath = strings.TrimSuffix(apiPrefix+rawRest, "/")
+		}
+		if c.auth != nil {
+			server = c.auth(server)
+		}
+		server.ServeHTTP(rw, clusterReq)
+	})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/steve/pkg/clusters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterServersHandler(t *testing.T) {
+	ctx := context.Background()
+	registry := clusters.NewRegistry(fake.NewSimpleClientset(), "clusters")
+	_, err := registry.Create(ctx, &clusters.Registration{ID: "east", Server: "https://east.example.com", Token: "token"})
+	require.NoError(t, err)
+
+	c := newClusterServers(ctx, &Server{Clusters: registry}, nil)
+	var served string
+	east := &clusterServer{handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		served = "east " + req.URL.Path
+	})}
+	east.once.Do(func() {})
+	c.servers["east"] = east
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		served = "local " + req.URL.Path
+	})
+
+	for path, want := range map[string]string{
+		"/v1/clusters/east/pods":           "east /v1/pods",
+		"/v1/clusters/east/pods/default/a": "east /v1/pods/default/a",
+		"/v1/clusters/east":                "east /v1",
+		"/v1/east/pods":                    "local /v1/east/pods",
+		"/v1/pods/default":                 "local /v1/pods/default",
+		"/api/v1/namespaces":               "local /api/v1/namespaces",
+	} {
+		c.handler(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, served, path)
+	}
+
+	// the server of a cluster is stopped when its registration changes
+	require.NoError(t, registry.Delete(ctx, "east"))
+	assert.NotContains(t, c.servers, "east")
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/rancher/wrangler/pkg/generic"
 	"github.com/rancher/wrangler/pkg/ratelimit"
 	"github.com/rancher/wrangler/pkg/start"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -26,7 +27,10 @@ type Controllers struct {
 	RBAC       rbacv1.Interface
 	API        apiregistrationv1.Interface
 	CRD        apiextensionsv1.Interface
-	starters   []start.Starter
+	// Informers are the caches of the types wrangler has no controllers for, such as resource quotas. They are
+	// started with the controllers.
+	Informers informers.SharedInformerFactory
+	starters  []start.Starter
 }
 
 func (c *Controllers) Start(ctx context.Context) error {
@@ -72,6 +76,8 @@ func NewController(cfg *rest.Config, opts *generic.FactoryOptions) (*Controllers
 	if err != nil {
 		return nil, err
 	}
+	c.Informers = informers.NewSharedInformerFactory(c.K8s, 0)
+	c.starters = append(c.starters, informerStarter{c.Informers})
 	c.Core = core.Core().V1()
 	c.RBAC = rbac.Rbac().V1()
 	c.API = api.Apiregistration().V1()
@@ -81,4 +87,21 @@ func NewController(cfg *rest.Config, opts *generic.FactoryOptions) (*Controllers
 	return c, nil
 }
 
+// informerStarter starts the informers of a client-go factory which have been asked for, as the wrangler factories
+// start their controllers.
+type informerStarter struct {
+	informers.SharedInformerFactory
+}
+
+func (i informerStarter) Sync(ctx context.Context) error {
+	i.SharedInformerFactory.Start(ctx.Done())
+	i.WaitForCacheSync(ctx.Done())
+	return nil
+}
+
+func (i informerStarter) Start(ctx context.Context, _ int) error {
+	i.SharedInformerFactory.Start(ctx.Done())
+	return nil
+}
+
 type StartHook func(context.Context, *Server) error
@@ -3,32 +3,74 @@ package handler
 import (
 	"net/http"
 
+	"github.com/rancher/apiserver/pkg/parse"
 	apiserver "github.com/rancher/apiserver/pkg/server"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/apiserver/pkg/urlbuilder"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/auth"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/rancher/steve/pkg/clusters"
+	"github.com/rancher/steve/pkg/graphql"
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/steve/pkg/openapi"
 	k8sproxy "github.com/rancher/steve/pkg/proxy"
+	"github.com/rancher/steve/pkg/rpc"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/server/router"
+	"github.com/rancher/steve/pkg/stores/audit"
+	"github.com/rancher/steve/pkg/writer"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
+// Options are the optional parts of the API server and its routes.
+type Options struct {
+	// Clusters proxies the kubernetes APIs of its clusters on /k8s/clusters/<id>/ if set.
+	Clusters *clusters.Registry
+	// AuditSink audits the API requests that no audited store sees, such as actions, as configured by Audit if set.
+	AuditSink audit.Sink
+	Audit     audit.Options
+	// Metrics serves the prometheus metrics, if they are enabled, on /metrics to the users allowed to get the
+	// /metrics non-resource URL.
+	Metrics bool
+}
+
+// New returns the API server and the handler of its routes.
 func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, next http.Handler,
-	routerFunc router.RouterFunc) (*apiserver.Server, http.Handler, error) {
+	routerFunc router.RouterFunc, opts Options) (*apiserver.Server, http.Handler, error) {
 	var (
 		proxy http.Handler
 		err   error
 	)
 
 	a := &apiServer{
-		sf:     sf,
-		server: apiserver.DefaultAPIServer(),
+		sf:           sf,
+		server:       apiserver.DefaultAPIServer(),
+		auditSink:    opts.AuditSink,
+		auditOptions: opts.Audit,
 	}
 	a.server.AccessControl = accesscontrol.NewAccessControl()
+	a.server.Parser = withErrorHandler(a.server.Parser)
+	a.server.ResponseWriters["json"] = &writer.CompressWriter{
+		ResponseWriter: &writer.ResponseWriter{
+			ContentType: "application/json",
+			Encoder:     types.JSONEncoder,
+		},
+	}
+	a.server.ResponseWriters["yaml"] = &writer.CompressWriter{
+		ResponseWriter: &writer.ResponseWriter{
+			ContentType: "application/yaml",
+			Encoder:     types.YAMLEncoder,
+		},
+	}
+	for format, responseWriter := range a.server.ResponseWriters {
+		a.server.ResponseWriters[format] = &writer.CSVWriter{ResponseWriter: responseWriter}
+	}
 
+	impersonate := authMiddleware != nil
 	if authMiddleware == nil {
 		proxy, err = k8sproxy.Handler("/", cfg)
 		if err != nil {
@@ -46,6 +88,19 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 		K8sProxy:    w(proxy),
 		APIRoot:     w(a.apiHandler(apiRoot)),
 	}
+	handlers.OpenAPI = w(openapi.Handler(a.schemas))
+	handlers.GraphQL = w(graphql.Handler(a.request))
+	handlers.GRPC = w(rpc.Handler(a.request))
+	if m := metrics.Handler(); m != nil && opts.Metrics {
+		k8s, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return a.server, nil, err
+		}
+		handlers.Metrics = w(authorizeMetrics(k8s.AuthorizationV1().SubjectAccessReviews(), m))
+	}
+	if opts.Clusters != nil {
+		handlers.Clusters = w(opts.Clusters.Handler(impersonate, next))
+	}
 	if routerFunc == nil {
 		return a.server, router.Routes(handlers), nil
 	}
@@ -53,8 +108,10 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 }
 
 type apiServer struct {
-	sf     schema.Factory
-	server *apiserver.Server
+	sf           schema.Factory
+	server       *apiserver.Server
+	auditSink    audit.Sink
+	auditOptions audit.Options
 }
 
 func (a *apiServer) common(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool) {
@@ -70,31 +127,70 @@ func (a *apiServer) common(rw http.ResponseWriter, req *http.Request) (*types.AP
 		rw.WriteHeader(http.StatusInternalServerError)
 	}
 
-	urlBuilder, err := urlbuilder.NewPrefixed(req, schemas, "v1")
+	urlReq, prefix := urlRequest(req)
+	urlBuilder, err := urlbuilder.NewPrefixed(urlReq, schemas, prefix)
 	if err != nil {
 		rw.Write([]byte(err.Error()))
 		rw.WriteHeader(http.StatusInternalServerError)
 		return nil, false
 	}
 
+	ctx := writer.WithListMeta(req.Context())
+	ctx = client.WithWarningHandler(ctx, writer.ListMetaFrom(ctx).AddWarning)
 	return &types.APIRequest{
 		Schemas:    schemas,
-		Request:    req,
+		Request:    req.WithContext(ctx),
 		Response:   rw,
 		URLBuilder: urlBuilder,
 	}, true
 }
 
+// schemas returns the schemas of the user making the request.
+func (a *apiServer) schemas(rw http.ResponseWriter, req *http.Request) (*types.APISchemas, bool) {
+	apiOp, ok := a.common(rw, req)
+	if !ok {
+		return nil, false
+	}
+	return apiOp.Schemas, true
+}
+
+// request returns the request of the user with the access control of the server, for handlers which call the
+// stores of the schemas directly.
+func (a *apiServer) request(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool) {
+	apiOp, ok := a.common(rw, req)
+	if !ok {
+		return nil, false
+	}
+	apiOp.AccessControl = a.server.AccessControl
+	return apiOp, true
+}
+
+// withErrorHandler returns a parser which sets the error handler of a request to that of its schema, or to one
+// returning the details of the kubernetes statuses that errors came from.
+func withErrorHandler(parser parse.Parser) parse.Parser {
+	return func(apiOp *types.APIRequest, urlParser parse.URLParser) error {
+		err := parser(apiOp, urlParser)
+		if apiOp.Schema != nil && apiOp.Schema.ErrorHandler != nil {
+			apiOp.ErrorHandler = apiOp.Schema.ErrorHandler
+		} else if apiOp.ErrorHandler == nil {
+			apiOp.ErrorHandler = writer.ErrorHandler
+		}
+		return err
+	}
+}
+
 type APIFunc func(schema.Factory, *types.APIRequest)
 
 func (a *apiServer) apiHandler(apiFunc APIFunc) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	return audit.Handler(a.auditSink, a.auditOptions, func(rw http.ResponseWriter, req *http.Request) *types.APIRequest {
 		apiOp, ok := a.common(rw, req)
-		if ok {
-			if apiFunc != nil {
-				apiFunc(a.sf, apiOp)
-			}
-			a.server.Handle(apiOp)
+		if !ok {
+			return nil
+		}
+		if apiFunc != nil {
+			apiFunc(a.sf, apiOp)
 		}
+		a.server.Handle(apiOp)
+		return apiOp
 	})
 }
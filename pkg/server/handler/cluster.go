@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type clusterKey struct{}
+
+type clusterRequest struct {
+	cluster string
+	url     *url.URL
+}
+
+// WithCluster returns the context of a request for the API of a downstream cluster that was made to the URL given,
+// under /v1/clusters/<cluster>, before its path was rewritten to that of the API of the cluster. The links of the
+// response are made under /v1/clusters/<cluster>.
+func WithCluster(ctx context.Context, cluster string, url *url.URL) context.Context {
+	return context.WithValue(ctx, clusterKey{}, clusterRequest{cluster: cluster, url: url})
+}
+
+// urlRequest returns the request and prefix to make the links of a response from.
+func urlRequest(req *http.Request) (*http.Request, string) {
+	c, ok := req.Context().Value(clusterKey{}).(clusterRequest)
+	if !ok {
+		return req, "v1"
+	}
+	original := req.Clone(req.Context())
+	original.URL = c.url
+	return original, "v1/clusters/" + c.cluster
+}
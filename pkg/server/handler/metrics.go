@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+const metricsPath = "/metrics"
+
+// authorizeMetrics serves the metrics to the users allowed to get the /metrics non-resource URL, as the kubernetes
+// apiserver authorizes its own metrics, and refuses everyone else with 403.
+func authorizeMetrics(sar authorizationv1client.SubjectAccessReviewInterface, metrics http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		user, ok := request.UserFrom(req.Context())
+		if !ok {
+			http.Error(rw, "not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		review, err := sar.Create(req.Context(), &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+					Path: metricsPath,
+					Verb: "get",
+				},
+				User:   user.GetName(),
+				Groups: user.GetGroups(),
+				Extra:  extraValues(user.GetExtra()),
+				UID:    user.GetUID(),
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			logrus.Errorf("failed to authorize metrics request of user %s: %v", user.GetName(), err)
+			http.Error(rw, "failed to authorize request", http.StatusInternalServerError)
+			return
+		}
+		if !review.Status.Allowed {
+			http.Error(rw, "forbidden", http.StatusForbidden)
+			return
+		}
+		metrics.ServeHTTP(rw, req)
+	})
+}
+
+func extraValues(extra map[string][]string) map[string]authorizationv1.ExtraValue {
+	if len(extra) == 0 {
+		return nil
+	}
+	result := make(map[string]authorizationv1.ExtraValue, len(extra))
+	for k, v := range extra {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAuthorizeMetrics(t *testing.T) {
+	k8s := fake.NewSimpleClientset()
+	k8s.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.NonResourceAttributes
+		review.Status.Allowed = review.Spec.User == "prometheus" && attributes.Path == "/metrics" && attributes.Verb == "get"
+		return true, review, nil
+	})
+	handler := authorizeMetrics(k8s.AuthorizationV1().SubjectAccessReviews(), http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("metrics"))
+	}))
+
+	tests := []struct {
+		name   string
+		user   user.Info
+		status int
+	}{
+		{
+			name:   "allowed",
+			user:   &user.DefaultInfo{Name: "prometheus", Groups: []string{user.AllAuthenticated}},
+			status: http.StatusOK,
+		},
+		{
+			name:   "denied",
+			user:   &user.DefaultInfo{Name: "jane", Groups: []string{user.AllAuthenticated}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unauthenticated",
+			user:   &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no user",
+			status: http.StatusUnauthorized,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if test.user != nil {
+				req = req.WithContext(request.WithUser(req.Context(), test.user))
+			}
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			assert.Equal(t, test.status, rw.Code)
+			if test.status == http.StatusOK {
+				assert.Equal(t, "metrics", rw.Body.String())
+			}
+		})
+	}
+}
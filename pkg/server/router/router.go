@@ -2,6 +2,7 @@ package router
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/rancher/apiserver/pkg/urlbuilder"
@@ -14,6 +15,21 @@ type Handlers struct {
 	APIRoot     http.Handler
 	K8sProxy    http.Handler
 	Next        http.Handler
+	// OpenAPI serves the OpenAPI v3 document of the schemas of the user on /v1/openapi/v3 if set.
+	OpenAPI http.Handler
+	// GRPC serves the gRPC API of pkg/rpc to HTTP/2 requests with a gRPC content type if set.
+	GRPC http.Handler
+	// GraphQL serves queries in a subset of GraphQL over the schemas of the user on /v1/graphql if set.
+	GraphQL http.Handler
+	// Metrics serves prometheus metrics on /metrics if set, ahead of Next.
+	Metrics http.Handler
+	// Clusters proxies the kubernetes APIs of the registered downstream clusters on /k8s/clusters/<id>/ if set.
+	Clusters http.Handler
+}
+
+// isGRPC returns whether a request is a gRPC call, which is only carried over HTTP/2.
+func isGRPC(req *http.Request, _ *mux.RouteMatch) bool {
+	return req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc")
 }
 
 func Routes(h Handlers) http.Handler {
@@ -22,9 +38,19 @@ func Routes(h Handlers) http.Handler {
 	m.StrictSlash(true)
 	m.Use(urlbuilder.RedirectRewrite)
 
+	if h.GRPC != nil {
+		m.MatcherFunc(isGRPC).Handler(h.GRPC)
+	}
+
 	m.Path("/").Handler(h.APIRoot).HeadersRegexp("Accept", ".*json.*")
 	m.Path("/{name:v1}").Handler(h.APIRoot)
 
+	if h.OpenAPI != nil {
+		m.Path("/v1/openapi/v3").Handler(h.OpenAPI)
+	}
+	if h.GraphQL != nil {
+		m.Path("/v1/graphql").Handler(h.GraphQL)
+	}
 	m.Path("/v1/{type}").Handler(h.K8sResource)
 	m.Path("/v1/{type}/{nameorns}").Queries("link", "{link}").Handler(h.K8sResource)
 	m.Path("/v1/{type}/{nameorns}").Queries("action", "{action}").Handler(h.K8sResource)
@@ -38,6 +64,12 @@ func Routes(h Handlers) http.Handler {
 	m.PathPrefix("/apis").Handler(h.K8sProxy)
 	m.PathPrefix("/openapi").Handler(h.K8sProxy)
 	m.PathPrefix("/version").Handler(h.K8sProxy)
+	if h.Metrics != nil {
+		m.Path("/metrics").Handler(h.Metrics)
+	}
+	if h.Clusters != nil {
+		m.PathPrefix("/k8s/clusters/").Handler(h.Clusters)
+	}
 	m.NotFoundHandler = h.Next
 
 	return m
@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net/http"
+	"time"
 
 	apiserver "github.com/rancher/apiserver/pkg/server"
 	"github.com/rancher/apiserver/pkg/types"
@@ -13,13 +15,23 @@ import (
 	"github.com/rancher/steve/pkg/auth"
 	"github.com/rancher/steve/pkg/client"
 	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/steve/pkg/clusters"
 	schemacontroller "github.com/rancher/steve/pkg/controllers/schema"
 	"github.com/rancher/steve/pkg/resources"
+	"github.com/rancher/steve/pkg/resources/actions"
 	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/resources/kubeconfig"
 	"github.com/rancher/steve/pkg/resources/schemas"
+	"github.com/rancher/steve/pkg/resources/virtual"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/server/handler"
 	"github.com/rancher/steve/pkg/server/router"
+	"github.com/rancher/steve/pkg/stores/admission"
+	"github.com/rancher/steve/pkg/stores/apiservice"
+	"github.com/rancher/steve/pkg/stores/audit"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"github.com/rancher/steve/pkg/stores/ratelimit"
+	"github.com/rancher/steve/pkg/stores/sqlcache"
 	"github.com/rancher/steve/pkg/summarycache"
 	"k8s.io/client-go/rest"
 )
@@ -29,17 +41,40 @@ var ErrConfigRequired = errors.New("rest config is required")
 type Server struct {
 	http.Handler
 
-	ClientFactory   *client.Factory
-	ClusterCache    clustercache.ClusterCache
-	SchemaFactory   schema.Factory
-	RESTConfig      *rest.Config
-	BaseSchemas     *types.APISchemas
-	AccessSetLookup accesscontrol.AccessSetLookup
-	APIServer       *apiserver.Server
-	ClusterRegistry string
-	Version         string
+	ClientFactory       *client.Factory
+	ClusterCache        clustercache.ClusterCache
+	SchemaFactory       schema.Factory
+	RESTConfig          *rest.Config
+	BaseSchemas         *types.APISchemas
+	AccessSetLookup     accesscontrol.AccessSetLookup
+	APIServer           *apiserver.Server
+	ClusterRegistry     string
+	Version             string
+	ListConcurrency     int64
+	ExcludeFields       []string
+	ListTimeout         time.Duration
+	SkipEmptyPartitions bool
+	ListTransformers    []partition.ListTransformer
+	AllowImpersonation  bool
+	RateLimits          ratelimit.Options
+	Audit               audit.Options
+	AdmissionHooks      *admission.Hooks
+	Actions             *actions.Registry
+	VirtualSchemas      *virtual.Registry
+	ResourceFilter      schema.ResourceFilter
+	SQLCache            sqlcache.Options
+	History             common.HistoryOptions
+	ClusterNamespace    string
+	Clusters            *clusters.Registry
+	GroupProvider       auth.GroupProvider
+	Metrics             bool
+	Redact              bool
+	StreamOrigins       []string
 
 	authMiddleware      auth.Middleware
+	clientCerts         *auth.ClientCertAuthenticator
+	kubeconfig          *kubeconfig.Options
+	tokenIssuer         *auth.TokenIssuer
 	controllers         *Controllers
 	needControllerStart bool
 	next                http.Handler
@@ -61,6 +96,78 @@ type Options struct {
 	AggregationSecretName      string
 	ClusterRegistry            string
 	ServerVersion              string
+	// ListConcurrency is the maximum number of partitions, such as namespaces, listed at once for a single request.
+	// Zero uses the default of 3.
+	ListConcurrency int64
+	// ExcludeFields are the field paths stripped from listed and watched objects by default, such as
+	// metadata.managedFields. Clients may override them with the excludeFields query parameter.
+	ExcludeFields []string
+	// ListTimeout is the default time limit for a list request, after which the objects listed so far are
+	// returned with a continue token. Zero means no limit. Clients may override it with the timeout query parameter.
+	ListTimeout time.Duration
+	// SkipEmptyPartitions probes partitions before a list and skips the ones without any objects.
+	SkipEmptyPartitions bool
+	// ListTransformers post-process every listed and watched object, for example to redact values.
+	ListTransformers []partition.ListTransformer
+	// AllowImpersonation lets users with the impersonate permission act as other users with the Impersonate-User
+	// and Impersonate-Group headers. It requires an AuthMiddleware, since without one every request is made as admin.
+	AllowImpersonation bool
+	// RateLimits are the token bucket limits of the requests of each user, with overrides for expensive schemas.
+	// Requests over the limit get a 429 response. No limits are applied by default.
+	RateLimits ratelimit.Options
+	// Audit configures the audit log of every store operation and action, as JSON lines written to a file or batches
+	// sent to a webhook. Nothing is audited by default.
+	Audit audit.Options
+	// AdmissionHooks are the mutations and validations run on objects before they are created or updated. Hooks
+	// may also be added to Server.AdmissionHooks after the server is created.
+	AdmissionHooks *admission.Hooks
+	// Actions are the custom actions on the objects of schemas, such as ?action=redeploy, in addition to the
+	// built-in redeploy of workloads and drain of nodes. Actions may also be added to Server.Actions after the
+	// server is created.
+	Actions *actions.Registry
+	// VirtualSchemas are the schemas whose objects are computed by the embedder rather than stored in kubernetes.
+	// Schemas must be added before the server is created.
+	VirtualSchemas *virtual.Registry
+	// ResourceFilter selects the resources that get schemas, so an embedder can serve and watch only the types it
+	// needs. Every resource is served by default.
+	ResourceFilter schema.ResourceFilter
+	// SQLCache mirrors the objects of the chosen schemas into a SQLite database, opened by the embedder with the
+	// driver of its choice, and serves their lists from it. Nothing is cached by default.
+	SQLCache sqlcache.Options
+	// History keeps the last revisions of the objects of the chosen schemas from their watch events, served by the
+	// revisions link of each object and compared by its diff action. No revisions are kept by default.
+	History common.HistoryOptions
+	// ClusterNamespace is the namespace of the secrets registering downstream clusters, which are managed as
+	// remoteclusters. The kubernetes API of each cluster is proxied on /k8s/clusters/<id>/ and its steve API is
+	// served on /v1/clusters/<id>/ with its own schemas and access control. Clusters can't be registered unless it is set.
+	ClusterNamespace string
+	// OIDC authenticates requests bearing the ID tokens of an OpenID Connect issuer, so users of the issuer may use
+	// steve without an authenticating proxy in front of it. It can't be combined with an AuthMiddleware.
+	OIDC *auth.OIDCOptions
+	// ClientCert authenticates the connections of ListenAndServe that present a client certificate signed by a CA
+	// bundle, as the user of its common name in the groups of its organizations. Other requests are authenticated
+	// by the AuthMiddleware or OIDC, and are unauthenticated without either.
+	ClientCert *auth.ClientCertOptions
+	// GroupProvider adds the groups of an external source, such as an identity provider sync, to the groups users
+	// authenticated with, on every request. The groups are included in the access of users and impersonated in the
+	// requests made to kubernetes on their behalf. It requires authentication of requests.
+	GroupProvider auth.GroupProvider
+	// Metrics serves the prometheus metrics enabled by CATTLE_PROMETHEUS_METRICS=true on /metrics, to the users
+	// allowed to get the /metrics non-resource URL by the cluster. The metrics aren't served by default, so the
+	// /metrics of Next isn't shadowed.
+	Metrics bool
+	// Redact masks the data of secrets, and the sensitive fields of other schemas, in the objects returned to users
+	// not granted the unredacted verb on them. Masked values sent back in an update are kept as they were. No default
+	// role grants the verb, so it must be bound to the users that may see the values before this is enabled.
+	Redact bool
+	// StreamOrigins are the origins, such as https://dashboard.example.com, from which browsers may open the exec
+	// and port forwarding websockets of pods, besides the origin of steve itself.
+	StreamOrigins []string
+	// Kubeconfig serves POST /v1/kubeconfigs, which generates kubeconfigs with the credentials of the user of the
+	// request: a token issued by steve for the kubernetes API it proxies, or a client certificate signed by the
+	// cluster if allowed. Requests bearing an issued token are authenticated by it, but may not generate other
+	// kubeconfigs, and all other requests by the AuthMiddleware, OIDC or ClientCert, one of which is required.
+	Kubeconfig *kubeconfig.Options
 }
 
 func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server, error) {
@@ -68,11 +175,50 @@ func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server,
 		opts = &Options{}
 	}
 
+	authMiddleware := opts.AuthMiddleware
+	if opts.OIDC != nil {
+		if authMiddleware != nil {
+			return nil, errors.New("oidc authentication can not be combined with an auth middleware")
+		}
+		var err error
+		authMiddleware, err = auth.NewOIDCMiddleware(*opts.OIDC)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var tokenIssuer *auth.TokenIssuer
+	if opts.Kubeconfig != nil {
+		if authMiddleware == nil && opts.ClientCert == nil {
+			return nil, errors.New("kubeconfigs can not be generated without authentication")
+		}
+		var err error
+		tokenIssuer, err = auth.NewTokenIssuer(opts.Kubeconfig.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		authMiddleware = auth.IssuedTokenMiddleware(tokenIssuer, authMiddleware)
+	}
+	var clientCerts *auth.ClientCertAuthenticator
+	if opts.ClientCert != nil {
+		var err error
+		clientCerts, err = auth.NewClientCertAuthenticator(*opts.ClientCert)
+		if err != nil {
+			return nil, err
+		}
+		authMiddleware = auth.ClientCertMiddleware(clientCerts, authMiddleware)
+	}
+	if opts.GroupProvider != nil && authMiddleware == nil {
+		return nil, errors.New("group providers can not be used without authentication")
+	}
+
 	server := &Server{
 		RESTConfig:                 restConfig,
 		ClientFactory:              opts.ClientFactory,
 		AccessSetLookup:            opts.AccessSetLookup,
-		authMiddleware:             opts.AuthMiddleware,
+		authMiddleware:             authMiddleware,
+		clientCerts:                clientCerts,
+		kubeconfig:                 opts.Kubeconfig,
+		tokenIssuer:                tokenIssuer,
 		controllers:                opts.Controllers,
 		next:                       opts.Next,
 		router:                     opts.Router,
@@ -80,6 +226,25 @@ func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server,
 		aggregationSecretName:      opts.AggregationSecretName,
 		ClusterRegistry:            opts.ClusterRegistry,
 		Version:                    opts.ServerVersion,
+		ListConcurrency:            opts.ListConcurrency,
+		ExcludeFields:              opts.ExcludeFields,
+		ListTimeout:                opts.ListTimeout,
+		SkipEmptyPartitions:        opts.SkipEmptyPartitions,
+		ListTransformers:           opts.ListTransformers,
+		AllowImpersonation:         opts.AllowImpersonation,
+		RateLimits:                 opts.RateLimits,
+		Audit:                      opts.Audit,
+		AdmissionHooks:             opts.AdmissionHooks,
+		Actions:                    opts.Actions,
+		VirtualSchemas:             opts.VirtualSchemas,
+		ResourceFilter:             opts.ResourceFilter,
+		SQLCache:                   opts.SQLCache,
+		History:                    opts.History,
+		ClusterNamespace:           opts.ClusterNamespace,
+		GroupProvider:              opts.GroupProvider,
+		Metrics:                    opts.Metrics,
+		Redact:                     opts.Redact,
+		StreamOrigins:              opts.StreamOrigins,
 	}
 
 	if err := setup(ctx, server); err != nil {
@@ -107,6 +272,18 @@ func setDefaults(server *Server) error {
 		server.next = http.NotFoundHandler()
 	}
 
+	if server.AdmissionHooks == nil {
+		server.AdmissionHooks = admission.NewHooks()
+	}
+
+	if server.Actions == nil {
+		server.Actions = actions.NewRegistry()
+	}
+
+	if server.VirtualSchemas == nil {
+		server.VirtualSchemas = virtual.NewRegistry()
+	}
+
 	if server.BaseSchemas == nil {
 		server.BaseSchemas = types.EmptyAPISchemas()
 	}
@@ -141,11 +318,65 @@ func setup(ctx context.Context, server *Server) error {
 	if err = resources.DefaultSchemas(ctx, server.BaseSchemas, ccache, server.ClientFactory, sf, server.Version); err != nil {
 		return err
 	}
+	server.VirtualSchemas.Register(server.BaseSchemas, asl)
+
+	if server.kubeconfig != nil {
+		admin, err := cf.AdminK8sInterface()
+		if err != nil {
+			return err
+		}
+		kubeconfig.Register(server.BaseSchemas, *server.kubeconfig, server.tokenIssuer, admin, server.RESTConfig)
+	}
+
+	if server.ClusterNamespace != "" {
+		server.Clusters = clusters.NewRegistry(server.controllers.K8s, server.ClusterNamespace)
+		server.Clusters.Start(ctx)
+		clusters.Register(server.BaseSchemas, server.Clusters)
+	}
 
 	summaryCache := summarycache.New(sf, ccache)
 	summaryCache.Start(ctx)
+	var history *common.History
+	if server.History.Enabled() {
+		history = common.NewHistory(ctx, ccache, server.History)
+	}
 
-	for _, template := range resources.DefaultSchemaTemplates(cf, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery()) {
+	auditSink, err := audit.NewSink(ctx, server.Audit)
+	if err != nil {
+		return err
+	}
+
+	var sqlCache *sqlcache.Cache
+	if server.SQLCache.Enabled() {
+		sqlCache = sqlcache.New(ctx, cf.AdminDynamicClient(), server.SQLCache)
+	}
+
+	availability := apiservice.NewAvailability()
+	availability.Start(ctx, server.controllers.K8s.Discovery().RESTClient())
+
+	for _, template := range resources.DefaultSchemaTemplates(cf, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), resources.TemplateOptions{
+		TemplateOptions: common.TemplateOptions{
+			Store: partition.Options{
+				Concurrency:         server.ListConcurrency,
+				ExcludeFields:       server.ExcludeFields,
+				Timeout:             server.ListTimeout,
+				SkipEmptyPartitions: server.SkipEmptyPartitions,
+				Transformers:        server.ListTransformers,
+			},
+			RateLimits:     server.RateLimits,
+			AuditSink:      auditSink,
+			Audit:          server.Audit,
+			AdmissionHooks: server.AdmissionHooks,
+			SQLCache:       sqlCache,
+			Redact:         server.Redact,
+			Availability:   availability,
+			Events:         server.controllers.Core.Event().Cache(),
+			History:        history,
+		},
+		Actions:       server.Actions,
+		Informers:     server.controllers.Informers,
+		StreamOrigins: server.StreamOrigins,
+	}) {
 		sf.AddTemplate(template)
 	}
 
@@ -161,15 +392,34 @@ func setup(ctx context.Context, server *Server) error {
 		server.controllers.K8s.Discovery(),
 		server.controllers.CRD.CustomResourceDefinition(),
 		server.controllers.API.APIService(),
+		availability,
 		server.controllers.K8s.AuthorizationV1().SelfSubjectAccessReviews(),
 		ccache,
-		sf)
+		sf,
+		server.ResourceFilter)
+
+	authMiddleware := server.authMiddleware
+	if authMiddleware != nil && server.GroupProvider != nil {
+		authMiddleware = authMiddleware.Chain(auth.GroupProviderMiddleware(server.GroupProvider))
+	}
+	if authMiddleware != nil && server.AllowImpersonation {
+		authMiddleware = authMiddleware.Chain(auth.ImpersonationMiddleware(asl))
+	}
 
-	apiServer, handler, err := handler.New(server.RESTConfig, sf, server.authMiddleware, server.next, server.router)
+	apiServer, handler, err := handler.New(server.RESTConfig, sf, authMiddleware, server.next, server.router, handler.Options{
+		Clusters:  server.Clusters,
+		AuditSink: auditSink,
+		Audit:     server.Audit,
+		Metrics:   server.Metrics,
+	})
 	if err != nil {
 		return err
 	}
 
+	if server.Clusters != nil {
+		handler = newClusterServers(ctx, server, authMiddleware).handler(handler)
+	}
+
 	server.APIServer = apiServer
 	server.Handler = handler
 	server.SchemaFactory = sf
@@ -197,6 +447,12 @@ func (c *Server) ListenAndServe(ctx context.Context, httpsPort, httpPort int, op
 	if opts.Storage == nil && opts.Secrets == nil {
 		opts.Secrets = c.controllers.Core.Secret()
 	}
+	if c.clientCerts != nil {
+		if opts.TLSListenerConfig.TLSConfig == nil {
+			opts.TLSListenerConfig.TLSConfig = &tls.Config{}
+		}
+		c.clientCerts.ConfigureTLS(opts.TLSListenerConfig.TLSConfig)
+	}
 
 	c.StartAggregation(ctx)
 
@@ -0,0 +1,225 @@
+// Package admission runs hooks registered in Go on the objects created and updated through a store, so steve can
+// enforce its own policies before anything is sent to kubernetes, without admission webhooks in the cluster.
+//
+// For example, to forbid hostPath volumes in pods:
+//
+//	hooks.AddValidator("pod", func(apiOp *types.APIRequest, obj data.Object) error {
+//		for _, volume := range obj.Slice("spec", "volumes") {
+//			if volume.Map("hostPath") != nil {
+//				return fmt.Errorf("hostPath volume %s is not allowed", volume.String("name"))
+//			}
+//		}
+//		return nil
+//	})
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/data"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// maxPatchSize matches the limit of the proxy store on the body of a patch.
+const maxPatchSize = 2 << 20
+
+// MutateFunc changes an object before it is created or updated. The method of apiOp tells the two apart.
+// Patches are not mutated, since they carry only the changed fields.
+type MutateFunc func(apiOp *types.APIRequest, obj data.Object) error
+
+// ValidateFunc rejects an object that is about to be created or updated by returning an error, after every
+// mutation. Patches are validated against the patched object, from a dry run of the patch.
+// Errors that are not API errors are returned as 403 PermissionDenied.
+type ValidateFunc func(apiOp *types.APIRequest, obj data.Object) error
+
+// Hooks is a registry of the mutations and validations of each schema. Hooks may be added at any time and apply
+// to the requests that follow.
+type Hooks struct {
+	lock       sync.RWMutex
+	mutators   map[string][]MutateFunc
+	validators map[string][]ValidateFunc
+}
+
+// NewHooks returns an empty registry.
+func NewHooks() *Hooks {
+	return &Hooks{
+		mutators:   map[string][]MutateFunc{},
+		validators: map[string][]ValidateFunc{},
+	}
+}
+
+// AddMutator registers a mutation of the objects of the schema. Mutations run in the order they are added.
+func (h *Hooks) AddMutator(schemaID string, mutator MutateFunc) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.mutators[schemaID] = append(h.mutators[schemaID], mutator)
+}
+
+// AddValidator registers a validation of the objects of the schema.
+func (h *Hooks) AddValidator(schemaID string, validator ValidateFunc) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.validators[schemaID] = append(h.validators[schemaID], validator)
+}
+
+func (h *Hooks) hooks(schema *types.APISchema) ([]MutateFunc, []ValidateFunc) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.mutators[schema.ID], h.validators[schema.ID]
+}
+
+// admit runs the hooks of the schema on the object, returning the mutated object.
+func (h *Hooks) admit(apiOp *types.APIRequest, schema *types.APISchema, obj types.APIObject) (types.APIObject, error) {
+	mutators, validators := h.hooks(schema)
+	if len(mutators) == 0 && len(validators) == 0 {
+		return obj, nil
+	}
+
+	d := obj.Data()
+	for _, mutate := range mutators {
+		if err := mutate(apiOp, d); err != nil {
+			return obj, denied(err)
+		}
+	}
+	if err := validate(apiOp, validators, d); err != nil {
+		return obj, err
+	}
+	obj.Object = map[string]interface{}(d)
+	return obj, nil
+}
+
+func validate(apiOp *types.APIRequest, validators []ValidateFunc, obj data.Object) error {
+	for _, validate := range validators {
+		if err := validate(apiOp, obj); err != nil {
+			return denied(err)
+		}
+	}
+	return nil
+}
+
+func denied(err error) error {
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	return apierror.NewAPIError(validation.PermissionDenied, "admission denied: "+err.Error())
+}
+
+// Store runs the hooks of the schema on every create and update before passing the object to the wrapped store.
+type Store struct {
+	types.Store
+	hooks *Hooks
+}
+
+// NewAdmissionStore returns a Store which runs hooks on the objects created and updated in store.
+func NewAdmissionStore(store types.Store, hooks *Hooks) *Store {
+	return &Store{
+		Store: store,
+		hooks: hooks,
+	}
+}
+
+// Create creates a single object in the store.
+func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	data, err := s.hooks.admit(apiOp, schema, data)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Create(apiOp, schema, data)
+}
+
+// Update updates a single object in the store.
+func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	if apiOp.Method == http.MethodPatch {
+		if err := s.validatePatch(apiOp, schema, data, id); err != nil {
+			return types.APIObject{}, err
+		}
+		return s.Store.Update(apiOp, schema, data, id)
+	}
+	data, err := s.hooks.admit(apiOp, schema, data)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Update(apiOp, schema, data, id)
+}
+
+// validatePatch validates the object a patch results in, by sending the patch as a dry run first. The body of the
+// request is restored for the patch itself, pinned to the resource version of the dry run, so that the patch fails
+// with a conflict rather than applying to an object other than the one validated.
+func (s *Store) validatePatch(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) error {
+	_, validators := s.hooks.hooks(schema)
+	if len(validators) == 0 {
+		return nil
+	}
+
+	body, err := writer.ReadBody(apiOp, maxPatchSize)
+	if err != nil {
+		return err
+	}
+
+	dryRun := apiOp.Clone()
+	dryRun.Request = apiOp.Request.Clone(apiOp.Context())
+	query := dryRun.Request.URL.Query()
+	query.Set("dryRun", metav1.DryRunAll)
+	dryRun.Request.URL.RawQuery = query.Encode()
+	dryRun.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	patched, err := s.Store.Update(dryRun, schema, data, id)
+	if err != nil {
+		return err
+	}
+	if err := validate(apiOp, validators, patched.Data()); err != nil {
+		return err
+	}
+
+	body, err = pin(body, apiOp.Request.Header.Get("Content-Type"), patched.Data().String("metadata", "resourceVersion"))
+	if err != nil {
+		return apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	apiOp.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// pin returns the patch with a precondition on the resource version of the object: a test operation in front of a
+// JSON patch, or the resource version in the metadata of any other patch.
+func pin(body []byte, contentType, resourceVersion string) ([]byte, error) {
+	if resourceVersion == "" {
+		return body, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); apitypes.PatchType(mediaType) == apitypes.JSONPatchType {
+		var ops []interface{}
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, fmt.Errorf("invalid json patch: %w", err)
+		}
+		test := map[string]interface{}{"op": "test", "path": "/metadata/resourceVersion", "value": resourceVersion}
+		return json.Marshal(append([]interface{}{test}, ops...))
+	}
+
+	// YAML is only sent with apply patches, but every other patch is JSON, which is YAML too
+	patch, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &obj); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	if err := unstructured.SetNestedField(obj, resourceVersion, "metadata", "resourceVersion"); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	return json.Marshal(obj)
+}
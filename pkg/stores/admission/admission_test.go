@@ -0,0 +1,192 @@
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/data"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	empty.Store
+}
+
+func (testStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	return data, nil
+}
+
+// patchStore returns the object of its dry runs, and keeps the body of the other patches.
+type patchStore struct {
+	empty.Store
+	obj     map[string]interface{}
+	patched []byte
+}
+
+func (p *patchStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	if apiOp.Request.URL.Query().Get("dryRun") == "" {
+		p.patched, _ = ioutil.ReadAll(apiOp.Request.Body)
+	}
+	return types.APIObject{Object: p.obj}, nil
+}
+
+func TestValidatePatch(t *testing.T) {
+	hooks := NewHooks()
+	hooks.AddValidator("pod", func(apiOp *types.APIRequest, obj data.Object) error {
+		if obj.String("spec", "nodeName") == "forbidden" {
+			return fmt.Errorf("node is not allowed")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		nodeName    string
+		wantPatch   string
+		wantCode    validation.ErrorCode
+	}{
+		{
+			name:        "merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"spec":{"nodeName":"allowed"}}`,
+			nodeName:    "allowed",
+			wantPatch:   `{"metadata":{"resourceVersion":"42"},"spec":{"nodeName":"allowed"}}`,
+		},
+		{
+			name:        "json patch",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"replace","path":"/spec/nodeName","value":"allowed"}]`,
+			nodeName:    "allowed",
+			wantPatch:   `[{"op":"test","path":"/metadata/resourceVersion","value":"42"},{"op":"replace","path":"/spec/nodeName","value":"allowed"}]`,
+		},
+		{
+			name:        "apply patch",
+			contentType: "application/apply-patch+yaml",
+			body:        "spec:\n  nodeName: allowed\n",
+			nodeName:    "allowed",
+			wantPatch:   `{"metadata":{"resourceVersion":"42"},"spec":{"nodeName":"allowed"}}`,
+		},
+		{
+			name:        "rejected",
+			contentType: "application/merge-patch+json",
+			body:        `{"spec":{"nodeName":"forbidden"}}`,
+			nodeName:    "forbidden",
+			wantCode:    validation.PermissionDenied,
+		},
+		{
+			name:        "too large",
+			contentType: "application/merge-patch+json",
+			body:        `{"spec":{"nodeName":"` + strings.Repeat("a", maxPatchSize) + `"}}`,
+			wantCode:    writer.RequestTooLarge,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			next := &patchStore{obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"resourceVersion": "42"},
+				"spec":     map[string]interface{}{"nodeName": test.nodeName},
+			}}
+			store := NewAdmissionStore(next, hooks)
+			req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			apiOp := &types.APIRequest{
+				Method:   http.MethodPatch,
+				Request:  req,
+				Response: httptest.NewRecorder(),
+			}
+			schema := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+
+			_, err := store.Update(apiOp, schema, types.APIObject{}, "web")
+			if test.wantCode.Code != "" {
+				var apiErr *apierror.APIError
+				require.True(t, errors.As(err, &apiErr))
+				assert.Equal(t, test.wantCode, apiErr.Code)
+				assert.Nil(t, next.patched, "the patch is not sent")
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, test.wantPatch, string(next.patched), "the patch is pinned to the resource version of the dry run")
+		})
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	hooks := NewHooks()
+	hooks.AddMutator("pod", func(apiOp *types.APIRequest, obj data.Object) error {
+		data.PutValue(obj, "steve", "metadata", "labels", "managed-by")
+		return nil
+	})
+	hooks.AddValidator("pod", func(apiOp *types.APIRequest, obj data.Object) error {
+		for _, volume := range obj.Slice("spec", "volumes") {
+			if volume.Map("hostPath") != nil {
+				return fmt.Errorf("hostPath volume %s is not allowed", volume.String("name"))
+			}
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name       string
+		schema     string
+		obj        map[string]interface{}
+		wantLabels data.Object
+		wantStatus int
+	}{
+		{
+			name:       "mutated",
+			schema:     "pod",
+			obj:        map[string]interface{}{"spec": map[string]interface{}{}},
+			wantLabels: data.Object{"managed-by": "steve"},
+		},
+		{
+			name:   "rejected",
+			schema: "pod",
+			obj: map[string]interface{}{"spec": map[string]interface{}{
+				"volumes": []interface{}{map[string]interface{}{"name": "root", "hostPath": map[string]interface{}{"path": "/"}}},
+			}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "other schema",
+			schema: "deployment",
+			obj:    map[string]interface{}{"spec": map[string]interface{}{}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewAdmissionStore(&testStore{}, hooks)
+			apiOp := &types.APIRequest{
+				Method:  http.MethodPost,
+				Request: httptest.NewRequest(http.MethodPost, "/", nil),
+			}
+			schema := &types.APISchema{Schema: &schemas.Schema{ID: test.schema}}
+
+			obj, err := store.Create(apiOp, schema, types.APIObject{Object: test.obj})
+			if test.wantStatus != 0 {
+				var apiErr *apierror.APIError
+				if assert.True(t, errors.As(err, &apiErr)) {
+					assert.Equal(t, test.wantStatus, apiErr.Code.Status)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			d := obj.Data()
+			assert.Equal(t, test.wantLabels, d.Map("metadata", "labels"))
+		})
+	}
+}
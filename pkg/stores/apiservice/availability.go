@@ -0,0 +1,213 @@
+// Package apiservice tracks the availability of the aggregated APIServices that serve resources through custom API
+// servers, such as metrics.k8s.io, and rejects the requests for the resources of an unavailable APIService at once,
+// instead of leaving them to wait for a backend that is down until they time out.
+package apiservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	apiv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+const (
+	checkIntervalEnv     = "CATTLE_APISERVICE_CHECK_SECONDS"
+	defaultCheckInterval = 30 * time.Second
+	checkTimeout         = 5 * time.Second
+)
+
+var serviceUnavailable = validation.ErrorCode{Code: "ServiceUnavailable", Status: http.StatusServiceUnavailable}
+
+// Status is the availability of an aggregated APIService.
+type Status struct {
+	// Name is the name of the APIService, such as v1beta1.metrics.k8s.io.
+	Name      string
+	Available bool
+	// Message is why the APIService is unavailable.
+	Message string
+}
+
+type service struct {
+	name      string
+	available bool
+	message   string
+	// checkErr is the error of the last check of the discovery of the group version, if it failed.
+	checkErr error
+}
+
+func (s *service) status() Status {
+	status := Status{
+		Name:      s.name,
+		Available: s.available && s.checkErr == nil,
+		Message:   s.message,
+	}
+	if s.available && s.checkErr != nil {
+		status.Message = s.checkErr.Error()
+	}
+	return status
+}
+
+// Availability is the availability of the aggregated APIServices, by the group version they serve. An APIService is
+// available if the aggregator reports it available and steve can read the discovery of its group version.
+type Availability struct {
+	lock      sync.RWMutex
+	services  map[schema.GroupVersion]*service
+	listeners []func()
+}
+
+// NewAvailability returns an Availability of no APIServices, which are added as they are updated.
+func NewAvailability() *Availability {
+	return &Availability{
+		services: map[schema.GroupVersion]*service{},
+	}
+}
+
+// Update records the availability of an APIService, or its removal if it is nil or deleted. APIServices without
+// a service are served by the kubernetes apiserver itself, and aren't tracked.
+func (a *Availability) Update(name string, api *apiv1.APIService) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for gv, s := range a.services {
+		if s.name == name {
+			delete(a.services, gv)
+		}
+	}
+	if api == nil || api.DeletionTimestamp != nil || api.Spec.Service == nil {
+		return
+	}
+
+	s := &service{name: api.Name}
+	for _, condition := range api.Status.Conditions {
+		if condition.Type != apiv1.Available {
+			continue
+		}
+		s.available = condition.Status == apiv1.ConditionTrue
+		if !s.available {
+			s.message = condition.Reason
+			if condition.Message != "" {
+				s.message += ": " + condition.Message
+			}
+		}
+	}
+	a.services[schema.GroupVersion{Group: api.Spec.Group, Version: api.Spec.Version}] = s
+}
+
+// Status returns the availability of the APIService serving a group version, and false if it is not served by an
+// aggregated APIService.
+func (a *Availability) Status(gv schema.GroupVersion) (Status, bool) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	s, ok := a.services[gv]
+	if !ok {
+		return Status{}, false
+	}
+	return s.status(), true
+}
+
+// Check returns a 503 error if the group version is served by an APIService that is unavailable.
+func (a *Availability) Check(gv schema.GroupVersion) error {
+	status, ok := a.Status(gv)
+	if !ok || status.Available {
+		return nil
+	}
+	message := fmt.Sprintf("%s is served by APIService %s, which is unavailable", gv, status.Name)
+	if status.Message != "" {
+		message += ": " + status.Message
+	}
+	return apierror.NewAPIError(serviceUnavailable, message)
+}
+
+// OnChange registers a function called whenever a check changes the availability of an APIService.
+func (a *Availability) OnChange(f func()) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.listeners = append(a.listeners, f)
+}
+
+// Start checks the discovery of the group version of every APIService periodically with the client, until the
+// context is done, so a backend that goes down is noticed before the aggregator reports it.
+func (a *Availability) Start(ctx context.Context, client rest.Interface) {
+	interval := checkInterval()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			a.checkAll(ctx, client)
+		}
+	}()
+}
+
+func (a *Availability) checkAll(ctx context.Context, client rest.Interface) {
+	a.lock.RLock()
+	gvs := make([]schema.GroupVersion, 0, len(a.services))
+	for gv := range a.services {
+		gvs = append(gvs, gv)
+	}
+	a.lock.RUnlock()
+
+	results := make(map[schema.GroupVersion]error, len(gvs))
+	for _, gv := range gvs {
+		results[gv] = check(ctx, client, gv)
+	}
+
+	a.lock.Lock()
+	changed := false
+	for gv, err := range results {
+		s, ok := a.services[gv]
+		if !ok {
+			continue
+		}
+		if (s.checkErr == nil) != (err == nil) {
+			changed = true
+			if err != nil {
+				logrus.Infof("APIService %s is unavailable: %v", s.name, err)
+			} else {
+				logrus.Infof("APIService %s is available again", s.name)
+			}
+		}
+		s.checkErr = err
+	}
+	listeners := a.listeners
+	a.lock.Unlock()
+
+	if changed {
+		for _, f := range listeners {
+			f()
+		}
+	}
+}
+
+// check reads the discovery of a group version, which the aggregator proxies to the backend of its APIService.
+func check(ctx context.Context, client rest.Interface, gv schema.GroupVersion) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	return client.Get().AbsPath("/apis", gv.Group, gv.Version).Do(ctx).Error()
+}
+
+func checkInterval() time.Duration {
+	if setting := os.Getenv(checkIntervalEnv); setting != "" {
+		seconds, err := strconv.Atoi(setting)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", checkIntervalEnv, err)
+		} else if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultCheckInterval
+}
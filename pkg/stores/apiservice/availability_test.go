@@ -0,0 +1,86 @@
+package apiservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	apiv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+func apiService(name, group, version string, aggregated bool, status apiv1.ConditionStatus) *apiv1.APIService {
+	api := &apiv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       apiv1.APIServiceSpec{Group: group, Version: version},
+		Status: apiv1.APIServiceStatus{Conditions: []apiv1.APIServiceCondition{{
+			Type:    apiv1.Available,
+			Status:  status,
+			Reason:  "FailedDiscoveryCheck",
+			Message: "no response from https://10.43.0.10:443",
+		}}},
+	}
+	if aggregated {
+		api.Spec.Service = &apiv1.ServiceReference{Namespace: "kube-system", Name: "metrics-server"}
+	}
+	return api
+}
+
+func TestAvailability(t *testing.T) {
+	metrics := schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+	apps := schema.GroupVersion{Group: "apps", Version: "v1"}
+
+	a := NewAvailability()
+	a.Update("v1.apps", apiService("v1.apps", "apps", "v1", false, apiv1.ConditionTrue))
+	a.Update("v1beta1.metrics.k8s.io", apiService("v1beta1.metrics.k8s.io", "metrics.k8s.io", "v1beta1", true, apiv1.ConditionFalse))
+
+	_, ok := a.Status(apps)
+	assert.False(t, ok, "APIServices of the kubernetes apiserver aren't tracked")
+	assert.NoError(t, a.Check(apps))
+
+	err := a.Check(metrics)
+	require.Error(t, err)
+	apiErr, ok := err.(*apierror.APIError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.Code.Status)
+	assert.Contains(t, apiErr.Message, "v1beta1.metrics.k8s.io")
+	assert.Contains(t, apiErr.Message, "no response from")
+
+	// the aggregator reports the backend back, but it doesn't answer discovery
+	a.Update("v1beta1.metrics.k8s.io", apiService("v1beta1.metrics.k8s.io", "metrics.k8s.io", "v1beta1", true, apiv1.ConditionTrue))
+	assert.NoError(t, a.Check(metrics))
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host:    server.URL,
+		APIPath: "/apis",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &metav1.SchemeGroupVersion,
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		},
+	})
+	require.NoError(t, err)
+
+	changed := 0
+	a.OnChange(func() { changed++ })
+	a.checkAll(context.Background(), client)
+	assert.Equal(t, 1, changed)
+	status, ok := a.Status(metrics)
+	require.True(t, ok)
+	assert.False(t, status.Available)
+	assert.Error(t, a.Check(metrics))
+
+	a.Update("v1beta1.metrics.k8s.io", nil)
+	_, ok = a.Status(metrics)
+	assert.False(t, ok, "deleted APIServices aren't tracked")
+}
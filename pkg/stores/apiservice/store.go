@@ -0,0 +1,74 @@
+package apiservice
+
+import (
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+)
+
+// Store rejects the requests for the resources of an unavailable APIService with a 503 response, before they reach
+// the wrapped store.
+type Store struct {
+	types.Store
+	availability *Availability
+}
+
+// NewAPIServiceStore returns a Store which rejects the requests to store for the resources of unavailable
+// APIServices.
+func NewAPIServiceStore(store types.Store, availability *Availability) *Store {
+	return &Store{
+		Store:        store,
+		availability: availability,
+	}
+}
+
+// ByID looks up a single object by its ID.
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := s.check(schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.ByID(apiOp, schema, id)
+}
+
+// List returns a list of objects.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	if err := s.check(schema); err != nil {
+		return types.APIObjectList{}, err
+	}
+	return s.Store.List(apiOp, schema)
+}
+
+// Create creates a single object in the store.
+func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	if err := s.check(schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Create(apiOp, schema, data)
+}
+
+// Update updates a single object in the store.
+func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	if err := s.check(schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Update(apiOp, schema, data, id)
+}
+
+// Delete deletes an object from the store.
+func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := s.check(schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Delete(apiOp, schema, id)
+}
+
+// Watch returns a channel of events for a list or resource.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	if err := s.check(schema); err != nil {
+		return nil, err
+	}
+	return s.Store.Watch(apiOp, schema, wr)
+}
+
+func (s *Store) check(schema *types.APISchema) error {
+	return s.availability.Check(attributes.GVR(schema).GroupVersion())
+}
@@ -0,0 +1,158 @@
+// Package audit records every operation on a store, and every action and other request served outside of the
+// stores, with the user making it, its target and its outcome, to an audit log.
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/redact"
+	"github.com/rancher/wrangler/pkg/slice"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Event is the audit record of a single store operation, or of a request served outside of the stores. The events
+// of actions have the verb action, and the name of the action in Action.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Groups    []string  `json:"groups,omitempty"`
+	Verb      string    `json:"verb"`
+	Action    string    `json:"action,omitempty"`
+	Schema    string    `json:"schema"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Code      int       `json:"code"`
+	// Latency is the duration of the operation in milliseconds.
+	Latency float64 `json:"latency"`
+	// Body is the object sent by a create or update, with its sensitive fields masked.
+	Body map[string]interface{} `json:"body,omitempty"`
+	// Redacted is set when the body was left out because the schema is one of Options.RedactSchemas.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// Store sends an Event to its sink for every operation of the wrapped store.
+type Store struct {
+	types.Store
+	sink    Sink
+	options Options
+}
+
+// NewAuditStore returns a Store which records the operations of store to sink.
+func NewAuditStore(store types.Store, sink Sink, options Options) *Store {
+	return &Store{
+		Store:   store,
+		sink:    sink,
+		options: options,
+	}
+}
+
+// ByID looks up a single object by its ID.
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	start := time.Now()
+	obj, err := s.Store.ByID(apiOp, schema, id)
+	s.record(apiOp, schema, "get", id, nil, start, err)
+	return obj, err
+}
+
+// List returns a list of objects.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	start := time.Now()
+	list, err := s.Store.List(apiOp, schema)
+	s.record(apiOp, schema, "list", "", nil, start, err)
+	return list, err
+}
+
+// Create creates a single object in the store.
+func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	start := time.Now()
+	obj, err := s.Store.Create(apiOp, schema, data)
+	id := data.Name()
+	if ns := data.Namespace(); ns != "" {
+		id = ns + "/" + id
+	}
+	s.record(apiOp, schema, "create", id, data.Data(), start, err)
+	return obj, err
+}
+
+// Update updates a single object in the store.
+func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	start := time.Now()
+	obj, err := s.Store.Update(apiOp, schema, data, id)
+	verb := "update"
+	if apiOp.Method == http.MethodPatch {
+		verb = "patch"
+	}
+	s.record(apiOp, schema, verb, id, data.Data(), start, err)
+	return obj, err
+}
+
+// Delete deletes an object from a store.
+func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	start := time.Now()
+	obj, err := s.Store.Delete(apiOp, schema, id)
+	s.record(apiOp, schema, "delete", id, nil, start, err)
+	return obj, err
+}
+
+// Watch returns a channel of events for a list or resource. The event records the start of the watch.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	start := time.Now()
+	c, err := s.Store.Watch(apiOp, schema, wr)
+	s.record(apiOp, schema, "watch", wr.ID, nil, start, err)
+	return c, err
+}
+
+func (s *Store) record(apiOp *types.APIRequest, schema *types.APISchema, verb, id string, body map[string]interface{}, start time.Time, err error) {
+	event := newEvent(apiOp, s.options, schema.ID, verb, id, start)
+	event.Code = code(verb, err)
+	if s.options.IncludeBodies && len(body) > 0 {
+		if slice.ContainsString(s.options.RedactSchemas, schema.ID) {
+			event.Redacted = true
+		} else {
+			event.Body = redact.Mask(body, redact.Fields(schema))
+		}
+	}
+	s.sink.Record(event)
+}
+
+// newEvent returns the event of an operation of the user of the request, without its outcome.
+func newEvent(apiOp *types.APIRequest, options Options, schemaID, verb, id string, start time.Time) Event {
+	event := Event{
+		Time:      start,
+		Cluster:   options.Cluster,
+		Verb:      verb,
+		Schema:    schemaID,
+		Namespace: apiOp.Namespace,
+		Name:      id,
+		Latency:   float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if ns, name, ok := strings.Cut(id, "/"); ok {
+		event.Namespace, event.Name = ns, name
+	}
+	if user, ok := request.UserFrom(apiOp.Context()); ok {
+		event.User = user.GetName()
+		event.Groups = user.GetGroups()
+	}
+	return event
+}
+
+// code returns the HTTP status code of the outcome of an operation.
+func code(verb string, err error) int {
+	if err == nil {
+		if verb == "create" {
+			return http.StatusCreated
+		}
+		return http.StatusOK
+	}
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code.Status
+	}
+	return http.StatusInternalServerError
+}
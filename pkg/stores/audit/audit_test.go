@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type recorder []Event
+
+func (r *recorder) Record(event Event) {
+	*r = append(*r, event)
+}
+
+type testStore struct {
+	empty.Store
+}
+
+func (testStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	return types.APIObjectList{}, nil
+}
+
+func (testStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	return data, nil
+}
+
+func (testStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, "denied")
+}
+
+func TestAudit(t *testing.T) {
+	secret := &types.APISchema{Schema: &schemas.Schema{ID: "secret"}}
+	attributes.SetSensitiveFields(secret, [][]string{{"data"}})
+	configMap := &types.APISchema{Schema: &schemas.Schema{ID: "configmap"}}
+	object := func(data map[string]interface{}) types.APIObject {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "a", "namespace": "ns"},
+		}}
+		for k, v := range data {
+			obj.Object[k] = v
+		}
+		return types.APIObject{Object: obj}
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+		do      func(s *Store, apiOp *types.APIRequest) error
+		want    Event
+	}{
+		{
+			name: "list",
+			do: func(s *Store, apiOp *types.APIRequest) error {
+				_, err := s.List(apiOp, configMap)
+				return err
+			},
+			want: Event{Verb: "list", Schema: "configmap", Code: http.StatusOK},
+		},
+		{
+			name: "failed update",
+			do: func(s *Store, apiOp *types.APIRequest) error {
+				_, err := s.Update(apiOp, configMap, object(nil), "ns/a")
+				return err
+			},
+			want: Event{Verb: "update", Schema: "configmap", Namespace: "ns", Name: "a", Code: http.StatusForbidden},
+		},
+		{
+			name:    "masked body",
+			options: Options{IncludeBodies: true},
+			do: func(s *Store, apiOp *types.APIRequest) error {
+				_, err := s.Create(apiOp, secret, object(map[string]interface{}{"data": map[string]interface{}{"key": "value"}}))
+				return err
+			},
+			want: Event{Verb: "create", Schema: "secret", Namespace: "ns", Name: "a", Code: http.StatusCreated, Body: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "a", "namespace": "ns"},
+				"data":     map[string]interface{}{"key": ""},
+			}},
+		},
+		{
+			name:    "redacted schema",
+			options: Options{IncludeBodies: true, RedactSchemas: []string{"configmap"}},
+			do: func(s *Store, apiOp *types.APIRequest) error {
+				_, err := s.Create(apiOp, configMap, object(map[string]interface{}{"data": map[string]interface{}{"key": "value"}}))
+				return err
+			},
+			want: Event{Verb: "create", Schema: "configmap", Namespace: "ns", Name: "a", Code: http.StatusCreated, Redacted: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := &recorder{}
+			store := NewAuditStore(&testStore{}, events, test.options)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			apiOp := &types.APIRequest{
+				Request: req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice", Groups: []string{"devs"}})),
+			}
+
+			_ = test.do(store, apiOp)
+			if assert.Len(t, *events, 1) {
+				got := (*events)[0]
+				assert.False(t, got.Time.IsZero())
+				got.Time, got.Latency = test.want.Time, 0
+				test.want.User = "alice"
+				test.want.Groups = []string{"devs"}
+				assert.Equal(t, test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+// Handler returns a handler which records an Event for the requests served by serve that no audited store sees:
+// actions, which their handlers serve on their own, and the writes of schemas with a create handler or a store that
+// isn't audited, such as imports and kubeconfigs. The store operations those handlers make are recorded as well.
+// serve returns the request it parsed, or nil if it wasn't parsed. It is called directly if sink is nil.
+func Handler(sink Sink, options Options, serve func(rw http.ResponseWriter, req *http.Request) *types.APIRequest) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if sink == nil {
+			serve(rw, req)
+			return
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: rw}
+		apiOp := serve(recorder, req)
+		if apiOp == nil || apiOp.Schema == nil {
+			return
+		}
+		verb := handlerVerb(apiOp)
+		if verb == "" {
+			return
+		}
+		event := newEvent(apiOp, options, apiOp.Schema.ID, verb, apiOp.Name, start)
+		event.Action = apiOp.Action
+		event.Code = recorder.code
+		if event.Code == 0 {
+			event.Code = http.StatusOK
+		}
+		sink.Record(event)
+	})
+}
+
+// handlerVerb returns the verb of a request audited by the Handler, or an empty string if it isn't.
+func handlerVerb(apiOp *types.APIRequest) string {
+	if apiOp.Action != "" {
+		return "action"
+	}
+	if _, ok := apiOp.Schema.Store.(*Store); ok && (apiOp.Method != http.MethodPost || apiOp.Schema.CreateHandler == nil) {
+		return ""
+	}
+	switch apiOp.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return ""
+}
+
+// statusRecorder keeps the status code of a response. Websockets are recorded as 101 Switching Protocols once the
+// connection is hijacked.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && s.code == 0 {
+		s.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestHandler(t *testing.T) {
+	audited := &types.APISchema{Schema: &schemas.Schema{ID: "node"}, Store: NewAuditStore(&testStore{}, &recorder{}, Options{})}
+	imports := &types.APISchema{
+		Schema:        &schemas.Schema{ID: "import"},
+		Store:         audited.Store,
+		CreateHandler: func(*types.APIRequest) (types.APIObject, error) { return types.APIObject{}, nil },
+	}
+	kubeconfigs := &types.APISchema{Schema: &schemas.Schema{ID: "kubeconfig"}, Store: &testStore{}}
+
+	tests := []struct {
+		name   string
+		method string
+		schema *types.APISchema
+		action string
+		id     string
+		code   int
+		want   *Event
+	}{
+		{
+			name:   "action",
+			method: http.MethodPost,
+			schema: audited,
+			action: "drain",
+			id:     "node-1",
+			code:   http.StatusForbidden,
+			want:   &Event{Verb: "action", Action: "drain", Schema: "node", Name: "node-1", Code: http.StatusForbidden},
+		},
+		{
+			name:   "create handler",
+			method: http.MethodPost,
+			schema: imports,
+			code:   http.StatusCreated,
+			want:   &Event{Verb: "create", Schema: "import", Code: http.StatusCreated},
+		},
+		{
+			name:   "store that isn't audited",
+			method: http.MethodPost,
+			schema: kubeconfigs,
+			want:   &Event{Verb: "create", Schema: "kubeconfig", Code: http.StatusOK},
+		},
+		{
+			name:   "audited store",
+			method: http.MethodPut,
+			schema: audited,
+			id:     "node-1",
+		},
+		{
+			name:   "read of a store that isn't audited",
+			method: http.MethodGet,
+			schema: kubeconfigs,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			events := &recorder{}
+			handler := Handler(events, Options{Cluster: "c-1"}, func(rw http.ResponseWriter, req *http.Request) *types.APIRequest {
+				if test.code != 0 {
+					rw.WriteHeader(test.code)
+				}
+				return &types.APIRequest{
+					Method:  test.method,
+					Schema:  test.schema,
+					Action:  test.action,
+					Name:    test.id,
+					Request: req,
+				}
+			})
+			req := httptest.NewRequest(test.method, "/", nil)
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "jane"}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if test.want == nil {
+				assert.Empty(t, *events)
+				return
+			}
+			require.Len(t, *events, 1)
+			got := (*events)[0]
+			assert.False(t, got.Time.IsZero())
+			got.Time, got.Latency = test.want.Time, 0
+			test.want.User, test.want.Cluster = "jane", "c-1"
+			assert.Equal(t, *test.want, got)
+		})
+	}
+}
+
+type chanSink chan Event
+
+func (c chanSink) Record(event Event) {
+	c <- event
+}
+
+func TestHandlerWebsocket(t *testing.T) {
+	events := make(chanSink, 1)
+	pods := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	server := httptest.NewServer(Handler(events, Options{}, func(rw http.ResponseWriter, req *http.Request) *types.APIRequest {
+		conn, _, err := rw.(http.Hijacker).Hijack()
+		if assert.NoError(t, err) {
+			conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+			conn.Close()
+		}
+		return &types.APIRequest{Method: http.MethodGet, Schema: pods, Action: "exec", Namespace: "default", Name: "web", Request: req}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	event := <-events
+	assert.Equal(t, "exec", event.Action)
+	assert.Equal(t, "default", event.Namespace)
+	assert.Equal(t, http.StatusSwitchingProtocols, event.Code)
+}
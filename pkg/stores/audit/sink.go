@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	webhookBufferSize = 1000
+	webhookBatchSize  = 100
+	webhookInterval   = time.Second
+	webhookTimeout    = 10 * time.Second
+)
+
+// Options configure where audit events are sent and what they contain.
+type Options struct {
+	// Path is the file events are appended to as JSON lines, or - for stdout.
+	Path string
+	// WebhookURL is the URL events are POSTed to in batches, as JSON arrays.
+	WebhookURL string
+	// IncludeBodies adds the objects sent by creates and updates to their events, with sensitive fields masked.
+	IncludeBodies bool
+	// RedactSchemas are the IDs of schemas whose bodies are always left out, such as secret.
+	RedactSchemas []string
+	// Cluster is the ID of the downstream cluster whose operations are audited, recorded in their events. It is
+	// empty for the local cluster.
+	Cluster string
+}
+
+// Enabled returns whether events are sent anywhere.
+func (o Options) Enabled() bool {
+	return o.Path != "" || o.WebhookURL != ""
+}
+
+// Sink receives audit events. Record must not block the operation being audited.
+type Sink interface {
+	Record(event Event)
+}
+
+// NewSink returns the sink for the options, which is stopped when ctx is done, or nil if auditing is disabled.
+func NewSink(ctx context.Context, options Options) (Sink, error) {
+	var sinks multiSink
+	if options.Path != "" {
+		w := io.WriteCloser(nopCloser{os.Stdout})
+		if options.Path != "-" {
+			f, err := os.OpenFile(options.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				return nil, fmt.Errorf("opening audit log: %w", err)
+			}
+			w = f
+		}
+		go func() {
+			<-ctx.Done()
+			w.Close()
+		}()
+		sinks = append(sinks, NewWriterSink(w))
+	}
+	if options.WebhookURL != "" {
+		sinks = append(sinks, NewWebhookSink(ctx, options.WebhookURL))
+	}
+	switch len(sinks) {
+	case 0:
+		return nil, nil
+	case 1:
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
+
+type multiSink []Sink
+
+func (m multiSink) Record(event Event) {
+	for _, sink := range m {
+		sink.Record(event)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type writerSink struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterSink returns a Sink which writes each event to w as a line of JSON.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (w *writerSink) Record(event Event) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.encoder.Encode(event); err != nil {
+		logrus.Errorf("failed to write audit event: %v", err)
+	}
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+	events chan Event
+}
+
+// NewWebhookSink returns a Sink which POSTs events to url in batches, until ctx is done. Events are dropped when
+// the webhook falls too far behind, rather than slowing down requests.
+func NewWebhookSink(ctx context.Context, url string) Sink {
+	w := &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		events: make(chan Event, webhookBufferSize),
+	}
+	go w.run(ctx)
+	return w
+}
+
+func (w *webhookSink) Record(event Event) {
+	select {
+	case w.events <- event:
+	default:
+		logrus.Warnf("audit webhook is behind, dropping event for %s %s", event.Verb, event.Schema)
+	}
+}
+
+func (w *webhookSink) run(ctx context.Context) {
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.send(batch); err != nil {
+			logrus.Errorf("failed to send %d audit events: %v", len(batch), err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case event := <-w.events:
+			batch = append(batch, event)
+			if len(batch) >= webhookBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (w *webhookSink) send(batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
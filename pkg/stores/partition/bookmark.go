@@ -0,0 +1,69 @@
+package partition
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+const (
+	// BookmarkAPIEvent is the name of an event which carries no object and only marks the revision a watch has reached.
+	BookmarkAPIEvent = "resource.bookmark"
+
+	// bookmarkParam is the query parameter of the watch request that asks for bookmarks to be forwarded.
+	bookmarkParam = "bookmarks"
+)
+
+// bookmarks merges the bookmarks of the partitions of a watch into a single revision.
+// A merged bookmark is only reported once every partition has reached it, so that a client
+// resuming from the merged revision misses no events in any partition.
+type bookmarks struct {
+	lock sync.Mutex
+
+	revisions []uint64
+	last      uint64
+}
+
+func newBookmarks(partitions int) *bookmarks {
+	return &bookmarks{
+		revisions: make([]uint64, partitions),
+	}
+}
+
+// seen records that the partition at the index has reached the revision of a bookmark event.
+// It returns a merged bookmark event if every partition has now reached a revision past the last merged bookmark.
+// Only bookmarks are trusted to mark progress, since other events may be synthesized out of order,
+// for example when a related object changes.
+func (b *bookmarks) seen(index int, event types.APIEvent) (types.APIEvent, bool) {
+	if event.Name != BookmarkAPIEvent {
+		return types.APIEvent{}, false
+	}
+	revision, err := strconv.ParseUint(event.Revision, 10, 64)
+	if err != nil {
+		return types.APIEvent{}, false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if revision > b.revisions[index] {
+		b.revisions[index] = revision
+	}
+
+	merged := b.revisions[0]
+	for _, r := range b.revisions[1:] {
+		if r < merged {
+			merged = r
+		}
+	}
+	if merged == 0 || merged <= b.last {
+		return types.APIEvent{}, false
+	}
+	b.last = merged
+
+	return types.APIEvent{
+		Name:     BookmarkAPIEvent,
+		Revision: strconv.FormatUint(merged, 10),
+	}, true
+}
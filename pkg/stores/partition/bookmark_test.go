@@ -0,0 +1,79 @@
+package partition
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookmarks(t *testing.T) {
+	b := newBookmarks(2)
+	bookmark := func(revision string) types.APIEvent {
+		return types.APIEvent{Name: BookmarkAPIEvent, Revision: revision}
+	}
+
+	_, ok := b.seen(0, bookmark("5"))
+	assert.False(t, ok, "not every partition has reached a revision")
+	merged, ok := b.seen(1, bookmark("3"))
+	assert.True(t, ok)
+	assert.Equal(t, bookmark("3"), merged, "the merged bookmark is the oldest revision of the partitions")
+
+	_, ok = b.seen(0, bookmark("7"))
+	assert.False(t, ok, "the oldest revision did not move")
+	_, ok = b.seen(1, bookmark("2"))
+	assert.False(t, ok, "bookmarks never go back")
+	_, ok = b.seen(1, types.APIEvent{Name: types.ChangeAPIEvent, Revision: "9"})
+	assert.False(t, ok, "only bookmarks mark progress")
+	_, ok = b.seen(1, bookmark("invalid"))
+	assert.False(t, ok)
+
+	merged, ok = b.seen(1, bookmark("8"))
+	assert.True(t, ok)
+	assert.Equal(t, bookmark("7"), merged)
+}
+
+func TestWatchForwardsBookmarks(t *testing.T) {
+	for _, forward := range []bool{true, false} {
+		a, b := newWatchStore(), newWatchStore()
+		s := &Store{Partitioner: namespacePartitioner{stores: map[string]types.Store{"a": a, "b": b}}}
+		target := "/v1/pods"
+		if forward {
+			target += "?bookmarks=true"
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)}
+
+		c, err := s.Watch(apiOp, &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}, types.WatchRequest{})
+		require.NoError(t, err)
+		events := make(chan types.APIEvent, 10)
+		go func() {
+			defer close(events)
+			for event := range c {
+				events <- event
+			}
+		}()
+		a.events <- types.APIEvent{Name: BookmarkAPIEvent, Revision: "5"}
+		b.events <- types.APIEvent{Name: BookmarkAPIEvent, Revision: "3"}
+		// an event after the bookmarks, so the watch is known to have handled them
+		created := types.APIEvent{Name: types.CreateAPIEvent, Object: types.APIObject{ID: "web"}}
+		b.events <- created
+
+		if forward {
+			assert.ElementsMatch(t, []types.APIEvent{{Name: BookmarkAPIEvent, Revision: "3"}, created}, []types.APIEvent{<-events, <-events},
+				"the merged bookmark is forwarded")
+		} else {
+			assert.Equal(t, created, <-events, "bookmarks are only forwarded when asked for")
+		}
+		cancel()
+		close(a.events)
+		close(b.events)
+		for range events {
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package partition
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	listCacheTTLEnv  = "CATTLE_LIST_CACHE_TTL_SECONDS"
+	listCacheSize    = 100
+	defaultListCache = 30 * time.Second
+	listCacheName    = "steve-list-cache"
+	// anyRevision is the resourceVersion of a request that accepts a list of any revision, as kubernetes serves it
+	// from its watch cache.
+	anyRevision = "0"
+)
+
+// pagingParams are the query parameters that select a segment or shape of a list rather than the list itself,
+// so they are left out of the cache key.
+var pagingParams = []string{"continue", "limit", "page", "pagesize", "revision", "sort", "order", "fields", "excludeFields", "timeout", "partitionOrder", "limitPerPartition", "cache", "resourceVersion"}
+
+// listCache holds complete, merged lists of objects across partitions, keyed by schema, by the request
+// that produced them and by the revision they were listed at. It lets a client walk the pages of a
+// sorted or paginated list without every page re-listing every partition from kubernetes.
+type listCache struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	schemas map[string]*cache.LRUExpireCache
+}
+
+func newListCache() *listCache {
+	ttl := defaultListCache
+	if ttlSetting := os.Getenv(listCacheTTLEnv); ttlSetting != "" {
+		seconds, err := strconv.Atoi(ttlSetting)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", listCacheTTLEnv, err)
+		} else {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	return &listCache{
+		ttl:     ttl,
+		schemas: map[string]*cache.LRUExpireCache{},
+	}
+}
+
+func (l *listCache) forSchema(schemaID string) *cache.LRUExpireCache {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	c, ok := l.schemas[schemaID]
+	if !ok {
+		c = cache.NewLRUExpireCache(listCacheSize)
+		l.schemas[schemaID] = c
+	}
+	return c
+}
+
+// listCacheEntry is a complete list cached at a revision.
+type listCacheEntry struct {
+	objects  []types.APIObject
+	revision string
+	added    time.Time
+}
+
+// enabled returns whether lists are cached.
+func (l *listCache) enabled() bool {
+	return l.ttl > 0
+}
+
+// get returns a copy of the cached list for the key at the given revision, or at the latest revision cached for
+// the key if the revision is anyRevision.
+// The copy may be reordered by the caller without affecting other requests.
+func (l *listCache) get(schemaID, key, revision string) (listCacheEntry, bool) {
+	if l.ttl <= 0 || revision == "" {
+		return listCacheEntry{}, false
+	}
+	c := l.forSchema(schemaID)
+	if revision == anyRevision {
+		latest, ok := c.Get(key + "@" + anyRevision)
+		if !ok {
+			return listCacheEntry{}, false
+		}
+		revision = latest.(string)
+	}
+	obj, ok := c.Get(key + "@" + revision)
+	if !ok {
+		return listCacheEntry{}, false
+	}
+	entry := obj.(listCacheEntry)
+	entry.objects = append(make([]types.APIObject, 0, len(entry.objects)), entry.objects...)
+	return entry, true
+}
+
+// add stores a copy of the list for the key at the given revision, which becomes the latest revision of the key.
+func (l *listCache) add(schemaID, key, revision string, objects []types.APIObject) {
+	if l.ttl <= 0 || revision == "" {
+		return
+	}
+	c := l.forSchema(schemaID)
+	c.Add(key+"@"+revision, listCacheEntry{
+		objects:  append(make([]types.APIObject, 0, len(objects)), objects...),
+		revision: revision,
+		added:    time.Now(),
+	}, l.ttl)
+	c.Add(key+"@"+anyRevision, revision, l.ttl)
+}
+
+// listCacheKey returns the key identifying the complete list for a request.
+// Partitions are derived from the user's access, so users with the same access share entries.
+func listCacheKey(apiOp *types.APIRequest, partitions []Partition) string {
+	query := url.Values{}
+	for k, v := range apiOp.Request.URL.Query() {
+		query[k] = v
+	}
+	for _, param := range pagingParams {
+		query.Del(param)
+	}
+
+	parts := make([]string, 0, len(partitions))
+	for _, partition := range partitions {
+		parts = append(parts, fmt.Sprintf("%+v", partition))
+	}
+
+	key := apiOp.Namespace + "?" + query.Encode() + "#" + strings.Join(parts, ";")
+	if client.AcceptsTable(apiOp.Request) {
+		// tables are listed with only the metadata of the objects
+		key += "#table"
+	}
+	return key
+}
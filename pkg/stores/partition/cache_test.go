@@ -0,0 +1,33 @@
+package partition
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+func TestListCacheAnyRevision(t *testing.T) {
+	l := &listCache{ttl: time.Minute, schemas: map[string]*cache.LRUExpireCache{}}
+
+	_, ok := l.get("pod", "key", anyRevision)
+	assert.False(t, ok)
+
+	l.add("pod", "key", "10", []types.APIObject{{ID: "a"}})
+	l.add("pod", "key", "12", []types.APIObject{{ID: "a"}, {ID: "b"}})
+
+	entry, ok := l.get("pod", "key", "10")
+	assert.True(t, ok)
+	assert.Equal(t, "10", entry.revision)
+	assert.Len(t, entry.objects, 1)
+
+	entry, ok = l.get("pod", "key", anyRevision)
+	assert.True(t, ok)
+	assert.Equal(t, "12", entry.revision)
+	assert.Len(t, entry.objects, 2)
+
+	_, ok = l.get("pod", "key", "")
+	assert.False(t, ok)
+}
@@ -0,0 +1,247 @@
+package partition
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/steve/pkg/writer"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	clusterParam  = "cluster"
+	clustersParam = "clusters"
+)
+
+// ClusterPartition is a partition of one cluster of a fleet, such as a namespace of the cluster.
+type ClusterPartition struct {
+	Cluster   string
+	Partition Partition
+}
+
+// Name returns the cluster and the name of the partition within it, so the partitions of each cluster are kept
+// apart in continue and watch tokens.
+func (p ClusterPartition) Name() string {
+	return p.Cluster + "/" + p.Partition.Name()
+}
+
+// ClusterPartitioner partitions the objects of a fleet of clusters, each partitioned by its own Partitioner, such as
+// a proxy partitioner over the clients of the cluster. A partition Store using it lists and watches every cluster
+// at once, and every object it returns has the name of its cluster set as metadata.cluster.
+//
+// Lists and watches span every cluster unless the comma separated clusters query parameter names some. Other
+// requests go to the cluster of the cluster query parameter, or else to the local cluster.
+type ClusterPartitioner struct {
+	lock     sync.RWMutex
+	local    string
+	clusters map[string]Partitioner
+}
+
+// NewClusterPartitioner returns a partitioner of the given clusters. Single objects are looked up in the local
+// cluster unless the request names another.
+func NewClusterPartitioner(local string, clusters map[string]Partitioner) *ClusterPartitioner {
+	p := &ClusterPartitioner{
+		local:    local,
+		clusters: map[string]Partitioner{},
+	}
+	for name, partitioner := range clusters {
+		p.clusters[name] = partitioner
+	}
+	return p
+}
+
+// Set adds a cluster to the fleet, or replaces its partitioner.
+func (p *ClusterPartitioner) Set(cluster string, partitioner Partitioner) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.clusters[cluster] = partitioner
+}
+
+// Remove removes a cluster from the fleet. Watches of the cluster that are open continue until they end.
+func (p *ClusterPartitioner) Remove(cluster string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.clusters, cluster)
+}
+
+func (p *ClusterPartitioner) cluster(name string) (Partitioner, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	partitioner, ok := p.clusters[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "clusters"}, name)
+	}
+	return partitioner, nil
+}
+
+// selected returns the names of the clusters of a list or watch, in name order.
+func (p *ClusterPartitioner) selected(apiOp *types.APIRequest) []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	var names []string
+	if selection := apiOp.Request.URL.Query().Get(clustersParam); selection != "" {
+		for _, name := range strings.Split(selection, ",") {
+			if _, ok := p.clusters[name]; ok {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name := range p.clusters {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the partition of the cluster named by the cluster query parameter, or of the local cluster.
+func (p *ClusterPartitioner) Lookup(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) (Partition, error) {
+	name := apiOp.Request.URL.Query().Get(clusterParam)
+	if name == "" {
+		name = p.local
+	}
+	partitioner, err := p.cluster(name)
+	if err != nil {
+		return nil, err
+	}
+	partition, err := partitioner.Lookup(apiOp, schema, verb, id)
+	if err != nil {
+		return nil, err
+	}
+	return ClusterPartition{Cluster: name, Partition: partition}, nil
+}
+
+// All returns the partitions of every selected cluster. If the request is partial, clusters whose partitions can't
+// be determined are skipped and reported in the response instead of failing the request.
+func (p *ClusterPartitioner) All(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) ([]Partition, error) {
+	partial := listprocessor.ParseQuery(apiOp).Partial
+	var result []Partition
+	for _, name := range p.selected(apiOp) {
+		partitioner, err := p.cluster(name)
+		if err != nil {
+			// removed since the clusters were selected
+			continue
+		}
+		partitions, err := partitioner.All(apiOp, schema, verb, id)
+		if err != nil {
+			if partial {
+				writer.ListMetaFrom(apiOp.Context()).AddPartialError(name, err)
+				continue
+			}
+			return nil, fmt.Errorf("cluster %s: %w", name, err)
+		}
+		if sorter, ok := partitioner.(PartitionSorter); ok {
+			partitions = sorter.SortPartitions(apiOp, schema, partitions)
+		}
+		for _, partition := range partitions {
+			result = append(result, ClusterPartition{Cluster: name, Partition: partition})
+		}
+	}
+	return result, nil
+}
+
+// Store returns the store of a partition from the partitioner of its cluster.
+func (p *ClusterPartitioner) Store(apiOp *types.APIRequest, partition Partition) (types.Store, error) {
+	clusterPartition, ok := partition.(ClusterPartition)
+	if !ok {
+		return nil, fmt.Errorf("partition %s is not a cluster partition", partition.Name())
+	}
+	partitioner, err := p.cluster(clusterPartition.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	store, err := partitioner.Store(apiOp, clusterPartition.Partition)
+	if err != nil {
+		return nil, err
+	}
+	return &clusterStore{Store: store, cluster: clusterPartition.Cluster}, nil
+}
+
+// clusterStore sets the cluster of the objects of a store.
+type clusterStore struct {
+	types.Store
+	cluster string
+}
+
+func (c *clusterStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	obj, err := c.Store.ByID(apiOp, schema, id)
+	return c.withCluster(obj), err
+}
+
+func (c *clusterStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	list, err := c.Store.List(apiOp, schema)
+	for i := range list.Objects {
+		list.Objects[i] = c.withCluster(list.Objects[i])
+	}
+	return list, err
+}
+
+func (c *clusterStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	obj, err := c.Store.Create(apiOp, schema, data)
+	return c.withCluster(obj), err
+}
+
+func (c *clusterStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	obj, err := c.Store.Update(apiOp, schema, data, id)
+	return c.withCluster(obj), err
+}
+
+func (c *clusterStore) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	obj, err := c.Store.Delete(apiOp, schema, id)
+	return c.withCluster(obj), err
+}
+
+func (c *clusterStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	events, err := c.Store.Watch(apiOp, schema, w)
+	if err != nil || events == nil {
+		return events, err
+	}
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		for event := range events {
+			event.Object = c.withCluster(event.Object)
+			result <- event
+		}
+	}()
+	return result, nil
+}
+
+// withCluster returns the object with its cluster set as metadata.cluster. The object may be shared with a cache, so
+// the cluster is set on a copy of the maps it changes.
+func (c *clusterStore) withCluster(obj types.APIObject) types.APIObject {
+	u, ok := obj.Object.(*unstructured.Unstructured)
+	if !ok {
+		return obj
+	}
+	metadata, _ := u.Object["metadata"].(map[string]interface{})
+	withCluster := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		withCluster[k] = v
+	}
+	withCluster["cluster"] = c.cluster
+	copied := make(map[string]interface{}, len(u.Object))
+	for k, v := range u.Object {
+		copied[k] = v
+	}
+	copied["metadata"] = withCluster
+	obj.Object = &unstructured.Unstructured{Object: copied}
+	return obj
+}
+
+// withCluster returns a copy of a request for an object of a cluster, as listed by a ClusterPartitioner.
+func withCluster(req *http.Request, cluster string) *http.Request {
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	query.Set(clusterParam, cluster)
+	req.URL.RawQuery = query.Encode()
+	return req
+}
@@ -0,0 +1,83 @@
+package partition
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type namespaceStore struct {
+	empty.Store
+	objects []types.APIObject
+}
+
+func (n *namespaceStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	return types.APIObjectList{Revision: "1", Objects: n.objects}, nil
+}
+
+// clusterPartitioner partitions a cluster by the namespaces of its objects.
+type clusterPartitioner map[string][]types.APIObject
+
+func (c clusterPartitioner) Lookup(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) (Partition, error) {
+	return testPartition(apiOp.Namespace), nil
+}
+
+func (c clusterPartitioner) All(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) ([]Partition, error) {
+	var partitions []Partition
+	for ns := range c {
+		partitions = append(partitions, testPartition(ns))
+	}
+	return partitions, nil
+}
+
+func (c clusterPartitioner) Store(apiOp *types.APIRequest, partition Partition) (types.Store, error) {
+	return &namespaceStore{objects: c[partition.Name()]}, nil
+}
+
+func object(namespace, name string) types.APIObject {
+	return types.APIObject{
+		ID: namespace + "/" + name,
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": name, "namespace": namespace},
+		}},
+	}
+}
+
+func TestClusterPartitioner(t *testing.T) {
+	shared := object("default", "web")
+	partitioner := NewClusterPartitioner("local", map[string]Partitioner{
+		"local": clusterPartitioner{"default": {shared}},
+		"east":  clusterPartitioner{"default": {object("default", "web")}, "apps": {object("apps", "api")}},
+	})
+	store := &Store{Partitioner: partitioner}
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "test"}}
+
+	list := func(query string) []string {
+		apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodGet, "/v1/test"+query, nil)}
+		list, err := store.List(apiOp, schema)
+		require.NoError(t, err)
+		var ids []string
+		for _, obj := range list.Objects {
+			ids = append(ids, obj.Data().String("metadata", "cluster")+":"+obj.ID)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"local:default/web", "east:default/web", "east:apps/api"}, list(""))
+	assert.ElementsMatch(t, []string{"east:default/web", "east:apps/api"}, list("?clusters=east,west"))
+	assert.Empty(t, shared.Data().String("metadata", "cluster"), "the listed object must not be changed")
+
+	partitioner.Remove("east")
+	assert.ElementsMatch(t, []string{"local:default/web"}, list(""))
+
+	apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodGet, "/v1/test/default/web?cluster=east", nil)}
+	_, err := partitioner.Lookup(apiOp, schema, "get", "default/web")
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package partition
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	dedupSize = 1000
+	dedupTTL  = 5 * time.Minute
+)
+
+// eventDedup remembers recently delivered events so that an event received from more than one
+// overlapping partition, such as by-name partitions within the same namespace, is only delivered once.
+type eventDedup struct {
+	lock sync.Mutex
+	seen *cache.LRUExpireCache
+}
+
+func newEventDedup() *eventDedup {
+	return &eventDedup{
+		seen: cache.NewLRUExpireCache(dedupSize),
+	}
+}
+
+// duplicate reports whether an event for the same object at the same resourceVersion has already been delivered
+// by a different partition. Repeated events from the same partition are kept, since the relationship notifier
+// resends unchanged objects when a related object changes.
+// Events that do not identify an object version, such as errors and bookmarks, are never duplicates.
+func (d *eventDedup) duplicate(index int, event types.APIEvent) bool {
+	if d == nil || event.Error != nil || event.Revision == "" || event.Object.Object == nil {
+		return false
+	}
+	uid := event.Object.Data().String("metadata", "uid")
+	if uid == "" {
+		return false
+	}
+	key := event.Name + "/" + uid + "/" + event.Revision
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if first, ok := d.seen.Get(key); ok {
+		return first.(int) != index
+	}
+	d.seen.Add(key, index, dedupTTL)
+	return false
+}
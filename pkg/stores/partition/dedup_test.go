@@ -0,0 +1,64 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func podEvent(name, uid, revision string) types.APIEvent {
+	return types.APIEvent{
+		Name:     name,
+		Revision: revision,
+		Object: types.APIObject{Type: "pod", ID: "default/web", Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "namespace": "default", "uid": uid, "resourceVersion": revision},
+		}}},
+	}
+}
+
+func TestEventDedup(t *testing.T) {
+	d := newEventDedup()
+
+	assert.False(t, d.duplicate(0, podEvent(types.ChangeAPIEvent, "1234", "5")))
+	assert.True(t, d.duplicate(1, podEvent(types.ChangeAPIEvent, "1234", "5")), "the event was delivered by another partition")
+	assert.False(t, d.duplicate(0, podEvent(types.ChangeAPIEvent, "1234", "5")), "repeated events of a partition are kept")
+	assert.False(t, d.duplicate(1, podEvent(types.ChangeAPIEvent, "1234", "6")), "other revisions are kept")
+	assert.False(t, d.duplicate(1, podEvent(types.RemoveAPIEvent, "1234", "5")), "other kinds of events are kept")
+	assert.False(t, d.duplicate(1, podEvent(types.ChangeAPIEvent, "5678", "5")), "other objects are kept")
+
+	assert.False(t, d.duplicate(1, podEvent(types.ChangeAPIEvent, "", "5")), "objects without a uid are never duplicates")
+	assert.False(t, d.duplicate(1, types.APIEvent{Name: types.ChangeAPIEvent, Revision: "5", Error: errors.New("failed")}),
+		"errors are never duplicates")
+	assert.False(t, (*eventDedup)(nil).duplicate(1, podEvent(types.ChangeAPIEvent, "1234", "5")),
+		"watches of one partition don't dedup")
+}
+
+func TestWatchDedupsPartitions(t *testing.T) {
+	a, b := newWatchStore(), newWatchStore()
+	s := &Store{Partitioner: namespacePartitioner{stores: map[string]types.Store{"a": a, "b": b}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodGet, "/v1/pods", nil).WithContext(ctx)}
+
+	c, err := s.Watch(apiOp, &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}, types.WatchRequest{})
+	require.NoError(t, err)
+
+	a.events <- podEvent(types.ChangeAPIEvent, "1234", "5")
+	assert.Equal(t, "5", (<-c).Revision)
+	b.events <- podEvent(types.ChangeAPIEvent, "1234", "5")
+	b.events <- podEvent(types.ChangeAPIEvent, "1234", "6")
+	assert.Equal(t, "6", (<-c).Revision, "the event delivered by both partitions is only sent once")
+
+	cancel()
+	close(a.events)
+	close(b.events)
+	for range c {
+	}
+}
@@ -0,0 +1,125 @@
+package partition
+
+import (
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// selectorParams are the query parameters that select the objects of a collection.
+var selectorParams = []string{"labelSelector", "fieldSelector", "filter"}
+
+// DeleteCollectionResult reports the outcome of deleting a collection.
+type DeleteCollectionResult struct {
+	// Deleted is the IDs of the objects that were deleted.
+	Deleted []string `json:"deleted"`
+	// Errors is the reason each object that could not be deleted failed.
+	Errors []DeleteError `json:"errors,omitempty"`
+}
+
+// DeleteError is the failure to delete a single object of a collection.
+type DeleteError struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// deleteCollection deletes every object across all partitions that matches the selectors and filters of the request.
+// Objects are deleted concurrently, up to the store's concurrency, and an object that fails to delete does not stop
+// the others. The result is returned as the object of the response.
+//
+// The user must be allowed to deletecollection the schema in the namespace of the request, or in every namespace
+// without one, and a request of every namespace must narrow the objects with a selector or filter.
+func (s *Store) deleteCollection(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObject, error) {
+	if err := checkDeleteCollection(apiOp, schema); err != nil {
+		return types.APIObject{}, err
+	}
+
+	partitions, err := s.Partitioner.All(apiOp, schema, "list", "")
+	if err != nil {
+		return types.APIObject{}, err
+	}
+
+	opts := listprocessor.ParseQuery(apiOp)
+	lister := s.newLister(apiOp, schema, partitions, opts)
+	objects, err := listAll(apiOp.Context(), &lister)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+
+	var (
+		result = DeleteCollectionResult{Deleted: []string{}}
+		lock   sync.Mutex
+		sem    = semaphore.NewWeighted(s.getConcurrency(apiOp.Request))
+		eg     errgroup.Group
+	)
+	for _, obj := range objects {
+		obj := obj
+		if err := sem.Acquire(apiOp.Context(), 1); err != nil {
+			return types.APIObject{}, err
+		}
+		eg.Go(func() error {
+			defer sem.Release(1)
+			err := s.deleteObject(apiOp, schema, obj)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				result.Errors = append(result.Errors, DeleteError{ID: obj.ID, Message: err.Error()})
+			} else {
+				result.Deleted = append(result.Deleted, obj.ID)
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return types.APIObject{
+		Type:   schema.ID,
+		Object: result,
+	}, nil
+}
+
+// checkDeleteCollection returns an error if the user may not delete the collection of the request, or if the request
+// would delete the objects of every namespace without selecting them.
+func checkDeleteCollection(apiOp *types.APIRequest, schema *types.APISchema) error {
+	namespace := apiOp.Namespace
+	if namespace == "" {
+		namespace = accesscontrol.All
+	}
+	access, _ := attributes.Access(schema).(accesscontrol.AccessListByVerb)
+	if !access.Grants("deletecollection", namespace, accesscontrol.All) {
+		return apierror.NewAPIError(validation.PermissionDenied, "can not deletecollection "+schema.ID)
+	}
+	if apiOp.Namespace != "" {
+		return nil
+	}
+	q := apiOp.Request.URL.Query()
+	for _, param := range selectorParams {
+		if q.Get(param) != "" {
+			return nil
+		}
+	}
+	return apierror.NewAPIError(validation.MissingRequired, "deleting "+schema.ID+" of every namespace requires a labelSelector, fieldSelector or filter")
+}
+
+// deleteObject deletes a single listed object through the store of the partition it belongs to.
+func (s *Store) deleteObject(apiOp *types.APIRequest, schema *types.APISchema, obj types.APIObject) error {
+	req := apiOp.Clone()
+	req.Namespace = obj.Namespace()
+	if cluster := obj.Data().String("metadata", "cluster"); cluster != "" {
+		req.Request = withCluster(req.Request, cluster)
+	}
+	target, err := s.getStore(req, schema, "delete", obj.Name())
+	if err != nil {
+		return err
+	}
+	_, err = target.Delete(req, schema, obj.Name())
+	return err
+}
@@ -0,0 +1,86 @@
+package partition
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+var errListed = errors.New("listed")
+
+// listingPartitioner fails the listing of partitions, which deleteCollection only reaches once its checks pass.
+type listingPartitioner struct {
+	Partitioner
+}
+
+func (listingPartitioner) All(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) ([]Partition, error) {
+	return nil, errListed
+}
+
+func TestDeleteCollectionChecks(t *testing.T) {
+	tests := []struct {
+		name      string
+		access    accesscontrol.AccessList
+		namespace string
+		query     string
+		code      validation.ErrorCode
+	}{
+		{
+			name:      "no deletecollection access",
+			namespace: "default",
+			code:      validation.PermissionDenied,
+		},
+		{
+			name:   "deletecollection access to another namespace",
+			access: accesscontrol.AccessList{{Namespace: "other", ResourceName: "*"}},
+			query:  "?labelSelector=app=web",
+			code:   validation.PermissionDenied,
+		},
+		{
+			name:   "every namespace without a selector",
+			access: accesscontrol.AccessList{{Namespace: "*", ResourceName: "*"}},
+			code:   validation.MissingRequired,
+		},
+		{
+			name:   "every namespace with a selector",
+			access: accesscontrol.AccessList{{Namespace: "*", ResourceName: "*"}},
+			query:  "?labelSelector=app=web",
+		},
+		{
+			name:      "a namespace",
+			access:    accesscontrol.AccessList{{Namespace: "default", ResourceName: "*"}},
+			namespace: "default",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			schema := &types.APISchema{Schema: &schemas.Schema{ID: "pod", Attributes: map[string]interface{}{}}}
+			attributes.SetAccess(schema, accesscontrol.AccessListByVerb{"deletecollection": test.access})
+			apiOp := &types.APIRequest{
+				Namespace: test.namespace,
+				Request:   httptest.NewRequest(http.MethodDelete, "/v1/pods"+test.query, nil),
+			}
+
+			s := &Store{Partitioner: listingPartitioner{}}
+			_, err := s.Delete(apiOp, schema, "")
+			if test.code.Code == "" {
+				assert.Equal(t, errListed, err)
+				return
+			}
+			var apiErr *apierror.APIError
+			if assert.True(t, errors.As(err, &apiErr), err) {
+				assert.Equal(t, test.code, apiErr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package partition
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	watchStrategyEnv   = "CATTLE_WATCH_BACKPRESSURE"
+	watchBufferSizeEnv = "CATTLE_WATCH_BUFFER_SIZE"
+	defaultBufferSize  = 100
+)
+
+// watchStrategy is how events from the partitions of a watch are handed to a consumer that is not keeping up.
+type watchStrategy string
+
+const (
+	// blockStrategy makes every partition wait until the consumer reads its event.
+	blockStrategy watchStrategy = "block"
+	// bufferStrategy queues events up to the buffer size before partitions wait.
+	bufferStrategy watchStrategy = "buffer"
+	// dropStrategy discards events when the buffer is full.
+	dropStrategy watchStrategy = "drop"
+	// coalesceStrategy keeps only the latest pending event for each object, so partitions never wait.
+	coalesceStrategy watchStrategy = "coalesce"
+)
+
+// watchSink merges the events of all partitions of a watch into one channel.
+// The strategy is configured with the CATTLE_WATCH_BACKPRESSURE environment variable, one of block, buffer,
+// drop or coalesce, and the buffer size with CATTLE_WATCH_BUFFER_SIZE.
+type watchSink struct {
+	strategy watchStrategy
+	response chan types.APIEvent
+
+	lock    sync.Mutex
+	pending []*types.APIEvent
+	byID    map[string]*types.APIEvent
+	ready   chan struct{}
+	done    chan struct{}
+}
+
+func newWatchSink(ctx context.Context) *watchSink {
+	strategy := watchStrategy(os.Getenv(watchStrategyEnv))
+	size := defaultBufferSize
+	if setting := os.Getenv(watchBufferSizeEnv); setting != "" {
+		userSetSize, err := strconv.Atoi(setting)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", watchBufferSizeEnv, err)
+		} else {
+			size = userSetSize
+		}
+	}
+
+	w := &watchSink{
+		strategy: strategy,
+		done:     make(chan struct{}),
+	}
+	switch strategy {
+	case bufferStrategy, dropStrategy:
+		w.response = make(chan types.APIEvent, size)
+		close(w.done)
+	case coalesceStrategy:
+		w.response = make(chan types.APIEvent)
+		w.byID = map[string]*types.APIEvent{}
+		w.ready = make(chan struct{}, 1)
+		go w.pump(ctx)
+	default:
+		w.strategy = blockStrategy
+		w.response = make(chan types.APIEvent)
+		close(w.done)
+	}
+	return w
+}
+
+// send hands an event from a partition to the consumer according to the strategy.
+func (w *watchSink) send(ctx context.Context, event types.APIEvent) {
+	switch w.strategy {
+	case dropStrategy:
+		select {
+		case w.response <- event:
+		default:
+			logrus.Debugf("dropping watch event %s for %s: consumer is not keeping up", event.Name, event.Object.ID)
+		}
+	case coalesceStrategy:
+		w.enqueue(event)
+	default:
+		select {
+		case w.response <- event:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// enqueue adds an event to the pending queue, replacing any pending event for the same object.
+func (w *watchSink) enqueue(event types.APIEvent) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	id := event.Object.Type + "/" + event.Object.ID
+	if event.Error != nil || event.Object.ID == "" {
+		w.pending = append(w.pending, &event)
+	} else if existing, ok := w.byID[id]; ok {
+		name := event.Name
+		// a consumer that never saw the object created must still be told it was created
+		if existing.Name == types.CreateAPIEvent && name == types.ChangeAPIEvent {
+			name = types.CreateAPIEvent
+		}
+		*existing = event
+		existing.Name = name
+	} else {
+		e := &event
+		w.byID[id] = e
+		w.pending = append(w.pending, e)
+	}
+
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pump delivers coalesced events to the consumer until the watch is done.
+func (w *watchSink) pump(ctx context.Context) {
+	defer close(w.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.ready:
+		}
+
+		for {
+			w.lock.Lock()
+			if len(w.pending) == 0 {
+				w.lock.Unlock()
+				break
+			}
+			event := w.pending[0]
+			w.pending = w.pending[1:]
+			if event.Error == nil && event.Object.ID != "" {
+				delete(w.byID, event.Object.Type+"/"+event.Object.ID)
+			}
+			w.lock.Unlock()
+
+			select {
+			case w.response <- *event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// wait blocks until no more events will be written to the response channel.
+func (w *watchSink) wait() {
+	<-w.done
+}
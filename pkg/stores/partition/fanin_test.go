@@ -0,0 +1,75 @@
+package partition
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func watchEvent(name, id, revision string) types.APIEvent {
+	return types.APIEvent{Name: name, Revision: revision, Object: types.APIObject{Type: "pod", ID: id}}
+}
+
+func TestWatchSinkBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := newWatchSink(ctx)
+	assert.Equal(t, blockStrategy, sink.strategy, "partitions wait for the consumer by default")
+
+	go sink.send(ctx, watchEvent(types.CreateAPIEvent, "web", "1"))
+	assert.Equal(t, watchEvent(types.CreateAPIEvent, "web", "1"), <-sink.response)
+
+	cancel()
+	sink.send(ctx, watchEvent(types.ChangeAPIEvent, "web", "2"))
+	sink.wait()
+}
+
+func TestWatchSinkBuffer(t *testing.T) {
+	t.Setenv(watchStrategyEnv, string(bufferStrategy))
+	t.Setenv(watchBufferSizeEnv, "2")
+	sink := newWatchSink(context.Background())
+
+	sink.send(context.Background(), watchEvent(types.CreateAPIEvent, "web", "1"))
+	sink.send(context.Background(), watchEvent(types.ChangeAPIEvent, "web", "2"))
+	assert.Len(t, sink.response, 2, "events are queued without waiting for the consumer")
+	assert.Equal(t, watchEvent(types.CreateAPIEvent, "web", "1"), <-sink.response)
+	assert.Equal(t, watchEvent(types.ChangeAPIEvent, "web", "2"), <-sink.response)
+}
+
+func TestWatchSinkDrop(t *testing.T) {
+	t.Setenv(watchStrategyEnv, string(dropStrategy))
+	t.Setenv(watchBufferSizeEnv, "1")
+	sink := newWatchSink(context.Background())
+
+	sink.send(context.Background(), watchEvent(types.CreateAPIEvent, "web", "1"))
+	sink.send(context.Background(), watchEvent(types.ChangeAPIEvent, "web", "2"))
+	assert.Len(t, sink.response, 1, "events are dropped once the buffer is full")
+	assert.Equal(t, watchEvent(types.CreateAPIEvent, "web", "1"), <-sink.response)
+}
+
+func TestWatchSinkCoalesce(t *testing.T) {
+	t.Setenv(watchStrategyEnv, string(coalesceStrategy))
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := newWatchSink(ctx)
+
+	// the consumer isn't reading, so the first event of web is held by the pump while the rest are pending
+	sink.send(ctx, watchEvent(types.CreateAPIEvent, "web", "1"))
+	sink.send(ctx, watchEvent(types.CreateAPIEvent, "db", "1"))
+	sink.send(ctx, watchEvent(types.ChangeAPIEvent, "db", "2"))
+	sink.send(ctx, watchEvent(types.ChangeAPIEvent, "db", "3"))
+
+	assert.Equal(t, watchEvent(types.CreateAPIEvent, "web", "1"), <-sink.response)
+	assert.Equal(t, watchEvent(types.CreateAPIEvent, "db", "3"), <-sink.response,
+		"pending events of an object are replaced by the latest, still as a create")
+
+	cancel()
+	sink.wait()
+}
+
+func TestWatchSinkBufferSize(t *testing.T) {
+	t.Setenv(watchStrategyEnv, string(bufferStrategy))
+	t.Setenv(watchBufferSizeEnv, "many")
+	sink := newWatchSink(context.Background())
+	assert.Equal(t, defaultBufferSize, cap(sink.response), "an invalid size falls back to the default")
+}
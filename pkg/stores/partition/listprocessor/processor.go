@@ -0,0 +1,475 @@
+// Package listprocessor contains methods for filtering, sorting, and paginating lists of objects.
+package listprocessor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/data"
+	"github.com/rancher/wrangler/pkg/data/convert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	filterParam   = "filter"
+	sortParam     = "sort"
+	orderParam    = "order"
+	pageSizeParam = "pagesize"
+	pageParam     = "page"
+	revisionParam = "revision"
+	partialParam  = "partial"
+	dynamicParam  = "dynamicpartitions"
+	fieldsParam   = "fields"
+	excludeParam  = "excludeFields"
+)
+
+// SortOrder represents whether the list should be ascending or descending.
+type SortOrder int
+
+const (
+	// ASC stands for ascending order.
+	ASC SortOrder = iota
+	// DESC stands for descending (reverse) order.
+	DESC
+)
+
+// ListOptions represents the query parameters that may be included in a list request.
+type ListOptions struct {
+	Filters    []Filter
+	Sort       Sort
+	Pagination Pagination
+	// Revision is the resourceVersion the client requested the list to be pinned to, if any.
+	Revision string
+	// Partial is set when the client asked for partitions that fail to list to be skipped rather than failing the list.
+	Partial bool
+	// DynamicPartitions is set when the client asked for partitions that appear while a list is being walked to be merged in.
+	DynamicPartitions bool
+	// Fields is the set of field paths to include in each returned object. Empty means the whole object.
+	Fields [][]string
+	// ExcludeFields is the set of field paths to strip from each returned object.
+	// It is nil if the request did not set the parameter, and empty if it asked for nothing to be excluded.
+	ExcludeFields [][]string
+}
+
+// Filter represents a field to filter by.
+// A subfield in an object is represented in a request query using . notation, e.g. 'metadata.name'.
+// Keys that themselves contain dots may be wrapped in brackets, e.g. 'metadata.labels[app.kubernetes.io/name]'.
+// The subfield is internally represented as a slice, e.g. [metadata, name].
+type Filter struct {
+	field []string
+	match string
+}
+
+// Field returns the path of the field the filter matches.
+func (f Filter) Field() []string {
+	return f.field
+}
+
+// Match returns the value the field must equal.
+func (f Filter) Match() string {
+	return f.match
+}
+
+// Sort represents the criteria to sort on.
+// Fields are compared in order, each one breaking ties left by the previous one.
+type Sort struct {
+	Fields []SortField
+}
+
+// SortField is a single field to sort by and the direction to sort it in.
+type SortField struct {
+	field []string
+	order SortOrder
+}
+
+// Field returns the path of the field to sort by.
+func (s SortField) Field() []string {
+	return s.field
+}
+
+// Order returns the direction to sort the field in.
+func (s SortField) Order() SortOrder {
+	return s.order
+}
+
+// Pagination represents how to return paginated results.
+type Pagination struct {
+	PageSize int
+	Page     int
+}
+
+// ParseQuery parses the query params of a request and returns a ListOptions.
+// Multiple filters may be given either as repeated filter parameters or as a comma-separated list;
+// an object must match all of them to be included in the result.
+func ParseQuery(apiOp *types.APIRequest) *ListOptions {
+	q := apiOp.Request.URL.Query()
+
+	var filterOpts []Filter
+	for _, filters := range q[filterParam] {
+		for _, filter := range strings.Split(filters, ",") {
+			parts := strings.SplitN(filter, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				continue
+			}
+			filterOpts = append(filterOpts, Filter{
+				field: splitField(parts[0]),
+				match: parts[1],
+			})
+		}
+	}
+
+	pagination := Pagination{}
+	pagination.PageSize, _ = strconv.Atoi(q.Get(pageSizeParam))
+	pagination.Page, _ = strconv.Atoi(q.Get(pageParam))
+
+	opts := &ListOptions{
+		Filters:           filterOpts,
+		Sort:              parseSort(q.Get(sortParam), q.Get(orderParam)),
+		Pagination:        pagination,
+		Revision:          q.Get(revisionParam),
+		Partial:           q.Get(partialParam) == "true",
+		DynamicPartitions: q.Get(dynamicParam) == "true",
+		Fields:            ParseFields(q[fieldsParam]),
+	}
+	if values, ok := q[excludeParam]; ok {
+		opts.ExcludeFields = append([][]string{}, ParseFields(values)...)
+	}
+	return opts
+}
+
+// parseSort parses a comma-separated list of field paths, each optionally prefixed with '-' for descending order.
+// An order of "desc" reverses the direction of every field.
+func parseSort(sortKeys, order string) Sort {
+	var result Sort
+	for _, key := range strings.Split(sortKeys, ",") {
+		sortOrder := ASC
+		if strings.HasPrefix(key, "-") {
+			sortOrder = DESC
+			key = key[1:]
+		}
+		if key == "" {
+			continue
+		}
+		if strings.EqualFold(order, "desc") {
+			sortOrder = 1 - sortOrder
+		}
+		result.Fields = append(result.Fields, SortField{
+			field: splitField(key),
+			order: sortOrder,
+		})
+	}
+	return result
+}
+
+// ParseFields parses repeated or comma-separated field paths.
+func ParseFields(values []string) [][]string {
+	var result [][]string
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			if path := splitField(field); len(path) > 0 {
+				result = append(result, path)
+			}
+		}
+	}
+	return result
+}
+
+// splitField splits a field path on dots, treating bracketed segments as a single literal key.
+func splitField(field string) []string {
+	var (
+		result  []string
+		current strings.Builder
+		bracket bool
+	)
+
+	for _, r := range field {
+		switch {
+		case r == '[' && !bracket:
+			if current.Len() > 0 {
+				result = append(result, current.String())
+				current.Reset()
+			}
+			bracket = true
+		case r == ']' && bracket:
+			result = append(result, current.String())
+			current.Reset()
+			bracket = false
+		case r == '.' && !bracket:
+			if current.Len() > 0 {
+				result = append(result, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		result = append(result, current.String())
+	}
+
+	return result
+}
+
+// FilterList returns the subset of objects that match all of the given filters.
+func FilterList(list []types.APIObject, filters []Filter) []types.APIObject {
+	if len(filters) == 0 {
+		return list
+	}
+
+	result := make([]types.APIObject, 0, len(list))
+	for _, obj := range list {
+		if matchesAll(obj.Data(), filters) {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+func matchesAll(obj map[string]interface{}, filters []Filter) bool {
+	for _, f := range filters {
+		if !matchesOne(obj, f.field, f.match) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesOne reports whether the value at the field path equals match.
+// If a slice is encountered along the path, any element of the slice may satisfy the remainder of the path.
+func matchesOne(obj map[string]interface{}, field []string, match string) bool {
+	for i, key := range field {
+		val, ok := obj[key]
+		if !ok {
+			return false
+		}
+		rest := field[i+1:]
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if len(rest) == 0 {
+				return false
+			}
+			obj = v
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok && len(rest) > 0 {
+					if matchesOne(m, rest, match) {
+						return true
+					}
+				} else if len(rest) == 0 && convert.ToString(item) == match {
+					return true
+				}
+			}
+			return false
+		default:
+			return len(rest) == 0 && convert.ToString(val) == match
+		}
+	}
+	return false
+}
+
+// SortList sorts the slice by the provided sort criteria.
+// Objects that compare equal on every field are ordered by namespace and name so that pages are stable.
+func SortList(list []types.APIObject, s Sort) []types.APIObject {
+	if len(s.Fields) == 0 {
+		return list
+	}
+
+	type sortable struct {
+		obj  types.APIObject
+		keys []string
+		id   string
+	}
+
+	items := make([]sortable, len(list))
+	for i, obj := range list {
+		d := obj.Data()
+		keys := make([]string, len(s.Fields))
+		for j, f := range s.Fields {
+			keys[j] = convert.ToString(data.GetValueN(d, f.field...))
+		}
+		items[i] = sortable{
+			obj:  obj,
+			keys: keys,
+			id:   obj.Namespace() + "/" + obj.Name(),
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for k, f := range s.Fields {
+			c := compare(items[i].keys[k], items[j].keys[k])
+			if c == 0 {
+				continue
+			}
+			if f.order == DESC {
+				return c > 0
+			}
+			return c < 0
+		}
+		return items[i].id < items[j].id
+	})
+
+	for i := range items {
+		list[i] = items[i].obj
+	}
+	return list
+}
+
+// compare compares two values numerically if both are numbers, and lexically otherwise.
+func compare(left, right string) int {
+	l, lErr := strconv.ParseFloat(left, 64)
+	r, rErr := strconv.ParseFloat(right, 64)
+	if lErr == nil && rErr == nil {
+		switch {
+		case l < r:
+			return -1
+		case l > r:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(left, right)
+}
+
+// PaginateList returns a subset of the result based on the pagination criteria as well as the total number of pages.
+// Page numbers start at 1; a page of 0 or less is treated as the first page.
+func PaginateList(list []types.APIObject, p Pagination) ([]types.APIObject, int) {
+	if p.PageSize <= 0 {
+		return list, 0
+	}
+	page := p.Page - 1
+	if page < 0 {
+		page = 0
+	}
+	pages := len(list) / p.PageSize
+	if len(list)%p.PageSize != 0 {
+		pages++
+	}
+	offset := p.PageSize * page
+	if offset > len(list) {
+		return []types.APIObject{}, pages
+	}
+	if p.PageSize > len(list)-offset {
+		return list[offset:], pages
+	}
+	return list[offset : offset+p.PageSize], pages
+}
+
+// projectedFields are always kept when projecting objects, since they identify the object.
+var projectedFields = [][]string{
+	{"apiVersion"},
+	{"kind"},
+	{"metadata", "name"},
+	{"metadata", "namespace"},
+}
+
+// ProjectList returns copies of the objects that contain only the given field paths.
+// If a slice is encountered along a path, the remainder of the path is projected from each element of the slice.
+// The original objects are not modified.
+func ProjectList(list []types.APIObject, fields [][]string) []types.APIObject {
+	if len(fields) == 0 {
+		return list
+	}
+
+	fields = append(append([][]string{}, projectedFields...), fields...)
+	result := make([]types.APIObject, 0, len(list))
+	for _, obj := range list {
+		src := obj.Data()
+		dst := map[string]interface{}{}
+		for _, field := range fields {
+			project(dst, src, field)
+		}
+		projected := obj
+		projected.Object = &unstructured.Unstructured{Object: dst}
+		result = append(result, projected)
+	}
+	return result
+}
+
+// project copies the value at the field path from src into dst.
+func project(dst, src map[string]interface{}, field []string) {
+	val, ok := src[field[0]]
+	if !ok {
+		return
+	}
+	if len(field) == 1 {
+		dst[field[0]] = val
+		return
+	}
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		child, ok := dst[field[0]].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			dst[field[0]] = child
+		}
+		project(child, v, field[1:])
+	case []interface{}:
+		items, ok := dst[field[0]].([]interface{})
+		if !ok || len(items) != len(v) {
+			items = make([]interface{}, len(v))
+			for i := range items {
+				items[i] = map[string]interface{}{}
+			}
+			dst[field[0]] = items
+		}
+		for i, item := range v {
+			src, srcOK := item.(map[string]interface{})
+			dst, dstOK := items[i].(map[string]interface{})
+			if srcOK && dstOK {
+				project(dst, src, field[1:])
+			}
+		}
+	}
+}
+
+// ExcludeList returns copies of the objects with the given field paths removed.
+// Only the maps along each removed path are copied, so the original objects are not modified.
+func ExcludeList(list []types.APIObject, fields [][]string) []types.APIObject {
+	if len(fields) == 0 {
+		return list
+	}
+	result := make([]types.APIObject, 0, len(list))
+	for _, obj := range list {
+		result = append(result, ExcludeObject(obj, fields))
+	}
+	return result
+}
+
+// ExcludeObject returns a copy of the object with the given field paths removed.
+func ExcludeObject(obj types.APIObject, fields [][]string) types.APIObject {
+	if len(fields) == 0 || obj.Object == nil {
+		return obj
+	}
+	data := obj.Data()
+	for _, field := range fields {
+		data = exclude(data, field)
+	}
+	obj.Object = &unstructured.Unstructured{Object: data}
+	return obj
+}
+
+// exclude returns a copy of obj without the value at the field path, sharing every map that is not on the path.
+func exclude(obj map[string]interface{}, field []string) map[string]interface{} {
+	val, ok := obj[field[0]]
+	if !ok {
+		return obj
+	}
+	result := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		result[k] = v
+	}
+	if len(field) == 1 {
+		delete(result, field[0])
+		return result
+	}
+	child, ok := val.(map[string]interface{})
+	if !ok {
+		return obj
+	}
+	result[field[0]] = exclude(child, field[1:])
+	return result
+}
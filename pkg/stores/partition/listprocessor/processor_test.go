@@ -0,0 +1,258 @@
+package listprocessor
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newObject(name string, labels map[string]interface{}, containers ...string) types.APIObject {
+	var cs []interface{}
+	for _, c := range containers {
+		cs = append(cs, map[string]interface{}{"name": c})
+	}
+	return types.APIObject{
+		Type: "pod",
+		ID:   name,
+		Object: &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":   name,
+					"labels": labels,
+				},
+				"spec": map[string]interface{}{
+					"containers": cs,
+				},
+			},
+		},
+	}
+}
+
+func names(list []types.APIObject) []string {
+	var result []string
+	for _, obj := range list {
+		result = append(result, obj.Name())
+	}
+	return result
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []Filter
+	}{
+		{
+			name:  "single filter",
+			query: "filter=metadata.name=foo",
+			want:  []Filter{{field: []string{"metadata", "name"}, match: "foo"}},
+		},
+		{
+			name:  "comma separated and repeated filters",
+			query: "filter=metadata.name=foo,metadata.namespace=bar&filter=spec.replicas=1",
+			want: []Filter{
+				{field: []string{"metadata", "name"}, match: "foo"},
+				{field: []string{"metadata", "namespace"}, match: "bar"},
+				{field: []string{"spec", "replicas"}, match: "1"},
+			},
+		},
+		{
+			name:  "bracketed key containing dots",
+			query: "filter=" + url.QueryEscape("metadata.labels[app.kubernetes.io/name]=web"),
+			want:  []Filter{{field: []string{"metadata", "labels", "app.kubernetes.io/name"}, match: "web"}},
+		},
+		{
+			name:  "malformed filter is ignored",
+			query: "filter=metadata.name",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := &types.APIRequest{
+				Request: &http.Request{URL: &url.URL{RawQuery: test.query}},
+			}
+			assert.Equal(t, test.want, ParseQuery(req).Filters)
+		})
+	}
+}
+
+func TestFilterList(t *testing.T) {
+	list := []types.APIObject{
+		newObject("a", map[string]interface{}{"app": "web"}, "nginx"),
+		newObject("b", map[string]interface{}{"app": "db"}, "postgres", "sidecar"),
+		newObject("c", nil, "sidecar"),
+	}
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    []string
+	}{
+		{
+			name: "no filters returns everything",
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:    "match label",
+			filters: []Filter{{field: []string{"metadata", "labels", "app"}, match: "db"}},
+			want:    []string{"b"},
+		},
+		{
+			name:    "match any element of a slice",
+			filters: []Filter{{field: []string{"spec", "containers", "name"}, match: "sidecar"}},
+			want:    []string{"b", "c"},
+		},
+		{
+			name: "all filters must match",
+			filters: []Filter{
+				{field: []string{"spec", "containers", "name"}, match: "sidecar"},
+				{field: []string{"metadata", "labels", "app"}, match: "db"},
+			},
+			want: []string{"b"},
+		},
+		{
+			name:    "missing field does not match",
+			filters: []Filter{{field: []string{"spec", "nodeName"}, match: "node1"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, names(FilterList(list, test.filters)))
+		})
+	}
+}
+
+func TestSortList(t *testing.T) {
+	newSortable := func(name, namespace string, replicas int64) types.APIObject {
+		return types.APIObject{
+			Object: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      name,
+						"namespace": namespace,
+					},
+					"spec": map[string]interface{}{
+						"replicas": replicas,
+					},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "sort by name",
+			query: "sort=metadata.name",
+			want:  []string{"a", "b", "c", "d"},
+		},
+		{
+			name:  "sort by name descending",
+			query: "sort=metadata.name&order=desc",
+			want:  []string{"d", "c", "b", "a"},
+		},
+		{
+			name:  "numeric values sort numerically",
+			query: "sort=spec.replicas",
+			want:  []string{"c", "b", "d", "a"},
+		},
+		{
+			name:  "secondary sort key breaks ties",
+			query: "sort=metadata.namespace,-metadata.name",
+			want:  []string{"c", "a", "d", "b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := []types.APIObject{
+				newSortable("b", "y", 2),
+				newSortable("a", "x", 10),
+				newSortable("d", "y", 3),
+				newSortable("c", "x", 1),
+			}
+			req := &types.APIRequest{
+				Request: &http.Request{URL: &url.URL{RawQuery: test.query}},
+			}
+			assert.Equal(t, test.want, names(SortList(list, ParseQuery(req).Sort)))
+		})
+	}
+}
+
+func TestPaginateList(t *testing.T) {
+	list := []types.APIObject{
+		newObject("a", nil),
+		newObject("b", nil),
+		newObject("c", nil),
+		newObject("d", nil),
+		newObject("e", nil),
+	}
+	tests := []struct {
+		name       string
+		pagination Pagination
+		want       []string
+		wantPages  int
+	}{
+		{
+			name: "no page size returns everything",
+			want: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:       "first page",
+			pagination: Pagination{PageSize: 2, Page: 1},
+			want:       []string{"a", "b"},
+			wantPages:  3,
+		},
+		{
+			name:       "page defaults to first",
+			pagination: Pagination{PageSize: 2},
+			want:       []string{"a", "b"},
+			wantPages:  3,
+		},
+		{
+			name:       "last partial page",
+			pagination: Pagination{PageSize: 2, Page: 3},
+			want:       []string{"e"},
+			wantPages:  3,
+		},
+		{
+			name:       "page past the end",
+			pagination: Pagination{PageSize: 2, Page: 4},
+			wantPages:  3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, pages := PaginateList(list, test.pagination)
+			assert.Equal(t, test.want, names(got))
+			assert.Equal(t, test.wantPages, pages)
+		})
+	}
+}
+
+func TestProjectList(t *testing.T) {
+	list := []types.APIObject{
+		newObject("a", map[string]interface{}{"app": "web"}, "nginx", "sidecar"),
+	}
+	req := &types.APIRequest{
+		Request: &http.Request{URL: &url.URL{RawQuery: "fields=metadata.labels,spec.containers.name"}},
+	}
+	got := ProjectList(list, ParseQuery(req).Fields)
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   "a",
+			"labels": map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "nginx"},
+				map[string]interface{}{"name": "sidecar"},
+			},
+		},
+	}, map[string]interface{}(got[0].Data()))
+	assert.Contains(t, list[0].Data(), "spec", "original object must not be modified")
+}
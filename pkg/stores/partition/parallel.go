@@ -2,12 +2,35 @@ package partition
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	tokenKeyEnv      = "CATTLE_CONTINUE_TOKEN_KEY"
+	listStateVersion = 1
+)
+
+var (
+	tokenKeyOnce  sync.Once
+	tokenKeyBytes []byte
 )
 
 // Partition represents a named grouping of kubernetes resources,
@@ -27,9 +50,38 @@ type ParallelPartitionLister struct {
 	// Partitions is the set of partitions that will be concurrently queried.
 	Partitions []Partition
 
-	state    *listState
-	revision string
-	err      error
+	// Backoff controls how transient errors from the Lister are retried. A zero Backoff disables retries.
+	Backoff wait.Backoff
+
+	// SkipError is called with the error of a partition once its retries are exhausted. If it returns true the
+	// partition is skipped as if it were empty rather than failing the list. Nil fails the list on every error.
+	SkipError func(partition Partition, err error) bool
+
+	// Dynamic enables merging partitions that became visible while a list was being walked with continue tokens.
+	// The continue token then records every partition that has been completely listed, so it grows with the
+	// number of partitions.
+	Dynamic bool
+
+	// Metrics records the lister's metrics under the resource being listed.
+	Metrics metrics.MetricLogger
+
+	// PartitionLimit is the maximum number of objects returned from a single partition in one page, so that a
+	// large partition cannot fill the whole page. Partitions cut short are listed again in a later round, after
+	// every partition has had its turn. Zero means no limit.
+	PartitionLimit int
+
+	state          *listState
+	round          bool
+	positions      map[string]partitionPosition
+	deferred       []partitionPosition
+	partitionLimit int
+	revision       string
+	err            error
+	merged         []string
+	changed        bool
+	resume         *listState
+	finished       bool // set once the page is known to be complete, so a deadline passing afterwards is harmless
+	timedOut       bool
 }
 
 // PartitionLister lists objects for one partition.
@@ -50,11 +102,18 @@ func (p *ParallelPartitionLister) Continue() string {
 	if p.state == nil {
 		return ""
 	}
-	bytes, err := json.Marshal(p.state)
-	if err != nil {
-		return ""
-	}
-	return base64.StdEncoding.EncodeToString(bytes)
+	return p.state.encode()
+}
+
+// TimedOut reports whether the list was cut short because its context deadline passed.
+// The continue token then resumes the list after the last complete page that was returned.
+func (p *ParallelPartitionLister) TimedOut() bool {
+	return p.timedOut
+}
+
+// PartitionsChanged reports whether, in dynamic mode, partitions were added or removed since the list was started.
+func (p *ParallelPartitionLister) PartitionsChanged() bool {
+	return p.changed
 }
 
 func indexOrZero(partitions []Partition, name string) int {
@@ -72,22 +131,54 @@ func indexOrZero(partitions []Partition, name string) int {
 // List returns a stream of objects up to the requested limit.
 // If the continue token is not empty, it decodes it and returns the stream
 // starting at the indicated marker.
+// The lister may be reused for subsequent pages; each call resets the continuation state.
 func (p *ParallelPartitionLister) List(ctx context.Context, limit int, resume string) (<-chan []types.APIObject, error) {
 	var state listState
 	if resume != "" {
-		bytes, err := base64.StdEncoding.DecodeString(resume)
+		var err error
+		state, err = decodeListState(resume)
 		if err != nil {
 			return nil, err
 		}
-		if err := json.Unmarshal(bytes, &state); err != nil {
-			return nil, err
-		}
 
 		if state.Limit > 0 {
 			limit = state.Limit
 		}
 	}
+	p.partitionLimit = p.PartitionLimit
+	if state.PartitionLimit > 0 {
+		p.partitionLimit = state.PartitionLimit
+	}
 
+	if resume != "" {
+		p.Metrics.IncPartitionContinueRequests()
+	}
+	p.Metrics.RecordPartitionsPerRequest(len(p.Partitions))
+
+	p.state = nil
+	p.err = nil
+	p.merged = nil
+	p.changed = false
+	p.resume = nil
+	p.finished = false
+	p.timedOut = false
+	p.deferred = append([]partitionPosition{}, state.Deferred...)
+	p.round = len(state.Pending) > 0
+	if p.round {
+		p.positions = p.roundPartitions(state.Pending)
+	} else {
+		p.positions = map[string]partitionPosition{}
+		if state.PartitionName != "" {
+			p.positions[state.PartitionName] = partitionPosition{
+				Name:     state.PartitionName,
+				Continue: state.Continue,
+				Offset:   state.Offset,
+			}
+		}
+		if p.Dynamic && resume != "" {
+			p.mergePartitions(state)
+		}
+	}
 	result := make(chan []types.APIObject)
 	go p.feeder(ctx, state, limit, result)
 	return result, nil
@@ -96,6 +187,9 @@ func (p *ParallelPartitionLister) List(ctx context.Context, limit int, resume st
 // listState is a representation of the continuation point for a partial list.
 // It is encoded as the continue token in the returned response.
 type listState struct {
+	// Version is the version of the token format.
+	Version int `json:"v"`
+
 	// Revision is the resourceVersion for the List object.
 	Revision string `json:"r,omitempty"`
 
@@ -111,6 +205,226 @@ type listState struct {
 
 	// Limit is the maximum number of items from all partitions to return in the result.
 	Limit int `json:"l,omitempty"`
+
+	// Listed is the set of partitions that have been completely listed, recorded only in dynamic mode.
+	Listed []string `json:"d,omitempty"`
+
+	// Merged is the set of partitions that appeared behind the current partition and are yet to be listed,
+	// recorded only in dynamic mode.
+	Merged []string `json:"m,omitempty"`
+
+	// PartitionLimit is the maximum number of objects from a single partition to return in the result.
+	PartitionLimit int `json:"n,omitempty"`
+
+	// Pending is the set of partitions left to list in the current round, once every partition has been
+	// listed up to the partition limit. The first is resumed in place of PartitionName.
+	Pending []partitionPosition `json:"q,omitempty"`
+
+	// Deferred is the set of partitions that were cut short by the partition limit, to be listed in the next round.
+	Deferred []partitionPosition `json:"f,omitempty"`
+}
+
+// partitionPosition is the point to resume listing a single partition from.
+type partitionPosition struct {
+	Name     string `json:"p"`
+	Continue string `json:"c,omitempty"`
+	Offset   int    `json:"o,omitempty"`
+}
+
+// encode returns the continue token representation of the list state.
+// The token is the base64 encoded state followed by a dot and the base64 encoded HMAC of the state.
+func (s *listState) encode() string {
+	s.Version = listStateVersion
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(bytes) + "." + base64.StdEncoding.EncodeToString(signListState(bytes))
+}
+
+// decodeListState parses a continue token into a list state.
+// Tokens that were not signed by this server, have been modified, or were encoded by an older version
+// are rejected with a 410 Gone error so that clients restart the list from the beginning.
+func decodeListState(token string) (listState, error) {
+	var state listState
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return state, expiredToken("continue token is not signed")
+	}
+	bytes, err := base64.StdEncoding.DecodeString(token[:i])
+	if err != nil {
+		return state, expiredToken("continue token is malformed")
+	}
+	mac, err := base64.StdEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, signListState(bytes)) {
+		return state, expiredToken("continue token signature is invalid")
+	}
+	if err := json.Unmarshal(bytes, &state); err != nil {
+		return state, expiredToken("continue token is malformed")
+	}
+	if state.Version != listStateVersion {
+		return state, expiredToken("continue token version is not supported")
+	}
+	return state, nil
+}
+
+func expiredToken(message string) error {
+	return apierrors.NewResourceExpired(message + ", restart the list without a continue token")
+}
+
+// signListState returns the HMAC of an encoded list state.
+func signListState(bytes []byte) []byte {
+	mac := hmac.New(sha256.New, tokenKey())
+	mac.Write(bytes)
+	return mac.Sum(nil)
+}
+
+// tokenKey returns the key used to sign continue tokens.
+// Servers behind a load balancer must share the key through the CATTLE_CONTINUE_TOKEN_KEY environment variable;
+// otherwise a random key is generated and tokens do not survive a restart.
+func tokenKey() []byte {
+	tokenKeyOnce.Do(func() {
+		if key := os.Getenv(tokenKeyEnv); key != "" {
+			tokenKeyBytes = []byte(key)
+			return
+		}
+		tokenKeyBytes = make([]byte, 32)
+		if _, err := rand.Read(tokenKeyBytes); err != nil {
+			logrus.Fatalf("failed to generate continue token key: %v", err)
+		}
+	})
+	return tokenKeyBytes
+}
+
+// mergePartitions reorders the partitions for a resumed dynamic list.
+// Partitions that were already listed are dropped. The partition being resumed is listed first, followed by any
+// partitions that are ordered before it but have not been listed, since they became visible after the list passed
+// their position, followed by the remaining partitions in their usual order.
+func (p *ParallelPartitionLister) mergePartitions(state listState) {
+	var (
+		listed  = map[string]bool{}
+		merged  = map[string]bool{}
+		present int
+		cursor  []Partition
+		behind  []Partition
+		rest    []Partition
+	)
+	for _, name := range state.Listed {
+		listed[name] = true
+	}
+	for _, name := range state.Merged {
+		merged[name] = true
+	}
+
+	// partitions are behind the cursor until it is reached, if it is still present
+	beforeCursor := false
+	for _, partition := range p.Partitions {
+		if partition.Name() == state.PartitionName {
+			beforeCursor = true
+		}
+	}
+
+	for _, partition := range p.Partitions {
+		name := partition.Name()
+		switch {
+		case listed[name]:
+			present++
+		case name == state.PartitionName:
+			cursor = append(cursor, partition)
+			beforeCursor = false
+		case beforeCursor:
+			if !merged[name] {
+				p.changed = true
+			}
+			p.merged = append(p.merged, name)
+			behind = append(behind, partition)
+		default:
+			rest = append(rest, partition)
+		}
+	}
+	if present < len(listed) || (state.PartitionName != "" && len(cursor) == 0) {
+		p.changed = true
+	}
+
+	p.Partitions = append(append(cursor, behind...), rest...)
+}
+
+// roundPartitions restricts the partitions to those pending in the current round, in the order they were deferred,
+// and returns the position to resume each one from. Partitions that are no longer present are dropped.
+func (p *ParallelPartitionLister) roundPartitions(pending []partitionPosition) map[string]partitionPosition {
+	present := map[string]Partition{}
+	for _, partition := range p.Partitions {
+		present[partition.Name()] = partition
+	}
+	positions := map[string]partitionPosition{}
+	var partitions []Partition
+	for _, pos := range pending {
+		if partition, ok := present[pos.Name]; ok {
+			partitions = append(partitions, partition)
+			positions[pos.Name] = pos
+		}
+	}
+	p.Partitions = partitions
+	return positions
+}
+
+// cutState returns the state for a list truncated at the partition at the given index, which resumes from pos.
+// Partitions deferred so far by the partition limit are carried over to the next round.
+func (p *ParallelPartitionLister) cutState(state listState, index int, pos partitionPosition, limit int) *listState {
+	next := listState{
+		Revision:       state.Revision,
+		Limit:          limit,
+		PartitionLimit: p.partitionLimit,
+		Listed:         state.Listed,
+		Deferred:       append([]partitionPosition{}, p.deferred...),
+	}
+	if !p.round {
+		next.PartitionName = pos.Name
+		next.Continue = pos.Continue
+		next.Offset = pos.Offset
+		return p.nextState(next, index)
+	}
+	next.Pending = []partitionPosition{pos}
+	for _, partition := range p.Partitions[index+1:] {
+		next.Pending = append(next.Pending, p.positions[partition.Name()])
+	}
+	return &next
+}
+
+// roundState returns the state for the next round once every partition has been listed, or nil if no partition was
+// cut short by the partition limit.
+func (p *ParallelPartitionLister) roundState(state listState, limit int) *listState {
+	if len(p.deferred) == 0 {
+		return nil
+	}
+	return &listState{
+		Revision:       state.Revision,
+		Limit:          limit,
+		PartitionLimit: p.partitionLimit,
+		Pending:        p.deferred,
+	}
+}
+
+// nextState returns the state for a list truncated at the partition at the given index,
+// recording the listed and merged partitions if the lister is dynamic.
+func (p *ParallelPartitionLister) nextState(state listState, index int) *listState {
+	if !p.Dynamic {
+		return &state
+	}
+	listed := append([]string{}, state.Listed...)
+	done := map[string]bool{}
+	for _, partition := range p.Partitions[:index] {
+		listed = append(listed, partition.Name())
+		done[partition.Name()] = true
+	}
+	state.Listed = listed
+	state.Merged = nil
+	for _, name := range p.merged {
+		if !done[name] {
+			state.Merged = append(state.Merged, name)
+		}
+	}
+	return &state
 }
 
 // feeder spawns a goroutine to list resources in each partition and feeds the
@@ -122,26 +436,40 @@ type listState struct {
 // request.
 func (p *ParallelPartitionLister) feeder(ctx context.Context, state listState, limit int, result chan []types.APIObject) {
 	var (
-		sem      = semaphore.NewWeighted(p.Concurrency)
-		capacity = limit
+		sem = semaphore.NewWeighted(p.Concurrency)
+		// capacity is read by the feeder loop while the goroutine holding the turn writes it
+		capacity = int64(limit)
 		last     chan struct{}
 	)
 
+	parent := ctx
 	eg, ctx := errgroup.WithContext(ctx)
 	defer func() {
 		err := eg.Wait()
 		if p.err == nil {
 			p.err = err
 		}
+		// If the deadline passed after some pages were returned, hand back what was listed so far with a
+		// continue token for the rest instead of failing the whole list.
+		if !p.finished && errors.Is(parent.Err(), context.DeadlineExceeded) {
+			if p.state == nil && p.resume != nil {
+				p.state = p.resume
+				p.err = nil
+				p.timedOut = true
+			} else if p.state == nil {
+				p.err = apierrors.NewTimeoutError("timed out listing partitions", 0)
+			}
+		}
 		close(result)
 	}()
 
 	for i := indexOrZero(p.Partitions, state.PartitionName); i < len(p.Partitions); i++ {
-		if capacity <= 0 || isDone(ctx) {
+		if atomic.LoadInt64(&capacity) <= 0 || isDone(ctx) {
 			break
 		}
 
 		var (
+			index     = i
 			partition = p.Partitions[i]
 			tickets   = int64(1)
 			turn      = last
@@ -154,25 +482,31 @@ func (p *ParallelPartitionLister) feeder(ctx context.Context, state listState, l
 		// state.Revision is decoded from the continue token, there won't be a revision on the first request.
 		if state.Revision == "" {
 			// don't have a revision yet so grab all tickets to set a revision
-			tickets = 3
+			tickets = p.Concurrency
 		}
+		waitStart := time.Now()
 		if err := sem.Acquire(ctx, tickets); err != nil {
 			p.err = err
 			break
 		}
+		p.Metrics.RecordPartitionSemaphoreWaitTime(float64(time.Since(waitStart).Milliseconds()))
 
 		// make state local for this partition
 		state := state
+		pos := p.positions[partition.Name()]
 		eg.Go(func() error {
 			defer sem.Release(tickets)
 			defer close(next)
 
+			quota := p.partitionLimit
 			for {
-				cont := ""
-				if partition.Name() == state.PartitionName {
-					cont = state.Continue
+				cont := pos.Continue
+				listStart := time.Now()
+				list, err := p.listWithRetry(ctx, partition, cont, state.Revision, limit)
+				p.Metrics.RecordPartitionListTime(float64(time.Since(listStart).Milliseconds()))
+				if err != nil && p.SkipError != nil && ctx.Err() == nil && p.SkipError(partition, err) {
+					list, err = types.APIObjectList{}, nil
 				}
-				list, err := p.Lister(ctx, partition, cont, state.Revision, limit)
 				if err != nil {
 					return err
 				}
@@ -191,37 +525,61 @@ func (p *ParallelPartitionLister) feeder(ctx context.Context, state listState, l
 				}
 
 				// We have already seen the first objects in the list, truncate up to the offset.
-				if state.PartitionName == partition.Name() && state.Offset > 0 && state.Offset < len(list.Objects) {
-					list.Objects = list.Objects[state.Offset:]
+				objects, skipped := list.Objects, 0
+				if pos.Offset > 0 && pos.Offset < len(objects) {
+					objects, skipped = objects[pos.Offset:], pos.Offset
 				}
 
 				// Case 1: the capacity has been reached across all goroutines but the list is still only partial,
 				// so save the state so that the next page can be requested later.
-				if len(list.Objects) > capacity {
-					result <- list.Objects[:capacity]
+				remaining := int(atomic.LoadInt64(&capacity))
+				if len(objects) > remaining && (p.partitionLimit <= 0 || remaining <= quota) {
+					p.finished = true
+					if !send(ctx, result, objects[:remaining]) {
+						return ctx.Err()
+					}
 					// save state to redo this list at this offset
-					p.state = &listState{
-						Revision:      list.Revision,
-						PartitionName: partition.Name(),
-						Continue:      cont,
-						Offset:        capacity,
-						Limit:         limit,
+					p.state = p.cutState(state, index, partitionPosition{
+						Name:     partition.Name(),
+						Continue: cont,
+						Offset:   skipped + remaining,
+					}, limit)
+					atomic.StoreInt64(&capacity, 0)
+					p.Metrics.IncPartitionTruncatedLists()
+					return nil
+				}
+
+				// Case 2: the partition limit has been reached but the partition is still only partial,
+				// so defer the rest of it to the next round and move on to the next partition.
+				if p.partitionLimit > 0 && len(objects) >= quota && (len(objects) > quota || list.Continue != "") {
+					if !send(ctx, result, objects[:quota]) {
+						return ctx.Err()
+					}
+					atomic.AddInt64(&capacity, -int64(quota))
+					deferred := partitionPosition{Name: partition.Name(), Continue: cont, Offset: skipped + quota}
+					if quota == len(objects) {
+						deferred = partitionPosition{Name: partition.Name(), Continue: list.Continue}
 					}
-					capacity = 0
+					p.deferred = append(p.deferred, deferred)
+					p.partitionDone(state, index, limit)
 					return nil
 				}
-				result <- list.Objects
-				capacity -= len(list.Objects)
-				// Case 2: all objects have been returned, we are done.
+
+				if !send(ctx, result, objects) {
+					return ctx.Err()
+				}
+				atomic.AddInt64(&capacity, -int64(len(objects)))
+				quota -= len(objects)
+				// Case 3: all objects have been returned, we are done.
 				if list.Continue == "" {
+					p.partitionDone(state, index, limit)
 					return nil
 				}
-				// Case 3: we started at an offset and truncated the list to skip the objects up to the offset.
+				pos = partitionPosition{Name: partition.Name(), Continue: list.Continue}
+				p.resume = p.cutState(state, index, pos, limit)
+				// Case 4: we started at an offset and truncated the list to skip the objects up to the offset.
 				// We're not yet up to capacity and have not retrieved every object,
 				// so loop again and get more data.
-				state.Continue = list.Continue
-				state.PartitionName = partition.Name()
-				state.Offset = 0
 			}
 		})
 	}
@@ -229,6 +587,31 @@ func (p *ParallelPartitionLister) feeder(ctx context.Context, state listState, l
 	p.err = eg.Wait()
 }
 
+// partitionDone records that the partition at the given index has been listed for this page.
+// Once every partition has been listed, the list is complete unless partitions were deferred to another round.
+func (p *ParallelPartitionLister) partitionDone(state listState, index, limit int) {
+	if index+1 == len(p.Partitions) {
+		p.finished = true
+		p.state = p.roundState(state, limit)
+		return
+	}
+	name := p.Partitions[index+1].Name()
+	pos := p.positions[name]
+	pos.Name = name
+	p.resume = p.cutState(state, index+1, pos, limit)
+}
+
+// send writes the objects to the result channel unless the context is done first, for example because the
+// client went away and nothing will read the channel again. It reports whether the objects were sent.
+func send(ctx context.Context, result chan<- []types.APIObject, objects []types.APIObject) bool {
+	select {
+	case result <- objects:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func waitForTurn(ctx context.Context, turn chan struct{}) {
 	if turn == nil {
 		return
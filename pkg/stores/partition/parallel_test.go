@@ -0,0 +1,258 @@
+package partition
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type testPartition string
+
+func (t testPartition) Name() string {
+	return string(t)
+}
+
+// pagedLister returns a lister that serves pages of the given size forever, so the list never finishes on its own.
+func pagedLister(size int) PartitionLister {
+	return func(ctx context.Context, partition Partition, cont string, revision string, limit int) (types.APIObjectList, error) {
+		page, _ := strconv.Atoi(cont)
+		objects := make([]types.APIObject, size)
+		for i := range objects {
+			objects[i] = types.APIObject{ID: partition.Name() + "-" + strconv.Itoa(page*size+i)}
+		}
+		return types.APIObjectList{
+			Revision: "1",
+			Continue: strconv.Itoa(page + 1),
+			Objects:  objects,
+		}, nil
+	}
+}
+
+// assertNoLeaks waits for the number of goroutines to return to the baseline.
+func assertNoLeaks(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "goroutines leaked")
+}
+
+func TestListCancelDoesNotLeak(t *testing.T) {
+	tests := []struct {
+		name string
+		read int
+	}{
+		{
+			name: "cancel before reading",
+		},
+		{
+			name: "cancel after the first page",
+			read: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			baseline := runtime.NumGoroutine()
+			lister := ParallelPartitionLister{
+				Lister:      pagedLister(10),
+				Concurrency: 3,
+				Partitions:  []Partition{testPartition("a"), testPartition("b"), testPartition("c"), testPartition("d")},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result, err := lister.List(ctx, 1000000, "")
+			assert.NoError(t, err)
+			for i := 0; i < test.read; i++ {
+				<-result
+			}
+			// the client went away: nothing reads the result channel again
+			cancel()
+
+			assertNoLeaks(t, baseline)
+		})
+	}
+}
+
+func TestListTimeoutReturnsContinue(t *testing.T) {
+	lister := ParallelPartitionLister{
+		Lister:      pagedLister(10),
+		Concurrency: 3,
+		Partitions:  []Partition{testPartition("a"), testPartition("b")},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := lister.List(ctx, 1000000, "")
+	assert.NoError(t, err)
+
+	var count int
+	for objects := range result {
+		count += len(objects)
+	}
+	assert.NoError(t, lister.Err())
+	assert.True(t, lister.TimedOut())
+	assert.NotEmpty(t, lister.Continue())
+	assert.Greater(t, count, 0)
+}
+
+// sizedLister returns a lister that serves the given number of objects from each partition, in pages of the requested limit.
+func sizedLister(sizes map[string]int) PartitionLister {
+	return func(ctx context.Context, partition Partition, cont string, revision string, limit int) (types.APIObjectList, error) {
+		start, _ := strconv.Atoi(cont)
+		end := start + limit
+		if end > sizes[partition.Name()] {
+			end = sizes[partition.Name()]
+		}
+		list := types.APIObjectList{Revision: "1"}
+		for i := start; i < end; i++ {
+			list.Objects = append(list.Objects, types.APIObject{ID: partition.Name() + "-" + strconv.Itoa(i)})
+		}
+		if end < sizes[partition.Name()] {
+			list.Continue = strconv.Itoa(end)
+		}
+		return list, nil
+	}
+}
+
+func TestListPartitionLimit(t *testing.T) {
+	sizes := map[string]int{"a": 25, "b": 3, "c": 6}
+	lister := ParallelPartitionLister{
+		Lister:         sizedLister(sizes),
+		Concurrency:    3,
+		Partitions:     []Partition{testPartition("a"), testPartition("b"), testPartition("c")},
+		PartitionLimit: 4,
+	}
+
+	var (
+		pages  [][]string
+		seen   = map[string]int{}
+		resume string
+	)
+	for len(pages) < 100 {
+		result, err := lister.List(context.Background(), 10, resume)
+		assert.NoError(t, err)
+		var page []string
+		for objects := range result {
+			for _, obj := range objects {
+				page = append(page, obj.ID)
+				seen[obj.ID]++
+			}
+		}
+		assert.NoError(t, lister.Err())
+		pages = append(pages, page)
+		resume = lister.Continue()
+		if resume == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"a-0", "a-1", "a-2", "a-3", "b-0", "b-1", "b-2", "c-0", "c-1", "c-2"}, pages[0])
+	assert.Len(t, seen, 34)
+	for id, count := range seen {
+		assert.Equal(t, 1, count, id)
+	}
+}
+
+func TestContinueTokens(t *testing.T) {
+	state := &listState{Revision: "10", PartitionName: "default", Continue: "abc", Offset: 2, Limit: 5}
+	token := state.encode()
+	decoded, err := decodeListState(token)
+	require.NoError(t, err)
+	assert.Equal(t, *state, decoded)
+
+	// sign returns a token of the state signed with key, regardless of its version.
+	sign := func(state listState, key []byte) string {
+		bytes, err := json.Marshal(state)
+		require.NoError(t, err)
+		mac := hmac.New(sha256.New, key)
+		mac.Write(bytes)
+		return base64.StdEncoding.EncodeToString(bytes) + "." + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	payload, mac, _ := strings.Cut(token, ".")
+	tampered := *state
+	tampered.PartitionName = "kube-system"
+	tamperedPayload, _, _ := strings.Cut(sign(tampered, tokenKey()), ".")
+	old := *state
+	old.Version = listStateVersion - 1
+
+	tests := map[string]string{
+		"unsigned":    payload,
+		"malformed":   "not base64." + mac,
+		"tampered":    tamperedPayload + "." + mac,
+		"wrong key":   sign(*state, []byte("another server")),
+		"old version": sign(old, tokenKey()),
+	}
+	for name, token := range tests {
+		_, err := decodeListState(token)
+		assert.True(t, apierrors.IsResourceExpired(err), "%s tokens are rejected as expired: %v", name, err)
+
+		lister := &ParallelPartitionLister{
+			Lister:      pagedLister(1),
+			Concurrency: 1,
+			Partitions:  []Partition{testPartition("default")},
+		}
+		_, err = lister.List(context.Background(), 1, token)
+		assert.True(t, apierrors.IsResourceExpired(err), "%s tokens fail the list: %v", name, err)
+	}
+}
+
+func TestListDynamicPartitions(t *testing.T) {
+	partitions := func(names ...string) []Partition {
+		var result []Partition
+		for _, name := range names {
+			result = append(result, testPartition(name))
+		}
+		return result
+	}
+	sizes := map[string]int{"a": 2, "b": 2, "c": 2, "d": 2}
+	// page lists a page of a fresh lister of the partitions, as each request of a list does
+	page := func(names []string, resume string) ([]string, *ParallelPartitionLister) {
+		lister := &ParallelPartitionLister{
+			Lister:      sizedLister(sizes),
+			Concurrency: 1,
+			Partitions:  partitions(names...),
+			Dynamic:     true,
+		}
+		result, err := lister.List(context.Background(), 3, resume)
+		require.NoError(t, err)
+		var ids []string
+		for objects := range result {
+			for _, obj := range objects {
+				ids = append(ids, obj.ID)
+			}
+		}
+		require.NoError(t, lister.Err())
+		return ids, lister
+	}
+
+	ids, lister := page([]string{"a", "c"}, "")
+	assert.Equal(t, []string{"a-0", "a-1", "c-0"}, ids)
+	assert.False(t, lister.PartitionsChanged())
+
+	ids, lister = page([]string{"a", "b", "c", "d"}, lister.Continue())
+	assert.Equal(t, []string{"c-1", "b-0", "b-1"}, ids, "a partition that appeared behind the list is merged after the resumed one")
+	assert.True(t, lister.PartitionsChanged())
+
+	ids, lister = page([]string{"a", "b", "c", "d"}, lister.Continue())
+	assert.Equal(t, []string{"d-0", "d-1"}, ids, "listed partitions are not listed again")
+	assert.False(t, lister.PartitionsChanged(), "merged partitions are only reported once")
+	assert.Empty(t, lister.Continue())
+
+	ids, lister = page([]string{"a", "c"}, "")
+	_, lister = page([]string{"c"}, lister.Continue())
+	assert.Equal(t, []string{"a-0", "a-1", "c-0"}, ids)
+	assert.True(t, lister.PartitionsChanged(), "removed partitions are reported")
+}
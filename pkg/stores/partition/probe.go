@@ -0,0 +1,86 @@
+package partition
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	probeConcurrency = 10
+	probeCacheSize   = 1000
+	probeCacheTTL    = 10 * time.Second
+)
+
+// probeCache returns the store's cache of probe results, creating it on first use.
+// It remembers whether a partition held any objects when it was last probed, keyed by schema, by the request
+// and by the partition. Results are kept briefly, so a partition that gains objects is listed again within the TTL.
+func (s *Store) probeCache() *cache.LRUExpireCache {
+	s.probeOnce.Do(func() {
+		s.probes = cache.NewLRUExpireCache(probeCacheSize)
+	})
+	return s.probes
+}
+
+// skipEmpty returns the partitions which hold at least one object, in their original order.
+// Each partition is probed with a list of a single object, so that partitions with nothing to return do not
+// take a semaphore ticket or a lister goroutine. Partitions whose probe fails are kept, so that the list
+// itself reports the error.
+func (s *Store) skipEmpty(apiOp *types.APIRequest, schema *types.APISchema, partitions []Partition, opts *listprocessor.ListOptions) []Partition {
+	if len(partitions) <= 1 {
+		return partitions
+	}
+
+	var (
+		key   = schema.ID + "/" + listCacheKey(apiOp, nil)
+		empty = make([]bool, len(partitions))
+		sem   = semaphore.NewWeighted(probeConcurrency)
+		eg    errgroup.Group
+	)
+	for i, partition := range partitions {
+		index := i
+		partition := partition
+		partitionKey := fmt.Sprintf("%s/%+v", key, partition)
+		if cached, ok := s.probeCache().Get(partitionKey); ok {
+			empty[index] = cached.(bool)
+			continue
+		}
+		if err := sem.Acquire(apiOp.Context(), 1); err != nil {
+			break
+		}
+		eg.Go(func() error {
+			defer sem.Release(1)
+			isEmpty, err := s.probe(apiOp.Context(), apiOp, schema, partition, opts)
+			if err != nil {
+				return nil
+			}
+			empty[index] = isEmpty
+			s.probeCache().Add(partitionKey, isEmpty, probeCacheTTL)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	result := make([]Partition, 0, len(partitions))
+	for i, partition := range partitions {
+		if !empty[i] {
+			result = append(result, partition)
+		}
+	}
+	return result
+}
+
+// probe reports whether a partition holds no objects at the requested revision.
+func (s *Store) probe(ctx context.Context, apiOp *types.APIRequest, schema *types.APISchema, partition Partition, opts *listprocessor.ListOptions) (bool, error) {
+	list, err := s.listPartition(ctx, apiOp, schema, partition, "", opts.Revision, 1, opts.Revision != "")
+	if err != nil {
+		return false, err
+	}
+	return len(list.Objects) == 0 && list.Continue == "", nil
+}
@@ -0,0 +1,93 @@
+package partition
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	retryAttemptsEnv = "CATTLE_LIST_RETRY_ATTEMPTS"
+	retryJitterEnv   = "CATTLE_LIST_RETRY_JITTER"
+)
+
+// defaultBackoff returns the backoff used to retry partition lists.
+// The number of attempts and the jitter factor may be overridden with the CATTLE_LIST_RETRY_ATTEMPTS and
+// CATTLE_LIST_RETRY_JITTER environment variables; one attempt disables retries.
+func defaultBackoff() wait.Backoff {
+	backoff := wait.Backoff{
+		Steps:    3,
+		Duration: 100 * time.Millisecond,
+		Factor:   2,
+		Jitter:   0.1,
+		Cap:      2 * time.Second,
+	}
+	if setting := os.Getenv(retryAttemptsEnv); setting != "" {
+		attempts, err := strconv.Atoi(setting)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", retryAttemptsEnv, err)
+		} else {
+			backoff.Steps = attempts
+		}
+	}
+	if setting := os.Getenv(retryJitterEnv); setting != "" {
+		jitter, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			logrus.Debugf("could not parse %s environment variable, error: %v", retryJitterEnv, err)
+		} else {
+			backoff.Jitter = jitter
+		}
+	}
+	return backoff
+}
+
+// isTransient reports whether a list error is likely to succeed if the request is retried.
+func isTransient(err error) bool {
+	return apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsTimeout(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// listWithRetry calls the lister for one partition, retrying transient errors according to the lister's backoff.
+// Each call is traced as a single span covering every attempt.
+func (p *ParallelPartitionLister) listWithRetry(ctx context.Context, partition Partition, cont string, revision string, limit int) (types.APIObjectList, error) {
+	ctx, span := tracer.Start(ctx, "partition.ParallelPartitionLister.list", trace.WithAttributes(
+		attribute.String("partition", partition.Name()),
+		attribute.Int("limit", limit),
+		attribute.Bool("continue", cont != ""),
+	))
+
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		list, err := p.Lister(ctx, partition, cont, revision, limit)
+		if err == nil || backoff.Steps <= 1 || !isTransient(err) {
+			span.SetAttributes(
+				attribute.Int("items", len(list.Objects)),
+				attribute.Int("attempts", attempt),
+			)
+			endSpan(span, err)
+			return list, err
+		}
+		delay := backoff.Step()
+		logrus.Debugf("retrying list of partition %q in %v: %v", partition.Name(), delay, err)
+		select {
+		case <-ctx.Done():
+			endSpan(span, err)
+			return list, err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -4,12 +4,26 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/steve/pkg/writer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/cache"
 )
 
-const defaultLimit = 100000
+const (
+	defaultLimit        = 100000
+	defaultConcurrency  = 3
+	concurrencyHeader   = "X-Steve-List-Concurrency"
+	timeoutParam        = "timeout"
+	partitionLimitParam = "limitPerPartition"
+)
 
 // Partitioner is an interface for interacting with partitions.
 type Partitioner interface {
@@ -18,9 +32,52 @@ type Partitioner interface {
 	Store(apiOp *types.APIRequest, partition Partition) (types.Store, error)
 }
 
+// PartitionSorter may be implemented by a Partitioner to order the partitions of a list.
+// Partitions are listed in the order returned, so it decides which objects appear on the first page,
+// for example by listing a user's favorite namespaces first. Without it, partitions are listed in the order
+// returned by All.
+type PartitionSorter interface {
+	SortPartitions(apiOp *types.APIRequest, schema *types.APISchema, partitions []Partition) []Partition
+}
+
+// ListTransformer post-processes an object returned by a list or watch, for example to redact values or
+// to inject computed fields. It must return a modified copy rather than change the object it is given.
+type ListTransformer func(obj types.APIObject) types.APIObject
+
+// Options are the settings of a partition Store.
+type Options struct {
+	// Concurrency is the maximum number of partitions listed at once. Zero uses the default of 3.
+	Concurrency int64
+	// Timeout is the default time limit for a list request. If it passes, the objects listed so far are returned
+	// with a continue token for the rest. Zero means no limit unless the request sets the timeout parameter.
+	Timeout time.Duration
+	// ExcludeFields are the field paths stripped from listed and watched objects unless the request
+	// sets its own excludeFields parameter, e.g. metadata.managedFields.
+	ExcludeFields []string
+	// SkipEmptyPartitions probes every partition for a single object before a list and skips the partitions
+	// that hold none, which is faster when most of many partitions, such as namespaces, are empty.
+	SkipEmptyPartitions bool
+	// Transformers are applied in order to every listed and watched object, before filters and field selection.
+	Transformers []ListTransformer
+}
+
 // Store implements types.Store for partitions.
 type Store struct {
 	Partitioner Partitioner
+	Options
+
+	cacheOnce sync.Once
+	cache     *listCache
+	probeOnce sync.Once
+	probes    *cache.LRUExpireCache
+}
+
+// listCache returns the store's list cache, creating it on first use.
+func (s *Store) listCache() *listCache {
+	s.cacheOnce.Do(func() {
+		s.cache = newListCache()
+	})
+	return s.cache
 }
 
 func (s *Store) getStore(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) (types.Store, error) {
@@ -33,7 +90,13 @@ func (s *Store) getStore(apiOp *types.APIRequest, schema *types.APISchema, verb,
 }
 
 // Delete deletes an object from a store.
+// If the id is empty, every object matching the request's selectors and filters is deleted across all partitions,
+// and the object returned is a DeleteCollectionResult.
 func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if id == "" {
+		return s.deleteCollection(apiOp, schema)
+	}
+
 	target, err := s.getStore(apiOp, schema, "delete", id)
 	if err != nil {
 		return types.APIObject{}, err
@@ -52,8 +115,10 @@ func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string
 	return target.ByID(apiOp, schema, id)
 }
 
+// listPartition lists one page of objects from a single partition.
+// If pin is set, the first page is requested at exactly the given revision so that the list reflects a fixed snapshot.
 func (s *Store) listPartition(ctx context.Context, apiOp *types.APIRequest, schema *types.APISchema, partition Partition,
-	cont string, revision string, limit int) (types.APIObjectList, error) {
+	cont string, revision string, limit int, pin bool) (types.APIObjectList, error) {
 	store, err := s.Partitioner.Store(apiOp, partition)
 	if err != nil {
 		return types.APIObjectList{}, err
@@ -65,6 +130,10 @@ func (s *Store) listPartition(ctx context.Context, apiOp *types.APIRequest, sche
 	values := req.Request.URL.Query()
 	values.Set("continue", cont)
 	values.Set("revision", revision)
+	if pin && revision != "" && cont == "" {
+		values.Set("resourceVersion", revision)
+		values.Set("resourceVersionMatch", "Exact")
+	}
 	if limit > 0 {
 		values.Set("limit", strconv.Itoa(limit))
 	} else {
@@ -76,8 +145,39 @@ func (s *Store) listPartition(ctx context.Context, apiOp *types.APIRequest, sche
 }
 
 // List returns a list of objects across all applicable partitions.
-// If pagination parameters are used, it returns a segment of the list.
+// The labelSelector and fieldSelector parameters are passed to kubernetes with the list of every partition.
+// The store's Transformers are applied to each object as it is listed, before any filters.
+// If filter parameters are used, objects not matching the filters are dropped before the limit is applied.
+// If sort parameters are used, the objects from all partitions are sorted together before the limit is applied.
+// If pagination parameters are used, it returns a segment of the list: either the segment following the
+// continue token, or the requested page when a page size is given.
+// If partial=true is set, partitions that fail to list are skipped and reported in the response instead of failing the list.
+// If fields parameters are used, the returned objects contain only the requested field paths.
+// Fields in the store's ExcludeFields, or in the excludeFields parameter if set, are stripped from the returned objects.
+// If the store's Timeout or the timeout parameter passes before the list is complete, the objects listed so far
+// are returned with a continue token.
+// If dynamicpartitions=true is set, partitions that become visible while the list is walked with continue tokens
+// are merged into the remaining pages, and the response is flagged when the partition set changed.
+// If limitPerPartition is set, each page holds at most that many objects from any one partition, and the rest
+// of a partition that was cut short is returned after every other partition has been listed.
+// The total number of objects is reported whenever the complete list is known: always for sorted or paged
+// lists, and for continue-token lists only when they fit in a single response.
+// Sorted or paged lists are served from the list cache for a pinned revision, or for resourceVersion=0 at the
+// latest revision cached, unless cache=false is set. The Cache-Status header of the response tells which.
 func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	ctx, span := tracer.Start(apiOp.Context(), "partition.Store.List", trace.WithAttributes(
+		attribute.String("schema", schema.ID),
+	))
+	result, err := s.list(apiOp.WithContext(ctx), schema)
+	span.SetAttributes(
+		attribute.Int("items", len(result.Objects)),
+		attribute.Bool("continue", result.Continue != ""),
+	)
+	endSpan(span, err)
+	return result, err
+}
+
+func (s *Store) list(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
 	var (
 		result types.APIObjectList
 	)
@@ -86,18 +186,36 @@ func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.AP
 	if err != nil {
 		return result, err
 	}
+	if sorter, ok := s.Partitioner.(PartitionSorter); ok {
+		partitions = sorter.SortPartitions(apiOp, schema, partitions)
+	}
 
-	lister := ParallelPartitionLister{
-		Lister: func(ctx context.Context, partition Partition, cont string, revision string, limit int) (types.APIObjectList, error) {
-			return s.listPartition(ctx, apiOp, schema, partition, cont, revision, limit)
-		},
-		Concurrency: 3,
-		Partitions:  partitions,
+	opts := listprocessor.ParseQuery(apiOp)
+	if s.SkipEmptyPartitions {
+		partitions = s.skipEmpty(apiOp, schema, partitions, opts)
+	}
+
+	lister := s.newLister(apiOp, schema, partitions, opts)
+
+	if timeout := s.getTimeout(apiOp.Request, s.Timeout); timeout > 0 {
+		ctx, cancel := context.WithTimeout(apiOp.Context(), timeout)
+		defer cancel()
+		apiOp = apiOp.WithContext(ctx)
 	}
 
 	resume := apiOp.Request.URL.Query().Get("continue")
 	limit := getLimit(apiOp.Request)
+	trace.SpanFromContext(apiOp.Context()).SetAttributes(
+		attribute.Int("partitions", len(partitions)),
+		attribute.Int("limit", limit),
+		attribute.Bool("resume", resume != ""),
+	)
 
+	if len(opts.Sort.Fields) > 0 || opts.Pagination.PageSize > 0 {
+		return s.listComplete(apiOp, schema, &lister, opts, limit, resume)
+	}
+
+	lister.PartitionLimit = getPartitionLimit(apiOp.Request)
 	list, err := lister.List(apiOp.Context(), limit, resume)
 	if err != nil {
 		return result, err
@@ -106,12 +224,210 @@ func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.AP
 	for items := range list {
 		result.Objects = append(result.Objects, items...)
 	}
+	result.Objects = s.shape(result.Objects, opts)
 
 	result.Revision = lister.Revision()
 	result.Continue = lister.Continue()
+	if lister.PartitionsChanged() {
+		writer.ListMetaFrom(apiOp.Context()).SetPartitionsChanged()
+	}
+	if resume == "" && result.Continue == "" && lister.Err() == nil && !writer.ListMetaFrom(apiOp.Context()).HasPartialErrors() {
+		// The whole list fit in a single response, so its length is the total. A partition skipped by a partial
+		// list leaves the total unknown.
+		writer.ListMetaFrom(apiOp.Context()).SetCount(len(result.Objects))
+	}
 	return result, lister.Err()
 }
 
+// newLister returns the lister for the partitions of a list request.
+func (s *Store) newLister(apiOp *types.APIRequest, schema *types.APISchema, partitions []Partition, opts *listprocessor.ListOptions) ParallelPartitionLister {
+	var skipError func(partition Partition, err error) bool
+	if opts.Partial {
+		skipError = func(partition Partition, err error) bool {
+			writer.ListMetaFrom(apiOp.Context()).AddPartialError(partition.Name(), err)
+			return true
+		}
+	}
+	return ParallelPartitionLister{
+		Lister: func(ctx context.Context, partition Partition, cont string, revision string, limit int) (types.APIObjectList, error) {
+			pin := opts.Revision != ""
+			if pin {
+				revision = opts.Revision
+			}
+			list, err := s.listPartition(ctx, apiOp, schema, partition, cont, revision, limit, pin)
+			if err != nil {
+				return list, err
+			}
+			list.Objects = s.transform(list.Objects)
+			// Filtering each partition page as it is fetched keeps offsets in the continue token relative to
+			// the filtered page, which is stable as long as the same filters are sent with the next request.
+			list.Objects = listprocessor.FilterList(list.Objects, opts.Filters)
+			return list, nil
+		},
+		Concurrency: s.getConcurrency(apiOp.Request),
+		Backoff:     defaultBackoff(),
+		SkipError:   skipError,
+		Dynamic:     opts.DynamicPartitions,
+		Metrics:     metrics.MetricLogger{Resource: schema.ID, Method: apiOp.Method},
+		Partitions:  partitions,
+	}
+}
+
+// listComplete collects the objects from every partition, sorts them globally, and returns either the requested page
+// or the segment of the sorted list starting at the offset recorded in the continue token.
+// Partition order cannot be used to resume a sorted list, so the continue token records only the offset and limit.
+// Pages are computed from a complete list, so clients walking pages should send back the revision from the first
+// response to pin every page to the same snapshot.
+// The complete list is cached at its revision, so later pages for the same revision are served from memory.
+func (s *Store) listComplete(apiOp *types.APIRequest, schema *types.APISchema, lister *ParallelPartitionLister, opts *listprocessor.ListOptions, limit int, resume string) (types.APIObjectList, error) {
+	var (
+		result   types.APIObjectList
+		offset   int
+		revision = opts.Revision
+	)
+
+	if resume != "" && opts.Pagination.PageSize <= 0 {
+		state, err := decodeListState(resume)
+		if err != nil {
+			return result, err
+		}
+		offset = state.Offset
+		if state.Limit > 0 {
+			limit = state.Limit
+		}
+		if state.Revision != "" {
+			revision = state.Revision
+		}
+	}
+
+	cacheRevision := revision
+	if cacheRevision == "" && apiOp.Request.URL.Query().Get("resourceVersion") == anyRevision {
+		cacheRevision = anyRevision
+	}
+	key := listCacheKey(apiOp, lister.Partitions)
+	var (
+		entry listCacheEntry
+		ok    bool
+	)
+	if writer.CacheRequested(apiOp.Request) {
+		entry, ok = s.listCache().get(schema.ID, key, cacheRevision)
+	}
+	objects := entry.objects
+	if ok {
+		result.Revision = entry.revision
+		writer.AddCacheHit(apiOp, listCacheName, time.Since(entry.added))
+	} else {
+		if s.listCache().enabled() {
+			// a list of the latest revision, or one the client asked not to be cached, is listed fresh
+			reason := writer.ForwardMiss
+			if cacheRevision == "" || !writer.CacheRequested(apiOp.Request) {
+				reason = writer.ForwardRequest
+			}
+			writer.AddCacheForward(apiOp, listCacheName, reason)
+		}
+		var err error
+		objects, err = listAll(apiOp.Context(), lister)
+		if err != nil {
+			return result, err
+		}
+		result.Revision = lister.Revision()
+		// an incomplete list must not be served to later requests as though it were complete
+		if !writer.ListMetaFrom(apiOp.Context()).HasPartialErrors() {
+			s.listCache().add(schema.ID, key, result.Revision, objects)
+		}
+	}
+	objects = listprocessor.SortList(objects, opts.Sort)
+	if !writer.ListMetaFrom(apiOp.Context()).HasPartialErrors() {
+		writer.ListMetaFrom(apiOp.Context()).SetCount(len(objects))
+	}
+
+	if opts.Pagination.PageSize > 0 {
+		objects, pages := listprocessor.PaginateList(objects, opts.Pagination)
+		writer.ListMetaFrom(apiOp.Context()).SetPages(pages)
+		result.Objects = s.shape(objects, opts)
+		return result, nil
+	}
+
+	if offset >= len(objects) {
+		return result, nil
+	}
+	objects = objects[offset:]
+
+	if len(objects) > limit {
+		objects = objects[:limit]
+		state := listState{
+			Revision: result.Revision,
+			Offset:   offset + limit,
+			Limit:    limit,
+		}
+		result.Continue = state.encode()
+	}
+
+	result.Objects = s.shape(objects, opts)
+	return result, nil
+}
+
+// transform applies the store's transformers to each object of a partition page.
+func (s *Store) transform(objects []types.APIObject) []types.APIObject {
+	if len(s.Transformers) == 0 {
+		return objects
+	}
+	for i := range objects {
+		objects[i] = s.transformObject(objects[i])
+	}
+	return objects
+}
+
+// transformObject applies the store's transformers to a single object.
+func (s *Store) transformObject(obj types.APIObject) types.APIObject {
+	for _, transformer := range s.Transformers {
+		obj = transformer(obj)
+	}
+	return obj
+}
+
+// shape applies field exclusion and projection to the objects being returned.
+func (s *Store) shape(objects []types.APIObject, opts *listprocessor.ListOptions) []types.APIObject {
+	objects = listprocessor.ExcludeList(objects, s.excludeFields(opts))
+	return listprocessor.ProjectList(objects, opts.Fields)
+}
+
+// excludeFields returns the field paths to strip, which are the store's defaults unless the request set its own.
+func (s *Store) excludeFields(opts *listprocessor.ListOptions) [][]string {
+	if opts.ExcludeFields != nil {
+		return opts.ExcludeFields
+	}
+	return listprocessor.ParseFields(s.ExcludeFields)
+}
+
+// listAll walks every page of the lister and returns the complete set of objects across all partitions.
+func listAll(ctx context.Context, lister *ParallelPartitionLister) ([]types.APIObject, error) {
+	var (
+		objects []types.APIObject
+		resume  string
+	)
+
+	for {
+		list, err := lister.List(ctx, defaultLimit, resume)
+		if err != nil {
+			return nil, err
+		}
+
+		for items := range list {
+			objects = append(objects, items...)
+		}
+
+		if err := lister.Err(); err != nil {
+			return nil, err
+		}
+
+		resume = lister.Continue()
+		if resume == "" {
+			return objects, nil
+		}
+	}
+}
+
 // Create creates a single object in the store.
 func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
 	target, err := s.getStore(apiOp, schema, "create", "")
@@ -123,8 +439,13 @@ func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 }
 
 // Update updates a single object in the store.
+// A PATCH request is routed to the partition store for the patch verb, and its body is left for that store to read.
 func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
-	target, err := s.getStore(apiOp, schema, "update", id)
+	verb := "update"
+	if apiOp.Method == http.MethodPatch {
+		verb = "patch"
+	}
+	target, err := s.getStore(apiOp, schema, verb, id)
 	if err != nil {
 		return types.APIObject{}, err
 	}
@@ -133,46 +454,134 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data ty
 }
 
 // Watch returns a channel of events for a list or resource.
+// If the request has bookmarks=true set, the bookmarks of the partitions are merged and forwarded as
+// resource.bookmark events carrying the latest revision that every partition has reached; otherwise they are dropped.
+// If the request has resumable=true set, or the watch revision is a watch token, the revision of every event is
+// replaced by a watch token recording the last revision seen in each partition. A client that reconnects with the
+// latest token resumes each partition from its own revision, so it misses no events and receives no duplicates.
+// Events delivered by more than one overlapping partition are only sent once.
+// How events are handed to a slow consumer is set by the CATTLE_WATCH_BACKPRESSURE environment variable.
 func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
 	partitions, err := s.Partitioner.All(apiOp, schema, "watch", wr.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(apiOp.Context())
+	ctx, span := tracer.Start(apiOp.Context(), "partition.Store.Watch", trace.WithAttributes(
+		attribute.String("schema", schema.ID),
+		attribute.Int("partitions", len(partitions)),
+	))
+	ctx, cancel := context.WithCancel(ctx)
+	// watches are long lived, so only a timeout set by the request itself applies to them
+	if timeout := s.getTimeout(apiOp.Request, 0); timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancelWatch := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelWatch()
+		}
+	}
 	apiOp = apiOp.Clone().WithContext(ctx)
 
 	eg := errgroup.Group{}
-	response := make(chan types.APIEvent)
+	sink := newWatchSink(ctx)
+	forwardBookmarks := apiOp.Request.URL.Query().Get(bookmarkParam) == "true"
+	bookmarks := newBookmarks(len(partitions))
+	exclude := s.excludeFields(listprocessor.ParseQuery(apiOp))
+	var dedup *eventDedup
+	if len(partitions) > 1 {
+		dedup = newEventDedup()
+	}
+	state, resumable := decodeWatchState(wr.Revision)
+	if !resumable && apiOp.Request.URL.Query().Get(resumableParam) == "true" {
+		state, resumable = &watchState{Revisions: map[string]string{}}, true
+	}
 
-	for _, partition := range partitions {
+	for i, partition := range partitions {
+		index := i
+		name := partition.Name()
 		store, err := s.Partitioner.Store(apiOp, partition)
 		if err != nil {
 			cancel()
+			endSpan(span, err)
 			return nil, err
 		}
 
+		partitionRequest := wr
+		if resumable {
+			partitionRequest.Revision = state.revision(name)
+		}
+
 		eg.Go(func() error {
 			defer cancel()
-			c, err := store.Watch(apiOp, schema, wr)
+			c, err := store.Watch(apiOp, schema, partitionRequest)
 			if err != nil {
 				return err
 			}
 			for i := range c {
-				response <- i
+				if i.Name == BookmarkAPIEvent {
+					bookmark, ok := bookmarks.seen(index, i)
+					if resumable {
+						token := state.advance(name, i.Revision)
+						if forwardBookmarks {
+							// every partition bookmark is worth forwarding, since the token carries each partition's revision
+							sink.send(ctx, types.APIEvent{Name: BookmarkAPIEvent, Revision: token})
+						}
+					} else if ok && forwardBookmarks {
+						sink.send(ctx, bookmark)
+					}
+					continue
+				}
+				if dedup.duplicate(index, i) {
+					continue
+				}
+				if resumable && i.Error == nil {
+					i.Revision = state.advance(name, i.Revision)
+				}
+				if i.Error == nil && i.Name != BookmarkAPIEvent {
+					i.Object = s.transformObject(i.Object)
+				}
+				i.Object = listprocessor.ExcludeObject(i.Object, exclude)
+				sink.send(ctx, i)
 			}
 			return nil
 		})
 	}
 
 	go func() {
-		defer close(response)
+		defer close(sink.response)
 		<-ctx.Done()
-		eg.Wait()
+		err := eg.Wait()
 		cancel()
+		sink.wait()
+		endSpan(span, err)
 	}()
 
-	return response, nil
+	return sink.response, nil
+}
+
+// getConcurrency returns the number of partitions to list at once.
+// Clients may lower it for a single request with the X-Steve-List-Concurrency header, but not raise it above the store's setting.
+func (s *Store) getConcurrency(req *http.Request) int64 {
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	requested, err := strconv.ParseInt(req.Header.Get(concurrencyHeader), 10, 64)
+	if err == nil && requested > 0 && requested < concurrency {
+		return requested
+	}
+	return concurrency
+}
+
+// getTimeout returns the duration set by the timeout parameter of the request, such as 30s, or the default.
+func (s *Store) getTimeout(req *http.Request, def time.Duration) time.Duration {
+	timeout, err := time.ParseDuration(req.URL.Query().Get(timeoutParam))
+	if err != nil || timeout <= 0 {
+		return def
+	}
+	return timeout
 }
 
 // getLimit extracts the limit parameter from the request or sets a default of 100000.
@@ -189,3 +598,12 @@ func getLimit(req *http.Request) int {
 	}
 	return limit
 }
+
+// getPartitionLimit returns the limitPerPartition query parameter, or zero if it is not set or not positive.
+func getPartitionLimit(req *http.Request) int {
+	limit, err := strconv.Atoi(req.URL.Query().Get(partitionLimitParam))
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,180 @@
+package partition
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/writer"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type namedPartition string
+
+func (n namedPartition) Name() string {
+	return string(n)
+}
+
+// namespacePartitioner lists the partitions of its stores, by name.
+type namespacePartitioner struct {
+	Partitioner
+	stores map[string]types.Store
+}
+
+func (n namespacePartitioner) All(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) ([]Partition, error) {
+	return []Partition{namedPartition("a"), namedPartition("b")}, nil
+}
+
+func (n namespacePartitioner) Store(apiOp *types.APIRequest, partition Partition) (types.Store, error) {
+	return n.stores[partition.Name()], nil
+}
+
+type listStore struct {
+	empty.Store
+	names []string
+	err   error
+}
+
+func (l *listStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	var result types.APIObjectList
+	for _, name := range l.names {
+		obj := &unstructured.Unstructured{}
+		obj.SetName(name)
+		result.Objects = append(result.Objects, types.APIObject{ID: name, Object: obj})
+	}
+	return result, l.err
+}
+
+// watchStore serves the events sent to it to a single watch, and records the revision the watch started from.
+type watchStore struct {
+	empty.Store
+	events  chan types.APIEvent
+	started chan string
+}
+
+func newWatchStore() *watchStore {
+	return &watchStore{
+		events:  make(chan types.APIEvent),
+		started: make(chan string, 1),
+	}
+}
+
+func (w *watchStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	w.started <- wr.Revision
+	return w.events, nil
+}
+
+func TestListCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		err       error
+		wantCount bool
+	}{
+		{
+			name:      "complete list",
+			wantCount: true,
+		},
+		{
+			name:  "partial list",
+			query: "?partial=true",
+			err:   errors.New("forbidden"),
+		},
+		{
+			name:      "sorted list",
+			query:     "?sort=metadata.name",
+			wantCount: true,
+		},
+		{
+			name:  "sorted partial list",
+			query: "?sort=metadata.name&partial=true",
+			err:   errors.New("forbidden"),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := &Store{Partitioner: namespacePartitioner{stores: map[string]types.Store{
+				"a": &listStore{names: []string{"one", "two"}},
+				"b": &listStore{err: test.err},
+			}}}
+			req := httptest.NewRequest(http.MethodGet, "/v1/pods"+test.query, nil)
+			req = req.WithContext(writer.WithListMeta(req.Context()))
+			apiOp := &types.APIRequest{Request: req}
+
+			list, err := s.List(apiOp, &types.APISchema{Schema: &schemas.Schema{ID: "pod"}})
+			require.NoError(t, err)
+			assert.Len(t, list.Objects, 2)
+
+			meta := writer.ListMetaFrom(req.Context())
+			if !test.wantCount {
+				assert.Nil(t, meta.Count, "the total is unknown once a partition is skipped")
+				assert.Len(t, meta.PartialErrors, 1)
+				return
+			}
+			require.NotNil(t, meta.Count)
+			assert.Equal(t, 2, *meta.Count)
+		})
+	}
+}
+
+// flakyStore fails as many lists as failures with a transient error before it succeeds.
+type flakyStore struct {
+	listStore
+	failures int
+	calls    int
+}
+
+func (f *flakyStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return types.APIObjectList{}, apierrors.NewServiceUnavailable("try again")
+	}
+	return f.listStore.List(apiOp, schema)
+}
+
+func TestPartialListRetries(t *testing.T) {
+	flaky := &flakyStore{listStore: listStore{names: []string{"three"}}, failures: 1}
+	s := &Store{Partitioner: namespacePartitioner{stores: map[string]types.Store{
+		"a": &listStore{names: []string{"one", "two"}},
+		"b": flaky,
+	}}}
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods?partial=true", nil)
+	req = req.WithContext(writer.WithListMeta(req.Context()))
+
+	list, err := s.List(&types.APIRequest{Request: req}, &types.APISchema{Schema: &schemas.Schema{ID: "pod"}})
+	require.NoError(t, err)
+	assert.Len(t, list.Objects, 3)
+	assert.Equal(t, 2, flaky.calls, "the transient error is retried before the partition is skipped")
+	assert.Empty(t, writer.ListMetaFrom(req.Context()).PartialErrors)
+}
+
+// verbPartitioner records the verb of each lookup.
+type verbPartitioner struct {
+	namespacePartitioner
+	verbs []string
+}
+
+func (v *verbPartitioner) Lookup(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) (Partition, error) {
+	v.verbs = append(v.verbs, verb)
+	return namedPartition("a"), nil
+}
+
+func TestUpdateRoutesPatches(t *testing.T) {
+	partitioner := &verbPartitioner{namespacePartitioner: namespacePartitioner{stores: map[string]types.Store{"a": &empty.Store{}}}}
+	s := &Store{Partitioner: partitioner}
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		apiOp := &types.APIRequest{Method: method, Request: httptest.NewRequest(method, "/v1/pods/default/web", nil)}
+		_, _ = s.Update(apiOp, schema, types.APIObject{}, "web")
+	}
+	assert.Equal(t, []string{"update", "patch"}, partitioner.verbs, "patches are routed by the patch verb")
+}
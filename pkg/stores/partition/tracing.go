@@ -0,0 +1,20 @@
+package partition
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of partition lists and watches. Spans are only recorded if the
+// embedding application has registered a tracer provider with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/rancher/steve/pkg/stores/partition")
+
+// endSpan records the error, if any, on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
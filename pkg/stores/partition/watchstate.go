@@ -0,0 +1,83 @@
+package partition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// resumableParam is the query parameter of the watch request that asks for event revisions to be
+	// replaced by resumable watch tokens.
+	resumableParam = "resumable"
+
+	// watchTokenPrefix distinguishes watch tokens from plain resourceVersions, which are always numeric.
+	watchTokenPrefix = "w:"
+)
+
+// watchState is a representation of the point a watch across partitions has reached.
+// It is encoded as the revision of each event when a resumable watch is requested, and
+// may be sent back as the revision of a new watch to resume every partition where it left off.
+type watchState struct {
+	lock sync.Mutex
+
+	// Revisions is the last resourceVersion seen in each partition, by partition name.
+	Revisions map[string]string `json:"r"`
+}
+
+// decodeWatchState parses a watch token. It returns false if the revision is not a watch token.
+func decodeWatchState(revision string) (*watchState, bool) {
+	if !strings.HasPrefix(revision, watchTokenPrefix) {
+		return nil, false
+	}
+	bytes, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(revision, watchTokenPrefix))
+	if err != nil {
+		return nil, false
+	}
+	state := &watchState{}
+	if err := json.Unmarshal(bytes, state); err != nil || state.Revisions == nil {
+		return nil, false
+	}
+	return state, true
+}
+
+// revision returns the revision to start watching a partition from.
+// Partitions that did not exist when the token was issued start from the oldest revision in the token,
+// so that none of their events after that point are missed.
+func (w *watchState) revision(partition string) string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if rev, ok := w.Revisions[partition]; ok {
+		return rev
+	}
+	var (
+		oldest    uint64
+		oldestRev string
+	)
+	for _, rev := range w.Revisions {
+		r, err := strconv.ParseUint(rev, 10, 64)
+		if err == nil && (oldestRev == "" || r < oldest) {
+			oldest = r
+			oldestRev = rev
+		}
+	}
+	return oldestRev
+}
+
+// advance records the revision of an event in a partition and returns the encoded token for the new state.
+func (w *watchState) advance(partition, revision string) string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if revision != "" {
+		w.Revisions[partition] = revision
+	}
+	bytes, err := json.Marshal(w)
+	if err != nil {
+		return ""
+	}
+	return watchTokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+}
@@ -0,0 +1,90 @@
+package partition
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchState(t *testing.T) {
+	state := &watchState{Revisions: map[string]string{}}
+	state.advance("a", "5")
+	token := state.advance("b", "3")
+	assert.Equal(t, token, state.advance("b", ""), "events without a revision don't move the partition")
+
+	decoded, ok := decodeWatchState(token)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"a": "5", "b": "3"}, decoded.Revisions)
+	assert.Equal(t, "5", decoded.revision("a"))
+	assert.Equal(t, "3", decoded.revision("c"), "new partitions start from the oldest revision of the token")
+
+	for _, revision := range []string{
+		"",
+		"5",
+		watchTokenPrefix + "not base64!",
+		watchTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		watchTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte("{}")),
+	} {
+		_, ok := decodeWatchState(revision)
+		assert.False(t, ok, "%q is not a watch token", revision)
+	}
+}
+
+func TestWatchResumesPartitions(t *testing.T) {
+	state := &watchState{Revisions: map[string]string{"a": "5"}}
+	token := state.advance("b", "3")
+
+	tests := []struct {
+		name    string
+		query   string
+		wr      types.WatchRequest
+		started map[string]string
+		resumed map[string]string
+	}{
+		{
+			name:    "resumed from a token",
+			wr:      types.WatchRequest{Revision: token},
+			started: map[string]string{"a": "5", "b": "3"},
+			resumed: map[string]string{"a": "6", "b": "3"},
+		},
+		{
+			name:    "resumable from the start",
+			query:   "?resumable=true",
+			started: map[string]string{"a": "", "b": ""},
+			resumed: map[string]string{"a": "6"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a, b := newWatchStore(), newWatchStore()
+			s := &Store{Partitioner: namespacePartitioner{stores: map[string]types.Store{"a": a, "b": b}}}
+			ctx, cancel := context.WithCancel(context.Background())
+			apiOp := &types.APIRequest{Request: httptest.NewRequest(http.MethodGet, "/v1/pods"+test.query, nil).WithContext(ctx)}
+
+			c, err := s.Watch(apiOp, &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}, test.wr)
+			require.NoError(t, err)
+			assert.Equal(t, test.started, map[string]string{"a": <-a.started, "b": <-b.started},
+				"each partition starts from its own revision")
+
+			a.events <- types.APIEvent{Name: types.ChangeAPIEvent, Revision: "6", Object: types.APIObject{ID: "web"}}
+			event := <-c
+			resumed, ok := decodeWatchState(event.Revision)
+			require.True(t, ok, "the revision of the event is a watch token")
+			assert.Equal(t, test.resumed, resumed.Revisions)
+
+			cancel()
+			close(a.events)
+			close(b.events)
+			for range c {
+			}
+		})
+	}
+}
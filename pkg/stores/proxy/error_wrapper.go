@@ -1,10 +1,8 @@
 package proxy
 
 import (
-	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
-	"github.com/rancher/wrangler/pkg/schemas/validation"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"github.com/rancher/steve/pkg/writer"
 )
 
 type errorStore struct {
@@ -48,13 +46,7 @@ func (e *errorStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr
 	return data, translateError(err)
 }
 
+// translateError returns the API error of a kubernetes Status error, keeping the details of the status.
 func translateError(err error) error {
-	if apiError, ok := err.(errors.APIStatus); ok {
-		status := apiError.Status()
-		return apierror.NewAPIError(validation.ErrorCode{
-			Status: int(status.Code),
-			Code:   string(status.Reason),
-		}, status.Message)
-	}
-	return err
+	return writer.FromStatus(err)
 }
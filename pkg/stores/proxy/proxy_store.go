@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
 	"reflect"
@@ -16,27 +17,41 @@ import (
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/client"
 	metricsStore "github.com/rancher/steve/pkg/stores/metrics"
 	"github.com/rancher/steve/pkg/stores/partition"
 	"github.com/rancher/wrangler/pkg/data"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/slice"
 	"github.com/rancher/wrangler/pkg/summary"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
 )
 
-const watchTimeoutEnv = "CATTLE_WATCH_TIMEOUT_SECONDS"
+const (
+	watchTimeoutEnv     = "CATTLE_WATCH_TIMEOUT_SECONDS"
+	defaultFieldManager = "steve"
+)
 
 var (
+	// supportedSubresources are the subresources that may be read and updated through a link to them.
+	supportedSubresources = map[string]bool{
+		"status": true,
+		"scale":  true,
+	}
 	lowerChars  = regexp.MustCompile("[a-z]+")
 	paramScheme = runtime.NewScheme()
 	paramCodec  = runtime.NewParameterCodec(paramScheme)
@@ -65,6 +80,12 @@ type RelationshipNotifier interface {
 	OnInboundRelationshipChange(ctx context.Context, schema *types.APISchema, namespace string) <-chan *summary.Relationship
 }
 
+// UsageNotifier is implemented by a RelationshipNotifier that caches the types in use, which it is told of when
+// their objects are listed or watched.
+type UsageNotifier interface {
+	Use(gvk schema2.GroupVersionKind)
+}
+
 // Store implements types.Store directly on top of kubernetes.
 type Store struct {
 	clientGetter ClientGetter
@@ -72,30 +93,52 @@ type Store struct {
 }
 
 // NewProxyStore returns a wrapped types.Store.
-func NewProxyStore(clientGetter ClientGetter, notifier RelationshipNotifier, lookup accesscontrol.AccessSetLookup) types.Store {
+func NewProxyStore(clientGetter ClientGetter, notifier RelationshipNotifier, lookup accesscontrol.AccessSetLookup, opts partition.Options) types.Store {
 	return &errorStore{
-		Store: &WatchRefresh{
-			Store: &partition.Store{
-				Partitioner: &rbacPartitioner{
-					proxyStore: &Store{
-						clientGetter: clientGetter,
-						notifier:     notifier,
-					},
+		Store: &versionStore{
+			Store: &WatchRefresh{
+				Store: &partition.Store{
+					Partitioner: NewPartitioner(clientGetter, notifier),
+					Options:     opts,
 				},
+				asl: lookup,
 			},
-			asl: lookup,
 		},
 	}
 }
 
 // ByID looks up a single object by its ID.
+// If the request's link is a subresource of the schema, such as status or scale, the subresource is returned instead.
 func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
-	result, err := s.byID(apiOp, schema, apiOp.Namespace, id)
+	result, err := s.byID(apiOp, schema, apiOp.Namespace, id, subresource(apiOp, schema)...)
 	return toAPI(schema, result), err
 }
 
+// subresource returns the subresource named by the request's link, if the schema supports it.
+func subresource(apiOp *types.APIRequest, schema *types.APISchema) []string {
+	if apiOp.Link == "" || !supportedSubresources[apiOp.Link] {
+		return nil
+	}
+	if !slice.ContainsString(attributes.Subresources(schema), apiOp.Link) {
+		return nil
+	}
+	return []string{apiOp.Link}
+}
+
+// client returns the client for an object, or for one of its subresources, which are not served as tables.
+func (s *Store) client(apiOp *types.APIRequest, schema *types.APISchema, namespace string, subresources []string) (dynamic.ResourceInterface, error) {
+	if len(subresources) > 0 {
+		return s.clientGetter.Client(apiOp, schema, namespace)
+	}
+	return s.clientGetter.TableClient(apiOp, schema, namespace)
+}
+
+// decodeParams decodes the query parameters of the request, such as dryRun or fieldManager, into kubernetes options.
 func decodeParams(apiOp *types.APIRequest, target runtime.Object) error {
-	return paramCodec.DecodeParameters(apiOp.Request.URL.Query(), metav1.SchemeGroupVersion, target)
+	if err := paramCodec.DecodeParameters(apiOp.Request.URL.Query(), metav1.SchemeGroupVersion, target); err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	return nil
 }
 
 func toAPI(schema *types.APISchema, obj runtime.Object) types.APIObject {
@@ -104,6 +147,11 @@ func toAPI(schema *types.APISchema, obj runtime.Object) types.APIObject {
 	}
 
 	if unstr, ok := obj.(*unstructured.Unstructured); ok {
+		if gvk := attributes.GVK(schema); gvk.Kind != "" && unstr.GetKind() == "PartialObjectMetadata" {
+			// metadata-only rows carry the kind of the metadata rather than of the object
+			unstr.SetAPIVersion(gvk.GroupVersion().String())
+			unstr.SetKind(gvk.Kind)
+		}
 		obj = moveToUnderscore(unstr)
 	}
 
@@ -127,8 +175,8 @@ func toAPI(schema *types.APISchema, obj runtime.Object) types.APIObject {
 	return apiObject
 }
 
-func (s *Store) byID(apiOp *types.APIRequest, schema *types.APISchema, namespace, id string) (*unstructured.Unstructured, error) {
-	k8sClient, err := metricsStore.Wrap(s.clientGetter.TableClient(apiOp, schema, namespace))
+func (s *Store) byID(apiOp *types.APIRequest, schema *types.APISchema, namespace, id string, subresources ...string) (*unstructured.Unstructured, error) {
+	k8sClient, err := metricsStore.Wrap(s.client(apiOp, schema, namespace, subresources))
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +186,7 @@ func (s *Store) byID(apiOp *types.APIRequest, schema *types.APISchema, namespace
 		return nil, err
 	}
 
-	obj, err := k8sClient.Get(apiOp, id, opts)
+	obj, err := k8sClient.Get(apiOp, id, opts, subresources...)
 	rowToObject(obj)
 	return obj, err
 }
@@ -200,6 +248,15 @@ func tableToList(obj *unstructured.UnstructuredList) {
 	obj.Items = tableToObjects(obj.Object)
 }
 
+// tableOnly returns the request with the table clients asking the apiserver for only the metadata of the objects
+// of the rows, if the client accepts a kubernetes Table and so needs only the server-computed cells.
+func tableOnly(apiOp *types.APIRequest) *types.APIRequest {
+	if !client.AcceptsTable(apiOp.Request) {
+		return apiOp
+	}
+	return apiOp.WithContext(client.WithIncludeObject(apiOp.Context(), metav1.IncludeMetadata))
+}
+
 func tableToObjects(obj map[string]interface{}) []unstructured.Unstructured {
 	var result []unstructured.Unstructured
 
@@ -229,11 +286,10 @@ func tableToObjects(obj map[string]interface{}) []unstructured.Unstructured {
 // performing the list or watch will result in a Forbidden error, because the user does not have permission
 // to list *all* resources.
 // With this filter, the request can be performed successfully, and only the allowed resources will
-// be returned in the list.
+// be returned in the list. Without a namespace the objects of the names are listed from every namespace, as for
+// names granted on namespaced objects by a clusterrolebinding.
 func (s *Store) ByNames(apiOp *types.APIRequest, schema *types.APISchema, names sets.String) (types.APIObjectList, error) {
-	if apiOp.Namespace == "*" {
-		// This happens when you grant namespaced objects with "get" by name in a clusterrolebinding. We will treat
-		// this as an invalid situation instead of listing all objects in the cluster and filtering by name.
+	if names.Len() == 0 {
 		return types.APIObjectList{}, nil
 	}
 
@@ -242,6 +298,11 @@ func (s *Store) ByNames(apiOp *types.APIRequest, schema *types.APISchema, names
 		return types.APIObjectList{}, err
 	}
 
+	// a single name can be selected by the apiserver rather than by listing the whole namespace
+	if names.Len() == 1 {
+		apiOp = withFieldSelector(apiOp, fields.OneTermEqualSelector("metadata.name", names.List()[0]))
+	}
+
 	objs, err := s.list(apiOp, schema, adminClient)
 	if err != nil {
 		return types.APIObjectList{}, err
@@ -267,14 +328,35 @@ func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.AP
 	return s.list(apiOp, schema, client)
 }
 
+// withFieldSelector returns a copy of the request whose fieldSelector parameter also requires the given selector.
+func withFieldSelector(apiOp *types.APIRequest, selector fields.Selector) *types.APIRequest {
+	apiOp = apiOp.Clone()
+	apiOp.Request = apiOp.Request.Clone(apiOp.Context())
+	q := apiOp.Request.URL.Query()
+	if existing := q.Get("fieldSelector"); existing != "" {
+		if parsed, err := fields.ParseSelector(existing); err == nil {
+			selector = fields.AndSelectors(parsed, selector)
+		} else {
+			// leave an invalid selector for the apiserver to reject
+			return apiOp
+		}
+	}
+	q.Set("fieldSelector", selector.String())
+	apiOp.Request.URL.RawQuery = q.Encode()
+	return apiOp
+}
+
+// list lists the resources in kubernetes. The labelSelector and fieldSelector parameters of the request are
+// passed to the apiserver, so selected objects are filtered server side.
 func (s *Store) list(apiOp *types.APIRequest, schema *types.APISchema, client dynamic.ResourceInterface) (types.APIObjectList, error) {
+	s.use(schema)
 	opts := metav1.ListOptions{}
 	if err := decodeParams(apiOp, &opts); err != nil {
-		return types.APIObjectList{}, nil
+		return types.APIObjectList{}, err
 	}
 
 	k8sClient, _ := metricsStore.Wrap(client, nil)
-	resultList, err := k8sClient.List(apiOp, opts)
+	resultList, err := k8sClient.List(tableOnly(apiOp), opts)
 	if err != nil {
 		return types.APIObjectList{}, err
 	}
@@ -317,14 +399,16 @@ func (s *Store) listAndWatch(apiOp *types.APIRequest, client dynamic.ResourceInt
 		}
 	}
 	k8sClient, _ := metricsStore.Wrap(client, nil)
-	watcher, err := k8sClient.Watch(apiOp, metav1.ListOptions{
-		Watch:           true,
-		TimeoutSeconds:  &timeout,
-		ResourceVersion: rev,
-		LabelSelector:   w.Selector,
+	watcher, err := k8sClient.Watch(tableOnly(apiOp), metav1.ListOptions{
+		Watch:               true,
+		TimeoutSeconds:      &timeout,
+		ResourceVersion:     rev,
+		LabelSelector:       w.Selector,
+		FieldSelector:       apiOp.Request.URL.Query().Get("fieldSelector"),
+		AllowWatchBookmarks: true,
 	})
 	if err != nil {
-		returnErr(errors.Wrapf(err, "stopping watch for %s: %v", schema.ID, err), result)
+		returnErr(translateError(errors.Wrapf(err, "stopping watch for %s: %v", schema.ID, err)), result)
 		return
 	}
 	defer watcher.Stop()
@@ -345,7 +429,7 @@ func (s *Store) listAndWatch(apiOp *types.APIRequest, client dynamic.ResourceInt
 					result <- s.toAPIEvent(apiOp, schema, watch.Modified, obj)
 				} else {
 					logrus.Debugf("notifier watch error: %v", err)
-					returnErr(errors.Wrapf(err, "notifier watch error: %v", err), result)
+					returnErr(translateError(errors.Wrapf(err, "notifier watch error: %v", err)), result)
 				}
 			}
 			return fmt.Errorf("closed")
@@ -357,7 +441,7 @@ func (s *Store) listAndWatch(apiOp *types.APIRequest, client dynamic.ResourceInt
 			if event.Type == watch.Error {
 				if status, ok := event.Object.(*metav1.Status); ok {
 					logrus.Debugf("event watch error: %s", status.Message)
-					returnErr(fmt.Errorf("event watch error: %s", status.Message), result)
+					returnErr(translateError(apierrors.FromObject(status)), result)
 				} else {
 					logrus.Debugf("event watch error: could not decode event object %T", event.Object)
 				}
@@ -392,7 +476,7 @@ func (s *Store) WatchNames(apiOp *types.APIRequest, schema *types.APISchema, w t
 	go func() {
 		defer close(result)
 		for item := range c {
-			if item.Error == nil && names.Has(item.Object.Name()) {
+			if item.Error != nil || item.Name == partition.BookmarkAPIEvent || names.Has(item.Object.Name()) {
 				result <- item
 			}
 		}
@@ -411,6 +495,7 @@ func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.
 }
 
 func (s *Store) watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest, client dynamic.ResourceInterface) (chan types.APIEvent, error) {
+	s.use(schema)
 	result := make(chan types.APIEvent)
 	go func() {
 		s.listAndWatch(apiOp, client, schema, w, result)
@@ -420,6 +505,13 @@ func (s *Store) watch(apiOp *types.APIRequest, schema *types.APISchema, w types.
 	return result, nil
 }
 
+// use tells the notifier that the objects of a schema are being listed or watched, if it caches the types in use.
+func (s *Store) use(schema *types.APISchema) {
+	if notifier, ok := s.notifier.(UsageNotifier); ok {
+		notifier.Use(attributes.GVK(schema))
+	}
+}
+
 func (s *Store) toAPIEvent(apiOp *types.APIRequest, schema *types.APISchema, et watch.EventType, obj runtime.Object) types.APIEvent {
 	name := types.ChangeAPIEvent
 	switch et {
@@ -427,6 +519,15 @@ func (s *Store) toAPIEvent(apiOp *types.APIRequest, schema *types.APISchema, et
 		name = types.RemoveAPIEvent
 	case watch.Added:
 		name = types.CreateAPIEvent
+	case watch.Bookmark:
+		// bookmarks only carry a resourceVersion, so don't send the empty object along
+		event := types.APIEvent{
+			Name: partition.BookmarkAPIEvent,
+		}
+		if m, err := meta.Accessor(obj); err == nil {
+			event.Revision = m.GetResourceVersion()
+		}
+		return event
 	}
 
 	if unstr, ok := obj.(*unstructured.Unstructured); ok {
@@ -448,6 +549,8 @@ func (s *Store) toAPIEvent(apiOp *types.APIRequest, schema *types.APISchema, et
 }
 
 // Create creates a single object in the store.
+// Updates of a subresource, such as status or scale, are made by linking to it, e.g. PUT /v1/apps.deployments/ns/name/scale.
+// Like updates and deletes, it is only validated, admission webhooks included, if the request sets dryRun=All.
 func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, params types.APIObject) (types.APIObject, error) {
 	var (
 		resp *unstructured.Unstructured
@@ -488,7 +591,25 @@ func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, params
 	return apiObject, err
 }
 
+// patchType returns the kind of patch for the content type of a PATCH request.
+// Bodies without a patch content type, such as plain application/json, are strategic merge patches.
+func patchType(contentType string) apitypes.PatchType {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return apitypes.StrategicMergePatchType
+	}
+	switch pType := apitypes.PatchType(mediaType); pType {
+	case apitypes.JSONPatchType, apitypes.MergePatchType, apitypes.StrategicMergePatchType, apitypes.ApplyPatchType:
+		return pType
+	default:
+		return apitypes.StrategicMergePatchType
+	}
+}
+
 // Update updates a single object in the store.
+// A PATCH is sent to kubernetes as a JSON patch, a JSON merge patch, a strategic merge patch or a server-side apply,
+// according to its content type. For a server-side apply, the fieldManager and force query parameters are passed
+// through to kubernetes.
 func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params types.APIObject, id string) (types.APIObject, error) {
 	var (
 		err   error
@@ -496,7 +617,12 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 	)
 
 	ns := types.Namespace(input)
-	k8sClient, err := metricsStore.Wrap(s.clientGetter.TableClient(apiOp, schema, ns))
+	if ns == "" && apiOp.Method == http.MethodPatch {
+		// a patch has no body to take the namespace from
+		ns = apiOp.Namespace
+	}
+	subresources := subresource(apiOp, schema)
+	k8sClient, err := metricsStore.Wrap(s.client(apiOp, schema, ns, subresources))
 	if err != nil {
 		return types.APIObject{}, err
 	}
@@ -507,20 +633,28 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 			return types.APIObject{}, err
 		}
 
-		pType := apitypes.StrategicMergePatchType
-		if apiOp.Request.Header.Get("content-type") == string(apitypes.JSONPatchType) {
-			pType = apitypes.JSONPatchType
-		}
+		pType := patchType(apiOp.Request.Header.Get("content-type"))
 
 		opts := metav1.PatchOptions{}
 		if err := decodeParams(apiOp, &opts); err != nil {
 			return types.APIObject{}, err
 		}
 
-		if pType == apitypes.StrategicMergePatchType {
+		if pType == apitypes.ApplyPatchType {
+			// server-side apply requires a field manager; force takes ownership of conflicting fields
+			if opts.FieldManager == "" {
+				opts.FieldManager = defaultFieldManager
+			}
+			bytes, err = yaml.YAMLToJSON(bytes)
+			if err != nil {
+				return types.APIObject{}, apierrors.NewBadRequest(err.Error())
+			}
+		}
+
+		if pType != apitypes.JSONPatchType {
 			data := map[string]interface{}{}
 			if err := json.Unmarshal(bytes, &data); err != nil {
-				return types.APIObject{}, err
+				return types.APIObject{}, apierrors.NewBadRequest(fmt.Sprintf("invalid %s patch: %v", pType, err))
 			}
 			data = moveFromUnderscore(data)
 			bytes, err = json.Marshal(data)
@@ -529,7 +663,7 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 			}
 		}
 
-		resp, err := k8sClient.Patch(apiOp, id, pType, bytes, opts)
+		resp, err := k8sClient.Patch(apiOp, id, pType, bytes, opts, subresources...)
 		if err != nil {
 			return types.APIObject{}, err
 		}
@@ -547,7 +681,7 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 		return types.APIObject{}, err
 	}
 
-	resp, err := k8sClient.Update(apiOp, &unstructured.Unstructured{Object: moveFromUnderscore(input)}, metav1.UpdateOptions{})
+	resp, err := k8sClient.Update(apiOp, &unstructured.Unstructured{Object: moveFromUnderscore(input)}, opts, subresources...)
 	if err != nil {
 		return types.APIObject{}, err
 	}
@@ -556,11 +690,52 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 	return toAPI(schema, resp), nil
 }
 
+// removeFinalizers removes the finalizers of an object that is stuck terminating, so a force delete completes
+// without waiting for the controllers that own them. Objects that are not terminating are rejected, as are users
+// who may not update the object, since removing finalizers is an update.
+func (s *Store) removeFinalizers(apiOp *types.APIRequest, schema *types.APISchema, id string, k8sClient metricsStore.ResourceClientWithMetrics, dryRun []string) error {
+	gr := attributes.GR(schema)
+	access := accesscontrol.GetAccessListMap(schema)
+	if !access.Grants("update", apiOp.Namespace, id) && !access.Grants("patch", apiOp.Namespace, id) {
+		return apierrors.NewForbidden(gr, id, fmt.Errorf("force delete requires permission to update the object"))
+	}
+
+	obj, err := s.byID(apiOp, schema, apiOp.Namespace, id)
+	if err != nil {
+		return err
+	}
+	if obj.GetDeletionTimestamp() == nil {
+		return apierrors.NewConflict(gr, id, fmt.Errorf("only objects stuck terminating can be force deleted"))
+	}
+	if len(obj.GetFinalizers()) == 0 {
+		return nil
+	}
+
+	// the resourceVersion makes the patch fail if the object changed since it was read
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      nil,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Infof("force deleting %s %s/%s for %s, removing finalizers %v", schema.ID, apiOp.Namespace, id, apiOp.GetUser(), obj.GetFinalizers())
+	_, err = k8sClient.Patch(apiOp, id, apitypes.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
 // Delete deletes an object from a store.
+// With dryRun=All the delete is only validated, and the object that would have been deleted is returned.
+// With force=true the finalizers of an object stuck terminating are removed first.
 func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
 	opts := metav1.DeleteOptions{}
 	if err := decodeParams(apiOp, &opts); err != nil {
-		return types.APIObject{}, nil
+		return types.APIObject{}, err
 	}
 
 	k8sClient, err := metricsStore.Wrap(s.clientGetter.TableClient(apiOp, schema, apiOp.Namespace))
@@ -568,7 +743,15 @@ func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id stri
 		return types.APIObject{}, err
 	}
 
-	if err := k8sClient.Delete(apiOp, id, opts); err != nil {
+	force := apiOp.Request.URL.Query().Get("force") == "true"
+	if force {
+		if err := s.removeFinalizers(apiOp, schema, id, k8sClient, opts.DryRun); err != nil {
+			return types.APIObject{}, err
+		}
+	}
+
+	// a force deleted object is gone as soon as its finalizers are removed
+	if err := k8sClient.Delete(apiOp, id, opts); err != nil && !(force && apierrors.IsNotFound(err)) {
 		return types.APIObject{}, err
 	}
 
@@ -0,0 +1,407 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// call is a request made to kubernetes, with its options.
+type call struct {
+	verb         string
+	name         string
+	patchType    apitypes.PatchType
+	patch        string
+	options      interface{}
+	subresources []string
+}
+
+// fakeClient records the calls made to kubernetes. It holds a single object, which is returned by gets and updated
+// by the objects written, and the objects it lists.
+type fakeClient struct {
+	dynamic.ResourceInterface
+	obj   *unstructured.Unstructured
+	items []unstructured.Unstructured
+	calls []call
+}
+
+func (f *fakeClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	f.calls = append(f.calls, call{verb: "list", options: opts})
+	return &unstructured.UnstructuredList{Items: f.items}, nil
+}
+
+func (f *fakeClient) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	f.calls = append(f.calls, call{verb: "get", name: name, options: opts, subresources: subresources})
+	if f.obj == nil {
+		return nil, apierrors.NewNotFound(schema2.GroupResource{Group: "apps", Resource: "deployments"}, name)
+	}
+	return f.obj.DeepCopy(), nil
+}
+
+func (f *fakeClient) Create(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	f.calls = append(f.calls, call{verb: "create", name: obj.GetName(), options: opts, subresources: subresources})
+	f.obj = obj.DeepCopy()
+	return obj, nil
+}
+
+func (f *fakeClient) Update(ctx context.Context, obj *unstructured.Unstructured, opts metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	f.calls = append(f.calls, call{verb: "update", name: obj.GetName(), options: opts, subresources: subresources})
+	f.obj = obj.DeepCopy()
+	return obj, nil
+}
+
+func (f *fakeClient) Patch(ctx context.Context, name string, pt apitypes.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	f.calls = append(f.calls, call{verb: "patch", name: name, patchType: pt, patch: string(data), options: opts, subresources: subresources})
+	if f.obj == nil {
+		return nil, apierrors.NewNotFound(schema2.GroupResource{Group: "apps", Resource: "deployments"}, name)
+	}
+	return f.obj.DeepCopy(), nil
+}
+
+func (f *fakeClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	f.calls = append(f.calls, call{verb: "delete", name: name, options: opts, subresources: subresources})
+	if f.obj == nil {
+		return apierrors.NewNotFound(schema2.GroupResource{Group: "apps", Resource: "deployments"}, name)
+	}
+	f.obj = nil
+	return nil
+}
+
+// fakeClientGetter returns the fake client, and records whether it was asked for a table client.
+type fakeClientGetter struct {
+	ClientGetter
+	client *fakeClient
+	tables []bool
+}
+
+func (f *fakeClientGetter) Client(ctx *types.APIRequest, schema *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
+	f.tables = append(f.tables, false)
+	return f.client, nil
+}
+
+func (f *fakeClientGetter) TableClient(ctx *types.APIRequest, schema *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
+	f.tables = append(f.tables, true)
+	return f.client, nil
+}
+
+func (f *fakeClientGetter) TableAdminClient(ctx *types.APIRequest, schema *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
+	return f.TableClient(ctx, schema, namespace)
+}
+
+func newDeployment() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"namespace":       "default",
+			"resourceVersion": "5",
+		},
+	}}
+}
+
+func newProxyStore(obj *unstructured.Unstructured) (*Store, *fakeClientGetter) {
+	getter := &fakeClientGetter{client: &fakeClient{obj: obj}}
+	return &Store{clientGetter: getter}, getter
+}
+
+func deploymentSchema() *types.APISchema {
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "apps.deployment"}}
+	attributes.SetGVK(schema, schema2.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	attributes.SetGVR(schema, schema2.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"})
+	return schema
+}
+
+func newPatchRequest(query, contentType, body string) *types.APIRequest {
+	req := httptest.NewRequest(http.MethodPatch, "/v1/apps.deployments/default/web"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return &types.APIRequest{Method: http.MethodPatch, Namespace: "default", Name: "web", Schema: deploymentSchema(), Request: req}
+}
+
+func TestUpdateApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		options metav1.PatchOptions
+	}{
+		{
+			name:    "default field manager",
+			options: metav1.PatchOptions{FieldManager: defaultFieldManager},
+		},
+		{
+			name:    "forced by a field manager",
+			query:   "?fieldManager=ci&force=true",
+			options: metav1.PatchOptions{FieldManager: "ci", Force: &[]bool{true}[0]},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s, getter := newProxyStore(newDeployment())
+			apiOp := newPatchRequest(test.query, string(apitypes.ApplyPatchType), "spec:\n  replicas: 3\n")
+
+			_, err := s.Update(apiOp, deploymentSchema(), types.APIObject{}, "web")
+			require.NoError(t, err)
+			require.Len(t, getter.client.calls, 1)
+			assert.Equal(t, call{
+				verb:      "patch",
+				name:      "web",
+				patchType: apitypes.ApplyPatchType,
+				patch:     `{"spec":{"replicas":3}}`,
+				options:   test.options,
+			}, getter.client.calls[0], "the YAML is applied as JSON")
+		})
+	}
+
+	s, _ := newProxyStore(newDeployment())
+	_, err := s.Update(newPatchRequest("", string(apitypes.ApplyPatchType), "spec: [replicas"), deploymentSchema(), types.APIObject{}, "web")
+	assert.True(t, apierrors.IsBadRequest(err), "invalid YAML is rejected")
+}
+
+func TestUpdatePatchTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		patchType   apitypes.PatchType
+		patch       string
+	}{
+		{
+			name:        "json patch",
+			contentType: string(apitypes.JSONPatchType),
+			body:        `[{"op":"replace","path":"/spec/replicas","value":3}]`,
+			patchType:   apitypes.JSONPatchType,
+			patch:       `[{"op":"replace","path":"/spec/replicas","value":3}]`,
+		},
+		{
+			name:        "merge patch",
+			contentType: string(apitypes.MergePatchType),
+			body:        `{"_type":"apps.deployment","spec":{"replicas":3}}`,
+			patchType:   apitypes.MergePatchType,
+			patch:       `{"spec":{"replicas":3},"type":"apps.deployment"}`,
+		},
+		{
+			name:        "strategic merge patch",
+			contentType: string(apitypes.StrategicMergePatchType) + "; charset=utf-8",
+			body:        `{"spec":{"replicas":3}}`,
+			patchType:   apitypes.StrategicMergePatchType,
+			patch:       `{"spec":{"replicas":3}}`,
+		},
+		{
+			name:        "plain json",
+			contentType: "application/json",
+			body:        `{"spec":{"replicas":3}}`,
+			patchType:   apitypes.StrategicMergePatchType,
+			patch:       `{"spec":{"replicas":3}}`,
+		},
+		{
+			name:      "no content type",
+			body:      `{"spec":{"replicas":3}}`,
+			patchType: apitypes.StrategicMergePatchType,
+			patch:     `{"spec":{"replicas":3}}`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s, getter := newProxyStore(newDeployment())
+			_, err := s.Update(newPatchRequest("", test.contentType, test.body), deploymentSchema(), types.APIObject{}, "web")
+			require.NoError(t, err)
+			require.Len(t, getter.client.calls, 1)
+			assert.Equal(t, test.patchType, getter.client.calls[0].patchType)
+			assert.Equal(t, test.patch, getter.client.calls[0].patch)
+		})
+	}
+
+	s, _ := newProxyStore(newDeployment())
+	_, err := s.Update(newPatchRequest("", string(apitypes.MergePatchType), `[{"op":"remove"}]`), deploymentSchema(), types.APIObject{}, "web")
+	assert.True(t, apierrors.IsBadRequest(err), "only json patches may be lists")
+}
+
+func TestWriteOptions(t *testing.T) {
+	const query = "?dryRun=All&fieldManager=ci"
+	request := func(method, query string) *types.APIRequest {
+		return &types.APIRequest{
+			Method:    method,
+			Namespace: "default",
+			Schema:    deploymentSchema(),
+			Request:   httptest.NewRequest(method, "/v1/apps.deployments/default/web"+query, nil),
+		}
+	}
+
+	s, getter := newProxyStore(nil)
+	_, err := s.Create(request(http.MethodPost, query), deploymentSchema(), types.APIObject{Object: newDeployment()})
+	require.NoError(t, err)
+	assert.Equal(t, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}, FieldManager: "ci"}, getter.client.calls[0].options)
+
+	s, getter = newProxyStore(newDeployment())
+	_, err = s.Update(request(http.MethodPut, query), deploymentSchema(), types.APIObject{Object: newDeployment()}, "web")
+	require.NoError(t, err)
+	assert.Equal(t, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}, FieldManager: "ci"}, getter.client.calls[0].options)
+
+	s, getter = newProxyStore(newDeployment())
+	_, err = s.Update(newPatchRequest(query, string(apitypes.MergePatchType), `{}`), deploymentSchema(), types.APIObject{}, "web")
+	require.NoError(t, err)
+	assert.Equal(t, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}, FieldManager: "ci"}, getter.client.calls[0].options)
+
+	s, getter = newProxyStore(newDeployment())
+	_, _ = s.Delete(request(http.MethodDelete, "?dryRun=All&gracePeriodSeconds=0&propagationPolicy=Orphan"), deploymentSchema(), "web")
+	orphan := metav1.DeletePropagationOrphan
+	assert.Equal(t, metav1.DeleteOptions{
+		DryRun:             []string{metav1.DryRunAll},
+		GracePeriodSeconds: &[]int64{0}[0],
+		PropagationPolicy:  &orphan,
+	}, getter.client.calls[0].options)
+
+	s, getter = newProxyStore(newDeployment())
+	_, err = s.Delete(request(http.MethodDelete, "?gracePeriodSeconds=soon"), deploymentSchema(), "web")
+	assert.True(t, apierrors.IsBadRequest(err), "invalid options are rejected")
+	assert.Empty(t, getter.client.calls, "nothing is sent to kubernetes")
+}
+
+func TestSubresources(t *testing.T) {
+	withSubresources := deploymentSchema()
+	attributes.SetSubresources(withSubresources, []string{"status", "scale", "rollback"})
+
+	tests := []struct {
+		name         string
+		link         string
+		schema       *types.APISchema
+		subresources []string
+	}{
+		{name: "object", schema: withSubresources},
+		{name: "scale", link: "scale", schema: withSubresources, subresources: []string{"scale"}},
+		{name: "status", link: "status", schema: withSubresources, subresources: []string{"status"}},
+		{name: "unsupported subresource", link: "rollback", schema: withSubresources},
+		{name: "subresource the schema doesn't have", link: "scale", schema: deploymentSchema()},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			request := func(method string) *types.APIRequest {
+				return &types.APIRequest{
+					Method:    method,
+					Namespace: "default",
+					Link:      test.link,
+					Schema:    test.schema,
+					Request:   httptest.NewRequest(method, "/v1/apps.deployments/default/web", nil),
+				}
+			}
+			s, getter := newProxyStore(newDeployment())
+
+			_, err := s.ByID(request(http.MethodGet), test.schema, "web")
+			require.NoError(t, err)
+			_, err = s.Update(request(http.MethodPut), test.schema, types.APIObject{Object: newDeployment()}, "web")
+			require.NoError(t, err)
+
+			require.Len(t, getter.client.calls, 2)
+			assert.Equal(t, test.subresources, getter.client.calls[0].subresources, "gets are made on the subresource")
+			assert.Equal(t, test.subresources, getter.client.calls[1].subresources, "updates are made on the subresource")
+			table := test.subresources == nil
+			assert.Equal(t, []bool{table, table}, getter.tables, "subresources aren't served as tables")
+		})
+	}
+}
+
+func TestForceDelete(t *testing.T) {
+	terminating := func(finalizers ...string) *unstructured.Unstructured {
+		obj := newDeployment()
+		now := metav1.Now()
+		obj.SetDeletionTimestamp(&now)
+		obj.SetFinalizers(finalizers)
+		return obj
+	}
+	canUpdate := deploymentSchema()
+	attributes.SetAccess(canUpdate, accesscontrol.AccessListByVerb{
+		"update": accesscontrol.AccessList{{Namespace: "default", ResourceName: "web"}},
+	})
+
+	tests := []struct {
+		name   string
+		query  string
+		obj    *unstructured.Unstructured
+		schema *types.APISchema
+		verbs  []string
+		patch  string
+		err    func(error) bool
+	}{
+		{
+			name:   "terminating",
+			query:  "?force=true&dryRun=All",
+			obj:    terminating("example.io/cleanup"),
+			schema: canUpdate,
+			verbs:  []string{"get", "patch", "delete", "get"},
+			patch:  `{"metadata":{"finalizers":null,"resourceVersion":"5"}}`,
+		},
+		{
+			name:   "terminating without finalizers",
+			query:  "?force=true",
+			obj:    terminating(),
+			schema: canUpdate,
+			verbs:  []string{"get", "delete", "get"},
+		},
+		{
+			name:   "not terminating",
+			query:  "?force=true",
+			obj:    newDeployment(),
+			schema: canUpdate,
+			verbs:  []string{"get"},
+			err:    apierrors.IsConflict,
+		},
+		{
+			name:   "without access to update",
+			query:  "?force=true",
+			obj:    terminating("example.io/cleanup"),
+			schema: deploymentSchema(),
+			err:    apierrors.IsForbidden,
+		},
+		{
+			name:   "not forced",
+			obj:    terminating("example.io/cleanup"),
+			schema: deploymentSchema(),
+			verbs:  []string{"delete", "get"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s, getter := newProxyStore(test.obj)
+			apiOp := &types.APIRequest{
+				Method:    http.MethodDelete,
+				Namespace: "default",
+				Schema:    test.schema,
+				Request:   httptest.NewRequest(http.MethodDelete, "/v1/apps.deployments/default/web"+test.query, nil),
+			}
+
+			_, err := s.Delete(apiOp, test.schema, "web")
+			if test.err != nil {
+				assert.True(t, test.err(err), err)
+			}
+			var verbs []string
+			for _, call := range getter.client.calls {
+				verbs = append(verbs, call.verb)
+				if call.verb == "patch" {
+					assert.Equal(t, apitypes.MergePatchType, call.patchType)
+					assert.Equal(t, test.patch, call.patch, "the finalizers are removed if the object is unchanged")
+					assert.Equal(t, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}}, call.options)
+				}
+			}
+			assert.Equal(t, test.verbs, verbs)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package proxy
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
@@ -12,6 +13,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
+const partitionOrderParam = "partitionOrder"
+
 var (
 	passthroughPartitions = []partition.Partition{
 		Partition{Passthrough: true},
@@ -24,6 +27,9 @@ type Partition struct {
 	All         bool
 	Passthrough bool
 	Names       sets.String
+	// Exclude are the namespaces left out of a partition of every namespace, because they have partitions of
+	// their own, so no object is listed twice.
+	Exclude sets.String
 }
 
 // Name returns the name of the partition, which for this type is the namespace.
@@ -36,6 +42,17 @@ type rbacPartitioner struct {
 	proxyStore *Store
 }
 
+// NewPartitioner returns the partitioner of the proxy store of a cluster, which can also partition one cluster of a
+// partition.ClusterPartitioner when a fleet is served at once.
+func NewPartitioner(clientGetter ClientGetter, notifier RelationshipNotifier) partition.Partitioner {
+	return &rbacPartitioner{
+		proxyStore: &Store{
+			clientGetter: clientGetter,
+			notifier:     notifier,
+		},
+	}
+}
+
 // Lookup returns the default passthrough partition which is used only for retrieving single resources.
 // Listing or watching resources require custom partitions.
 func (p *rbacPartitioner) Lookup(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) (partition.Partition, error) {
@@ -46,6 +63,8 @@ func (p *rbacPartitioner) Lookup(apiOp *types.APIRequest, schema *types.APISchem
 		fallthrough
 	case "update":
 		fallthrough
+	case "patch":
+		fallthrough
 	case "delete":
 		return passthroughPartitions[0], nil
 	default:
@@ -85,6 +104,32 @@ func (p *rbacPartitioner) All(apiOp *types.APIRequest, schema *types.APISchema,
 	}
 }
 
+// SortPartitions lists the namespaces named in the comma separated partitionOrder query parameter first,
+// in the order given, followed by the remaining partitions in namespace order.
+func (p *rbacPartitioner) SortPartitions(apiOp *types.APIRequest, schema *types.APISchema, partitions []partition.Partition) []partition.Partition {
+	order := apiOp.Request.URL.Query().Get(partitionOrderParam)
+	if order == "" {
+		return partitions
+	}
+
+	priority := map[string]int{}
+	for i, ns := range strings.Split(order, ",") {
+		if _, ok := priority[ns]; !ok {
+			priority[ns] = i
+		}
+	}
+
+	sort.SliceStable(partitions, func(i, j int) bool {
+		pi, iok := priority[partitions[i].Name()]
+		pj, jok := priority[partitions[j].Name()]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+	return partitions
+}
+
 // Store returns a proxy Store suited to listing and watching resources by partition.
 func (p *rbacPartitioner) Store(apiOp *types.APIRequest, partition partition.Partition) (types.Store, error) {
 	return &byNameOrNamespaceStore{
@@ -104,11 +149,22 @@ func (b *byNameOrNamespaceStore) List(apiOp *types.APIRequest, schema *types.API
 		return b.Store.List(apiOp, schema)
 	}
 
-	apiOp.Namespace = b.partition.Namespace
+	apiOp.Namespace = b.partition.namespace()
 	if b.partition.All {
 		return b.Store.List(apiOp, schema)
 	}
-	return b.Store.ByNames(apiOp, schema, b.partition.Names)
+	list, err := b.Store.ByNames(apiOp, schema, b.partition.Names)
+	if err != nil || b.partition.Exclude.Len() == 0 {
+		return list, err
+	}
+	var filtered []types.APIObject
+	for _, obj := range list.Objects {
+		if !b.partition.Exclude.Has(obj.Namespace()) {
+			filtered = append(filtered, obj)
+		}
+	}
+	list.Objects = filtered
+	return list, nil
 }
 
 // Watch returns a channel of resources by partition.
@@ -117,15 +173,40 @@ func (b *byNameOrNamespaceStore) Watch(apiOp *types.APIRequest, schema *types.AP
 		return b.Store.Watch(apiOp, schema, wr)
 	}
 
-	apiOp.Namespace = b.partition.Namespace
+	apiOp.Namespace = b.partition.namespace()
 	if b.partition.All {
 		return b.Store.Watch(apiOp, schema, wr)
 	}
-	return b.Store.WatchNames(apiOp, schema, wr, b.partition.Names)
+	c, err := b.Store.WatchNames(apiOp, schema, wr, b.partition.Names)
+	if err != nil || b.partition.Exclude.Len() == 0 {
+		return c, err
+	}
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		for event := range c {
+			if event.Error == nil && event.Name != partition.BookmarkAPIEvent && b.partition.Exclude.Has(event.Object.Namespace()) {
+				continue
+			}
+			result <- event
+		}
+	}()
+	return result, nil
+}
+
+// namespace returns the namespace of the requests of a partition, which is every namespace for the names granted
+// in all namespaces.
+func (p Partition) namespace() string {
+	if p.Namespace == accesscontrol.All {
+		return ""
+	}
+	return p.Namespace
 }
 
 // isPassthrough determines whether a request can be passed through directly to the underlying store
 // or if the results need to be partitioned by namespace and name based on the requester's access.
+// Names granted in every namespace, as by a clusterrolebinding, are added to the partition of each namespace and
+// get a partition of every other namespace, so the same objects are listed and watched as may be fetched.
 func isPassthrough(apiOp *types.APIRequest, schema *types.APISchema, verb string) ([]partition.Partition, bool) {
 	accessListByVerb, _ := attributes.Access(schema).(accesscontrol.AccessListByVerb)
 	if accessListByVerb.All(verb) {
@@ -133,6 +214,18 @@ func isPassthrough(apiOp *types.APIRequest, schema *types.APISchema, verb string
 	}
 
 	resources := accessListByVerb.Granted(verb)
+	if !attributes.Namespaced(schema) {
+		var result []partition.Partition
+		for _, v := range resources {
+			result = append(result, Partition{
+				All:   v.All,
+				Names: v.Names,
+			})
+		}
+		return result, false
+	}
+
+	everyNamespace := resources[accesscontrol.All].Names
 	if apiOp.Namespace != "" {
 		if resources[apiOp.Namespace].All {
 			return nil, true
@@ -140,29 +233,41 @@ func isPassthrough(apiOp *types.APIRequest, schema *types.APISchema, verb string
 		return []partition.Partition{
 			Partition{
 				Namespace: apiOp.Namespace,
-				Names:     resources[apiOp.Namespace].Names,
+				Names:     union(resources[apiOp.Namespace].Names, everyNamespace),
 			},
 		}, false
 	}
 
 	var result []partition.Partition
-
-	if attributes.Namespaced(schema) {
-		for k, v := range resources {
-			result = append(result, Partition{
-				Namespace: k,
-				All:       v.All,
-				Names:     v.Names,
-			})
+	exclude := sets.String{}
+	for k, v := range resources {
+		if k == accesscontrol.All {
+			continue
 		}
-	} else {
-		for _, v := range resources {
-			result = append(result, Partition{
-				All:   v.All,
-				Names: v.Names,
-			})
+		exclude.Insert(k)
+		p := Partition{
+			Namespace: k,
+			All:       v.All,
+		}
+		if !v.All {
+			p.Names = union(v.Names, everyNamespace)
 		}
+		result = append(result, p)
+	}
+	if everyNamespace.Len() > 0 {
+		result = append(result, Partition{
+			Namespace: accesscontrol.All,
+			Names:     everyNamespace,
+			Exclude:   exclude,
+		})
 	}
 
 	return result, false
 }
+
+func union(a, b sets.String) sets.String {
+	if b.Len() == 0 {
+		return a
+	}
+	return sets.NewString().Union(a).Union(b)
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestAllPartitions(t *testing.T) {
+	schema := deploymentSchema()
+	attributes.SetNamespaced(schema, true)
+	granted := accesscontrol.AccessList{
+		{Namespace: "dev", ResourceName: accesscontrol.All},
+		{Namespace: "prod", ResourceName: "db"},
+	}
+	attributes.SetAccess(schema, accesscontrol.AccessListByVerb{
+		"list":  granted,
+		"watch": granted,
+		// as granted by a clusterrolebinding
+		"get": accesscontrol.AccessList{{Namespace: accesscontrol.All, ResourceName: "web"}},
+	})
+	everything := deploymentSchema()
+	attributes.SetNamespaced(everything, true)
+	all := accesscontrol.AccessList{{Namespace: accesscontrol.All, ResourceName: accesscontrol.All}}
+	attributes.SetAccess(everything, accesscontrol.AccessListByVerb{"list": all, "watch": all})
+
+	tests := []struct {
+		name      string
+		namespace string
+		id        string
+		schema    *types.APISchema
+		want      []partition.Partition
+	}{
+		{
+			name:   "every namespace",
+			schema: schema,
+			want: []partition.Partition{
+				Partition{Namespace: accesscontrol.All, Names: sets.NewString("web"), Exclude: sets.NewString("dev", "prod")},
+				Partition{Namespace: "dev", All: true},
+				Partition{Namespace: "prod", Names: sets.NewString("db", "web")},
+			},
+		},
+		{
+			name:      "namespace with names",
+			namespace: "prod",
+			schema:    schema,
+			want:      []partition.Partition{Partition{Namespace: "prod", Names: sets.NewString("db", "web")}},
+		},
+		{
+			name:      "namespace with only the names of every namespace",
+			namespace: "staging",
+			schema:    schema,
+			want:      []partition.Partition{Partition{Namespace: "staging", Names: sets.NewString("web")}},
+		},
+		{
+			name:      "namespace with every object",
+			namespace: "dev",
+			schema:    schema,
+			want:      passthroughPartitions,
+		},
+		{
+			name:   "single object",
+			id:     "prod/db",
+			schema: schema,
+			want:   []partition.Partition{Partition{Namespace: "prod", Names: sets.NewString("db")}},
+		},
+		{
+			name:   "every object",
+			schema: everything,
+			want:   passthroughPartitions,
+		},
+	}
+	p := &rbacPartitioner{}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			apiOp := &types.APIRequest{
+				Namespace: test.namespace,
+				Request:   httptest.NewRequest(http.MethodGet, "/v1/apps.deployments", nil),
+			}
+			for _, verb := range []string{"list", "watch"} {
+				partitions, err := p.All(apiOp, test.schema, verb, test.id)
+				require.NoError(t, err)
+				assert.Equal(t, test.want, partitions, "%s: the same objects are listed and watched", verb)
+			}
+		})
+	}
+}
+
+func TestListEveryNamespacePartition(t *testing.T) {
+	object := func(namespace, name string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": name, "namespace": namespace},
+		}}
+	}
+	s, getter := newProxyStore(nil)
+	getter.client.items = []unstructured.Unstructured{object("dev", "web"), object("prod", "web"), object("prod", "db")}
+	p := &rbacPartitioner{proxyStore: s}
+	store, err := p.Store(nil, Partition{Namespace: accesscontrol.All, Names: sets.NewString("web"), Exclude: sets.NewString("dev")})
+	require.NoError(t, err)
+
+	apiOp := &types.APIRequest{
+		Schema:  deploymentSchema(),
+		Request: httptest.NewRequest(http.MethodGet, "/v1/apps.deployments", nil),
+	}
+	list, err := store.List(apiOp, deploymentSchema())
+	require.NoError(t, err)
+	require.Len(t, list.Objects, 1, "objects of other names, and of namespaces with partitions of their own, are left out")
+	assert.Equal(t, "prod/web", list.Objects[0].ID)
+	assert.Equal(t, "", apiOp.Namespace, "the names are listed from every namespace")
+	assert.Equal(t, "metadata.name=web", getter.client.calls[0].options.(metav1.ListOptions).FieldSelector)
+}
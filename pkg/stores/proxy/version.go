@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/slice"
+)
+
+// versionParam is the query parameter of lists and gets requesting one of the served versions of a resource other
+// than the preferred one.
+const versionParam = "version"
+
+// versionStore serves the version of a resource requested with ?version=, which the apiserver converts the objects
+// to, in place of the version of the schema.
+type versionStore struct {
+	types.Store
+}
+
+// ByID looks up a single object by its ID.
+func (v *versionStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	schema, err := forVersion(apiOp, schema)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return v.Store.ByID(apiOp, schema, id)
+}
+
+// List returns a list of resources.
+func (v *versionStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	schema, err := forVersion(apiOp, schema)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	return v.Store.List(apiOp, schema)
+}
+
+// forVersion returns the schema for the version requested, which must be one of the versions served for its
+// resource. Only the version of the copy returned differs from the schema.
+func forVersion(apiOp *types.APIRequest, schema *types.APISchema) (*types.APISchema, error) {
+	if apiOp.Request == nil {
+		return schema, nil
+	}
+	version := apiOp.Request.URL.Query().Get(versionParam)
+	if version == "" || version == attributes.Version(schema) {
+		return schema, nil
+	}
+	versions := attributes.Versions(schema)
+	if !slice.ContainsString(versions, version) {
+		return nil, apierror.NewAPIError(validation.InvalidOption,
+			fmt.Sprintf("version %s is not served for %s, served versions are %s", version, schema.ID, strings.Join(versions, ", ")))
+	}
+
+	copied := *schema
+	inner := *schema.Schema
+	copied.Schema = &inner
+	copied.Attributes = make(map[string]interface{}, len(schema.Attributes))
+	for k, v := range schema.Attributes {
+		copied.Attributes[k] = v
+	}
+	attributes.SetVersion(&copied, version)
+	return &copied, nil
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForVersion(t *testing.T) {
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "autoscaling.horizontalpodautoscaler"}}
+	attributes.SetVersion(schema, "v2")
+	attributes.SetVersions(schema, []string{"v2", "v1"})
+
+	request := func(query string) *types.APIRequest {
+		return &types.APIRequest{Request: httptest.NewRequest("GET", "/v1/autoscaling.horizontalpodautoscalers"+query, nil)}
+	}
+
+	got, err := forVersion(request(""), schema)
+	require.NoError(t, err)
+	assert.Same(t, schema, got)
+
+	got, err = forVersion(request("?version=v1"), schema)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", attributes.Version(got))
+	assert.Equal(t, schema.ID, got.ID)
+	assert.Equal(t, "v2", attributes.Version(schema), "the schema is not changed")
+
+	_, err = forVersion(request("?version=v2beta2"), schema)
+	require.Error(t, err)
+	apiErr, ok := err.(*apierror.APIError)
+	require.True(t, ok)
+	assert.Equal(t, 422, apiErr.Code.Status)
+}
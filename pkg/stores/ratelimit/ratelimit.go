@@ -0,0 +1,203 @@
+// Package ratelimit limits the rate of requests each user makes to a store, to protect the kubernetes apiserver
+// behind it.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	limiterCacheSize = 10000
+	limiterTTL       = 10 * time.Minute
+)
+
+var tooManyRequests = validation.ErrorCode{Code: "TooManyRequests", Status: http.StatusTooManyRequests}
+
+// Limit is the rate of a token bucket: QPS requests a second on average, in bursts of up to Burst requests.
+// A QPS of zero means no limit.
+type Limit struct {
+	QPS   float64
+	Burst int
+}
+
+// Options configure the limits of a Store.
+type Options struct {
+	// Limit is the limit of each user across every schema without an override.
+	Limit
+	// Overrides are separate limits of each user for a schema, by schema ID, for types that are expensive to list
+	// such as pods and events.
+	Overrides map[string]Limit
+}
+
+// Enabled returns whether any limit is set.
+func (o Options) Enabled() bool {
+	if o.QPS > 0 {
+		return true
+	}
+	for _, limit := range o.Overrides {
+		if limit.QPS > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseOverrides parses limits of the form schema=qps:burst, separated by commas, such as "pod=5:10,event=2:5".
+func ParseOverrides(value string) (map[string]Limit, error) {
+	result := map[string]Limit{}
+	for _, override := range strings.Split(value, ",") {
+		override = strings.TrimSpace(override)
+		if override == "" {
+			continue
+		}
+		schemaID, limit, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q, expected schema=qps:burst", override)
+		}
+		qps, burst, _ := strings.Cut(limit, ":")
+		l := Limit{}
+		var err error
+		if l.QPS, err = strconv.ParseFloat(qps, 64); err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %w", override, err)
+		}
+		if burst != "" {
+			if l.Burst, err = strconv.Atoi(burst); err != nil {
+				return nil, fmt.Errorf("invalid rate limit %q: %w", override, err)
+			}
+		}
+		result[schemaID] = l
+	}
+	return result, nil
+}
+
+// Store rejects the requests of a user once the user is over its limit, with a 429 response and a Retry-After
+// header, before they reach the wrapped store.
+type Store struct {
+	types.Store
+	options  Options
+	lock     sync.Mutex
+	limiters *cache.LRUExpireCache
+}
+
+// NewRateLimitStore returns a Store which limits the rate of requests to store.
+func NewRateLimitStore(store types.Store, options Options) *Store {
+	return &Store{
+		Store:    store,
+		options:  options,
+		limiters: cache.NewLRUExpireCache(limiterCacheSize),
+	}
+}
+
+// ByID looks up a single object by its ID.
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := s.wait(apiOp, schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.ByID(apiOp, schema, id)
+}
+
+// List returns a list of objects.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	if err := s.wait(apiOp, schema); err != nil {
+		return types.APIObjectList{}, err
+	}
+	return s.Store.List(apiOp, schema)
+}
+
+// Create creates a single object in the store.
+func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	if err := s.wait(apiOp, schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Create(apiOp, schema, data)
+}
+
+// Update updates a single object in the store.
+func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	if err := s.wait(apiOp, schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Update(apiOp, schema, data, id)
+}
+
+// Delete deletes an object from a store.
+func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := s.wait(apiOp, schema); err != nil {
+		return types.APIObject{}, err
+	}
+	return s.Store.Delete(apiOp, schema, id)
+}
+
+// Watch returns a channel of events for a list or resource.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	if err := s.wait(apiOp, schema); err != nil {
+		return nil, err
+	}
+	return s.Store.Watch(apiOp, schema, wr)
+}
+
+// wait takes a token from the bucket of the user for the schema, or returns an error saying when to retry.
+func (s *Store) wait(apiOp *types.APIRequest, schema *types.APISchema) error {
+	limiter := s.limiter(apiOp, schema)
+	if limiter == nil {
+		return nil
+	}
+
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return apierror.NewAPIError(tooManyRequests, "request is larger than the rate limit")
+	}
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+	reservation.Cancel()
+
+	if apiOp.Response != nil {
+		apiOp.Response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	}
+	return apierror.NewAPIError(tooManyRequests, fmt.Sprintf("rate limit exceeded for %s, retry in %s", schema.ID, delay.Round(time.Millisecond)))
+}
+
+// limiter returns the token bucket of the user for the schema, or nil if the schema has no limit.
+func (s *Store) limiter(apiOp *types.APIRequest, schema *types.APISchema) *rate.Limiter {
+	limit, key := s.options.Limit, ""
+	if override, ok := s.options.Overrides[schema.ID]; ok {
+		limit, key = override, schema.ID
+	}
+	if limit.QPS <= 0 {
+		return nil
+	}
+
+	user, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return nil
+	}
+	key = user.GetName() + "/" + key
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if limiter, ok := s.limiters.Get(key); ok {
+		return limiter.(*rate.Limiter)
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(limit.QPS))
+	}
+	limiter := rate.NewLimiter(rate.Limit(limit.QPS), burst)
+	s.limiters.Add(key, limiter, limiterTTL)
+	return limiter
+}
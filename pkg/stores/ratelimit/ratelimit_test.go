@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		users   []string
+		allowed int
+	}{
+		{
+			name:    "user limit",
+			schema:  "configmap",
+			users:   []string{"alice", "alice", "alice", "alice"},
+			allowed: 2,
+		},
+		{
+			name:    "override",
+			schema:  "pod",
+			users:   []string{"alice", "alice", "alice", "alice"},
+			allowed: 1,
+		},
+		{
+			name:    "users have separate limits",
+			schema:  "pod",
+			users:   []string{"alice", "bob", "alice", "bob"},
+			allowed: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewRateLimitStore(&empty.Store{}, Options{
+				Limit:     Limit{QPS: 0.001, Burst: 2},
+				Overrides: map[string]Limit{"pod": {QPS: 0.001, Burst: 1}},
+			})
+			schema := &types.APISchema{Schema: &schemas.Schema{ID: test.schema}}
+
+			var allowed int
+			for _, name := range test.users {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				apiOp := &types.APIRequest{
+					Request:  req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: name})),
+					Response: rw,
+				}
+				_, err := store.List(apiOp, schema)
+				var apiErr *apierror.APIError
+				if errors.As(err, &apiErr) && apiErr.Code.Status == http.StatusTooManyRequests {
+					assert.NotEmpty(t, rw.Header().Get("Retry-After"))
+					continue
+				}
+				allowed++
+			}
+			assert.Equal(t, test.allowed, allowed)
+		})
+	}
+}
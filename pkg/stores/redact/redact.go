@@ -0,0 +1,259 @@
+// Package redact masks sensitive values, such as the data of secrets, in the objects returned by a store.
+package redact
+
+import (
+	"context"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// UnredactedVerb is the RBAC verb that grants access to the unmasked sensitive values of a resource.
+const UnredactedVerb = "unredacted"
+
+var secretGVK = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+
+type exemptKey struct{}
+
+// WithExemptFields returns a context in which the Store leaves the values at the field paths unmasked. It is for
+// callers that read a sensitive value on behalf of the user without returning it, such as the helm releases stored
+// in secrets, once the wrapped store checked that the user may get the object.
+func WithExemptFields(ctx context.Context, fields ...[]string) context.Context {
+	return context.WithValue(ctx, exemptKey{}, fields)
+}
+
+// Store masks the sensitive fields of every object listed, fetched or watched, unless the caller is granted the
+// unredacted verb on the object. Each value of a sensitive map, such as the data of a secret, is replaced by an
+// empty string, so its keys are still visible. The masked values left in an update are replaced by the values they
+// hide, so that sending back a masked object keeps them.
+type Store struct {
+	types.Store
+	asl accesscontrol.AccessSetLookup
+}
+
+// NewRedactStore returns a Store which masks the sensitive fields of the objects returned by store.
+func NewRedactStore(store types.Store, asl accesscontrol.AccessSetLookup) *Store {
+	return &Store{
+		Store: store,
+		asl:   asl,
+	}
+}
+
+// ByID looks up a single object by its ID.
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	obj, err := s.Store.ByID(apiOp, schema, id)
+	if err != nil {
+		return obj, err
+	}
+	return s.redactor(apiOp, schema).redact(obj), nil
+}
+
+// List returns a list of objects.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	list, err := s.Store.List(apiOp, schema)
+	if err != nil {
+		return list, err
+	}
+	r := s.redactor(apiOp, schema)
+	for i := range list.Objects {
+		list.Objects[i] = r.redact(list.Objects[i])
+	}
+	return list, nil
+}
+
+// Update updates a single object, keeping the values of its sensitive fields that are still masked.
+func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	r := s.redactor(apiOp, schema)
+	if len(r.fields) > 0 && data.Object != nil {
+		current, err := s.Store.ByID(apiOp, schema, id)
+		if err != nil {
+			return current, err
+		}
+		if !r.grants(current.Namespace(), current.Name()) {
+			obj := data.Data()
+			for _, field := range r.fields {
+				obj = unmask(obj, current.Data(), field)
+			}
+			data.Object = &unstructured.Unstructured{Object: obj}
+		}
+	}
+	obj, err := s.Store.Update(apiOp, schema, data, id)
+	if err != nil {
+		return obj, err
+	}
+	return r.redact(obj), nil
+}
+
+// Watch returns a channel of events for a list or resource.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	c, err := s.Store.Watch(apiOp, schema, wr)
+	r := s.redactor(apiOp, schema)
+	if err != nil || c == nil || len(r.fields) == 0 {
+		return c, err
+	}
+
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		for event := range c {
+			event.Object = r.redact(event.Object)
+			select {
+			case result <- event:
+			case <-apiOp.Context().Done():
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// Fields returns the sensitive field paths of a schema: those set by attributes.SetSensitiveFields, or the data
+// and stringData of secrets.
+func Fields(schema *types.APISchema) [][]string {
+	if fields := attributes.SensitiveFields(schema); fields != nil {
+		return fields
+	}
+	if attributes.GVK(schema) == secretGVK {
+		return [][]string{{"data"}, {"stringData"}}
+	}
+	return nil
+}
+
+// Mask returns a copy of data with the values at the field paths masked, leaving data itself unchanged.
+func Mask(data map[string]interface{}, fields [][]string) map[string]interface{} {
+	for _, field := range fields {
+		data = mask(data, field)
+	}
+	return data
+}
+
+type redactor struct {
+	fields [][]string
+	exempt [][]string
+	grants func(namespace, name string) bool
+}
+
+// redactor returns the redactor for the schema and the requesting user.
+func (s *Store) redactor(apiOp *types.APIRequest, schema *types.APISchema) redactor {
+	r := redactor{
+		fields: Fields(schema),
+		grants: func(namespace, name string) bool { return false },
+	}
+	r.exempt, _ = apiOp.Context().Value(exemptKey{}).([][]string)
+	if user, ok := request.UserFrom(apiOp.Context()); ok && len(r.fields) > 0 {
+		access := s.asl.AccessFor(user)
+		gr := attributes.GR(schema)
+		r.grants = func(namespace, name string) bool {
+			return access.Grants(UnredactedVerb, gr, namespace, name)
+		}
+	}
+	return r
+}
+
+// redact returns a copy of the object with its sensitive fields masked, unless the user may see them.
+func (r redactor) redact(obj types.APIObject) types.APIObject {
+	if len(r.fields) == 0 || obj.Object == nil || r.grants(obj.Namespace(), obj.Name()) {
+		return obj
+	}
+	data := obj.Data()
+	masked := Mask(data, r.fields)
+	for _, field := range r.exempt {
+		masked = restore(masked, data, field)
+	}
+	obj.Object = &unstructured.Unstructured{Object: masked}
+	return obj
+}
+
+// restore returns a copy of the masked obj with the value of the original at the field path, sharing every map
+// that is not on the path.
+func restore(obj, original map[string]interface{}, field []string) map[string]interface{} {
+	val, ok := original[field[0]]
+	if !ok {
+		return obj
+	}
+	result := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		result[k] = v
+	}
+	if len(field) == 1 {
+		result[field[0]] = val
+		return result
+	}
+	originalChild, isMap := val.(map[string]interface{})
+	child, maskedMap := obj[field[0]].(map[string]interface{})
+	if !isMap || !maskedMap {
+		return obj
+	}
+	result[field[0]] = restore(child, originalChild, field[1:])
+	return result
+}
+
+// unmask returns a copy of obj with the masked values at the field path replaced by those of current, sharing every
+// map that is not on the path. A masked value is an empty string, so an empty value can't be set on a sensitive
+// field by a user who can't see it.
+func unmask(obj, current map[string]interface{}, field []string) map[string]interface{} {
+	val, ok := obj[field[0]]
+	currentVal, found := current[field[0]]
+	if !ok || !found {
+		return obj
+	}
+	child, isMap := val.(map[string]interface{})
+	currentChild, currentIsMap := currentVal.(map[string]interface{})
+	result := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		result[k] = v
+	}
+	switch {
+	case len(field) > 1 && isMap && currentIsMap:
+		result[field[0]] = unmask(child, currentChild, field[1:])
+	case len(field) > 1:
+		return obj
+	case isMap && currentIsMap:
+		unmasked := make(map[string]interface{}, len(child))
+		for k, v := range child {
+			if currentValue, ok := currentChild[k]; ok && v == "" {
+				v = currentValue
+			}
+			unmasked[k] = v
+		}
+		result[field[0]] = unmasked
+	case val == "":
+		result[field[0]] = currentVal
+	default:
+		return obj
+	}
+	return result
+}
+
+// mask returns a copy of obj with the value at the field path masked, sharing every map that is not on the path.
+// If the value is a map its values are masked, and otherwise the value itself is.
+func mask(obj map[string]interface{}, field []string) map[string]interface{} {
+	val, ok := obj[field[0]]
+	if !ok || val == nil {
+		return obj
+	}
+	result := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		result[k] = v
+	}
+	child, isMap := val.(map[string]interface{})
+	switch {
+	case len(field) > 1 && isMap:
+		result[field[0]] = mask(child, field[1:])
+	case len(field) > 1:
+		return obj
+	case isMap:
+		masked := make(map[string]interface{}, len(child))
+		for k := range child {
+			masked[k] = ""
+		}
+		result[field[0]] = masked
+	default:
+		result[field[0]] = ""
+	}
+	return result
+}
@@ -0,0 +1,163 @@
+package redact
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestRedact(t *testing.T) {
+	newSecret := func() types.APIObject {
+		return types.APIObject{
+			Object: &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":      "secret",
+					"namespace": "default",
+				},
+				"type": "Opaque",
+				"data": map[string]interface{}{
+					"username": "YWRtaW4=",
+					"password": "c2VjcmV0",
+				},
+			}},
+		}
+	}
+	tests := []struct {
+		name    string
+		granted bool
+		want    map[string]interface{}
+	}{
+		{
+			name: "masked without the unredacted verb",
+			want: map[string]interface{}{
+				"username": "",
+				"password": "",
+			},
+		},
+		{
+			name:    "unmasked with the unredacted verb",
+			granted: true,
+			want: map[string]interface{}{
+				"username": "YWRtaW4=",
+				"password": "c2VjcmV0",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := redactor{
+				fields: [][]string{{"data"}, {"stringData"}},
+				grants: func(namespace, name string) bool { return test.granted },
+			}
+			original := newSecret()
+			got := r.redact(original)
+			assert.Equal(t, test.want, got.Data()["data"])
+			assert.Equal(t, "Opaque", got.Data()["type"])
+			want := newSecret()
+			assert.Equal(t, want.Data(), original.Data(), "the original object must not be modified")
+		})
+	}
+}
+
+func TestRedactExemptFields(t *testing.T) {
+	r := redactor{
+		fields: [][]string{{"data"}, {"stringData"}},
+		exempt: [][]string{{"data", "release"}, {"data", "missing"}},
+		grants: func(namespace, name string) bool { return false },
+	}
+	original := types.APIObject{Object: &unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{
+			"release":  "cmVsZWFzZQ==",
+			"password": "c2VjcmV0",
+		},
+	}}}
+	got := r.redact(original)
+	assert.Equal(t, map[string]interface{}{"release": "cmVsZWFzZQ==", "password": ""}, got.Data()["data"])
+	assert.Equal(t, "c2VjcmV0", original.Data().String("data", "password"), "the original object must not be modified")
+}
+
+type fakeStore struct {
+	empty.Store
+	current types.APIObject
+	updated types.APIObject
+}
+
+func (f *fakeStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	return f.current, nil
+}
+
+func (f *fakeStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	f.updated = data
+	return data, nil
+}
+
+type fakeAccessSetLookup struct {
+	access *accesscontrol.AccessSet
+}
+
+func (f fakeAccessSetLookup) AccessFor(user.Info) *accesscontrol.AccessSet {
+	return f.access
+}
+
+func (f fakeAccessSetLookup) PurgeUserData(string) {}
+
+func TestUpdateKeepsMaskedValues(t *testing.T) {
+	secret := func(data map[string]interface{}) types.APIObject {
+		return types.APIObject{Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "secret", "namespace": "default"},
+			"data":     data,
+		}}}
+	}
+	secretSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret", Attributes: map[string]interface{}{}}}
+	attributes.SetGVK(secretSchema, schema.GroupVersionKind{Version: "v1", Kind: "Secret"})
+	attributes.SetGR(secretSchema, schema.GroupResource{Resource: "secrets"})
+	unredacted := &accesscontrol.AccessSet{}
+	unredacted.Add(UnredactedVerb, schema.GroupResource{Resource: "secrets"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: accesscontrol.All})
+
+	tests := []struct {
+		name    string
+		access  *accesscontrol.AccessSet
+		update  map[string]interface{}
+		updated map[string]interface{}
+		result  map[string]interface{}
+	}{
+		{
+			name:    "masked values are kept",
+			access:  &accesscontrol.AccessSet{},
+			update:  map[string]interface{}{"username": "", "password": "bmV3", "token": ""},
+			updated: map[string]interface{}{"username": "YWRtaW4=", "password": "bmV3", "token": ""},
+			result:  map[string]interface{}{"username": "", "password": "", "token": ""},
+		},
+		{
+			name:    "users granted the unredacted verb may clear values",
+			access:  unredacted,
+			update:  map[string]interface{}{"username": "", "password": "bmV3"},
+			updated: map[string]interface{}{"username": "", "password": "bmV3"},
+			result:  map[string]interface{}{"username": "", "password": "bmV3"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			store := &fakeStore{current: secret(map[string]interface{}{"username": "YWRtaW4=", "password": "c2VjcmV0"})}
+			req := httptest.NewRequest("PUT", "/v1/secrets/default/secret", nil)
+			apiOp := &types.APIRequest{Request: req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "jane"}))}
+
+			got, err := NewRedactStore(store, fakeAccessSetLookup{access: test.access}).Update(apiOp, secretSchema, secret(test.update), "default/secret")
+			require.NoError(t, err)
+			assert.Equal(t, test.updated, store.updated.Data()["data"], "the values sent to the store")
+			assert.Equal(t, test.result, got.Data()["data"], "the values returned to the user")
+		})
+	}
+}
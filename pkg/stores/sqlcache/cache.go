@@ -0,0 +1,246 @@
+// Package sqlcache mirrors the objects of chosen types into tables of a SQL database and serves lists of them from
+// there, with the filters, sorting and pagination of the request done in SQL, rather than listing from kubernetes
+// for every request. The queries are written for SQLite, whose driver the embedder registers and opens the
+// database with.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/wrangler/pkg/slice"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+var invalidTableChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Options configure the SQL cache.
+type Options struct {
+	// DB is the database the objects are mirrored into, opened with a SQLite driver. Nothing is cached without it.
+	DB *sql.DB
+	// Schemas are the IDs of the schemas whose objects are cached, such as pod and event.
+	Schemas []string
+	// IndexedFields are the paths of the fields indexed in the table of a schema, by schema ID, to speed up the
+	// sorts on them, such as metadata.creationTimestamp for events.
+	IndexedFields map[string][]string
+	// TablePrefix is prepended to the names of the tables, so that the caches of several clusters may share a
+	// database.
+	TablePrefix string
+}
+
+// Enabled returns whether any schema is cached.
+func (o Options) Enabled() bool {
+	return o.DB != nil && len(o.Schemas) > 0
+}
+
+// Cache is the set of tables mirroring the objects of the cached schemas. A table is created, and its informer
+// started, on the first list of its schema.
+type Cache struct {
+	sync.Mutex
+
+	ctx     context.Context
+	client  dynamic.Interface
+	options Options
+	tables  map[string]*table
+}
+
+// New returns a cache of the objects listed and watched with an admin client, whose informers run until the
+// context is done.
+func New(ctx context.Context, client dynamic.Interface, options Options) *Cache {
+	return &Cache{
+		ctx:     ctx,
+		client:  client,
+		options: options,
+		tables:  map[string]*table{},
+	}
+}
+
+// table returns the table of a schema, or nil if the schema isn't cached.
+func (c *Cache) table(schema *types.APISchema) *table {
+	if !slice.ContainsString(c.options.Schemas, schema.ID) {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if t, ok := c.tables[schema.ID]; ok {
+		return t
+	}
+
+	gvr := attributes.GVR(schema)
+	if gvr.Resource == "" {
+		return nil
+	}
+	t := &table{
+		db:   c.options.DB,
+		name: "steve_" + invalidTableChars.ReplaceAllString(c.options.TablePrefix+schema.ID, "_"),
+	}
+	if err := t.create(c.ctx, c.options.IndexedFields[schema.ID]); err != nil {
+		logrus.Errorf("failed to create the cache table of %s: %v", schema.ID, err)
+		return nil
+	}
+
+	informer := dynamicinformer.NewFilteredDynamicInformer(c.client, gvr, metav1.NamespaceAll, 0, cache.Indexers{}, nil).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			t.upsert(c.ctx, obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			t.upsert(c.ctx, obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			t.delete(c.ctx, obj)
+		},
+	})
+	t.synced = informer.HasSynced
+	go informer.Run(c.ctx.Done())
+
+	c.tables[schema.ID] = t
+	return t
+}
+
+// table is the table of the objects of a schema, keyed by namespace/name.
+type table struct {
+	sync.RWMutex
+
+	db     *sql.DB
+	name   string
+	synced func() bool
+	rev    string
+}
+
+// create creates the table, or empties it of the objects of an earlier run, with indexes of the namespaces and
+// names and of the values of the indexed fields.
+func (t *table) create(ctx context.Context, indexedFields []string) error {
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, namespace TEXT NOT NULL, name TEXT NOT NULL, object TEXT NOT NULL)`, quote(t.name)),
+		fmt.Sprintf(`DELETE FROM %s`, quote(t.name)),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (namespace, name)`, quote(t.name+"_namespace"), quote(t.name)),
+	}
+	for i, field := range listprocessor.ParseFields(indexedFields) {
+		path, ok := jsonPath(field)
+		if !ok {
+			return fmt.Errorf("invalid indexed field %q", strings.Join(field, "."))
+		}
+		statements = append(statements, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (%s)`,
+			quote(fmt.Sprintf("%s_field%d", t.name, i)), quote(t.name), extract(path)))
+	}
+	for _, statement := range statements {
+		if _, err := t.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *table) upsert(ctx context.Context, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	bytes, err := json.Marshal(u.Object)
+	if err != nil {
+		logrus.Errorf("failed to encode %s/%s for the cache: %v", u.GetNamespace(), u.GetName(), err)
+		return
+	}
+	key, _ := cache.MetaNamespaceKeyFunc(u)
+	_, err = t.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (key, namespace, name, object) VALUES (?, ?, ?, ?)
+ON CONFLICT (key) DO UPDATE SET object = excluded.object`, quote(t.name)), key, u.GetNamespace(), u.GetName(), string(bytes))
+	if err != nil {
+		logrus.Errorf("failed to cache %s: %v", key, err)
+		return
+	}
+	t.setRevision(u.GetResourceVersion())
+}
+
+func (t *table) delete(ctx context.Context, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	if _, err := t.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE key = ?`, quote(t.name)), key); err != nil {
+		logrus.Errorf("failed to remove %s from the cache: %v", key, err)
+		return
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		t.setRevision(u.GetResourceVersion())
+	}
+}
+
+func (t *table) setRevision(revision string) {
+	t.Lock()
+	defer t.Unlock()
+	t.rev = revision
+}
+
+// revision returns the resourceVersion of the last event mirrored into the table.
+func (t *table) revision() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.rev
+}
+
+// count returns the number of objects the query matches.
+func (t *table) count(ctx context.Context, q *query) (int, error) {
+	statement, args := q.countSQL(t.name)
+	var count int
+	err := t.db.QueryRowContext(ctx, statement, args...).Scan(&count)
+	return count, err
+}
+
+// list returns the objects the query matches, as the proxy store returns them.
+func (t *table) list(ctx context.Context, q *query, schema *types.APISchema) ([]types.APIObject, error) {
+	statement, args := q.selectSQL(t.name)
+	rows, err := t.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []types.APIObject
+	for rows.Next() {
+		var key, object string
+		if err := rows.Scan(&key, &object); err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(object), &u.Object); err != nil {
+			return nil, err
+		}
+		result = append(result, types.APIObject{
+			Type:   schema.ID,
+			ID:     key,
+			Object: moveToUnderscore(u),
+		})
+	}
+	return result, rows.Err()
+}
+
+// moveToUnderscore renames the fields of an object that the API reserves, as the proxy store does.
+func moveToUnderscore(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	for k := range types.ReservedFields {
+		if v, ok := obj.Object[k]; ok {
+			delete(obj.Object, k)
+			obj.Object["_"+k] = v
+		}
+	}
+	return obj
+}
+
+// quote returns a quoted SQL identifier.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
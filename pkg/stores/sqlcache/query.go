@@ -0,0 +1,174 @@
+package sqlcache
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+)
+
+// query is a list query of a table, built from the access of the user and the options of the request.
+type query struct {
+	where  []string
+	args   []interface{}
+	order  []string
+	limit  int
+	offset int
+}
+
+func (q *query) and(condition string, args ...interface{}) {
+	q.where = append(q.where, condition)
+	q.args = append(q.args, args...)
+}
+
+// access restricts the query to the objects of a namespace, if set, which the user can list. It returns false if
+// the user can list none of them.
+func (q *query) access(accessList accesscontrol.AccessListByVerb, namespace string) bool {
+	if namespace != "" {
+		q.and("namespace = ?", namespace)
+	}
+	if accessList.All("list") {
+		return true
+	}
+
+	granted := accessList.Granted("list")
+	namespaces := make([]string, 0, len(granted))
+	for ns := range granted {
+		if namespace == "" || ns == namespace || ns == accesscontrol.All {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	for _, ns := range namespaces {
+		var condition []string
+		if ns != accesscontrol.All {
+			condition = append(condition, "namespace = ?")
+			args = append(args, ns)
+		}
+		if resources := granted[ns]; !resources.All {
+			names := resources.Names.List()
+			if len(names) == 0 {
+				continue
+			}
+			condition = append(condition, "name IN ("+placeholders(len(names))+")")
+			for _, name := range names {
+				args = append(args, name)
+			}
+		}
+		if len(condition) == 0 {
+			// every object of the namespace, or of every namespace
+			return true
+		}
+		conditions = append(conditions, strings.Join(condition, " AND "))
+	}
+	if len(conditions) == 0 {
+		return false
+	}
+	q.and("(("+strings.Join(conditions, ") OR (")+"))", args...)
+	return true
+}
+
+// filter restricts the query to the objects with a field of the value of the filter, compared as the list
+// processor compares them. It returns false if the field can't be written as a JSON path.
+func (q *query) filter(f listprocessor.Filter) bool {
+	path, ok := jsonPath(f.Field())
+	if !ok {
+		return false
+	}
+	value := extract(path)
+	conditions := []string{value + " = ?"}
+	args := []interface{}{f.Match()}
+	if number, err := strconv.ParseFloat(f.Match(), 64); err == nil {
+		conditions = append(conditions, fmt.Sprintf("(json_type(object, %s) IN ('integer', 'real') AND %s = ?)", literal(path), value))
+		args = append(args, number)
+	}
+	if f.Match() == "true" || f.Match() == "false" {
+		// SQLite extracts booleans as 1 and 0
+		conditions = append(conditions, fmt.Sprintf("json_type(object, %s) = ?", literal(path)))
+		args = append(args, f.Match())
+	}
+	q.and("("+strings.Join(conditions, " OR ")+")", args...)
+	return true
+}
+
+// sort orders the query by the fields of the sort, then by namespace and name so pages are stable. It returns false
+// if a field can't be written as a JSON path.
+func (q *query) sort(s listprocessor.Sort) bool {
+	for _, field := range s.Fields {
+		path, ok := jsonPath(field.Field())
+		if !ok {
+			return false
+		}
+		order := extract(path)
+		if field.Order() == listprocessor.DESC {
+			order += " DESC"
+		}
+		q.order = append(q.order, order)
+	}
+	q.order = append(q.order, "namespace", "name")
+	return true
+}
+
+func (q *query) whereSQL() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// countSQL returns the statement counting the objects of the query in a table.
+func (q *query) countSQL(table string) (string, []interface{}) {
+	return "SELECT COUNT(*) FROM " + quote(table) + q.whereSQL(), q.args
+}
+
+// selectSQL returns the statement selecting the keys and objects of the query in a table.
+func (q *query) selectSQL(table string) (string, []interface{}) {
+	statement := "SELECT key, object FROM " + quote(table) + q.whereSQL()
+	args := q.args
+	if len(q.order) > 0 {
+		statement += " ORDER BY " + strings.Join(q.order, ", ")
+	}
+	if q.limit > 0 {
+		statement += " LIMIT ? OFFSET ?"
+		args = append(append([]interface{}{}, args...), q.limit, q.offset)
+	}
+	return statement, args
+}
+
+// jsonPath returns the SQLite JSON path of a field path, and false if a key can't be quoted in one.
+func jsonPath(field []string) (string, bool) {
+	if len(field) == 0 {
+		return "", false
+	}
+	path := &strings.Builder{}
+	path.WriteString("$")
+	for _, key := range field {
+		if strings.ContainsAny(key, `"\`) {
+			return "", false
+		}
+		path.WriteString(`."` + key + `"`)
+	}
+	return path.String(), true
+}
+
+// extract returns the expression of the value at a JSON path of the objects. The path is inlined rather than bound
+// so the expression matches the indexes of the indexed fields.
+func extract(path string) string {
+	return "json_extract(object, " + literal(path) + ")"
+}
+
+func literal(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,96 @@
+package sqlcache
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryAccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		access    accesscontrol.AccessListByVerb
+		namespace string
+		wantOK    bool
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:   "all",
+			access: accesscontrol.AccessListByVerb{"list": {{Namespace: "*", ResourceName: "*"}}},
+			wantOK: true,
+		},
+		{
+			name:      "all in namespace",
+			access:    accesscontrol.AccessListByVerb{"list": {{Namespace: "*", ResourceName: "*"}}},
+			namespace: "default",
+			wantOK:    true,
+			wantWhere: " WHERE namespace = ?",
+			wantArgs:  []interface{}{"default"},
+		},
+		{
+			name: "namespaces and names",
+			access: accesscontrol.AccessListByVerb{
+				"list": {{Namespace: "b", ResourceName: "*"}, {Namespace: "a", ResourceName: "*"}},
+				"get":  {{Namespace: "c", ResourceName: "web"}},
+			},
+			wantOK:    true,
+			wantWhere: " WHERE ((namespace = ?) OR (namespace = ?) OR (namespace = ? AND name IN (?)))",
+			wantArgs:  []interface{}{"a", "b", "c", "web"},
+		},
+		{
+			name:      "other namespace",
+			access:    accesscontrol.AccessListByVerb{"list": {{Namespace: "a", ResourceName: "*"}}},
+			namespace: "b",
+			wantOK:    false,
+		},
+		{
+			name:   "none",
+			access: accesscontrol.AccessListByVerb{},
+			wantOK: false,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			q := &query{}
+			ok := q.access(test.access, test.namespace)
+			assert.Equal(t, test.wantOK, ok)
+			if ok {
+				assert.Equal(t, test.wantWhere, q.whereSQL())
+				assert.Equal(t, test.wantArgs, q.args)
+			}
+		})
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/pods?filter=spec.nodeName=node-1&sort=-metadata.name", nil)
+	opts := listprocessor.ParseQuery(&types.APIRequest{Request: req})
+
+	q := &query{limit: 10, offset: 20}
+	assert.True(t, q.filter(opts.Filters[0]))
+	assert.True(t, q.sort(opts.Sort))
+
+	statement, args := q.selectSQL("steve_pod")
+	assert.Equal(t, `SELECT key, object FROM "steve_pod" WHERE (json_extract(object, '$."spec"."nodeName"') = ?) `+
+		`ORDER BY json_extract(object, '$."metadata"."name"') DESC, namespace, name LIMIT ? OFFSET ?`, statement)
+	assert.Equal(t, []interface{}{"node-1", 10, 20}, args)
+
+	statement, args = q.countSQL("steve_pod")
+	assert.Equal(t, `SELECT COUNT(*) FROM "steve_pod" WHERE (json_extract(object, '$."spec"."nodeName"') = ?)`, statement)
+	assert.Equal(t, []interface{}{"node-1"}, args)
+}
+
+func TestJSONPath(t *testing.T) {
+	path, ok := jsonPath([]string{"metadata", "labels", "app.kubernetes.io/name"})
+	assert.True(t, ok)
+	assert.Equal(t, `$."metadata"."labels"."app.kubernetes.io/name"`, path)
+
+	_, ok = jsonPath([]string{`a"b`})
+	assert.False(t, ok)
+}
@@ -0,0 +1,168 @@
+package sqlcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/steve/pkg/writer"
+)
+
+const (
+	defaultLimit   = 100000
+	continuePrefix = "sqlcache."
+	cacheName      = "steve-sql-cache"
+)
+
+// passthroughParams are the query parameters of lists that the cache can't serve, which are listed from
+// kubernetes instead.
+var passthroughParams = []string{"labelSelector", "fieldSelector", "revision", "dynamicpartitions", "partial", "version"}
+
+// Store serves the lists of the cached schemas from the cache once it has synced, and everything else from the
+// store it wraps.
+type Store struct {
+	types.Store
+
+	cache   *Cache
+	options partition.Options
+}
+
+// NewSQLCacheStore returns a store serving the lists of the cached schemas from the cache. The transformers and
+// excluded fields of the options are applied to the cached objects as the partition store applies them.
+func NewSQLCacheStore(store types.Store, cache *Cache, options partition.Options) types.Store {
+	return &Store{
+		Store:   store,
+		cache:   cache,
+		options: options,
+	}
+}
+
+// List lists the objects of a schema from the cache, with the access of the user and the filters, sort and page of
+// the request in the query of the table. Lists are always served from the latest state mirrored into the cache,
+// unless the request asks for a fresh list with ?cache=false.
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	t := s.cache.table(schema)
+	switch {
+	case t == nil:
+		return s.Store.List(apiOp, schema)
+	case !writer.CacheRequested(apiOp.Request):
+		return s.forward(apiOp, schema, writer.ForwardRequest)
+	case !cacheable(apiOp.Request):
+		return s.forward(apiOp, schema, writer.ForwardBypass)
+	case !t.synced():
+		return s.forward(apiOp, schema, writer.ForwardMiss)
+	}
+
+	opts := listprocessor.ParseQuery(apiOp)
+	q := &query{}
+	accessList, _ := attributes.Access(schema).(accesscontrol.AccessListByVerb)
+	revision := t.revision()
+	if !q.access(accessList, apiOp.Namespace) {
+		writer.ListMetaFrom(apiOp.Context()).SetCount(0)
+		return types.APIObjectList{Revision: revision}, nil
+	}
+	for _, filter := range opts.Filters {
+		if !q.filter(filter) {
+			return s.forward(apiOp, schema, writer.ForwardBypass)
+		}
+	}
+	if !q.sort(opts.Sort) {
+		return s.forward(apiOp, schema, writer.ForwardBypass)
+	}
+
+	count, err := t.count(apiOp.Context(), q)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	meta := writer.ListMetaFrom(apiOp.Context())
+	meta.SetCount(count)
+
+	result := types.APIObjectList{Revision: revision}
+	if opts.Pagination.PageSize > 0 {
+		page := opts.Pagination.Page
+		if page < 1 {
+			page = 1
+		}
+		q.limit, q.offset = opts.Pagination.PageSize, opts.Pagination.PageSize*(page-1)
+		meta.SetPages((count + q.limit - 1) / q.limit)
+	} else {
+		q.limit, q.offset = getLimit(apiOp.Request), parseContinue(apiOp.Request)
+		if q.offset+q.limit < count {
+			result.Continue = continuePrefix + strconv.Itoa(q.offset+q.limit)
+		}
+	}
+
+	objects, err := t.list(apiOp.Context(), q, schema)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	result.Objects = s.shape(objects, opts)
+	// the table follows the informer, so its entries have no age
+	writer.AddCacheHit(apiOp, cacheName, -1)
+	return result, nil
+}
+
+// forward lists the objects of a schema from the wrapped store, recording why the cache didn't serve them after
+// the status of any cache of the wrapped store, which is nearer to kubernetes.
+func (s *Store) forward(apiOp *types.APIRequest, schema *types.APISchema, reason string) (types.APIObjectList, error) {
+	result, err := s.Store.List(apiOp, schema)
+	writer.AddCacheForward(apiOp, cacheName, reason)
+	return result, err
+}
+
+// shape applies the transformers, excluded fields and projection to the objects being returned.
+func (s *Store) shape(objects []types.APIObject, opts *listprocessor.ListOptions) []types.APIObject {
+	for i := range objects {
+		for _, transformer := range s.options.Transformers {
+			objects[i] = transformer(objects[i])
+		}
+	}
+	excludeFields := opts.ExcludeFields
+	if excludeFields == nil {
+		excludeFields = listprocessor.ParseFields(s.options.ExcludeFields)
+	}
+	objects = listprocessor.ExcludeList(objects, excludeFields)
+	return listprocessor.ProjectList(objects, opts.Fields)
+}
+
+// cacheable returns whether the cache can serve a list request. Tables, selectors, pinned revisions and continue
+// tokens of kubernetes lists are passed through. A resourceVersion of 0 accepts the cache, as it does in kubernetes.
+func cacheable(req *http.Request) bool {
+	if client.AcceptsTable(req) {
+		return false
+	}
+	query := req.URL.Query()
+	for _, param := range passthroughParams {
+		if query.Get(param) != "" {
+			return false
+		}
+	}
+	// the cache holds the latest objects only, rather than those of an older resourceVersion
+	if rv := query.Get("resourceVersion"); rv != "" && rv != "0" {
+		return false
+	}
+	cont := query.Get("continue")
+	return cont == "" || strings.HasPrefix(cont, continuePrefix)
+}
+
+func parseContinue(req *http.Request) int {
+	offset, _ := strconv.Atoi(strings.TrimPrefix(req.URL.Query().Get("continue"), continuePrefix))
+	if offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+func getLimit(req *http.Request) int {
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+	if limit <= 0 {
+		return defaultLimit
+	}
+	return limit
+}
@@ -0,0 +1,92 @@
+package subscribe
+
+import (
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+// maxDebounce is the longest interval events may be held back for.
+const maxDebounce = 10 * time.Second
+
+// debounceInterval returns the interval of a debounce in milliseconds, capped at maxDebounce.
+func debounceInterval(millis int) time.Duration {
+	interval := time.Duration(millis) * time.Millisecond
+	if interval > maxDebounce {
+		return maxDebounce
+	}
+	return interval
+}
+
+// coalesce returns a channel with the events of c, where the events of an object within an interval are
+// coalesced into a single event with its latest state, sent at the end of the interval. Events without an object,
+// such as bookmarks and errors, send the pending events first so the order of revisions is kept for them.
+func coalesce(c chan types.APIEvent, interval time.Duration) chan types.APIEvent {
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		var (
+			order   []string
+			pending = map[string]types.APIEvent{}
+		)
+		flush := func() {
+			for _, id := range order {
+				if event, ok := pending[id]; ok {
+					result <- event
+					delete(pending, id)
+				}
+			}
+			order = nil
+		}
+
+		for {
+			select {
+			case event, ok := <-c:
+				if !ok {
+					flush()
+					return
+				}
+				id := event.Object.ID
+				if event.Error != nil || event.Object.Object == nil || id == "" {
+					flush()
+					result <- event
+					continue
+				}
+				previous, ok := pending[id]
+				if !ok {
+					order = append(order, id)
+					pending[id] = event
+					continue
+				}
+				if merged, keep := merge(previous, event); keep {
+					pending[id] = merged
+				} else {
+					delete(pending, id)
+				}
+			case <-t.C:
+				flush()
+			}
+		}
+	}()
+	return result
+}
+
+// merge returns the event of the latest state of an object after two of its events, and false if the client
+// doesn't need an event, as the object was created and removed within the interval.
+func merge(previous, next types.APIEvent) (types.APIEvent, bool) {
+	switch {
+	case previous.Name == types.CreateAPIEvent && next.Name == types.RemoveAPIEvent:
+		return types.APIEvent{}, false
+	case previous.Name == types.CreateAPIEvent:
+		// the client has yet to see the object
+		next.Name = types.CreateAPIEvent
+	case previous.Name == types.RemoveAPIEvent && next.Name == types.CreateAPIEvent:
+		// the client still has the removed object
+		next.Name = types.ChangeAPIEvent
+	}
+	return next, true
+}
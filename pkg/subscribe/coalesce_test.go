@@ -0,0 +1,86 @@
+package subscribe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	event := func(name, id, revision string) types.APIEvent {
+		return types.APIEvent{
+			Name:     name,
+			Revision: revision,
+			Object:   types.APIObject{ID: id, Object: map[string]interface{}{}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		events []types.APIEvent
+		want   []string
+	}{
+		{
+			name: "changes",
+			events: []types.APIEvent{
+				event(types.ChangeAPIEvent, "a", "1"),
+				event(types.ChangeAPIEvent, "b", "2"),
+				event(types.ChangeAPIEvent, "a", "3"),
+			},
+			want: []string{"resource.change a 3", "resource.change b 2"},
+		},
+		{
+			name: "created and changed",
+			events: []types.APIEvent{
+				event(types.CreateAPIEvent, "a", "1"),
+				event(types.ChangeAPIEvent, "a", "2"),
+			},
+			want: []string{"resource.create a 2"},
+		},
+		{
+			name: "created and removed",
+			events: []types.APIEvent{
+				event(types.CreateAPIEvent, "a", "1"),
+				event(types.RemoveAPIEvent, "a", "2"),
+				event(types.CreateAPIEvent, "a", "3"),
+			},
+			want: []string{"resource.create a 3"},
+		},
+		{
+			name: "removed and created",
+			events: []types.APIEvent{
+				event(types.RemoveAPIEvent, "a", "1"),
+				event(types.CreateAPIEvent, "a", "2"),
+			},
+			want: []string{"resource.change a 2"},
+		},
+		{
+			name: "errors flush",
+			events: []types.APIEvent{
+				event(types.ChangeAPIEvent, "a", "1"),
+				{Name: "resource.error", Error: errors.New("closed")},
+				event(types.ChangeAPIEvent, "a", "2"),
+			},
+			want: []string{"resource.change a 1", "resource.error  ", "resource.change a 2"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			c := make(chan types.APIEvent, len(test.events))
+			for _, event := range test.events {
+				c <- event
+			}
+			close(c)
+
+			var got []string
+			for event := range coalesce(c, time.Hour) {
+				got = append(got, event.Name+" "+event.Object.ID+" "+event.Revision)
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
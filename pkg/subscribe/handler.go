@@ -0,0 +1,297 @@
+package subscribe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	// writeWait is how long a control message to a websocket may take.
+	writeWait = 10 * time.Second
+)
+
+// errSessionExpired ends a session that reached its maximum duration.
+var errSessionExpired = errors.New("session expired")
+
+var upgrader = websocket.Upgrader{
+	HandshakeTimeout:  60 * time.Second,
+	EnableCompression: true,
+}
+
+// Subscribe is a message of a client starting or stopping the watch of a type.
+type Subscribe struct {
+	Stop            bool   `json:"stop,omitempty"`
+	ResourceType    string `json:"resourceType,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Namespace       string `json:"namespace,omitempty"`
+	ID              string `json:"id,omitempty"`
+	Selector        string `json:"selector,omitempty"`
+	// LabelSelector selects the objects to watch by label, like Selector, which it takes precedence over.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// FieldSelector selects the objects to watch by field, as the kubernetes API supports them for the type.
+	FieldSelector string `json:"fieldSelector,omitempty"`
+	// Fields are the paths of the fields of the objects sent in events, all fields if empty. The name and
+	// namespace are always sent.
+	Fields []string `json:"fields,omitempty"`
+	// SubscriptionID is an ID the client assigns to the subscription. The events of the subscription carry it,
+	// and a stop message only needs it. Without an ID, a subscription is identified by its type and selectors.
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	// ResumeToken is the resumeToken of the last event the client received for the subscription, to resume the
+	// watch from there rather than from ResourceVersion.
+	ResumeToken string `json:"resumeToken,omitempty"`
+	// Debounce is an interval in milliseconds over which the events of an object are coalesced into one event of
+	// its latest state, to send fewer events for objects that change in bursts. Events are sent as they happen
+	// if zero.
+	Debounce int `json:"debounce,omitempty"`
+}
+
+// Event is a message of the server about a subscription: a watch event of it, or its start, stop or error.
+type Event struct {
+	types.APIEvent
+	// SubscriptionID is the ID the client assigned to the subscription.
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	// ResumeToken resumes the subscription after the event when subscribing again.
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+func (s *Subscribe) key() string {
+	if s.SubscriptionID != "" {
+		return "id:" + s.SubscriptionID
+	}
+	return s.ResourceType + "/" + s.Namespace + "/" + s.ID + "/" + s.labelSelector() + "/" + s.FieldSelector +
+		"/" + strings.Join(s.Fields, ",")
+}
+
+// labelSelector returns the label selector of the subscription.
+func (s *Subscribe) labelSelector() string {
+	if s.LabelSelector != "" {
+		return s.LabelSelector
+	}
+	return s.Selector
+}
+
+func NewHandler(getter SchemasGetter, serverVersion string) types.RequestListHandler {
+	return func(apiOp *types.APIRequest) (types.APIObjectList, error) {
+		return Handler(apiOp, getter, serverVersion)
+	}
+}
+
+// Handler streams the events of the subscriptions of a client, over server-sent events if the client accepts
+// text/event-stream and over a websocket otherwise.
+func Handler(apiOp *types.APIRequest, getter SchemasGetter, serverVersion string) (types.APIObjectList, error) {
+	var err error
+	if strings.Contains(apiOp.Request.Header.Get("Accept"), eventStreamContentType) {
+		err = eventStreamHandler(apiOp, getter, serverVersion)
+	} else {
+		err = handler(apiOp, getter, serverVersion)
+	}
+	if err != nil && err != errSessionExpired {
+		logrus.Errorf("Error during subscribe %v", err)
+	}
+	return types.APIObjectList{}, validation.ErrComplete
+}
+
+func handler(apiOp *types.APIRequest, getter SchemasGetter, serverVersion string) error {
+	c, err := upgrader.Upgrade(apiOp.Response, apiOp.Request, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	t := sessionTimeouts()
+	if t.idle > 0 {
+		// pongs and messages of the client extend the deadline, so the read of a half-open connection fails
+		if err := c.SetReadDeadline(time.Now().Add(t.idle)); err != nil {
+			return err
+		}
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(t.idle))
+		})
+	}
+
+	watches := NewWatchSession(apiOp, getter)
+	watches.idleTimeout = t.idle
+	defer watches.Close()
+
+	err = writeEvents(apiOp, getter, watches.Watch(c), serverVersion, t, sink{
+		write: func(event Event) error {
+			messageWriter, err := c.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return err
+			}
+			defer messageWriter.Close()
+
+			return json.NewEncoder(messageWriter).Encode(event)
+		},
+		ping: func() error {
+			return c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+		},
+	})
+	if err == errSessionExpired {
+		_ = c.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, err.Error()), time.Now().Add(writeWait))
+	}
+	return err
+}
+
+// eventStreamHandler streams the events of the subscription of the query parameters as server-sent events, whose
+// IDs are the resourceVersions of the events. A client reconnecting with a Last-Event-ID resumes the watch from
+// that resourceVersion.
+func eventStreamHandler(apiOp *types.APIRequest, getter SchemasGetter, serverVersion string) error {
+	flusher, ok := apiOp.Response.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming is not supported by the response writer")
+	}
+
+	query := apiOp.Request.URL.Query()
+	sub := Subscribe{
+		ResourceType:    query.Get("resourceType"),
+		ResourceVersion: query.Get("resourceVersion"),
+		Namespace:       query.Get("namespace"),
+		ID:              query.Get("id"),
+		Selector:        query.Get("selector"),
+		LabelSelector:   query.Get("labelSelector"),
+		FieldSelector:   query.Get("fieldSelector"),
+		Fields:          query["fields"],
+	}
+	if debounce := query.Get("debounce"); debounce != "" {
+		millis, err := strconv.Atoi(debounce)
+		if err != nil {
+			apiOp.Response.WriteHeader(http.StatusBadRequest)
+			return fmt.Errorf("invalid debounce %q", debounce)
+		}
+		sub.Debounce = millis
+	}
+	if lastEventID := apiOp.Request.Header.Get("Last-Event-ID"); lastEventID != "" {
+		sub.ResourceVersion = lastEventID
+	}
+	if sub.ResourceType == "" {
+		apiOp.Response.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("resourceType is required")
+	}
+
+	apiOp.Response.Header().Set("Content-Type", eventStreamContentType)
+	apiOp.Response.Header().Set("Cache-Control", "no-cache")
+	// keep proxies such as nginx from buffering the stream
+	apiOp.Response.Header().Set("X-Accel-Buffering", "no")
+	apiOp.Response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	watches := NewWatchSession(apiOp, getter)
+	defer watches.Close()
+
+	// a client reconnects when the stream ends, resuming from the last event
+	return writeEvents(apiOp, getter, watches.Serve(sub), serverVersion, sessionTimeouts(), sink{
+		write: func(event Event) error {
+			if err := writeEventStream(apiOp.Response, event); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+	})
+}
+
+// writeEventStream writes an event as a server-sent event, with the same JSON as a websocket message.
+func writeEventStream(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.Revision != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", event.Revision); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// sink is the transport of the events of a session.
+type sink struct {
+	// write writes an event.
+	write func(Event) error
+	// ping pings the client at the transport level, if the transport can.
+	ping func() error
+}
+
+// writeEvents writes the events of a session, and a ping with the server version at the ping interval, until the
+// channel of the events is closed, a write fails or the session reaches its maximum duration.
+func writeEvents(apiOp *types.APIRequest, getter SchemasGetter, events <-chan Event, serverVersion string, timeouts timeouts, s sink) error {
+	t := time.NewTicker(timeouts.ping)
+	defer t.Stop()
+
+	var expired <-chan time.Time
+	if timeouts.maxSession > 0 {
+		timer := time.NewTimer(timeouts.maxSession)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	defer func() {
+		// Ensure that events gets fully consumed
+		go func() {
+			for range events {
+			}
+		}()
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.write(toMessage(apiOp, getter, event)); err != nil {
+				return err
+			}
+		case <-expired:
+			return errSessionExpired
+		case <-t.C:
+			if s.ping != nil {
+				if err := s.ping(); err != nil {
+					return err
+				}
+			}
+			if err := s.write(toMessage(apiOp, getter, Event{APIEvent: types.APIEvent{
+				Name: "ping",
+				Object: types.APIObject{
+					Object: map[string]interface{}{"version": serverVersion},
+				},
+			}})); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toMessage returns an event with the data of its object as the JSON API writes it, or with the error of the
+// event as its data.
+func toMessage(apiOp *types.APIRequest, getter SchemasGetter, event Event) Event {
+	event.APIEvent = MarshallObject(apiOp, getter, event.APIEvent)
+	if event.Error != nil {
+		event.Name = "resource.error"
+		data := map[string]interface{}{
+			"error": event.Error.Error(),
+		}
+		// the code lets clients tell errors such as an exceeded quota apart
+		var apiErr *apierror.APIError
+		if errors.As(event.Error, &apiErr) {
+			data["code"] = apiErr.Code.Code
+		}
+		event.Data = data
+	}
+	return event
+}
@@ -0,0 +1,253 @@
+package subscribe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type watchStore struct {
+	empty.Store
+	revision      string
+	labelSelector string
+	fieldSelector string
+}
+
+func (w *watchStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	w.revision = wr.Revision
+	w.labelSelector = wr.Selector
+	w.fieldSelector = apiOp.Request.URL.Query().Get("fieldSelector")
+	c := make(chan types.APIEvent, 1)
+	c <- types.APIEvent{
+		Name:     types.ChangeAPIEvent,
+		Revision: "11",
+		Object: types.APIObject{Type: schema.ID, ID: "default/web-1", Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web-1", "namespace": "default"},
+			"spec":     map[string]interface{}{"nodeName": "node-1"},
+			"status":   map[string]interface{}{"phase": "Running"},
+		}},
+	}
+	close(c)
+	return c, nil
+}
+
+func eventStream(t *testing.T, url string, lastEventID string) (*watchStore, *httptest.ResponseRecorder) {
+	store := &watchStore{}
+	apiSchemas := types.EmptyAPISchemas()
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", CollectionMethods: []string{http.MethodGet}},
+		Store:  store,
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	rw := httptest.NewRecorder()
+	urlBuilder, err := urlbuilder.NewPrefixed(req, apiSchemas, "v1")
+	require.NoError(t, err)
+
+	_, err = Handler(&types.APIRequest{
+		Schemas:       apiSchemas,
+		AccessControl: &server.SchemaBasedAccess{},
+		Request:       req,
+		Response:      rw,
+		URLBuilder:    urlBuilder,
+	}, DefaultGetter, "dev")
+	assert.Equal(t, validation.ErrComplete, err)
+	return store, rw
+}
+
+func TestEventStream(t *testing.T) {
+	store, rw := eventStream(t, "/v1/subscribe?resourceType=pod&resourceVersion=5", "10")
+
+	assert.Equal(t, "10", store.revision)
+	assert.Equal(t, "text/event-stream", rw.Header().Get("Content-Type"))
+	events := strings.Split(strings.TrimSpace(rw.Body.String()), "\n\n")
+	require.Len(t, events, 3)
+	assert.Contains(t, events[0], `"name":"resource.start"`)
+	assert.True(t, strings.HasPrefix(events[1], "id: 11\ndata: {"), events[1])
+	assert.Contains(t, events[1], `"name":"resource.change"`)
+	assert.Contains(t, events[2], `"name":"resource.stop"`)
+}
+
+func TestSubscribeFilters(t *testing.T) {
+	store, rw := eventStream(t, "/v1/subscribe?resourceType=pod&labelSelector=app%3Dweb&fieldSelector=spec.nodeName%3Dnode-1&fields=status.phase", "")
+
+	assert.Equal(t, "app=web", store.labelSelector)
+	assert.Equal(t, "spec.nodeName=node-1", store.fieldSelector)
+	events := strings.Split(strings.TrimSpace(rw.Body.String()), "\n\n")
+	require.Len(t, events, 3)
+	assert.Contains(t, events[1], `"phase":"Running"`)
+	assert.Contains(t, events[1], `"name":"web-1"`)
+	assert.NotContains(t, events[1], "nodeName")
+}
+
+type blockingStore struct {
+	empty.Store
+	revisions chan string
+}
+
+func (b *blockingStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	b.revisions <- wr.Revision
+	c := make(chan types.APIEvent)
+	go func() {
+		<-apiOp.Context().Done()
+		close(c)
+	}()
+	return c, nil
+}
+
+func TestMultiplexedSubscriptions(t *testing.T) {
+	store := &blockingStore{revisions: make(chan string, 10)}
+	apiSchemas := types.EmptyAPISchemas()
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", CollectionMethods: []string{http.MethodGet}},
+		Store:  store,
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		urlBuilder, _ := urlbuilder.NewPrefixed(req, apiSchemas, "v1")
+		_, _ = Handler(&types.APIRequest{
+			Schemas:       apiSchemas,
+			AccessControl: &server.SchemaBasedAccess{},
+			Request:       req,
+			Response:      rw,
+			URLBuilder:    urlBuilder,
+		}, DefaultGetter, "dev")
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	read := func() Event {
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		return event
+	}
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "a", ResourceType: "pod"}))
+	event := read()
+	assert.Equal(t, "resource.start", event.Name)
+	assert.Equal(t, "a", event.SubscriptionID)
+
+	require.NoError(t, conn.WriteJSON(Subscribe{
+		SubscriptionID: "b",
+		ResourceType:   "pod",
+		ResumeToken:    resumeToken("pod", "42"),
+	}))
+	event = read()
+	assert.Equal(t, "resource.start", event.Name)
+	assert.Equal(t, "b", event.SubscriptionID)
+	assert.Equal(t, "", <-store.revisions)
+	assert.Equal(t, "42", <-store.revisions)
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "a", ResourceType: "pod"}))
+	event = read()
+	assert.Equal(t, "resource.error", event.Name)
+	assert.Equal(t, "a", event.SubscriptionID)
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "a", Stop: true}))
+	event = read()
+	assert.Equal(t, "resource.stop", event.Name)
+	assert.Equal(t, "a", event.SubscriptionID)
+}
+
+func TestResumeToken(t *testing.T) {
+	revision, err := parseResumeToken(resumeToken("pod", "42"), "pod")
+	assert.NoError(t, err)
+	assert.Equal(t, "42", revision)
+
+	_, err = parseResumeToken(resumeToken("pod", "42"), "secret")
+	assert.Error(t, err)
+
+	_, err = parseResumeToken("not a token", "pod")
+	assert.Error(t, err)
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want timeouts
+	}{
+		{
+			name: "defaults",
+			want: timeouts{ping: defaultPingInterval, idle: defaultIdleTimeout},
+		},
+		{
+			name: "overridden",
+			env: map[string]string{
+				pingIntervalEnv: "10",
+				idleTimeoutEnv:  "0",
+				maxSessionEnv:   "3600",
+			},
+			want: timeouts{ping: 10 * time.Second, maxSession: time.Hour},
+		},
+		{
+			name: "invalid",
+			env: map[string]string{
+				pingIntervalEnv: "0",
+				idleTimeoutEnv:  "soon",
+			},
+			want: timeouts{ping: defaultPingInterval, idle: defaultIdleTimeout},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			for env, value := range test.env {
+				t.Setenv(env, value)
+			}
+			assert.Equal(t, test.want, sessionTimeouts())
+		})
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	t.Setenv(maxSessionEnv, "1")
+	store := &blockingStore{revisions: make(chan string, 10)}
+	apiSchemas := types.EmptyAPISchemas()
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", CollectionMethods: []string{http.MethodGet}},
+		Store:  store,
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		urlBuilder, _ := urlbuilder.NewPrefixed(req, apiSchemas, "v1")
+		_, _ = Handler(&types.APIRequest{
+			Schemas:       apiSchemas,
+			AccessControl: &server.SchemaBasedAccess{},
+			Request:       req,
+			Response:      rw,
+			URLBuilder:    urlBuilder,
+		}, DefaultGetter, "dev")
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "a", ResourceType: "pod"}))
+	for {
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+			break
+		}
+	}
+}
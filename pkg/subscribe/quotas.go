@@ -0,0 +1,97 @@
+package subscribe
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	maxSubscriptionsEnv = "CATTLE_WEBSOCKET_MAX_SUBSCRIPTIONS"
+	maxUserWatchesEnv   = "CATTLE_WEBSOCKET_MAX_USER_WATCHES"
+)
+
+var (
+	quotaExceeded = validation.ErrorCode{Code: "QuotaExceeded", Status: http.StatusTooManyRequests}
+
+	// watchCounts are the running watches of each user across their sessions.
+	watchCounts = &userWatches{counts: map[string]int{}}
+)
+
+// quotas are the limits of the watches of a client, zero meaning no limit.
+type quotas struct {
+	// subscriptions is the number of subscriptions a session may have at once.
+	subscriptions int
+	// userWatches is the number of watches a user may have at once across all of their sessions.
+	userWatches int
+}
+
+// sessionQuotas returns the quotas of subscribe sessions, which are set with the CATTLE_WEBSOCKET_MAX_SUBSCRIPTIONS
+// and CATTLE_WEBSOCKET_MAX_USER_WATCHES environment variables.
+func sessionQuotas() quotas {
+	return quotas{
+		subscriptions: intFromEnv(maxSubscriptionsEnv),
+		userWatches:   intFromEnv(maxUserWatchesEnv),
+	}
+}
+
+func intFromEnv(env string) int {
+	setting := os.Getenv(env)
+	if setting == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(setting)
+	if err != nil {
+		logrus.Debugf("could not parse %s environment variable, error: %v", env, err)
+		return 0
+	}
+	return value
+}
+
+type userWatches struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+// acquire counts a watch of a user, returning the function releasing it, or an error if the user has max watches
+// already.
+func (u *userWatches) acquire(user string, max int) (func(), error) {
+	u.Lock()
+	defer u.Unlock()
+	if max > 0 && u.counts[user] >= max {
+		return nil, apierror.NewAPIError(quotaExceeded, fmt.Sprintf("user has the maximum of %d watches", max))
+	}
+	u.counts[user]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			u.Lock()
+			defer u.Unlock()
+			if u.counts[user]--; u.counts[user] <= 0 {
+				delete(u.counts, user)
+			}
+		})
+	}, nil
+}
+
+// acquireWatch checks the quotas for a new subscription of the session and counts its watch against the user. The
+// caller holds the lock of the session.
+func (s *WatchSession) acquireWatch() (func(), error) {
+	if s.quotas.subscriptions > 0 && len(s.watchers) >= s.quotas.subscriptions {
+		return nil, apierror.NewAPIError(quotaExceeded,
+			fmt.Sprintf("session has the maximum of %d subscriptions", s.quotas.subscriptions))
+	}
+	user, ok := request.UserFrom(s.apiOp.Context())
+	if !ok {
+		return func() {}, nil
+	}
+	return watchCounts.acquire(user.GetName(), s.quotas.userWatches)
+}
@@ -0,0 +1,80 @@
+package subscribe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserWatches(t *testing.T) {
+	u := &userWatches{counts: map[string]int{}}
+
+	release, err := u.acquire("alice", 2)
+	require.NoError(t, err)
+	_, err = u.acquire("alice", 2)
+	require.NoError(t, err)
+	_, err = u.acquire("alice", 2)
+	assert.Error(t, err)
+	_, err = u.acquire("bob", 2)
+	assert.NoError(t, err)
+
+	release()
+	release()
+	assert.Equal(t, 1, u.counts["alice"])
+	_, err = u.acquire("alice", 2)
+	assert.NoError(t, err)
+}
+
+func TestSubscriptionQuota(t *testing.T) {
+	t.Setenv(maxSubscriptionsEnv, "1")
+	store := &blockingStore{revisions: make(chan string, 10)}
+	apiSchemas := types.EmptyAPISchemas()
+	require.NoError(t, apiSchemas.AddSchema(types.APISchema{
+		Schema: &schemas.Schema{ID: "pod", CollectionMethods: []string{http.MethodGet}},
+		Store:  store,
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		urlBuilder, _ := urlbuilder.NewPrefixed(req, apiSchemas, "v1")
+		_, _ = Handler(&types.APIRequest{
+			Schemas:       apiSchemas,
+			AccessControl: &server.SchemaBasedAccess{},
+			Request:       req,
+			Response:      rw,
+			URLBuilder:    urlBuilder,
+		}, DefaultGetter, "dev")
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	read := func() Event {
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		return event
+	}
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "a", ResourceType: "pod"}))
+	assert.Equal(t, "resource.start", read().Name)
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "b", ResourceType: "pod"}))
+	event := read()
+	assert.Equal(t, "resource.error", event.Name)
+	assert.Equal(t, "b", event.SubscriptionID)
+	assert.Equal(t, "QuotaExceeded", event.Data.(map[string]interface{})["code"])
+
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "a", Stop: true}))
+	assert.Equal(t, "resource.stop", read().Name)
+	require.NoError(t, conn.WriteJSON(Subscribe{SubscriptionID: "b", ResourceType: "pod"}))
+	assert.Equal(t, "resource.start", read().Name)
+}
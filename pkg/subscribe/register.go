@@ -0,0 +1,31 @@
+// Package subscribe implements the subscribe type of the steve API, which streams the watch events of the types a
+// client subscribes to. It follows the protocol of the apiserver subscribe package over websockets and adds
+// server-sent events for clients behind proxies that block websockets.
+package subscribe
+
+import (
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+// SchemasGetter returns the schemas of the user of a request.
+type SchemasGetter func(apiOp *types.APIRequest) *types.APISchemas
+
+// DefaultGetter returns the schemas of the request.
+func DefaultGetter(apiOp *types.APIRequest) *types.APISchemas {
+	return apiOp.Schemas
+}
+
+// Register adds the subscribe schema.
+func Register(schemas *types.APISchemas, getter SchemasGetter, serverVersion string) {
+	if getter == nil {
+		getter = DefaultGetter
+	}
+	schemas.MustImportAndCustomize(Subscribe{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{}
+		schema.ListHandler = NewHandler(getter, serverVersion)
+		schema.PluralName = "subscribe"
+	})
+}
@@ -0,0 +1,58 @@
+package subscribe
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	pingIntervalEnv = "CATTLE_WEBSOCKET_PING_INTERVAL_SECONDS"
+	idleTimeoutEnv  = "CATTLE_WEBSOCKET_IDLE_TIMEOUT_SECONDS"
+	maxSessionEnv   = "CATTLE_WEBSOCKET_MAX_SESSION_SECONDS"
+
+	defaultPingInterval = 30 * time.Second
+	defaultIdleTimeout  = 90 * time.Second
+)
+
+// timeouts are the limits of a subscribe session.
+type timeouts struct {
+	// ping is the interval of the pings sent to the client.
+	ping time.Duration
+	// idle is how long a websocket may go without a message or a pong from the client before it is closed, so
+	// half-open connections end. Zero disables it.
+	idle time.Duration
+	// maxSession is how long a session may last before the server ends it and the client must reconnect. Zero
+	// disables it.
+	maxSession time.Duration
+}
+
+// sessionTimeouts returns the timeouts of subscribe sessions, which may be overridden with the
+// CATTLE_WEBSOCKET_PING_INTERVAL_SECONDS, CATTLE_WEBSOCKET_IDLE_TIMEOUT_SECONDS and
+// CATTLE_WEBSOCKET_MAX_SESSION_SECONDS environment variables.
+func sessionTimeouts() timeouts {
+	t := timeouts{
+		ping:       secondsFromEnv(pingIntervalEnv, defaultPingInterval),
+		idle:       secondsFromEnv(idleTimeoutEnv, defaultIdleTimeout),
+		maxSession: secondsFromEnv(maxSessionEnv, 0),
+	}
+	if t.ping <= 0 {
+		t.ping = defaultPingInterval
+	}
+	return t
+}
+
+func secondsFromEnv(env string, def time.Duration) time.Duration {
+	setting := os.Getenv(env)
+	if setting == "" {
+		return def
+	}
+	seconds, err := strconv.Atoi(setting)
+	if err != nil {
+		logrus.Debugf("could not parse %s environment variable, error: %v", env, err)
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,308 @@
+package subscribe
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+)
+
+// WatchSession is the set of watches of a client, whose events go to one channel.
+type WatchSession struct {
+	sync.Mutex
+
+	apiOp    *types.APIRequest
+	getter   SchemasGetter
+	watchers map[string]*watch
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   func()
+	events   chan Event
+	// idleTimeout is how long a websocket may go without a message before its read fails, if set.
+	idleTimeout time.Duration
+	quotas      quotas
+}
+
+// watch is a running watch of a subscription.
+type watch struct {
+	cancel func()
+}
+
+// stop stops the watch of a subscription for a stop message of the client.
+func (s *WatchSession) stop(sub Subscribe) {
+	s.Lock()
+	defer s.Unlock()
+	if w, ok := s.watchers[sub.key()]; ok {
+		w.cancel()
+		s.stopped(sub)
+	} else if sub.SubscriptionID != "" {
+		sendErr(s.events, fmt.Errorf("unknown subscription %s", sub.SubscriptionID), sub)
+	}
+}
+
+// end removes the subscription of a watch that has ended, unless the client stopped it already.
+func (s *WatchSession) end(sub Subscribe, w *watch) {
+	s.Lock()
+	defer s.Unlock()
+	if s.watchers[sub.key()] == w {
+		s.stopped(sub)
+	}
+}
+
+// stopped removes a subscription and tells the client. The caller holds the lock.
+func (s *WatchSession) stopped(sub Subscribe) {
+	delete(s.watchers, sub.key())
+	s.events <- Event{
+		APIEvent: types.APIEvent{
+			Name:         "resource.stop",
+			ResourceType: sub.ResourceType,
+			Namespace:    sub.Namespace,
+			ID:           sub.ID,
+			Selector:     sub.Selector,
+		},
+		SubscriptionID: sub.SubscriptionID,
+	}
+}
+
+// add starts the watch of a subscription. A subscription without an ID that the session already has is ignored,
+// and one whose ID is in use is an error.
+func (s *WatchSession) add(sub Subscribe) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.watchers[sub.key()]; ok {
+		if sub.SubscriptionID != "" {
+			sendErr(s.events, fmt.Errorf("subscription %s already exists", sub.SubscriptionID), sub)
+		}
+		return
+	}
+
+	release, err := s.acquireWatch()
+	if err != nil {
+		sendErr(s.events, err, sub)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	w := &watch{cancel: cancel}
+	s.watchers[sub.key()] = w
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.end(sub, w)
+		defer release()
+		defer cancel()
+
+		if err := s.stream(ctx, sub, s.events); err != nil {
+			sendErr(s.events, err, sub)
+		}
+	}()
+}
+
+func (s *WatchSession) stream(ctx context.Context, sub Subscribe, result chan<- Event) error {
+	if sub.ResumeToken != "" {
+		revision, err := parseResumeToken(sub.ResumeToken, sub.ResourceType)
+		if err != nil {
+			return err
+		}
+		sub.ResourceVersion = revision
+	}
+
+	schemas := s.getter(s.apiOp)
+	schema := schemas.LookupSchema(sub.ResourceType)
+	if schema == nil {
+		return fmt.Errorf("failed to find schema %s", sub.ResourceType)
+	} else if schema.Store == nil {
+		return fmt.Errorf("schema %s does not support watching", sub.ResourceType)
+	}
+
+	if err := s.apiOp.AccessControl.CanWatch(s.apiOp, schema); err != nil {
+		return err
+	}
+
+	apiOp := s.apiOp.Clone().WithContext(ctx)
+	apiOp.Namespace = sub.Namespace
+	apiOp.Schemas = schemas
+	if sub.FieldSelector != "" {
+		// the stores pass the fieldSelector of the request on to the watch of the kubernetes API
+		u := *apiOp.Request.URL
+		query := u.Query()
+		query.Set("fieldSelector", sub.FieldSelector)
+		u.RawQuery = query.Encode()
+		apiOp.Request.URL = &u
+	}
+	c, err := schema.Store.Watch(apiOp, schema, types.WatchRequest{
+		Revision: sub.ResourceVersion,
+		ID:       sub.ID,
+		Selector: sub.labelSelector(),
+	})
+	if err != nil {
+		return err
+	}
+
+	result <- Event{
+		APIEvent: types.APIEvent{
+			Name:         "resource.start",
+			ResourceType: sub.ResourceType,
+			ID:           sub.ID,
+			Selector:     sub.Selector,
+		},
+		SubscriptionID: sub.SubscriptionID,
+	}
+
+	if c != nil && sub.Debounce > 0 {
+		c = coalesce(c, debounceInterval(sub.Debounce))
+	}
+
+	fields := listprocessor.ParseFields(sub.Fields)
+	if c == nil {
+		<-s.apiOp.Context().Done()
+	} else {
+		for event := range c {
+			if event.Error == nil {
+				event.ID = sub.ID
+				event.Selector = sub.Selector
+				if len(fields) > 0 && event.Object.Object != nil {
+					event.Object = listprocessor.ProjectList([]types.APIObject{event.Object}, fields)[0]
+				}
+				message := Event{
+					APIEvent:       event,
+					SubscriptionID: sub.SubscriptionID,
+				}
+				if event.Revision != "" {
+					message.ResumeToken = resumeToken(sub.ResourceType, event.Revision)
+				}
+				select {
+				case result <- message:
+				default:
+					// handle slow consumer
+					go func() {
+						for range c {
+							// continue to drain until close
+						}
+					}()
+					return nil
+				}
+			} else {
+				sendErr(result, event.Error, sub)
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewWatchSession returns a session for the watches of the user of a request, which end with the request.
+func NewWatchSession(apiOp *types.APIRequest, getter SchemasGetter) *WatchSession {
+	ws := &WatchSession{
+		apiOp:    apiOp,
+		getter:   getter,
+		watchers: map[string]*watch{},
+		events:   make(chan Event, 100),
+		quotas:   sessionQuotas(),
+	}
+
+	ws.ctx, ws.cancel = context.WithCancel(apiOp.Request.Context())
+	return ws
+}
+
+// Watch starts and stops the watches of the subscriptions read from a websocket. The channel of the events is
+// closed once the websocket is closed and the watches have ended.
+func (s *WatchSession) Watch(conn *websocket.Conn) <-chan Event {
+	go func() {
+		defer close(s.events)
+
+		if err := s.watch(conn); err != nil {
+			sendErr(s.events, err, Subscribe{})
+		}
+	}()
+	return s.events
+}
+
+// Serve starts the watches of a fixed set of subscriptions. The channel of the events is closed once every watch
+// has ended, either with the request or because the watch was closed by the server.
+func (s *WatchSession) Serve(subs ...Subscribe) <-chan Event {
+	for _, sub := range subs {
+		s.add(sub)
+	}
+	go func() {
+		s.wg.Wait()
+		s.cancel()
+		close(s.events)
+	}()
+	return s.events
+}
+
+func (s *WatchSession) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *WatchSession) watch(conn *websocket.Conn) error {
+	defer s.wg.Wait()
+	defer s.cancel()
+
+	for {
+		_, r, err := conn.NextReader()
+		if err != nil {
+			return err
+		}
+		if s.idleTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+				return err
+			}
+		}
+
+		var sub Subscribe
+
+		if err := json.NewDecoder(r).Decode(&sub); err != nil {
+			sendErr(s.events, err, Subscribe{})
+			continue
+		}
+
+		if sub.Stop {
+			s.stop(sub)
+		} else {
+			s.add(sub)
+		}
+	}
+}
+
+func sendErr(resp chan<- Event, err error, sub Subscribe) {
+	resp <- Event{
+		APIEvent: types.APIEvent{
+			ResourceType: sub.ResourceType,
+			Namespace:    sub.Namespace,
+			ID:           sub.ID,
+			Selector:     sub.Selector,
+			Error:        err,
+		},
+		SubscriptionID: sub.SubscriptionID,
+	}
+}
+
+// resumeToken returns the token resuming a watch of a type from a resourceVersion.
+func resumeToken(resourceType, revision string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(resourceType + "\x00" + revision))
+}
+
+// parseResumeToken returns the resourceVersion of a resume token of a watch of a type.
+func parseResumeToken(token, resourceType string) (string, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid resume token")
+	}
+	tokenType, revision, ok := strings.Cut(string(bytes), "\x00")
+	if !ok || tokenType != resourceType {
+		return "", fmt.Errorf("resume token is not for %s", resourceType)
+	}
+	return revision, nil
+}
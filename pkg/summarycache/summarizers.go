@@ -0,0 +1,44 @@
+package summarycache
+
+import (
+	"sync"
+
+	"github.com/rancher/wrangler/pkg/data"
+	"github.com/rancher/wrangler/pkg/summary"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Summarizer derives the summary of an object of a kind, such as its state and whether it is transitioning or in
+// error, from the summary of the built-in summarizers.
+type Summarizer func(obj data.Object, conditions []summary.Condition, summary summary.Summary) summary.Summary
+
+var (
+	summarizersLock sync.RWMutex
+	summarizers     = map[runtimeschema.GroupVersionKind][]Summarizer{}
+)
+
+func init() {
+	summary.Summarizers = append(summary.Summarizers, summarizeKind)
+}
+
+// RegisterSummarizer adds a summarizer of the objects of a kind, such as an in house CRD. The summarizers of a kind
+// run after the built-in ones, in the order they are registered, so they may override any field of the summary.
+// Summaries are cached, so summarizers should be registered before the server starts.
+func RegisterSummarizer(gvk runtimeschema.GroupVersionKind, summarizer Summarizer) {
+	summarizersLock.Lock()
+	defer summarizersLock.Unlock()
+	summarizers[gvk] = append(summarizers[gvk], summarizer)
+}
+
+func summarizeKind(obj data.Object, conditions []summary.Condition, result summary.Summary) summary.Summary {
+	gvk := runtimeschema.FromAPIVersionAndKind(obj.String("apiVersion"), obj.String("kind"))
+
+	summarizersLock.RLock()
+	kindSummarizers := summarizers[gvk]
+	summarizersLock.RUnlock()
+
+	for _, summarizer := range kindSummarizers {
+		result = summarizer(obj, conditions, result)
+	}
+	return result
+}
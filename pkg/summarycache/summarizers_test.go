@@ -0,0 +1,71 @@
+package summarycache
+
+import (
+	"testing"
+
+	"github.com/rancher/wrangler/pkg/data"
+	"github.com/rancher/wrangler/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRegisterSummarizer(t *testing.T) {
+	RegisterSummarizer(runtimeschema.GroupVersionKind{Group: "example.io", Version: "v1", Kind: "Backup"},
+		func(obj data.Object, _ []summary.Condition, s summary.Summary) summary.Summary {
+			switch obj.String("status", "result") {
+			case "Failed":
+				s.State = "failed"
+				s.Error = true
+				s.Message = append(s.Message, obj.String("status", "reason"))
+			case "":
+				s.State = "running"
+				s.Transitioning = true
+			}
+			return s
+		})
+
+	tests := []struct {
+		name string
+		obj  map[string]interface{}
+		want summary.Summary
+	}{
+		{
+			name: "failed",
+			obj: map[string]interface{}{
+				"apiVersion": "example.io/v1",
+				"kind":       "Backup",
+				"status":     map[string]interface{}{"result": "Failed", "reason": "disk full"},
+			},
+			want: summary.Summary{State: "failed", Error: true},
+		},
+		{
+			name: "running",
+			obj: map[string]interface{}{
+				"apiVersion": "example.io/v1",
+				"kind":       "Backup",
+			},
+			want: summary.Summary{State: "running", Transitioning: true},
+		},
+		{
+			name: "other kind",
+			obj: map[string]interface{}{
+				"apiVersion": "example.io/v1",
+				"kind":       "Restore",
+			},
+			want: summary.Summary{State: "active"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := summary.Summarize(&unstructured.Unstructured{Object: test.obj})
+			assert.Equal(t, test.want.State, got.State)
+			assert.Equal(t, test.want.Error, got.Error)
+			assert.Equal(t, test.want.Transitioning, got.Transitioning)
+			if test.want.Error {
+				assert.Contains(t, got.Message, "disk full")
+			}
+		})
+	}
+}
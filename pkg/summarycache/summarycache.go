@@ -16,6 +16,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -101,6 +102,12 @@ func (s *SummaryCache) OnInboundRelationshipChange(ctx context.Context, schema *
 	return ret
 }
 
+// Use records that the objects of a type are being listed or watched, so the cluster cache watches the type if it
+// starts types lazily.
+func (s *SummaryCache) Use(gvk runtimeschema.GroupVersionKind) {
+	s.clusterCache.Use(gvk)
+}
+
 func (s *SummaryCache) SummaryAndRelationship(obj runtime.Object) (*summary.SummarizedObject, []Relationship) {
 	s.RLock()
 	defer s.RUnlock()
@@ -142,6 +149,32 @@ func (s *SummaryCache) SummaryAndRelationship(obj runtime.Object) (*summary.Summ
 	return summarized, rels
 }
 
+// Relationships returns the summary and relationships of an object in the cluster cache, or false if it is not
+// cached.
+func (s *SummaryCache) Relationships(gvk runtimeschema.GroupVersionKind, namespace, name string) (*summary.SummarizedObject, []Relationship, bool) {
+	obj, ok, err := s.clusterCache.Get(gvk, namespace, name)
+	if err != nil || !ok {
+		return nil, nil, false
+	}
+	ro, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, nil, false
+	}
+	summarized, rels := s.SummaryAndRelationship(ro)
+	return summarized, rels, true
+}
+
+// Dependents returns the summaries of the cached objects owned by the object with the UID.
+func (s *SummaryCache) Dependents(uid k8stypes.UID) []*summary.SummarizedObject {
+	var result []*summary.SummarizedObject
+	for _, obj := range s.clusterCache.Dependents(uid) {
+		if ro, ok := obj.(runtime.Object); ok {
+			result = append(result, summary.Summarized(ro))
+		}
+	}
+	return result
+}
+
 func (s *SummaryCache) reverseRel(summarized *summary.SummarizedObject, rel summary.Relationship) Relationship {
 	return s.toRel(summarized.Namespace, &summary.Relationship{
 		Name:       summarized.Name,
@@ -0,0 +1,28 @@
+package writer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+)
+
+// RequestTooLarge is the error code of a request whose body is over the limit of its handler.
+var RequestTooLarge = validation.ErrorCode{Code: "RequestEntityTooLarge", Status: http.StatusRequestEntityTooLarge}
+
+// ReadBody reads the body of a request of at most limit bytes. A larger body is refused with a RequestTooLarge
+// error, and the connection is closed after the response rather than reading the rest of the body.
+func ReadBody(apiOp *types.APIRequest, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(apiOp.Response, apiOp.Request.Body, limit))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return nil, apierror.NewAPIError(RequestTooLarge, "request body is larger than "+strconv.FormatInt(limit, 10)+" bytes")
+	} else if err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	return body, nil
+}
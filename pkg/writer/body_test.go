@@ -0,0 +1,32 @@
+package writer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBody(t *testing.T) {
+	read := func(body string) ([]byte, error) {
+		return ReadBody(&types.APIRequest{
+			Request:  httptest.NewRequest(http.MethodPost, "/v1/crdapply", strings.NewReader(body)),
+			Response: httptest.NewRecorder(),
+		}, 4)
+	}
+
+	body, err := read("1234")
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", string(body))
+
+	_, err = read("12345")
+	var apiErr *apierror.APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, apiErr.Code.Status)
+	}
+}
@@ -0,0 +1,51 @@
+package writer
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+const (
+	// CacheStatusHeader is the response header of RFC 9211 telling clients how the caches of steve handled a list.
+	CacheStatusHeader = "Cache-Status"
+	cacheParam        = "cache"
+)
+
+// Reasons a cache forwarded a request rather than serving it, as the fwd parameter of the Cache-Status header.
+const (
+	// ForwardMiss is a request the cache had no entry for.
+	ForwardMiss = "miss"
+	// ForwardBypass is a request the cache can't serve, such as a list with a label selector.
+	ForwardBypass = "bypass"
+	// ForwardRequest is a request that asked not to be served from a cache.
+	ForwardRequest = "request"
+)
+
+// CacheRequested returns whether a request allows being served from a cache, which it refuses with ?cache=false.
+func CacheRequested(req *http.Request) bool {
+	return req.URL.Query().Get(cacheParam) != "false"
+}
+
+// AddCacheHit records in the Cache-Status header that a cache served a list, with the age of its entry if known.
+func AddCacheHit(apiOp *types.APIRequest, cache string, age time.Duration) {
+	if apiOp.Response == nil {
+		return
+	}
+	if age < 0 {
+		apiOp.Response.Header().Add(CacheStatusHeader, cache+"; hit")
+		return
+	}
+	apiOp.Response.Header().Add(CacheStatusHeader, fmt.Sprintf("%s; hit; age=%d", cache, int(math.Floor(age.Seconds()))))
+}
+
+// AddCacheForward records in the Cache-Status header that a cache forwarded a list for a reason.
+func AddCacheForward(apiOp *types.APIRequest, cache, reason string) {
+	if apiOp.Response == nil {
+		return
+	}
+	apiOp.Response.Header().Add(CacheStatusHeader, cache+"; fwd="+reason)
+}
@@ -0,0 +1,118 @@
+package writer
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+)
+
+// CompressWriter is a response writer which compresses the response with gzip or deflate,
+// whichever the client prefers according to its Accept-Encoding header.
+// The compressed stream is flushed whenever the response is flushed, so streamed responses
+// reach the client as they are written.
+type CompressWriter struct {
+	types.ResponseWriter
+}
+
+// compressor is a compressing writer which can be flushed.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Write writes a single object.
+func (c *CompressWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	apiOp, closer := compress(apiOp)
+	defer closer.Close()
+	c.ResponseWriter.Write(apiOp, code, obj)
+}
+
+// WriteList writes a collection.
+func (c *CompressWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	apiOp, closer := compress(apiOp)
+	defer closer.Close()
+	c.ResponseWriter.WriteList(apiOp, code, list)
+}
+
+// compress returns a copy of the request whose response is compressed, and the closer that finishes the stream.
+func compress(apiOp *types.APIRequest) (*types.APIRequest, io.Closer) {
+	encoding := negotiateEncoding(apiOp.Request.Header.Get("Accept-Encoding"))
+	apiOp.Response.Header().Add("Vary", "Accept-Encoding")
+
+	var c compressor
+	switch encoding {
+	case "gzip":
+		c = gzip.NewWriter(apiOp.Response)
+	case "deflate":
+		// flate.NewWriter only fails for an invalid compression level
+		c, _ = flate.NewWriter(apiOp.Response, flate.DefaultCompression)
+	default:
+		return apiOp, io.NopCloser(nil)
+	}
+
+	apiOp.Response.Header().Set("Content-Encoding", encoding)
+	apiOp.Response.Header().Del("Content-Length")
+
+	newOp := *apiOp
+	newOp.Response = &compressResponseWriter{
+		ResponseWriter: apiOp.Response,
+		compressor:     c,
+	}
+	return &newOp, c
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality in an Accept-Encoding header,
+// preferring gzip on ties, or the empty string if neither gzip nor deflate is acceptable.
+func negotiateEncoding(header string) string {
+	var (
+		best    string
+		bestQ   float64
+		offered = map[string]float64{}
+	)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		offered[name] = q
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		q, ok := offered[encoding]
+		if !ok {
+			q, ok = offered["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compressor compressor
+}
+
+func (c *compressResponseWriter) Write(b []byte) (int, error) {
+	return c.compressor.Write(b)
+}
+
+// Flush writes any buffered compressed data to the client.
+func (c *compressResponseWriter) Flush() {
+	_ = c.compressor.Flush()
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
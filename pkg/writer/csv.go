@@ -0,0 +1,171 @@
+package writer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	apiwriter "github.com/rancher/apiserver/pkg/writer"
+	"github.com/rancher/steve/pkg/attributes"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	csvContentType = "text/csv"
+	// csvFlushRows is the number of rows written between flushes, so large exports stream to the client.
+	csvFlushRows = 100
+)
+
+// CSVWriter is a response writer which writes collections as CSV of the display columns of their schema when the
+// client asks for it with ?format=csv or an Accept header of text/csv. Everything else is written by the wrapped
+// writer.
+type CSVWriter struct {
+	types.ResponseWriter
+}
+
+// column is a display column of a schema, from either the table of the kubernetes API or the printer columns of
+// a CRD.
+type column struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+}
+
+// WantsCSV returns whether a request asks for a CSV response.
+func WantsCSV(req *http.Request) bool {
+	if req.URL.Query().Get("format") == "csv" {
+		return true
+	}
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == csvContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteList writes a collection, as CSV if the client asked for it.
+func (c *CSVWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	if apiOp.Schema == nil || !WantsCSV(apiOp.Request) {
+		c.ResponseWriter.WriteList(apiOp, code, list)
+		return
+	}
+
+	apiOp, closer := compress(apiOp)
+	defer closer.Close()
+
+	apiwriter.AddCommonResponseHeader(apiOp)
+	apiOp.Response.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	apiOp.Response.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": apiOp.Schema.ID + ".csv",
+	}))
+	apiOp.Response.WriteHeader(code)
+	writeCSV(apiOp, csvColumns(apiOp.Schema), list)
+}
+
+func writeCSV(apiOp *types.APIRequest, columns []column, list types.APIObjectList) {
+	paths := make([]*jsonpath.JSONPath, len(columns))
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+		path := jsonpath.New(col.Name).AllowMissingKeys(true)
+		if err := path.Parse(fmt.Sprintf("{%s}", strings.TrimPrefix(col.Field, "$"))); err == nil {
+			paths[i] = path
+		}
+	}
+
+	w := csv.NewWriter(apiOp.Response)
+	if err := w.Write(header); err != nil {
+		return
+	}
+	row := make([]string, len(columns))
+	for i, obj := range list.Objects {
+		data := formatted(apiOp, obj)
+		for j, path := range paths {
+			row[j] = cellString(path, data)
+		}
+		if err := w.Write(row); err != nil {
+			return
+		}
+		if (i+1)%csvFlushRows == 0 {
+			flush(apiOp, w)
+		}
+	}
+	flush(apiOp, w)
+}
+
+func flush(apiOp *types.APIRequest, w *csv.Writer) {
+	w.Flush()
+	if f, ok := apiOp.Response.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// formatted returns the data of an object after the formatter of its schema, so the computed fields of the
+// columns are set as they are in the JSON response.
+func formatted(apiOp *types.APIRequest, obj types.APIObject) map[string]interface{} {
+	if apiOp.Schema.Formatter != nil {
+		resource := &types.RawResource{
+			ID:        obj.ID,
+			Type:      apiOp.Schema.ID,
+			Schema:    apiOp.Schema,
+			Links:     map[string]string{},
+			Actions:   map[string]string{},
+			APIObject: obj,
+		}
+		apiOp.Schema.Formatter(apiOp, resource)
+		obj = resource.APIObject
+	}
+	return obj.Data()
+}
+
+func cellString(path *jsonpath.JSONPath, data map[string]interface{}) string {
+	if path == nil {
+		return ""
+	}
+	results, err := path.FindResults(data)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return ""
+	}
+	switch v := results[0][0].Interface().(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool, int, int32, int64, float32, float64:
+		return fmt.Sprint(v)
+	default:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(bytes)
+	}
+}
+
+// csvColumns returns the display columns of a schema, with the namespace first for namespaced types since the
+// tables of the kubernetes API leave it out. Schemas without columns get the name and creation time.
+func csvColumns(schema *types.APISchema) []column {
+	var columns []column
+	if bytes, err := json.Marshal(attributes.Columns(schema)); err == nil {
+		_ = json.Unmarshal(bytes, &columns)
+	}
+	if len(columns) == 0 {
+		columns = []column{
+			{Name: "Name", Field: "$.metadata.name"},
+			{Name: "Created", Field: "$.metadata.creationTimestamp"},
+		}
+	}
+	if attributes.Namespaced(schema) {
+		for _, col := range columns {
+			if strings.EqualFold(col.Name, "namespace") {
+				return columns
+			}
+		}
+		columns = append([]column{{Name: "Namespace", Field: "$.metadata.namespace"}}, columns...)
+	}
+	return columns
+}
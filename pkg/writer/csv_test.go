@@ -0,0 +1,85 @@
+package writer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/schema/table"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type jsonWriter struct {
+	types.ResponseWriter
+	called bool
+}
+
+func (j *jsonWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	j.called = true
+}
+
+func TestCSVWriter(t *testing.T) {
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	attributes.SetNamespaced(schema, true)
+	attributes.SetColumns(schema, []table.Column{
+		{Name: "Name", Field: "$.metadata.fields[0]"},
+		{Name: "Status", Field: "$.metadata.fields[1]"},
+	})
+	list := types.APIObjectList{Objects: []types.APIObject{{
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "web-1",
+				"namespace": "default",
+				"fields":    []interface{}{"web-1", "Running, ready"},
+			},
+		}},
+	}}}
+
+	tests := []struct {
+		name    string
+		url     string
+		accept  string
+		wantCSV string
+	}{
+		{
+			name:    "format parameter",
+			url:     "/v1/pod?format=csv",
+			wantCSV: "Namespace,Name,Status\ndefault,web-1,\"Running, ready\"\n",
+		},
+		{
+			name:    "accept header",
+			url:     "/v1/pod",
+			accept:  "text/csv;q=0.9, application/json",
+			wantCSV: "Namespace,Name,Status\ndefault,web-1,\"Running, ready\"\n",
+		},
+		{
+			name: "json",
+			url:  "/v1/pod",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Set("Accept", test.accept)
+			rw := httptest.NewRecorder()
+			next := &jsonWriter{}
+			w := &CSVWriter{ResponseWriter: next}
+
+			apiOp := &types.APIRequest{Schemas: types.EmptyAPISchemas(), Schema: schema, Request: req, Response: rw}
+
+			w.WriteList(apiOp, http.StatusOK, list)
+			if test.wantCSV == "" {
+				assert.True(t, next.called)
+				return
+			}
+			assert.False(t, next.called)
+			assert.Equal(t, test.wantCSV, rw.Body.String())
+			assert.Equal(t, `attachment; filename=pod.csv`, rw.Header().Get("Content-Disposition"))
+		})
+	}
+}
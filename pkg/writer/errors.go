@@ -0,0 +1,107 @@
+package writer
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FromStatus returns the API error of an error carrying a kubernetes Status, with the code and reason of the status
+// and the status as its cause, so ErrorHandler can return its details. Errors wrapping a Status are translated too,
+// and other errors are returned as is.
+func FromStatus(err error) error {
+	var apiErr *apierror.APIError
+	if err == nil || errors.As(err, &apiErr) {
+		return err
+	}
+	var apiStatus apierrors.APIStatus
+	if !errors.As(err, &apiStatus) {
+		return err
+	}
+	status := apiStatus.Status()
+	result := &apierror.APIError{
+		Code: validation.ErrorCode{
+			Status: int(status.Code),
+			Code:   string(status.Reason),
+		},
+		Message: status.Message,
+		Cause:   err,
+	}
+	if status.Details != nil && len(status.Details.Causes) == 1 {
+		result.FieldName = status.Details.Causes[0].Field
+	}
+	return result
+}
+
+// statusOf returns the kubernetes Status an API error was translated from.
+func statusOf(apiErr *apierror.APIError) (metav1.Status, bool) {
+	var apiStatus apierrors.APIStatus
+	if apiErr.Cause == nil || !errors.As(apiErr.Cause, &apiStatus) {
+		return metav1.Status{}, false
+	}
+	return apiStatus.Status(), true
+}
+
+// ErrorHandler writes the response of an error as the default handler of the apiserver does, adding the details of
+// the kubernetes Status the error came from, such as its causes, and a Retry-After header if the status has one.
+// The causes of errors that are kubernetes statuses aren't logged, as they are responses of kubernetes rather than
+// failures of steve.
+func ErrorHandler(apiOp *types.APIRequest, err error) {
+	if err == validation.ErrComplete {
+		return
+	}
+	if ec, ok := err.(validation.ErrorCode); ok {
+		err = apierror.NewAPIError(ec, "")
+	}
+
+	var apiErr *apierror.APIError
+	if !errors.As(FromStatus(err), &apiErr) {
+		logrus.Errorf("Unknown error: %v", err)
+		apiErr = &apierror.APIError{
+			Code:    validation.ServerError,
+			Message: err.Error(),
+		}
+	}
+	status, fromStatus := statusOf(apiErr)
+	if apiErr.Cause != nil && !fromStatus {
+		u, _ := url.PathUnescape(apiOp.Request.URL.String())
+		if u == "" {
+			u = apiOp.Request.URL.String()
+		}
+		logrus.Errorf("API error response %v for %v %v. Cause: %v", apiErr.Code.Status, apiOp.Request.Method,
+			u, apiErr.Cause)
+	}
+
+	if apiErr.Code.Status == http.StatusNoContent {
+		apiOp.Response.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	data := map[string]interface{}{
+		"type":    "error",
+		"status":  apiErr.Code.Status,
+		"code":    apiErr.Code.Code,
+		"message": apiErr.Message,
+	}
+	if apiErr.FieldName != "" {
+		data["fieldName"] = apiErr.FieldName
+	}
+	if fromStatus && status.Details != nil {
+		data["details"] = status.Details
+		if status.Details.RetryAfterSeconds > 0 {
+			apiOp.Response.Header().Set("Retry-After", strconv.Itoa(int(status.Details.RetryAfterSeconds)))
+		}
+	}
+	apiOp.WriteResponse(apiErr.Code.Status, types.APIObject{
+		Type:   "error",
+		Object: data,
+	})
+}
@@ -0,0 +1,102 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type objectWriter struct {
+	types.ResponseWriter
+	code int
+	obj  types.APIObject
+}
+
+func (o *objectWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	o.code, o.obj = code, obj
+}
+
+func TestFromStatus(t *testing.T) {
+	invalid := apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "web",
+		field.ErrorList{field.Required(field.NewPath("spec", "template"), "")})
+	tests := []struct {
+		name          string
+		err           error
+		wantCode      validation.ErrorCode
+		wantFieldName string
+		wantSame      bool
+	}{
+		{
+			name:     "not found",
+			err:      apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web-1"),
+			wantCode: validation.ErrorCode{Code: "NotFound", Status: http.StatusNotFound},
+		},
+		{
+			name:          "invalid",
+			err:           invalid,
+			wantCode:      validation.ErrorCode{Code: "Invalid", Status: http.StatusUnprocessableEntity},
+			wantFieldName: "spec.template",
+		},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("listing partition: %w", apierrors.NewTooManyRequests("slow down", 5)),
+			wantCode: validation.ErrorCode{Code: "TooManyRequests", Status: http.StatusTooManyRequests},
+		},
+		{
+			name:     "api error",
+			err:      apierror.NewAPIError(validation.NotFound, "missing"),
+			wantSame: true,
+		},
+		{
+			name:     "other error",
+			err:      errors.New("failed"),
+			wantSame: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := FromStatus(test.err)
+			if test.wantSame {
+				assert.Equal(t, test.err, err)
+				return
+			}
+			var apiErr *apierror.APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, test.wantCode, apiErr.Code)
+			assert.Equal(t, test.wantFieldName, apiErr.FieldName)
+			assert.Equal(t, test.err, apiErr.Cause)
+		})
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+	w := &objectWriter{}
+	apiOp := &types.APIRequest{
+		Request:        httptest.NewRequest(http.MethodGet, "/v1/pods", nil),
+		Response:       rw,
+		ResponseWriter: w,
+	}
+
+	ErrorHandler(apiOp, apierrors.NewTooManyRequests("slow down", 5))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.code)
+	assert.Equal(t, "5", rw.Header().Get("Retry-After"))
+	data := w.obj.Object.(map[string]interface{})
+	assert.Equal(t, "TooManyRequests", data["code"])
+	assert.Equal(t, "slow down", data["message"])
+	assert.Equal(t, &metav1.StatusDetails{RetryAfterSeconds: 5}, data["details"])
+}
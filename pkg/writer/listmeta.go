@@ -0,0 +1,146 @@
+// Package writer provides response writers that extend the standard collection format with
+// fields computed by the stores while a list is being served.
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+type listMetaKey struct{}
+
+// ListMeta holds collection level fields that a store computes while serving a list request.
+// A ListMeta is attached to the request context by the API handler and read back by the response writer,
+// since types.APIObjectList has no room for fields beyond the revision and continue token.
+type ListMeta struct {
+	lock sync.Mutex
+
+	// Pages is the total number of pages when the list was requested with a page size.
+	Pages int `json:"pages,omitempty"`
+
+	// Count is the total number of objects in the list across all partitions, before pagination.
+	// It is only set when the store knows the complete list.
+	Count *int `json:"count,omitempty"`
+
+	// PartialErrors lists the partitions that could not be listed when the list was requested in partial mode.
+	PartialErrors []PartialError `json:"partialErrors,omitempty"`
+
+	// PartitionsChanged is set when partitions were added or removed while the list was being walked.
+	PartitionsChanged bool `json:"partitionsChanged,omitempty"`
+
+	// Warnings are the warnings kubernetes returned while the request was served, such as deprecation notices.
+	// They are also returned as Warning headers, which are the only place they appear for single objects.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PartialError describes a partition that was skipped because listing it failed.
+type PartialError struct {
+	Partition string `json:"partition,omitempty"`
+	Message   string `json:"message"`
+	// Code is the code of the API error or kubernetes status the list failed with, if any.
+	Code string `json:"code,omitempty"`
+}
+
+// WithListMeta returns a copy of ctx with an empty ListMeta attached.
+func WithListMeta(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listMetaKey{}, &ListMeta{})
+}
+
+// ListMetaFrom returns the ListMeta attached to ctx, or nil if there is none.
+// All ListMeta methods are safe to call on a nil ListMeta.
+func ListMetaFrom(ctx context.Context) *ListMeta {
+	m, _ := ctx.Value(listMetaKey{}).(*ListMeta)
+	return m
+}
+
+// SetPages records the total number of pages.
+func (m *ListMeta) SetPages(pages int) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Pages = pages
+}
+
+// SetCount records the total number of objects in the list.
+func (m *ListMeta) SetCount(count int) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Count = &count
+}
+
+// AddPartialError records that listing a partition failed and was skipped.
+func (m *ListMeta) AddPartialError(partition string, err error) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	partialErr := PartialError{
+		Partition: partition,
+		Message:   err.Error(),
+	}
+	var apiErr *apierror.APIError
+	if errors.As(FromStatus(err), &apiErr) {
+		partialErr.Code = apiErr.Code.Code
+	}
+	m.PartialErrors = append(m.PartialErrors, partialErr)
+}
+
+// HasPartialErrors reports whether any partition was skipped.
+func (m *ListMeta) HasPartialErrors() bool {
+	if m == nil {
+		return false
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.PartialErrors) > 0
+}
+
+// SetPartitionsChanged records that the partition set changed since the list was started.
+func (m *ListMeta) SetPartitionsChanged() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.PartitionsChanged = true
+}
+
+// AddWarning records a warning kubernetes returned, unless it was already recorded.
+func (m *ListMeta) AddWarning(text string) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, warning := range m.Warnings {
+		if warning == text {
+			return
+		}
+	}
+	m.Warnings = append(m.Warnings, text)
+}
+
+// addWarningHeaders adds the recorded warnings to the response as Warning headers.
+func (m *ListMeta) addWarningHeaders(rw http.ResponseWriter) {
+	if m == nil || rw == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, warning := range m.Warnings {
+		if header, err := utilnet.NewWarningHeader(299, "-", warning); err == nil {
+			rw.Header().Add("Warning", header)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package writer
+
+import (
+	"io"
+
+	"github.com/rancher/apiserver/pkg/types"
+	apiwriter "github.com/rancher/apiserver/pkg/writer"
+)
+
+// ResponseWriter is an encoding response writer which adds the request's ListMeta fields to collections, and its
+// warnings to every response as Warning headers.
+type ResponseWriter struct {
+	ContentType string
+	Encoder     func(io.Writer, interface{}) error
+}
+
+type collection struct {
+	*types.GenericCollection
+	*ListMeta
+}
+
+// Write writes a single object.
+func (r *ResponseWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	ListMetaFrom(apiOp.Context()).addWarningHeaders(apiOp.Response)
+	r.writer(apiOp).Write(apiOp, code, obj)
+}
+
+// WriteList writes a collection, including any fields recorded in the request's ListMeta.
+func (r *ResponseWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	ListMetaFrom(apiOp.Context()).addWarningHeaders(apiOp.Response)
+	r.writer(apiOp).WriteList(apiOp, code, list)
+}
+
+func (r *ResponseWriter) writer(apiOp *types.APIRequest) *apiwriter.EncodingResponseWriter {
+	meta := ListMetaFrom(apiOp.Context())
+	return &apiwriter.EncodingResponseWriter{
+		ContentType: r.ContentType,
+		Encoder: func(w io.Writer, v interface{}) error {
+			if c, ok := v.(*types.GenericCollection); ok && meta != nil {
+				meta.lock.Lock()
+				defer meta.lock.Unlock()
+				v = &collection{
+					GenericCollection: c,
+					ListMeta:          meta,
+				}
+			}
+			return r.Encoder(w, v)
+		},
+	}
+}